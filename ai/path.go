@@ -0,0 +1,283 @@
+// package ai implements navigation helpers for entities moving over the voxel
+// terrain.
+package ai
+
+import (
+	"container/heap"
+)
+
+// Grid is the voxel terrain queried by the pathfinder.
+type Grid interface {
+	// Solid returns true if the block at the provided position blocks
+	// movement.
+	Solid(x, y, z int) bool
+}
+
+// Point is a block position in the voxel grid.
+type Point struct {
+	X, Y, Z int
+}
+
+func (p Point) add(x, y, z int) Point {
+	return Point{p.X + x, p.Y + y, p.Z + z}
+}
+
+// Agent describes the movement capabilities of the entity following the path.
+type Agent struct {
+	// Height is the number of blocks of clearance the agent needs.
+	Height int
+	// StepHeight is how many blocks the agent can climb in a single move.
+	StepHeight int
+	// MaxDrop is how many blocks the agent can drop in a single move.
+	MaxDrop int
+	// JumpGap is the number of empty blocks the agent can jump over.
+	JumpGap int
+}
+
+// DefaultAgent is a player-sized agent that can climb a single block.
+var DefaultAgent = Agent{
+	Height:     2,
+	StepHeight: 1,
+	MaxDrop:    3,
+	JumpGap:    1,
+}
+
+// DefaultMaxNodes is the default limit of nodes expanded by FindPath.
+const DefaultMaxNodes = 4096
+
+var directions = [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+
+// Walkable returns true if the agent can stand at the position p: the block
+// below is solid and there is enough clearance above.
+func (a Agent) Walkable(g Grid, p Point) bool {
+	if !g.Solid(p.X, p.Y-1, p.Z) {
+		return false
+	}
+	return a.clear(g, p, 0)
+}
+
+// clear returns true if there is room for the agent at p, plus extra blocks of
+// headroom.
+func (a Agent) clear(g Grid, p Point, extra int) bool {
+	for y := 0; y < a.Height+extra; y++ {
+		if g.Solid(p.X, p.Y+y, p.Z) {
+			return false
+		}
+	}
+	return true
+}
+
+// neighbors returns the positions reachable in a single move from p.
+func (a Agent) neighbors(g Grid, p Point, out []Point) []Point {
+	for _, d := range directions {
+		dx, dz := d[0], d[1]
+		for dist := 1; dist <= a.JumpGap+1; dist++ {
+			next := p.add(dx*dist, 0, dz*dist)
+			if dist > 1 {
+				// Jumping requires the gap we pass over to be empty, with one
+				// extra block of headroom.
+				gap := p.add(dx*(dist-1), 0, dz*(dist-1))
+				if !a.clear(g, gap, 1) || g.Solid(gap.X, gap.Y-1, gap.Z) {
+					break
+				}
+			}
+			if n, ok := a.landing(g, p, next); ok {
+				out = append(out, n)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// landing finds a walkable position at the column of next, within the step
+// height and maximum drop of the agent.
+func (a Agent) landing(g Grid, from, next Point) (Point, bool) {
+	for dy := a.StepHeight; dy >= -a.MaxDrop; dy-- {
+		n := next.add(0, dy, 0)
+		if !a.Walkable(g, n) {
+			continue
+		}
+		// Make sure we have headroom to climb or drop into the new position.
+		if dy > 0 && !a.clear(g, from, dy) {
+			continue
+		}
+		if dy < 0 && !a.clear(g, next, 0) {
+			continue
+		}
+		return n, true
+	}
+	return Point{}, false
+}
+
+// FindPath returns the path from start to goal using the A* algorithm. The
+// returned path includes both start and goal. It returns false if there is no
+// path, or if maxNodes were expanded without reaching the goal. If maxNodes is
+// zero, DefaultMaxNodes is used.
+func FindPath(g Grid, a Agent, start, goal Point, maxNodes int) ([]Point, bool) {
+	if maxNodes <= 0 {
+		maxNodes = DefaultMaxNodes
+	}
+	if !a.Walkable(g, goal) {
+		return nil, false
+	}
+
+	open := &nodeQueue{}
+	nodes := map[Point]*node{}
+	first := &node{p: start, h: heuristic(start, goal)}
+	nodes[start] = first
+	heap.Push(open, first)
+
+	var buf []Point
+	for expanded := 0; open.Len() > 0 && expanded < maxNodes; expanded++ {
+		cur := heap.Pop(open).(*node)
+		if cur.p == goal {
+			return reconstruct(cur), true
+		}
+		cur.closed = true
+
+		buf = a.neighbors(g, cur.p, buf[:0])
+		for _, np := range buf {
+			cost := cur.g + moveCost(cur.p, np)
+			n, ok := nodes[np]
+			if !ok {
+				n = &node{p: np, g: cost, h: heuristic(np, goal), parent: cur}
+				nodes[np] = n
+				heap.Push(open, n)
+				continue
+			}
+			if n.closed || cost >= n.g {
+				continue
+			}
+			n.g, n.parent = cost, cur
+			heap.Fix(open, n.index)
+		}
+	}
+	return nil, false
+}
+
+// heuristic estimates the cost from a to b. It must never exceed the cost of
+// the moves, or the search returns paths longer than the shortest one.
+func heuristic(a, b Point) int {
+	return moveCost(a, b)
+}
+
+func moveCost(a, b Point) int {
+	// Horizontal distance plus a penalty for climbing or dropping.
+	return 10*(abs(a.X-b.X)+abs(a.Z-b.Z)) + 5*abs(a.Y-b.Y)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func reconstruct(n *node) []Point {
+	var path []Point
+	for ; n != nil; n = n.parent {
+		path = append(path, n.p)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// Smooth removes intermediate points of the path that can be skipped by
+// walking in a straight line over flat terrain.
+func Smooth(g Grid, a Agent, path []Point) []Point {
+	if len(path) < 3 {
+		return path
+	}
+	out := []Point{path[0]}
+	anchor := path[0]
+	for i := 1; i < len(path)-1; i++ {
+		if !a.straight(g, anchor, path[i+1]) {
+			anchor = path[i]
+			out = append(out, anchor)
+		}
+	}
+	return append(out, path[len(path)-1])
+}
+
+// straight returns true if all blocks on the line between a and b, at the same
+// height, are walkable.
+func (a Agent) straight(g Grid, from, to Point) bool {
+	if from.Y != to.Y {
+		return false
+	}
+	dx, dz := to.X-from.X, to.Z-from.Z
+	steps := abs(dx)
+	if abs(dz) > steps {
+		steps = abs(dz)
+	}
+	for i := 1; i < steps; i++ {
+		// Sample the line at each block; both the floor and the cells touched
+		// when rounding up or down must be walkable.
+		fx := float64(from.X) + float64(dx*i)/float64(steps)
+		fz := float64(from.Z) + float64(dz*i)/float64(steps)
+		for _, x := range []int{floor(fx), ceil(fx)} {
+			for _, z := range []int{floor(fz), ceil(fz)} {
+				if !a.Walkable(g, Point{x, from.Y, z}) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func floor(v float64) int {
+	i := int(v)
+	if float64(i) > v {
+		i--
+	}
+	return i
+}
+
+func ceil(v float64) int {
+	i := int(v)
+	if float64(i) < v {
+		i++
+	}
+	return i
+}
+
+// node is an entry in the A* open set.
+type node struct {
+	p      Point
+	g, h   int
+	parent *node
+	closed bool
+	index  int
+}
+
+// nodeQueue implements heap.Interface ordered by the node f = g + h cost.
+type nodeQueue []*node
+
+func (q nodeQueue) Len() int { return len(q) }
+
+func (q nodeQueue) Less(i, j int) bool {
+	return q[i].g+q[i].h < q[j].g+q[j].h
+}
+
+func (q nodeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *nodeQueue) Push(x any) {
+	n := x.(*node)
+	n.index = len(*q)
+	*q = append(*q, n)
+}
+
+func (q *nodeQueue) Pop() any {
+	old := *q
+	n := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return n
+}
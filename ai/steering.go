@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"encoding/json"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Steering moves an entity along a path, one waypoint at a time. It is an
+// entity.Component, saved with the path being followed, and the game applies
+// the velocity returned by Velocity to the entity position on each update.
+type Steering struct {
+	// MaxSpeed is the maximum velocity, in blocks per second.
+	MaxSpeed float32
+	// ArriveRadius is the distance to a waypoint considered as reaching it.
+	ArriveRadius float32
+
+	path []Point
+	next int
+}
+
+// savedSteering is the state of a Steering saved with its entity.
+type savedSteering struct {
+	MaxSpeed, ArriveRadius float32
+	Path                   []Point
+	Next                   int
+}
+
+// NewSteering creates a steering component with sensible defaults.
+func NewSteering() *Steering {
+	return &Steering{
+		MaxSpeed:     4.3,
+		ArriveRadius: 0.2,
+	}
+}
+
+// Follow makes the entity follow the provided path from the start.
+func (s *Steering) Follow(path []Point) {
+	s.path = path
+	s.next = 0
+}
+
+// Done returns true if there is no path, or the end of the path was reached.
+func (s *Steering) Done() bool {
+	return s.next >= len(s.path)
+}
+
+// Velocity returns the velocity the entity at pos must use to move towards the
+// next waypoint. Waypoints are the bottom center of the block positions in
+// the path. Only the horizontal components are steered; vertical movement is
+// left to the physics integration.
+func (s *Steering) Velocity(pos glm.Vec3) glm.Vec3 {
+	for !s.Done() {
+		p := s.path[s.next]
+		target := glm.Vec3{float32(p.X) + 0.5, pos.Y(), float32(p.Z) + 0.5}
+		delta := target.Sub(pos)
+		dist := delta.Len()
+		if dist <= s.ArriveRadius {
+			s.next++
+			continue
+		}
+		speed := s.MaxSpeed
+		if s.next == len(s.path)-1 && dist < 1 {
+			// Slow down when arriving at the last waypoint
+			speed *= dist
+		}
+		return delta.Mul(speed / dist)
+	}
+	return glm.Vec3{}
+}
+
+func (s *Steering) MarshalBinary() ([]byte, error) {
+	return json.Marshal(savedSteering{s.MaxSpeed, s.ArriveRadius, s.path, s.next})
+}
+
+func (s *Steering) UnmarshalBinary(b []byte) error {
+	var v savedSteering
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	s.MaxSpeed, s.ArriveRadius, s.path, s.next = v.MaxSpeed, v.ArriveRadius, v.Path, v.Next
+	return nil
+}
@@ -0,0 +1,5 @@
+package assets
+
+// run does nothing, as NewWatcher fails in the browser, where files can't be
+// watched.
+func (w *Watcher) run() {}
@@ -0,0 +1,35 @@
+//go:build !js
+
+package assets
+
+import (
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// run records the changed files until the watcher is closed.
+func (w *Watcher) run() {
+	for {
+		select {
+		case e, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			// Editors often save by writing a new file and renaming it over
+			// the old one, so creations count as changes too.
+			if e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			w.mu.Lock()
+			w.changed[filepath.Clean(e.Name)] = true
+			w.mu.Unlock()
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			log.Warnf("Error watching the asset files: %v", err)
+		}
+	}
+}
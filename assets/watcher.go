@@ -0,0 +1,262 @@
+// package assets reloads the textures, block definitions and block atlases of
+// the game when their files change, so artists see their edits without restarting the game.
+// It is meant for development builds: the files are watched with fsnotify,
+// which is not available in the browser.
+package assets
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ronoaldo/openvoxel/atlas"
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/log"
+	"github.com/ronoaldo/openvoxel/render"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// blockFile is a watched block definition file.
+type blockFile struct {
+	reg *block.Registry
+	m   *world.Map
+}
+
+// atlasDir is a watched directory of block textures.
+type atlasDir struct {
+	dir   string
+	atlas *atlas.Atlas
+	array *render.TextureArray
+}
+
+// Watcher watches the asset files and reloads them when they change.
+//
+// The changes are detected in the background, but only applied by Update,
+// which must be called from the render thread, as reloading textures uploads
+// them to the GPU.
+type Watcher struct {
+	Bus *event.Bus
+
+	fs       *fsnotify.Watcher
+	dirs     map[string]bool
+	textures map[string]*render.Texture
+	blocks   map[string]blockFile
+	atlases  []atlasDir
+
+	mu      sync.Mutex
+	changed map[string]bool
+}
+
+// NewWatcher starts watching for file changes. It returns an error where file
+// watching is not supported.
+func NewWatcher(bus *event.Bus) (*Watcher, error) {
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		Bus:      bus,
+		fs:       fs,
+		dirs:     map[string]bool{},
+		textures: map[string]*render.Texture{},
+		blocks:   map[string]blockFile{},
+		changed:  map[string]bool{},
+	}
+	go w.run()
+	return w, nil
+}
+
+// watch adds the directory of the file to the watched directories. Files are
+// not watched directly, as renaming a file over them stops the notifications.
+func (w *Watcher) watch(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(path)
+	if !w.dirs[dir] {
+		if err := w.fs.Add(dir); err != nil {
+			return "", err
+		}
+		w.dirs[dir] = true
+	}
+	return path, nil
+}
+
+// Texture reloads the texture, loaded from the image file at path, when the
+// file changes.
+func (w *Watcher) Texture(path string, t *render.Texture) error {
+	path, err := w.watch(path)
+	if err != nil {
+		return err
+	}
+	w.textures[path] = t
+	return nil
+}
+
+// Blocks defines the blocks of the definition file at path in the registry,
+// and defines them again when the file changes. Chunks of the map using the
+// changed blocks are published with event.BlocksRedefined.
+func (w *Watcher) Blocks(path string, reg *block.Registry, m *world.Map) error {
+	path, err := w.watch(path)
+	if err != nil {
+		return err
+	}
+	w.blocks[path] = blockFile{reg, m}
+	return w.reloadBlocks(path, w.blocks[path])
+}
+
+// Atlas builds the atlas, uploads it to the array, and builds and uploads it
+// again when the files inside dir, where the atlas loads its textures from,
+// change, or when the block definitions are reloaded. It returns the error of
+// the first build, as Atlas.Build does.
+func (w *Watcher) Atlas(dir string, a *atlas.Atlas, t *render.TextureArray) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || w.dirs[path] {
+			return err
+		}
+		if err := w.fs.Add(path); err != nil {
+			return err
+		}
+		w.dirs[path] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	at := atlasDir{dir, a, t}
+	w.atlases = append(w.atlases, at)
+	return w.rebuildAtlas(at)
+}
+
+// Update applies the changes of the files since the last call. Failed reloads
+// are logged, keeping the previous assets, so the artist can fix the file and
+// save it again.
+func (w *Watcher) Update() {
+	w.mu.Lock()
+	changed := make([]string, 0, len(w.changed))
+	for path := range w.changed {
+		changed = append(changed, path)
+	}
+	w.changed = map[string]bool{}
+	w.mu.Unlock()
+	sort.Strings(changed)
+
+	dirty := make([]bool, len(w.atlases))
+	for _, path := range changed {
+		for i, at := range w.atlases {
+			if strings.HasPrefix(path, at.dir+string(filepath.Separator)) {
+				dirty[i] = true
+			}
+		}
+		if t, ok := w.textures[path]; ok {
+			if err := w.reloadTexture(path, t); err != nil {
+				log.Warnf("Error reloading the texture %v: %v", path, err)
+			} else {
+				log.Infof("Reloaded the texture %v", path)
+			}
+		}
+		if f, ok := w.blocks[path]; ok {
+			if err := w.reloadBlocks(path, f); err != nil {
+				log.Warnf("Error reloading the blocks %v: %v", path, err)
+			} else {
+				log.Infof("Reloaded the blocks %v", path)
+				// The blocks may use new textures.
+				for i := range dirty {
+					dirty[i] = true
+				}
+			}
+		}
+	}
+	for i, at := range w.atlases {
+		if !dirty[i] {
+			continue
+		}
+		if err := w.rebuildAtlas(at); err != nil {
+			log.Warnf("Error reloading the atlas %v: %v", at.dir, err)
+		} else {
+			log.Infof("Reloaded the atlas %v", at.dir)
+		}
+	}
+}
+
+func (w *Watcher) reloadTexture(path string, t *render.Texture) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := t.Reload(b); err != nil {
+		return err
+	}
+	event.Publish(w.Bus, event.TextureReloaded{Path: path})
+	return nil
+}
+
+// rebuildAtlas builds the atlas and uploads it, even if some textures failed
+// to load, as they are replaced by the missing texture.
+func (w *Watcher) rebuildAtlas(at atlasDir) error {
+	err := at.atlas.Build()
+	at.array.Update(at.atlas.Images())
+	event.Publish(w.Bus, event.TextureReloaded{Path: at.dir})
+	return err
+}
+
+func (w *Watcher) reloadBlocks(path string, f blockFile) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	defs, err := block.ParseDefinitions(b)
+	if err != nil {
+		return err
+	}
+	changed := map[block.ID]bool{}
+	var ids []uint16
+	for _, def := range defs {
+		if id, ok := f.reg.Define(def); ok {
+			changed[id] = true
+			ids = append(ids, uint16(id))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	e := event.BlocksRedefined{IDs: ids}
+	if f.m != nil {
+		f.m.Each(func(c *world.Chunk) {
+			for _, s := range c.Palette() {
+				if changed[s.ID] {
+					p := c.Pos()
+					e.Chunks = append(e.Chunks, [3]int{p.X, p.Y, p.Z})
+					return
+				}
+			}
+		})
+		sort.Slice(e.Chunks, func(i, j int) bool {
+			a, b := e.Chunks[i], e.Chunks[j]
+			if a[1] != b[1] {
+				return a[1] < b[1]
+			}
+			if a[0] != b[0] {
+				return a[0] < b[0]
+			}
+			return a[2] < b[2]
+		})
+	}
+	event.Publish(w.Bus, e)
+	return nil
+}
+
+// Close stops watching the files.
+func (w *Watcher) Close() error {
+	return w.fs.Close()
+}
@@ -0,0 +1,215 @@
+// package atlas gathers the textures of the registered blocks into the layers
+// of a texture array, and tells the mesher the layer of each face.
+//
+// Each texture gets its own layer, so the faces merged by the greedy mesher
+// repeat the texture without bleeding into the neighbor textures, unlike the
+// tiles of a single atlas image. Textures of different sizes are scaled to the
+// size of the largest one.
+package atlas
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+// LayerLimit is the largest number of layers, as the packed vertices store
+// the layer in 8 bits.
+const LayerLimit = 256
+
+// Missing is the layer of the faces without texture, or whose texture could
+// not be loaded: a magenta and black checkerboard.
+const Missing uint8 = 0
+
+// Atlas is the texture array of the blocks of a registry.
+type Atlas struct {
+	// Registry provides the texture names of the blocks and models.
+	Registry *block.Registry
+	// Load reads the texture with the name, such as "block/stone".
+	Load func(name string) (image.Image, error)
+	// MaxSize limits the width and height of the layers, usually
+	// render.Caps.MaxTextureSize, and MaxLayers their number, usually
+	// render.Caps.MaxArrayTextureLayers. Zero does not limit the size, and
+	// the number of layers is always limited to LayerLimit.
+	MaxSize, MaxLayers int
+
+	mu     sync.RWMutex
+	layers map[string]uint8
+	faces  [][6]uint8
+	images []*image.RGBA
+}
+
+// Build loads the textures used by the registry into the layers, such as
+// after the blocks or textures are reloaded. Textures already in the atlas keep
+// their layers, so the meshes built before stay valid. The textures that fail
+// to load or do not fit use the Missing layer, and are reported in the
+// returned error, so the atlas is usable anyway.
+func (a *Atlas) Build() error {
+	limit := a.MaxLayers
+	if limit <= 0 || limit > LayerLimit {
+		limit = LayerLimit
+	}
+	a.mu.RLock()
+	prev := a.layers
+	a.mu.RUnlock()
+
+	var errs []error
+	layers := map[string]uint8{}
+	// loaded holds the image of each layer, nil for the Missing layer and
+	// the free ones.
+	loaded := []image.Image{nil}
+	load := func(name string, layer int) {
+		img, err := a.Load(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("atlas: %q: %w", name, err))
+			return
+		}
+		for len(loaded) <= layer {
+			loaded = append(loaded, nil)
+		}
+		loaded[layer] = img
+		layers[name] = uint8(layer)
+	}
+	var added []string
+	for _, name := range a.names() {
+		if layer, ok := prev[name]; ok {
+			load(name, int(layer))
+		} else {
+			added = append(added, name)
+		}
+	}
+	free := 1
+	for _, name := range added {
+		for free < len(loaded) && loaded[free] != nil {
+			free++
+		}
+		if free >= limit {
+			errs = append(errs, fmt.Errorf("atlas: %q does not fit in %d layers", name, limit))
+			continue
+		}
+		load(name, free)
+	}
+
+	size := 16
+	for _, img := range loaded {
+		if img == nil {
+			continue
+		}
+		if b := img.Bounds(); b.Dx() > size || b.Dy() > size {
+			size = b.Dx()
+			if b.Dy() > size {
+				size = b.Dy()
+			}
+		}
+	}
+	if a.MaxSize > 0 && size > a.MaxSize {
+		size = a.MaxSize
+	}
+	images := make([]*image.RGBA, len(loaded))
+	for i, img := range loaded {
+		if img == nil {
+			images[i] = checkerboard(size)
+		} else {
+			images[i] = scale(img, size)
+		}
+	}
+	faces := make([][6]uint8, a.Registry.Len())
+	for id := range faces {
+		for f, name := range a.Registry.Get(block.ID(id)).Textures {
+			faces[id][f] = layers[name]
+		}
+	}
+
+	a.mu.Lock()
+	a.layers, a.faces, a.images = layers, faces, images
+	a.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// names returns the texture names used by the registry, in the order of the
+// blocks.
+func (a *Atlas) names() []string {
+	seen := map[string]bool{"": true}
+	var names []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for id := 0; id < a.Registry.Len(); id++ {
+		def := a.Registry.Get(block.ID(id))
+		for _, name := range def.Textures {
+			add(name)
+		}
+		if def.Model == nil {
+			continue
+		}
+		for _, e := range def.Model.Elements {
+			for _, face := range e.Faces {
+				if face != nil {
+					add(face.Texture)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// Layer returns the layer of the face of the block, in the order +X, -X, +Y,
+// -Y, +Z, -Z.
+func (a *Atlas) Layer(id block.ID, face int) uint8 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if int(id) >= len(a.faces) {
+		return Missing
+	}
+	return a.faces[id][face]
+}
+
+// Named returns the layer of the texture with the name, such as the textures
+// of the model faces.
+func (a *Atlas) Named(name string) uint8 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.layers[name]
+}
+
+// Images returns the layers, all with the same size, to be uploaded with
+// render.TextureArray.Update.
+func (a *Atlas) Images() []*image.RGBA {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.images
+}
+
+// checkerboard returns the Missing texture.
+func checkerboard(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			i := img.PixOffset(x, y)
+			if (x*2/size+y*2/size)%2 == 0 {
+				img.Pix[i], img.Pix[i+2] = 255, 255
+			}
+			img.Pix[i+3] = 255
+		}
+	}
+	return img
+}
+
+// scale resizes the image to size x size pixels, without filtering, to keep
+// the pixel art look.
+func scale(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			out.Set(x, y, img.At(b.Min.X+x*b.Dx()/size, b.Min.Y+y*b.Dy()/size))
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+// package audio computes how sounds are heard in the voxel world: how much
+// they are muffled by the blocks between the source and the listener, and how
+// much they echo in the space around the listener.
+//
+// There is no sound effect playback backend yet, and music only plays on the
+// web. The Params returned are meant to be applied to the gain, low-pass
+// filter and reverb send of each voice of a mixer.
+package audio
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Volume is the voxel storage sounds travel through.
+type Volume interface {
+	// Solid returns true if the block muffles the sounds crossing it.
+	Solid(x, y, z int) bool
+}
+
+// Params are the settings a voice is played with.
+type Params struct {
+	// Gain multiplies the volume of the sound, from 0 to 1.
+	Gain float32
+	// Cutoff is the frequency of the low-pass filter, in Hz. Occluded
+	// sounds lose their high frequencies first.
+	Cutoff float32
+	// Reverb is the amount of the sound sent to the reverb, from 0 to 1,
+	// and Decay the reverb time, in seconds.
+	Reverb, Decay float32
+}
+
+// Lerp interpolates between the parameters, so changes of occlusion or
+// reverb can be smoothed over a few frames instead of heard as clicks.
+func (p Params) Lerp(o Params, t float32) Params {
+	l := func(a, b float32) float32 { return a + (b-a)*t }
+	return Params{l(p.Gain, o.Gain), l(p.Cutoff, o.Cutoff), l(p.Reverb, o.Reverb), l(p.Decay, o.Decay)}
+}
+
+// Occlusion returns the number of solid blocks crossed by the segment from
+// the source to the listener, up to max. The blocks containing both ends are
+// not counted, so sounds of blocks, such as a furnace, are not muffled by
+// themselves.
+func Occlusion(v Volume, source, listener glm.Vec3, max int) int {
+	n := 0
+	start := blockOf(source)
+	end := blockOf(listener)
+	traverse(source, listener, func(b [3]int) bool {
+		if b != start && b != end && v.Solid(b[0], b[1], b[2]) {
+			n++
+		}
+		return n < max
+	})
+	return n
+}
+
+func blockOf(p glm.Vec3) [3]int {
+	return [3]int{
+		int(math.Floor(float64(p[0]))),
+		int(math.Floor(float64(p[1]))),
+		int(math.Floor(float64(p[2]))),
+	}
+}
+
+// traverse calls fn with each block crossed by the segment from a to b, in
+// order, until fn returns false.
+func traverse(a, b glm.Vec3, fn func(b [3]int) bool) {
+	d := b.Sub(a)
+	length := d.Len()
+	cell, last := blockOf(a), blockOf(b)
+	if length == 0 {
+		fn(cell)
+		return
+	}
+	var step [3]int
+	var next, delta [3]float32
+	for i := 0; i < 3; i++ {
+		switch {
+		case d[i] > 0:
+			step[i] = 1
+			delta[i] = length / d[i]
+			next[i] = (float32(cell[i]+1) - a[i]) / d[i] * length
+		case d[i] < 0:
+			step[i] = -1
+			delta[i] = -length / d[i]
+			next[i] = (a[i] - float32(cell[i])) / -d[i] * length
+		default:
+			delta[i] = float32(math.Inf(1))
+			next[i] = float32(math.Inf(1))
+		}
+	}
+	for {
+		if !fn(cell) || cell == last {
+			return
+		}
+		axis := 0
+		if next[1] < next[axis] {
+			axis = 1
+		}
+		if next[2] < next[axis] {
+			axis = 2
+		}
+		if next[axis] > length {
+			return
+		}
+		cell[axis] += step[axis]
+		next[axis] += delta[axis]
+	}
+}
@@ -0,0 +1,50 @@
+package audio
+
+// Bus groups sounds that share a volume setting.
+type Bus int
+
+const (
+	BusMusic Bus = iota
+	BusSFX
+)
+
+// Buses holds the volume settings, from 0 to 1. The gain of a sound is the
+// Master volume times the volume of its bus.
+type Buses struct {
+	Master, Music, SFX float32
+}
+
+// DefaultBuses plays everything at full volume.
+var DefaultBuses = Buses{Master: 1, Music: 1, SFX: 1}
+
+var buses = DefaultBuses
+
+// SetBuses changes the volume settings, clamping them to [0, 1].
+func SetBuses(b Buses) {
+	clamp := func(v float32) float32 {
+		if v < 0 {
+			return 0
+		}
+		if v > 1 {
+			return 1
+		}
+		return v
+	}
+	buses = Buses{clamp(b.Master), clamp(b.Music), clamp(b.SFX)}
+}
+
+// CurrentBuses returns the volume settings in use.
+func CurrentBuses() Buses {
+	return buses
+}
+
+// Gain returns the volume of the bus, including the master volume.
+func (b Buses) Gain(bus Bus) float32 {
+	switch bus {
+	case BusMusic:
+		return b.Master * b.Music
+	case BusSFX:
+		return b.Master * b.SFX
+	}
+	return b.Master
+}
@@ -0,0 +1,180 @@
+package audio
+
+import (
+	"errors"
+
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// Track is a music file, streamed while it plays.
+type Track struct {
+	Name string
+	// URL is the address of the OGG file, relative to the page.
+	URL string
+}
+
+// ErrNoPlayer is returned by DefaultPlayer on the platforms without music
+// playback. Only the web build plays music.
+var ErrNoPlayer = errors.New("audio: no music player on this platform")
+
+// Player streams tracks. DefaultPlayer returns the one of the platform.
+type Player interface {
+	Play(t Track) (Voice, error)
+}
+
+// Voice is a track being played.
+type Voice interface {
+	// SetGain changes the volume, from 0 to 1.
+	SetGain(gain float32)
+	// Done returns true when the track ended.
+	Done() bool
+	// Stop ends the playback and releases the voice.
+	Stop()
+}
+
+// Mood is a set of tracks played in a situation, such as at night, in a biome
+// or during combat. When several moods are active, the one with the highest
+// Priority plays.
+type Mood struct {
+	Name     string
+	Priority int
+	Tracks   []Track
+}
+
+type playing struct {
+	mood  string
+	voice Voice
+	// fade is the crossfade position, from 0 (silent) to 1, and dir the
+	// direction it moves: 1 fading in, -1 fading out.
+	fade float32
+	dir  float32
+}
+
+// Soundtrack chooses the music from the active moods, crossfading when the
+// mood changes. Moods are activated by the game, directly with Set or from
+// events with TriggerOn. Music only plays on the web, where DefaultPlayer
+// returns a Player.
+type Soundtrack struct {
+	Player Player
+	// Fade is the crossfade duration, in seconds.
+	Fade float32
+
+	moods  map[string]*Mood
+	next   map[string]int
+	active map[string]bool
+	voices []*playing
+}
+
+// NewSoundtrack creates a soundtrack without moods, played with the player.
+func NewSoundtrack(p Player) *Soundtrack {
+	return &Soundtrack{
+		Player: p,
+		Fade:   3,
+		moods:  map[string]*Mood{},
+		next:   map[string]int{},
+		active: map[string]bool{},
+	}
+}
+
+// Add registers the mood, replacing any mood with the same name.
+func (s *Soundtrack) Add(m Mood) {
+	s.moods[m.Name] = &m
+}
+
+// Set activates or deactivates the mood.
+func (s *Soundtrack) Set(mood string, active bool) {
+	if active {
+		s.active[mood] = true
+	} else {
+		delete(s.active, mood)
+	}
+}
+
+// Active returns true if the mood is active.
+func (s *Soundtrack) Active(mood string) bool {
+	return s.active[mood]
+}
+
+// TriggerOn sets the mood whenever an event of type E is published on the bus,
+// to the value returned by active, such as activating the "combat" mood when
+// the player is hurt. It returns a function that removes the subscription.
+func TriggerOn[E any](s *Soundtrack, b *event.Bus, mood string, active func(e E) bool) (unsubscribe func()) {
+	return event.Subscribe(b, func(e E) {
+		s.Set(mood, active(e))
+	})
+}
+
+// current returns the active mood with the highest priority, or nil.
+func (s *Soundtrack) current() *Mood {
+	var best *Mood
+	for name := range s.active {
+		m := s.moods[name]
+		if m == nil || len(m.Tracks) == 0 {
+			continue
+		}
+		if best == nil || m.Priority > best.Priority || (m.Priority == best.Priority && m.Name < best.Name) {
+			best = m
+		}
+	}
+	return best
+}
+
+// Update advances the crossfades by dt seconds, starts the tracks of the
+// current mood, and applies the music volume. It must be called once per
+// frame.
+func (s *Soundtrack) Update(dt float32) {
+	mood := s.current()
+	var lead *playing
+	for _, v := range s.voices {
+		if v.dir > 0 {
+			lead = v
+		}
+	}
+	// Fade the leading track out when the mood changes or it ended.
+	if lead != nil && (mood == nil || lead.mood != mood.Name || lead.voice.Done()) {
+		lead.dir = -1
+		lead = nil
+	}
+	if lead == nil && mood != nil {
+		t := mood.Tracks[s.next[mood.Name]%len(mood.Tracks)]
+		s.next[mood.Name]++
+		voice, err := s.Player.Play(t)
+		if err != nil {
+			log.Warnf("Error playing %v: %v", t.Name, err)
+			// Don't retry on every frame.
+			s.Set(mood.Name, false)
+		} else {
+			s.voices = append(s.voices, &playing{mood: mood.Name, voice: voice, dir: 1})
+		}
+	}
+
+	step := dt / s.Fade
+	if s.Fade <= 0 {
+		step = 1
+	}
+	gain := CurrentBuses().Gain(BusMusic)
+	live := s.voices[:0]
+	for _, v := range s.voices {
+		v.fade += v.dir * step
+		if v.fade > 1 {
+			v.fade = 1
+		}
+		if v.fade <= 0 || (v.dir < 0 && v.voice.Done()) {
+			v.voice.Stop()
+			continue
+		}
+		v.voice.SetGain(v.fade * gain)
+		live = append(live, v)
+	}
+	s.voices = live
+}
+
+// Stop ends all tracks and deactivates all moods.
+func (s *Soundtrack) Stop() {
+	for _, v := range s.voices {
+		v.voice.Stop()
+	}
+	s.voices = nil
+	s.active = map[string]bool{}
+}
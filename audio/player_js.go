@@ -0,0 +1,43 @@
+package audio
+
+import (
+	"syscall/js"
+)
+
+// DefaultPlayer returns the music player of the platform. On the web, tracks
+// are streamed and decoded by HTML audio elements.
+func DefaultPlayer() (Player, error) {
+	return htmlPlayer{}, nil
+}
+
+type htmlPlayer struct{}
+
+func (htmlPlayer) Play(t Track) (Voice, error) {
+	el := js.Global().Get("Audio").New(t.URL)
+	el.Set("volume", 0)
+	// Browsers refuse to play before the first user interaction; the
+	// rejected promise leaves the element paused, and Done reports it.
+	el.Call("play").Call("catch", ignore)
+	return &htmlVoice{el: el}, nil
+}
+
+// ignore handles the rejected play promises. It is shared, as each js.Func
+// must be released.
+var ignore = js.FuncOf(func(js.Value, []js.Value) any { return nil })
+
+type htmlVoice struct {
+	el js.Value
+}
+
+func (v *htmlVoice) SetGain(gain float32) {
+	v.el.Set("volume", gain)
+}
+
+func (v *htmlVoice) Done() bool {
+	return v.el.Get("ended").Bool() || v.el.Get("error").Truthy()
+}
+
+func (v *htmlVoice) Stop() {
+	v.el.Call("pause")
+	v.el.Set("src", "")
+}
@@ -0,0 +1,9 @@
+//go:build !js
+
+package audio
+
+// DefaultPlayer returns the music player of the platform. The desktop build
+// has no audio output yet, so it returns ErrNoPlayer.
+func DefaultPlayer() (Player, error) {
+	return nil, ErrNoPlayer
+}
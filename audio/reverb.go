@@ -0,0 +1,125 @@
+package audio
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// ReverbZone is a region with a hand-tuned reverb, such as a cathedral or a
+// dungeon, that replaces the estimated one while the listener is inside.
+type ReverbZone struct {
+	Min, Max glm.Vec3
+	// Reverb is the send amount, from 0 to 1, and Decay the reverb time, in
+	// seconds.
+	Reverb, Decay float32
+}
+
+// Contains returns true if p is inside the zone.
+func (z ReverbZone) Contains(p glm.Vec3) bool {
+	return p[0] >= z.Min[0] && p[0] < z.Max[0] &&
+		p[1] >= z.Min[1] && p[1] < z.Max[1] &&
+		p[2] >= z.Min[2] && p[2] < z.Max[2]
+}
+
+// Presets of the estimated reverb, blended by the enclosure of the listener.
+var (
+	OpenAir = ReverbZone{Reverb: 0.05, Decay: 0.3}
+	Cave    = ReverbZone{Reverb: 0.6, Decay: 2.5}
+)
+
+// enclosureDirections are the rays cast by Enclosure: the 26 directions to the
+// neighbors of a block.
+var enclosureDirections = func() []glm.Vec3 {
+	var dirs []glm.Vec3
+	for x := -1; x <= 1; x++ {
+		for y := -1; y <= 1; y++ {
+			for z := -1; z <= 1; z++ {
+				if x != 0 || y != 0 || z != 0 {
+					dirs = append(dirs, glm.Vec3{float32(x), float32(y), float32(z)}.Normalize())
+				}
+			}
+		}
+	}
+	return dirs
+}()
+
+// Enclosure returns how enclosed the position is, from 0 in the open air to 1
+// when solid blocks are found in all directions within distance blocks, as in
+// a cave.
+func Enclosure(v Volume, pos glm.Vec3, distance float32) float32 {
+	hits := 0
+	for _, d := range enclosureDirections {
+		traverse(pos, pos.Add(d.Mul(distance)), func(b [3]int) bool {
+			if v.Solid(b[0], b[1], b[2]) {
+				hits++
+				return false
+			}
+			return true
+		})
+	}
+	return float32(hits) / float32(len(enclosureDirections))
+}
+
+// Listener computes the Params of the sounds heard at its position.
+type Listener struct {
+	Volume   Volume
+	Position glm.Vec3
+
+	// OcclusionGain multiplies the gain for each solid block between the
+	// source and the listener, and OcclusionCutoff the low-pass cutoff.
+	OcclusionGain, OcclusionCutoff float32
+	// MaxOcclusion is the number of blocks after which the occlusion stops
+	// increasing.
+	MaxOcclusion int
+	// Cutoff is the low-pass cutoff of unoccluded sounds, in Hz.
+	Cutoff float32
+
+	// Zones are the hand-tuned reverb zones. When the listener is in none
+	// of them, the reverb is estimated from the enclosure within
+	// EnclosureDistance blocks.
+	Zones             []ReverbZone
+	EnclosureDistance float32
+
+	reverb ReverbZone
+}
+
+// NewListener creates a listener with the default settings: each block halves
+// the gain and the cutoff, up to 4 blocks.
+func NewListener(v Volume) *Listener {
+	return &Listener{
+		Volume:            v,
+		OcclusionGain:     0.5,
+		OcclusionCutoff:   0.5,
+		MaxOcclusion:      4,
+		Cutoff:            20000,
+		EnclosureDistance: 16,
+		reverb:            OpenAir,
+	}
+}
+
+// Move updates the listener position and its reverb. It casts a few dozen
+// rays to estimate the enclosure, so it should be called once per frame, not
+// for each sound.
+func (l *Listener) Move(pos glm.Vec3) {
+	l.Position = pos
+	for _, z := range l.Zones {
+		if z.Contains(pos) {
+			l.reverb = z
+			return
+		}
+	}
+	e := Enclosure(l.Volume, pos, l.EnclosureDistance)
+	l.reverb = ReverbZone{
+		Reverb: OpenAir.Reverb + (Cave.Reverb-OpenAir.Reverb)*e,
+		Decay:  OpenAir.Decay + (Cave.Decay-OpenAir.Decay)*e,
+	}
+}
+
+// Params returns the settings of a sound played at source.
+func (l *Listener) Params(source glm.Vec3) Params {
+	p := Params{Gain: 1, Cutoff: l.Cutoff, Reverb: l.reverb.Reverb, Decay: l.reverb.Decay}
+	for n := Occlusion(l.Volume, source, l.Position, l.MaxOcclusion); n > 0; n-- {
+		p.Gain *= l.OcclusionGain
+		p.Cutoff *= l.OcclusionCutoff
+	}
+	return p
+}
@@ -0,0 +1,110 @@
+// package auth implements player authentication and session management for
+// multiplayer games.
+//
+// Authentication is pluggable through the Authenticator interface. Two
+// implementations are provided: Offline, which trusts the username sent by
+// the client, and Token, which uses a challenge/response handshake with a
+// pre-shared token per player.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	// ErrInvalidUsername is returned when the username has invalid
+	// characters or length.
+	ErrInvalidUsername = errors.New("auth: invalid username")
+
+	// ErrAuthFailed is returned when the client response doesn't match the
+	// challenge.
+	ErrAuthFailed = errors.New("auth: authentication failed")
+)
+
+// Identity is an authenticated player.
+type Identity struct {
+	Username string
+}
+
+// Authenticator validates players joining the server.
+type Authenticator interface {
+	// Challenge returns the data to be sent to the client, that must be
+	// used to compute the response. It may return nil if no challenge is
+	// required.
+	Challenge(username string) ([]byte, error)
+
+	// Verify checks the client response to the challenge.
+	Verify(username string, challenge, response []byte) (Identity, error)
+}
+
+var usernameRe = regexp.MustCompile(`^[a-zA-Z0-9_]{3,16}$`)
+
+// ValidUsername returns true if the username has only letters, digits or
+// underscores, and is between 3 and 16 characters long.
+func ValidUsername(username string) bool {
+	return usernameRe.MatchString(username)
+}
+
+// Offline accepts any valid username without verification. It must only be
+// used for local games and LAN servers.
+type Offline struct{}
+
+func (Offline) Challenge(username string) ([]byte, error) {
+	if !ValidUsername(username) {
+		return nil, ErrInvalidUsername
+	}
+	return nil, nil
+}
+
+func (Offline) Verify(username string, challenge, response []byte) (Identity, error) {
+	if !ValidUsername(username) {
+		return Identity{}, ErrInvalidUsername
+	}
+	return Identity{Username: username}, nil
+}
+
+// challengeSize is the number of random bytes used in challenges.
+const challengeSize = 32
+
+// Token authenticates players that know a pre-shared secret token. The client
+// proves it knows the token by sending the HMAC-SHA256 of the challenge, so
+// the token itself is never sent over the network.
+type Token struct {
+	// Lookup returns the token of the player, and false if the player is
+	// unknown.
+	Lookup func(username string) ([]byte, bool)
+}
+
+func (t Token) Challenge(username string) ([]byte, error) {
+	if !ValidUsername(username) {
+		return nil, ErrInvalidUsername
+	}
+	c := make([]byte, challengeSize)
+	if _, err := rand.Read(c); err != nil {
+		return nil, fmt.Errorf("auth: generating challenge: %w", err)
+	}
+	return c, nil
+}
+
+func (t Token) Verify(username string, challenge, response []byte) (Identity, error) {
+	token, ok := t.Lookup(username)
+	if !ok || len(challenge) != challengeSize {
+		return Identity{}, ErrAuthFailed
+	}
+	if !hmac.Equal(Respond(token, challenge), response) {
+		return Identity{}, ErrAuthFailed
+	}
+	return Identity{Username: username}, nil
+}
+
+// Respond computes the client response to a Token challenge.
+func Respond(token, challenge []byte) []byte {
+	m := hmac.New(sha256.New, token)
+	m.Write(challenge)
+	return m.Sum(nil)
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// List is a set of usernames, safe for concurrent use. It can be used as a
+// whitelist or as a ban list.
+type List struct {
+	mu    sync.RWMutex
+	names map[string]struct{}
+}
+
+// NewList creates a list with the provided usernames.
+func NewList(names ...string) *List {
+	l := &List{names: map[string]struct{}{}}
+	for _, n := range names {
+		l.Add(n)
+	}
+	return l
+}
+
+// Add adds the username to the list. Usernames are case insensitive.
+func (l *List) Add(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.names[strings.ToLower(name)] = struct{}{}
+}
+
+// Remove removes the username from the list.
+func (l *List) Remove(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.names, strings.ToLower(name))
+}
+
+// Contains returns true if the username is in the list.
+func (l *List) Contains(name string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	_, ok := l.names[strings.ToLower(name)]
+	return ok
+}
+
+// Whitelist returns a hook that rejects players not in the list.
+func Whitelist(l *List) Hook {
+	return func(id Identity, addr net.Addr) error {
+		if !l.Contains(id.Username) {
+			return fmt.Errorf("%w: %v is not whitelisted", ErrRejected, id.Username)
+		}
+		return nil
+	}
+}
+
+// Banlist returns a hook that rejects players in the list.
+func Banlist(l *List) Hook {
+	return func(id Identity, addr net.Addr) error {
+		if l.Contains(id.Username) {
+			return fmt.Errorf("%w: %v is banned", ErrRejected, id.Username)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrRejected is wrapped by the errors returned from Hooks to reject a
+// player.
+var ErrRejected = errors.New("auth: rejected")
+
+// Hook is called after a player is authenticated, and can reject the player
+// by returning an error, for instance to enforce whitelists and bans.
+type Hook func(id Identity, addr net.Addr) error
+
+// Session holds the state of an authenticated connection.
+type Session struct {
+	ID       string
+	Identity Identity
+	Addr     net.Addr
+	Created  time.Time
+
+	mu     sync.Mutex
+	values map[string]any
+}
+
+// Get returns a value stored in the session.
+func (s *Session) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// Set stores a value in the session.
+func (s *Session) Set(key string, v any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = map[string]any{}
+	}
+	s.values[key] = v
+}
+
+// Manager authenticates connections and keeps track of active sessions.
+type Manager struct {
+	Auth  Authenticator
+	Hooks []Hook
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// NewManager creates a session manager using the authenticator.
+func NewManager(a Authenticator) *Manager {
+	return &Manager{
+		Auth:     a,
+		sessions: map[string]*Session{},
+	}
+}
+
+// Handshake runs the server side of the authentication handshake on the
+// connection:
+//
+//  1. the client sends its username;
+//  2. the server replies with a challenge, possibly empty;
+//  3. the client sends its response;
+//  4. the server replies with the session ID.
+//
+// Each message is prefixed by its length as an uint16. The server messages
+// start with a status byte, statusOK, or statusRejected followed by an error
+// message if the player was rejected, so challenges can hold any bytes.
+func (m *Manager) Handshake(conn net.Conn) (*Session, error) {
+	username, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := m.Auth.Challenge(string(username))
+	if err != nil {
+		writeStatus(conn, statusRejected, []byte(err.Error()))
+		return nil, err
+	}
+	if err := writeStatus(conn, statusOK, challenge); err != nil {
+		return nil, err
+	}
+	response, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := m.Auth.Verify(string(username), challenge, response)
+	if err == nil {
+		for _, h := range m.Hooks {
+			if err = h(id, conn.RemoteAddr()); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		writeStatus(conn, statusRejected, []byte(err.Error()))
+		return nil, err
+	}
+
+	s := &Session{
+		ID:       newSessionID(),
+		Identity: id,
+		Addr:     conn.RemoteAddr(),
+		Created:  time.Now(),
+	}
+	m.mu.Lock()
+	m.sessions[s.ID] = s
+	m.mu.Unlock()
+	if err := writeStatus(conn, statusOK, []byte(s.ID)); err != nil {
+		m.End(s.ID)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Session returns the active session with the ID.
+func (m *Manager) Session(id string) (*Session, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	return s, ok
+}
+
+// Sessions returns all active sessions.
+func (m *Manager) Sessions() []*Session {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := make([]*Session, 0, len(m.sessions))
+	for _, s := range m.sessions {
+		all = append(all, s)
+	}
+	return all
+}
+
+// End removes the session, usually when the player disconnects.
+func (m *Manager) End(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+}
+
+// Login runs the client side of the handshake, returning the session ID. The
+// token is only used if the server sends a challenge.
+func Login(conn net.Conn, username string, token []byte) (string, error) {
+	if err := writeFrame(conn, []byte(username)); err != nil {
+		return "", err
+	}
+	challenge, err := readStatus(conn)
+	if err != nil {
+		return "", err
+	}
+	var response []byte
+	if len(challenge) > 0 {
+		response = Respond(token, challenge)
+	}
+	if err := writeFrame(conn, response); err != nil {
+		return "", err
+	}
+	reply, err := readStatus(conn)
+	if err != nil {
+		return "", err
+	}
+	return string(reply), nil
+}
+
+// maxFrameSize limits the handshake messages.
+const maxFrameSize = 1024
+
+func writeFrame(w io.Writer, b []byte) error {
+	if len(b) > maxFrameSize {
+		return fmt.Errorf("auth: frame too large: %d bytes", len(b))
+	}
+	buf := binary.BigEndian.AppendUint16(nil, uint16(len(b)))
+	_, err := w.Write(append(buf, b...))
+	return err
+}
+
+// The status of the server messages, sent as their first byte.
+const (
+	statusOK byte = iota
+	statusRejected
+)
+
+// writeStatus writes a server message, the status followed by b.
+func writeStatus(w io.Writer, status byte, b []byte) error {
+	return writeFrame(w, append([]byte{status}, b...))
+}
+
+// readStatus reads a server message, returning the bytes after the status,
+// or an error wrapping ErrRejected with the message if the player was
+// rejected.
+func readStatus(r io.Reader) ([]byte, error) {
+	b, err := readFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("auth: missing status")
+	}
+	switch b[0] {
+	case statusOK:
+		return b[1:], nil
+	case statusRejected:
+		return nil, fmt.Errorf("%w: %s", ErrRejected, b[1:])
+	}
+	return nil, fmt.Errorf("auth: unknown status %d", b[0])
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint16(size[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("auth: frame too large: %d bytes", n)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
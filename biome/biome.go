@@ -0,0 +1,129 @@
+// package biome implements the climate based coloring of grass, foliage and
+// water.
+//
+// Each biome has a temperature and a humidity, used to look up the tint color
+// in a colormap image. Colors are blended with the neighbor columns, so the
+// transitions between biomes are smooth, including across chunk borders.
+package biome
+
+import (
+	"image"
+	"image/color"
+)
+
+// Tint selects the colormap applied to a block texture.
+type Tint uint8
+
+const (
+	// TintNone leaves the texture untouched.
+	TintNone Tint = iota
+	TintGrass
+	TintFoliage
+	TintWater
+)
+
+// Biome describes the climate of a region of the world.
+type Biome struct {
+	Name string
+	// Temperature and Humidity range from 0 to 1.
+	Temperature float32
+	Humidity    float32
+	// Water is the water tint, as water does not use a colormap.
+	Water color.RGBA
+	// Ground is the average color of the terrain seen from far away, such
+	// as by the far terrain impostors.
+	Ground color.RGBA
+}
+
+// Colormap maps the climate to a tint color. The image is indexed with the
+// temperature decreasing along the x axis and the humidity, scaled by the
+// temperature, decreasing along the y axis.
+type Colormap struct {
+	img image.Image
+}
+
+// NewColormap creates a colormap from the image.
+func NewColormap(img image.Image) *Colormap {
+	return &Colormap{img: img}
+}
+
+// At returns the tint color for the climate.
+func (c *Colormap) At(temperature, humidity float32) color.RGBA {
+	t := clamp01(temperature)
+	h := clamp01(humidity) * t
+	b := c.img.Bounds()
+	x := b.Min.X + int((1-t)*float32(b.Dx()-1))
+	y := b.Min.Y + int((1-h)*float32(b.Dy()-1))
+	r, g, bl, a := c.img.At(x, y).RGBA()
+	return color.RGBA{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8), uint8(a >> 8)}
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// Source returns the biome of the world column at x, z. It must be defined
+// for any column, including those in unloaded chunks, usually by querying the
+// world generator.
+type Source func(x, z int) *Biome
+
+// Tinter computes the blended tint colors used by the mesher to fill the
+// vertex tint attribute.
+type Tinter struct {
+	Grass   *Colormap
+	Foliage *Colormap
+	// Radius is the number of neighbor columns blended in each direction.
+	Radius int
+
+	source Source
+}
+
+// NewTinter creates a tinter that queries the biomes from the source.
+func NewTinter(source Source, grass, foliage *Colormap) *Tinter {
+	return &Tinter{Grass: grass, Foliage: foliage, Radius: 2, source: source}
+}
+
+// Color returns the tint of the column at x, z as linear r, g, b values from
+// 0 to 1, ready to be used as vertex attributes. TintNone is always white.
+func (t *Tinter) Color(tint Tint, x, z int) [3]float32 {
+	if tint == TintNone {
+		return [3]float32{1, 1, 1}
+	}
+	var sum [3]float32
+	n := 0
+	for dz := -t.Radius; dz <= t.Radius; dz++ {
+		for dx := -t.Radius; dx <= t.Radius; dx++ {
+			c := t.columnColor(tint, t.source(x+dx, z+dz))
+			sum[0] += float32(c.R)
+			sum[1] += float32(c.G)
+			sum[2] += float32(c.B)
+			n++
+		}
+	}
+	for i := range sum {
+		sum[i] /= float32(n) * 255
+	}
+	return sum
+}
+
+func (t *Tinter) columnColor(tint Tint, b *Biome) color.RGBA {
+	switch tint {
+	case TintGrass:
+		if t.Grass != nil {
+			return t.Grass.At(b.Temperature, b.Humidity)
+		}
+	case TintFoliage:
+		if t.Foliage != nil {
+			return t.Foliage.At(b.Temperature, b.Humidity)
+		}
+	case TintWater:
+		return b.Water
+	}
+	return color.RGBA{0xff, 0xff, 0xff, 0xff}
+}
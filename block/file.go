@@ -0,0 +1,260 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/ronoaldo/openvoxel/biome"
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/physics"
+)
+
+// ErrInvalidDefinition is returned when parsing malformed block definition
+// files.
+var ErrInvalidDefinition = errors.New("block: invalid definition")
+
+// fileDefinition is a Definition as written in the block definition files.
+type fileDefinition struct {
+	Name string `json:"name"`
+	// Collision is a list of boxes as their minimum and maximum corners.
+	// Definitions without it are full blocks, and an empty list has no
+	// collision.
+	Collision  *[][6]float32 `json:"collision"`
+	Hardness   float32       `json:"hardness"`
+	Resistance float32       `json:"resistance"`
+	Medium     string        `json:"medium"`
+	Opaque     bool          `json:"opaque"`
+	Emission   [3]uint8      `json:"emission"`
+	Tint       string        `json:"tint"`
+	// Shape is the name of a built-in model, and Model a custom one.
+	Shape string     `json:"shape"`
+	Model *fileModel `json:"model"`
+	// Texture is the texture of all faces, and Textures overrides it for
+	// "side", "end" or single faces.
+	Texture  string            `json:"texture"`
+	Textures map[string]string `json:"textures"`
+}
+
+// fileModel is a Model as written in the block definition files.
+type fileModel struct {
+	Facing   bool          `json:"facing"`
+	Elements []fileElement `json:"elements"`
+}
+
+type fileElement struct {
+	From  [3]float32 `json:"from"`
+	To    [3]float32 `json:"to"`
+	Cross bool       `json:"cross"`
+	// Faces maps the face names to their texture coordinates. Elements
+	// without faces have the six faces of Box.
+	Faces map[string]struct {
+		UV [4]float32 `json:"uv"`
+	} `json:"faces"`
+	Connect []string `json:"connect"`
+}
+
+var shapes = map[string]func() *Model{
+	"cross":  CrossModel,
+	"slab":   SlabModel,
+	"stairs": StairsModel,
+	"fence":  FenceModel,
+}
+
+// faceNames are the face indexes of Element.Faces.
+var faceNames = map[string]int{
+	"east":  0,
+	"west":  1,
+	"up":    2,
+	"down":  3,
+	"south": 4,
+	"north": 5,
+}
+
+// textureFaces maps the keys of the textures of the definition files to the
+// faces they set. Single faces are applied after "side" and "end".
+var textureFaces = map[string][]int{
+	"side": {0, 1, 4, 5},
+	"end":  {2, 3},
+}
+
+var facings = map[string]Facing{
+	"north": North,
+	"east":  East,
+	"south": South,
+	"west":  West,
+}
+
+var media = map[string]physics.Medium{
+	"":          physics.MediumAir,
+	"air":       physics.MediumAir,
+	"liquid":    physics.MediumLiquid,
+	"climbable": physics.MediumClimbable,
+}
+
+var tints = map[string]biome.Tint{
+	"":        biome.TintNone,
+	"none":    biome.TintNone,
+	"grass":   biome.TintGrass,
+	"foliage": biome.TintFoliage,
+	"water":   biome.TintWater,
+}
+
+// ParseDefinitions decodes a block definition file, a JSON list of blocks
+// such as:
+//
+//	[
+//	  {"name": "openvoxel:glowstone", "hardness": 0.3, "opaque": true, "emission": [15, 14, 10]},
+//	  {"name": "openvoxel:ladder", "collision": [], "medium": "climbable"},
+//	  {"name": "openvoxel:slab", "collision": [[0, 0, 0, 1, 0.5, 1]]}
+//	]
+//
+// The medium is "air", "liquid" or "climbable", and the tint "none", "grass",
+// "foliage" or "water". Blocks that are not full cubes have either a built-in
+// "shape", one of "cross", "slab", "stairs" and "fence", or a custom "model"
+// made of boxes with per-face texture coordinates:
+//
+//	{"name": "openvoxel:lamp", "model": {"elements": [
+//	  {"from": [0.25, 0, 0.25], "to": [0.75, 0.5, 0.75], "faces": {
+//	    "up": {"uv": [0.25, 0.25, 0.75, 0.75]},
+//	    "north": {"uv": [0.25, 0, 0.75, 0.5]}
+//	  }}
+//	]}}
+//
+// Face names are "east", "west", "up", "down", "south" and "north", and
+// elements without faces have all six. Elements may also be "cross" planes, or
+// "connect" towards a list of directions, and models with "facing" turn with
+// the block.
+//
+// The "texture" names the texture of all faces, such as "block/stone", and
+// "textures" overrides it for the four "side" faces, the two "end" faces or
+// single faces by name:
+//
+//	{"name": "openvoxel:log", "texture": "block/log_side", "textures": {"end": "block/log_top"}}
+//
+// Errors wrap ErrInvalidDefinition.
+func ParseDefinitions(b []byte) ([]Definition, error) {
+	var file []fileDefinition
+	if err := json.Unmarshal(b, &file); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	defs := make([]Definition, 0, len(file))
+	for i, f := range file {
+		if f.Name == "" {
+			return nil, fmt.Errorf("%w: block %d has no name", ErrInvalidDefinition, i)
+		}
+		medium, ok := media[f.Medium]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q has unknown medium %q", ErrInvalidDefinition, f.Name, f.Medium)
+		}
+		tint, ok := tints[f.Tint]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q has unknown tint %q", ErrInvalidDefinition, f.Name, f.Tint)
+		}
+		def := Definition{
+			Name:       f.Name,
+			Collision:  []physics.AABB{physics.Box(0, 0, 0, 1, 1, 1)},
+			Hardness:   f.Hardness,
+			Resistance: f.Resistance,
+			Medium:     medium,
+			Opaque:     f.Opaque,
+			Emission:   light.RGB(f.Emission[0], f.Emission[1], f.Emission[2]),
+			Tint:       tint,
+		}
+		switch {
+		case f.Shape != "" && f.Model != nil:
+			return nil, fmt.Errorf("%w: %q has both a shape and a model", ErrInvalidDefinition, f.Name)
+		case f.Shape != "":
+			shape, ok := shapes[f.Shape]
+			if !ok {
+				return nil, fmt.Errorf("%w: %q has unknown shape %q", ErrInvalidDefinition, f.Name, f.Shape)
+			}
+			def.Model = shape()
+		case f.Model != nil:
+			model, err := f.Model.model()
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q %v", ErrInvalidDefinition, f.Name, err)
+			}
+			def.Model = model
+		}
+		if err := f.textures(&def); err != nil {
+			return nil, err
+		}
+		if f.Collision != nil {
+			def.Collision = nil
+			for _, c := range *f.Collision {
+				def.Collision = append(def.Collision, physics.Box(c[0], c[1], c[2], c[3], c[4], c[5]))
+			}
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// Define registers the definition, or replaces the definition with the same
+// name keeping its ID, such as when reloading the block definition files. It
+// returns true if a different definition was replaced, so the chunks using the
+// block must be meshed again.
+func (r *Registry) Define(def Definition) (id ID, changed bool) {
+	id, ok := r.byName[def.Name]
+	if !ok {
+		id, _ = r.Register(def)
+		return id, false
+	}
+	changed = !reflect.DeepEqual(r.defs[id], def)
+	r.defs[id] = def
+	return id, changed
+}
+
+// textures sets the Textures of the definition.
+func (f *fileDefinition) textures(def *Definition) error {
+	for i := range def.Textures {
+		def.Textures[i] = f.Texture
+	}
+	for _, group := range []string{"side", "end"} {
+		if t, ok := f.Textures[group]; ok {
+			for _, i := range textureFaces[group] {
+				def.Textures[i] = t
+			}
+		}
+	}
+	for name, t := range f.Textures {
+		if _, ok := textureFaces[name]; ok {
+			continue
+		}
+		i, ok := faceNames[name]
+		if !ok {
+			return fmt.Errorf("%w: %q has a texture for unknown face %q", ErrInvalidDefinition, f.Name, name)
+		}
+		def.Textures[i] = t
+	}
+	return nil
+}
+
+func (f *fileModel) model() (*Model, error) {
+	m := &Model{Facing: f.Facing}
+	for _, fe := range f.Elements {
+		e := Box(fe.From, fe.To)
+		e.Cross = fe.Cross
+		if fe.Faces != nil {
+			e.Faces = [6]*Face{}
+			for name, face := range fe.Faces {
+				i, ok := faceNames[name]
+				if !ok {
+					return nil, fmt.Errorf("has unknown face %q", name)
+				}
+				e.Faces[i] = &Face{UV: face.UV}
+			}
+		}
+		for _, name := range fe.Connect {
+			dir, ok := facings[name]
+			if !ok {
+				return nil, fmt.Errorf("has unknown direction %q", name)
+			}
+			e.Connect |= 1 << dir
+		}
+		m.Elements = append(m.Elements, e)
+	}
+	return m, nil
+}
@@ -0,0 +1,298 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidModel is returned when parsing malformed JSON block models.
+var ErrInvalidModel = errors.New("block: invalid model")
+
+// maxModelDepth limits the chains of parents and texture variables, to stop
+// on cycles.
+const maxModelDepth = 16
+
+// jsonModel is a block model in the resource pack format.
+type jsonModel struct {
+	Parent   string            `json:"parent"`
+	Textures map[string]string `json:"textures"`
+	Elements []jsonElement     `json:"elements"`
+}
+
+type jsonElement struct {
+	From     [3]float32 `json:"from"`
+	To       [3]float32 `json:"to"`
+	Rotation *struct {
+		Origin  [3]float32 `json:"origin"`
+		Axis    string     `json:"axis"`
+		Angle   float32    `json:"angle"`
+		Rescale bool       `json:"rescale"`
+	} `json:"rotation"`
+	Faces map[string]jsonFace `json:"faces"`
+}
+
+type jsonFace struct {
+	UV       *[4]float32 `json:"uv"`
+	Texture  string      `json:"texture"`
+	CullFace string      `json:"cullface"`
+	Rotation int         `json:"rotation"`
+}
+
+var axes = map[string]int{"x": 0, "y": 1, "z": 2}
+
+// builtinModels are the parents most resource pack models inherit from, used
+// when the load function does not find them.
+var builtinModels = map[string]string{
+	"block/block": `{}`,
+	"block/cube": `{"parent": "block/block", "elements": [{"from": [0, 0, 0], "to": [16, 16, 16], "faces": {
+		"down": {"texture": "#down", "cullface": "down"},
+		"up": {"texture": "#up", "cullface": "up"},
+		"north": {"texture": "#north", "cullface": "north"},
+		"south": {"texture": "#south", "cullface": "south"},
+		"west": {"texture": "#west", "cullface": "west"},
+		"east": {"texture": "#east", "cullface": "east"}
+	}}]}`,
+	"block/cube_all": `{"parent": "block/cube", "textures": {
+		"particle": "#all", "down": "#all", "up": "#all",
+		"north": "#all", "east": "#all", "south": "#all", "west": "#all"
+	}}`,
+	"block/cube_column": `{"parent": "block/cube", "textures": {
+		"particle": "#side", "down": "#end", "up": "#end",
+		"north": "#side", "east": "#side", "south": "#side", "west": "#side"
+	}}`,
+	"block/cross": `{"parent": "block/block", "textures": {"particle": "#cross"}, "elements": [
+		{"from": [0.8, 0, 8], "to": [15.2, 16, 8],
+		 "rotation": {"origin": [8, 8, 8], "axis": "y", "angle": 45, "rescale": true},
+		 "faces": {"north": {"uv": [0, 0, 16, 16], "texture": "#cross"}, "south": {"uv": [0, 0, 16, 16], "texture": "#cross"}}},
+		{"from": [8, 0, 0.8], "to": [8, 16, 15.2],
+		 "rotation": {"origin": [8, 8, 8], "axis": "y", "angle": 45, "rescale": true},
+		 "faces": {"west": {"uv": [0, 0, 16, 16], "texture": "#cross"}, "east": {"uv": [0, 0, 16, 16], "texture": "#cross"}}}
+	]}`,
+}
+
+// ParseModel decodes a block model in the JSON format of resource packs, so
+// existing community models can be meshed. Models are made of elements, boxes
+// from 0 to 16 with optional rotations, with faces that name their texture,
+// directly or through "#variables" of the "textures" map, and the neighbor
+// that hides them with "cullface".
+//
+// The parents named by "parent" are loaded with the load function, which may
+// be nil, and the common ones such as "block/cube_all" and "block/cross" are
+// built in. Namespaces such as in "mypack:block/lamp" are kept in the names
+// passed to load. Properties that openvoxel does not use, such as "display",
+// "shade", "tintindex" and "ambientocclusion", are ignored. Errors wrap
+// ErrInvalidModel.
+func ParseModel(b []byte, load func(name string) ([]byte, error)) (*Model, error) {
+	var m jsonModel
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidModel, err)
+	}
+	textures := map[string]string{}
+	for k, v := range m.Textures {
+		textures[k] = v
+	}
+	elements := m.Elements
+	// The elements of the closest model with elements are used, and the
+	// textures of the children override the ones of the parents.
+	for depth := 0; m.Parent != ""; depth++ {
+		if depth == maxModelDepth {
+			return nil, fmt.Errorf("%w: too many parents", ErrInvalidModel)
+		}
+		parent, err := loadModel(m.Parent, load)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range parent.Textures {
+			if _, ok := textures[k]; !ok {
+				textures[k] = v
+			}
+		}
+		if elements == nil {
+			elements = parent.Elements
+		}
+		m = parent
+	}
+
+	model := &Model{}
+	for _, je := range elements {
+		e := Element{}
+		for i := range je.From {
+			e.From[i], e.To[i] = je.From[i]/16, je.To[i]/16
+		}
+		if r := je.Rotation; r != nil {
+			axis, ok := axes[r.Axis]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown axis %q", ErrInvalidModel, r.Axis)
+			}
+			e.Rotation = &ElementRotation{Axis: axis, Angle: r.Angle, Rescale: r.Rescale}
+			for i, v := range r.Origin {
+				e.Rotation.Origin[i] = v / 16
+			}
+		}
+		for name, jf := range je.Faces {
+			f, ok := faceNames[name]
+			if !ok {
+				return nil, fmt.Errorf("%w: unknown face %q", ErrInvalidModel, name)
+			}
+			face, err := jf.face(f, je.From, je.To, textures)
+			if err != nil {
+				return nil, err
+			}
+			e.Faces[f] = face
+		}
+		model.Elements = append(model.Elements, e)
+	}
+	return model, nil
+}
+
+// loadModel loads the parent model with the name, falling back to the built
+// in models.
+func loadModel(name string, load func(name string) ([]byte, error)) (jsonModel, error) {
+	var b []byte
+	var err error
+	if load != nil {
+		b, err = load(name)
+	}
+	if load == nil || err != nil {
+		builtin, ok := builtinModels[name[strings.IndexByte(name, ':')+1:]]
+		if !ok {
+			if err == nil {
+				err = errors.New("not found")
+			}
+			return jsonModel{}, fmt.Errorf("%w: parent %q: %v", ErrInvalidModel, name, err)
+		}
+		b = []byte(builtin)
+	}
+	var m jsonModel
+	if err := json.Unmarshal(b, &m); err != nil {
+		return jsonModel{}, fmt.Errorf("%w: parent %q: %v", ErrInvalidModel, name, err)
+	}
+	return m, nil
+}
+
+// resolveTexture follows the "#variable" references of the texture.
+func resolveTexture(t string, textures map[string]string) (string, error) {
+	for depth := 0; strings.HasPrefix(t, "#"); depth++ {
+		v, ok := textures[t[1:]]
+		if !ok || depth == maxModelDepth {
+			return "", fmt.Errorf("%w: texture %q is not defined", ErrInvalidModel, t)
+		}
+		t = v
+	}
+	return t, nil
+}
+
+// face converts the face f of the element between from and to, in 1/16 of a
+// block.
+func (jf jsonFace) face(f int, from, to [3]float32, textures map[string]string) (*Face, error) {
+	face := &Face{Cull: CullNone}
+	var err error
+	if face.Texture, err = resolveTexture(jf.Texture, textures); err != nil {
+		return nil, err
+	}
+	if jf.CullFace != "" {
+		c, ok := faceNames[jf.CullFace]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown cullface %q", ErrInvalidModel, jf.CullFace)
+		}
+		face.Cull = CullEast + CullFace(c)
+	}
+
+	// Compute the texture coordinates of each corner as resource packs
+	// do, then find the coordinates and rotation of the Face with the same
+	// result. The mesher puts the corners along the axes after the face
+	// axis, while resource packs map each face to the texture as seen from
+	// outside, with the top of the texture up, or north for the up and down
+	// faces.
+	d := f / 2
+	u, v := (d+1)%3, (d+2)%3
+	param := func(p [3]float32) (s, t float32) {
+		switch f {
+		case 0: // east
+			return 16 - p[2], 16 - p[1]
+		case 1: // west
+			return p[2], 16 - p[1]
+		case 2: // up
+			return p[0], p[2]
+		case 3: // down
+			return p[0], 16 - p[2]
+		case 4: // south
+			return p[0], 16 - p[1]
+		default: // north
+			return 16 - p[0], 16 - p[1]
+		}
+	}
+	var st [4][2]float32
+	smin, tmin := float32(16), float32(16)
+	smax, tmax := float32(0), float32(0)
+	for i, k := range [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		p := from
+		if f%2 == 0 {
+			p[d] = to[d]
+		}
+		if k[0] == 1 {
+			p[u] = to[u]
+		}
+		if k[1] == 1 {
+			p[v] = to[v]
+		}
+		s, t := param(p)
+		st[i] = [2]float32{s, t}
+		smin, smax = min32(smin, s), max32(smax, s)
+		tmin, tmax = min32(tmin, t), max32(tmax, t)
+	}
+	uv := [4]float32{smin, tmin, smax, tmax}
+	if jf.UV != nil {
+		uv = *jf.UV
+	}
+	var want [4][2]float32
+	for i, c := range st {
+		// Normalize the corner on the face, turn it by the face rotation,
+		// clockwise on the texture, and map it to the uv rectangle.
+		s, t := norm(c[0], smin, smax), norm(c[1], tmin, tmax)
+		for r := 0; r < jf.Rotation/90%4; r++ {
+			s, t = t, 1-s
+		}
+		tu, tv := uv[0]+(uv[2]-uv[0])*s, uv[1]+(uv[3]-uv[1])*t
+		// Resource pack textures go down from the top row, while the
+		// mesher texture coordinates go up.
+		want[i] = [2]float32{tu / 16, 1 - tv/16}
+	}
+	for r := 0; r < 4; r++ {
+		// The corner i takes the coordinates of corner i-r of the face
+		// without rotation, which must be a rectangle along the axes.
+		base := func(i int) [2]float32 { return want[(i+r)%4] }
+		if base(0)[1] == base(1)[1] && base(1)[0] == base(2)[0] &&
+			base(2)[1] == base(3)[1] && base(3)[0] == base(0)[0] {
+			face.UV = [4]float32{base(0)[0], base(0)[1], base(2)[0], base(2)[1]}
+			face.Rotation = r
+			return face, nil
+		}
+	}
+	face.UV = [4]float32{want[0][0], want[0][1], want[2][0], want[2][1]}
+	return face, nil
+}
+
+// norm returns the position of v from lo to hi, from 0 to 1.
+func norm(v, lo, hi float32) float32 {
+	if hi <= lo {
+		return 0
+	}
+	return (v - lo) / (hi - lo)
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
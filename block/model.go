@@ -0,0 +1,188 @@
+package block
+
+// Model is the shape of a block that is not a full cube, such as plants,
+// slabs, stairs and fences, made of boxes in block local coordinates, from 0
+// to 1. Blocks with a model should not be Opaque, so the faces of the blocks
+// around them are drawn.
+type Model struct {
+	Elements []Element
+	// Facing models are turned around the vertical axis by the facing of the
+	// block state. They are modeled facing North.
+	Facing bool
+}
+
+// Face is a face of a model element.
+type Face struct {
+	// UV are the texture coordinates of the minimum and maximum corners of
+	// the face, in the same units as the greedy mesher: one unit per block
+	// texture.
+	UV [4]float32
+	// Rotation turns the texture coordinates by quarter turns around the
+	// face.
+	Rotation int
+	// Texture is the name of the texture of the face, such as
+	// "block/oak_planks". Empty uses the texture of the block.
+	Texture string
+	// Cull selects the neighbor that hides the face.
+	Cull CullFace
+}
+
+// CullFace selects the neighbor that hides a model face when it is opaque.
+type CullFace uint8
+
+const (
+	// CullBorder hides the faces on the border of the block by the neighbor
+	// they face.
+	CullBorder CullFace = iota
+	// CullNone always draws the face.
+	CullNone
+	// CullEast to CullNorth hide the face by the neighbor in the direction,
+	// in the order of the faces: +X, -X, +Y, -Y, +Z, -Z.
+	CullEast
+	CullWest
+	CullUp
+	CullDown
+	CullSouth
+	CullNorth
+)
+
+// ElementRotation turns an element around an axis.
+type ElementRotation struct {
+	// Origin is the point the element turns around.
+	Origin [3]float32
+	// Axis is 0 for X, 1 for Y and 2 for Z.
+	Axis int
+	// Angle is in degrees, counter clockwise when looking at the origin from
+	// the positive side of the axis.
+	Angle float32
+	// Rescale stretches the element across the block after turning it, so
+	// planes at 45 degrees span the whole block.
+	Rescale bool
+}
+
+// Element is a box of a model.
+type Element struct {
+	// From and To are the minimum and maximum corners of the box.
+	From, To [3]float32
+	// Faces of the box, in the order +X, -X, +Y, -Y, +Z, -Z. Nil faces are
+	// not drawn. By default, faces on the border of the block are hidden by
+	// opaque neighbors, like the faces of full blocks.
+	Faces [6]*Face
+	// Rotation, if set, turns the element. The faces of turned elements are
+	// only hidden by an explicit Cull.
+	Rotation *ElementRotation
+	// Cross elements are drawn as the two vertical diagonal planes of the
+	// box, seen from both sides, such as grass and flowers. Faces is ignored
+	// and the planes use the whole texture.
+	Cross bool
+	// Connect is a mask of directions, such as 1<<North|1<<South. Elements
+	// with a mask are only drawn when the neighbor in one of the directions
+	// is opaque or the same block, such as the arms of fences.
+	Connect uint8
+}
+
+// Offset returns the position of the neighbor in the direction, where North is
+// -Z and East is +X.
+func (f Facing) Offset() (dx, dz int) {
+	switch f {
+	case North:
+		return 0, -1
+	case East:
+		return 1, 0
+	case South:
+		return 0, 1
+	default:
+		return -1, 0
+	}
+}
+
+// Box returns an element with the six faces, with texture coordinates taken
+// from the position of each face in the block, so a slab shows the bottom
+// half of the texture on its sides.
+func Box(from, to [3]float32) Element {
+	e := Element{From: from, To: to}
+	for f := range e.Faces {
+		d := f / 2
+		u, v := (d+1)%3, (d+2)%3
+		e.Faces[f] = &Face{UV: [4]float32{from[u], from[v], to[u], to[v]}}
+	}
+	return e
+}
+
+// rotatedFaces maps each face to the face it turns into after a quarter turn
+// from North to East.
+var rotatedFaces = [6]int{4, 5, 2, 3, 1, 0}
+
+// Rotate returns the element turned around the center of the block by the
+// quarter turns from North to the facing.
+func (e Element) Rotate(f Facing) Element {
+	for i := 0; i < int(f)%4; i++ {
+		r := e
+		r.From[0], r.To[0] = 1-e.To[2], 1-e.From[2]
+		r.From[2], r.To[2] = e.From[0], e.To[0]
+		for face, to := range rotatedFaces {
+			fc := e.Faces[face]
+			if fc != nil && fc.Cull >= CullEast {
+				c := *fc
+				c.Cull = CullEast + CullFace(rotatedFaces[fc.Cull-CullEast])
+				fc = &c
+			}
+			r.Faces[to] = fc
+		}
+		r.Connect = (e.Connect<<1 | e.Connect>>3) & 15
+		if e.Rotation != nil {
+			// A turn around X becomes a turn around Z, and a turn around
+			// Z a turn around X the other way.
+			rot := *e.Rotation
+			rot.Origin[0], rot.Origin[2] = 1-e.Rotation.Origin[2], e.Rotation.Origin[0]
+			switch rot.Axis {
+			case 0:
+				rot.Axis = 2
+			case 2:
+				rot.Axis, rot.Angle = 0, -rot.Angle
+			}
+			r.Rotation = &rot
+		}
+		e = r
+	}
+	return e
+}
+
+// CrossModel returns the model of plants: two diagonal planes across the
+// block.
+func CrossModel() *Model {
+	return &Model{Elements: []Element{{To: [3]float32{1, 1, 1}, Cross: true}}}
+}
+
+// SlabModel returns the model of the bottom half of a block.
+func SlabModel() *Model {
+	return &Model{Elements: []Element{Box([3]float32{0, 0, 0}, [3]float32{1, 0.5, 1})}}
+}
+
+// StairsModel returns the model of stairs going up towards the facing.
+func StairsModel() *Model {
+	return &Model{
+		Elements: []Element{
+			Box([3]float32{0, 0, 0}, [3]float32{1, 0.5, 1}),
+			Box([3]float32{0, 0.5, 0}, [3]float32{1, 1, 0.5}),
+		},
+		Facing: true,
+	}
+}
+
+// FenceModel returns the model of fences: a post with arms towards the
+// connected neighbors.
+func FenceModel() *Model {
+	return &Model{Elements: []Element{
+		Box([3]float32{0.375, 0, 0.375}, [3]float32{0.625, 1, 0.625}),
+		withConnect(Box([3]float32{0.4375, 0.375, 0}, [3]float32{0.5625, 0.9375, 0.375}), North),
+		withConnect(Box([3]float32{0.625, 0.375, 0.4375}, [3]float32{1, 0.9375, 0.5625}), East),
+		withConnect(Box([3]float32{0.4375, 0.375, 0.625}, [3]float32{0.5625, 0.9375, 1}), South),
+		withConnect(Box([3]float32{0, 0.375, 0.4375}, [3]float32{0.375, 0.9375, 0.5625}), West),
+	}}
+}
+
+func withConnect(e Element, f Facing) Element {
+	e.Connect = 1 << f
+	return e
+}
@@ -0,0 +1,151 @@
+// package block defines the block types available in the voxel world.
+package block
+
+import (
+	"fmt"
+
+	"github.com/ronoaldo/openvoxel/biome"
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/physics"
+)
+
+// ID identifies a block type in the registry.
+type ID uint16
+
+// Air is the empty block, always registered with ID zero.
+const Air ID = 0
+
+// Definition describes the properties of a block type.
+type Definition struct {
+	// Name is the unique name of the block, such as "openvoxel:dirt".
+	Name string
+
+	// Collision is the list of collision boxes of the block, in block local
+	// coordinates. Blocks without collision, such as air or flowers, use nil.
+	// The boxes of blocks with a Facing model turn with the block, so they
+	// are also modeled facing North.
+	Collision []physics.AABB
+
+	// Hardness is the time, in seconds, to break the block by hand. Zero
+	// breaks it instantly, and negative values make it unbreakable.
+	Hardness float32
+
+	// Resistance is how much the block weakens the explosions going
+	// through it. Unbreakable blocks are never destroyed by explosions.
+	Resistance float32
+
+	// Medium is how the block affects entities inside it, such as making
+	// them swim in water or climb ladders.
+	Medium physics.Medium
+
+	// Opaque blocks stop the light propagation.
+	Opaque bool
+
+	// Emission is the colored light emitted by the block, such as torches
+	// and lava.
+	Emission light.Color
+
+	// Tint selects the biome colormap multiplied with the block texture.
+	Tint biome.Tint
+
+	// Model is the shape drawn for the block, or nil for a full cube.
+	Model *Model
+
+	// Textures are the names of the textures of the faces, in the order +X,
+	// -X, +Y, -Y, +Z, -Z, such as "block/grass_side". The faces of models
+	// with their own Texture ignore them.
+	Textures [6]string
+}
+
+// Registry maps block IDs to their definitions.
+type Registry struct {
+	defs   []Definition
+	byName map[string]ID
+}
+
+// NewRegistry creates a registry with only the Air block registered.
+func NewRegistry() *Registry {
+	r := &Registry{byName: map[string]ID{}}
+	r.Register(Definition{Name: "openvoxel:air"})
+	return r
+}
+
+// Register adds the block definition to the registry and returns its ID.
+func (r *Registry) Register(def Definition) (ID, error) {
+	if _, ok := r.byName[def.Name]; ok {
+		return 0, fmt.Errorf("block: %q already registered", def.Name)
+	}
+	id := ID(len(r.defs))
+	r.defs = append(r.defs, def)
+	r.byName[def.Name] = id
+	return id, nil
+}
+
+// Get returns the definition of the block with the provided ID. Unknown IDs
+// return the Air definition.
+func (r *Registry) Get(id ID) *Definition {
+	if int(id) >= len(r.defs) {
+		return &r.defs[Air]
+	}
+	return &r.defs[id]
+}
+
+// Lookup returns the ID of the block with the provided name.
+func (r *Registry) Lookup(name string) (ID, bool) {
+	id, ok := r.byName[name]
+	return id, ok
+}
+
+// Len returns the number of registered blocks.
+func (r *Registry) Len() int {
+	return len(r.defs)
+}
+
+// Shapes adapts a block lookup function into a physics.ShapeSource using the
+// collision boxes in the registry, turned by the facing of the state.
+func (r *Registry) Shapes(at func(x, y, z int) State) physics.ShapeSource {
+	return shapeSource{r, at}
+}
+
+type shapeSource struct {
+	r  *Registry
+	at func(x, y, z int) State
+}
+
+func (s shapeSource) Shape(x, y, z int) []physics.AABB {
+	st := s.at(x, y, z)
+	def := s.r.Get(st.ID)
+	f := st.Facing() % 4
+	if def.Model == nil || !def.Model.Facing || f == North {
+		return def.Collision
+	}
+	boxes := make([]physics.AABB, len(def.Collision))
+	for i, b := range def.Collision {
+		boxes[i] = rotateBox(b, f)
+	}
+	return boxes
+}
+
+// rotateBox returns the collision box turned around the center of the block
+// by the quarter turns from North to the facing, like Element.Rotate.
+func rotateBox(b physics.AABB, f Facing) physics.AABB {
+	for i := 0; i < int(f)%4; i++ {
+		b = physics.Box(1-b.Max[2], b.Min[1], b.Min[0], 1-b.Min[2], b.Max[1], b.Max[0])
+	}
+	return b
+}
+
+// Media adapts a block lookup function into a physics.MediumSource using the
+// media in the registry.
+func (r *Registry) Media(at func(x, y, z int) ID) physics.MediumSource {
+	return mediumSource{r, at}
+}
+
+type mediumSource struct {
+	r  *Registry
+	at func(x, y, z int) ID
+}
+
+func (s mediumSource) Medium(x, y, z int) physics.Medium {
+	return s.r.Get(s.at(x, y, z)).Medium
+}
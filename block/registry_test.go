@@ -0,0 +1,30 @@
+package block
+
+import (
+	"testing"
+
+	"github.com/ronoaldo/openvoxel/physics"
+)
+
+func TestStairsShapeMatchesModel(t *testing.T) {
+	r := NewRegistry()
+	id, err := r.Register(Definition{Name: "test:stairs", Collision: physics.Stairs, Model: StairsModel()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for f := North; f <= West; f++ {
+		s := State{ID: id}.WithFacing(f)
+		shape := r.Shapes(func(x, y, z int) State { return s }).Shape(0, 0, 0)
+		model := r.Get(id).Model
+		if len(shape) != len(model.Elements) {
+			t.Fatalf("facing %v: %d collision boxes for %d elements", f, len(shape), len(model.Elements))
+		}
+		for i, e := range model.Elements {
+			e = e.Rotate(f)
+			want := physics.Box(e.From[0], e.From[1], e.From[2], e.To[0], e.To[1], e.To[2])
+			if shape[i] != want {
+				t.Errorf("facing %v: collision box %d = %v, want the model's %v", f, i, shape[i], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package block
+
+// State is a block type along with its metadata, such as orientation, growth
+// stage or liquid level. How the metadata bits are used depends on the block
+// type.
+type State struct {
+	ID   ID
+	Meta uint16
+}
+
+// Facing is the horizontal direction a block is facing.
+type Facing uint8
+
+const (
+	North Facing = iota
+	East
+	South
+	West
+)
+
+// Common metadata bit fields. Blocks are free to use the Meta bits in other
+// ways, but builtin helpers use this layout.
+const (
+	facingOffset, facingBits = 0, 2
+	levelOffset, levelBits   = 2, 4
+)
+
+// Bits returns the width bits of the metadata starting at offset.
+func (s State) Bits(offset, width uint) uint16 {
+	return (s.Meta >> offset) & (1<<width - 1)
+}
+
+// WithBits returns a copy of the state with the width bits of the metadata
+// starting at offset set to v.
+func (s State) WithBits(offset, width uint, v uint16) State {
+	mask := uint16(1<<width-1) << offset
+	s.Meta = s.Meta&^mask | (v<<offset)&mask
+	return s
+}
+
+// Facing returns the orientation of blocks such as stairs and doors.
+func (s State) Facing() Facing {
+	return Facing(s.Bits(facingOffset, facingBits))
+}
+
+// WithFacing returns a copy of the state with the provided orientation.
+func (s State) WithFacing(f Facing) State {
+	return s.WithBits(facingOffset, facingBits, uint16(f))
+}
+
+// Level returns the liquid level or growth stage of the block, from 0 to 15.
+func (s State) Level() uint8 {
+	return uint8(s.Bits(levelOffset, levelBits))
+}
+
+// WithLevel returns a copy of the state with the provided liquid level or
+// growth stage.
+func (s State) WithLevel(l uint8) State {
+	return s.WithBits(levelOffset, levelBits, uint16(l))
+}
+
+// Pack returns the state as a single value, as used in event.BlockChanged,
+// with the ID in the upper 16 bits.
+func (s State) Pack() uint32 {
+	return uint32(s.ID)<<16 | uint32(s.Meta)
+}
+
+// Unpack returns the state packed by Pack.
+func Unpack(v uint32) State {
+	return State{ID: ID(v >> 16), Meta: uint16(v)}
+}
@@ -0,0 +1,311 @@
+// package circuit implements signal circuits built from blocks, similar to
+// redstone: power sources and toggles power the wires next to them, wires
+// carry the power to their neighbors losing one level per block, repeaters
+// restore the power after a delay, and receivers such as doors and lamps
+// react to it.
+//
+// Circuits are updated by the block ticks of a tick.Scheduler. The state of
+// the circuit blocks is kept in their metadata:
+//
+//   - Wire: the power level, from 0 to 15.
+//   - Toggle: bit 0 is set when on.
+//   - Repeater: bits 0-2 are the facing direction, an index of Directions,
+//     bit 3 is set when powered, and bits 4-5 are the delay minus one, in
+//     repeater steps of 2 ticks.
+package circuit
+
+import (
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/tick"
+)
+
+// MaxPower is the power of sources, which wires carry for MaxPower blocks.
+const MaxPower = 15
+
+// maxNetwork limits the number of wires updated at once.
+const maxNetwork = 4096
+
+// Kind is the role of a block in a circuit.
+type Kind int
+
+const (
+	None Kind = iota
+	// Wire carries the power of its neighbors.
+	Wire
+	// Source always outputs the maximum power.
+	Source
+	// Toggle outputs the maximum power while on, and is switched on and
+	// off when used by the player.
+	Toggle
+	// Repeater outputs the maximum power in the direction it faces, a
+	// delay after the block behind it is powered.
+	Repeater
+	// Receiver publishes event.SignalChanged when the power it receives
+	// changes.
+	Receiver
+)
+
+// Directions are the neighbors of a block, indexed by the facing direction of
+// the repeaters.
+var Directions = [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}}
+
+// Repeater metadata bits.
+const (
+	repeaterFacing  = 0x7
+	repeaterPowered = 0x8
+	repeaterDelay   = 4
+)
+
+// World is the voxel storage the circuits are built in.
+type World interface {
+	Block(x, y, z int) block.State
+	SetBlock(x, y, z int, s block.State)
+}
+
+// Circuit updates the circuit blocks of a world.
+type Circuit struct {
+	World World
+	Ticks *tick.Scheduler
+	Bus   *event.Bus
+
+	kinds map[block.ID]Kind
+	// power is the last power published for each receiver.
+	power map[[3]int]int
+	// updating ignores the block changes made by the circuit itself.
+	updating bool
+}
+
+// New creates a circuit updated by the scheduler, publishing on the bus.
+func New(w World, t *tick.Scheduler, b *event.Bus) *Circuit {
+	return &Circuit{
+		World: w,
+		Ticks: t,
+		Bus:   b,
+		kinds: map[block.ID]Kind{},
+		power: map[[3]int]int{},
+	}
+}
+
+// Register sets the circuit kind of the blocks with the id.
+func (c *Circuit) Register(id block.ID, k Kind) {
+	c.kinds[id] = k
+	switch k {
+	case Wire:
+		c.Ticks.OnScheduledTick(id, func(x, y, z int, s block.State) { c.updateWires([3]int{x, y, z}) })
+	case Repeater:
+		c.Ticks.OnScheduledTick(id, c.updateRepeater)
+	case Receiver:
+		c.Ticks.OnScheduledTick(id, c.updateReceiver)
+	}
+}
+
+// Swap registers the off and on blocks as receivers that turn into on while
+// powered and back into off otherwise, keeping their metadata, such as lamps
+// or doors. It returns a function that stops listening.
+func (c *Circuit) Swap(off, on block.ID) (unsubscribe func()) {
+	c.Register(off, Receiver)
+	c.Register(on, Receiver)
+	return event.Subscribe(c.Bus, func(e event.SignalChanged) {
+		s := block.Unpack(e.Block)
+		switch {
+		case s.ID == off && e.Power > 0:
+			s.ID = on
+		case s.ID == on && e.Power == 0:
+			s.ID = off
+		default:
+			return
+		}
+		c.set([3]int{e.X, e.Y, e.Z}, s)
+	})
+}
+
+// Listen updates the circuits next to the blocks changed in the world, and
+// switches the toggles used by the player. It returns a function that stops
+// listening.
+func (c *Circuit) Listen() (unsubscribe func()) {
+	stopChanged := event.Subscribe(c.Bus, func(e event.BlockChanged) {
+		if !c.updating {
+			c.Notify(e.X, e.Y, e.Z)
+		}
+	})
+	stopExplosion := event.Subscribe(c.Bus, func(e event.Explosion) {
+		for _, b := range e.Blocks {
+			c.Notify(b.X, b.Y, b.Z)
+		}
+	})
+	stopUse := event.Subscribe(c.Bus, func(e *event.BlockUse) {
+		s := block.Unpack(e.Block)
+		if e.Handled || c.kinds[s.ID] != Toggle {
+			return
+		}
+		e.Handled = true
+		s.Meta ^= 1
+		c.set([3]int{e.X, e.Y, e.Z}, s)
+		c.Notify(e.X, e.Y, e.Z)
+	})
+	return func() {
+		stopChanged()
+		stopExplosion()
+		stopUse()
+	}
+}
+
+// Notify schedules the update of the circuit blocks at and around the world
+// coordinates x, y, z, after a block changed there.
+func (c *Circuit) Notify(x, y, z int) {
+	p := [3]int{x, y, z}
+	c.schedule(p)
+	for _, d := range Directions {
+		c.schedule(add(p, d))
+	}
+}
+
+func (c *Circuit) schedule(p [3]int) {
+	s := c.World.Block(p[0], p[1], p[2])
+	switch c.kinds[s.ID] {
+	case Wire, Receiver:
+		c.Ticks.Schedule(p[0], p[1], p[2], 1)
+	case Repeater:
+		delay := int(s.Meta>>repeaterDelay&0x3) + 1
+		c.Ticks.Schedule(p[0], p[1], p[2], delay*2)
+	}
+}
+
+// set changes a block without notifying the circuit, and publishes
+// event.BlockChanged.
+func (c *Circuit) set(p [3]int, s block.State) {
+	old := c.World.Block(p[0], p[1], p[2])
+	c.World.SetBlock(p[0], p[1], p[2], s)
+	c.updating = true
+	event.Publish(c.Bus, event.BlockChanged{X: p[0], Y: p[1], Z: p[2], Old: old.Pack(), New: s.Pack()})
+	c.updating = false
+}
+
+// output returns the power the block at p outputs to its neighbor at to.
+func (c *Circuit) output(p, to [3]int) int {
+	s := c.World.Block(p[0], p[1], p[2])
+	switch c.kinds[s.ID] {
+	case Source:
+		return MaxPower
+	case Toggle:
+		if s.Meta&1 != 0 {
+			return MaxPower
+		}
+	case Wire:
+		return int(s.Meta & 0xf)
+	case Repeater:
+		if s.Meta&repeaterPowered != 0 && add(p, facing(s)) == to {
+			return MaxPower
+		}
+	}
+	return 0
+}
+
+// Power returns the power received by the block at the world coordinates x,
+// y, z from its neighbors.
+func (c *Circuit) Power(x, y, z int) int {
+	p := [3]int{x, y, z}
+	power := 0
+	for _, d := range Directions {
+		if v := c.output(add(p, d), p); v > power {
+			power = v
+		}
+	}
+	return power
+}
+
+// updateWires recomputes the power of the network of wires connected to the
+// wire at start, and notifies the blocks next to the wires that changed.
+func (c *Circuit) updateWires(start [3]int) {
+	isWire := func(p [3]int) bool {
+		return c.kinds[c.World.Block(p[0], p[1], p[2]).ID] == Wire
+	}
+	// Find the network and the power it receives from other blocks.
+	level := map[[3]int]int{start: 0}
+	queue := [][3]int{start}
+	var buckets [MaxPower + 1][][3]int
+	for i := 0; i < len(queue) && len(level) < maxNetwork; i++ {
+		p := queue[i]
+		in := 0
+		for _, d := range Directions {
+			n := add(p, d)
+			if isWire(n) {
+				if _, ok := level[n]; !ok {
+					level[n] = 0
+					queue = append(queue, n)
+				}
+			} else if v := c.output(n, p); v > in {
+				in = v
+			}
+		}
+		level[p] = in
+		buckets[in] = append(buckets[in], p)
+	}
+	// Spread the power from the strongest inputs, one level per wire.
+	for l := MaxPower; l > 1; l-- {
+		for _, p := range buckets[l] {
+			if level[p] != l {
+				continue
+			}
+			for _, d := range Directions {
+				n := add(p, d)
+				if v, ok := level[n]; ok && v < l-1 {
+					level[n] = l - 1
+					buckets[l-1] = append(buckets[l-1], n)
+				}
+			}
+		}
+	}
+	for _, p := range queue {
+		s := c.World.Block(p[0], p[1], p[2])
+		if int(s.Meta&0xf) == level[p] {
+			continue
+		}
+		s.Meta = s.Meta&^0xf | uint16(level[p])
+		c.set(p, s)
+		for _, d := range Directions {
+			if n := add(p, d); !isWire(n) {
+				c.schedule(n)
+			}
+		}
+	}
+}
+
+// updateRepeater powers the repeater if the block behind it outputs power to
+// it, and notifies the block in front when it changes.
+func (c *Circuit) updateRepeater(x, y, z int, s block.State) {
+	p := [3]int{x, y, z}
+	f := facing(s)
+	behind := [3]int{p[0] - f[0], p[1] - f[1], p[2] - f[2]}
+	powered := c.output(behind, p) > 0
+	if powered == (s.Meta&repeaterPowered != 0) {
+		return
+	}
+	s.Meta ^= repeaterPowered
+	c.set(p, s)
+	c.Notify(p[0]+f[0], p[1]+f[1], p[2]+f[2])
+}
+
+// updateReceiver publishes event.SignalChanged if the power received changed.
+func (c *Circuit) updateReceiver(x, y, z int, s block.State) {
+	p := [3]int{x, y, z}
+	power := c.Power(x, y, z)
+	if power == c.power[p] {
+		return
+	}
+	if power == 0 {
+		delete(c.power, p)
+	} else {
+		c.power[p] = power
+	}
+	event.Publish(c.Bus, event.SignalChanged{X: x, Y: y, Z: z, Block: s.Pack(), Power: power})
+}
+
+func facing(s block.State) [3]int {
+	return Directions[int(s.Meta&repeaterFacing)%len(Directions)]
+}
+
+func add(a, b [3]int) [3]int {
+	return [3]int{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
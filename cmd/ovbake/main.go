@@ -0,0 +1,113 @@
+// The `ovbake` command bakes the lighting of MagicaVoxel .vox structures, and
+// writes the lightmap alongside each file, with the structure.LightmapExt
+// extension, to be loaded with structure.Structure.DecodeLightmap:
+//
+//	go run ./cmd/ovbake -samples 512 -emissive 9,10 assets/structures/*.vox
+//
+// All the voxels are opaque blocks, and the ones with the -emissive palette
+// indices emit light of their color. It runs without a window or a GPU, so it
+// can bake the structures on a build server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/structure"
+)
+
+var (
+	samples  = flag.Int("samples", structure.DefaultBakeOptions.Samples, "rays traced from each block")
+	bounces  = flag.Int("bounces", structure.DefaultBakeOptions.Bounces, "times a ray is reflected by the blocks")
+	sky      = flag.String("sky", "#ffffff", "color of the light coming from outside the structure, #000000 for underground structures")
+	albedo   = flag.Float64("albedo", float64(structure.DefaultBakeOptions.Albedo), "fraction of the light reflected by the blocks")
+	emissive = flag.String("emissive", "", "comma separated palette indices of the voxels emitting light")
+	seed     = flag.Uint64("seed", structure.DefaultBakeOptions.Seed, "seed of the random rays")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: ovbake [flags] file.vox...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	emitters := map[uint8]bool{}
+	for _, s := range strings.Split(*emissive, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 1 || v > 255 {
+			log.Fatalf("invalid palette index: %q", s)
+		}
+		emitters[uint8(v)] = true
+	}
+	var c color.RGBA
+	if _, err := fmt.Sscanf(*sky, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		log.Fatalf("invalid sky color: %q", *sky)
+	}
+	o := structure.DefaultBakeOptions
+	o.Samples, o.Bounces, o.Albedo, o.Seed = *samples, *bounces, float32(*albedo), *seed
+	o.Sky = [3]float32{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255}
+
+	for _, name := range flag.Args() {
+		if err := bake(name, emitters, o); err != nil {
+			log.Fatalf("%v: %v", name, err)
+		}
+	}
+}
+
+// bake reads the structure file, bakes it and writes its lightmap.
+func bake(name string, emitters map[uint8]bool, o structure.BakeOptions) error {
+	fd, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	// Each palette color is an opaque block, emitting light of its color
+	// if it is one of the emitters.
+	reg := block.NewRegistry()
+	ids := map[uint8]block.ID{}
+	s, err := structure.ReadVox(fd, func(i uint8, c color.RGBA) block.State {
+		if id, ok := ids[i]; ok {
+			return block.State{ID: id}
+		}
+		def := block.Definition{Name: fmt.Sprintf("vox:%d", i), Opaque: true}
+		if emitters[i] {
+			def.Emission = light.RGB(c.R/17, c.G/17, c.B/17)
+		}
+		id, _ := reg.Define(def)
+		ids[i] = id
+		return block.State{ID: id}
+	})
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	structure.Bake(s, reg, o)
+	b, err := s.EncodeLightmap()
+	if err != nil {
+		return err
+	}
+	out := name + structure.LightmapExt
+	if err := os.WriteFile(out, b, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%v: %dx%dx%d blocks baked in %v\n", out, s.SizeX, s.SizeY, s.SizeZ, time.Since(start).Round(time.Millisecond))
+	return nil
+}
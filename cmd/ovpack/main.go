@@ -0,0 +1,210 @@
+// The `ovpack` command builds a program for several platforms and packages it
+// for distribution.
+//
+// Native builds produce the binary and an archive with the binary and the
+// assets: a zip file for Windows and macOS, and a tar.gz for Linux. The js/wasm
+// build produces the web deploy files, a <name>_js_wasm.html page with the
+// wasm binary, wasm_exec.js and worker.js, plus a zip archive of them.
+//
+// The program expects to be executed from the root project folder:
+//
+//	go run ./cmd/ovpack [-o build] [-targets linux/amd64,js/wasm] [-assets dir] [package]
+//
+// The package defaults to ./exp/cmd/helloworld. Native targets use cgo, and the
+// C cross compilers are selected as in scripts/cross-setup.sh; macOS targets
+// expect the osxcross compilers in the PATH. The exit status is non-zero if any
+// target fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/ronoaldo/openvoxel/internal/wasmpage"
+	"github.com/ronoaldo/openvoxel/worker"
+)
+
+// defaultTargets are the platforms built by the CI.
+const defaultTargets = "windows/386,windows/amd64,linux/386,linux/amd64,linux/arm64,js/wasm"
+
+// crossCompilers are the C compilers used to cross build with cgo.
+var crossCompilers = map[string]string{
+	"windows/386":   "i686-w64-mingw32-gcc",
+	"windows/amd64": "x86_64-w64-mingw32-gcc",
+	"linux/386":     "i686-linux-gnu-gcc",
+	"linux/arm64":   "aarch64-linux-gnu-gcc",
+	"linux/arm":     "arm-linux-gnueabi-gcc",
+	"darwin/amd64":  "o64-clang",
+	"darwin/arm64":  "oa64-clang",
+}
+
+var (
+	outDir  = flag.String("o", "build", "output directory")
+	targets = flag.String("targets", defaultTargets, "comma separated list of os/arch pairs to build")
+	assets  = flag.String("assets", "", "directory copied next to the binaries and into the archives")
+	small   = flag.Bool("small", false, "reduce the binary size: strip debug information and only decode PNG textures")
+	tinygo  = flag.Bool("tinygo", false, "build the js/wasm target with TinyGo (experimental, not tested)")
+	clean   = flag.Bool("clean", false, "remove the output directory contents before building")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: ovpack [flags] [package]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	pkg := "./exp/cmd/helloworld"
+	if flag.NArg() > 0 {
+		pkg = flag.Arg(0)
+	}
+
+	if *clean {
+		if err := os.RemoveAll(*outDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+
+	var failed []string
+	for _, t := range strings.Split(*targets, ",") {
+		goos, goarch, ok := strings.Cut(strings.TrimSpace(t), "/")
+		if !ok {
+			log.Fatalf("Invalid target %q, expected os/arch", t)
+		}
+		start := time.Now()
+		log.Printf("Building %v for %v/%v ...", pkg, goos, goarch)
+		var err error
+		if goos == "js" {
+			err = packWeb(pkg, goarch)
+		} else {
+			err = packNative(pkg, goos, goarch)
+		}
+		if err != nil {
+			log.Printf("Error building %v/%v: %v", goos, goarch, err)
+			failed = append(failed, t)
+			continue
+		}
+		log.Printf("Built %v/%v in %v", goos, goarch, time.Since(start).Round(time.Millisecond))
+	}
+	if len(failed) > 0 {
+		log.Printf("Failed targets: %v", strings.Join(failed, ", "))
+		os.Exit(1)
+	}
+}
+
+// stem returns the base name of the files of a target, as in
+// helloworld_linux_amd64.
+func stem(pkg, goos, goarch string) string {
+	return fmt.Sprintf("%v_%v_%v", path.Base(filepath.ToSlash(pkg)), goos, goarch)
+}
+
+// goBuild runs go build for the platform with the size flags applied.
+func goBuild(pkg, goos, goarch, out string, env ...string) error {
+	args := []string{"build", "-o", out}
+	if *small {
+		args = append(args, "-trimpath", "-tags", "openvoxel_small", "-ldflags", "-s -w")
+	}
+	cmd := exec.Command("go", append(args, pkg)...)
+	cmd.Env = append(os.Environ(), append([]string{"GOOS=" + goos, "GOARCH=" + goarch}, env...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// packNative builds a native binary and archives it with the assets.
+func packNative(pkg, goos, goarch string) error {
+	name := stem(pkg, goos, goarch)
+	bin := name
+	if goos == "windows" {
+		bin += ".exe"
+	}
+	env := []string{"CGO_ENABLED=1"}
+	if goos != runtime.GOOS || goarch != runtime.GOARCH {
+		if cc, ok := crossCompilers[goos+"/"+goarch]; ok {
+			env = append(env, "CC="+cc)
+		}
+	}
+	if err := goBuild(pkg, goos, goarch, filepath.Join(*outDir, bin), env...); err != nil {
+		return err
+	}
+
+	files := []string{bin}
+	if *assets != "" {
+		dst := filepath.Base(*assets)
+		if err := copyDir(*assets, filepath.Join(*outDir, dst)); err != nil {
+			return err
+		}
+		files = append(files, dst)
+	}
+	if goos == "linux" {
+		return writeTarGz(filepath.Join(*outDir, name+".tar.gz"), *outDir, files)
+	}
+	return writeZip(filepath.Join(*outDir, name+".zip"), *outDir, files)
+}
+
+// packWeb builds the wasm binary and the files needed to serve it.
+func packWeb(pkg, goarch string) error {
+	name := stem(pkg, "js", goarch)
+	wasm := name + ".wasm"
+	out := filepath.Join(*outDir, wasm)
+
+	var execJS string
+	if *tinygo {
+		b, err := exec.Command("tinygo", "env", "TINYGOROOT").Output()
+		if err != nil {
+			return fmt.Errorf("finding TinyGo: %w", err)
+		}
+		execJS = filepath.Join(strings.TrimSpace(string(b)), "targets", "wasm_exec.js")
+		cmd := exec.Command("tinygo", "build", "-target", "wasm", "-no-debug",
+			"-tags", "openvoxel_small", "-o", out, pkg)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if execJS, err = wasmpage.ExecJS(); err != nil {
+			return err
+		}
+		if err := goBuild(pkg, "js", goarch, out, "CGO_ENABLED=0"); err != nil {
+			return err
+		}
+	}
+
+	if err := copyFile(execJS, filepath.Join(*outDir, "wasm_exec.js")); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(*outDir, "worker.js"), worker.Script, 0644); err != nil {
+		return err
+	}
+	page, err := os.Create(filepath.Join(*outDir, name+".html"))
+	if err != nil {
+		return err
+	}
+	if err := wasmpage.Write(page, wasmpage.Page{Name: path.Base(filepath.ToSlash(pkg)), Wasm: wasm}); err != nil {
+		page.Close()
+		return err
+	}
+	if err := page.Close(); err != nil {
+		return err
+	}
+
+	files := []string{name + ".html", wasm, "wasm_exec.js", "worker.js"}
+	if *assets != "" {
+		dst := filepath.Base(*assets)
+		if err := copyDir(*assets, filepath.Join(*outDir, dst)); err != nil {
+			return err
+		}
+		files = append(files, dst)
+	}
+	return writeZip(filepath.Join(*outDir, name+".zip"), *outDir, files)
+}
@@ -0,0 +1,95 @@
+// The `seedview` command renders previews of the terrain generated for a
+// seed, to find interesting seeds and to validate changes to the world
+// generator visually.
+//
+// By default, it writes a heightmap and a biome map to PNG files for each zoom
+// level, in blocks per pixel, centered on the -x and -z coordinates:
+//
+//	go run ./cmd/seedview -seed 42 -zoom 1,4,16 -o previews
+//
+// With -window, the previews are shown interactively instead: the arrow keys
+// or WASD move the view, + and - change the zoom, B switches between the
+// heightmap and the biome map, and P saves the current preview.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ronoaldo/openvoxel/worldgen"
+)
+
+var (
+	seed   = flag.Uint64("seed", 1, "world seed")
+	x      = flag.Int("x", 0, "x coordinate of the preview center")
+	z      = flag.Int("z", 0, "z coordinate of the preview center")
+	size   = flag.Int("size", 512, "preview size, in pixels")
+	zooms  = flag.String("zoom", "1,4,16", "comma separated zoom levels, in blocks per pixel")
+	outDir = flag.String("o", ".", "output directory of the PNG files")
+	window = flag.Bool("window", false, "show the previews in a window instead of writing files")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: seedview [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var levels []int
+	for _, s := range strings.Split(*zooms, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || v < 1 {
+			log.Fatalf("invalid zoom level: %q", s)
+		}
+		levels = append(levels, v)
+	}
+
+	g := worldgen.New(*seed)
+	if *window {
+		if err := view(g, levels[0]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	for _, zoom := range levels {
+		for _, k := range []kind{heightmap, biomes} {
+			p := &preview{gen: g, kind: k, x: *x, z: *z, zoom: zoom, size: *size}
+			name, err := save(p, *outDir)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(name)
+		}
+	}
+}
+
+// save renders the preview and writes it to a PNG file in dir, returning the
+// file name.
+func save(p *preview, dir string) (string, error) {
+	name := filepath.Join(dir, fmt.Sprintf("seed%d_%s_x%d_z%d_zoom%d.png", p.gen.Seed, p.kind, p.x, p.z, p.zoom))
+	b, err := encode(p.render())
+	if err != nil {
+		return "", err
+	}
+	return name, os.WriteFile(name, b, 0644)
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/ronoaldo/openvoxel/biome"
+	"github.com/ronoaldo/openvoxel/job"
+	"github.com/ronoaldo/openvoxel/worldgen"
+)
+
+// kind is the information shown by a preview.
+type kind int
+
+const (
+	heightmap kind = iota
+	biomes
+)
+
+func (k kind) String() string {
+	if k == biomes {
+		return "biome"
+	}
+	return "height"
+}
+
+// biomeColors are the colors of the biomes in the biome map.
+var biomeColors = map[*biome.Biome]color.RGBA{
+	worldgen.Ocean:     {0x1e, 0x3c, 0x96, 0xff},
+	worldgen.Beach:     {0xe8, 0xd8, 0x8e, 0xff},
+	worldgen.Plains:    {0x8d, 0xb3, 0x60, 0xff},
+	worldgen.Forest:    {0x2f, 0x6b, 0x2a, 0xff},
+	worldgen.Desert:    {0xf0, 0xc0, 0x6c, 0xff},
+	worldgen.Taiga:     {0x3b, 0x5e, 0x4f, 0xff},
+	worldgen.Tundra:    {0xe6, 0xf0, 0xf5, 0xff},
+	worldgen.Mountains: {0x84, 0x80, 0x7c, 0xff},
+}
+
+// preview is a top down view of the terrain, centered on x, z, with zoom
+// blocks per pixel.
+type preview struct {
+	gen        *worldgen.Generator
+	kind       kind
+	x, z, zoom int
+	size       int
+}
+
+// render computes the preview image, one row per job of job.Default.
+func (p *preview) render() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, p.size, p.size))
+	rows := make([]*job.Job, p.size)
+	for py := range rows {
+		py := py
+		rows[py] = job.Default.Submit(func() {
+			for px := 0; px < p.size; px++ {
+				wx := p.x + (px-p.size/2)*p.zoom
+				wz := p.z + (py-p.size/2)*p.zoom
+				img.SetRGBA(px, py, p.color(wx, wz))
+			}
+		})
+	}
+	for _, j := range rows {
+		j.Wait()
+	}
+	return img
+}
+
+// color returns the pixel color for the world column x, z.
+func (p *preview) color(x, z int) color.RGBA {
+	g := p.gen
+	if p.kind == biomes {
+		return biomeColors[g.Biome(x, z)]
+	}
+	h := g.Height(x, z)
+	if h < g.SeaLevel {
+		// Deeper water is darker.
+		d := float32(g.SeaLevel-h) / 48
+		return shade(color.RGBA{0x3a, 0x6e, 0xd8, 0xff}, 1-0.6*min32(d, 1))
+	}
+	var c color.RGBA
+	switch above := h - g.SeaLevel; {
+	case above < 2:
+		c = color.RGBA{0xe8, 0xd8, 0x8e, 0xff}
+	case above < 32:
+		c = lerp(color.RGBA{0x6d, 0xa3, 0x4a, 0xff}, color.RGBA{0x8a, 0x7a, 0x52, 0xff}, float32(above)/32)
+	case above < 64:
+		c = lerp(color.RGBA{0x8a, 0x7a, 0x52, 0xff}, color.RGBA{0x8c, 0x8c, 0x8c, 0xff}, float32(above-32)/32)
+	default:
+		c = color.RGBA{0xf4, 0xf4, 0xf8, 0xff}
+	}
+	// Light the slopes from the north west, so the relief is visible.
+	slope := float32(h-g.Height(x-p.zoom, z-p.zoom)) / float32(p.zoom)
+	return shade(c, 1+max32(min32(slope*0.15, 0.3), -0.3))
+}
+
+func shade(c color.RGBA, f float32) color.RGBA {
+	ch := func(v uint8) uint8 { return uint8(min32(float32(v)*f, 255)) }
+	return color.RGBA{ch(c.R), ch(c.G), ch(c.B), c.A}
+}
+
+func lerp(a, b color.RGBA, t float32) color.RGBA {
+	ch := func(x, y uint8) uint8 { return uint8(float32(x) + (float32(y)-float32(x))*t) }
+	return color.RGBA{ch(a.R, b.R), ch(a.G, b.G), ch(a.B, b.B), 0xff}
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
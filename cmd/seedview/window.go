@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/render"
+	"github.com/ronoaldo/openvoxel/worldgen"
+)
+
+const previewFragmentGLSL = `
+in vec2 TexCoord;
+out vec4 FragColor;
+uniform sampler2D preview;
+
+void main() {
+    FragColor = texture(preview, TexCoord);
+}
+`
+
+// view shows the previews in a window until it is closed.
+func view(g *worldgen.Generator, zoom int) error {
+	w, err := render.NewWindow(*size, *size, fmt.Sprintf("seedview: %d", g.Seed))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	shader := &render.Shader{}
+	shader.VertexShader("#version 330 core\n" + render.FullscreenVertexGLSL).
+		FragmentShader("#version 330 core\n" + previewFragmentGLSL)
+	if err := shader.Link(); err != nil {
+		return err
+	}
+	defer shader.Delete()
+
+	p := &preview{gen: g, kind: heightmap, x: *x, z: *z, zoom: zoom, size: *size}
+	dirty := true
+	unsubscribe := event.Subscribe(event.Default, func(e event.Key) {
+		if e.Action == event.KeyRelease {
+			return
+		}
+		step := p.size / 8 * p.zoom
+		switch e.Key {
+		case 'W', 265:
+			p.z -= step
+		case 'S', 264:
+			p.z += step
+		case 'A', 263:
+			p.x -= step
+		case 'D', 262:
+			p.x += step
+		case '=', 334:
+			if p.zoom > 1 {
+				p.zoom /= 2
+			}
+		case '-', 333:
+			if p.zoom < 256 {
+				p.zoom *= 2
+			}
+		case 'B':
+			p.kind = 1 - p.kind
+		case 'P':
+			if e.Action == event.KeyPress {
+				name, err := save(p, *outDir)
+				if err != nil {
+					log.Print(err)
+					return
+				}
+				fmt.Println(name)
+			}
+			return
+		default:
+			return
+		}
+		dirty = true
+	})
+	defer unsubscribe()
+
+	var tex *render.Texture
+	defer func() {
+		if tex != nil {
+			tex.Delete()
+		}
+	}()
+	for !w.ShouldClose() {
+		if dirty {
+			b, err := encode(p.render())
+			if err != nil {
+				return err
+			}
+			if tex != nil {
+				tex.Delete()
+			}
+			if tex, err = render.NewTextureFromBytes(b); err != nil {
+				return err
+			}
+			fmt.Printf("%s at x=%d z=%d, %d blocks per pixel\n", p.kind, p.x, p.z, p.zoom)
+			dirty = false
+		}
+		w.BindDefaultFramebuffer()
+		shader.Use()
+		shader.UniformInts("preview", 0)
+		tex.Bind(0)
+		render.DrawFullscreen()
+		w.SwapBuffers()
+		w.WaitEvents()
+	}
+	return nil
+}
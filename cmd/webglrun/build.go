@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// target is a wasm program built and served by webglrun.
+type target struct {
+	// Pkg is the package path passed to go build.
+	Pkg string
+	// Name is the last element of the package path.
+	Name string
+	// Wasm is the file name of the binary, relative to the target page.
+	Wasm string
+
+	out string
+
+	mu         sync.RWMutex
+	err        error
+	built      time.Time
+	compressed compressed
+	// dirs are the source directories of the target and its dependencies.
+	dirs map[string]bool
+}
+
+func newTarget(pkg, outDir string) *target {
+	name := path.Base(filepath.ToSlash(pkg))
+	return &target{
+		Pkg:  pkg,
+		Name: name,
+		Wasm: name + ".wasm",
+		out:  filepath.Join(outDir, name+".wasm"),
+	}
+}
+
+// build compiles the target with GOOS=js GOARCH=wasm. The binary is replaced
+// only when the build succeeds, so the last good version is still served
+// otherwise.
+func (t *target) build() error {
+	start := time.Now()
+	tmp := t.out + ".tmp"
+	cmd := exec.Command("go", "build", "-o", tmp, t.Pkg)
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm", "CGO_ENABLED=0")
+	b, err := cmd.CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("%v\n%s", err, strings.TrimSpace(string(b)))
+	} else {
+		var c compressed
+		if c, err = compress(tmp); err == nil {
+			t.mu.Lock()
+			if err = os.Rename(tmp, t.out); err == nil {
+				t.compressed, t.built = c, time.Now()
+			}
+			t.mu.Unlock()
+		}
+	}
+
+	dirs, derr := depDirs(t.Pkg)
+	t.mu.Lock()
+	t.err = err
+	if derr == nil {
+		t.dirs = dirs
+	}
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	log.Printf("Built %v in %v", t.Pkg, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// Err returns the error of the last build, if it failed.
+func (t *target) Err() error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.err
+}
+
+// dependsOn reports whether the source directory dir is used by the target.
+// Before the first build all directories are considered dependencies.
+func (t *target) dependsOn(dir string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.dirs == nil || t.dirs[dir]
+}
+
+// depDirs returns the source directories of pkg and of its dependencies, for
+// the js/wasm platform.
+func depDirs(pkg string) (map[string]bool, error) {
+	cmd := exec.Command("go", "list", "-deps", "-f", "{{.Dir}}", pkg)
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	b, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	dirs := make(map[string]bool)
+	for _, d := range strings.Fields(string(b)) {
+		dirs[d] = true
+	}
+	return dirs, nil
+}
+
+// renderPkg is imported by all the WebGL programs.
+const renderPkg = "github.com/ronoaldo/openvoxel/render"
+
+// discover returns the main packages under cmd/ and exp/cmd/ that build for
+// js/wasm and use the render package, excluding webglrun itself.
+func discover() ([]string, error) {
+	cmd := exec.Command("go", "list", "-e",
+		"-f", "{{.Name}} {{.ImportPath}} {{if .Error}}error{{end}} {{join .Imports \",\"}}",
+		"./cmd/...", "./exp/cmd/...")
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	b, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 3 || f[0] != "main" || strings.HasSuffix(f[1], "/webglrun") {
+			continue
+		}
+		for _, imp := range strings.Split(f[2], ",") {
+			if imp == renderPkg {
+				pkgs = append(pkgs, f[1])
+				break
+			}
+		}
+	}
+	return pkgs, nil
+}
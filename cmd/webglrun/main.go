@@ -1,38 +1,177 @@
-// The `webglrun` command provides a simple tool to rebuild wasm programs using
-// the scripts/make.sh build helper.
+// The `webglrun` command is a development server for the WebGL programs.
+//
+// It discovers the programs under cmd/ and exp/cmd/ that use the render
+// package, builds them with GOOS=js GOARCH=wasm, and serves a gallery page to
+// pick which one to run. Each program is served with a generated index.html
+// and the wasm_exec.js of the Go toolchain in use, and is rebuilt whenever one
+// of the Go packages it depends on changes. Open pages reload after each
+// successful build, or display the compiler errors when it fails.
 //
 // The program expects to be executed from the root project folder.  A simple
 // invocation can be executed with:
 //
-//	go run cmd/webglrun/main.go
+//	go run ./cmd/webglrun [-host localhost] [-port 8080] [-isolate] [package ...]
+//
+// When packages are given, only those are served. The wasm binaries are
+// served compressed with gzip, or brotli when the brotli command is installed.
+// The -isolate flag enables the cross-origin isolation headers required by
+// SharedArrayBuffer.
 package main
 
 import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/ronoaldo/openvoxel/internal/wasmpage"
+	"github.com/ronoaldo/openvoxel/worker"
+)
+
+//go:embed gallery.html
+var galleryHTML string
+
+//go:embed reload.js
+var reloadJS []byte
+
+var galleryTmpl = template.Must(template.New("gallery").Parse(galleryHTML))
+
+var (
+	host    = flag.String("host", "localhost", "host name or address to listen on")
+	port    = flag.Int("port", 8080, "port to listen on")
+	isolate = flag.Bool("isolate", false, "send the COOP/COEP headers to enable SharedArrayBuffer")
 )
 
 func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: webglrun [flags] [package ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	pkgs := flag.Args()
+	if len(pkgs) == 0 {
+		var err error
+		if pkgs, err = discover(); err != nil {
+			log.Fatalf("Error discovering the WebGL programs: %v", err)
+		}
+	}
+	if len(pkgs) == 0 {
+		log.Fatal("No WebGL programs found")
+	}
+
+	outDir, err := os.MkdirTemp("", "webglrun")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(outDir)
+
+	wasmExec, err := wasmpage.ExecJS()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	hub := newReloadHub()
+	targets := make(map[string]*target)
+	var names []string
+	for _, pkg := range pkgs {
+		t := newTarget(pkg, outDir)
+		if _, dup := targets[t.Name]; dup {
+			log.Printf("Skipping %v: there is already a program named %v", pkg, t.Name)
+			continue
+		}
+		targets[t.Name] = t
+		names = append(names, t.Name)
+		rebuild(t, hub)
+	}
+	sort.Strings(names)
+
 	log.Print("Watching for file changes ... ")
-	go watchForChanges()
+	go watchForChanges(targets, hub)
 
-	_, err := exec.Command("xdg-open", "http://localhost:8000/").CombinedOutput()
-	log.Printf("Launching browser (err=%v)", err)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		name, file, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if name == "" {
+			if len(names) == 1 {
+				http.Redirect(w, r, "/"+names[0]+"/", http.StatusFound)
+				return
+			}
+			var list []*target
+			for _, n := range names {
+				list = append(list, targets[n])
+			}
+			if err := galleryTmpl.Execute(w, list); err != nil {
+				log.Printf("Error rendering gallery: %v", err)
+			}
+			return
+		}
+		t, ok := targets[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch file {
+		case "":
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				http.Redirect(w, r, r.URL.Path+"/", http.StatusFound)
+				return
+			}
+			page := wasmpage.Page{
+				Name:  t.Name,
+				Wasm:  t.Wasm,
+				Extra: template.HTML(`<script src="/_webglrun/reload.js" data-target="` + template.HTMLEscapeString(t.Name) + `"></script>`),
+			}
+			if err := wasmpage.Write(w, page); err != nil {
+				log.Printf("Error rendering index: %v", err)
+			}
+		case "wasm_exec.js":
+			http.ServeFile(w, r, wasmExec)
+		case "worker.js":
+			w.Header().Set("Content-Type", "text/javascript")
+			w.Write(worker.Script)
+		case t.Wasm:
+			serveWasm(w, r, t)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	http.Handle(reloadPath, hub)
+	http.HandleFunc("/_webglrun/reload.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/javascript")
+		w.Write(reloadJS)
+	})
 
-	log.Print("Starting server for wasmrun ...")
-	http.Handle("/", http.FileServer(http.Dir("./")))
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	addr := net.JoinHostPort(*host, strconv.Itoa(*port))
+	url := "http://" + addr + "/"
+	_, err = exec.Command("xdg-open", url).CombinedOutput()
+	log.Printf("Launching browser at %v (err=%v)", url, err)
+
+	log.Printf("Serving %v at %v", strings.Join(names, ", "), url)
+	log.Fatal(http.ListenAndServe(addr, withHeaders(http.DefaultServeMux, *isolate)))
 }
 
-func watchForChanges() {
+// rebuild builds t and notifies its open pages.
+func rebuild(t *target, hub *reloadHub) {
+	if err := t.build(); err != nil {
+		log.Printf("Build of %v failed: %v", t.Pkg, err)
+		hub.Broadcast(reloadMessage{Type: "error", Target: t.Name, Error: err.Error()})
+		return
+	}
+	hub.Broadcast(reloadMessage{Type: "reload", Target: t.Name})
+}
+
+func watchForChanges(targets map[string]*target, hub *reloadHub) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		panic(err)
@@ -46,18 +185,17 @@ func watchForChanges() {
 				if !ok {
 					return
 				}
-				log.Printf("Event: %#v", event)
 				if strings.HasSuffix(event.Name, ".go") {
 					if time.Since(lastBuild) < 100*time.Millisecond {
 						log.Printf("Not rebuilding since lastBuild is %v ago", time.Since(lastBuild))
 						continue
 					}
-
-					b, err := exec.Command("scripts/make.sh", "js", "wasm").CombinedOutput()
-					if err != nil {
-						log.Printf("Error: %v", err)
-					} else {
-						log.Printf("Build output: %v", string(b))
+					dir := filepath.Dir(event.Name)
+					for _, t := range targets {
+						if t.dependsOn(dir) {
+							log.Printf("Changed %v, rebuilding %v ...", event.Name, t.Name)
+							rebuild(t, hub)
+						}
 					}
 					lastBuild = time.Now()
 				}
@@ -77,8 +215,10 @@ func watchForChanges() {
 
 	log.Printf("Watching for changes at '%v'", wd)
 	filepath.Walk(wd, func(path string, info fs.FileInfo, err error) error {
-		if info.IsDir() && !strings.Contains(path, "/.git") && !strings.Contains(path, "/build") {
-			log.Printf("> Added %v", path)
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && !strings.Contains(path, "/.git") && !strings.Contains(path, "/build") && !strings.Contains(path, "/vendor") {
 			return watcher.Add(path)
 		}
 		return nil
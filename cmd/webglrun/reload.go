@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// reloadPath is the WebSocket endpoint used by the injected reload script.
+const reloadPath = "/_webglrun/reload"
+
+// websocketGUID is the magic value of the WebSocket handshake, RFC 6455.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// reloadMessage is sent to the pages after each build.
+type reloadMessage struct {
+	// Type is "reload" after a successful build, or "error" with the compiler
+	// output in Error.
+	Type   string `json:"type"`
+	Target string `json:"target"`
+	Error  string `json:"error,omitempty"`
+	// Replay is set on the errors sent when the page connects.
+	Replay bool `json:"replay,omitempty"`
+}
+
+// reloadHub keeps the WebSocket connections of the open pages.
+//
+// Only the small subset of the protocol needed to push text messages to the
+// browser is implemented: frames sent by the client are discarded.
+type reloadHub struct {
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+	// errors are the last build failures, by target name.
+	errors map[string]*reloadMessage
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{
+		conns:  make(map[net.Conn]bool),
+		errors: make(map[string]*reloadMessage),
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket connection.
+func (h *reloadHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "websocket required", http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		log.Printf("Error upgrading to websocket: %v", err)
+		return
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	h.mu.Lock()
+	h.conns[conn] = true
+	// A page opened after a failed build shows the error right away.
+	for _, msg := range h.errors {
+		replay := *msg
+		replay.Replay = true
+		h.send(conn, &replay)
+	}
+	h.mu.Unlock()
+
+	go h.discard(conn, rw.Reader)
+}
+
+// discard reads the client frames until the connection is closed.
+func (h *reloadHub) discard(conn net.Conn, r *bufio.Reader) {
+	io.Copy(io.Discard, r)
+	h.mu.Lock()
+	delete(h.conns, conn)
+	h.mu.Unlock()
+	conn.Close()
+}
+
+// Broadcast sends msg to all pages. Pages ignore the messages of other
+// targets.
+func (h *reloadHub) Broadcast(msg reloadMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if msg.Type == "error" {
+		h.errors[msg.Target] = &msg
+	} else {
+		delete(h.errors, msg.Target)
+	}
+	for c := range h.conns {
+		h.send(c, &msg)
+	}
+}
+
+// send writes msg as a single text frame. Must be called with h.mu held.
+func (h *reloadHub) send(c net.Conn, msg *reloadMessage) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error encoding reload message: %v", err)
+		return
+	}
+	// FIN bit set and text opcode, followed by the unmasked payload length.
+	frame := []byte{0x81}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	if _, err := c.Write(append(frame, payload...)); err != nil {
+		delete(h.conns, c)
+		c.Close()
+	}
+}
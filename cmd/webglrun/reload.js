@@ -0,0 +1,47 @@
+// Live reload client injected by webglrun. The page reloads after a successful
+// build, and compile errors are displayed over it. Pages of a program set the
+// data-target attribute of the script to the program name; without it, as in
+// the gallery, the page reloads after any build.
+(function() {
+	const target = document.currentScript.dataset.target;
+	function overlay(text) {
+		let el = document.getElementById("webglrun-error");
+		if (!el) {
+			el = document.createElement("pre");
+			el.id = "webglrun-error";
+			el.style.cssText = "position:fixed;inset:0;margin:0;padding:2em;z-index:1000;" +
+				"overflow:auto;background:rgba(20,0,0,0.92);color:#f88;font:14px monospace;" +
+				"white-space:pre-wrap";
+			document.body.appendChild(el);
+		}
+		el.textContent = "Build failed\n\n" + text;
+	}
+	function connect() {
+		const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") +
+			location.host + "/_webglrun/reload");
+		ws.onmessage = (e) => {
+			const msg = JSON.parse(e.data);
+			if (!target) {
+				// The gallery already lists the errors known when loaded.
+				if (!msg.replay) {
+					location.reload();
+				}
+				return;
+			}
+			if (msg.target !== target) {
+				return;
+			}
+			if (msg.type === "error") {
+				overlay(msg.error);
+			} else if (msg.type === "reload") {
+				location.reload();
+			}
+		};
+		// Reconnect when the server restarts, reloading to pick up the new
+		// build.
+		ws.onclose = () => setTimeout(() => {
+			fetch(location.href, {method: "HEAD"}).then(() => location.reload(), connect);
+		}, 1000);
+	}
+	connect();
+})();
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// compressed holds the encoded versions of a wasm binary, so they are only
+// computed once per build.
+type compressed struct {
+	gzip   []byte
+	brotli []byte
+}
+
+// compress encodes the file at path with gzip and, when the brotli command is
+// installed, with brotli. The standard library has no brotli encoder.
+func compress(path string) (c compressed, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if _, err := zw.Write(raw); err != nil {
+		return c, err
+	}
+	if err := zw.Close(); err != nil {
+		return c, err
+	}
+	c.gzip = buf.Bytes()
+
+	if _, err := exec.LookPath("brotli"); err == nil {
+		if b, err := exec.Command("brotli", "-c", path).Output(); err == nil {
+			c.brotli = b
+		}
+	}
+	return c, nil
+}
+
+// acceptsEncoding reports whether the request accepts the content encoding.
+func acceptsEncoding(r *http.Request, enc string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, q, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(name) == enc && strings.TrimSpace(q) != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// serveWasm serves the target binary with the application/wasm content type,
+// required by WebAssembly.instantiateStreaming, using the best encoding
+// accepted by the browser.
+func serveWasm(w http.ResponseWriter, r *http.Request, t *target) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	h := w.Header()
+	h.Set("Content-Type", "application/wasm")
+	h.Set("Vary", "Accept-Encoding")
+	var body []byte
+	switch {
+	case t.compressed.brotli != nil && acceptsEncoding(r, "br"):
+		h.Set("Content-Encoding", "br")
+		body = t.compressed.brotli
+	case t.compressed.gzip != nil && acceptsEncoding(r, "gzip"):
+		h.Set("Content-Encoding", "gzip")
+		body = t.compressed.gzip
+	default:
+		http.ServeFile(w, r, t.out)
+		return
+	}
+	http.ServeContent(w, r, t.Wasm, t.built, bytes.NewReader(body))
+}
+
+// withHeaders adds the headers common to all responses. Caching is disabled,
+// so the browser always loads the latest build. When isolate is set, the
+// Cross-Origin-Opener-Policy and Cross-Origin-Embedder-Policy headers make the
+// page cross-origin isolated, enabling SharedArrayBuffer.
+func withHeaders(next http.Handler, isolate bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Cache-Control", "no-cache")
+		if isolate {
+			h.Set("Cross-Origin-Opener-Policy", "same-origin")
+			h.Set("Cross-Origin-Embedder-Policy", "require-corp")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
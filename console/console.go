@@ -0,0 +1,211 @@
+// package console implements a registry of text commands, shared by the
+// in-game console, the server console and the remote administration protocol.
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ronoaldo/openvoxel/i18n"
+)
+
+// ErrUnknownCommand is returned when executing a command not registered.
+var ErrUnknownCommand = errors.New("console: unknown command")
+
+// Handler executes a command with the provided arguments and returns its
+// output.
+type Handler func(args []string) (string, error)
+
+// Command is an entry in the registry.
+type Command struct {
+	Name  string
+	Usage string
+	// Help describes the command. It is translated with i18n.T when listed.
+	Help    string
+	Handler Handler
+	// Complete returns the candidates for the last argument, which may be
+	// empty, given the arguments typed so far. It is optional.
+	Complete func(args []string) []string
+}
+
+// Registry holds the available commands. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	commands map[string]Command
+}
+
+// NewRegistry creates a registry with the builtin "help" command.
+func NewRegistry() *Registry {
+	r := &Registry{commands: map[string]Command{}}
+	r.Register(Command{
+		Name:    "help",
+		Help:    "lists the available commands",
+		Handler: r.help,
+	})
+	return r
+}
+
+// Register adds the command to the registry, replacing any command with the
+// same name.
+func (r *Registry) Register(c Command) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[c.Name] = c
+}
+
+// Commands returns all registered commands, sorted by name.
+func (r *Registry) Commands() []Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cmds := make([]Command, 0, len(r.commands))
+	for _, c := range r.commands {
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// Execute parses the command line and runs the matching command.
+func (r *Registry) Execute(line string) (string, error) {
+	args := Split(line)
+	if len(args) == 0 {
+		return "", nil
+	}
+	r.mu.RLock()
+	c, ok := r.commands[args[0]]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %v", ErrUnknownCommand, args[0])
+	}
+	return c.Handler(args[1:])
+}
+
+// Complete returns the command lines that complete the partial line, such as
+// when pressing tab in the console: the command names starting with the first
+// word, or the candidates of the command for its last argument. The lines are
+// sorted and keep the text typed before the completed word.
+func (r *Registry) Complete(line string) []string {
+	args := Split(line)
+	if len(args) == 0 || strings.HasSuffix(line, " ") || strings.HasSuffix(line, "\t") {
+		args = append(args, "")
+	}
+	word := args[len(args)-1]
+	var candidates []string
+	if len(args) == 1 {
+		for _, c := range r.Commands() {
+			candidates = append(candidates, c.Name)
+		}
+	} else {
+		r.mu.RLock()
+		c, ok := r.commands[args[0]]
+		r.mu.RUnlock()
+		if !ok || c.Complete == nil {
+			return nil
+		}
+		candidates = c.Complete(args[1:])
+	}
+	typed := strings.TrimSuffix(line, word)
+	typed = strings.TrimSuffix(typed, `"`)
+	var lines []string
+	for _, s := range candidates {
+		if !strings.HasPrefix(s, word) {
+			continue
+		}
+		if s == "" || strings.ContainsAny(s, " \t") {
+			s = `"` + s + `"`
+		}
+		lines = append(lines, typed+s)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func (r *Registry) help(args []string) (string, error) {
+	var b strings.Builder
+	for _, c := range r.Commands() {
+		fmt.Fprintf(&b, "%s %s\t%s\n", c.Name, c.Usage, i18n.T(c.Help))
+	}
+	return b.String(), nil
+}
+
+// RegisterClipboardCommands adds the copy command, which runs a command and
+// copies its output with set, such as the seed or the player coordinates. The
+// clipboard is provided by the caller, as the registry is also used by the
+// headless server.
+func RegisterClipboardCommands(r *Registry, set func(text string)) {
+	r.Register(Command{
+		Name:  "copy",
+		Usage: "<command> [args]",
+		Help:  "copies the output of the command to the clipboard",
+		Handler: func(args []string) (string, error) {
+			if len(args) == 0 {
+				return "", fmt.Errorf("usage: copy <command> [args]")
+			}
+			r.mu.RLock()
+			c, ok := r.commands[args[0]]
+			r.mu.RUnlock()
+			if !ok {
+				return "", fmt.Errorf("%w: %v", ErrUnknownCommand, args[0])
+			}
+			out, err := c.Handler(args[1:])
+			if err != nil {
+				return "", err
+			}
+			set(strings.TrimSuffix(out, "\n"))
+			return "copied to the clipboard", nil
+		},
+	})
+}
+
+// Split breaks the command line into arguments separated by spaces. Double
+// quotes can be used to group arguments with spaces.
+func Split(line string) []string {
+	var (
+		args   []string
+		cur    strings.Builder
+		quoted bool
+		inArg  bool
+	)
+	for _, ch := range line {
+		switch {
+		case ch == '"':
+			quoted = !quoted
+			inArg = true
+		case (ch == ' ' || ch == '\t') && !quoted:
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
+
+// Run reads commands from in, one per line, and writes their output to out
+// until in is exhausted. It is used for the interactive server console.
+func Run(r *Registry, in io.Reader, out io.Writer) error {
+	s := bufio.NewScanner(in)
+	fmt.Fprint(out, "> ")
+	for s.Scan() {
+		res, err := r.Execute(s.Text())
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+		} else if res != "" {
+			fmt.Fprint(out, strings.TrimSuffix(res, "\n")+"\n")
+		}
+		fmt.Fprint(out, "> ")
+	}
+	return s.Err()
+}
@@ -0,0 +1,96 @@
+package cvar
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ronoaldo/openvoxel/console"
+)
+
+// RegisterCommands adds the set, reset and cvars commands to the registry,
+// changing and listing the variables of r, with the variable names
+// completed.
+func RegisterCommands(reg *console.Registry, r *Registry) {
+	names := func(args []string) []string {
+		if len(args) == 1 {
+			return r.Complete(args[0])
+		}
+		return nil
+	}
+	reg.Register(console.Command{
+		Name:  "set",
+		Usage: "<name> [value]",
+		Help:  "changes a console variable, or shows its value",
+		Handler: func(args []string) (string, error) {
+			if len(args) == 0 || len(args) > 2 {
+				return "", fmt.Errorf("usage: set <name> [value]")
+			}
+			v, ok := r.Lookup(args[0])
+			if !ok {
+				return "", fmt.Errorf("%w: %v", ErrUnknownVar, args[0])
+			}
+			if len(args) == 2 {
+				if err := v.Parse(args[1]); err != nil {
+					return "", err
+				}
+			}
+			return describe(v), nil
+		},
+		Complete: func(args []string) []string {
+			if len(args) == 2 {
+				if v, ok := r.Lookup(args[0]); ok {
+					if _, ok := v.(*BoolVar); ok {
+						return []string{"false", "true"}
+					}
+					return []string{v.String(), v.Default()}
+				}
+			}
+			return names(args)
+		},
+	})
+	reg.Register(console.Command{
+		Name:  "reset",
+		Usage: "<name>",
+		Help:  "changes a console variable back to its default value",
+		Handler: func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("usage: reset <name>")
+			}
+			v, ok := r.Lookup(args[0])
+			if !ok {
+				return "", fmt.Errorf("%w: %v", ErrUnknownVar, args[0])
+			}
+			v.Reset()
+			return describe(v), nil
+		},
+		Complete: names,
+	})
+	reg.Register(console.Command{
+		Name:  "cvars",
+		Usage: "[prefix]",
+		Help:  "lists the console variables starting with the prefix",
+		Handler: func(args []string) (string, error) {
+			prefix := ""
+			if len(args) > 0 {
+				prefix = args[0]
+			}
+			var b strings.Builder
+			for _, name := range r.Complete(prefix) {
+				v, _ := r.Lookup(name)
+				b.WriteString(describe(v) + "\n")
+			}
+			return b.String(), nil
+		},
+		Complete: names,
+	})
+}
+
+// describe returns the name and value of the variable, with the default if
+// it was changed.
+func describe(v Var) string {
+	s := fmt.Sprintf("%s = %s", v.Name(), quote(v.String()))
+	if v.String() != v.Default() {
+		s += fmt.Sprintf(" (default %s)", quote(v.Default()))
+	}
+	return s
+}
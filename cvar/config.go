@@ -0,0 +1,116 @@
+package cvar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ronoaldo/openvoxel/console"
+)
+
+// Save writes the variables changed from their defaults, one per line as the
+// name and the value, quoted if needed. The values loaded for variables not
+// registered are kept, so settings of subsystems not started in this run are
+// not lost.
+func (r *Registry) Save(w io.Writer) error {
+	values := map[string]string{}
+	r.mu.RLock()
+	for name, s := range r.pending {
+		values[name] = s
+	}
+	r.mu.RUnlock()
+	for _, v := range r.Vars() {
+		if s := v.String(); s != v.Default() {
+			values[v.Name()] = s
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b := bufio.NewWriter(w)
+	for _, name := range names {
+		fmt.Fprintf(b, "%s %s\n", name, quote(values[name]))
+	}
+	return b.Flush()
+}
+
+// Load reads the variables written by Save. Lines starting with # are
+// comments. Variables not registered yet get the value when they are
+// registered. Lines that fail to parse are reported in the returned error,
+// after the other lines are applied.
+func (r *Registry) Load(rd io.Reader) error {
+	var errs []error
+	s := bufio.NewScanner(rd)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args := console.Split(line)
+		if len(args) != 2 {
+			errs = append(errs, fmt.Errorf("cvar: line %d: expected a name and a value", n))
+			continue
+		}
+		v, ok := r.Lookup(args[0])
+		if !ok {
+			r.mu.Lock()
+			r.pending[args[0]] = args[1]
+			r.mu.Unlock()
+			continue
+		}
+		if err := v.Parse(args[1]); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", n, err))
+		}
+	}
+	if err := s.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// SaveFile saves the variables to the file, creating its directory.
+func (r *Registry) SaveFile(name string) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := r.Save(fd); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
+
+// LoadFile loads the variables from the file. A missing file is not an error,
+// as it is only written once a setting changes.
+func (r *Registry) LoadFile(name string) error {
+	fd, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return r.Load(fd)
+}
+
+// quote surrounds the value with double quotes if console.Split would not
+// read it back as a single argument.
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t") {
+		return `"` + s + `"`
+	}
+	return s
+}
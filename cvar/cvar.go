@@ -0,0 +1,362 @@
+// package cvar implements console variables: named settings, such as
+// "r_renderdistance", shared by the code, the console and the settings
+// screens, and persisted to the config file.
+//
+// Subsystems register their variables with the default values, read them with
+// the typed accessors and react live to the changes with OnChange, no matter
+// if the value came from the config file, a console command or a slider:
+//
+//	distance := cvar.Float("r_renderdistance", 12).Range(2, 32)
+//	distance.OnChange(func(v float64) { chunks.SetRadius(int(v)) })
+package cvar
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// ErrUnknownVar is returned when changing a variable not registered.
+var ErrUnknownVar = errors.New("cvar: unknown variable")
+
+// ErrInvalidValue is returned when the text does not parse as a value of the
+// variable type.
+var ErrInvalidValue = errors.New("cvar: invalid value")
+
+// Var is a variable seen as text, as used by the console and the config file.
+// The typed variables, such as *FloatVar, implement it.
+type Var interface {
+	// Name returns the name of the variable.
+	Name() string
+	// String returns the current value as text.
+	String() string
+	// Default returns the default value as text.
+	Default() string
+	// Parse changes the value to the text, calling the OnChange callbacks
+	// when it changes.
+	Parse(s string) error
+	// Reset changes the value back to the default.
+	Reset()
+}
+
+// value is the state shared by the typed variables.
+type value[T comparable] struct {
+	name   string
+	def    T
+	parse  func(s string) (T, error)
+	format func(v T) string
+	clamp  func(v T) T
+
+	mu        sync.Mutex
+	v         T
+	callbacks map[int]func(T)
+	next      int
+}
+
+func newValue[T comparable](name string, def T, parse func(string) (T, error), format func(T) string) value[T] {
+	return value[T]{name: name, def: def, v: def, parse: parse, format: format, callbacks: map[int]func(T){}}
+}
+
+// Name returns the name of the variable.
+func (v *value[T]) Name() string {
+	return v.name
+}
+
+// Get returns the current value.
+func (v *value[T]) Get() T {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+// Set changes the value and, if it changed, calls the OnChange callbacks with
+// the new value, in the goroutine of the caller.
+func (v *value[T]) Set(x T) {
+	v.mu.Lock()
+	if v.clamp != nil {
+		x = v.clamp(x)
+	}
+	if x == v.v {
+		v.mu.Unlock()
+		return
+	}
+	v.v = x
+	callbacks := make([]func(T), 0, len(v.callbacks))
+	for id := 0; id < v.next; id++ {
+		if fn, ok := v.callbacks[id]; ok {
+			callbacks = append(callbacks, fn)
+		}
+	}
+	v.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(x)
+	}
+}
+
+// OnChange calls fn with the new value every time the value changes, in the
+// order the callbacks were added. It returns a function that removes the
+// callback.
+func (v *value[T]) OnChange(fn func(T)) (remove func()) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	id := v.next
+	v.next++
+	v.callbacks[id] = fn
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		delete(v.callbacks, id)
+	}
+}
+
+// String returns the current value as text.
+func (v *value[T]) String() string {
+	return v.format(v.Get())
+}
+
+// Default returns the default value as text.
+func (v *value[T]) Default() string {
+	return v.format(v.def)
+}
+
+// Parse changes the value to the text.
+func (v *value[T]) Parse(s string) error {
+	x, err := v.parse(s)
+	if err != nil {
+		return fmt.Errorf("%w for %v: %q", ErrInvalidValue, v.name, s)
+	}
+	v.Set(x)
+	return nil
+}
+
+// Reset changes the value back to the default.
+func (v *value[T]) Reset() {
+	v.Set(v.def)
+}
+
+// FloatVar is a floating point variable.
+type FloatVar struct {
+	value[float64]
+	min, max float64
+	bounded  bool
+}
+
+func newFloat(name string, def float64) *FloatVar {
+	return &FloatVar{value: newValue(name, def, func(s string) (float64, error) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			err = ErrInvalidValue
+		}
+		return f, err
+	}, func(f float64) string {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	})}
+}
+
+// Range limits the value to [min, max], clamping the values set afterwards
+// and the current one, and returns the variable.
+func (v *FloatVar) Range(min, max float64) *FloatVar {
+	v.mu.Lock()
+	v.min, v.max, v.bounded = min, max, true
+	v.clamp = func(f float64) float64 { return math.Max(min, math.Min(max, f)) }
+	v.mu.Unlock()
+	v.Set(v.Get())
+	return v
+}
+
+// Bounds returns the range set with Range, such as for the limits of a
+// slider, and false if there is none.
+func (v *FloatVar) Bounds() (min, max float64, ok bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.min, v.max, v.bounded
+}
+
+// IntVar is an integer variable.
+type IntVar struct {
+	value[int]
+	min, max int
+	bounded  bool
+}
+
+func newInt(name string, def int) *IntVar {
+	return &IntVar{value: newValue(name, def, strconv.Atoi, strconv.Itoa)}
+}
+
+// Range limits the value to [min, max], clamping the values set afterwards
+// and the current one, and returns the variable.
+func (v *IntVar) Range(min, max int) *IntVar {
+	v.mu.Lock()
+	v.min, v.max, v.bounded = min, max, true
+	v.clamp = func(i int) int {
+		if i < min {
+			return min
+		}
+		if i > max {
+			return max
+		}
+		return i
+	}
+	v.mu.Unlock()
+	v.Set(v.Get())
+	return v
+}
+
+// Bounds returns the range set with Range and false if there is none.
+func (v *IntVar) Bounds() (min, max int, ok bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.min, v.max, v.bounded
+}
+
+// BoolVar is a boolean variable. It parses the values accepted by
+// strconv.ParseBool.
+type BoolVar struct {
+	value[bool]
+}
+
+func newBool(name string, def bool) *BoolVar {
+	return &BoolVar{value: newValue(name, def, strconv.ParseBool, strconv.FormatBool)}
+}
+
+// StringVar is a text variable.
+type StringVar struct {
+	value[string]
+}
+
+func newString(name string, def string) *StringVar {
+	return &StringVar{value: newValue(name, def, func(s string) (string, error) { return s, nil }, func(s string) string { return s })}
+}
+
+// Registry holds the variables by name. It is safe for concurrent use.
+type Registry struct {
+	mu   sync.RWMutex
+	vars map[string]Var
+	// pending holds the values loaded for variables not registered yet, so
+	// the config file can be loaded before the subsystems start.
+	pending map[string]string
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{vars: map[string]Var{}, pending: map[string]string{}}
+}
+
+// Default is the registry used by the package functions, such as Float.
+var Default = NewRegistry()
+
+// register adds the variable, applying the value loaded for it, if any. It
+// panics if the name is already registered, as two subsystems would fight
+// over the same setting.
+func (r *Registry) register(v Var) {
+	r.mu.Lock()
+	if _, ok := r.vars[v.Name()]; ok {
+		r.mu.Unlock()
+		panic(fmt.Sprintf("cvar: %q registered twice", v.Name()))
+	}
+	r.vars[v.Name()] = v
+	s, ok := r.pending[v.Name()]
+	delete(r.pending, v.Name())
+	r.mu.Unlock()
+	if ok {
+		if err := v.Parse(s); err != nil {
+			log.Warnf("Ignoring saved value: %v", err)
+		}
+	}
+}
+
+// Float registers a floating point variable with the default value.
+func (r *Registry) Float(name string, def float64) *FloatVar {
+	v := newFloat(name, def)
+	r.register(v)
+	return v
+}
+
+// Int registers an integer variable with the default value.
+func (r *Registry) Int(name string, def int) *IntVar {
+	v := newInt(name, def)
+	r.register(v)
+	return v
+}
+
+// Bool registers a boolean variable with the default value.
+func (r *Registry) Bool(name string, def bool) *BoolVar {
+	v := newBool(name, def)
+	r.register(v)
+	return v
+}
+
+// String registers a text variable with the default value.
+func (r *Registry) String(name string, def string) *StringVar {
+	v := newString(name, def)
+	r.register(v)
+	return v
+}
+
+// Float registers a floating point variable in the Default registry.
+func Float(name string, def float64) *FloatVar {
+	return Default.Float(name, def)
+}
+
+// Int registers an integer variable in the Default registry.
+func Int(name string, def int) *IntVar {
+	return Default.Int(name, def)
+}
+
+// Bool registers a boolean variable in the Default registry.
+func Bool(name string, def bool) *BoolVar {
+	return Default.Bool(name, def)
+}
+
+// String registers a text variable in the Default registry.
+func String(name string, def string) *StringVar {
+	return Default.String(name, def)
+}
+
+// Lookup returns the variable with the name.
+func (r *Registry) Lookup(name string) (Var, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.vars[name]
+	return v, ok
+}
+
+// Vars returns the registered variables, sorted by name, such as to build a
+// settings screen.
+func (r *Registry) Vars() []Var {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	vars := make([]Var, 0, len(r.vars))
+	for _, v := range r.vars {
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name() < vars[j].Name() })
+	return vars
+}
+
+// Set changes the variable with the name to the text.
+func (r *Registry) Set(name, value string) error {
+	v, ok := r.Lookup(name)
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnknownVar, name)
+	}
+	return v.Parse(value)
+}
+
+// Complete returns the names of the variables starting with the prefix,
+// sorted.
+func (r *Registry) Complete(prefix string) []string {
+	var names []string
+	for _, v := range r.Vars() {
+		if strings.HasPrefix(v.Name(), prefix) {
+			names = append(names, v.Name())
+		}
+	}
+	return names
+}
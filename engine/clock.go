@@ -0,0 +1,125 @@
+package engine
+
+import "github.com/ronoaldo/openvoxel/render"
+
+// Clock keeps track of the frame timing of the main loop.
+//
+// Each call to Tick measures the time since the previous call, clamps it to
+// MaxDelta, and applies the Scale multiplier. A smoothed delta is also kept,
+// which is more suitable for displaying to the user than the raw value.
+//
+// The zero Clock is usable: it measures render.Time, with a Scale of 1 and no
+// MaxDelta or Smoothing. NewClock sets the defaults.
+type Clock struct {
+	// MaxDelta is the maximum delta time, in seconds, reported by Tick. Zero
+	// means no limit.
+	MaxDelta float64
+
+	// Scale is a multiplier applied to the delta time. Use values below 1 for
+	// slow motion. Zero means 1.
+	Scale float64
+
+	// Paused makes Tick report a zero delta time, while still measuring the
+	// frame time.
+	Paused bool
+
+	// Smoothing is the weight of the previous value in the exponential moving
+	// average of the delta time, in the range [0, 1).
+	Smoothing float64
+
+	now      func() float64
+	last     float64
+	delta    float64
+	smoothed float64
+	elapsed  float64
+	frames   uint64
+}
+
+// NewClock creates a clock using render.Time as the time source.
+func NewClock() *Clock {
+	c := &Clock{
+		MaxDelta:  0.25,
+		Scale:     1,
+		Smoothing: 0.9,
+		now:       render.Time,
+	}
+	c.Reset()
+	return c
+}
+
+// Tick advances the clock and returns the scaled delta time, in seconds, since
+// the previous call to Tick.
+func (c *Clock) Tick() float64 {
+	now := c.time()
+	raw := now - c.last
+	c.last = now
+	if raw < 0 {
+		raw = 0
+	}
+	if c.MaxDelta > 0 && raw > c.MaxDelta {
+		raw = c.MaxDelta
+	}
+
+	if c.frames == 0 {
+		c.smoothed = raw
+	} else {
+		c.smoothed = c.Smoothing*c.smoothed + (1-c.Smoothing)*raw
+	}
+	c.frames++
+
+	scale := c.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	c.delta = 0
+	if !c.Paused {
+		c.delta = raw * scale
+	}
+	c.elapsed += c.delta
+	return c.delta
+}
+
+// Reset discards the time since the last Tick, so the next call does not
+// report a large delta. It is useful after the main loop was paused.
+func (c *Clock) Reset() {
+	c.last = c.time()
+}
+
+// time returns the current time from the time source, render.Time if none was
+// set.
+func (c *Clock) time() float64 {
+	if c.now == nil {
+		c.now = render.Time
+	}
+	return c.now()
+}
+
+// Delta returns the scaled delta time computed by the last Tick.
+func (c *Clock) Delta() float64 {
+	return c.delta
+}
+
+// SmoothedDelta returns the exponential moving average of the unscaled delta
+// time.
+func (c *Clock) SmoothedDelta() float64 {
+	return c.smoothed
+}
+
+// FPS returns the frame rate computed from the smoothed delta time.
+func (c *Clock) FPS() float64 {
+	if c.smoothed == 0 {
+		return 0
+	}
+	return 1 / c.smoothed
+}
+
+// Elapsed returns the total scaled time, in seconds, since the clock was
+// created.
+func (c *Clock) Elapsed() float64 {
+	return c.elapsed
+}
+
+// Frames returns the number of times Tick was called.
+func (c *Clock) Frames() uint64 {
+	return c.frames
+}
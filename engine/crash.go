@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// ErrCrashed is returned by RunWithConfig when the game panics. The crash
+// report was logged, and saved to Config.CrashDir if set.
+var ErrCrashed = errors.New("engine: the game crashed")
+
+// CrashReport describes a panic in the main loop.
+type CrashReport struct {
+	Time  time.Time
+	Panic string
+	Stack string
+
+	// Renderer is the graphics API version and the GPU vendor and model.
+	Renderer string
+	System   string
+
+	// Log are the last log lines before the crash.
+	Log []string
+}
+
+func newCrashReport(p any, stack []byte, renderer string) *CrashReport {
+	r := &CrashReport{
+		Time:     time.Now(),
+		Panic:    fmt.Sprint(p),
+		Stack:    string(stack),
+		Renderer: renderer,
+		System: fmt.Sprintf("%v/%v, %v CPUs, %v", runtime.GOOS, runtime.GOARCH,
+			runtime.NumCPU(), runtime.Version()),
+		Log: log.Recent(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		r.System += ", " + info.Main.Path + " " + info.Main.Version
+	}
+	return r
+}
+
+// String formats the report as plain text.
+func (r *CrashReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "openvoxel crash report\n\n")
+	fmt.Fprintf(&b, "Time: %v\n", r.Time.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Panic: %v\n", r.Panic)
+	fmt.Fprintf(&b, "System: %v\n", r.System)
+	fmt.Fprintf(&b, "Renderer: %v\n\n", r.Renderer)
+	fmt.Fprintf(&b, "Stack trace:\n%v\n", r.Stack)
+	fmt.Fprintf(&b, "Last %d log lines:\n", len(r.Log))
+	for _, l := range r.Log {
+		fmt.Fprintln(&b, l)
+	}
+	return b.String()
+}
+
+// Save writes the report to a new file in dir, returning its path.
+func (r *CrashReport) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, "crash-"+r.Time.Format("20060102-150405")+".txt")
+	return name, os.WriteFile(name, []byte(r.String()), 0644)
+}
+
+// defaultCrashDir returns the crash reports directory inside the user cache
+// directory, or an empty string if there is none.
+func defaultCrashDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "openvoxel", "crashes")
+}
+
+// recoverCrash handles a panic in the main loop, reporting it according to
+// the configuration, and sets err to ErrCrashed. It must be deferred.
+func recoverCrash(cfg *Config, renderer *string, err *error) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	r := newCrashReport(p, debug.Stack(), *renderer)
+	log.Errorf("%v", r)
+
+	path := ""
+	if cfg.CrashDir != "" {
+		var serr error
+		if path, serr = r.Save(cfg.CrashDir); serr != nil {
+			log.Errorf("Error saving crash report: %v", serr)
+			path = ""
+		} else {
+			log.Errorf("Crash report saved to %v", path)
+		}
+	}
+	if cfg.CrashDialog {
+		showCrash(r, path)
+	}
+	*err = ErrCrashed
+}
@@ -0,0 +1,21 @@
+package engine
+
+import "syscall/js"
+
+// showCrash displays the report over the page, so users can copy it into a
+// bug report.
+func showCrash(r *CrashReport, path string) {
+	document := js.Global().Get("document")
+	el := document.Call("createElement", "div")
+	el.Get("style").Set("cssText", "position:fixed;inset:0;z-index:1000;overflow:auto;"+
+		"padding:2em;background:#200;color:#eee;font-family:sans-serif")
+	title := document.Call("createElement", "h2")
+	title.Set("textContent", "The game crashed")
+	hint := document.Call("createElement", "p")
+	hint.Set("textContent", "Please include the report below when reporting the problem.")
+	report := document.Call("createElement", "pre")
+	report.Get("style").Set("cssText", "white-space:pre-wrap;user-select:all;color:#f99")
+	report.Set("textContent", r.String())
+	el.Call("append", title, hint, report)
+	document.Get("body").Call("appendChild", el)
+}
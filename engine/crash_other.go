@@ -0,0 +1,7 @@
+//go:build !js
+
+package engine
+
+// showCrash is a no-op on native builds: there is no portable way to display a
+// dialog without the window, so the report is only logged and saved.
+func showCrash(r *CrashReport, path string) {}
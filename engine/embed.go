@@ -0,0 +1,56 @@
+package engine
+
+import "github.com/ronoaldo/openvoxel/render"
+
+// Embedded runs a game inside another UI toolkit, in a window created with
+// render.AttachExternalContext. The host owns the event loop: it calls Frame
+// when the widget must be redrawn, and swaps the buffers itself.
+type Embedded struct {
+	game   Game
+	window *render.Window
+	clock  *Clock
+
+	dt          float64
+	accumulator float64
+}
+
+// NewEmbedded applies the configuration, then initializes and loads the game
+// in the window. The Width, Height, Title, PauseWhenHidden and crash settings
+// of cfg are not used, as the host manages the widget and its errors.
+func NewEmbedded(g Game, w *render.Window, cfg Config) (*Embedded, error) {
+	if cfg.TickRate <= 0 {
+		cfg.TickRate = DefaultConfig.TickRate
+	}
+	e := &Embedded{game: g, window: w, clock: cfg.Clock, dt: 1.0 / float64(cfg.TickRate)}
+	if e.clock == nil {
+		e.clock = NewClock()
+	}
+	if err := start(g, w, &cfg); err != nil {
+		return nil, err
+	}
+	e.clock.Reset()
+	return e, nil
+}
+
+// Frame advances the simulation by the time elapsed since the last frame, and
+// renders the game into the framebuffer of the window.
+func (e *Embedded) Frame() {
+	e.window.PollEvents()
+	e.accumulator += e.clock.Tick()
+	for e.accumulator >= e.dt {
+		e.game.Update(e.dt)
+		e.accumulator -= e.dt
+	}
+	// The host toolkit changes the GL state between frames.
+	render.InvalidatePipeline()
+	e.window.BindDefaultFramebuffer()
+	e.window.Scene().Clear()
+	e.game.Render(e.accumulator / e.dt)
+}
+
+// Close shuts the game down and releases the window resources. The context of
+// the host must be current.
+func (e *Embedded) Close() {
+	e.game.Shutdown()
+	e.window.Close()
+}
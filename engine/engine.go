@@ -0,0 +1,275 @@
+// package engine provides the main loop and lifecycle management for games
+// built with openvoxel.
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/ronoaldo/openvoxel/audio"
+	"github.com/ronoaldo/openvoxel/cvar"
+	"github.com/ronoaldo/openvoxel/i18n"
+	"github.com/ronoaldo/openvoxel/log"
+	"github.com/ronoaldo/openvoxel/render"
+)
+
+func init() {
+	// OpenGL contexts are bound to the thread that created them, so we make
+	// sure the main goroutine stays on the main OS thread.
+	runtime.LockOSThread()
+}
+
+// Game is the interface that must be implemented by programs that want to be
+// executed by the engine main loop.
+type Game interface {
+	// Init is called once, after the window and rendering backend are ready.
+	// Returning an error aborts the execution.
+	Init(w *render.Window) error
+
+	// Update advances the game simulation by dt seconds. It is called at a
+	// fixed rate, as configured by Config.TickRate.
+	Update(dt float64)
+
+	// Render draws the current frame. The alpha value is in the range [0, 1)
+	// and represents how far we are between the last and the next Update
+	// call, and can be used to interpolate the game state.
+	Render(alpha float64)
+
+	// Shutdown is called once when the main loop exits, before the window is
+	// closed.
+	Shutdown()
+}
+
+// Config holds the settings used to initialize the engine.
+type Config struct {
+	Width  int
+	Height int
+	Title  string
+
+	// TickRate is the number of Update calls per second.
+	TickRate int
+
+	// PauseWhenHidden stops calling Update and Render while the window is
+	// minimized or the browser tab is hidden.
+	PauseWhenHidden bool
+
+	// Clock is used to measure the frame time. If nil, a new clock is created
+	// by the engine. Games can provide their own clock to control the time
+	// scale.
+	Clock *Clock
+
+	// ShaderCacheDir is where linked shader programs are cached between runs.
+	// Empty disables the cache. It has no effect on WebGL.
+	ShaderCacheDir string
+
+	// CrashDir is where crash reports are written when the main loop panics.
+	// Empty disables writing them; they are always logged.
+	CrashDir string
+
+	// CrashDialog shows the crash report to the user. It is only supported
+	// on the web, where the report is displayed over the page.
+	CrashDialog bool
+
+	// Accessibility holds the initial accessibility settings. They can be
+	// changed later with render.SetAccessibility.
+	Accessibility render.Accessibility
+
+	// Graphics holds the initial graphics settings. They can be changed
+	// later with render.SetGraphics.
+	Graphics render.Graphics
+
+	// Volume holds the initial volume settings. They can be changed later
+	// with audio.SetBuses.
+	Volume audio.Buses
+
+	// CvarFile is where the console variables of cvar.Default are loaded
+	// from before Game.Init and saved to when the main loop exits. Empty
+	// disables persisting them.
+	CvarFile string
+
+	// Tracer, if set, marks each frame after it is presented, such as
+	// server.Trace to find the latency between the input and the frames.
+	Tracer Tracer
+
+	// Locale selects the language of the i18n.Default catalog. Empty uses
+	// the locale of the user, from i18n.DetectLocale.
+	Locale string
+}
+
+// Tracer records frame markers. It is implemented by server.Trace.
+type Tracer interface {
+	Frame()
+}
+
+// DefaultConfig is the configuration used by Run.
+var DefaultConfig = Config{
+	Width:    1280,
+	Height:   720,
+	Title:    "openvoxel",
+	TickRate: 60,
+
+	PauseWhenHidden: true,
+
+	ShaderCacheDir: defaultShaderCacheDir(),
+	CvarFile:       defaultCvarFile(),
+
+	CrashDir:    defaultCrashDir(),
+	CrashDialog: true,
+
+	Accessibility: render.DefaultAccessibility,
+	Graphics:      render.DefaultGraphics,
+	Volume:        audio.DefaultBuses,
+}
+
+// defaultShaderCacheDir returns the shader cache directory inside the user
+// cache directory, or an empty string if there is none.
+func defaultShaderCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "openvoxel", "shaders")
+}
+
+// defaultCvarFile returns the console variables file inside the user config
+// directory, or an empty string if there is none.
+func defaultCvarFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "openvoxel", "cvars.cfg")
+}
+
+// start applies the configuration, then initializes and loads the game in the
+// window.
+func start(g Game, window *render.Window, cfg *Config) error {
+	log.Infof("Rendering Backend: %v", render.Version())
+	caps := render.Capabilities()
+	log.Infof("Capabilities: max texture %d, %d array layers, anisotropy %v, compute %v, multi-draw %v, %d extensions",
+		caps.MaxTextureSize, caps.MaxArrayTextureLayers, caps.MaxAnisotropy, caps.Compute, caps.MultiDrawIndirect, len(caps.Extensions))
+	render.SetShaderCacheDir(cfg.ShaderCacheDir)
+	render.SetAccessibility(cfg.Accessibility)
+	render.SetGraphics(cfg.Graphics)
+	audio.SetBuses(cfg.Volume)
+	if cfg.Locale == "" {
+		cfg.Locale = i18n.DetectLocale()
+	}
+	i18n.SetLocale(cfg.Locale)
+	if cfg.CvarFile != "" {
+		if err := cvar.Default.LoadFile(cfg.CvarFile); err != nil {
+			log.Warnf("Error loading console variables: %v", err)
+		}
+	}
+
+	window.ShowLoading(0, i18n.T("Initializing"))
+	window.SwapBuffers()
+	if err := g.Init(window); err != nil {
+		return err
+	}
+	if l, ok := g.(Loader); ok {
+		if err := load(window, l.Load()); err != nil {
+			g.Shutdown()
+			return err
+		}
+	} else {
+		window.HideLoading()
+	}
+	return nil
+}
+
+// Run executes the provided game using the DefaultConfig.
+func Run(g Game) error {
+	return RunWithConfig(g, DefaultConfig)
+}
+
+// RunWithConfig creates the program window and executes the main loop for the
+// provided game until the window is closed.
+//
+// Panics in the main loop are recovered and reported as configured, and make
+// RunWithConfig return ErrCrashed. Panics in other goroutines are not handled.
+func RunWithConfig(g Game, cfg Config) (err error) {
+	renderer := "unknown"
+	defer recoverCrash(&cfg, &renderer, &err)
+
+	if cfg.TickRate <= 0 {
+		cfg.TickRate = DefaultConfig.TickRate
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = NewClock()
+	}
+
+	log.Infof("Initializing main window")
+	window, err := render.NewWindow(cfg.Width, cfg.Height, cfg.Title)
+	if err != nil {
+		return err
+	}
+	defer window.Close()
+	renderer = render.Version()
+	if err := start(g, window, &cfg); err != nil {
+		return err
+	}
+	if cfg.CvarFile != "" {
+		defer func() {
+			if err := cvar.Default.SaveFile(cfg.CvarFile); err != nil {
+				log.Warnf("Error saving console variables: %v", err)
+			}
+		}()
+	}
+	defer g.Shutdown()
+
+	visible, resumed := window.Visible(), false
+	window.SetVisibilityCallback(func(v bool) {
+		if v && !visible {
+			resumed = true
+		}
+		visible = v
+	})
+
+	dt := 1.0 / float64(cfg.TickRate)
+	accumulator := 0.0
+	clock.Reset()
+
+	lastLog := render.Time()
+	for !window.ShouldClose() {
+		if !visible && cfg.PauseWhenHidden {
+			window.WaitEvents()
+			continue
+		}
+
+		if resumed {
+			// Discard the time spent hidden, so we don't get a giant dt spike
+			// when the window is visible again.
+			log.Infof("Resuming main loop")
+			clock.Reset()
+			resumed = false
+		}
+
+		// The clock clamps the frame time, which avoids the "spiral of death"
+		// when the program takes too long to render a frame.
+		accumulator += clock.Tick()
+		for accumulator >= dt {
+			g.Update(dt)
+			accumulator -= dt
+		}
+
+		window.Scene().Clear()
+		g.Render(accumulator / dt)
+
+		window.SwapBuffers()
+		if cfg.Tracer != nil {
+			cfg.Tracer.Frame()
+		}
+		window.PollEvents()
+
+		if now := render.Time(); now-lastLog >= 1 {
+			st := render.Stats()
+			log.Infof("At %.0f sec, avg FPS %.02f, %d draw calls, %d triangles, %d/%d chunks visible",
+				now, clock.FPS(), st.DrawCalls, st.Triangles, st.VisibleChunks, st.VisibleChunks+st.CulledChunks)
+			lastLog = now
+		}
+	}
+	return nil
+}
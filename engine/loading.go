@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/ronoaldo/openvoxel/i18n"
+	"github.com/ronoaldo/openvoxel/log"
+	"github.com/ronoaldo/openvoxel/render"
+)
+
+// LoadStep is a unit of work of the loading phase, such as decoding a texture
+// atlas or compiling a shader.
+type LoadStep struct {
+	// Name is displayed on the loading screen while the step runs,
+	// translated with i18n.T.
+	Name string
+
+	// Weight is the share of the progress bar taken by the step. Zero is
+	// handled as one.
+	Weight float64
+
+	Run func() error
+}
+
+// Loader is implemented by games that have a loading phase. After Init, the
+// engine runs the steps returned by Load, rendering the loading screen
+// between them, before the first Update.
+//
+// On the web the steps run on the main thread, so each one should be short
+// enough to keep the page responsive; the browser repaints the progress bar
+// between steps.
+type Loader interface {
+	Load() []LoadStep
+}
+
+// load runs the loading steps. It returns early, without error, if the
+// window is closed while loading.
+func load(w *render.Window, steps []LoadStep) error {
+	total := 0.0
+	for i := range steps {
+		if steps[i].Weight <= 0 {
+			steps[i].Weight = 1
+		}
+		total += steps[i].Weight
+	}
+
+	done := 0.0
+	for _, s := range steps {
+		if w.ShouldClose() {
+			return nil
+		}
+		w.ShowLoading(done/total, i18n.T(s.Name))
+		w.SwapBuffers()
+		w.PollEvents()
+
+		start := render.Time()
+		if err := s.Run(); err != nil {
+			return fmt.Errorf("loading %v: %w", s.Name, err)
+		}
+		log.Debugf("Loaded %v in %.03fs", s.Name, render.Time()-start)
+		done += s.Weight
+	}
+	w.ShowLoading(1, i18n.T("Done"))
+	w.SwapBuffers()
+	w.HideLoading()
+	return nil
+}
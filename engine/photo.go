@@ -0,0 +1,393 @@
+package engine
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/log"
+	"github.com/ronoaldo/openvoxel/render"
+)
+
+// PhotoKeys are the key codes, as published in event.Key, of the photo mode
+// controls.
+type PhotoKeys struct {
+	Toggle, Capture                       int
+	Forward, Back, Left, Right, Up, Down  int
+	LookLeft, LookRight, LookUp, LookDown int
+	RollLeft, RollRight                   int
+	ZoomIn, ZoomOut                       int
+	// Slow divides all speeds by 4 while held, for fine adjustments.
+	Slow int
+}
+
+// DefaultPhotoKeys toggles the photo mode with F9 and captures with F12. The
+// camera moves with WASD, Space and C, looks around with the arrow keys,
+// rolls with Q and E, and zooms with + and -. Shift slows everything down.
+var DefaultPhotoKeys = PhotoKeys{
+	Toggle: 298, Capture: 301,
+	Forward: 'W', Back: 'S', Left: 'A', Right: 'D', Up: ' ', Down: 'C',
+	LookLeft: 263, LookRight: 262, LookUp: 265, LookDown: 264,
+	RollLeft: 'Q', RollRight: 'E',
+	ZoomIn: '=', ZoomOut: '-',
+	Slow: 340,
+}
+
+// PhotoMode pauses the simulation and frees the camera, so players can frame
+// and capture high quality screenshots. While active, the clock is paused,
+// the built-in camera controls are replaced by the photo mode keys, and the
+// HiddenPasses of the Renderer are disabled. The camera is restored on exit.
+type PhotoMode struct {
+	Keys PhotoKeys
+	// Speed is the camera speed in blocks per second, TurnSpeed the look and
+	// roll speed in degrees per second, and ZoomSpeed the field of view change
+	// in degrees per second.
+	Speed, TurnSpeed, ZoomSpeed float32
+	// MinFOV and MaxFOV limit the field of view, in degrees.
+	MinFOV, MaxFOV float32
+
+	// Supersampling is the resolution multiplier of the captures, from 1 to
+	// 4. Captures are rendered that many times larger, then downscaled.
+	Supersampling int
+	// Dir is where the captures triggered by the Capture key are saved.
+	Dir string
+
+	// Renderer, if set, has the HiddenPasses disabled in the photo mode, and
+	// draws the captures when Draw is nil.
+	Renderer     *render.Renderer
+	HiddenPasses []string
+	// Draw renders the scene for a capture into the current render target,
+	// using the frame camera.
+	Draw func(f *render.Frame)
+
+	window      *render.Window
+	clock       *Clock
+	active      bool
+	held        map[int]bool
+	capture     bool
+	pending     *pendingCapture
+	last        float64
+	unsubscribe func()
+
+	// Camera and clock state restored on exit.
+	pos, front glm.Vec3
+	fov, roll  float32
+	paused     bool
+}
+
+// NewPhotoMode creates an inactive photo mode for the window camera, pausing
+// the clock when active. It listens to the keys on event.Default until Close
+// is called.
+func NewPhotoMode(w *render.Window, clock *Clock) *PhotoMode {
+	p := &PhotoMode{
+		Keys:          DefaultPhotoKeys,
+		Speed:         4,
+		TurnSpeed:     60,
+		ZoomSpeed:     20,
+		MinFOV:        10,
+		MaxFOV:        110,
+		Supersampling: 2,
+		Dir:           defaultScreenshotDir(),
+		HiddenPasses:  []string{render.PassViewModel, render.PassUI, render.PassDebug},
+		window:        w,
+		clock:         clock,
+		held:          map[int]bool{},
+	}
+	p.unsubscribe = event.Subscribe(event.Default, p.onKey)
+	return p
+}
+
+// defaultScreenshotDir returns the openvoxel directory inside the user
+// pictures directory, or an empty string if there is no home directory.
+func defaultScreenshotDir() string {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "Pictures", "openvoxel")
+}
+
+func (p *PhotoMode) onKey(e event.Key) {
+	if e.Text {
+		return
+	}
+	switch e.Action {
+	case event.KeyRelease:
+		delete(p.held, e.Key)
+		return
+	case event.KeyPress:
+		switch e.Key {
+		case p.Keys.Toggle:
+			p.Toggle()
+			return
+		case p.Keys.Capture:
+			p.capture = p.active
+			return
+		}
+	}
+	p.held[e.Key] = true
+}
+
+// Active returns true while the photo mode is on.
+func (p *PhotoMode) Active() bool {
+	return p.active
+}
+
+// Toggle enters or exits the photo mode.
+func (p *PhotoMode) Toggle() {
+	if p.active {
+		p.Exit()
+	} else {
+		p.Enter()
+	}
+}
+
+// Enter pauses the simulation and gives the camera to the photo mode.
+func (p *PhotoMode) Enter() {
+	if p.active {
+		return
+	}
+	p.active = true
+	cam := p.window.Scene().Camera()
+	p.pos, p.front, p.fov, p.roll = cam.Position(), cam.Front(), cam.FOV, cam.Roll
+	p.paused = p.clock.Paused
+	p.clock.Paused = true
+	p.window.SetCameraControls(false)
+	p.setPasses(false)
+	p.last = render.Time()
+	log.Infof("Photo mode on")
+}
+
+// Exit restores the camera and resumes the simulation.
+func (p *PhotoMode) Exit() {
+	if !p.active {
+		return
+	}
+	p.active = false
+	cam := p.window.Scene().Camera()
+	cam.SetPosition(p.pos)
+	cam.SetFront(p.front)
+	cam.FOV, cam.Roll = p.fov, p.roll
+	p.clock.Paused = p.paused
+	p.window.SetCameraControls(true)
+	p.setPasses(true)
+	p.capture = false
+	log.Infof("Photo mode off")
+}
+
+func (p *PhotoMode) setPasses(enabled bool) {
+	if p.Renderer == nil {
+		return
+	}
+	for _, name := range p.HiddenPasses {
+		p.Renderer.SetEnabled(name, enabled)
+	}
+}
+
+// Update moves the camera with the held keys, captures if requested, and saves
+// the previous capture once its pixels are read back. It must be called once per frame, usually from Game.Render, as
+// Game.Update is not called while the simulation is paused.
+func (p *PhotoMode) Update() {
+	if p.pending != nil && p.pending.rb.Ready() {
+		p.save()
+	}
+	if !p.active {
+		return
+	}
+	now := render.Time()
+	dt := float32(now - p.last)
+	p.last = now
+	if p.held[p.Keys.Slow] {
+		dt /= 4
+	}
+	axis := func(neg, pos int) float32 {
+		var v float32
+		if p.held[neg] {
+			v--
+		}
+		if p.held[pos] {
+			v++
+		}
+		return v
+	}
+
+	cam := p.window.Scene().Camera()
+	front := cam.Front()
+	right := front.Cross(glm.Vec3{0, 1, 0}).Normalize()
+	move := front.Mul(axis(p.Keys.Back, p.Keys.Forward)).
+		Add(right.Mul(axis(p.Keys.Left, p.Keys.Right))).
+		Add(glm.Vec3{0, axis(p.Keys.Down, p.Keys.Up), 0})
+	cam.SetPosition(cam.Position().Add(move.Mul(p.Speed * dt)))
+
+	turn := glm.DegToRad(p.TurnSpeed * dt)
+	if yaw := axis(p.Keys.LookRight, p.Keys.LookLeft); yaw != 0 {
+		front = glm.HomogRotate3DY(yaw * turn).Mul4x1(front.Vec4(0)).Vec3()
+	}
+	if pitch := axis(p.Keys.LookDown, p.Keys.LookUp); pitch != 0 {
+		rotated := glm.HomogRotate3D(pitch*turn, right).Mul4x1(front.Vec4(0)).Vec3()
+		// Stop before looking straight up or down, where the view flips.
+		if rotated.Normalize()[1] < 0.99 && rotated.Normalize()[1] > -0.99 {
+			front = rotated
+		}
+	}
+	cam.SetFront(front)
+	cam.Roll += axis(p.Keys.RollLeft, p.Keys.RollRight) * p.TurnSpeed * dt
+	cam.FOV += axis(p.Keys.ZoomIn, p.Keys.ZoomOut) * p.ZoomSpeed * dt
+	if cam.FOV < p.MinFOV {
+		cam.FOV = p.MinFOV
+	}
+	if cam.FOV > p.MaxFOV {
+		cam.FOV = p.MaxFOV
+	}
+
+	if p.capture {
+		p.capture = false
+		if p.pending != nil {
+			// Only one capture is read back at a time.
+			p.save()
+		}
+		fb, s, err := p.drawCapture()
+		if err != nil {
+			log.Warnf("Error capturing screenshot: %v", err)
+			return
+		}
+		// Read the pixels back asynchronously, so the capture does not stall
+		// the frame, and save them once the GPU is done.
+		p.pending = &pendingCapture{fb: fb, rb: fb.ReadPixelsAsync(0, image.Rect(0, 0, fb.Width, fb.Height)), s: s}
+	}
+}
+
+// pendingCapture is a capture being read back from the GPU.
+type pendingCapture struct {
+	fb *render.Framebuffer
+	rb *render.Readback
+	s  int
+}
+
+// save writes the pending capture to Dir and releases it.
+func (p *PhotoMode) save() {
+	c := p.pending
+	p.pending = nil
+	img := downscale(c.rb.Image(), c.s)
+	c.rb.Delete()
+	c.fb.Delete()
+	name, err := SaveScreenshot(p.Dir, img)
+	if err != nil {
+		log.Warnf("Error capturing screenshot: %v", err)
+		return
+	}
+	log.Infof("Screenshot saved to %v", name)
+}
+
+// Capture renders the current camera view at Supersampling times the window
+// size, and returns it downscaled to the window size. It waits for the GPU to
+// finish rendering, unlike the captures triggered by the Capture key.
+func (p *PhotoMode) Capture() (*image.RGBA, error) {
+	fb, s, err := p.drawCapture()
+	if err != nil {
+		return nil, err
+	}
+	defer fb.Delete()
+	return downscale(fb.ReadPixels(0), s), nil
+}
+
+// drawCapture renders the current camera view into a new framebuffer at the
+// returned supersampling times the window size.
+func (p *PhotoMode) drawCapture() (*render.Framebuffer, int, error) {
+	draw := p.Draw
+	if draw == nil && p.Renderer != nil {
+		draw = p.Renderer.Draw
+	}
+	if draw == nil {
+		return nil, 0, fmt.Errorf("photo mode: no Draw function or Renderer")
+	}
+	s := p.Supersampling
+	if s < 1 {
+		s = 1
+	}
+	if s > 4 {
+		s = 4
+	}
+	w, h := p.window.Width, p.window.Height
+	fb, err := render.NewFramebuffer(w*s, h*s, render.FormatRGBA8)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cam := p.window.Scene().Camera()
+	f := &render.Frame{
+		Window:     p.window,
+		Eye:        cam.Position(),
+		View:       cam.View(),
+		Projection: cam.Projection(float32(w) / float32(h)),
+	}
+	fb.Bind()
+	p.window.Scene().Clear()
+	draw(f)
+	p.window.BindDefaultFramebuffer()
+	return fb, s, nil
+}
+
+// downscale averages each s x s block of pixels of img.
+func downscale(img *image.RGBA, s int) *image.RGBA {
+	if s == 1 {
+		return img
+	}
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx()/s, b.Dy()/s))
+	n := uint32(s * s)
+	for y := 0; y < out.Rect.Dy(); y++ {
+		for x := 0; x < out.Rect.Dx(); x++ {
+			var sum [4]uint32
+			for dy := 0; dy < s; dy++ {
+				i := img.PixOffset(x*s, y*s+dy)
+				for dx := 0; dx < s; dx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += uint32(img.Pix[i+dx*4+c])
+					}
+				}
+			}
+			o := out.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				out.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return out
+}
+
+// SaveScreenshot writes img as a PNG file named after the current time in
+// dir, and returns the file name.
+func SaveScreenshot(dir string, img image.Image) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("photo mode: no screenshot directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, "screenshot-"+time.Now().Format("20060102-150405.000")+".png")
+	fd, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	if err := png.Encode(fd, img); err != nil {
+		fd.Close()
+		return "", err
+	}
+	return name, fd.Close()
+}
+
+// Close exits the photo mode, saves the pending capture and stops listening to
+// the keys.
+func (p *PhotoMode) Close() {
+	p.Exit()
+	if p.pending != nil {
+		p.save()
+	}
+	p.unsubscribe()
+}
@@ -0,0 +1,256 @@
+// package entity keeps the entities of the world, such as mobs and dropped
+// items, indexed by the chunk they are in, so they are saved and unloaded with
+// their chunk.
+//
+// The state of an entity is a list of components, created for each entity
+// type by the factory in the Registry, that the game systems find with Get,
+// update directly and that are serialized with it, such as a health.Health
+// or an ai.Steering.
+package entity
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// ErrUnknownType is returned when creating or loading an entity of a type that
+// is not registered.
+var ErrUnknownType = errors.New("entity: unknown type")
+
+// Component is a piece of the entity state saved with it.
+type Component interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// Attacher is implemented by the components that need the entity they belong
+// to, such as health.Health, which publishes events with its ID. The Store
+// calls Attach when the entity is spawned or loaded, before publishing
+// event.EntitySpawned.
+type Attacher interface {
+	Attach(id uint64, b *event.Bus)
+}
+
+// JSON returns a component that serializes v, a pointer, as JSON. It allows
+// plain structs, such as mover.Falling, to be saved with the entity.
+func JSON(v any) Component {
+	return jsonComponent{v}
+}
+
+type jsonComponent struct {
+	v any
+}
+
+func (c jsonComponent) MarshalBinary() ([]byte, error) {
+	return json.Marshal(c.v)
+}
+
+func (c jsonComponent) UnmarshalBinary(b []byte) error {
+	return json.Unmarshal(b, c.v)
+}
+
+// Factory creates the components of a new entity of a type, with their
+// default values. Loading an entity unmarshals the saved components in the
+// same order, so new components must be appended at the end: entities saved
+// before they existed keep their default values.
+type Factory func() []Component
+
+// Registry maps the entity type names, such as "openvoxel:zombie", to their
+// component factories.
+type Registry struct {
+	factories map[string]Factory
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{factories: map[string]Factory{}}
+}
+
+// Register adds the entity type.
+func (r *Registry) Register(name string, f Factory) error {
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("entity: %q already registered", name)
+	}
+	r.factories[name] = f
+	return nil
+}
+
+// New returns the components for a new entity of the type.
+func (r *Registry) New(name string) ([]Component, error) {
+	f, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, name)
+	}
+	return f(), nil
+}
+
+// Entity is an entity in the world.
+type Entity struct {
+	ID   uint64
+	Type string
+	// Position is the entity position in the world, which selects the chunk
+	// it is saved with. It must be changed with Store.Move.
+	Position   glm.Vec3
+	Components []Component
+}
+
+// Get returns the first component of the entity of type T, including
+// the values wrapped by JSON.
+func Get[T any](e *Entity) (T, bool) {
+	for _, c := range e.Components {
+		if j, ok := c.(jsonComponent); ok {
+			if v, ok := j.v.(T); ok {
+				return v, true
+			}
+			continue
+		}
+		if v, ok := c.(T); ok {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// Store holds the entities of the loaded chunks. It is not safe for concurrent
+// use.
+type Store struct {
+	Registry *Registry
+	Bus      *event.Bus
+	// NextID is the ID of the next spawned entity. It must be saved with the
+	// world, so entities in unloaded chunks keep unique IDs.
+	NextID uint64
+
+	entities map[uint64]*Entity
+	chunks   map[world.ChunkPos]map[uint64]*Entity
+}
+
+// NewStore creates a store without entities.
+func NewStore(r *Registry, b *event.Bus) *Store {
+	return &Store{
+		Registry: r,
+		Bus:      b,
+		NextID:   1,
+		entities: map[uint64]*Entity{},
+		chunks:   map[world.ChunkPos]map[uint64]*Entity{},
+	}
+}
+
+// chunkOf returns the chunk containing p.
+func chunkOf(p glm.Vec3) world.ChunkPos {
+	return world.ChunkAt(
+		int(math.Floor(float64(p[0]))),
+		int(math.Floor(float64(p[1]))),
+		int(math.Floor(float64(p[2]))),
+	)
+}
+
+// Spawn creates an entity of the type at p, and publishes
+// event.EntitySpawned.
+func (s *Store) Spawn(name string, p glm.Vec3) (*Entity, error) {
+	components, err := s.Registry.New(name)
+	if err != nil {
+		return nil, err
+	}
+	e := &Entity{ID: s.NextID, Type: name, Position: p, Components: components}
+	s.NextID++
+	s.add(e)
+	return e, nil
+}
+
+// add indexes the entity, attaches its components and publishes
+// event.EntitySpawned.
+func (s *Store) add(e *Entity) {
+	for _, c := range e.Components {
+		var v any = c
+		if j, ok := c.(jsonComponent); ok {
+			v = j.v
+		}
+		if a, ok := v.(Attacher); ok {
+			a.Attach(e.ID, s.Bus)
+		}
+	}
+	s.entities[e.ID] = e
+	c := chunkOf(e.Position)
+	if s.chunks[c] == nil {
+		s.chunks[c] = map[uint64]*Entity{}
+	}
+	s.chunks[c][e.ID] = e
+	event.Publish(s.Bus, event.EntitySpawned{ID: e.ID})
+}
+
+// Get returns the entity with the ID, or nil if it is not loaded.
+func (s *Store) Get(id uint64) *Entity {
+	return s.entities[id]
+}
+
+// Len returns the number of loaded entities.
+func (s *Store) Len() int {
+	return len(s.entities)
+}
+
+// Move changes the position of the entity, moving it to another chunk when
+// it crosses a chunk border.
+func (s *Store) Move(e *Entity, p glm.Vec3) {
+	from, to := chunkOf(e.Position), chunkOf(p)
+	e.Position = p
+	if from == to || s.entities[e.ID] != e {
+		return
+	}
+	s.unindex(from, e.ID)
+	if s.chunks[to] == nil {
+		s.chunks[to] = map[uint64]*Entity{}
+	}
+	s.chunks[to][e.ID] = e
+}
+
+// Remove deletes the entity from the world, and publishes
+// event.EntityRemoved.
+func (s *Store) Remove(e *Entity) {
+	if s.entities[e.ID] != e {
+		return
+	}
+	s.remove(e, false)
+}
+
+func (s *Store) remove(e *Entity, unloaded bool) {
+	delete(s.entities, e.ID)
+	s.unindex(chunkOf(e.Position), e.ID)
+	event.Publish(s.Bus, event.EntityRemoved{ID: e.ID, Unloaded: unloaded})
+}
+
+func (s *Store) unindex(c world.ChunkPos, id uint64) {
+	delete(s.chunks[c], id)
+	if len(s.chunks[c]) == 0 {
+		delete(s.chunks, c)
+	}
+}
+
+// InChunk returns the entities in the chunk at p, ordered by ID.
+func (s *Store) InChunk(p world.ChunkPos) []*Entity {
+	out := make([]*Entity, 0, len(s.chunks[p]))
+	for _, e := range s.chunks[p] {
+		out = append(out, e)
+	}
+	sortByID(out)
+	return out
+}
+
+func sortByID(es []*Entity) {
+	sort.Slice(es, func(i, j int) bool { return es[i].ID < es[j].ID })
+}
+
+// Each calls fn for each loaded entity, in no particular order.
+func (s *Store) Each(fn func(e *Entity)) {
+	for _, e := range s.entities {
+		fn(e)
+	}
+}
@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"testing"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/ai"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/health"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+func TestComponentsSavedWithChunk(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("test:zombie", func() []Component {
+		return []Component{health.New(nil, 0, 20), ai.NewSteering()}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	bus := event.NewBus()
+	s := NewStore(r, bus)
+	e, err := s.Spawn("test:zombie", glm.Vec3{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _ := Get[*health.Health](e)
+	if h.Entity != e.ID || h.Bus != bus {
+		t.Fatalf("spawned health attached to entity %d, want %d", h.Entity, e.ID)
+	}
+	h.Current = 7
+	steer, _ := Get[*ai.Steering](e)
+	steer.Follow([]ai.Point{{X: 5, Y: 2, Z: 3}})
+
+	b, err := s.EncodeChunk(world.ChunkPos{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DecodeChunk(b); err != nil {
+		t.Fatal(err)
+	}
+	e = s.Get(e.ID)
+	if e == nil {
+		t.Fatal("entity not loaded again")
+	}
+	h, _ = Get[*health.Health](e)
+	if h.Entity != e.ID || h.Bus != bus {
+		t.Errorf("loaded health attached to entity %d, want %d", h.Entity, e.ID)
+	}
+	if h.Current != 7 || h.Max != 20 {
+		t.Errorf("loaded health %v/%v, want 7/20", h.Current, h.Max)
+	}
+	steer, _ = Get[*ai.Steering](e)
+	if v := steer.Velocity(e.Position); steer.Done() || v.X() <= 0 {
+		t.Errorf("loaded steering moves %v, want it following the saved path", v)
+	}
+}
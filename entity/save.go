@@ -0,0 +1,175 @@
+package entity
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// ErrInvalidEntities is returned when decoding malformed entity data.
+var ErrInvalidEntities = errors.New("entity: invalid entity data")
+
+// EncodeChunk removes the entities in the chunk at p and serializes them, to be
+// saved with the chunk when it is unloaded. It publishes event.EntityRemoved,
+// with Unloaded set, for each entity.
+//
+// The payload is the chunk coordinates as three int32 values and the number of
+// entities as an uint32, followed by each entity as its ID, as an uint64, its
+// type name prefixed by its length as an uint16, its position as three
+// float32 values, and the number of components as an uint16, followed by each
+// component encoded by MarshalBinary, prefixed by its length as an uint32. All
+// values are little endian.
+func (s *Store) EncodeChunk(p world.ChunkPos) ([]byte, error) {
+	es := s.InChunk(p)
+	data := binary.LittleEndian.AppendUint32(nil, uint32(int32(p.X)))
+	data = binary.LittleEndian.AppendUint32(data, uint32(int32(p.Y)))
+	data = binary.LittleEndian.AppendUint32(data, uint32(int32(p.Z)))
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(es)))
+	for _, e := range es {
+		if len(e.Type) > 0xffff || len(e.Components) > 0xffff {
+			return nil, fmt.Errorf("entity: cannot encode entity %d of type %q", e.ID, e.Type)
+		}
+		data = binary.LittleEndian.AppendUint64(data, e.ID)
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(e.Type)))
+		data = append(data, e.Type...)
+		for _, v := range e.Position {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		}
+		data = binary.LittleEndian.AppendUint16(data, uint16(len(e.Components)))
+		for _, c := range e.Components {
+			b, err := c.MarshalBinary()
+			if err != nil {
+				return nil, fmt.Errorf("entity: encoding entity %d: %w", e.ID, err)
+			}
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(b)))
+			data = append(data, b...)
+		}
+	}
+	compressed, err := world.Compress(world.DefaultCompression, data)
+	if err != nil {
+		return nil, err
+	}
+	// Only unload the entities once they were saved.
+	for _, e := range es {
+		s.remove(e, true)
+	}
+	b := world.AppendHeader(nil, world.Header{Kind: world.KindEntities, Version: world.CurrentVersion[world.KindEntities]})
+	return append(b, compressed...), nil
+}
+
+// MaxChunkBytes limits the decompressed size of the entities of a chunk read
+// by DecodeChunk. Entities with large components, such as inventories, may
+// need it raised.
+var MaxChunkBytes = 4 << 20
+
+// DecodeChunk spawns the entities serialized by EncodeChunk, when the chunk is
+// loaded again, and publishes event.EntitySpawned for each of them. Entities
+// of unknown types fail the whole chunk, so they are not lost when saving it
+// again.
+func (s *Store) DecodeChunk(b []byte) error {
+	h, payload, err := world.ReadHeader(b)
+	if err != nil {
+		return err
+	}
+	if h.Kind != world.KindEntities {
+		return fmt.Errorf("%w: expected entities, got %v", world.ErrInvalidHeader, h.Kind)
+	}
+	data, err := world.Decompress(payload, MaxChunkBytes)
+	if err != nil {
+		return err
+	}
+	if data, err = world.Migrate(h.Kind, h.Version, data); err != nil {
+		return err
+	}
+	if len(data) < 16 {
+		return ErrInvalidEntities
+	}
+	n := int(binary.LittleEndian.Uint32(data[12:]))
+	d := decoder{data: data[16:]}
+	es := make([]*Entity, 0, n)
+	for i := 0; i < n; i++ {
+		e := &Entity{ID: d.uint64()}
+		e.Type = string(d.bytes(int(d.uint16())))
+		var pos glm.Vec3
+		for j := range pos {
+			pos[j] = math.Float32frombits(d.uint32())
+		}
+		e.Position = pos
+		saved := int(d.uint16())
+		if d.err != nil {
+			return d.err
+		}
+		if e.Components, err = s.Registry.New(e.Type); err != nil {
+			return err
+		}
+		for j := 0; j < saved; j++ {
+			c := d.bytes(int(d.uint32()))
+			if d.err != nil {
+				return d.err
+			}
+			// Components removed from the type are dropped.
+			if j >= len(e.Components) {
+				continue
+			}
+			if err := e.Components[j].UnmarshalBinary(c); err != nil {
+				return fmt.Errorf("entity: decoding entity %d: %w", e.ID, err)
+			}
+		}
+		if _, ok := s.entities[e.ID]; ok {
+			return fmt.Errorf("%w: entity %d is already loaded", ErrInvalidEntities, e.ID)
+		}
+		es = append(es, e)
+	}
+	if len(d.data) != 0 {
+		return fmt.Errorf("%w: unexpected size %d", ErrInvalidEntities, len(data))
+	}
+	for _, e := range es {
+		if e.ID >= s.NextID {
+			s.NextID = e.ID + 1
+		}
+		s.add(e)
+	}
+	return nil
+}
+
+// decoder reads little endian values, recording an error when the data is too
+// short.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil || len(d.data) < n {
+		d.err = ErrInvalidEntities
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.bytes(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+// package event implements a typed publish/subscribe event bus, used to
+// decouple the engine subsystems.
+package event
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Bus dispatches published events to the handlers subscribed to their type.
+// Handlers are called synchronously, in the order they were subscribed, from
+// the goroutine that published the event.
+type Bus struct {
+	mu       sync.Mutex
+	nextID   uint64
+	handlers map[reflect.Type][]handler
+}
+
+type handler struct {
+	id uint64
+	fn func(any)
+}
+
+// Default is the event bus used by the engine subsystems.
+var Default = NewBus()
+
+// NewBus creates an empty event bus.
+func NewBus() *Bus {
+	return &Bus{
+		handlers: make(map[reflect.Type][]handler),
+	}
+}
+
+// Subscribe registers fn to be called for every event of type E published on
+// the bus. It returns a function that removes the subscription.
+func Subscribe[E any](b *Bus, fn func(E)) (unsubscribe func()) {
+	t := reflect.TypeOf((*E)(nil)).Elem()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.handlers[t] = append(b.handlers[t], handler{
+		id: id,
+		fn: func(e any) { fn(e.(E)) },
+	})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		handlers := b.handlers[t]
+		for i, h := range handlers {
+			if h.id == id {
+				b.handlers[t] = append(handlers[:i:i], handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish sends the event e to all handlers subscribed to the type E.
+func Publish[E any](b *Bus, e E) {
+	t := reflect.TypeOf((*E)(nil)).Elem()
+
+	// Copy the handler list, so handlers can (un)subscribe while we dispatch.
+	b.mu.Lock()
+	handlers := b.handlers[t]
+	b.mu.Unlock()
+
+	for _, h := range handlers {
+		h.fn(e)
+	}
+}
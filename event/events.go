@@ -0,0 +1,274 @@
+package event
+
+// WindowResized is published when the window framebuffer size changes.
+type WindowResized struct {
+	Width  int
+	Height int
+}
+
+// KeyAction describes what happened to a key.
+type KeyAction int
+
+const (
+	KeyRelease KeyAction = iota
+	KeyPress
+	KeyRepeat
+)
+
+// Key is published when a keyboard key is pressed, released or repeated.
+type Key struct {
+	Key      int
+	Scancode int
+	Action   KeyAction
+	Mods     int
+	// Text is true when the key was pressed in the text input mode. Game
+	// controls should ignore these events, which text fields use for the
+	// editing keys, such as Backspace and Enter.
+	Text bool
+}
+
+// Text is published in the text input mode with the UTF-8 text typed by the
+// user, including the result of an input method composition.
+type Text struct {
+	Text string
+}
+
+// TextComposition is published in the text input mode while an input method
+// composes text, such as the syllables of a CJK phrase not yet confirmed. It
+// should be displayed at the cursor of the text field, and replaced when the
+// next one arrives. An empty Text ends the composition, either canceled or
+// followed by the Text event with the result.
+type TextComposition struct {
+	Text string
+	// Cursor is the position of the composition cursor, in runes.
+	Cursor int
+}
+
+// Mouse buttons, as published in MouseButton.
+const (
+	MouseLeft   = 0
+	MouseRight  = 1
+	MouseMiddle = 2
+)
+
+// MouseButton is published when a mouse button is pressed or released.
+type MouseButton struct {
+	Button int
+	Action KeyAction
+	Mods   int
+}
+
+// FileDrop is published when files are dropped on the window, with their
+// contents already read.
+type FileDrop struct {
+	Files []DroppedFile
+}
+
+// DroppedFile is a file dropped on the window.
+type DroppedFile struct {
+	// Name is the base name of the file. Browsers don't expose the
+	// directory.
+	Name string
+	Data []byte
+}
+
+// BlockChanged is published when a block in the world is modified. The states
+// are packed with block.State.Pack.
+type BlockChanged struct {
+	X, Y, Z  int
+	Old, New uint32
+}
+
+// BlockBreaking is published while the local player is breaking a block, with
+// the progress from 0 to 1, so the cracks can be drawn. A negative progress
+// means the player stopped.
+type BlockBreaking struct {
+	X, Y, Z  int
+	Progress float32
+}
+
+// BlockBreak is published, as a pointer, before a block is broken by the
+// local player. Handlers can set Cancel to keep the block, such as in
+// protected areas.
+type BlockBreak struct {
+	X, Y, Z int
+	Block   uint32
+	Cancel  bool
+}
+
+// BlockPlace is published, as a pointer, before the local player places a
+// block. Handlers can set Cancel to prevent it.
+type BlockPlace struct {
+	X, Y, Z int
+	Block   uint32
+	Cancel  bool
+}
+
+// BlockUse is published, as a pointer, when the local player uses a block,
+// such as opening a door. Handlers that handle the use must set Handled,
+// which prevents placing the held block against it.
+type BlockUse struct {
+	X, Y, Z int
+	Block   uint32
+	Handled bool
+}
+
+// ChunkLoaded is published when a chunk becomes available in the world.
+type ChunkLoaded struct {
+	X, Y, Z int
+}
+
+// EntitySpawned is published when a new entity is added to the world.
+type EntitySpawned struct {
+	ID uint64
+}
+
+// EntityRemoved is published when an entity leaves the world, either removed
+// for good or Unloaded with its chunk, to be spawned again when the chunk is
+// loaded.
+type EntityRemoved struct {
+	ID       uint64
+	Unloaded bool
+}
+
+// PlayerActionKind is the type of action performed by the local player.
+type PlayerActionKind int
+
+const (
+	ActionAttack PlayerActionKind = iota
+	ActionUse
+	ActionPlace
+)
+
+// PlayerAction is published when the local player attacks, uses an item or
+// places a block.
+type PlayerAction struct {
+	Action PlayerActionKind
+}
+
+// DamageSource is the cause of the damage taken by an entity.
+type DamageSource int
+
+const (
+	DamageFall DamageSource = iota
+	DamageDrowning
+	DamageMob
+	DamageExplosion
+)
+
+// Damage is published, as a pointer, before an entity takes damage. Handlers
+// can reduce Amount, such as for armor, or set Cancel to prevent it.
+type Damage struct {
+	Entity uint64
+	Source DamageSource
+	Amount float32
+	Cancel bool
+}
+
+// HealthChanged is published when the health of an entity changes.
+type HealthChanged struct {
+	Entity      uint64
+	Health, Max float32
+}
+
+// Died is published when the health of an entity reaches zero.
+type Died struct {
+	Entity uint64
+	Source DamageSource
+}
+
+// Respawned is published when a dead entity comes back at the position X, Y,
+// Z.
+type Respawned struct {
+	Entity  uint64
+	X, Y, Z float32
+}
+
+// GameModeChanged is published when the game mode of the local player
+// changes. Mode is a gamemode.Mode.
+type GameModeChanged struct {
+	Mode int
+}
+
+// EntityUse is published, as a pointer, when the local player uses an entity,
+// such as talking to an NPC. Handlers that handle the use must set Handled,
+// which prevents using the block behind it.
+type EntityUse struct {
+	Entity  uint64
+	Handled bool
+}
+
+// TriggerEnter is published when an entity enters a trigger volume.
+type TriggerEnter struct {
+	Trigger string
+	Entity  uint64
+}
+
+// TriggerExit is published when an entity leaves a trigger volume.
+type TriggerExit struct {
+	Trigger string
+	Entity  uint64
+}
+
+// DialogLine is published when an NPC says a line of a dialog. The UI shows
+// the text and the choices, and answers with DialogChoice. Lines without
+// choices are continued with any choice.
+type DialogLine struct {
+	Entity        uint64
+	Speaker, Text string
+	Choices       []string
+}
+
+// DialogChoice is published by the UI when the player picks a choice of the
+// line said by the entity. A negative choice closes the dialog.
+type DialogChoice struct {
+	Entity uint64
+	Choice int
+}
+
+// DialogAction is published when the player reaches a dialog node or picks a
+// choice with an action, for the game and mods to react, such as starting a
+// quest or opening a door.
+type DialogAction struct {
+	Entity uint64
+	Action string
+}
+
+// DialogEnd is published when a dialog ends.
+type DialogEnd struct {
+	Entity uint64
+}
+
+// SignalChanged is published when the power received by a circuit receiver,
+// such as a door or a lamp, changes. Power ranges from 0 to 15, and Block is
+// the receiver state packed with block.State.Pack.
+type SignalChanged struct {
+	X, Y, Z int
+	Block   uint32
+	Power   int
+}
+
+// Explosion is published after an explosion destroyed the blocks in the
+// world, instead of an event.BlockChanged for each block. Chunks lists the
+// chunks, including the neighbors of the blocks on their borders, that must
+// be meshed again.
+type Explosion struct {
+	X, Y, Z float32
+	Power   float32
+	Blocks  []BlockChanged
+	Chunks  [][3]int
+}
+
+// BlocksRedefined is published when block definitions are replaced, such as
+// when the definition files are reloaded during development. Chunks lists the
+// loaded chunks using the blocks, which must be meshed again.
+type BlocksRedefined struct {
+	IDs    []uint16
+	Chunks [][3]int
+}
+
+// TextureReloaded is published after a texture file changed and was uploaded
+// again, keeping the same texture object.
+type TextureReloaded struct {
+	Path string
+}
@@ -2,5 +2,7 @@
 // Engine.
 //
 // Currently it only shows some small elements on screen, and provides a very
-// basic demo game.
+// basic demo game. Press R to switch the floor between the mesh renderer and
+// the experimental raymarching renderer, and F9 to enter the photo mode, where
+// F12 saves a screenshot.
 package main
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"fmt"
 	"os"
-	"runtime"
-	"time"
+	"strings"
 
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/console"
+	"github.com/ronoaldo/openvoxel/engine"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/input"
 	"github.com/ronoaldo/openvoxel/log"
+	"github.com/ronoaldo/openvoxel/platform"
 	"github.com/ronoaldo/openvoxel/render"
 	"github.com/ronoaldo/openvoxel/transform"
+	"github.com/ronoaldo/openvoxel/worker"
 
 	_ "embed"
 )
@@ -17,6 +24,9 @@ var (
 	winHeight int = 768
 )
 
+// floorSize is the width of the dirt floor, in blocks.
+const floorSize = 20
+
 // f is a syntax suggar to cast any number to float32
 func f[X int | int32 | int64 | uint | uint32 | uint64 | float64](i X) float32 {
 	return float32(i)
@@ -33,83 +43,236 @@ var (
 	texDirt []byte
 )
 
-func init() {
-	runtime.LockOSThread()
+// demo implements the engine.Game interface.
+type demo struct {
+	window *render.Window
+	shader *render.Shader
+	tex    *render.Texture
+
+	// The floor can be drawn by raymarching a voxel volume instead of
+	// rasterizing cubes, toggled with the R key, to compare both renderers.
+	raymarcher  *render.Raymarcher
+	volume      *render.VolumeTexture
+	raymarch    bool
+	unsubscribe func()
+	// unsubscribeDrop stops logging the files dropped on the window.
+	unsubscribeDrop func()
+
+	clock *engine.Clock
+	photo *engine.PhotoMode
+
+	// chat is the message being typed, opened with the T key.
+	chat     *input.TextField
+	openChat bool
+	// commands run the chat messages starting with a slash.
+	commands *console.Registry
+
+	frameCount int32
+
+	// t is the simulation time, advanced on each Update.
+	t float64
 }
 
-func main() {
-	log.Infof("Initializing main window")
-	window, err := render.NewWindow(winWidth, winHeight, "openvoxel.net [Demo]")
-	if err != nil {
-		log.Warnf("Unable to open new window: %v", err)
+func (d *demo) Init(w *render.Window) error {
+	d.window = w
+	d.unsubscribe = event.Subscribe(event.Default, func(e event.Key) {
+		if e.Text || e.Action != event.KeyPress {
+			return
+		}
+		switch e.Key {
+		case 'R':
+			d.raymarch = !d.raymarch && d.raymarcher != nil
+			log.Infof("Raymarched floor: %v", d.raymarch)
+		case 'T':
+			// Started on the next frame, so the T itself is not typed.
+			d.openChat = true
+		}
+	})
+	d.unsubscribeDrop = event.Subscribe(event.Default, func(e event.FileDrop) {
+		for _, f := range e.Files {
+			log.Infof("Dropped file %v: %d bytes", f.Name, len(f.Data))
+		}
+	})
+	d.chat = input.NewTextField()
+	d.chat.MaxLength = 256
+	d.commands = console.NewRegistry()
+	d.commands.Register(console.Command{
+		Name: "pos",
+		Help: "shows the camera position",
+		Handler: func(args []string) (string, error) {
+			p := w.Scene().Camera().Position()
+			return fmt.Sprintf("%.1f %.1f %.1f", p[0], p[1], p[2]), nil
+		},
+	})
+	console.RegisterClipboardCommands(d.commands, platform.ClipboardSet)
+	d.chat.OnSubmit = func(text string) {
+		if strings.HasPrefix(text, "/") {
+			out, err := d.commands.Execute(text[1:])
+			if err != nil {
+				out = err.Error()
+			}
+			log.Infof("%v", out)
+		} else {
+			log.Infof("Chat: %v", text)
+		}
+		d.chat.SetText("")
+		w.StopTextInput()
 	}
-	defer window.Close()
-	log.Infof("Rendering Backend: %v", render.Version())
-
-	shader := &render.Shader{}
-	shader.VertexShader(vertexShaderSrc).FragmentShader(fragmentShaderSrc)
-	if err := shader.Link(); err != nil {
-		log.Warnf("error linking shader program: %v", err)
-		os.Exit(1)
+	d.chat.OnCancel = func() {
+		d.chat.SetText("")
+		w.StopTextInput()
 	}
+	d.chat.Listen(event.Default)
+	d.photo = engine.NewPhotoMode(w, d.clock)
+	d.photo.Draw = d.draw
+	return nil
+}
+
+// Load implements engine.Loader, so the shaders and textures are loaded while
+// the loading screen is displayed.
+func (d *demo) Load() []engine.LoadStep {
+	return []engine.LoadStep{
+		{Name: "Compiling shaders", Run: func() error {
+			d.shader = &render.Shader{}
+			d.shader.VertexShader(vertexShaderSrc).FragmentShader(fragmentShaderSrc)
+			if err := d.shader.Link(); err != nil {
+				log.Warnf("error linking shader program: %v", err)
+				return err
+			}
+			return nil
+		}},
+		{Name: "Loading textures", Run: func() error {
+			log.Infof("Rendering cube %v", cube)
+			d.window.Scene().AddVertices(cube)
 
-	log.Infof("Rendering cube %v", cube)
-	window.Scene().AddVertices(cube)
+			tex, err := render.NewTextureFromBytes(texDirt)
+			if err != nil {
+				log.Warnf("Error loading texture: %v", err)
+				return err
+			}
+			d.window.Scene().AddTexture(tex)
+			d.tex = tex
+			return nil
+		}},
+		{Name: "Building voxel volume", Run: func() error {
+			r, err := render.NewRaymarcher()
+			if err != nil {
+				// The experimental renderer is optional.
+				log.Warnf("Raymarching unavailable: %v", err)
+				return nil
+			}
+			// Unlit, like the rasterized cubes, so both look the same.
+			r.LightDir = glm.Vec3{}
+			r.Texture = d.tex
+			d.raymarcher = r
 
-	tex, err := render.NewTextureFromBytes(texDirt)
-	if err != nil {
-		log.Warnf("Error loading texture: %v", err)
-		os.Exit(1)
+			d.volume = render.NewVolumeTexture(floorSize, 1, floorSize)
+			pixels := make([]uint8, floorSize*floorSize*4)
+			for i := range pixels {
+				pixels[i] = 255
+			}
+			d.volume.Update(pixels)
+			return nil
+		}},
 	}
-	window.Scene().AddTexture(tex)
+}
 
-	// Main program loop
-	fov := transform.DegToRad(45)
-	frameCount := int32(0)
-	start := time.Now()
-	lastLog := 0
-	for !window.ShouldClose() {
-		t := render.Time()
+func (d *demo) Update(dt float64) {
+	d.t += dt
+}
 
-		window.Scene().Clear()
+func (d *demo) Render(alpha float64) {
+	if d.openChat {
+		d.openChat = false
+		d.window.StartTextInput()
+	}
+	d.photo.Update()
+	cam := d.window.Scene().Camera()
+	aspect := f(d.window.Width) / f(d.window.Height)
+	d.draw(&render.Frame{
+		Window:     d.window,
+		Eye:        cam.Position(),
+		View:       cam.View(),
+		Projection: cam.Projection(aspect),
+		Alpha:      alpha,
+	})
+	d.frameCount++
+}
 
-		aspect := f(window.Width) / f(window.Height)
-		projection := transform.Perspective(fov, aspect, 0.1, 100)
+// draw renders the scene with the frame camera. It is also used by the photo
+// mode to render the captures.
+func (d *demo) draw(fr *render.Frame) {
+	t := d.t
+	shader := d.shader
+	shader.Use()
+	shader.UniformInts("frameCount", d.frameCount)
+	shader.UniformFloats("renderTime", f(t))
+	shader.UniformTransformation("projection", fr.Projection)
 
+	// Draw 20x20 blocks of dirt at bottom
+	if d.raymarch {
+		// The cubes are centered on the integer coordinates.
+		origin := glm.Vec3{-floorSize/2 - 0.5, -0.5, -floorSize/2 - 0.5}
+		d.raymarcher.Draw(d.volume, origin, fr.Eye, fr.View, fr.Projection, d.window.Scene().Camera().Far)
 		shader.Use()
-		shader.UniformInts("frameCount", frameCount)
-		shader.UniformFloats("renderTime", f(t))
-		shader.UniformTransformation("projection", projection)
-
-		// Draw 10x10 blocks of dirt at bottom
-		for x := -10; x < 10; x++ {
-			for z := -10; z < 10; z++ {
+	} else {
+		for x := -floorSize / 2; x < floorSize/2; x++ {
+			for z := -floorSize / 2; z < floorSize/2; z++ {
 				model := transform.Translate(f(x), 0, f(z))
 				shader.UniformTransformation("model", model)
-				window.Scene().Draw(shader)
+				d.window.Scene().Draw(shader)
 			}
 		}
+	}
 
-		// Draw a rotating cube above them
-		ang := transform.DegToRad(45) * f(t)
-		model := transform.Chain(
-			transform.Translate(0, 3, 0),
-			transform.Rotate(ang, 0, 1, 0),
-		)
-		shader.UniformTransformation("model", model)
-		window.Scene().Draw(shader)
-
-		window.SwapBuffers()
-		window.PollEvents()
-
-		frameCount++
-		elapsedMs := int(time.Since(start).Milliseconds())
-		elapsedSec := elapsedMs / 1000
-		fps := f(frameCount) / f(elapsedSec)
-		if lastLog != int(elapsedSec) {
-			log.Infof("At %d sec, avg FPS %.02f; t=%v", elapsedSec, fps, t)
-			lastLog = int(elapsedSec)
-		}
+	// Draw a rotating cube above them
+	ang := transform.DegToRad(45) * f(t)
+	model := transform.Chain(
+		transform.Translate(0, 3, 0),
+		transform.Rotate(ang, 0, 1, 0),
+	)
+	shader.UniformTransformation("model", model)
+	d.window.Scene().Draw(shader)
+}
+
+func (d *demo) Shutdown() {
+	if d.unsubscribe != nil {
+		d.unsubscribe()
+		d.unsubscribeDrop()
+		d.chat.Close()
+	}
+	if d.photo != nil {
+		d.photo.Close()
+	}
+	if d.raymarcher != nil {
+		d.raymarcher.Delete()
+		d.volume.Delete()
+	}
+	// The loading steps may not have run if the window was closed early.
+	if d.tex != nil {
+		d.tex.Delete()
+	}
+	if d.shader != nil {
+		d.shader.Delete()
+	}
+}
+
+func main() {
+	// On the web the same module also runs the background workers.
+	if worker.IsWorker() {
+		worker.Serve()
+		return
+	}
+
+	// The clock is shared with the photo mode, which pauses it.
+	clock := engine.NewClock()
+	cfg := engine.DefaultConfig
+	cfg.Width, cfg.Height = winWidth, winHeight
+	cfg.Title = "openvoxel.net [Demo]"
+	cfg.Clock = clock
+	if err := engine.RunWithConfig(&demo{clock: clock}, cfg); err != nil {
+		log.Errorf("Error running demo: %v", err)
+		os.Exit(1)
 	}
 }
 
@@ -3,8 +3,11 @@ precision mediump float;
 
 out vec4 FragColor;
 in vec2 TexCoord;
+in vec3 Light;
+in vec3 Tint;
 uniform sampler2D texture0;
 
 void main() {
-    FragColor = texture(texture0, TexCoord);
+    vec4 color = texture(texture0, TexCoord);
+    FragColor = vec4(color.rgb * Tint * Light, color.a);
 }
\ No newline at end of file
@@ -1,9 +1,13 @@
 #version 300 es
 layout (location = 0) in vec3 aPos;
 layout (location = 1) in vec2 aTexCoord;
+layout (location = 2) in vec3 aLight;
+layout (location = 5) in vec3 aTint;
 
 out vec3 ourColor;
 out vec2 TexCoord;
+out vec3 Light;
+out vec3 Tint;
 
 uniform int frameCount;
 uniform float renderTime;
@@ -14,4 +18,6 @@ uniform mat4 projection;
 void main() {
     gl_Position = projection * view * model * vec4(aPos, 1.0);
     TexCoord = vec2(aTexCoord.x, aTexCoord.y);
+    Light = aLight;
+    Tint = aTint;
 }
\ No newline at end of file
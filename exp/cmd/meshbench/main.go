@@ -0,0 +1,128 @@
+// Command meshbench measures the chunk meshing throughput on a generated
+// terrain, to compare meshing strategies.
+//
+// The CPU greedy mesher is always measured. With -gpu, a window is opened to
+// measure the compute shader mesher as well, which needs OpenGL 4.3.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/mesh"
+	"github.com/ronoaldo/openvoxel/render"
+	"github.com/ronoaldo/openvoxel/rng"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+var (
+	chunks = flag.Int("chunks", 64, "number of chunks to mesh")
+	seed   = flag.Uint64("seed", 1, "terrain seed")
+	gpu    = flag.Bool("gpu", false, "also measure the compute shader mesher")
+)
+
+func main() {
+	flag.Parse()
+
+	reg := block.NewRegistry()
+	stone, _ := reg.Register(block.Definition{Name: "openvoxel:stone", Opaque: true})
+	dirt, _ := reg.Register(block.Definition{Name: "openvoxel:dirt", Opaque: true})
+
+	r := rng.New(*seed)
+	cs := make([]*world.Chunk, *chunks)
+	for n := range cs {
+		c := world.NewChunk(n, 0, 0)
+		phase := r.Float64() * math.Pi
+		for x := 0; x < world.SizeX; x++ {
+			for z := 0; z < world.SizeZ; z++ {
+				h := 8 + int(4*math.Sin(float64(x+n*world.SizeX)/7+phase)*math.Cos(float64(z)/5))
+				for y := 0; y < h; y++ {
+					id := stone
+					if y > h-4 {
+						id = dirt
+					}
+					if r.Intn(50) == 0 {
+						continue
+					}
+					c.Set(x, y, z, block.State{ID: id})
+				}
+			}
+		}
+		cs[n] = c
+	}
+
+	m := &mesh.Mesher{Registry: reg}
+	start := time.Now()
+	vertices, floatBytes, packedBytes := 0, 0, 0
+	for _, c := range cs {
+		quads := m.Quads(c, nil)
+		v := m.Vertices(c, quads)
+		vertices += len(v) / mesh.VertexSize
+		floatBytes += len(v) * 4
+		packedBytes += len(m.Pack(c, quads))
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("cpu-greedy: %d chunks, %d vertices, %v total, %v/chunk\n",
+		len(cs), vertices, elapsed, elapsed/time.Duration(len(cs)))
+	fmt.Printf("vertex data: %d bytes as floats, %d bytes packed\n", floatBytes, packedBytes)
+
+	if *gpu {
+		benchGPU(reg, cs)
+	}
+}
+
+// benchGPU measures the compute shader mesher on the chunks.
+func benchGPU(reg *block.Registry, cs []*world.Chunk) {
+	w, err := render.NewWindow(64, 64, "meshbench")
+	if err != nil {
+		fmt.Println("gpu-compute: cannot open window:", err)
+		return
+	}
+	defer w.Close()
+	m, err := render.NewComputeMesher()
+	if err != nil {
+		fmt.Println("gpu-compute: unavailable:", err)
+		return
+	}
+	defer m.Delete()
+
+	opaque := make([]uint32, reg.Len())
+	for i := range opaque {
+		if reg.Get(block.ID(i)).Opaque {
+			opaque[i] = 1
+		}
+	}
+	dims := [3]int{world.SizeX, world.SizeY, world.SizeZ}
+	blocks := make([]uint32, (dims[0]+2)*(dims[1]+2)*(dims[2]+2))
+	buf := render.NewMeshBuffer(render.PackedLayout, 64<<20)
+	defer buf.Delete()
+
+	start := time.Now()
+	vertices := 0
+	for _, c := range cs {
+		n := 0
+		for y := -1; y <= dims[1]; y++ {
+			for z := -1; z <= dims[2]; z++ {
+				for x := -1; x <= dims[0]; x++ {
+					blocks[n] = 0
+					if world.Inside(x, y, z) {
+						blocks[n] = uint32(c.Get(x, y, z).ID)
+					}
+					n++
+				}
+			}
+		}
+		count, err := m.Mesh(dims, blocks, opaque, buf, 0)
+		if err != nil {
+			fmt.Println("gpu-compute:", err)
+			return
+		}
+		vertices += count
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("gpu-compute: %d chunks, %d vertices, %v total, %v/chunk\n",
+		len(cs), vertices, elapsed, elapsed/time.Duration(len(cs)))
+}
@@ -0,0 +1,235 @@
+// package explosion implements explosions that carve the terrain and push the
+// entities around them.
+//
+// The destroyed blocks are found by casting rays from the center in all
+// directions: each ray starts with a random fraction of the explosion power,
+// and loses power with the distance and with the resistance of the blocks it
+// crosses, destroying the blocks while it has power left. Thick or resistant
+// walls therefore shelter what is behind them.
+package explosion
+
+import (
+	"math"
+	"sort"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/physics"
+	"github.com/ronoaldo/openvoxel/rng"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// rayGrid is the number of rays along each edge of the cube the rays are cast
+// through, for 1352 rays.
+const rayGrid = 16
+
+// rayStep is the length, in blocks, of each step along the rays.
+const rayStep = 0.3
+
+// maxDebris limits the debris returned by an explosion.
+const maxDebris = 64
+
+// World is the voxel storage explosions happen in.
+type World interface {
+	Block(x, y, z int) block.State
+	SetBlock(x, y, z int, s block.State)
+}
+
+// Explosion describes an explosion.
+type Explosion struct {
+	Center glm.Vec3
+	// Power is the strength of the explosion, with rays reaching about
+	// 1.3 * Power blocks in the open air.
+	Power float32
+	// Seed makes the random ray powers and debris reproducible.
+	Seed uint64
+}
+
+// Debris is a piece of a destroyed block thrown by the explosion, to be drawn
+// as a particle.
+type Debris struct {
+	Position, Velocity glm.Vec3
+	Block              block.State
+}
+
+// Result is the outcome of an explosion.
+type Result struct {
+	Explosion
+	// Blocks are the destroyed blocks, ordered by position.
+	Blocks []event.BlockChanged
+	// Chunks are the chunks to mesh again.
+	Chunks []world.ChunkPos
+	// Debris are the pieces thrown by a sample of the destroyed blocks.
+	Debris []Debris
+}
+
+// Explode destroys the blocks reached by the explosion, and publishes a single
+// event.Explosion with all the changes, so the affected chunks are meshed
+// once instead of once per block.
+func Explode(w World, reg *block.Registry, bus *event.Bus, e Explosion) *Result {
+	r := rng.New(rng.Derive(e.Seed, "explosion"))
+	destroyed := map[[3]int]block.State{}
+	for i := 0; i < rayGrid; i++ {
+		for j := 0; j < rayGrid; j++ {
+			for k := 0; k < rayGrid; k++ {
+				if i != 0 && i != rayGrid-1 && j != 0 && j != rayGrid-1 && k != 0 && k != rayGrid-1 {
+					continue
+				}
+				dir := glm.Vec3{
+					float32(i)/(rayGrid-1)*2 - 1,
+					float32(j)/(rayGrid-1)*2 - 1,
+					float32(k)/(rayGrid-1)*2 - 1,
+				}.Normalize()
+				power := e.Power * (0.7 + r.Float32()*0.6)
+				cast(w, reg, e.Center, dir, power, destroyed)
+			}
+		}
+	}
+
+	res := &Result{Explosion: e}
+	keys := make([][3]int, 0, len(destroyed))
+	for p := range destroyed {
+		keys = append(keys, p)
+	}
+	// Sort the blocks, as the map order is random.
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		if a[2] != b[2] {
+			return a[2] < b[2]
+		}
+		return a[0] < b[0]
+	})
+	chunks := map[world.ChunkPos]bool{}
+	air := block.State{ID: block.Air}
+	for n, p := range keys {
+		old := destroyed[p]
+		w.SetBlock(p[0], p[1], p[2], air)
+		res.Blocks = append(res.Blocks, event.BlockChanged{X: p[0], Y: p[1], Z: p[2], Old: old.Pack(), New: air.Pack()})
+		// Neighbor chunks show the faces uncovered at the borders.
+		for _, d := range [][3]int{{0, 0, 0}, {1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}} {
+			chunks[world.ChunkAt(p[0]+d[0], p[1]+d[1], p[2]+d[2])] = true
+		}
+		if len(res.Debris) < maxDebris && (len(keys) <= maxDebris || r.Intn(len(keys)-n) < maxDebris-len(res.Debris)) {
+			res.Debris = append(res.Debris, debris(r, e, p, old))
+		}
+	}
+	for c := range chunks {
+		res.Chunks = append(res.Chunks, c)
+	}
+	sort.Slice(res.Chunks, func(i, j int) bool {
+		a, b := res.Chunks[i], res.Chunks[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		return a.X < b.X
+	})
+
+	ev := event.Explosion{X: e.Center[0], Y: e.Center[1], Z: e.Center[2], Power: e.Power, Blocks: res.Blocks}
+	for _, c := range res.Chunks {
+		ev.Chunks = append(ev.Chunks, [3]int{c.X, c.Y, c.Z})
+	}
+	event.Publish(bus, ev)
+	return res
+}
+
+// cast walks the ray, adding the blocks it destroys.
+func cast(w World, reg *block.Registry, p, dir glm.Vec3, power float32, destroyed map[[3]int]block.State) {
+	step := dir.Mul(rayStep)
+	for ; power > 0; power -= rayStep * 0.75 {
+		b := [3]int{floor(p[0]), floor(p[1]), floor(p[2])}
+		s, seen := destroyed[b]
+		if !seen {
+			s = w.Block(b[0], b[1], b[2])
+		}
+		if s.ID != block.Air {
+			def := reg.Get(s.ID)
+			if def.Hardness < 0 {
+				return
+			}
+			power -= (def.Resistance + 0.3) * rayStep
+			if power > 0 && !seen {
+				destroyed[b] = s
+			}
+		}
+		p = p.Add(step)
+	}
+}
+
+// debris throws a piece of the block at p away from the center.
+func debris(r *rng.Rand, e Explosion, p [3]int, s block.State) Debris {
+	pos := glm.Vec3{float32(p[0]) + r.Float32(), float32(p[1]) + r.Float32(), float32(p[2]) + r.Float32()}
+	dir := pos.Sub(e.Center)
+	if dir.Len() < 0.01 {
+		dir = glm.Vec3{0, 1, 0}
+	}
+	speed := (2 + r.Float32()*4) * e.Power / (dir.Len() + 1)
+	v := dir.Normalize().Mul(speed)
+	v[1] += 2 + r.Float32()*3
+	return Debris{Position: pos, Velocity: v, Block: s}
+}
+
+// Exposure returns the fraction, from 0 to 1, of the box visible from the
+// center of the explosion, sampling a grid of points in the box.
+func (r *Result) Exposure(src physics.ShapeSource, box physics.AABB) float32 {
+	const n = 3
+	seen, total := 0, 0
+	size := box.Max.Sub(box.Min)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			for k := 0; k < n; k++ {
+				p := box.Min.Add(glm.Vec3{
+					size[0] * (float32(i) + 0.5) / n,
+					size[1] * (float32(j) + 0.5) / n,
+					size[2] * (float32(k) + 0.5) / n,
+				})
+				d := p.Sub(r.Center)
+				total++
+				if l := d.Len(); l < 0.01 {
+					seen++
+				} else if _, hit := physics.Raycast(src, r.Center, d.Mul(1/l), l); !hit {
+					seen++
+				}
+			}
+		}
+	}
+	return float32(seen) / float32(total)
+}
+
+// Impact returns the strength, from 0 to 1, of the explosion on the box: it
+// fades to zero at twice the power away from the center, and is reduced by
+// the blocks between them. It can be used to scale the damage taken.
+func (r *Result) Impact(src physics.ShapeSource, box physics.AABB) float32 {
+	center := box.Min.Add(box.Max).Mul(0.5)
+	d := center.Sub(r.Center).Len() / (r.Power * 2)
+	if d >= 1 {
+		return 0
+	}
+	return (1 - d) * r.Exposure(src, box)
+}
+
+// Knockback pushes the controller away from the center of the explosion, and
+// returns the impact on it.
+func (r *Result) Knockback(src physics.ShapeSource, c *physics.Controller) float32 {
+	box := c.Bounds()
+	impact := r.Impact(src, box)
+	if impact <= 0 {
+		return 0
+	}
+	dir := box.Min.Add(box.Max).Mul(0.5).Sub(r.Center)
+	if dir.Len() < 0.01 {
+		dir = glm.Vec3{0, 1, 0}
+	}
+	c.Push(dir.Normalize().Mul(impact * r.Power * 4))
+	return impact
+}
+
+func floor(v float32) int {
+	return int(math.Floor(float64(v)))
+}
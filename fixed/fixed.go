@@ -0,0 +1,228 @@
+// package fixed implements deterministic fixed-point numbers for the
+// simulation, so multiplayer peers and replays compute the same results on all
+// architectures.
+//
+// Go rounds each float32 operation exactly, but the compiler may fuse a
+// multiplication and an addition into a single instruction on some
+// architectures, and math functions such as math.Exp use architecture
+// specific code. Fixed-point numbers are plain integers, which have none of
+// those issues, and can be sent over the network or saved without loss.
+package fixed
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Frac is the number of fractional bits of Num.
+const Frac = 16
+
+// Num is a signed fixed-point number with Frac fractional bits, a precision of
+// 1/65536 and a range of about ±1.4e14.
+type Num int64
+
+const (
+	// One is the number 1.
+	One Num = 1 << Frac
+	// Half is the number 0.5.
+	Half Num = One / 2
+	// Max and Min are the largest and smallest numbers.
+	Max Num = math.MaxInt64
+	Min Num = math.MinInt64
+)
+
+// Int returns the number for the integer i.
+func Int(i int) Num {
+	return Num(i) << Frac
+}
+
+// Float returns the number closest to f. Float and Float32 are exact inverses
+// for the numbers returned by Float32, so a value snapped with Snap is kept
+// when converted back and forth.
+func Float(f float32) Num {
+	return Num(math.Round(float64(f) * float64(One)))
+}
+
+// Float32 returns the float32 closest to n.
+func (n Num) Float32() float32 {
+	return float32(float64(n) / float64(One))
+}
+
+// Float64 returns the float64 closest to n.
+func (n Num) Float64() float64 {
+	return float64(n) / float64(One)
+}
+
+// Floor returns the largest integer not greater than n.
+func (n Num) Floor() int {
+	return int(n >> Frac)
+}
+
+// Round returns the nearest integer, rounding halves up.
+func (n Num) Round() int {
+	return int((n + Half) >> Frac)
+}
+
+// Abs returns the absolute value of n.
+func (n Num) Abs() Num {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Mul returns n * m, rounded to the nearest number.
+func (n Num) Mul(m Num) Num {
+	neg := (n < 0) != (m < 0)
+	hi, lo := bits.Mul64(uint64(n.Abs()), uint64(m.Abs()))
+	lo, carry := bits.Add64(lo, uint64(Half), 0)
+	hi += carry
+	r := Num(hi<<(64-Frac) | lo>>Frac)
+	if neg {
+		return -r
+	}
+	return r
+}
+
+// Div returns n / m, truncated towards zero. It panics if m is zero.
+func (n Num) Div(m Num) Num {
+	if m == 0 {
+		panic("fixed: division by zero")
+	}
+	neg := (n < 0) != (m < 0)
+	a, b := uint64(n.Abs()), uint64(m.Abs())
+	hi, lo := a>>(64-Frac), a<<Frac
+	if hi >= b {
+		// The quotient does not fit.
+		if neg {
+			return Min
+		}
+		return Max
+	}
+	q, _ := bits.Div64(hi, lo, b)
+	if neg {
+		return -Num(q)
+	}
+	return Num(q)
+}
+
+// Sqrt returns the square root of n, or zero for negative numbers.
+func (n Num) Sqrt() Num {
+	if n <= 0 {
+		return 0
+	}
+	if n < 1<<(62-Frac) {
+		return Num(isqrt(uint64(n) << Frac))
+	}
+	return Num(isqrt(uint64(n)) << (Frac / 2))
+}
+
+// isqrt returns the floor of the square root of v.
+func isqrt(v uint64) uint64 {
+	var r uint64
+	bit := uint64(1) << 62
+	for bit > v {
+		bit >>= 2
+	}
+	for bit != 0 {
+		if v >= r+bit {
+			v -= r + bit
+			r = r>>1 + bit
+		} else {
+			r >>= 1
+		}
+		bit >>= 2
+	}
+	return r
+}
+
+// Exp returns e raised to n, saturating at Max.
+func Exp(n Num) Num {
+	const (
+		// q is the precision of the intermediate results, in bits.
+		q    = 30
+		ln2  = 744261118 // ln(2) << q
+		over = 21 * One  // e^21 is larger than 2^30
+	)
+	if n >= over {
+		return Max
+	}
+	if n < -12*One {
+		// e^-12 is smaller than the precision.
+		return 0
+	}
+	// e^n = 2^k * e^r, with |r| <= ln(2)/2.
+	x := int64(n) << (q - Frac)
+	k := (x + ln2/2) / ln2
+	if x+ln2/2 < 0 && (x+ln2/2)%ln2 != 0 {
+		k--
+	}
+	r := x - k*ln2
+	sum, term := int64(1)<<q, int64(1)<<q
+	for i := int64(1); i < 12 && term != 0; i++ {
+		term = term * r >> q / i
+		sum += term
+	}
+	// sum is e^r with q fractional bits, shift it to Frac bits times 2^k.
+	shift := int64(q-Frac) - k
+	if shift > 0 {
+		return Num((sum + 1<<(shift-1)) >> shift)
+	}
+	return Num(sum << -shift)
+}
+
+// Snap rounds f to the closest value representable by Num, converted back to
+// float32. Snapped values are kept by Float and Float32 conversions.
+func Snap(f float32) float32 {
+	return Float(f).Float32()
+}
+
+func (n Num) String() string {
+	return strconv.FormatFloat(n.Float64(), 'f', -1, 64)
+}
+
+// Vec3 is a vector of fixed-point numbers.
+type Vec3 [3]Num
+
+// Vec returns the vector closest to v.
+func Vec(v glm.Vec3) Vec3 {
+	return Vec3{Float(v[0]), Float(v[1]), Float(v[2])}
+}
+
+// Vec returns the float32 vector closest to v.
+func (v Vec3) Vec() glm.Vec3 {
+	return glm.Vec3{v[0].Float32(), v[1].Float32(), v[2].Float32()}
+}
+
+// Add returns v + w.
+func (v Vec3) Add(w Vec3) Vec3 {
+	return Vec3{v[0] + w[0], v[1] + w[1], v[2] + w[2]}
+}
+
+// Sub returns v - w.
+func (v Vec3) Sub(w Vec3) Vec3 {
+	return Vec3{v[0] - w[0], v[1] - w[1], v[2] - w[2]}
+}
+
+// Scale returns v multiplied by s.
+func (v Vec3) Scale(s Num) Vec3 {
+	return Vec3{v[0].Mul(s), v[1].Mul(s), v[2].Mul(s)}
+}
+
+// Dot returns the dot product of v and w.
+func (v Vec3) Dot(w Vec3) Num {
+	return v[0].Mul(w[0]) + v[1].Mul(w[1]) + v[2].Mul(w[2])
+}
+
+// Len returns the length of v.
+func (v Vec3) Len() Num {
+	return v.Dot(v).Sqrt()
+}
+
+// SnapVec rounds each component of v with Snap.
+func SnapVec(v glm.Vec3) glm.Vec3 {
+	return Vec(v).Vec()
+}
@@ -0,0 +1,259 @@
+// package fluid implements a cellular simulation for liquids, such as water and
+// lava, spreading over the voxel terrain.
+//
+// The simulation is finite: each block holds a liquid level from 0 (empty) to
+// MaxLevel (full), and the total volume is preserved as liquid falls, spreads
+// and drains into holes.
+package fluid
+
+// Kind identifies a liquid type. The zero value means no liquid.
+type Kind uint8
+
+const (
+	None Kind = iota
+	Water
+	Lava
+)
+
+// MaxLevel is the liquid level of a full block.
+const MaxLevel = 8
+
+// Properties describes how a liquid behaves.
+type Properties struct {
+	// Interval is the number of ticks between updates of the liquid. Higher
+	// values make the liquid flow slower.
+	Interval int
+}
+
+// DefaultProperties holds the behavior of the builtin liquids.
+var DefaultProperties = map[Kind]Properties{
+	Water: {Interval: 1},
+	Lava:  {Interval: 3},
+}
+
+// World is the voxel storage updated by the simulation.
+type World interface {
+	// Solid returns true if the block can't hold any liquid.
+	Solid(x, y, z int) bool
+	// Fluid returns the liquid kind and level at the position.
+	Fluid(x, y, z int) (Kind, uint8)
+	// SetFluid updates the liquid kind and level at the position. It is
+	// expected to update the block state, so the mesher can rebuild the
+	// surface geometry.
+	SetFluid(x, y, z int, k Kind, level uint8)
+}
+
+type pos struct {
+	x, y, z int
+}
+
+// Simulator keeps the set of active liquid blocks and updates them on each
+// tick.
+type Simulator struct {
+	// MaxUpdates is the maximum number of blocks updated per tick. Blocks
+	// beyond this limit are kept for the next ticks, so the frame time stays
+	// bounded.
+	MaxUpdates int
+	// Active, if set, limits the updates to the blocks it returns true
+	// for, such as the blocks of the chunks near the players. The other
+	// blocks stay queued, in order, until they are active again.
+	Active func(x, y, z int) bool
+
+	world  World
+	props  map[Kind]Properties
+	tick   int
+	queue  []pos
+	queued map[pos]struct{}
+}
+
+// NewSimulator creates a simulator for the world, using DefaultProperties.
+func NewSimulator(w World) *Simulator {
+	return &Simulator{
+		MaxUpdates: 1024,
+		world:      w,
+		props:      DefaultProperties,
+		queued:     map[pos]struct{}{},
+	}
+}
+
+// Schedule marks the block at the position, and its neighbors, to be updated
+// on the next ticks. Call it when a block is placed or removed next to a
+// liquid, or when a new liquid source is placed.
+func (s *Simulator) Schedule(x, y, z int) {
+	s.schedule(pos{x, y, z})
+	for _, n := range neighbors(pos{x, y, z}) {
+		s.schedule(n)
+	}
+}
+
+func (s *Simulator) schedule(p pos) {
+	if _, ok := s.queued[p]; ok {
+		return
+	}
+	s.queued[p] = struct{}{}
+	s.queue = append(s.queue, p)
+}
+
+// Pending returns the number of blocks waiting to be updated.
+func (s *Simulator) Pending() int {
+	return len(s.queue)
+}
+
+// Tick runs a single step of the simulation. It must be called from the fixed
+// timestep update of the game.
+func (s *Simulator) Tick() {
+	s.tick++
+	limit := len(s.queue)
+	if s.MaxUpdates > 0 && limit > s.MaxUpdates {
+		limit = s.MaxUpdates
+	}
+	var batch, waiting []pos
+	for i, p := range s.queue {
+		if len(batch) == limit {
+			waiting = append(waiting, s.queue[i:]...)
+			break
+		}
+		if s.Active != nil && !s.Active(p.x, p.y, p.z) {
+			waiting = append(waiting, p)
+			continue
+		}
+		batch = append(batch, p)
+	}
+	s.queue = waiting
+	for _, p := range batch {
+		delete(s.queued, p)
+	}
+	for _, p := range batch {
+		s.update(p)
+	}
+}
+
+func (s *Simulator) update(p pos) {
+	w := s.world
+	k, level := w.Fluid(p.x, p.y, p.z)
+	if k == None || level == 0 {
+		return
+	}
+	if interval := s.props[k].Interval; interval > 1 && s.tick%interval != 0 {
+		// Not our turn yet, try again later
+		s.schedule(p)
+		return
+	}
+
+	// Fall down first
+	below := pos{p.x, p.y - 1, p.z}
+	if s.accepts(below, k) {
+		_, bl := w.Fluid(below.x, below.y, below.z)
+		flow := min(level, MaxLevel-bl)
+		if flow > 0 {
+			level -= flow
+			w.SetFluid(below.x, below.y, below.z, k, bl+flow)
+			s.set(p, k, level)
+			s.Schedule(below.x, below.y, below.z)
+			s.Schedule(p.x, p.y, p.z)
+			if level == 0 {
+				return
+			}
+		}
+	}
+
+	// Then spread sideways, one unit at a time, towards lower neighbors.
+	changed := false
+	for _, n := range horizontal(p) {
+		if level <= 1 {
+			break
+		}
+		if !s.accepts(n, k) {
+			continue
+		}
+		_, nl := w.Fluid(n.x, n.y, n.z)
+		if nl+1 < level {
+			level--
+			w.SetFluid(n.x, n.y, n.z, k, nl+1)
+			s.Schedule(n.x, n.y, n.z)
+			changed = true
+		}
+	}
+	if changed {
+		s.set(p, k, level)
+		s.Schedule(p.x, p.y, p.z)
+	}
+}
+
+func (s *Simulator) set(p pos, k Kind, level uint8) {
+	if level == 0 {
+		k = None
+	}
+	s.world.SetFluid(p.x, p.y, p.z, k, level)
+}
+
+// accepts returns true if liquid of kind k can flow into p.
+func (s *Simulator) accepts(p pos, k Kind) bool {
+	if s.world.Solid(p.x, p.y, p.z) {
+		return false
+	}
+	nk, nl := s.world.Fluid(p.x, p.y, p.z)
+	return nk == None || nl == 0 || nk == k
+}
+
+func neighbors(p pos) []pos {
+	return []pos{
+		{p.x, p.y + 1, p.z}, {p.x, p.y - 1, p.z},
+		{p.x + 1, p.y, p.z}, {p.x - 1, p.y, p.z},
+		{p.x, p.y, p.z + 1}, {p.x, p.y, p.z - 1},
+	}
+}
+
+func horizontal(p pos) [4]pos {
+	return [4]pos{
+		{p.x + 1, p.y, p.z}, {p.x - 1, p.y, p.z},
+		{p.x, p.y, p.z + 1}, {p.x, p.y, p.z - 1},
+	}
+}
+
+func min(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// SurfaceHeights returns the height of the liquid surface at the four top
+// corners of the block, in the order (-x,-z), (+x,-z), (+x,+z), (-x,+z). The
+// heights are in the range [0, 1], and can be used by the mesher to build the
+// slanted surface geometry.
+func SurfaceHeights(w World, x, y, z int) [4]float32 {
+	k, _ := w.Fluid(x, y, z)
+	return [4]float32{
+		cornerHeight(w, k, x-1, y, z-1),
+		cornerHeight(w, k, x, y, z-1),
+		cornerHeight(w, k, x, y, z),
+		cornerHeight(w, k, x-1, y, z),
+	}
+}
+
+// cornerHeight averages the liquid level of the four blocks starting at x, z
+// that share a vertical edge.
+func cornerHeight(w World, k Kind, x, y, z int) float32 {
+	var sum, count float32
+	for dx := 0; dx <= 1; dx++ {
+		for dz := 0; dz <= 1; dz++ {
+			// Liquid above any of the blocks sharing this corner makes the
+			// corner full.
+			if ak, _ := w.Fluid(x+dx, y+1, z+dz); ak == k {
+				return 1
+			}
+			nk, nl := w.Fluid(x+dx, y, z+dz)
+			if nk == k && nl > 0 {
+				sum += float32(nl) / MaxLevel
+				count++
+			} else if !w.Solid(x+dx, y, z+dz) {
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / count
+}
@@ -0,0 +1,54 @@
+package fluid
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidState is returned when decoding a malformed simulator state.
+var ErrInvalidState = errors.New("fluid: invalid simulator state")
+
+// MarshalBinary encodes the blocks waiting to be updated, such as to rewind
+// the simulation. The liquid levels are stored in the world, so they must be
+// saved with it.
+//
+// The format is the tick count as an int64 and the number of blocks as an
+// uint32, followed by the world coordinates of each block, in the update
+// order, as three int32 values. All values are little endian.
+func (s *Simulator) MarshalBinary() ([]byte, error) {
+	b := binary.LittleEndian.AppendUint64(nil, uint64(int64(s.tick)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s.queue)))
+	for _, p := range s.queue {
+		b = binary.LittleEndian.AppendUint32(b, uint32(int32(p.x)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(int32(p.y)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(int32(p.z)))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces the blocks waiting to be updated with the ones
+// encoded by MarshalBinary.
+func (s *Simulator) UnmarshalBinary(b []byte) error {
+	if len(b) < 12 {
+		return ErrInvalidState
+	}
+	n := int(binary.LittleEndian.Uint32(b[8:]))
+	if len(b) != 12+n*12 {
+		return fmt.Errorf("%w: unexpected size %d", ErrInvalidState, len(b))
+	}
+	s.tick = int(int64(binary.LittleEndian.Uint64(b)))
+	s.queue = make([]pos, n)
+	s.queued = make(map[pos]struct{}, n)
+	for i := range s.queue {
+		v := b[12+i*12:]
+		p := pos{
+			int(int32(binary.LittleEndian.Uint32(v))),
+			int(int32(binary.LittleEndian.Uint32(v[4:]))),
+			int(int32(binary.LittleEndian.Uint32(v[8:]))),
+		}
+		s.queue[i] = p
+		s.queued[p] = struct{}{}
+	}
+	return nil
+}
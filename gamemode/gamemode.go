@@ -0,0 +1,167 @@
+// package gamemode implements the game modes, which select the rules of the
+// game for the local player: flight, instant block breaking, infinite items,
+// collision and damage.
+//
+// In multiplayer the mode is decided by the server: the game changes it with
+// Set when the server tells it to, and the console command asks the server
+// instead of changing it locally.
+package gamemode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ronoaldo/openvoxel/console"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/health"
+	"github.com/ronoaldo/openvoxel/interact"
+	"github.com/ronoaldo/openvoxel/physics"
+)
+
+// ErrUnknownMode is returned when parsing an invalid game mode name.
+var ErrUnknownMode = errors.New("gamemode: unknown mode")
+
+// Mode is a game mode.
+type Mode int
+
+const (
+	// Survival is the default mode, where blocks take time to break,
+	// items run out and the player takes damage.
+	Survival Mode = iota
+	// Creative allows flying, breaking blocks instantly and placing blocks
+	// without running out, without taking damage.
+	Creative
+	// Spectator flies through the blocks without interacting with them.
+	Spectator
+)
+
+var names = [...]string{"survival", "creative", "spectator"}
+
+func (m Mode) String() string {
+	if m < 0 || int(m) >= len(names) {
+		return fmt.Sprintf("mode(%d)", int(m))
+	}
+	return names[m]
+}
+
+// Parse returns the mode with the name, or its first letter.
+func Parse(s string) (Mode, error) {
+	s = strings.ToLower(s)
+	for i, n := range names {
+		if s == n || s == n[:1] {
+			return Mode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %v", ErrUnknownMode, s)
+}
+
+// Rules are the rules of the game enforced by a mode.
+type Rules struct {
+	// Flight allows toggling flight, and ForceFlight keeps flying.
+	Flight, ForceFlight bool
+	// InstantBreak breaks any block on the first hit.
+	InstantBreak bool
+	// InfiniteItems places blocks without consuming them.
+	InfiniteItems bool
+	// Collision stops the player at the blocks.
+	Collision bool
+	// Damage hurts the player.
+	Damage bool
+	// Interact allows breaking, using and placing blocks.
+	Interact bool
+}
+
+// Rules returns the rules of the mode.
+func (m Mode) Rules() Rules {
+	switch m {
+	case Creative:
+		return Rules{Flight: true, InstantBreak: true, InfiniteItems: true, Collision: true, Interact: true}
+	case Spectator:
+		return Rules{Flight: true, ForceFlight: true}
+	}
+	return Rules{Collision: true, Damage: true, Interact: true}
+}
+
+// Apply configures the components of the player with the rules. Any of them
+// can be nil.
+func (r Rules) Apply(c *physics.Controller, i *interact.Interactor, h *health.Health) {
+	if c != nil {
+		if r.ForceFlight {
+			c.Flying = true
+		} else if !r.Flight {
+			c.Flying = false
+		}
+	}
+	if i != nil {
+		i.InstantBreak = r.InstantBreak
+		i.InfiniteItems = r.InfiniteItems
+		if !r.Interact {
+			i.SetButtons(false, false)
+		}
+	}
+	if h != nil {
+		h.Invulnerable = !r.Damage
+	}
+}
+
+// Shapes returns the shapes the player collides with: src, or none if the
+// rules disable collision.
+func (r Rules) Shapes(src physics.ShapeSource) physics.ShapeSource {
+	if r.Collision {
+		return src
+	}
+	return noShapes{}
+}
+
+type noShapes struct{}
+
+func (noShapes) Shape(x, y, z int) []physics.AABB { return nil }
+
+// current is the mode of the local player.
+var current = Survival
+
+// Set changes the mode of the local player and publishes
+// event.GameModeChanged on event.Default.
+func Set(m Mode) {
+	current = m
+	event.Publish(event.Default, event.GameModeChanged{Mode: int(m)})
+}
+
+// Current returns the mode of the local player.
+func Current() Mode {
+	return current
+}
+
+// RegisterCommands adds the gamemode command, which changes the mode with
+// set, such as by asking the server in multiplayer. A nil set uses Set, for
+// single player games.
+func RegisterCommands(r *console.Registry, set func(m Mode) error) {
+	if set == nil {
+		set = func(m Mode) error {
+			Set(m)
+			return nil
+		}
+	}
+	r.Register(console.Command{
+		Name:  "gamemode",
+		Usage: "[survival|creative|spectator]",
+		Help:  "shows or changes the game mode",
+		Handler: func(args []string) (string, error) {
+			if len(args) == 0 {
+				return Current().String(), nil
+			}
+			if len(args) != 1 {
+				return "", fmt.Errorf("usage: gamemode [survival|creative|spectator]")
+			}
+			m, err := Parse(args[0])
+			if err != nil {
+				return "", err
+			}
+			if err := set(m); err != nil {
+				return "", err
+			}
+			return "game mode set to " + m.String(), nil
+		},
+	})
+}
@@ -0,0 +1,135 @@
+// package health implements the health of entities: the damage they take from
+// falls, drowning and mob attacks, their death and their respawn.
+//
+// Health and Breath are entity components, created by the factories of the
+// entity types in the entity.Registry:
+//
+//	r.Register("openvoxel:zombie", func() []entity.Component {
+//		return []entity.Component{health.New(nil, 0, 20), entity.JSON(health.NewBreath())}
+//	})
+//
+// The game systems find them with entity.Get and update them along with the
+// physics.Controller of the entity.
+package health
+
+import (
+	"encoding/json"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/physics"
+)
+
+// Health is the health of an entity, in half hearts. It is an
+// entity.Component, and the entity.Store sets its Entity and Bus when the
+// entity is spawned or loaded.
+type Health struct {
+	// Entity is the ID published in the events.
+	Entity uint64
+	Bus    *event.Bus
+
+	Max, Current float32
+	// Invulnerability is the time, in seconds, damage is ignored after
+	// being hurt, so touching a mob doesn't kill in a few frames.
+	Invulnerability float32
+	// Invulnerable ignores all damage, such as in the creative mode.
+	Invulnerable bool
+
+	immune float32
+}
+
+// savedHealth is the state of a Health saved with its entity.
+type savedHealth struct {
+	Max, Current, Invulnerability float32
+	Invulnerable                  bool
+}
+
+// New creates the health of the entity, starting full.
+func New(b *event.Bus, entity uint64, max float32) *Health {
+	return &Health{
+		Entity:          entity,
+		Bus:             b,
+		Max:             max,
+		Current:         max,
+		Invulnerability: 0.5,
+	}
+}
+
+// Attach sets the entity the health belongs to, and the bus of its events.
+func (h *Health) Attach(entity uint64, b *event.Bus) {
+	h.Entity, h.Bus = entity, b
+}
+
+func (h *Health) MarshalBinary() ([]byte, error) {
+	return json.Marshal(savedHealth{h.Max, h.Current, h.Invulnerability, h.Invulnerable})
+}
+
+func (h *Health) UnmarshalBinary(b []byte) error {
+	var s savedHealth
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	h.Max, h.Current, h.Invulnerability, h.Invulnerable = s.Max, s.Current, s.Invulnerability, s.Invulnerable
+	return nil
+}
+
+// Dead returns true if the health reached zero.
+func (h *Health) Dead() bool {
+	return h.Current <= 0
+}
+
+// Update advances the invulnerability time by dt seconds.
+func (h *Health) Update(dt float32) {
+	if h.immune > 0 {
+		h.immune -= dt
+	}
+}
+
+// Damage hurts the entity, publishing event.Damage so handlers can change or
+// cancel it, and event.Died if the health reaches zero. It returns true if
+// damage was taken.
+func (h *Health) Damage(src event.DamageSource, amount float32) bool {
+	if amount <= 0 || h.Dead() || h.Invulnerable || h.immune > 0 {
+		return false
+	}
+	e := &event.Damage{Entity: h.Entity, Source: src, Amount: amount}
+	event.Publish(h.Bus, e)
+	if e.Cancel || e.Amount <= 0 {
+		return false
+	}
+	h.immune = h.Invulnerability
+	h.set(h.Current - e.Amount)
+	if h.Dead() {
+		event.Publish(h.Bus, event.Died{Entity: h.Entity, Source: src})
+	}
+	return true
+}
+
+// Heal restores the health, up to Max. Dead entities must be respawned
+// instead.
+func (h *Health) Heal(amount float32) {
+	if h.Dead() || amount <= 0 {
+		return
+	}
+	h.set(h.Current + amount)
+}
+
+// Respawn restores the health of the entity and moves its controller to the
+// spawn point, publishing event.Respawned.
+func (h *Health) Respawn(c *physics.Controller, spawn glm.Vec3) {
+	h.immune = 0
+	h.set(h.Max)
+	c.Teleport(spawn)
+	event.Publish(h.Bus, event.Respawned{Entity: h.Entity, X: spawn[0], Y: spawn[1], Z: spawn[2]})
+}
+
+func (h *Health) set(v float32) {
+	switch {
+	case v < 0:
+		v = 0
+	case v > h.Max:
+		v = h.Max
+	}
+	h.Current = v
+	event.Publish(h.Bus, event.HealthChanged{Entity: h.Entity, Health: v, Max: h.Max})
+}
@@ -0,0 +1,79 @@
+package health
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+// Breath is the air held by an entity under water. When it runs out, the
+// entity takes drowning damage at regular intervals.
+type Breath struct {
+	// Max is the time, in seconds, the air lasts, and Air the time left.
+	Max, Air float32
+	// Recovery is how much faster the air is recovered than spent.
+	Recovery float32
+	// Interval is the time, in seconds, between drowning damage, and
+	// Amount the damage taken.
+	Interval, Amount float32
+
+	drowning float32
+}
+
+// NewBreath creates a breath lasting 15 seconds that deals a heart of damage
+// each second after running out.
+func NewBreath() *Breath {
+	return &Breath{Max: 15, Air: 15, Recovery: 5, Interval: 1, Amount: 2}
+}
+
+// Update spends the air if the head of the entity is submerged, or recovers
+// it otherwise, and damages h when drowning.
+func (b *Breath) Update(h *Health, submerged bool, dt float32) {
+	if !submerged {
+		b.Air += dt * b.Recovery
+		if b.Air > b.Max {
+			b.Air = b.Max
+		}
+		b.drowning = 0
+		return
+	}
+	if b.Air > 0 {
+		b.Air -= dt
+		return
+	}
+	b.Air = 0
+	b.drowning -= dt
+	if b.drowning <= 0 {
+		b.drowning = b.Interval
+		h.Damage(event.DamageDrowning, b.Amount)
+	}
+}
+
+// Attack is the melee attack of a mob.
+type Attack struct {
+	// Reach is the distance, in blocks, the target can be hit from.
+	Reach float32
+	// Amount is the damage dealt, and Cooldown the time, in seconds,
+	// between hits.
+	Amount, Cooldown float32
+
+	wait float32
+}
+
+// NewAttack creates a melee attack dealing amount damage once per second.
+func NewAttack(amount float32) *Attack {
+	return &Attack{Reach: 1.5, Amount: amount, Cooldown: 1}
+}
+
+// Update advances the cooldown by dt seconds, and hits the target if it is
+// within reach of the mob, returning true if it did.
+func (a *Attack) Update(mob, target glm.Vec3, h *Health, dt float32) bool {
+	if a.wait > 0 {
+		a.wait -= dt
+		return false
+	}
+	if h.Dead() || mob.Sub(target).Len() > a.Reach {
+		return false
+	}
+	a.wait = a.Cooldown
+	return h.Damage(event.DamageMob, a.Amount)
+}
@@ -0,0 +1,203 @@
+// package i18n translates the engine and game strings.
+//
+// Strings are looked up by their source text, usually in English, as with
+// gettext: a missing translation returns the source text itself, so games can
+// be written first and translated later. Translations are loaded per locale
+// from JSON or gettext PO files, and a locale falls back to its language and
+// then to the fallback locales, so "pt-BR" can use the "pt" translations.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Catalog holds the translations of several locales, and the locale in use.
+// It is safe for concurrent use.
+type Catalog struct {
+	mu        sync.RWMutex
+	tables    map[string]map[string][]string
+	locale    string
+	fallbacks []string
+	chain     []string
+}
+
+// NewCatalog creates an empty catalog using the "en" locale.
+func NewCatalog() *Catalog {
+	c := &Catalog{tables: map[string]map[string][]string{}}
+	c.SetLocale("en")
+	return c
+}
+
+// Default is the catalog used by the engine and by the package functions.
+var Default = NewCatalog()
+
+// Normalize returns the canonical form of a locale name, with the language in
+// lowercase and the region in uppercase, separated by a dash. For example,
+// "pt_br.UTF-8" becomes "pt-BR".
+func Normalize(locale string) string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	parts := strings.Split(strings.ReplaceAll(locale, "_", "-"), "-")
+	parts[0] = strings.ToLower(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if len(parts[i]) == 2 {
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// language returns the language part of a normalized locale.
+func language(locale string) string {
+	if i := strings.IndexByte(locale, '-'); i >= 0 {
+		return locale[:i]
+	}
+	return locale
+}
+
+// SetLocale selects the locale used by the lookups.
+func (c *Catalog) SetLocale(locale string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.locale = Normalize(locale)
+	c.updateChain()
+}
+
+// Locale returns the locale in use.
+func (c *Catalog) Locale() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.locale
+}
+
+// SetFallbacks changes the locales searched, in order, when a string is not
+// translated in the current locale or its language.
+func (c *Catalog) SetFallbacks(locales ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fallbacks = c.fallbacks[:0]
+	for _, l := range locales {
+		c.fallbacks = append(c.fallbacks, Normalize(l))
+	}
+	c.updateChain()
+}
+
+// updateChain computes the locales searched by the lookups. It must be called
+// with the lock held.
+func (c *Catalog) updateChain() {
+	c.chain = c.chain[:0]
+	add := func(l string) {
+		for _, o := range c.chain {
+			if o == l {
+				return
+			}
+		}
+		c.chain = append(c.chain, l)
+	}
+	for _, l := range append([]string{c.locale}, c.fallbacks...) {
+		add(l)
+		add(language(l))
+	}
+}
+
+// Locales returns the locales with translations loaded.
+func (c *Catalog) Locales() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var out []string
+	for l := range c.tables {
+		out = append(out, l)
+	}
+	return out
+}
+
+// Add registers the translation of the source text for the locale. A string
+// with plural forms has one translation per form of the locale plural rule,
+// in the gettext order.
+func (c *Catalog) Add(locale, source string, forms ...string) {
+	locale = Normalize(locale)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.tables[locale]
+	if t == nil {
+		t = map[string][]string{}
+		c.tables[locale] = t
+	}
+	t[source] = forms
+}
+
+// lookup returns the translation of source for the plural count n, searching
+// the locale chain.
+func (c *Catalog) lookup(source string, n int, plural bool) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, l := range c.chain {
+		forms, ok := c.tables[l][source]
+		if !ok || len(forms) == 0 {
+			continue
+		}
+		i := 0
+		if plural {
+			i = PluralRuleFor(l).Select(n)
+		}
+		if i < len(forms) && forms[i] != "" {
+			return forms[i], true
+		}
+		// Use the last form, "other", if the translation is incomplete.
+		if last := forms[len(forms)-1]; last != "" {
+			return last, true
+		}
+	}
+	return "", false
+}
+
+// T returns the translation of the source text. If args are provided, the
+// result is formatted with them as with fmt.Sprintf.
+func (c *Catalog) T(source string, args ...any) string {
+	s, ok := c.lookup(source, 0, false)
+	if !ok {
+		s = source
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(s, args...)
+	}
+	return s
+}
+
+// N returns the translation of the singular or plural source text, selected
+// for the count n by the plural rule of the locale, like gettext ngettext.
+// The result is formatted with args, or with n if no args are provided.
+func (c *Catalog) N(singular, plural string, n int, args ...any) string {
+	s, ok := c.lookup(singular, n, true)
+	if !ok {
+		s = plural
+		if n == 1 {
+			s = singular
+		}
+	}
+	if len(args) == 0 {
+		args = []any{n}
+	}
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	return fmt.Sprintf(s, args...)
+}
+
+// SetLocale selects the locale of the Default catalog.
+func SetLocale(locale string) {
+	Default.SetLocale(locale)
+}
+
+// T translates the source text with the Default catalog.
+func T(source string, args ...any) string {
+	return Default.T(source, args...)
+}
+
+// N translates the singular or plural source text with the Default catalog.
+func N(singular, plural string, n int, args ...any) string {
+	return Default.N(singular, plural, n, args...)
+}
@@ -0,0 +1,161 @@
+package i18n
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Load reads all translation files in the directory dir of fsys, such as an
+// embedded assets directory. Files are named after their locale, like
+// "pt-BR.json" or "fr.po"; other files are ignored.
+func (c *Catalog) Load(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		ext := path.Ext(e.Name())
+		if e.IsDir() || (ext != ".json" && ext != ".po") {
+			continue
+		}
+		locale := strings.TrimSuffix(e.Name(), ext)
+		f, err := fsys.Open(path.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		if ext == ".json" {
+			err = c.LoadJSON(locale, f)
+		} else {
+			err = c.LoadPO(locale, f)
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("i18n: %v: %w", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// LoadJSON reads the translations of the locale from a JSON object mapping the
+// source texts to their translation. Strings with plural forms map to an
+// object with the translation of each plural category of the locale:
+//
+//	{
+//		"Loading world": "Carregando mundo",
+//		"%d player online": {"one": "%d jogador online", "other": "%d jogadores online"}
+//	}
+func (c *Catalog) LoadJSON(locale string, r io.Reader) error {
+	var table map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&table); err != nil {
+		return err
+	}
+	rule := PluralRuleFor(locale)
+	for source, raw := range table {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			c.Add(locale, source, s)
+			continue
+		}
+		var plural map[string]string
+		if err := json.Unmarshal(raw, &plural); err != nil {
+			return fmt.Errorf("invalid translation of %q: %w", source, err)
+		}
+		forms := make([]string, len(rule.Forms))
+		for i, category := range rule.Forms {
+			forms[i] = plural[category]
+		}
+		if other, ok := plural["other"]; ok && forms[len(forms)-1] == "" {
+			forms[len(forms)-1] = other
+		}
+		c.Add(locale, source, forms...)
+	}
+	return nil
+}
+
+// LoadPO reads the translations of the locale from a gettext PO file. The
+// msgstr[n] of plural strings must follow the order of the built-in plural
+// rule of the language, which matches the usual gettext Plural-Forms. Fuzzy
+// and untranslated entries are skipped, and contexts are not supported.
+func (c *Catalog) LoadPO(locale string, r io.Reader) error {
+	var (
+		id, field string
+		forms     []string
+		fuzzy     bool
+		seen      bool
+	)
+	flush := func() {
+		if seen && id != "" && !fuzzy && len(forms) > 0 {
+			translated := false
+			for _, f := range forms {
+				translated = translated || f != ""
+			}
+			if translated {
+				c.Add(locale, id, forms...)
+			}
+		}
+		id, field, forms, fuzzy, seen = "", "", nil, false, false
+	}
+	// appendTo adds the quoted text to the current field.
+	appendTo := func(quoted string) error {
+		s, err := strconv.Unquote(strings.TrimSpace(quoted))
+		if err != nil {
+			return err
+		}
+		switch {
+		case field == "msgid":
+			id += s
+		case strings.HasPrefix(field, "msgstr"):
+			forms[len(forms)-1] += s
+		}
+		return nil
+	}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		var err error
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#,"):
+			if seen {
+				flush()
+			}
+			fuzzy = strings.Contains(line, "fuzzy")
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "msgid_plural "):
+			field = "msgid_plural"
+		case strings.HasPrefix(line, "msgid "):
+			if seen && field != "msgid" {
+				flush()
+			}
+			seen, field = true, "msgid"
+			err = appendTo(line[len("msgid "):])
+		case strings.HasPrefix(line, "msgstr"):
+			field = "msgstr"
+			forms = append(forms, "")
+			i := strings.IndexByte(line, ' ')
+			if i < 0 {
+				return fmt.Errorf("line %d: missing text", n)
+			}
+			err = appendTo(line[i+1:])
+		case strings.HasPrefix(line, `"`):
+			err = appendTo(line)
+		case strings.HasPrefix(line, "msgctxt "):
+			return fmt.Errorf("line %d: contexts are not supported", n)
+		default:
+			return fmt.Errorf("line %d: unexpected %q", n, line)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	flush()
+	return s.Err()
+}
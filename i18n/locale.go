@@ -0,0 +1,16 @@
+//go:build !js
+
+package i18n
+
+import "os"
+
+// DetectLocale returns the user locale from the LC_ALL, LC_MESSAGES and LANG
+// environment variables, or "en" if none is set.
+func DetectLocale() string {
+	for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l := os.Getenv(v); l != "" && l != "C" && l != "POSIX" {
+			return Normalize(l)
+		}
+	}
+	return "en"
+}
@@ -0,0 +1,16 @@
+package i18n
+
+import "syscall/js"
+
+// DetectLocale returns the preferred language of the browser, or "en" if it
+// is not available.
+func DetectLocale() string {
+	nav := js.Global().Get("navigator")
+	if nav.IsUndefined() {
+		return "en"
+	}
+	if l := nav.Get("language"); l.Type() == js.TypeString && l.String() != "" {
+		return Normalize(l.String())
+	}
+	return "en"
+}
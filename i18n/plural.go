@@ -0,0 +1,114 @@
+package i18n
+
+import "sync"
+
+// PluralRule selects the plural form of a language for a count.
+type PluralRule struct {
+	// Forms lists the CLDR plural categories of the language ("zero", "one",
+	// "two", "few", "many" and "other"), in the order of the gettext
+	// msgstr[n] indices.
+	Forms []string
+	// Select returns the index in Forms used for the count n.
+	Select func(n int) int
+}
+
+var (
+	oneOther = PluralRule{[]string{"one", "other"}, func(n int) int {
+		if n == 1 {
+			return 0
+		}
+		return 1
+	}}
+	// zeroOneOther is used by languages where zero is singular, like French
+	// and Brazilian Portuguese.
+	zeroOneOther = PluralRule{[]string{"one", "other"}, func(n int) int {
+		if n == 0 || n == 1 {
+			return 0
+		}
+		return 1
+	}}
+	otherOnly = PluralRule{[]string{"other"}, func(n int) int { return 0 }}
+	// slavic is used by Russian and Ukrainian.
+	slavic = PluralRule{[]string{"one", "few", "many"}, func(n int) int {
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return 1
+		}
+		return 2
+	}}
+	polish = PluralRule{[]string{"one", "few", "many"}, func(n int) int {
+		switch {
+		case n == 1:
+			return 0
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return 1
+		}
+		return 2
+	}}
+	czech = PluralRule{[]string{"one", "few", "other"}, func(n int) int {
+		switch {
+		case n == 1:
+			return 0
+		case n >= 2 && n <= 4:
+			return 1
+		}
+		return 2
+	}}
+	arabic = PluralRule{[]string{"zero", "one", "two", "few", "many", "other"}, func(n int) int {
+		switch {
+		case n == 0:
+			return 0
+		case n == 1:
+			return 1
+		case n == 2:
+			return 2
+		case n%100 >= 3 && n%100 <= 10:
+			return 3
+		case n%100 >= 11:
+			return 4
+		}
+		return 5
+	}}
+)
+
+var (
+	pluralMu    sync.RWMutex
+	pluralRules = map[string]PluralRule{
+		"en": oneOther, "de": oneOther, "nl": oneOther, "sv": oneOther,
+		"da": oneOther, "no": oneOther, "nb": oneOther, "fi": oneOther,
+		"es": oneOther, "it": oneOther, "pt-PT": oneOther, "el": oneOther,
+		"hu": oneOther, "tr": oneOther, "et": oneOther, "bg": oneOther,
+		"fr": zeroOneOther, "pt": zeroOneOther,
+		"ja": otherOnly, "zh": otherOnly, "ko": otherOnly, "vi": otherOnly,
+		"th": otherOnly, "id": otherOnly,
+		"ru": slavic, "uk": slavic, "be": slavic,
+		"pl": polish,
+		"cs": czech, "sk": czech,
+		"ar": arabic,
+	}
+)
+
+// RegisterPluralRule sets the plural rule of a locale or language, replacing
+// the built-in one.
+func RegisterPluralRule(locale string, r PluralRule) {
+	pluralMu.Lock()
+	defer pluralMu.Unlock()
+	pluralRules[Normalize(locale)] = r
+}
+
+// PluralRuleFor returns the plural rule of the locale, or of its language.
+// Unknown languages use the English rule.
+func PluralRuleFor(locale string) PluralRule {
+	locale = Normalize(locale)
+	pluralMu.RLock()
+	defer pluralMu.RUnlock()
+	if r, ok := pluralRules[locale]; ok {
+		return r
+	}
+	if r, ok := pluralRules[language(locale)]; ok {
+		return r
+	}
+	return oneOther
+}
@@ -0,0 +1,112 @@
+package input
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Device is an input device of a local player: the keyboard and mouse, or a
+// gamepad, by its index.
+type Device int
+
+// KeyboardMouse is the keyboard and the mouse, which publish their events on
+// event.Default.
+const KeyboardMouse Device = -1
+
+// Gamepad returns the device of the gamepad with the index, from 0.
+func Gamepad(i int) Device {
+	return Device(i)
+}
+
+func (d Device) String() string {
+	if d == KeyboardMouse {
+		return "keyboard and mouse"
+	}
+	return fmt.Sprintf("gamepad %d", int(d))
+}
+
+// Assignment maps the input devices to the local players of a split screen,
+// so the input of each device moves only the camera of its player. A player
+// can use several devices, and the first player has the KeyboardMouse by
+// default. It is safe for concurrent use.
+type Assignment struct {
+	// Players is the number of local players, such as the number of
+	// render.Viewport of the split screen.
+	Players int
+
+	mu      sync.Mutex
+	devices map[Device]int
+}
+
+// NewAssignment creates an assignment for the players, with the KeyboardMouse
+// assigned to the first one.
+func NewAssignment(players int) *Assignment {
+	return &Assignment{Players: players, devices: map[Device]int{KeyboardMouse: 0}}
+}
+
+// Assign gives the device to the player, replacing its previous player.
+func (a *Assignment) Assign(d Device, player int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.devices == nil {
+		a.devices = map[Device]int{}
+	}
+	a.devices[d] = player
+}
+
+// Unassign removes the device from its player, such as when a gamepad is
+// disconnected.
+func (a *Assignment) Unassign(d Device) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.devices, d)
+}
+
+// Player returns the player of the device, and false if it has none.
+func (a *Assignment) Player(d Device) (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.devices[d]
+	return p, ok
+}
+
+// Devices returns the devices of the player, in increasing order.
+func (a *Assignment) Devices(player int) []Device {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var out []Device
+	for d, p := range a.devices {
+		if p == player {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// Join assigns an unassigned device to the first player without devices,
+// such as when a button is pressed on a new gamepad, and returns the player.
+// It returns false when the device is already assigned or all the players
+// have devices.
+func (a *Assignment) Join(d Device) (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.devices[d]; ok {
+		return 0, false
+	}
+	used := map[int]bool{}
+	for _, p := range a.devices {
+		used[p] = true
+	}
+	for p := 0; p < a.Players; p++ {
+		if !used[p] {
+			if a.devices == nil {
+				a.devices = map[Device]int{}
+			}
+			a.devices[d] = p
+			return p, true
+		}
+	}
+	return 0, false
+}
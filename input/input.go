@@ -0,0 +1,23 @@
+// Package input provides access to input devices beyond the keyboard and
+// mouse events published on event.Default, such as gamepad force feedback,
+// and assigns the devices to the local players of a split screen.
+package input
+
+import "time"
+
+// Rumble vibrates the connected gamepads with strength, from 0 to 1, for the
+// duration. A new call replaces the effect being played, and a zero strength
+// or duration stops it. It does nothing when force feedback is not supported;
+// see RumbleSupported.
+func Rumble(strength float32, duration time.Duration) {
+	if strength < 0 {
+		strength = 0
+	}
+	if strength > 1 {
+		strength = 1
+	}
+	if duration < 0 {
+		duration = 0
+	}
+	rumble(strength, duration)
+}
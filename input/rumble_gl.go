@@ -0,0 +1,15 @@
+//go:build !js
+
+package input
+
+import "time"
+
+// GLFW, up to version 3.4, has no force feedback API, so the desktop build
+// can't vibrate gamepads yet.
+
+// RumbleSupported returns true if a connected gamepad can vibrate.
+func RumbleSupported() bool {
+	return false
+}
+
+func rumble(strength float32, duration time.Duration) {}
@@ -0,0 +1,56 @@
+package input
+
+import (
+	"syscall/js"
+	"time"
+)
+
+// ignore handles the rejected promises of preempted effects. It is shared, as
+// each js.Func must be released.
+var ignore = js.FuncOf(func(js.Value, []js.Value) any { return nil })
+
+// actuators returns the vibration actuators of the connected gamepads, from
+// the Gamepad API.
+func actuators() []js.Value {
+	nav := js.Global().Get("navigator")
+	if nav.IsUndefined() || nav.Get("getGamepads").IsUndefined() {
+		return nil
+	}
+	pads := nav.Call("getGamepads")
+	var a []js.Value
+	for i := 0; i < pads.Length(); i++ {
+		p := pads.Index(i)
+		if p.IsNull() || p.IsUndefined() {
+			continue
+		}
+		if v := p.Get("vibrationActuator"); !v.IsNull() && !v.IsUndefined() {
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+// RumbleSupported returns true if a connected gamepad can vibrate.
+func RumbleSupported() bool {
+	return len(actuators()) > 0
+}
+
+func rumble(strength float32, duration time.Duration) {
+	for _, a := range actuators() {
+		if strength == 0 || duration == 0 {
+			if !a.Get("reset").IsUndefined() {
+				a.Call("reset")
+			}
+			continue
+		}
+		params := js.Global().Get("Object").New()
+		params.Set("duration", duration.Milliseconds())
+		// The strong motor carries most of the effect, with the weak one
+		// adding the high frequency buzz of strong hits.
+		params.Set("strongMagnitude", strength)
+		params.Set("weakMagnitude", strength*strength)
+		// playEffect returns a promise that is rejected when a new effect
+		// preempts this one; catch it to keep the console clean.
+		a.Call("playEffect", "dual-rumble", params).Call("catch", ignore)
+	}
+}
@@ -0,0 +1,162 @@
+package input
+
+import (
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/platform"
+)
+
+// Key codes used by the text fields, as published in event.Key.
+const (
+	keyEnter     = 257
+	keyBackspace = 259
+	keyDelete    = 261
+	keyRight     = 262
+	keyLeft      = 263
+	keyHome      = 268
+	keyEnd       = 269
+	keyKPEnter   = 335
+	keyEscape    = 256
+
+	// modShortcut matches the Control and Super modifiers, used for the
+	// clipboard shortcuts.
+	modShortcut = 0x2 | 0x8
+)
+
+// TextField edits a single line of text from the text input mode events, such
+// as the chat or the console prompt. The window must be in the text input mode
+// while the field is in use; see Window.StartTextInput in the render package.
+type TextField struct {
+	// MaxLength limits the number of runes in the text. Zero is unlimited.
+	MaxLength int
+	// OnSubmit is called with the text when Enter is pressed.
+	OnSubmit func(text string)
+	// OnCancel is called when Escape is pressed.
+	OnCancel func()
+
+	text        []rune
+	cursor      int
+	composition string
+	compCursor  int
+	unsubscribe []func()
+}
+
+// NewTextField creates an empty text field. Call Listen to connect it to the
+// text input events.
+func NewTextField() *TextField {
+	return &TextField{}
+}
+
+// Listen feeds the field with the text input events published on b, until
+// Close is called.
+func (t *TextField) Listen(b *event.Bus) {
+	t.unsubscribe = append(t.unsubscribe,
+		event.Subscribe(b, t.HandleKey),
+		event.Subscribe(b, func(e event.Text) { t.Insert(e.Text) }),
+		event.Subscribe(b, func(e event.TextComposition) {
+			t.composition, t.compCursor = e.Text, e.Cursor
+		}),
+	)
+}
+
+// Close stops listening to the events.
+func (t *TextField) Close() {
+	for _, u := range t.unsubscribe {
+		u()
+	}
+	t.unsubscribe = nil
+}
+
+// Text returns the text entered.
+func (t *TextField) Text() string {
+	return string(t.text)
+}
+
+// SetText replaces the text and moves the cursor to its end.
+func (t *TextField) SetText(s string) {
+	t.text = []rune(s)
+	if t.MaxLength > 0 && len(t.text) > t.MaxLength {
+		t.text = t.text[:t.MaxLength]
+	}
+	t.cursor = len(t.text)
+	t.composition = ""
+}
+
+// Cursor returns the position of the cursor, in runes.
+func (t *TextField) Cursor() int {
+	return t.cursor
+}
+
+// Display returns the text to draw, with the input method composition in
+// progress at the cursor, and the position of the cursor in it, in runes.
+func (t *TextField) Display() (text string, cursor int) {
+	if t.composition == "" {
+		return string(t.text), t.cursor
+	}
+	return string(t.text[:t.cursor]) + t.composition + string(t.text[t.cursor:]), t.cursor + t.compCursor
+}
+
+// Insert adds s at the cursor, dropping the runes beyond MaxLength.
+func (t *TextField) Insert(s string) {
+	r := []rune(s)
+	if t.MaxLength > 0 && len(t.text)+len(r) > t.MaxLength {
+		n := t.MaxLength - len(t.text)
+		if n < 0 {
+			n = 0
+		}
+		r = r[:n]
+	}
+	t.text = append(t.text[:t.cursor], append(r, t.text[t.cursor:]...)...)
+	t.cursor += len(r)
+}
+
+// HandleKey applies the editing keys of the text input mode. Events without
+// the Text flag are game input and are ignored.
+//
+// Control+C copies the whole text and Control+X cuts it, using the platform
+// clipboard. Pasting is done by the window, which publishes the clipboard text
+// as event.Text.
+func (t *TextField) HandleKey(e event.Key) {
+	if !e.Text || e.Action == event.KeyRelease || t.composition != "" {
+		return
+	}
+	if e.Mods&modShortcut != 0 {
+		if e.Action == event.KeyPress && (e.Key == 'C' || e.Key == 'X') && len(t.text) > 0 {
+			platform.ClipboardSet(string(t.text))
+			if e.Key == 'X' {
+				t.SetText("")
+			}
+		}
+		return
+	}
+	switch e.Key {
+	case keyBackspace:
+		if t.cursor > 0 {
+			t.text = append(t.text[:t.cursor-1], t.text[t.cursor:]...)
+			t.cursor--
+		}
+	case keyDelete:
+		if t.cursor < len(t.text) {
+			t.text = append(t.text[:t.cursor], t.text[t.cursor+1:]...)
+		}
+	case keyLeft:
+		if t.cursor > 0 {
+			t.cursor--
+		}
+	case keyRight:
+		if t.cursor < len(t.text) {
+			t.cursor++
+		}
+	case keyHome:
+		t.cursor = 0
+	case keyEnd:
+		t.cursor = len(t.text)
+	case keyEnter, keyKPEnter:
+		if e.Action == event.KeyPress && t.OnSubmit != nil {
+			t.OnSubmit(string(t.text))
+		}
+	case keyEscape:
+		if e.Action == event.KeyPress && t.OnCancel != nil {
+			t.OnCancel()
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package interact
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+// Dialog is a conversation tree said by an NPC when the player uses it. It is
+// driven by events, so the UI and mods only deal with the bus: each node is
+// published as event.DialogLine, and the UI answers with event.DialogChoice.
+//
+// Dialogs are usually loaded with ParseDialog from JSON files:
+//
+//	{"start": "hello", "nodes": {
+//	  "hello": {"speaker": "Bob", "text": "Hi!", "choices": [
+//	    {"text": "Any work?", "next": "quest"},
+//	    {"text": "Bye."}]},
+//	  "quest": {"speaker": "Bob", "text": "Find my cat.", "action": "quest.cat"}}}
+type Dialog struct {
+	Start string                 `json:"start"`
+	Nodes map[string]*DialogNode `json:"nodes"`
+}
+
+// DialogNode is a line of a dialog. Nodes without choices continue to Next,
+// and the dialog ends at nodes without choices nor Next.
+type DialogNode struct {
+	Speaker string         `json:"speaker"`
+	Text    string         `json:"text"`
+	Choices []DialogChoice `json:"choices"`
+	Next    string         `json:"next"`
+	// Action is published as event.DialogAction when the node is said.
+	Action string `json:"action"`
+}
+
+// DialogChoice is an answer to a dialog node.
+type DialogChoice struct {
+	Text string `json:"text"`
+	// Next is the node said after the choice, or empty to end the dialog.
+	Next string `json:"next"`
+	// Action is published as event.DialogAction when the choice is picked.
+	Action string `json:"action"`
+}
+
+// ParseDialog decodes a dialog in the JSON format, checking that all nodes
+// referenced exist.
+func ParseDialog(b []byte) (*Dialog, error) {
+	var d Dialog
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("dialog: %w", err)
+	}
+	check := func(from, to string) error {
+		if _, ok := d.Nodes[to]; to != "" && !ok {
+			return fmt.Errorf("dialog: %q references unknown node %q", from, to)
+		}
+		return nil
+	}
+	if d.Start == "" {
+		return nil, fmt.Errorf("dialog: missing start node")
+	}
+	if err := check("start", d.Start); err != nil {
+		return nil, err
+	}
+	for name, n := range d.Nodes {
+		if err := check(name, n.Next); err != nil {
+			return nil, err
+		}
+		for _, c := range n.Choices {
+			if err := check(name, c.Next); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &d, nil
+}
+
+// Listen starts the dialog when the player uses the entity, and follows the
+// choices published for it. It returns a function that stops listening.
+func (d *Dialog) Listen(b *event.Bus, entity uint64) (unsubscribe func()) {
+	var current *DialogNode
+	say := func(name string) {
+		current = d.Nodes[name]
+		if current == nil {
+			event.Publish(b, event.DialogEnd{Entity: entity})
+			return
+		}
+		choices := make([]string, len(current.Choices))
+		for i, c := range current.Choices {
+			choices[i] = c.Text
+		}
+		event.Publish(b, event.DialogLine{Entity: entity, Speaker: current.Speaker, Text: current.Text, Choices: choices})
+		if current.Action != "" {
+			event.Publish(b, event.DialogAction{Entity: entity, Action: current.Action})
+		}
+	}
+	stopUse := event.Subscribe(b, func(e *event.EntityUse) {
+		if e.Entity != entity || e.Handled {
+			return
+		}
+		e.Handled = true
+		if current == nil {
+			say(d.Start)
+		}
+	})
+	stopChoice := event.Subscribe(b, func(e event.DialogChoice) {
+		if e.Entity != entity || current == nil {
+			return
+		}
+		n := current
+		switch {
+		case e.Choice < 0:
+			say("")
+		case len(n.Choices) == 0:
+			say(n.Next)
+		case e.Choice < len(n.Choices):
+			c := n.Choices[e.Choice]
+			if c.Action != "" {
+				event.Publish(b, event.DialogAction{Entity: entity, Action: c.Action})
+			}
+			say(c.Next)
+		}
+	})
+	return func() {
+		stopUse()
+		stopChoice()
+	}
+}
@@ -0,0 +1,273 @@
+// package interact implements how the local player interacts with the world:
+// targeting blocks within reach, breaking them over time according to their
+// hardness, using blocks and entities and placing blocks against them, and
+// the trigger volumes and NPC dialogs used by adventure maps.
+//
+// Every interaction is published on the event bus before it is applied, so
+// mods can intercept it.
+package interact
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/physics"
+)
+
+// World is the voxel storage the player interacts with.
+type World interface {
+	Block(x, y, z int) block.State
+	SetBlock(x, y, z int, s block.State)
+}
+
+// Interactor applies the attack and use actions of the local player. Attack
+// is the left mouse button and use the right one, tracked by Listen.
+type Interactor struct {
+	Registry *block.Registry
+	World    World
+	Bus      *event.Bus
+
+	// Reach is the distance, in blocks, of the blocks that can be targeted.
+	Reach float32
+	// UseCooldown is the time, in seconds, between repeated uses or
+	// placements while the button is held, and BreakCooldown the pause
+	// after breaking a block before the next one starts breaking.
+	UseCooldown, BreakCooldown float32
+	// Held is the block placed by the use action. Air places nothing.
+	Held block.State
+	// Occupied returns true if an entity overlaps the box, to prevent
+	// placing blocks inside players and mobs. A nil function allows it.
+	Occupied func(box physics.AABB) bool
+	// Consume is called after placing the held block, to take it from the
+	// inventory, and returns false when there are none left, which clears
+	// Held. A nil function never runs out.
+	Consume func(s block.State) bool
+
+	// Entities returns the closest entity hit by the ray from origin along
+	// dir within max distance, so the player can use entities, such as to
+	// talk to NPCs. A nil function ignores the entities.
+	Entities func(origin, dir glm.Vec3, max float32) (id uint64, ok bool)
+
+	// InstantBreak breaks any block on the first hit, including the
+	// unbreakable ones, and InfiniteItems places blocks without consuming
+	// them. Both are set by the creative mode.
+	InstantBreak, InfiniteItems bool
+
+	attack, use bool
+
+	target        [3]int
+	breaking      bool
+	progress      float32
+	useCooldown   float32
+	breakCooldown float32
+}
+
+// New creates an interactor for the world, publishing the interactions on
+// the bus.
+func New(r *block.Registry, w World, b *event.Bus) *Interactor {
+	return &Interactor{
+		Registry:      r,
+		World:         w,
+		Bus:           b,
+		Reach:         5,
+		UseCooldown:   0.25,
+		BreakCooldown: 0.25,
+	}
+}
+
+// Listen tracks the mouse buttons published on the bus. It returns a function
+// that stops listening.
+func (i *Interactor) Listen(b *event.Bus) (unsubscribe func()) {
+	return event.Subscribe(b, func(e event.MouseButton) {
+		pressed := e.Action != event.KeyRelease
+		switch e.Button {
+		case event.MouseLeft:
+			i.attack = pressed
+		case event.MouseRight:
+			i.use = pressed
+			if !pressed {
+				// Clicking again uses right away.
+				i.useCooldown = 0
+			}
+		}
+	})
+}
+
+// SetButtons sets the state of the attack and use actions, for games that map
+// them to other inputs.
+func (i *Interactor) SetButtons(attack, use bool) {
+	i.attack = attack
+	if !use {
+		i.useCooldown = 0
+	}
+	i.use = use
+}
+
+// Shape returns the shape the blocks are targeted with: their collision
+// shape, or a full cube for blocks without collision, such as flowers. Air and
+// liquids can't be targeted.
+func (i *Interactor) Shape(x, y, z int) []physics.AABB {
+	s := i.World.Block(x, y, z)
+	if s.ID == block.Air {
+		return nil
+	}
+	def := i.Registry.Get(s.ID)
+	if def.Collision != nil {
+		return def.Collision
+	}
+	if def.Medium == physics.MediumLiquid {
+		return nil
+	}
+	return physics.FullCube
+}
+
+// Target returns the block targeted from the eye along dir, within reach.
+func (i *Interactor) Target(eye, dir glm.Vec3) (physics.RayHit, bool) {
+	return physics.Raycast(i, eye, dir, i.Reach)
+}
+
+// Update applies the actions for the player looking from eye along dir, after
+// dt seconds. It must be called once per simulation step.
+func (i *Interactor) Update(eye, dir glm.Vec3, dt float32) {
+	hit, ok := i.Target(eye, dir)
+	i.updateBreak(hit, ok, dt)
+
+	i.useCooldown -= dt
+	if !i.use || i.useCooldown > 0 {
+		return
+	}
+	max := i.Reach
+	if ok {
+		max = hit.Distance
+	}
+	if i.Entities != nil {
+		if id, hitEntity := i.Entities(eye, dir, max); hitEntity {
+			i.useCooldown = i.UseCooldown
+			if i.useEntity(id) {
+				return
+			}
+		}
+	}
+	if ok {
+		i.useCooldown = i.UseCooldown
+		i.useBlock(hit)
+	}
+}
+
+// useEntity publishes event.EntityUse and returns true if it was handled.
+func (i *Interactor) useEntity(id uint64) bool {
+	use := &event.EntityUse{Entity: id}
+	event.Publish(i.Bus, use)
+	if use.Handled {
+		event.Publish(i.Bus, event.PlayerAction{Action: event.ActionUse})
+	}
+	return use.Handled
+}
+
+func (i *Interactor) stopBreaking() {
+	if i.breaking {
+		t := i.target
+		event.Publish(i.Bus, event.BlockBreaking{X: t[0], Y: t[1], Z: t[2], Progress: -1})
+	}
+	i.breaking = false
+	i.progress = 0
+}
+
+func (i *Interactor) updateBreak(hit physics.RayHit, ok bool, dt float32) {
+	if i.breakCooldown > 0 {
+		i.breakCooldown -= dt
+	}
+	if !i.attack || !ok || (i.breaking && hit.Block != i.target) {
+		i.stopBreaking()
+		if !i.attack || !ok {
+			return
+		}
+	}
+	if i.breakCooldown > 0 {
+		return
+	}
+	p := hit.Block
+	s := i.World.Block(p[0], p[1], p[2])
+	hardness := i.Registry.Get(s.ID).Hardness
+	if i.InstantBreak {
+		hardness = 0
+	}
+	if hardness < 0 {
+		return
+	}
+	if !i.breaking {
+		i.breaking, i.target = true, p
+		event.Publish(i.Bus, event.PlayerAction{Action: event.ActionAttack})
+	}
+	if hardness == 0 {
+		i.progress = 1
+	} else {
+		i.progress += dt / hardness
+	}
+	if i.progress < 1 {
+		event.Publish(i.Bus, event.BlockBreaking{X: p[0], Y: p[1], Z: p[2], Progress: i.progress})
+		return
+	}
+
+	i.stopBreaking()
+	i.breakCooldown = i.BreakCooldown
+	e := &event.BlockBreak{X: p[0], Y: p[1], Z: p[2], Block: s.Pack()}
+	event.Publish(i.Bus, e)
+	if !e.Cancel {
+		i.set(p, block.State{ID: block.Air})
+	}
+}
+
+func (i *Interactor) useBlock(hit physics.RayHit) {
+	p := hit.Block
+	s := i.World.Block(p[0], p[1], p[2])
+	use := &event.BlockUse{X: p[0], Y: p[1], Z: p[2], Block: s.Pack()}
+	event.Publish(i.Bus, use)
+	if use.Handled {
+		event.Publish(i.Bus, event.PlayerAction{Action: event.ActionUse})
+		return
+	}
+	if i.Held.ID == block.Air {
+		return
+	}
+	p = [3]int{p[0] + hit.Normal[0], p[1] + hit.Normal[1], p[2] + hit.Normal[2]}
+	if !i.CanPlace(p, i.Held) {
+		return
+	}
+	place := &event.BlockPlace{X: p[0], Y: p[1], Z: p[2], Block: i.Held.Pack()}
+	event.Publish(i.Bus, place)
+	if place.Cancel {
+		return
+	}
+	i.set(p, i.Held)
+	event.Publish(i.Bus, event.PlayerAction{Action: event.ActionPlace})
+	if !i.InfiniteItems && i.Consume != nil && !i.Consume(i.Held) {
+		i.Held = block.State{ID: block.Air}
+	}
+}
+
+// CanPlace returns true if the block s can be placed at p: the position must
+// hold air or a liquid, and the block must not collide with an entity.
+func (i *Interactor) CanPlace(p [3]int, s block.State) bool {
+	old := i.World.Block(p[0], p[1], p[2])
+	if old.ID != block.Air && i.Registry.Get(old.ID).Medium != physics.MediumLiquid {
+		return false
+	}
+	if i.Occupied == nil {
+		return true
+	}
+	base := glm.Vec3{float32(p[0]), float32(p[1]), float32(p[2])}
+	for _, b := range i.Registry.Get(s.ID).Collision {
+		if i.Occupied(b.Offset(base)) {
+			return false
+		}
+	}
+	return true
+}
+
+// set changes the block and publishes event.BlockChanged.
+func (i *Interactor) set(p [3]int, s block.State) {
+	old := i.World.Block(p[0], p[1], p[2])
+	i.World.SetBlock(p[0], p[1], p[2], s)
+	event.Publish(i.Bus, event.BlockChanged{X: p[0], Y: p[1], Z: p[2], Old: old.Pack(), New: s.Pack()})
+}
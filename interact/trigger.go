@@ -0,0 +1,74 @@
+package interact
+
+import (
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/physics"
+)
+
+// Trigger is a region of the world that publishes event.TriggerEnter and
+// event.TriggerExit when entities enter and leave it, such as to start a
+// cutscene in an adventure map.
+type Trigger struct {
+	Name string
+	Box  physics.AABB
+	// Once removes the trigger after the first entity enters it.
+	Once bool
+}
+
+// Triggers tracks the entities inside the trigger volumes.
+type Triggers struct {
+	Bus *event.Bus
+
+	triggers map[string]*Trigger
+	// inside holds the entities inside each trigger.
+	inside map[string]map[uint64]bool
+}
+
+// NewTriggers creates an empty set of triggers publishing on the bus.
+func NewTriggers(b *event.Bus) *Triggers {
+	return &Triggers{
+		Bus:      b,
+		triggers: map[string]*Trigger{},
+		inside:   map[string]map[uint64]bool{},
+	}
+}
+
+// Add adds the trigger, replacing any trigger with the same name.
+func (t *Triggers) Add(tr *Trigger) {
+	t.triggers[tr.Name] = tr
+	t.inside[tr.Name] = map[uint64]bool{}
+}
+
+// Remove deletes the trigger without publishing exit events.
+func (t *Triggers) Remove(name string) {
+	delete(t.triggers, name)
+	delete(t.inside, name)
+}
+
+// Update checks the bounding box of the entity against the triggers, and
+// publishes the events for the ones it entered or left since the last update.
+func (t *Triggers) Update(entity uint64, box physics.AABB) {
+	for name, tr := range t.triggers {
+		in := tr.Box.Intersects(box)
+		was := t.inside[name][entity]
+		switch {
+		case in && !was:
+			t.inside[name][entity] = true
+			event.Publish(t.Bus, event.TriggerEnter{Trigger: name, Entity: entity})
+			if tr.Once {
+				t.Remove(name)
+			}
+		case !in && was:
+			delete(t.inside[name], entity)
+			event.Publish(t.Bus, event.TriggerExit{Trigger: name, Entity: entity})
+		}
+	}
+}
+
+// Forget removes the entity from the triggers, such as when it despawns,
+// without publishing exit events.
+func (t *Triggers) Forget(entity uint64) {
+	for _, in := range t.inside {
+		delete(in, entity)
+	}
+}
@@ -0,0 +1,54 @@
+// Package wasmpage provides the files needed to run the wasm builds of the
+// programs in a browser. It is shared by the development server and the
+// packaging tool.
+package wasmpage
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed index.html
+var indexHTML string
+
+var indexTmpl = template.Must(template.New("index").Parse(indexHTML))
+
+// Page describes the index.html page of a program.
+type Page struct {
+	// Name is the page title.
+	Name string
+	// Wasm is the URL of the wasm binary, relative to the page.
+	Wasm string
+	// Extra is included at the end of the page body.
+	Extra template.HTML
+}
+
+// Write renders the page. It expects wasm_exec.js and worker.js to be served
+// next to it.
+func Write(w io.Writer, p Page) error {
+	return indexTmpl.Execute(w, p)
+}
+
+// ExecJS returns the path of the wasm_exec.js support file that matches the
+// Go toolchain in the PATH.
+func ExecJS() (string, error) {
+	b, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return "", err
+	}
+	root := strings.TrimSpace(string(b))
+	// Go 1.24 moved the file from misc/wasm to lib/wasm.
+	for _, dir := range []string{"lib/wasm", "misc/wasm"} {
+		p := filepath.Join(root, dir, "wasm_exec.js")
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("wasm_exec.js not found in %v", root)
+}
@@ -0,0 +1,246 @@
+// package job implements a worker pool used to run engine workloads in the
+// background, such as the meshing and world generation tasks of worker.Pool on
+// native builds, structure baking and terrain previews.
+package job
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Job is a unit of work submitted to a Scheduler.
+type Job struct {
+	s     *Scheduler
+	fn    func()
+	frame bool
+
+	// Dependency tracking, protected by the scheduler mutex.
+	pending    int
+	dependents []*Job
+	finished   bool
+
+	queuedAt time.Time
+	done     chan struct{}
+}
+
+// Default is the scheduler shared by the engine workloads, such as the worker
+// pools, structure baking and the terrain previews, with one worker per
+// logical processor. It is never closed.
+var Default = NewScheduler(0)
+
+// Wait blocks until the job has finished running.
+func (j *Job) Wait() {
+	<-j.done
+}
+
+// Done returns a channel that is closed when the job finishes.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Stats reports instrumentation data about the scheduler.
+type Stats struct {
+	Queued    int
+	Running   int
+	Completed uint64
+
+	// Queue latency is the time a job waited, after all its dependencies
+	// finished, until a worker picked it up.
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+// Scheduler runs jobs on a fixed pool of worker goroutines.
+//
+// Jobs can depend on other jobs, and will only be queued after all of their
+// dependencies finished. Frame jobs are always picked before background jobs,
+// and can be waited for with WaitFrame.
+type Scheduler struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	frameQueue      []*Job
+	backgroundQueue []*Job
+	frameJobs       sync.WaitGroup
+	// waiting are the jobs whose dependencies did not finish yet.
+	waiting map[*Job]struct{}
+	closed  bool
+	workers sync.WaitGroup
+
+	running      int
+	completed    uint64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+// NewScheduler starts a scheduler with the provided number of workers. If
+// workers is zero or negative, runtime.GOMAXPROCS is used.
+func NewScheduler(workers int) *Scheduler {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	s := &Scheduler{waiting: map[*Job]struct{}{}}
+	s.cond = sync.NewCond(&s.mu)
+	s.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// Submit queues fn to run in background after all deps have finished. The deps
+// must have been submitted to the same scheduler.
+func (s *Scheduler) Submit(fn func(), deps ...*Job) *Job {
+	return s.submit(fn, false, deps)
+}
+
+// SubmitFrame queues fn to run after all deps have finished, with priority
+// over background jobs. Frame jobs are expected to finish within the current
+// frame; use WaitFrame to wait for them.
+func (s *Scheduler) SubmitFrame(fn func(), deps ...*Job) *Job {
+	return s.submit(fn, true, deps)
+}
+
+func (s *Scheduler) submit(fn func(), frame bool, deps []*Job) *Job {
+	for _, d := range deps {
+		if d != nil && d.s != s {
+			panic("job: dependency submitted to another Scheduler")
+		}
+	}
+	j := &Job{
+		s:     s,
+		fn:    fn,
+		frame: frame,
+		done:  make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		panic("job: Submit called on a closed Scheduler")
+	}
+	if frame {
+		s.frameJobs.Add(1)
+	}
+	for _, d := range deps {
+		if d != nil && !d.finished {
+			j.pending++
+			d.dependents = append(d.dependents, j)
+		}
+	}
+	if j.pending == 0 {
+		s.enqueue(j)
+	} else {
+		s.waiting[j] = struct{}{}
+	}
+	return j
+}
+
+// enqueue adds a ready job to the proper queue. Must be called with s.mu held.
+func (s *Scheduler) enqueue(j *Job) {
+	delete(s.waiting, j)
+	j.queuedAt = time.Now()
+	if j.frame {
+		s.frameQueue = append(s.frameQueue, j)
+	} else {
+		s.backgroundQueue = append(s.backgroundQueue, j)
+	}
+	s.cond.Signal()
+}
+
+// WaitFrame blocks until all frame jobs submitted so far have finished.
+func (s *Scheduler) WaitFrame() {
+	s.frameJobs.Wait()
+}
+
+// Stats returns a snapshot of the scheduler instrumentation.
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Stats{
+		Queued:     len(s.frameQueue) + len(s.backgroundQueue),
+		Running:    s.running,
+		Completed:  s.completed,
+		MaxLatency: s.maxLatency,
+	}
+	if s.completed > 0 {
+		st.AvgLatency = s.totalLatency / time.Duration(s.completed)
+	}
+	return st
+}
+
+// Close waits for all queued jobs to finish and stops the workers. Jobs still
+// waiting for dependencies that never run are discarded: they are marked done
+// without running, so Wait and WaitFrame return.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	s.workers.Wait()
+
+	s.mu.Lock()
+	discarded := s.waiting
+	s.waiting = map[*Job]struct{}{}
+	s.mu.Unlock()
+	for j := range discarded {
+		close(j.done)
+		if j.frame {
+			s.frameJobs.Done()
+		}
+	}
+}
+
+func (s *Scheduler) work() {
+	defer s.workers.Done()
+	for {
+		s.mu.Lock()
+		for len(s.frameQueue) == 0 && len(s.backgroundQueue) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		var j *Job
+		switch {
+		case len(s.frameQueue) > 0:
+			j, s.frameQueue = s.frameQueue[0], s.frameQueue[1:]
+		case len(s.backgroundQueue) > 0:
+			j, s.backgroundQueue = s.backgroundQueue[0], s.backgroundQueue[1:]
+		default:
+			// Closed and nothing else to run
+			s.mu.Unlock()
+			return
+		}
+		latency := time.Since(j.queuedAt)
+		s.totalLatency += latency
+		if latency > s.maxLatency {
+			s.maxLatency = latency
+		}
+		s.running++
+		s.mu.Unlock()
+
+		s.run(j)
+	}
+}
+
+func (s *Scheduler) run(j *Job) {
+	defer func() {
+		s.mu.Lock()
+		s.running--
+		s.completed++
+		j.finished = true
+		for _, d := range j.dependents {
+			d.pending--
+			if d.pending == 0 {
+				s.enqueue(d)
+			}
+		}
+		j.dependents = nil
+		s.mu.Unlock()
+
+		close(j.done)
+		if j.frame {
+			s.frameJobs.Done()
+		}
+	}()
+	j.fn()
+}
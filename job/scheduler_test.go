@@ -0,0 +1,44 @@
+package job
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCloseFinishesFrameJobs(t *testing.T) {
+	s := NewScheduler(2)
+	var ran atomic.Int32
+	var last *Job
+	for i := 0; i < 100; i++ {
+		last = s.SubmitFrame(func() { ran.Add(1) }, last)
+	}
+	s.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.WaitFrame()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitFrame did not return after Close")
+	}
+	if n := ran.Load(); n != 100 {
+		t.Errorf("ran %d jobs, want 100", n)
+	}
+}
+
+func TestForeignDependency(t *testing.T) {
+	a, b := NewScheduler(1), NewScheduler(1)
+	defer a.Close()
+	defer b.Close()
+	dep := a.Submit(func() {})
+	defer func() {
+		if recover() == nil {
+			t.Error("Submit accepted a dependency from another scheduler")
+		}
+	}()
+	b.Submit(func() {}, dep)
+}
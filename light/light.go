@@ -0,0 +1,212 @@
+// package light implements flood-fill voxel lighting with colored light
+// sources.
+//
+// Each block stores a light value per color channel, from 0 to MaxLevel. Light
+// sources emit light that spreads to the neighbor blocks, losing one level per
+// block, independently for the red, green and blue channels. This allows lava,
+// torches and glowstone to tint their surroundings.
+package light
+
+// MaxLevel is the maximum light level of each channel.
+const MaxLevel = 15
+
+// Color is a light value packed as 4 bits per red, green and blue channels.
+type Color uint16
+
+// RGB creates a light color. Channel values above MaxLevel are clamped.
+func RGB(r, g, b uint8) Color {
+	return Color(clamp(r))<<8 | Color(clamp(g))<<4 | Color(clamp(b))
+}
+
+func clamp(v uint8) uint8 {
+	if v > MaxLevel {
+		return MaxLevel
+	}
+	return v
+}
+
+// R returns the red channel level.
+func (c Color) R() uint8 { return uint8(c>>8) & MaxLevel }
+
+// G returns the green channel level.
+func (c Color) G() uint8 { return uint8(c>>4) & MaxLevel }
+
+// B returns the blue channel level.
+func (c Color) B() uint8 { return uint8(c) & MaxLevel }
+
+// channel returns the level of the i-th channel: 0 for red, 1 for green and 2
+// for blue.
+func (c Color) channel(i int) uint8 {
+	return uint8(c>>(8-4*i)) & MaxLevel
+}
+
+func (c Color) withChannel(i int, v uint8) Color {
+	shift := 8 - 4*i
+	return c&^(MaxLevel<<shift) | Color(v)<<shift
+}
+
+// Max returns the per channel maximum of both colors.
+func (c Color) Max(o Color) Color {
+	r := c
+	for i := 0; i < 3; i++ {
+		if o.channel(i) > r.channel(i) {
+			r = r.withChannel(i, o.channel(i))
+		}
+	}
+	return r
+}
+
+// Floats returns the color channels normalized to the range [0, 1], to be used
+// as vertex attributes.
+func (c Color) Floats() (r, g, b float32) {
+	return float32(c.R()) / MaxLevel, float32(c.G()) / MaxLevel, float32(c.B()) / MaxLevel
+}
+
+// Common light source colors.
+var (
+	Torch     = RGB(14, 12, 8)
+	Lava      = RGB(15, 8, 2)
+	Glowstone = RGB(15, 14, 10)
+)
+
+// Volume is the voxel storage lit by the propagation.
+type Volume interface {
+	// Opaque returns true if the block doesn't let light through.
+	Opaque(x, y, z int) bool
+	// Emission returns the light emitted by the block.
+	Emission(x, y, z int) Color
+	// Light returns the current light value of the block.
+	Light(x, y, z int) Color
+	// SetLight stores the light value of the block.
+	SetLight(x, y, z int, c Color)
+}
+
+type node struct {
+	x, y, z int
+}
+
+type removal struct {
+	node
+	level Color
+}
+
+var offsets = [6][3]int{
+	{1, 0, 0}, {-1, 0, 0},
+	{0, 1, 0}, {0, -1, 0},
+	{0, 0, 1}, {0, 0, -1},
+}
+
+// Propagator runs the breadth-first light propagation over a Volume.
+//
+// Call AddSource or Remove for every block changed, then Propagate to update
+// the light of the affected blocks.
+type Propagator struct {
+	v       Volume
+	add     []node
+	removes []removal
+}
+
+// NewPropagator creates a propagator for the volume.
+func NewPropagator(v Volume) *Propagator {
+	return &Propagator{v: v}
+}
+
+// AddSource queues the block at the position to have its emission spread to
+// its neighbors.
+func (p *Propagator) AddSource(x, y, z int) {
+	c := p.v.Light(x, y, z).Max(p.v.Emission(x, y, z))
+	p.v.SetLight(x, y, z, c)
+	p.add = append(p.add, node{x, y, z})
+}
+
+// Remove queues the light at the position to be removed, for instance when a
+// light source is broken or an opaque block is placed. Neighbor light that was
+// not coming from this block is spread again into the dark area.
+func (p *Propagator) Remove(x, y, z int) {
+	p.removes = append(p.removes, removal{node{x, y, z}, p.v.Light(x, y, z)})
+	p.v.SetLight(x, y, z, 0)
+}
+
+// Propagate processes all queued changes.
+func (p *Propagator) Propagate() {
+	for p.Step(1 << 30) {
+	}
+}
+
+// Step processes up to n queued blocks, and returns true if there are blocks
+// left. It spreads large changes, such as relighting whole chunks, over
+// several ticks.
+func (p *Propagator) Step(n int) bool {
+	for ; n > 0; n-- {
+		switch {
+		case len(p.removes) > 0:
+			// Removals must finish before the light is spread again.
+			r := p.removes[0]
+			p.removes = p.removes[1:]
+			p.remove(r)
+		case len(p.add) > 0:
+			a := p.add[0]
+			p.add = p.add[1:]
+			p.spread(a)
+		default:
+			return false
+		}
+	}
+	return p.Pending() > 0
+}
+
+// Pending returns the number of blocks waiting to be processed.
+func (p *Propagator) Pending() int {
+	return len(p.removes) + len(p.add)
+}
+
+func (p *Propagator) remove(r removal) {
+	v := p.v
+	for _, o := range offsets {
+		n := node{r.x + o[0], r.y + o[1], r.z + o[2]}
+		nc := v.Light(n.x, n.y, n.z)
+		cleared, removed := nc, Color(0)
+		for i := 0; i < 3; i++ {
+			level, nl := r.level.channel(i), nc.channel(i)
+			if nl != 0 && nl < level {
+				// This light came from the removed block
+				cleared = cleared.withChannel(i, 0)
+				removed = removed.withChannel(i, nl)
+			}
+		}
+		if removed != 0 {
+			v.SetLight(n.x, n.y, n.z, cleared)
+			p.removes = append(p.removes, removal{n, removed})
+		}
+		if cleared != 0 {
+			// Brighter light from another source, spread it again.
+			p.add = append(p.add, n)
+		}
+	}
+	// Re-emit the light of sources that were inside the removed area.
+	if e := v.Emission(r.x, r.y, r.z); e != 0 {
+		p.AddSource(r.x, r.y, r.z)
+	}
+}
+
+func (p *Propagator) spread(n node) {
+	v := p.v
+	c := v.Light(n.x, n.y, n.z)
+	for _, o := range offsets {
+		nx, ny, nz := n.x+o[0], n.y+o[1], n.z+o[2]
+		if v.Opaque(nx, ny, nz) {
+			continue
+		}
+		nc := v.Light(nx, ny, nz)
+		updated := nc
+		for i := 0; i < 3; i++ {
+			if l := c.channel(i); l > 1 && nc.channel(i) < l-1 {
+				updated = updated.withChannel(i, l-1)
+			}
+		}
+		if updated != nc {
+			v.SetLight(nx, ny, nz, updated)
+			p.add = append(p.add, node{nx, ny, nz})
+		}
+	}
+}
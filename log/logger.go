@@ -3,6 +3,7 @@ package log
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -13,6 +14,17 @@ const (
 	LevelError = "ERROR"
 )
 
+// HistorySize is the number of recent log lines kept in memory, to be
+// included in crash reports.
+const HistorySize = 200
+
+var history = struct {
+	sync.Mutex
+	lines [HistorySize]string
+	next  int
+	full  bool
+}{}
+
 // Debugf prints a log message with DEBUG level
 func Debugf(message string, args ...interface{}) {
 	printf(LevelDebug, message, args...)
@@ -33,6 +45,26 @@ func Errorf(message string, args ...interface{}) {
 	printf(LevelError, message, args...)
 }
 
+// Recent returns up to the last HistorySize log lines, oldest first.
+func Recent() []string {
+	history.Lock()
+	defer history.Unlock()
+	if !history.full {
+		return append([]string(nil), history.lines[:history.next]...)
+	}
+	out := append([]string(nil), history.lines[history.next:]...)
+	return append(out, history.lines[:history.next]...)
+}
+
 func printf(level, message string, args ...interface{}) {
-	fmt.Printf(time.Now().Format("2006-01-02T15:04:05 ")+level+": "+message+"\n", args...)
+	line := fmt.Sprintf(time.Now().Format("2006-01-02T15:04:05 ")+level+": "+message, args...)
+	fmt.Println(line)
+
+	history.Lock()
+	history.lines[history.next] = line
+	history.next = (history.next + 1) % HistorySize
+	if history.next == 0 {
+		history.full = true
+	}
+	history.Unlock()
 }
@@ -0,0 +1,95 @@
+package mesh
+
+import (
+	"sort"
+
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// Dirty tracks the chunks whose meshes must be built again, such as after a
+// block changed or a neighbor chunk was loaded. It is not safe for concurrent
+// use.
+type Dirty struct {
+	chunks map[world.ChunkPos]struct{}
+}
+
+// NewDirty creates an empty set of dirty chunks.
+func NewDirty() *Dirty {
+	return &Dirty{chunks: map[world.ChunkPos]struct{}{}}
+}
+
+// Mark schedules the mesh of the chunk at p to be built again.
+func (d *Dirty) Mark(p world.ChunkPos) {
+	d.chunks[p] = struct{}{}
+}
+
+// MarkBlock schedules the chunk of the block at the world coordinates x, y,
+// z, and the neighbor chunks touching the block, whose faces on the border or
+// ambient occlusion may change.
+func (d *Dirty) MarkBlock(x, y, z int) {
+	for dy := -1; dy <= 1; dy++ {
+		for dz := -1; dz <= 1; dz++ {
+			for dx := -1; dx <= 1; dx++ {
+				d.Mark(world.ChunkAt(x+dx, y+dy, z+dz))
+			}
+		}
+	}
+}
+
+// MarkChunk schedules the chunk at p and its six neighbors, whose border
+// faces are culled against it.
+func (d *Dirty) MarkChunk(p world.ChunkPos) {
+	d.Mark(p)
+	for _, n := range [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}} {
+		d.Mark(world.ChunkPos{X: p.X + n[0], Y: p.Y + n[1], Z: p.Z + n[2]})
+	}
+}
+
+// Len returns the number of dirty chunks.
+func (d *Dirty) Len() int {
+	return len(d.chunks)
+}
+
+// Take returns the dirty chunks, ordered by position, and clears the set.
+// Chunks not loaded must be skipped by the caller.
+func (d *Dirty) Take() []world.ChunkPos {
+	out := make([]world.ChunkPos, 0, len(d.chunks))
+	for p := range d.chunks {
+		out = append(out, p)
+	}
+	d.chunks = map[world.ChunkPos]struct{}{}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Z < b.Z
+	})
+	return out
+}
+
+// Listen marks the chunks around the blocks changed in the world and the
+// chunks loaded, and the chunks changed by explosions. It returns a function
+// that stops listening.
+func (d *Dirty) Listen(bus *event.Bus) (unsubscribe func()) {
+	stopChanged := event.Subscribe(bus, func(e event.BlockChanged) {
+		d.MarkBlock(e.X, e.Y, e.Z)
+	})
+	stopLoaded := event.Subscribe(bus, func(e event.ChunkLoaded) {
+		d.MarkChunk(world.ChunkPos{X: e.X, Y: e.Y, Z: e.Z})
+	})
+	stopExplosion := event.Subscribe(bus, func(e event.Explosion) {
+		for _, c := range e.Chunks {
+			d.MarkChunk(world.ChunkPos{X: c[0], Y: c[1], Z: c[2]})
+		}
+	})
+	return func() {
+		stopChanged()
+		stopLoaded()
+		stopExplosion()
+	}
+}
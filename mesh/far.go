@@ -0,0 +1,118 @@
+package mesh
+
+import "math"
+
+// FarVertexSize is the number of floats per vertex emitted by Far: the x,y,z
+// world position and the r,g,b color, in the layout of render.FarLayout.
+const FarVertexSize = 6
+
+// Surface returns the height of the top of the world column x, z and its
+// color, such as worldgen.Generator.Surface. It must be defined for columns
+// far away from the loaded chunks.
+type Surface func(x, z int) (height float32, color [3]float32)
+
+// Far builds the impostor of the terrain beyond the loaded chunks, so
+// mountains stay visible at extreme distances: a height grid sampled from the
+// Surface, made of square rings around the camera, each one twice as large
+// and twice as coarse as the previous one. Each ring has the same number of
+// cells, so the cost grows with the logarithm of the distance.
+type Far struct {
+	Surface Surface
+	// Inner is the distance, in blocks, where the first ring starts, usually
+	// the render distance of the chunks, and Outer where the last ring
+	// ends.
+	Inner, Outer int
+	// Step is the distance, in blocks, between the vertices of the first
+	// ring.
+	Step int
+
+	center [2]int
+	built  bool
+}
+
+// levels returns the number of rings, and the inner extent and step of the
+// first one, rounded to a multiple of the step.
+func (f *Far) levels() (n, extent, step int) {
+	step = f.Step
+	if step < 1 {
+		step = 1
+	}
+	extent = (f.Inner + step - 1) / step * step
+	if extent < step {
+		extent = step
+	}
+	for e := extent; e < f.Outer; e *= 2 {
+		n++
+	}
+	return n, extent, step
+}
+
+// Update returns the mesh of the terrain around the camera at the world
+// position x, z, in the FarVertexSize layout, and true, when the mesh must be
+// replaced. The rings stay put until the camera moves by the step of the last
+// ring, so they are rebuilt rarely and their vertices do not swim.
+func (f *Far) Update(x, z float32) ([]float32, bool) {
+	n, _, step := f.levels()
+	if n == 0 {
+		return nil, false
+	}
+	snap := step << (n - 1)
+	c := [2]int{int(math.Floor(float64(x)/float64(snap))) * snap, int(math.Floor(float64(z)/float64(snap))) * snap}
+	if f.built && c == f.center {
+		return nil, false
+	}
+	f.center, f.built = c, true
+	return f.Mesh(c[0], c[1]), true
+}
+
+// Mesh builds the rings around the world column cx, cz, which should be a
+// multiple of the step of the last ring.
+func (f *Far) Mesh(cx, cz int) []float32 {
+	n, extent, step := f.levels()
+	var out []float32
+	for level := 0; level < n; level++ {
+		out = f.ring(out, cx, cz, extent, step, level == n-1)
+		extent, step = extent*2, step*2
+	}
+	return out
+}
+
+// ring appends the ring from extent to twice the extent around cx, cz.
+func (f *Far) ring(out []float32, cx, cz, extent, step int, last bool) []float32 {
+	cells := extent * 4 / step
+	size := cells + 1
+	heights := make([]float32, size*size)
+	colors := make([][3]float32, size*size)
+	for j := 0; j < size; j++ {
+		for i := 0; i < size; i++ {
+			heights[j*size+i], colors[j*size+i] = f.Surface(cx-extent*2+i*step, cz-extent*2+j*step)
+		}
+	}
+	if !last {
+		// The vertices of the outer edge between the vertices of the next,
+		// coarser, ring take the height of its edge, so there are no
+		// cracks between the rings.
+		for k := 1; k < size-1; k += 2 {
+			for _, e := range [4][3]int{{k, 0, 1}, {k, size - 1, 1}, {0, k, size}, {size - 1, k, size}} {
+				i := e[1]*size + e[0]
+				heights[i] = (heights[i-e[2]] + heights[i+e[2]]) / 2
+			}
+		}
+	}
+	hole := cells / 4
+	for j := 0; j < cells; j++ {
+		for i := 0; i < cells; i++ {
+			if i >= hole && i < cells-hole && j >= hole && j < cells-hole {
+				continue
+			}
+			for _, c := range [6][2]int{{0, 0}, {0, 1}, {1, 1}, {0, 0}, {1, 1}, {1, 0}} {
+				k := (j+c[1])*size + i + c[0]
+				out = append(out,
+					float32(cx-extent*2+(i+c[0])*step), heights[k], float32(cz-extent*2+(j+c[1])*step),
+					colors[k][0], colors[k][1], colors[k][2],
+				)
+			}
+		}
+	}
+	return out
+}
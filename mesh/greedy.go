@@ -0,0 +1,282 @@
+// package mesh builds the renderable geometry of the voxel world.
+package mesh
+
+import (
+	"github.com/ronoaldo/openvoxel/biome"
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// VertexSize is the number of floats per vertex emitted by the mesher, in the
+// layout expected by render.Scene.AddTintedVertices: the x,y,z position, the
+// texture coordinate, the light color and the tint color.
+const VertexSize = 11
+
+// World provides the blocks around a chunk, in world coordinates, so faces on
+// the chunk borders can be culled against the neighbor chunks.
+type World interface {
+	Block(x, y, z int) block.State
+}
+
+// Mesher generates the chunk meshes.
+type Mesher struct {
+	Registry *block.Registry
+	// Tinter, if set, provides the biome tint of the blocks with a Tint.
+	Tinter *biome.Tinter
+	// Light, if set, returns the light level at the world coordinates.
+	// Faces are fully lit otherwise.
+	Light func(x, y, z int) light.Color
+	// Textures, if set, selects the texture array layer of the faces, such
+	// as an atlas.Atlas. Faces use layer zero otherwise.
+	Textures Textures
+	// Liquid, if set, returns the height of the liquid surface at the four
+	// top corners of the block at the world coordinates, such as
+	// fluid.SurfaceHeights. The blocks with physics.MediumLiquid are then
+	// meshed with slanted surfaces instead of as full blocks.
+	Liquid func(x, y, z int) [4]float32
+}
+
+// Textures provides the texture array layers of the faces.
+type Textures interface {
+	// Layer returns the layer of the face of the block, in the order +X,
+	// -X, +Y, -Y, +Z, -Z.
+	Layer(id block.ID, face int) uint8
+	// Named returns the layer of the texture with the name, used by the
+	// model faces with a Texture.
+	Named(name string) uint8
+}
+
+var dims = [3]int{world.SizeX, world.SizeY, world.SizeZ}
+
+// faceKey identifies faces that can be merged together.
+type faceKey struct {
+	id    block.ID
+	light light.Color
+	ao    [4]uint8
+}
+
+// Quad is a rectangle of merged block faces.
+type Quad struct {
+	ID block.ID
+	// Face is the direction the quad faces, in the order +X, -X, +Y, -Y, +Z,
+	// -Z, matching render.Face.
+	Face int
+	// Corners are the positions relative to the chunk origin, in counter
+	// clockwise order when seen from the front.
+	Corners [4][3]float32
+	// UV are the texture coordinates of each corner, one unit per block.
+	UV [4][2]float32
+	// AO is the ambient occlusion level of each corner, from 0 (darkest) to
+	// 3 (not occluded).
+	AO    [4]uint8
+	Light light.Color
+	// Layer is the texture array layer of the face.
+	Layer uint8
+}
+
+// Quads returns the faces of the chunk visible from outside, merging adjacent
+// coplanar faces of the same block, light level and ambient occlusion into
+// larger quads. Blocks with a model, and the liquids when Liquid is set, are
+// added after the merged faces, face by face.
+func (m *Mesher) Quads(c *world.Chunk, w World) []Quad {
+	var out []Quad
+	ox, oy, oz := c.Origin()
+
+	at := func(p [3]int) block.State {
+		if world.Inside(p[0], p[1], p[2]) {
+			return c.Get(p[0], p[1], p[2])
+		}
+		if w == nil {
+			return block.State{ID: block.Air}
+		}
+		return w.Block(p[0]+ox, p[1]+oy, p[2]+oz)
+	}
+	opaque := func(p [3]int) bool {
+		return m.Registry.Get(at(p).ID).Opaque
+	}
+
+	for d := 0; d < 3; d++ {
+		u, v := (d+1)%3, (d+2)%3
+		mask := make([]faceKey, dims[u]*dims[v])
+		for _, side := range []int{-1, 1} {
+			for layer := 0; layer < dims[d]; layer++ {
+				// Build the mask of visible faces in this layer
+				n := 0
+				for j := 0; j < dims[v]; j++ {
+					for i := 0; i < dims[u]; i++ {
+						var p [3]int
+						p[d], p[u], p[v] = layer, i, j
+						mask[n] = faceKey{}
+						s := at(p)
+						if s.ID != block.Air && m.Registry.Get(s.ID).Model == nil && !m.isLiquid(s.ID) {
+							q := p
+							q[d] += side
+							if !opaque(q) {
+								mask[n] = faceKey{id: s.ID, light: m.lightAt(q, ox, oy, oz), ao: occlusion(q, u, v, opaque)}
+							}
+						}
+						n++
+					}
+				}
+				// Merge the faces into rectangles
+				for j := 0; j < dims[v]; j++ {
+					for i := 0; i < dims[u]; {
+						k := mask[j*dims[u]+i]
+						if k.id == block.Air {
+							i++
+							continue
+						}
+						wd := 1
+						for i+wd < dims[u] && mask[j*dims[u]+i+wd] == k {
+							wd++
+						}
+						h := 1
+					grow:
+						for j+h < dims[v] {
+							for x := 0; x < wd; x++ {
+								if mask[(j+h)*dims[u]+i+x] != k {
+									break grow
+								}
+							}
+							h++
+						}
+						for y := 0; y < h; y++ {
+							for x := 0; x < wd; x++ {
+								mask[(j+y)*dims[u]+i+x] = faceKey{}
+							}
+						}
+						q := quad(d, u, v, side, layer, i, j, wd, h, k)
+						q.Layer = m.layer(k.id, q.Face)
+						out = append(out, q)
+						i += wd
+					}
+				}
+			}
+		}
+	}
+	return m.liquids(m.models(out, c, at), c, at)
+}
+
+// occlusion computes the ambient occlusion of the four corners of the face
+// whose front is the block at q, in the same order as Quad.Corners.
+func occlusion(q [3]int, u, v int, opaque func([3]int) bool) (ao [4]uint8) {
+	for n, c := range [4][2]int{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}} {
+		a, b, corner := q, q, q
+		a[u] += c[0]
+		b[v] += c[1]
+		corner[u] += c[0]
+		corner[v] += c[1]
+		s1, s2, sc := opaque(a), opaque(b), opaque(corner)
+		switch {
+		case s1 && s2:
+			ao[n] = 0
+		default:
+			ao[n] = 3 - btou(s1) - btou(s2) - btou(sc)
+		}
+	}
+	return ao
+}
+
+func btou(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (m *Mesher) lightAt(p [3]int, ox, oy, oz int) light.Color {
+	if m.Light == nil {
+		return light.RGB(light.MaxLevel, light.MaxLevel, light.MaxLevel)
+	}
+	return m.Light(p[0]+ox, p[1]+oy, p[2]+oz)
+}
+
+// layer returns the texture array layer of the face of the block.
+func (m *Mesher) layer(id block.ID, face int) uint8 {
+	if m.Textures == nil {
+		return 0
+	}
+	return m.Textures.Layer(id, face)
+}
+
+// quad builds a merged face with wd x h blocks.
+func quad(d, u, v, side, layer, i, j, wd, h int, k faceKey) Quad {
+	q := Quad{ID: k.id, Face: d * 2, AO: k.ao, Light: k.light}
+	if side < 0 {
+		q.Face++
+	}
+	var base [3]int
+	base[d] = layer
+	if side > 0 {
+		base[d]++
+	}
+	base[u], base[v] = i, j
+	for n, c := range [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		p := base
+		p[u] += c[0] * wd
+		p[v] += c[1] * h
+		q.Corners[n] = [3]float32{float32(p[0]), float32(p[1]), float32(p[2])}
+		q.UV[n] = [2]float32{float32(c[0] * wd), float32(c[1] * h)}
+	}
+	return q
+}
+
+// triangles returns the corner indexes of the two triangles of the quad, with
+// the front facing winding. The diagonal is flipped when needed so the
+// ambient occlusion is interpolated without artifacts.
+func (q *Quad) triangles() [6]int {
+	flip := int(q.AO[0])+int(q.AO[2]) < int(q.AO[1])+int(q.AO[3])
+	front := q.Face%2 == 0
+	switch {
+	case front && !flip:
+		return [6]int{0, 1, 2, 0, 2, 3}
+	case front && flip:
+		return [6]int{0, 1, 3, 1, 2, 3}
+	case !flip:
+		return [6]int{0, 2, 1, 0, 3, 2}
+	default:
+		return [6]int{0, 3, 1, 1, 3, 2}
+	}
+}
+
+// aoFactor is the light multiplier for each ambient occlusion level.
+var aoFactor = [4]float32{0.4, 0.6, 0.8, 1}
+
+// Vertices converts the quads to triangles in the VertexSize float layout.
+// Ambient occlusion is applied to the light color.
+func (m *Mesher) Vertices(c *world.Chunk, quads []Quad) []float32 {
+	ox, _, oz := c.Origin()
+	out := make([]float32, 0, len(quads)*6*VertexSize)
+	for i := range quads {
+		q := &quads[i]
+		lr, lg, lb := q.Light.Floats()
+		tint := m.Registry.Get(q.ID).Tint
+		for _, n := range q.triangles() {
+			p := q.Corners[n]
+			t := m.tint(tint, int(p[0])+ox, int(p[2])+oz)
+			ao := aoFactor[q.AO[n]]
+			out = append(out,
+				p[0], p[1], p[2],
+				q.UV[n][0], q.UV[n][1],
+				lr*ao, lg*ao, lb*ao,
+				t[0], t[1], t[2],
+			)
+		}
+	}
+	return out
+}
+
+func (m *Mesher) tint(tint biome.Tint, x, z int) [3]float32 {
+	if m.Tinter == nil || tint == biome.TintNone {
+		return [3]float32{1, 1, 1}
+	}
+	return m.Tinter.Color(tint, x, z)
+}
+
+// Greedy builds the mesh of the chunk in the VertexSize float layout.
+// Positions are relative to the chunk origin, and texture coordinates span one
+// unit per block, so block textures must use a repeat wrap mode.
+func (m *Mesher) Greedy(c *world.Chunk, w World) []float32 {
+	return m.Vertices(c, m.Quads(c, w))
+}
@@ -0,0 +1,40 @@
+package mesh
+
+import (
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// itemShade is the light level of each face of the items, in the order +X,
+// -X, +Y, -Y, +Z, -Z, so the sides of the icons are told apart without
+// lighting in the shader.
+var itemShade = [6]uint8{10, 10, 15, 8, 12, 12}
+
+// Item builds the mesh of the block s alone, such as for inventory icons, in
+// the VertexSize float layout with positions from 0 to 1. All faces are drawn,
+// models are not connected to any neighbor, and the faces are shaded by their
+// direction instead of the Light function.
+func (m *Mesher) Item(s block.State) []float32 {
+	mm := *m
+	mm.Light = nil
+	c := world.NewChunk(0, 0, 0)
+	c.Set(0, 0, 0, s)
+	air := func([3]int) block.State { return block.State{ID: block.Air} }
+
+	var quads []Quad
+	if model := m.Registry.Get(s.ID).Model; model != nil {
+		quads = mm.model(nil, c, [3]int{}, s, model, air)
+	} else if s.ID != block.Air {
+		for f := 0; f < 6; f++ {
+			d := f / 2
+			side := 1 - f%2*2
+			quads = append(quads, quad(d, (d+1)%3, (d+2)%3, side, 0, 0, 0, 1, 1, faceKey{id: s.ID, ao: fullAO}))
+		}
+	}
+	for i := range quads {
+		l := itemShade[quads[i].Face]
+		quads[i].Light = light.RGB(l, l, l)
+	}
+	return mm.Vertices(c, quads)
+}
@@ -0,0 +1,75 @@
+package mesh
+
+import (
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/physics"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// cornerIndex maps the x, z corner of the top of a block to the index of the
+// heights returned by Mesher.Liquid.
+var cornerIndex = [2][2]int{{0, 3}, {1, 2}}
+
+// isLiquid returns true if the blocks with the id are meshed with the liquid
+// surface heights.
+func (m *Mesher) isLiquid(id block.ID) bool {
+	return m.Liquid != nil && m.Registry.Get(id).Medium == physics.MediumLiquid
+}
+
+// liquids appends the faces of the liquid blocks of the chunk to out, with
+// the top corners at the height of the liquid surface, so the surface slants
+// down where the liquid flows.
+func (m *Mesher) liquids(out []Quad, c *world.Chunk, at func([3]int) block.State) []Quad {
+	found := false
+	for _, s := range c.Palette() {
+		if m.isLiquid(s.ID) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return out
+	}
+	ox, oy, oz := c.Origin()
+	c.Each(func(x, y, z int, s block.State) {
+		if !m.isLiquid(s.ID) {
+			return
+		}
+		p := [3]int{x, y, z}
+		h := m.Liquid(x+ox, y+oy, z+oz)
+		for f := 0; f < 6; f++ {
+			d, side := f/2, 1
+			if f%2 == 1 {
+				side = -1
+			}
+			n := p
+			n[d] += side
+			ns := at(n)
+			// Faces between blocks of the same liquid are hidden, and so
+			// are faces against opaque blocks, except for the top surface
+			// when it is below the block above.
+			if ns.ID == s.ID {
+				continue
+			}
+			if m.Registry.Get(ns.ID).Opaque && (f != 2 || h == [4]float32{1, 1, 1, 1}) {
+				continue
+			}
+			q := Quad{ID: s.ID, Face: f, AO: fullAO, Light: m.lightAt(n, ox, oy, oz), Layer: m.layer(s.ID, f)}
+			u, v := (d+1)%3, (d+2)%3
+			for i, k := range corners {
+				var pos [3]float32
+				if side > 0 {
+					pos[d] = 1
+				}
+				pos[u], pos[v] = k[0], k[1]
+				if pos[1] == 1 {
+					pos[1] = h[cornerIndex[int(pos[0])][int(pos[2])]]
+				}
+				q.Corners[i] = [3]float32{pos[0] + float32(x), pos[1] + float32(y), pos[2] + float32(z)}
+				q.UV[i] = k
+			}
+			out = append(out, q)
+		}
+	})
+	return out
+}
@@ -0,0 +1,158 @@
+package mesh
+
+import (
+	"math"
+
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// fullAO is the ambient occlusion of the model faces, which are not occluded.
+var fullAO = [4]uint8{3, 3, 3, 3}
+
+// corners are the positions of the corners of a face along its axes, in the
+// order of Quad.Corners.
+var corners = [4][2]float32{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+
+// models appends the faces of the blocks of the chunk with a model to out.
+func (m *Mesher) models(out []Quad, c *world.Chunk, at func([3]int) block.State) []Quad {
+	found := false
+	for _, s := range c.Palette() {
+		if m.Registry.Get(s.ID).Model != nil {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return out
+	}
+	c.Each(func(x, y, z int, s block.State) {
+		if model := m.Registry.Get(s.ID).Model; model != nil {
+			out = m.model(out, c, [3]int{x, y, z}, s, model, at)
+		}
+	})
+	return out
+}
+
+// model appends the faces of the block s at p, relative to the chunk origin.
+func (m *Mesher) model(out []Quad, c *world.Chunk, p [3]int, s block.State, model *block.Model, at func([3]int) block.State) []Quad {
+	ox, oy, oz := c.Origin()
+	self := m.lightAt(p, ox, oy, oz)
+	for _, e := range model.Elements {
+		if model.Facing {
+			e = e.Rotate(s.Facing())
+		}
+		if e.Connect != 0 && !m.connected(p, s, e.Connect, at) {
+			continue
+		}
+		if e.Cross {
+			out = cross(out, s.ID, p, e, self, m.layer(s.ID, 2))
+			continue
+		}
+		for f, face := range e.Faces {
+			if face == nil {
+				continue
+			}
+			d, side := f/2, 1
+			if f%2 == 1 {
+				side = -1
+			}
+			q := Quad{ID: s.ID, Face: f, AO: fullAO, Light: self, Layer: m.layer(s.ID, f)}
+			if face.Texture != "" && m.Textures != nil {
+				q.Layer = m.Textures.Named(face.Texture)
+			}
+			var base [3]float32
+			base[d] = e.From[d]
+			if side > 0 {
+				base[d] = e.To[d]
+			}
+			// Faces are hidden by opaque neighbors and lit by them, as the
+			// faces of full blocks.
+			cull := -1
+			switch {
+			case face.Cull >= block.CullEast:
+				cull = int(face.Cull - block.CullEast)
+			case face.Cull == block.CullBorder && e.Rotation == nil &&
+				((side > 0 && base[d] >= 1) || (side < 0 && base[d] <= 0)):
+				cull = f
+			}
+			if cull >= 0 {
+				n := p
+				n[cull/2] += 1 - cull%2*2
+				if m.Registry.Get(at(n).ID).Opaque {
+					continue
+				}
+				q.Light = m.lightAt(n, ox, oy, oz)
+			}
+			u, v := (d+1)%3, (d+2)%3
+			for i, k := range corners {
+				pos := base
+				pos[u] = e.From[u] + (e.To[u]-e.From[u])*k[0]
+				pos[v] = e.From[v] + (e.To[v]-e.From[v])*k[1]
+				if e.Rotation != nil {
+					pos = rotate(pos, e.Rotation)
+				}
+				q.Corners[i] = [3]float32{pos[0] + float32(p[0]), pos[1] + float32(p[1]), pos[2] + float32(p[2])}
+				// Rotated faces take the coordinates of the corners
+				// before them.
+				uv, k := face.UV, corners[(i+4-face.Rotation%4)%4]
+				q.UV[i] = [2]float32{uv[0] + (uv[2]-uv[0])*k[0], uv[1] + (uv[3]-uv[1])*k[1]}
+			}
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+// connected returns true if the neighbor of the block s at p in one of the
+// directions of the mask is opaque or the same block.
+func (m *Mesher) connected(p [3]int, s block.State, mask uint8, at func([3]int) block.State) bool {
+	for f := block.North; f <= block.West; f++ {
+		if mask&(1<<f) == 0 {
+			continue
+		}
+		dx, dz := f.Offset()
+		n := at([3]int{p[0] + dx, p[1], p[2] + dz})
+		if n.ID == s.ID || m.Registry.Get(n.ID).Opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// cross appends the two diagonal planes of the element, seen from both sides.
+// They face up, so they are lit as the top of the blocks.
+func cross(out []Quad, id block.ID, p [3]int, e block.Element, l light.Color, layer uint8) []Quad {
+	x0, y0, z0 := e.From[0]+float32(p[0]), e.From[1]+float32(p[1]), e.From[2]+float32(p[2])
+	x1, y1, z1 := e.To[0]+float32(p[0]), e.To[1]+float32(p[1]), e.To[2]+float32(p[2])
+	uv := [4][2]float32{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	for _, plane := range [2][4][3]float32{
+		{{x0, y0, z0}, {x1, y0, z1}, {x1, y1, z1}, {x0, y1, z0}},
+		{{x0, y0, z1}, {x1, y0, z0}, {x1, y1, z0}, {x0, y1, z1}},
+	} {
+		front := Quad{ID: id, Face: 2, Corners: plane, UV: uv, AO: fullAO, Light: l, Layer: layer}
+		back := front
+		for i, n := range [4]int{0, 3, 2, 1} {
+			back.Corners[i] = plane[n]
+			back.UV[i] = uv[n]
+		}
+		out = append(out, front, back)
+	}
+	return out
+}
+
+// rotate turns the position p, in block local coordinates, by the element
+// rotation.
+func rotate(p [3]float32, r *block.ElementRotation) [3]float32 {
+	a, b := (r.Axis+1)%3, (r.Axis+2)%3
+	sin, cos := math.Sincos(float64(r.Angle) * math.Pi / 180)
+	pa, pb := float64(p[a]-r.Origin[a]), float64(p[b]-r.Origin[b])
+	ra, rb := pa*cos-pb*sin, pa*sin+pb*cos
+	if r.Rescale && cos != 0 {
+		ra, rb = ra/math.Abs(cos), rb/math.Abs(cos)
+	}
+	p[a] = r.Origin[a] + float32(ra)
+	p[b] = r.Origin[b] + float32(rb)
+	return p
+}
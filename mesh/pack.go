@@ -0,0 +1,68 @@
+package mesh
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// PackedSize is the number of bytes per vertex emitted by Pack, in the layout
+// expected by render.PackedLayout and unpacked by render.PackedVertexGLSL.
+//
+// Each vertex is made of three little endian 32 bit words:
+//
+//	word 0: x (9 bits) | y (9 bits) | z (9 bits) | face (3 bits) | ao (2 bits)
+//	word 1: u (9 bits) | v (9 bits) | light r,g,b (4 bits each)
+//	word 2: tint r,g,b (8 bits each) | texture array layer (8 bits)
+//
+// Positions and texture coordinates are in 1/16 of a block, so block models
+// are rounded to that precision. Positions are offset by PackedBias blocks.
+const PackedSize = 12
+
+// PackedBias is added to the packed positions, in blocks, so that model
+// elements reaching outside their block, such as rotated boxes or models
+// with coordinates below 0, do not wrap around at the chunk's 0 edge.
+// Positions outside the bias are clamped.
+const PackedBias = 1
+
+// The packed positions go from -PackedBias to the chunk size plus PackedBias,
+// in 1/16 units, which must fit in the 9 bit fields. These fail to compile
+// otherwise.
+const (
+	_ = uint(511 - (world.SizeX+2*PackedBias)*16)
+	_ = uint(511 - (world.SizeY+2*PackedBias)*16)
+	_ = uint(511 - (world.SizeZ+2*PackedBias)*16)
+)
+
+// Pack converts the quads to triangles in the PackedSize layout, which uses
+// about a quarter of the memory of the float layout.
+func (m *Mesher) Pack(c *world.Chunk, quads []Quad) []byte {
+	ox, _, oz := c.Origin()
+	out := make([]byte, 0, len(quads)*6*PackedSize)
+	var buf [PackedSize]byte
+	for i := range quads {
+		q := &quads[i]
+		tint := m.Registry.Get(q.ID).Tint
+		light := uint32(q.Light.R())<<18 | uint32(q.Light.G())<<22 | uint32(q.Light.B())<<26
+		for _, n := range q.triangles() {
+			p := q.Corners[n]
+			t := m.tint(tint, int(p[0])+ox, int(p[2])+oz)
+			w0 := sixteenths(p[0]+PackedBias) | sixteenths(p[1]+PackedBias)<<9 | sixteenths(p[2]+PackedBias)<<18 |
+				uint32(q.Face)<<27 | uint32(q.AO[n])<<30
+			w1 := sixteenths(q.UV[n][0]) | sixteenths(q.UV[n][1])<<9 | light
+			w2 := uint32(t[0]*255) | uint32(t[1]*255)<<8 | uint32(t[2]*255)<<16 | uint32(q.Layer)<<24
+			binary.LittleEndian.PutUint32(buf[0:], w0)
+			binary.LittleEndian.PutUint32(buf[4:], w1)
+			binary.LittleEndian.PutUint32(buf[8:], w2)
+			out = append(out, buf[:]...)
+		}
+	}
+	return out
+}
+
+// sixteenths returns v in 1/16 units, as stored in the 9 bit packed fields,
+// clamped to their range.
+func sixteenths(v float32) uint32 {
+	return uint32(math.Max(0, math.Min(math.Round(float64(v)*16), 511)))
+}
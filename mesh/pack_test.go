@@ -0,0 +1,65 @@
+package mesh
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// unpackPosition decodes the position of a packed vertex, like
+// render.PackedVertexGLSL.
+func unpackPosition(v []byte) [3]float32 {
+	w0 := binary.LittleEndian.Uint32(v)
+	var p [3]float32
+	for i := range p {
+		p[i] = float32(w0>>(9*i)&511)/16 - PackedBias
+	}
+	return p
+}
+
+func TestPackModelAtChunkEdge(t *testing.T) {
+	// A full box turned by 45 degrees and rescaled reaches half a block
+	// outside its block on X and Z.
+	e := block.Box([3]float32{0, 0, 0}, [3]float32{1, 1, 1})
+	e.Rotation = &block.ElementRotation{Origin: [3]float32{0.5, 0.5, 0.5}, Axis: 1, Angle: 45, Rescale: true}
+	reg := block.NewRegistry()
+	id, err := reg.Register(block.Definition{Name: "test:turned", Model: &block.Model{Elements: []block.Element{e}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := world.NewChunk(0, 0, 0)
+	c.Set(0, 0, 0, block.State{ID: id})
+
+	m := &Mesher{Registry: reg}
+	quads := m.Quads(c, nil)
+	packed := m.Pack(c, quads)
+	if len(packed) != len(quads)*6*PackedSize {
+		t.Fatalf("Pack returned %d bytes for %d quads", len(packed), len(quads))
+	}
+	min := float32(math.Inf(1))
+	n := 0
+	for i := range quads {
+		for _, k := range quads[i].triangles() {
+			want := quads[i].Corners[k]
+			got := unpackPosition(packed[n*PackedSize:])
+			n++
+			for j := range want {
+				if math.Abs(float64(got[j]-want[j])) > 1.0/32 {
+					t.Errorf("quad %d corner %d: unpacked %v, want %v", i, k, got, want)
+					break
+				}
+			}
+			for _, v := range want {
+				if v < min {
+					min = v
+				}
+			}
+		}
+	}
+	if min > -0.4 {
+		t.Errorf("model reaches down to %v, want it outside the chunk", min)
+	}
+}
@@ -0,0 +1,22 @@
+package mesh
+
+import (
+	"github.com/ronoaldo/openvoxel/worker"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// Handler returns a worker.Handler that meshes chunks off the main thread.
+// The request is a chunk encoded with MarshalBinary and the response is the
+// packed mesh. Faces on the chunk borders are not culled against the
+// neighbors, and Light is ignored, since the worker only sees the chunk.
+func (m *Mesher) Handler() worker.Handler {
+	return func(req []byte) ([]byte, error) {
+		var c world.Chunk
+		if err := c.UnmarshalBinary(req); err != nil {
+			return nil, err
+		}
+		mm := *m
+		mm.Light = nil
+		return mm.Pack(&c, mm.Quads(&c, nil)), nil
+	}
+}
@@ -0,0 +1,348 @@
+// package mover implements the blocks that move through the world as
+// entities: blocks that fall when nothing holds them, such as sand and gravel,
+// and carts that follow rails.
+//
+// Movers are simulated by the server with fixed-point math, so their state is
+// exact when sent to the clients with States and Apply, and replays of the
+// same ticks produce the same results on all architectures.
+package mover
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/entity"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/fixed"
+	"github.com/ronoaldo/openvoxel/tick"
+)
+
+// Entity type names of the movers.
+const (
+	FallingBlock = "openvoxel:falling_block"
+	Cart         = "openvoxel:cart"
+)
+
+// Kind is the role of a block for the movers.
+type Kind int
+
+const (
+	None Kind = iota
+	// Falls blocks turn into falling blocks when the block below them has no
+	// collision, and land back into the grid.
+	Falls
+	// Rail blocks guide the carts. Carts follow connected rails, turning at
+	// corners and going up and down one block at a time.
+	Rail
+)
+
+// World is the voxel storage the movers move through.
+type World interface {
+	Block(x, y, z int) block.State
+	SetBlock(x, y, z int, s block.State)
+}
+
+// Falling is the component of falling block entities.
+type Falling struct {
+	// Block is the falling block state, packed with block.State.Pack.
+	Block uint32
+	// Velocity is the vertical speed, in blocks per second.
+	Velocity fixed.Num
+}
+
+// Moving is the component of cart entities.
+type Moving struct {
+	// Dir is the direction the cart moves along the rails, one of the
+	// horizontal unit vectors.
+	Dir [3]int
+	// Speed is in blocks per second.
+	Speed fixed.Num
+}
+
+// RegisterTypes adds the FallingBlock and Cart entity types to the registry.
+func RegisterTypes(r *entity.Registry) error {
+	if err := r.Register(FallingBlock, func() []entity.Component {
+		return []entity.Component{entity.JSON(&Falling{})}
+	}); err != nil {
+		return err
+	}
+	return r.Register(Cart, func() []entity.Component {
+		return []entity.Component{entity.JSON(&Moving{Dir: [3]int{1, 0, 0}})}
+	})
+}
+
+// Simulator moves the falling blocks and carts on each tick.
+type Simulator struct {
+	World    World
+	Blocks   *block.Registry
+	Entities *entity.Store
+	Bus      *event.Bus
+
+	// Gravity is the downwards acceleration of falling blocks, in blocks per
+	// second squared, and MaxFallSpeed their terminal velocity.
+	Gravity, MaxFallSpeed fixed.Num
+	// Friction slows the carts, in blocks per second squared, MaxSpeed is
+	// their top speed, and Slope is the speed gained going down one block,
+	// or lost going up.
+	Friction, MaxSpeed, Slope fixed.Num
+
+	dt    fixed.Num
+	kinds map[block.ID]Kind
+	// check are the blocks to test for support on the next tick.
+	check map[[3]int]struct{}
+}
+
+// New creates a simulator updated on each tick of the scheduler.
+func New(w World, reg *block.Registry, store *entity.Store, t *tick.Scheduler, b *event.Bus) *Simulator {
+	s := &Simulator{
+		World:        w,
+		Blocks:       reg,
+		Entities:     store,
+		Bus:          b,
+		Gravity:      fixed.Int(32),
+		MaxFallSpeed: fixed.Int(40),
+		Friction:     fixed.Float(0.4),
+		MaxSpeed:     fixed.Int(8),
+		Slope:        fixed.Float(0.6),
+		dt:           fixed.Float(float32(1 / t.Rate)),
+		kinds:        map[block.ID]Kind{},
+		check:        map[[3]int]struct{}{},
+	}
+	t.Every(1, s.Tick)
+	return s
+}
+
+// Register sets the mover kind of the blocks with the id.
+func (s *Simulator) Register(id block.ID, k Kind) {
+	s.kinds[id] = k
+}
+
+// Listen checks the blocks changed in the world, and the blocks above them,
+// for support. It returns a function that stops listening.
+func (s *Simulator) Listen() (unsubscribe func()) {
+	stopChanged := event.Subscribe(s.Bus, func(e event.BlockChanged) {
+		s.Notify(e.X, e.Y, e.Z)
+	})
+	stopExplosion := event.Subscribe(s.Bus, func(e event.Explosion) {
+		for _, b := range e.Blocks {
+			s.Notify(b.X, b.Y, b.Z)
+		}
+	})
+	return func() {
+		stopChanged()
+		stopExplosion()
+	}
+}
+
+// Notify checks the block at the position, and the one above it, for support
+// on the next tick.
+func (s *Simulator) Notify(x, y, z int) {
+	s.check[[3]int{x, y, z}] = struct{}{}
+	s.check[[3]int{x, y + 1, z}] = struct{}{}
+}
+
+// Tick runs a single step of the simulation. It is called by the scheduler.
+func (s *Simulator) Tick() {
+	s.detach()
+	var falling, carts []*entity.Entity
+	s.Entities.Each(func(e *entity.Entity) {
+		switch e.Type {
+		case FallingBlock:
+			falling = append(falling, e)
+		case Cart:
+			carts = append(carts, e)
+		}
+	})
+	// Update in order, so the results do not depend on the map order.
+	sortByID(falling)
+	sortByID(carts)
+	for _, e := range falling {
+		s.fall(e)
+	}
+	for _, e := range carts {
+		s.roll(e)
+	}
+}
+
+// set changes the block and publishes event.BlockChanged.
+func (s *Simulator) set(p [3]int, b block.State) {
+	old := s.World.Block(p[0], p[1], p[2])
+	s.World.SetBlock(p[0], p[1], p[2], b)
+	event.Publish(s.Bus, event.BlockChanged{X: p[0], Y: p[1], Z: p[2], Old: old.Pack(), New: b.Pack()})
+}
+
+// solid returns true if the block at p has collision.
+func (s *Simulator) solid(p [3]int) bool {
+	return s.Blocks.Get(s.World.Block(p[0], p[1], p[2]).ID).Collision != nil
+}
+
+// detach turns the unsupported Falls blocks into falling blocks.
+func (s *Simulator) detach() {
+	check := make([][3]int, 0, len(s.check))
+	for p := range s.check {
+		check = append(check, p)
+	}
+	s.check = map[[3]int]struct{}{}
+	sortPos(check)
+	for _, p := range check {
+		b := s.World.Block(p[0], p[1], p[2])
+		if s.kinds[b.ID] != Falls || s.solid([3]int{p[0], p[1] - 1, p[2]}) {
+			continue
+		}
+		pos := glm.Vec3{float32(p[0]) + 0.5, float32(p[1]), float32(p[2]) + 0.5}
+		e, err := s.Entities.Spawn(FallingBlock, pos)
+		if err != nil {
+			// The type is not registered, so keep the block.
+			continue
+		}
+		if f, ok := entity.Get[*Falling](e); ok {
+			f.Block = b.Pack()
+		}
+		s.set(p, block.State{})
+	}
+}
+
+// fall moves the falling block down, and places it back into the grid when
+// it lands.
+func (s *Simulator) fall(e *entity.Entity) {
+	f, ok := entity.Get[*Falling](e)
+	if !ok {
+		return
+	}
+	f.Velocity -= s.Gravity.Mul(s.dt)
+	if f.Velocity < -s.MaxFallSpeed {
+		f.Velocity = -s.MaxFallSpeed
+	}
+	p := fixed.Vec(e.Position)
+	x, z := p[0].Floor(), p[2].Floor()
+	y := p[1] + f.Velocity.Mul(s.dt)
+	// Test each block crossed in this tick, so fast blocks do not go through
+	// thin floors.
+	for cell := p[1].Floor() - 1; cell >= y.Floor(); cell-- {
+		if s.solid([3]int{x, cell, z}) {
+			s.Entities.Remove(e)
+			s.set([3]int{x, cell + 1, z}, block.Unpack(f.Block))
+			return
+		}
+	}
+	p[1] = y
+	s.Entities.Move(e, p.Vec())
+}
+
+// SpawnCart places a cart on the rail at the position.
+func (s *Simulator) SpawnCart(x, y, z int) (*entity.Entity, error) {
+	return s.Entities.Spawn(Cart, glm.Vec3{float32(x) + 0.5, float32(y), float32(z) + 0.5})
+}
+
+// Push sets the speed of the cart, moving along the rail direction closest to
+// dir, such as the direction the player is looking at.
+func (s *Simulator) Push(e *entity.Entity, dir glm.Vec3, speed float32) {
+	m, ok := entity.Get[*Moving](e)
+	if !ok {
+		return
+	}
+	switch {
+	case math.Abs(float64(dir[0])) >= math.Abs(float64(dir[2])):
+		m.Dir = [3]int{sign(dir[0]), 0, 0}
+	default:
+		m.Dir = [3]int{0, 0, sign(dir[2])}
+	}
+	m.Speed = fixed.Float(speed)
+	if m.Speed > s.MaxSpeed {
+		m.Speed = s.MaxSpeed
+	}
+}
+
+// rail returns the height of the rail the cart moves to from the block at p
+// in the direction d, going up or down one block, and false if there is none.
+func (s *Simulator) rail(p, d [3]int) (int, bool) {
+	for _, dy := range []int{0, 1, -1} {
+		n := [3]int{p[0] + d[0], p[1] + dy, p[2] + d[2]}
+		if s.kinds[s.World.Block(n[0], n[1], n[2]).ID] == Rail {
+			return n[1], true
+		}
+	}
+	return 0, false
+}
+
+// roll moves the cart along the rails.
+func (s *Simulator) roll(e *entity.Entity) {
+	m, ok := entity.Get[*Moving](e)
+	if !ok || m.Speed <= 0 {
+		return
+	}
+	m.Speed -= s.Friction.Mul(s.dt)
+	if m.Speed > s.MaxSpeed {
+		m.Speed = s.MaxSpeed
+	}
+	if m.Speed <= 0 {
+		m.Speed = 0
+		return
+	}
+	p := fixed.Vec(e.Position)
+	left := m.Speed.Mul(s.dt)
+	// The speed is below a block per tick, so the cart crosses at most one
+	// block center and one block border on each step.
+	for i := 0; i < 4 && left > 0 && m.Speed > 0; i++ {
+		cell := [3]int{p[0].Floor(), p[1].Floor(), p[2].Floor()}
+		axis := 0
+		if m.Dir[2] != 0 {
+			axis = 2
+		}
+		d := fixed.Int(m.Dir[axis])
+		center := fixed.Int(cell[axis]) + fixed.Half
+		toCenter := (center - p[axis]).Mul(d)
+		if toCenter > 0 && toCenter <= left {
+			// Reaching the center, choose where to go next.
+			p[axis] = center
+			left -= toCenter
+			s.turn(m, cell)
+			continue
+		}
+		p[axis] += left.Mul(d)
+		left = 0
+		if next := p[axis].Floor(); next != cell[axis] {
+			// Entering the next block, follow the rail up or down.
+			y, ok := s.rail(cell, m.Dir)
+			if !ok {
+				m.Speed = 0
+				p[axis] = center
+				break
+			}
+			switch {
+			case y > cell[1]:
+				m.Speed -= s.Slope
+			case y < cell[1]:
+				m.Speed += s.Slope
+			}
+			p[1] = fixed.Int(y)
+		}
+	}
+	if m.Speed < 0 {
+		m.Speed = 0
+	}
+	s.Entities.Move(e, p.Vec())
+}
+
+// turn changes the direction of the cart at the center of the block p, when
+// the rail does not continue ahead, or stops it at the end of the rail.
+func (s *Simulator) turn(m *Moving, p [3]int) {
+	if _, ok := s.rail(p, m.Dir); ok {
+		return
+	}
+	for _, d := range [][3]int{{m.Dir[2], 0, m.Dir[0]}, {-m.Dir[2], 0, -m.Dir[0]}} {
+		if _, ok := s.rail(p, d); ok {
+			m.Dir = d
+			return
+		}
+	}
+	m.Speed = 0
+}
+
+func sign(v float32) int {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
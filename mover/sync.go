@@ -0,0 +1,135 @@
+package mover
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/ronoaldo/openvoxel/entity"
+	"github.com/ronoaldo/openvoxel/fixed"
+)
+
+// ErrInvalidStates is returned when decoding malformed mover states.
+var ErrInvalidStates = errors.New("mover: invalid states")
+
+// stateSize is the encoded size of a State.
+const stateSize = 8 + 3*8 + 3*4 + 8
+
+// State is the state of a mover sent by the server to the clients after each
+// tick. The entities themselves, and the blocks they leave and land on, are
+// synchronized as any other entity and block.
+type State struct {
+	ID       uint64
+	Position fixed.Vec3
+	// Dir and Speed are the cart direction and speed. Falling blocks use
+	// Speed for their vertical velocity.
+	Dir   [3]int32
+	Speed fixed.Num
+}
+
+// States appends the states of the loaded movers to dst, ordered by ID.
+func (s *Simulator) States(dst []State) []State {
+	var es []*entity.Entity
+	s.Entities.Each(func(e *entity.Entity) {
+		if e.Type == FallingBlock || e.Type == Cart {
+			es = append(es, e)
+		}
+	})
+	sortByID(es)
+	for _, e := range es {
+		st := State{ID: e.ID, Position: fixed.Vec(e.Position)}
+		if f, ok := entity.Get[*Falling](e); ok {
+			st.Speed = f.Velocity
+		}
+		if m, ok := entity.Get[*Moving](e); ok {
+			st.Dir = [3]int32{int32(m.Dir[0]), int32(m.Dir[1]), int32(m.Dir[2])}
+			st.Speed = m.Speed
+		}
+		dst = append(dst, st)
+	}
+	return dst
+}
+
+// Apply updates the movers with the states received from the server. States
+// of entities that are not loaded are ignored.
+func (s *Simulator) Apply(states []State) {
+	for _, st := range states {
+		e := s.Entities.Get(st.ID)
+		if e == nil {
+			continue
+		}
+		if f, ok := entity.Get[*Falling](e); ok {
+			f.Velocity = st.Speed
+		}
+		if m, ok := entity.Get[*Moving](e); ok {
+			m.Dir = [3]int{int(st.Dir[0]), int(st.Dir[1]), int(st.Dir[2])}
+			m.Speed = st.Speed
+		}
+		s.Entities.Move(e, st.Position.Vec())
+	}
+}
+
+// AppendStates encodes the states to b. Each state is its ID as an uint64, its
+// position as three int64 fixed-point values, its direction as three int32
+// values and its speed as an int64 fixed-point value. All values are little
+// endian.
+func AppendStates(b []byte, states []State) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(states)))
+	for _, st := range states {
+		b = binary.LittleEndian.AppendUint64(b, st.ID)
+		for _, v := range st.Position {
+			b = binary.LittleEndian.AppendUint64(b, uint64(v))
+		}
+		for _, v := range st.Dir {
+			b = binary.LittleEndian.AppendUint32(b, uint32(v))
+		}
+		b = binary.LittleEndian.AppendUint64(b, uint64(st.Speed))
+	}
+	return b
+}
+
+// DecodeStates decodes the states encoded by AppendStates.
+func DecodeStates(b []byte) ([]State, error) {
+	if len(b) < 4 {
+		return nil, ErrInvalidStates
+	}
+	n := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	if uint64(len(b)) != uint64(n)*stateSize {
+		return nil, ErrInvalidStates
+	}
+	states := make([]State, n)
+	for i := range states {
+		st := &states[i]
+		st.ID = binary.LittleEndian.Uint64(b)
+		b = b[8:]
+		for j := range st.Position {
+			st.Position[j] = fixed.Num(binary.LittleEndian.Uint64(b))
+			b = b[8:]
+		}
+		for j := range st.Dir {
+			st.Dir[j] = int32(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		}
+		st.Speed = fixed.Num(binary.LittleEndian.Uint64(b))
+		b = b[8:]
+	}
+	return states, nil
+}
+
+func sortByID(es []*entity.Entity) {
+	sort.Slice(es, func(i, j int) bool { return es[i].ID < es[j].ID })
+}
+
+func sortPos(ps [][3]int) {
+	sort.Slice(ps, func(i, j int) bool {
+		a, b := ps[i], ps[j]
+		if a[1] != b[1] {
+			return a[1] < b[1]
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[2] < b[2]
+	})
+}
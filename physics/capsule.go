@@ -0,0 +1,170 @@
+package physics
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Capsule is a vertical capsule collider, described by the position of its
+// bottom, its radius and total height.
+//
+// Horizontally, the capsule is round, so it slides smoothly around block
+// corners, which makes player movement feel better than with an AABB.
+// Vertically it uses its bounding box, so landing and ceiling detection match
+// the block shapes exactly.
+type Capsule struct {
+	Base   glm.Vec3
+	Radius float32
+	Height float32
+}
+
+// Bounds returns the bounding box of the capsule.
+func (c Capsule) Bounds() AABB {
+	return AABB{
+		glm.Vec3{c.Base[0] - c.Radius, c.Base[1], c.Base[2] - c.Radius},
+		glm.Vec3{c.Base[0] + c.Radius, c.Base[1] + c.Height, c.Base[2] + c.Radius},
+	}
+}
+
+// penetration returns the horizontal vector that pushes the capsule out of the
+// box, and false if they don't overlap.
+func (c Capsule) penetration(box AABB) (glm.Vec3, bool) {
+	if box.Max[1] <= c.Base[1] || box.Min[1] >= c.Base[1]+c.Height {
+		return glm.Vec3{}, false
+	}
+	cx, cz := c.Base[0], c.Base[2]
+	px, pz := clampf(cx, box.Min[0], box.Max[0]), clampf(cz, box.Min[2], box.Max[2])
+	dx, dz := cx-px, cz-pz
+	// The explicit conversions round the products, so they are not fused
+	// into a multiply-add on some architectures and the result is the same
+	// everywhere.
+	dist := float32(math.Sqrt(float64(float32(dx*dx) + float32(dz*dz))))
+	if dist >= c.Radius {
+		return glm.Vec3{}, false
+	}
+	if dist > 1e-6 {
+		k := (c.Radius - dist) / dist
+		return glm.Vec3{dx * k, 0, dz * k}, true
+	}
+	// The center is inside the box; push out through the closest side.
+	candidates := [4]glm.Vec3{
+		{box.Max[0] - cx + c.Radius, 0, 0},
+		{box.Min[0] - cx - c.Radius, 0, 0},
+		{0, 0, box.Max[2] - cz + c.Radius},
+		{0, 0, box.Min[2] - cz - c.Radius},
+	}
+	best := candidates[0]
+	for _, v := range candidates[1:] {
+		if abs32(v[0]+v[2]) < abs32(best[0]+best[2]) {
+			best = v
+		}
+	}
+	return best, true
+}
+
+// MoveCapsule moves the capsule by v, resolving collisions with the terrain
+// shapes. If the capsule is on the ground and runs into an obstacle lower than
+// stepHeight, such as a slab or a stair, it is lifted on top of it.
+//
+// The horizontal movement is split in steps no longer than the radius, so
+// fast capsules, such as after a knockback or a long frame, can't pass
+// through thin walls.
+func MoveCapsule(src ShapeSource, c Capsule, v glm.Vec3, stepHeight float32, onGround bool) (Capsule, Result) {
+	var res Result
+	start := c.Base
+
+	vertical := sweep(src, c.Bounds(), glm.Vec3{0, v[1], 0})
+	c.Base[1] += vertical.Moved[1]
+	res.OnGround = vertical.OnGround
+	res.Collided[1] = vertical.Collided[1]
+
+	steps := 1
+	if c.Radius > 0 {
+		length := float32(math.Sqrt(float64(float32(v[0]*v[0]) + float32(v[2]*v[2]))))
+		steps = int(math.Ceil(float64(length / c.Radius)))
+		if steps < 1 {
+			steps = 1
+		}
+	}
+	step := glm.Vec3{v[0] / float32(steps), 0, v[2] / float32(steps)}
+	for i := 0; i < steps; i++ {
+		c = c.moveHorizontal(src, step, stepHeight, onGround || res.OnGround, &res)
+	}
+
+	res.Moved = c.Base.Sub(start)
+	return c, res
+}
+
+// moveHorizontal moves the capsule by v, which must not be longer than the
+// radius, and pushes it out of the terrain, recording the collisions in res.
+func (c Capsule) moveHorizontal(src ShapeSource, v glm.Vec3, stepHeight float32, onGround bool, res *Result) Capsule {
+	next := c
+	next.Base = c.Base.Add(v)
+	if stepHeight > 0 && onGround {
+		if top, ok := stepTop(src, next, stepHeight); ok {
+			next.Base[1] = top
+		}
+	}
+
+	// Iterate a few times, as pushing out of one box may push into another.
+	for iter := 0; iter < 4; iter++ {
+		pushed := false
+		for _, b := range colliders(src, next.Bounds(), nil) {
+			if push, ok := next.penetration(b); ok {
+				next.Base = next.Base.Add(push)
+				if push[0] != 0 {
+					res.Collided[0] = true
+				}
+				if push[2] != 0 {
+					res.Collided[2] = true
+				}
+				pushed = true
+			}
+		}
+		if !pushed {
+			break
+		}
+	}
+	return next
+}
+
+// stepTop returns the height the capsule must be lifted to stand on the
+// obstacles it overlaps, if they are not higher than stepHeight and there is
+// room for the capsule above them.
+func stepTop(src ShapeSource, c Capsule, stepHeight float32) (float32, bool) {
+	boxes := colliders(src, c.Bounds(), nil)
+	top := c.Base[1]
+	for _, b := range boxes {
+		if _, ok := c.penetration(b); !ok {
+			continue
+		}
+		if b.Max[1]-c.Base[1] > stepHeight {
+			return 0, false
+		}
+		if b.Max[1] > top {
+			top = b.Max[1]
+		}
+	}
+	if top == c.Base[1] {
+		return 0, false
+	}
+	raised := c
+	raised.Base[1] = top
+	for _, b := range colliders(src, raised.Bounds(), nil) {
+		if _, ok := raised.penetration(b); ok {
+			return 0, false
+		}
+	}
+	return top, true
+}
+
+func clampf(v, min, max float32) float32 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,311 @@
+package physics
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+
+	"github.com/ronoaldo/openvoxel/fixed"
+)
+
+// Medium describes how a block affects the movement of the entities inside it.
+type Medium uint8
+
+const (
+	// MediumAir has no effect.
+	MediumAir Medium = iota
+	// MediumLiquid makes entities swim, like water and lava.
+	MediumLiquid
+	// MediumClimbable lets entities climb, like ladders and vines.
+	MediumClimbable
+)
+
+// MediumSource provides the medium of the voxel terrain blocks.
+type MediumSource interface {
+	Medium(x, y, z int) Medium
+}
+
+// MoveState is the way a Controller is moving.
+type MoveState int
+
+const (
+	Walking MoveState = iota
+	Sprinting
+	Swimming
+	Climbing
+	Flying
+)
+
+func (s MoveState) String() string {
+	switch s {
+	case Walking:
+		return "walking"
+	case Sprinting:
+		return "sprinting"
+	case Swimming:
+		return "swimming"
+	case Climbing:
+		return "climbing"
+	case Flying:
+		return "flying"
+	}
+	return "unknown"
+}
+
+// ControllerParams tunes the movement of a Controller. Speeds are in blocks
+// per second.
+type ControllerParams struct {
+	WalkSpeed, SprintSpeed, SwimSpeed, ClimbSpeed, FlySpeed float32
+	// JumpSpeed is the upwards speed of a jump, Gravity the downwards
+	// acceleration, in blocks per second squared, and MaxFallSpeed the
+	// terminal velocity.
+	JumpSpeed, Gravity, MaxFallSpeed float32
+	// SinkSpeed is how fast idle swimmers sink.
+	SinkSpeed float32
+	// StepHeight is the tallest obstacle walked over without jumping.
+	StepHeight float32
+	// SafeFall is the height, in blocks, of the falls that cause no damage,
+	// and FallDamage the damage per block fallen beyond it.
+	SafeFall, FallDamage float32
+}
+
+// DefaultControllerParams are tuned for a player 1.8 blocks tall.
+var DefaultControllerParams = ControllerParams{
+	WalkSpeed:    4.3,
+	SprintSpeed:  5.6,
+	SwimSpeed:    2,
+	ClimbSpeed:   2.4,
+	FlySpeed:     11,
+	JumpSpeed:    8.4,
+	Gravity:      32,
+	MaxFallSpeed: 78,
+	SinkSpeed:    0.6,
+	StepHeight:   0.6,
+	SafeFall:     3,
+	FallDamage:   1,
+}
+
+// ControllerInput is the movement requested for a Controller in a step.
+type ControllerInput struct {
+	// Direction is the horizontal direction to move to, in world space, with
+	// a length of up to 1 for analog input.
+	Direction glm.Vec3
+	// Jump jumps when walking, and moves up when swimming, climbing or
+	// flying. Descend moves down in those states.
+	Jump, Descend bool
+	// Sprint moves faster when walking on the ground.
+	Sprint bool
+}
+
+// Controller moves a capsule through the terrain as a player or a mob would,
+// switching between walking, sprinting, swimming, climbing and flying from the
+// input and the medium of the blocks around it.
+type Controller struct {
+	Params ControllerParams
+	Capsule
+	Velocity glm.Vec3
+	// Flying is set by the game, such as when flying is toggled in the
+	// creative mode, and takes precedence over the other states.
+	Flying bool
+	// Fixed snaps the position and velocities to the precision of fixed.Num
+	// after each step, so a Snapshot restores the controller exactly and
+	// multiplayer peers and replays stay in sync.
+	Fixed bool
+
+	// State is the movement state of the last step, and OnGround is true
+	// if the capsule was standing on a block.
+	State    MoveState
+	OnGround bool
+
+	// knockback is the horizontal velocity added by Push.
+	knockback glm.Vec3
+
+	// fallFrom is the highest position since the capsule left the ground,
+	// used to compute the fall damage.
+	fallFrom float32
+}
+
+// NewController creates a controller for the capsule with the default
+// parameters.
+func NewController(c Capsule) *Controller {
+	return &Controller{Params: DefaultControllerParams, Capsule: c, fallFrom: c.Base[1]}
+}
+
+// Teleport moves the capsule base to p and stops it, without taking fall
+// damage on the next landing.
+func (c *Controller) Teleport(p glm.Vec3) {
+	c.Base = p
+	c.Velocity = glm.Vec3{}
+	c.fallFrom = p[1]
+}
+
+// Snapshot is the simulation state of a Controller in fixed-point numbers, to
+// send over the network or record in replays.
+type Snapshot struct {
+	Base, Velocity, Knockback fixed.Vec3
+	FallFrom                  fixed.Num
+	State                     MoveState
+	OnGround, Flying          bool
+}
+
+// Snapshot returns the state of the controller. It is exact if the controller
+// is Fixed.
+func (c *Controller) Snapshot() Snapshot {
+	return Snapshot{
+		Base:      fixed.Vec(c.Base),
+		Velocity:  fixed.Vec(c.Velocity),
+		Knockback: fixed.Vec(c.knockback),
+		FallFrom:  fixed.Float(c.fallFrom),
+		State:     c.State,
+		OnGround:  c.OnGround,
+		Flying:    c.Flying,
+	}
+}
+
+// Restore sets the state of the controller from s.
+func (c *Controller) Restore(s Snapshot) {
+	c.Base = s.Base.Vec()
+	c.Velocity = s.Velocity.Vec()
+	c.knockback = s.Knockback.Vec()
+	c.fallFrom = s.FallFrom.Float32()
+	c.State = s.State
+	c.OnGround = s.OnGround
+	c.Flying = s.Flying
+}
+
+// Push adds the impulse to the velocity, such as the knockback of a hit or an
+// explosion. The horizontal part is added on top of the movement input, and
+// fades quickly on the ground and slowly in the air.
+func (c *Controller) Push(impulse glm.Vec3) {
+	c.knockback = c.knockback.Add(glm.Vec3{impulse[0], 0, impulse[2]})
+	c.Velocity[1] += impulse[1]
+	if impulse[1] > 0 {
+		c.OnGround = false
+	}
+}
+
+// state returns the movement state for the input, from the medium at the feet
+// and the middle of the capsule.
+func (c *Controller) state(media MediumSource, in ControllerInput) MoveState {
+	if c.Flying {
+		return Flying
+	}
+	at := func(h float32) Medium {
+		p := c.Base.Add(glm.Vec3{0, h, 0})
+		return media.Medium(floor(p[0]), floor(p[1]), floor(p[2]))
+	}
+	feet, middle := at(0.1), at(c.Height*0.5)
+	switch {
+	case middle == MediumLiquid:
+		return Swimming
+	case feet == MediumClimbable || middle == MediumClimbable:
+		return Climbing
+	case in.Sprint && length(in.Direction) > 0:
+		return Sprinting
+	}
+	return Walking
+}
+
+// Update advances the controller by dt seconds, and returns the fall damage
+// taken when landing, or zero.
+func (c *Controller) Update(src ShapeSource, media MediumSource, in ControllerInput, dt float32) (damage float32) {
+	p := c.Params
+	c.State = c.state(media, in)
+	dir := glm.Vec3{in.Direction[0], 0, in.Direction[2]}
+	if l := length(dir); l > 1 {
+		dir = dir.Mul(1 / l)
+	}
+	vertical := func(speed, idle float32) float32 {
+		switch {
+		case in.Jump:
+			return speed
+		case in.Descend:
+			return -speed
+		}
+		return idle
+	}
+
+	v := c.Velocity
+	switch c.State {
+	case Flying:
+		v = dir.Mul(p.FlySpeed)
+		v[1] = vertical(p.FlySpeed, 0)
+	case Swimming:
+		v = dir.Mul(p.SwimSpeed)
+		v[1] = vertical(p.SwimSpeed, -p.SinkSpeed)
+	case Climbing:
+		v = dir.Mul(p.WalkSpeed * 0.5)
+		v[1] = vertical(p.ClimbSpeed, 0)
+	default:
+		speed := p.WalkSpeed
+		if c.State == Sprinting {
+			speed = p.SprintSpeed
+		}
+		v[0], v[2] = dir[0]*speed, dir[2]*speed
+		if c.OnGround && in.Jump {
+			v[1] = p.JumpSpeed
+		} else {
+			v[1] -= float32(p.Gravity * dt)
+			if v[1] < -p.MaxFallSpeed {
+				v[1] = -p.MaxFallSpeed
+			}
+		}
+	}
+	if c.State != Flying {
+		v[0] += c.knockback[0]
+		v[2] += c.knockback[2]
+		drag := float32(1)
+		if c.OnGround {
+			drag = 8
+		}
+		// math.Exp differs between architectures, fixed.Exp does not.
+		c.knockback = c.knockback.Mul(fixed.Exp(fixed.Float(-drag * dt)).Float32())
+	}
+
+	step := float32(0)
+	if c.State == Walking || c.State == Sprinting {
+		step = p.StepHeight
+	}
+	var res Result
+	c.Capsule, res = MoveCapsule(src, c.Capsule, v.Mul(dt), step, c.OnGround)
+	if res.Collided[1] {
+		v[1] = 0
+	}
+	for _, axis := range []int{0, 2} {
+		if res.Collided[axis] {
+			c.knockback[axis] = 0
+		}
+	}
+	c.Velocity = v
+	c.OnGround = res.OnGround
+	if c.Fixed {
+		c.Base = fixed.SnapVec(c.Base)
+		c.Velocity = fixed.SnapVec(c.Velocity)
+		c.knockback = fixed.SnapVec(c.knockback)
+	}
+
+	// Swimming, climbing and flying break the fall.
+	if c.State != Walking && c.State != Sprinting {
+		c.fallFrom = c.Base[1]
+		return 0
+	}
+	if !c.OnGround {
+		if c.Base[1] > c.fallFrom {
+			c.fallFrom = c.Base[1]
+		}
+		return 0
+	}
+	fell := c.fallFrom - c.Base[1]
+	c.fallFrom = c.Base[1]
+	if fell > p.SafeFall {
+		return (fell - p.SafeFall) * p.FallDamage
+	}
+	return 0
+}
+
+// length returns the length of v. Unlike glm.Vec3.Len, the products are
+// rounded explicitly so they are not fused into multiply-adds, which gives
+// different results on some architectures.
+func length(v glm.Vec3) float32 {
+	return float32(math.Sqrt(float64(float32(v[0]*v[0]) + float32(v[1]*v[1]) + float32(v[2]*v[2]))))
+}
@@ -0,0 +1,101 @@
+package physics
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Result reports the outcome of a movement.
+type Result struct {
+	// Moved is the movement actually performed after collisions.
+	Moved glm.Vec3
+	// OnGround is true if the downwards movement was blocked.
+	OnGround bool
+	// Collided has one flag per axis that was blocked.
+	Collided [3]bool
+}
+
+// Move sweeps the box by the velocity v, one axis at a time, stopping at the
+// terrain collision shapes. If the horizontal movement is blocked while on the
+// ground, it tries to step up by up to stepHeight, which allows walking over
+// slabs and stairs.
+func Move(src ShapeSource, box AABB, v glm.Vec3, stepHeight float32, onGround bool) (AABB, Result) {
+	res := sweep(src, box, v)
+	blockedHorizontal := res.Collided[0] || res.Collided[2]
+	if stepHeight <= 0 || !blockedHorizontal || !(onGround || res.OnGround) {
+		return box.Offset(res.Moved), res
+	}
+
+	// Try again from a raised position, then move back down.
+	up := sweep(src, box, glm.Vec3{0, stepHeight, 0})
+	raised := box.Offset(up.Moved)
+	side := sweep(src, raised, glm.Vec3{v[0], 0, v[2]})
+	moved := raised.Offset(side.Moved)
+	down := sweep(src, moved, glm.Vec3{0, -up.Moved[1] + minf(v[1], 0), 0})
+
+	stepped := float32(side.Moved[0]*side.Moved[0]) + float32(side.Moved[2]*side.Moved[2])
+	direct := float32(res.Moved[0]*res.Moved[0]) + float32(res.Moved[2]*res.Moved[2])
+	if stepped <= direct {
+		return box.Offset(res.Moved), res
+	}
+
+	total := up.Moved.Add(side.Moved).Add(down.Moved)
+	return box.Offset(total), Result{
+		Moved:    total,
+		OnGround: down.OnGround,
+		Collided: [3]bool{side.Collided[0], down.Collided[1], side.Collided[2]},
+	}
+}
+
+// sweep moves the box along each axis, in Y, X, Z order, clipping the movement
+// against the colliders.
+func sweep(src ShapeSource, box AABB, v glm.Vec3) Result {
+	var res Result
+	boxes := colliders(src, box.Expand(v), nil)
+	for _, axis := range [3]int{1, 0, 2} {
+		d := v[axis]
+		for _, c := range boxes {
+			d = clip(axis, box, c, d)
+		}
+		if d != v[axis] {
+			res.Collided[axis] = true
+			if axis == 1 && v[axis] < 0 {
+				res.OnGround = true
+			}
+		}
+		box.Min[axis] += d
+		box.Max[axis] += d
+		res.Moved[axis] = d
+	}
+	return res
+}
+
+// clip limits the movement d of box along axis so it doesn't enter c.
+func clip(axis int, box, c AABB, d float32) float32 {
+	// Only boxes overlapping in the other two axes can block the movement.
+	for i := 0; i < 3; i++ {
+		if i == axis {
+			continue
+		}
+		if box.Max[i] <= c.Min[i] || box.Min[i] >= c.Max[i] {
+			return d
+		}
+	}
+	if d > 0 && box.Max[axis] <= c.Min[axis] {
+		if gap := c.Min[axis] - box.Max[axis]; gap < d {
+			return gap
+		}
+	}
+	if d < 0 && box.Min[axis] >= c.Max[axis] {
+		if gap := c.Max[axis] - box.Min[axis]; gap > d {
+			return gap
+		}
+	}
+	return d
+}
+
+func minf(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,133 @@
+package physics
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// RayHit is the block hit by a ray.
+type RayHit struct {
+	// Block is the position of the block hit.
+	Block [3]int
+	// Normal is the face of the block hit, pointing out of it, so the block
+	// next to that face is Block plus Normal.
+	Normal [3]int
+	// Point is where the ray hit the block shape, and Distance how far it
+	// is from the origin.
+	Point    glm.Vec3
+	Distance float32
+}
+
+// Raycast returns the first block whose collision shape is hit by the ray from
+// origin along dir, within max distance. The shapes are tested exactly, so rays
+// pass over slabs and between the steps of stairs.
+func Raycast(src ShapeSource, origin, dir glm.Vec3, max float32) (RayHit, bool) {
+	if dir.Len() == 0 {
+		return RayHit{}, false
+	}
+	dir = dir.Normalize()
+	cell := [3]int{floor(origin[0]), floor(origin[1]), floor(origin[2])}
+	var step [3]int
+	var next, delta [3]float32
+	for i := 0; i < 3; i++ {
+		switch {
+		case dir[i] > 0:
+			step[i] = 1
+			delta[i] = 1 / dir[i]
+			next[i] = (float32(cell[i]+1) - origin[i]) / dir[i]
+		case dir[i] < 0:
+			step[i] = -1
+			delta[i] = -1 / dir[i]
+			next[i] = (origin[i] - float32(cell[i])) / -dir[i]
+		default:
+			delta[i] = float32(math.Inf(1))
+			next[i] = float32(math.Inf(1))
+		}
+	}
+	for t := float32(0); t <= max; {
+		base := glm.Vec3{float32(cell[0]), float32(cell[1]), float32(cell[2])}
+		best, found := RayHit{}, false
+		for _, b := range src.Shape(cell[0], cell[1], cell[2]) {
+			if d, n, ok := rayBox(origin, dir, b.Offset(base)); ok && d <= max && (!found || d < best.Distance) {
+				best = RayHit{Block: cell, Normal: n, Point: origin.Add(dir.Mul(d)), Distance: d}
+				found = true
+			}
+		}
+		if found {
+			return best, true
+		}
+		axis := 0
+		if next[1] < next[axis] {
+			axis = 1
+		}
+		if next[2] < next[axis] {
+			axis = 2
+		}
+		t = next[axis]
+		cell[axis] += step[axis]
+		next[axis] += delta[axis]
+	}
+	return RayHit{}, false
+}
+
+// rayBox returns the distance along the ray where it enters the box, and the
+// normal of the face entered. Rays starting inside the box hit it at zero
+// distance, with the normal facing back to the ray.
+func rayBox(origin, dir glm.Vec3, b AABB) (float32, [3]int, bool) {
+	tmin, tmax := float32(math.Inf(-1)), float32(math.Inf(1))
+	var normal [3]int
+	for i := 0; i < 3; i++ {
+		if dir[i] == 0 {
+			if origin[i] < b.Min[i] || origin[i] > b.Max[i] {
+				return 0, normal, false
+			}
+			continue
+		}
+		t0 := (b.Min[i] - origin[i]) / dir[i]
+		t1 := (b.Max[i] - origin[i]) / dir[i]
+		n := -1
+		if t0 > t1 {
+			t0, t1 = t1, t0
+			n = 1
+		}
+		if t0 > tmin {
+			tmin = t0
+			normal = [3]int{}
+			normal[i] = n
+		}
+		if t1 < tmax {
+			tmax = t1
+		}
+	}
+	if tmax < tmin || tmax < 0 {
+		return 0, normal, false
+	}
+	if tmin < 0 {
+		tmin = 0
+		// Inside the box: use the axis the ray moves along the most.
+		normal = [3]int{}
+		axis := 0
+		for i := 1; i < 3; i++ {
+			if abs32(dir[i]) > abs32(dir[axis]) {
+				axis = i
+			}
+		}
+		normal[axis] = -sign(dir[axis])
+	}
+	return tmin, normal, true
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v float32) int {
+	if v < 0 {
+		return -1
+	}
+	return 1
+}
@@ -0,0 +1,100 @@
+// package physics implements collision detection and movement of entities
+// against the voxel terrain.
+package physics
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// AABB is an axis aligned bounding box.
+type AABB struct {
+	Min, Max glm.Vec3
+}
+
+// Box creates an AABB from the minimum and maximum coordinates.
+func Box(x0, y0, z0, x1, y1, z1 float32) AABB {
+	return AABB{glm.Vec3{x0, y0, z0}, glm.Vec3{x1, y1, z1}}
+}
+
+// Offset returns the box translated by v.
+func (b AABB) Offset(v glm.Vec3) AABB {
+	return AABB{b.Min.Add(v), b.Max.Add(v)}
+}
+
+// Intersects returns true if both boxes overlap.
+func (b AABB) Intersects(o AABB) bool {
+	return b.Min[0] < o.Max[0] && b.Max[0] > o.Min[0] &&
+		b.Min[1] < o.Max[1] && b.Max[1] > o.Min[1] &&
+		b.Min[2] < o.Max[2] && b.Max[2] > o.Min[2]
+}
+
+// Expand returns a box grown to include the movement by v.
+func (b AABB) Expand(v glm.Vec3) AABB {
+	r := b
+	for i := 0; i < 3; i++ {
+		if v[i] < 0 {
+			r.Min[i] += v[i]
+		} else {
+			r.Max[i] += v[i]
+		}
+	}
+	return r
+}
+
+// ClosestPoint returns the point inside the box closest to p.
+func (b AABB) ClosestPoint(p glm.Vec3) glm.Vec3 {
+	for i := 0; i < 3; i++ {
+		if p[i] < b.Min[i] {
+			p[i] = b.Min[i]
+		}
+		if p[i] > b.Max[i] {
+			p[i] = b.Max[i]
+		}
+	}
+	return p
+}
+
+// Block collision shapes, in block local coordinates.
+var (
+	// FullCube is the collision shape of regular blocks.
+	FullCube = []AABB{Box(0, 0, 0, 1, 1, 1)}
+	// BottomSlab is the collision shape of a half block on the floor.
+	BottomSlab = []AABB{Box(0, 0, 0, 1, 0.5, 1)}
+	// TopSlab is the collision shape of a half block on the ceiling.
+	TopSlab = []AABB{Box(0, 0.5, 0, 1, 1, 1)}
+	// Stairs is the collision shape of stairs ascending towards -Z, the
+	// North facing block models are made in, such as block.StairsModel.
+	Stairs = []AABB{Box(0, 0, 0, 1, 0.5, 1), Box(0, 0.5, 0, 1, 1, 0.5)}
+)
+
+// ShapeSource provides the collision shapes of the voxel terrain.
+type ShapeSource interface {
+	// Shape returns the collision boxes of the block at x, y, z, in block
+	// local coordinates. Blocks without collision return nil.
+	Shape(x, y, z int) []AABB
+}
+
+// colliders returns the world space collision boxes overlapping the area.
+func colliders(src ShapeSource, area AABB, out []AABB) []AABB {
+	x0, y0, z0 := floor(area.Min[0]), floor(area.Min[1]), floor(area.Min[2])
+	x1, y1, z1 := floor(area.Max[0]), floor(area.Max[1]), floor(area.Max[2])
+	for x := x0; x <= x1; x++ {
+		for y := y0; y <= y1; y++ {
+			for z := z0; z <= z1; z++ {
+				offset := glm.Vec3{float32(x), float32(y), float32(z)}
+				for _, b := range src.Shape(x, y, z) {
+					out = append(out, b.Offset(offset))
+				}
+			}
+		}
+	}
+	return out
+}
+
+func floor(v float32) int {
+	i := int(v)
+	if float32(i) > v {
+		i--
+	}
+	return i
+}
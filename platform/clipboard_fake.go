@@ -0,0 +1,20 @@
+//go:build openvoxel_fake
+
+package platform
+
+// clipboard is the in-memory clipboard of the fake backend.
+var clipboard string
+
+// ClipboardSet replaces the in-memory clipboard contents with text.
+func ClipboardSet(text string) {
+	clipboard = text
+}
+
+// ClipboardGet calls fn with the text in the in-memory clipboard, right away.
+func ClipboardGet(fn func(text string, err error)) {
+	if clipboard == "" {
+		fn("", ErrClipboardUnavailable)
+		return
+	}
+	fn(clipboard, nil)
+}
@@ -0,0 +1,24 @@
+//go:build !js && !openvoxel_fake
+
+package platform
+
+import "github.com/go-gl/glfw/v3.3/glfw"
+
+// ClipboardSet replaces the clipboard contents with text. It must be called
+// from the main thread after the window is created.
+func ClipboardSet(text string) {
+	glfw.SetClipboardString(text)
+}
+
+// ClipboardGet calls fn with the text in the clipboard. The browser API is
+// asynchronous, so fn may be called after ClipboardGet returns; on the desktop
+// it is called right away. It must be called from the main thread after the
+// window is created.
+func ClipboardGet(fn func(text string, err error)) {
+	text := glfw.GetClipboardString()
+	if text == "" {
+		fn("", ErrClipboardUnavailable)
+		return
+	}
+	fn(text, nil)
+}
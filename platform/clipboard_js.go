@@ -0,0 +1,73 @@
+//go:build !openvoxel_fake
+
+package platform
+
+import (
+	"syscall/js"
+
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// clipboard returns the browser Clipboard API, which is undefined outside of
+// secure contexts.
+func clipboard() js.Value {
+	nav := js.Global().Get("navigator")
+	if nav.IsUndefined() {
+		return js.Undefined()
+	}
+	return nav.Get("clipboard")
+}
+
+// then calls onResult or onError when the promise p settles, releasing both
+// callbacks afterwards.
+func then(p js.Value, onResult func(js.Value), onError func(js.Value)) {
+	var ok, fail js.Func
+	release := func() {
+		ok.Release()
+		fail.Release()
+	}
+	ok = js.FuncOf(func(this js.Value, args []js.Value) any {
+		release()
+		onResult(args[0])
+		return nil
+	})
+	fail = js.FuncOf(func(this js.Value, args []js.Value) any {
+		release()
+		onError(args[0])
+		return nil
+	})
+	p.Call("then", ok, fail)
+}
+
+// ClipboardSet replaces the clipboard contents with text. Browsers only allow
+// it while handling user input, such as a key press.
+func ClipboardSet(text string) {
+	c := clipboard()
+	if c.IsUndefined() {
+		log.Warnf("Clipboard API not available")
+		return
+	}
+	then(c.Call("writeText", text), func(js.Value) {}, func(err js.Value) {
+		log.Warnf("Error writing to the clipboard: %v", err.Call("toString").String())
+	})
+}
+
+// ClipboardGet calls fn with the text in the clipboard. The browser API is
+// asynchronous, so fn is called after ClipboardGet returns, once the browser
+// grants the permission to read the clipboard.
+//
+// In the text input mode, pasting with the keyboard shortcut is handled by the
+// browser and published as event.Text without asking for permission.
+func ClipboardGet(fn func(text string, err error)) {
+	c := clipboard()
+	if c.IsUndefined() || c.Get("readText").IsUndefined() {
+		fn("", ErrClipboardUnavailable)
+		return
+	}
+	then(c.Call("readText"), func(text js.Value) {
+		fn(text.String(), nil)
+	}, func(err js.Value) {
+		log.Warnf("Error reading the clipboard: %v", err.Call("toString").String())
+		fn("", ErrClipboardUnavailable)
+	})
+}
@@ -0,0 +1,9 @@
+// Package platform wraps the services of the operating system or the browser
+// that are not part of the rendering or input, such as the clipboard.
+package platform
+
+import "errors"
+
+// ErrClipboardUnavailable is returned when the clipboard can't be read, such
+// as when the browser denies the permission or it holds no text.
+var ErrClipboardUnavailable = errors.New("platform: clipboard unavailable")
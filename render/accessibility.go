@@ -0,0 +1,175 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// ColorFilter is a color vision deficiency filter applied to the final frame.
+type ColorFilter int
+
+const (
+	ColorFilterNone ColorFilter = iota
+	// SimulateProtanopia, SimulateDeuteranopia and SimulateTritanopia show
+	// the frame as seen with the color vision deficiency, to check that the
+	// game remains playable.
+	SimulateProtanopia
+	SimulateDeuteranopia
+	SimulateTritanopia
+	// CorrectProtanopia, CorrectDeuteranopia and CorrectTritanopia shift the
+	// colors lost with the deficiency to the ones still perceived, making
+	// them easier to tell apart.
+	CorrectProtanopia
+	CorrectDeuteranopia
+	CorrectTritanopia
+)
+
+// colorBlindness holds the simulation matrices of each deficiency, in linear
+// RGB, from Machado et al. 2009 at full severity. Matrices are row-major.
+var colorBlindness = [3][9]float32{
+	{0.152286, 1.052583, -0.204868, 0.114503, 0.786281, 0.099216, -0.003882, -0.048116, 1.051998},
+	{0.367322, 0.860646, -0.227968, 0.280085, 0.672501, 0.047413, -0.011820, 0.042940, 0.968881},
+	{1.255528, -0.076749, -0.178779, -0.078411, 0.930809, 0.147602, 0.004733, 0.691367, 0.303900},
+}
+
+// Accessibility holds the settings that adapt the rendering to the needs of
+// the player.
+type Accessibility struct {
+	// ColorFilter is applied to the final frame by the AccessibilityFilter
+	// and the ResolutionScaler.
+	ColorFilter ColorFilter
+	// UIScale multiplies the size of the 2D layer, including text. See
+	// UIProjection.
+	UIScale float32
+	// ReduceMotion disables the camera motion effects that may cause
+	// discomfort, such as the view model head bob and screen shakes.
+	ReduceMotion bool
+}
+
+// DefaultAccessibility has no color filter, the UI at its normal size and all
+// motion effects enabled.
+var DefaultAccessibility = Accessibility{UIScale: 1}
+
+var accessibility = DefaultAccessibility
+
+// SetAccessibility changes the accessibility settings. A UIScale of zero is
+// replaced by 1, and the scale is limited to the range [0.5, 4].
+func SetAccessibility(a Accessibility) {
+	switch {
+	case a.UIScale == 0:
+		a.UIScale = 1
+	case a.UIScale < 0.5:
+		a.UIScale = 0.5
+	case a.UIScale > 4:
+		a.UIScale = 4
+	}
+	accessibility = a
+}
+
+// CurrentAccessibility returns the accessibility settings in use.
+func CurrentAccessibility() Accessibility {
+	return accessibility
+}
+
+// UIProjection returns the orthographic projection of the 2D layer for a
+// render target of width x height pixels, with the origin at the top left.
+// One unit is UIScale pixels, so drawing the 2D layer and its text in units
+// scales all of it.
+func UIProjection(width, height int) glm.Mat4 {
+	s := accessibility.UIScale
+	return glm.Ortho(0, float32(width)/s, float32(height)/s, 0, -1, 1)
+}
+
+// ColorFilterGLSL declares a function that applies the current color filter,
+// with the uniforms set by setColorFilter.
+const ColorFilterGLSL = `
+uniform mat4 colorFilter;
+uniform int colorFilterMode; // 0: none, 1: simulate, 2: correct
+
+vec3 applyColorFilter(vec3 c) {
+    if (colorFilterMode == 0) {
+        return c;
+    }
+    vec3 linear = pow(c, vec3(2.2));
+    vec3 seen = mat3(colorFilter) * linear;
+    if (colorFilterMode == 2) {
+        // Move the lost information to the channels still perceived.
+        vec3 err = linear - seen;
+        seen = linear + vec3(0.0, 0.7 * err.r + err.g, 0.7 * err.r + err.b);
+    }
+    return pow(clamp(seen, 0.0, 1.0), vec3(1.0 / 2.2));
+}
+`
+
+// setColorFilter sets the ColorFilterGLSL uniforms of the shader for the
+// current settings.
+func setColorFilter(shader *Shader) {
+	f := accessibility.ColorFilter
+	var mode int32
+	switch {
+	case f >= SimulateProtanopia && f <= SimulateTritanopia:
+		mode = 1
+	case f >= CorrectProtanopia && f <= CorrectTritanopia:
+		mode = 2
+	}
+	shader.UniformInts("colorFilterMode", mode)
+	if mode == 0 {
+		return
+	}
+	m := colorBlindness[(int(f)-1)%3]
+	// Transpose to the column-major order of GLSL, in the upper left of a
+	// mat4.
+	shader.UniformTransformation("colorFilter", glm.Mat4{
+		m[0], m[3], m[6], 0,
+		m[1], m[4], m[7], 0,
+		m[2], m[5], m[8], 0,
+		0, 0, 0, 1,
+	})
+}
+
+const accessibilityFilterGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D scene;
+` + ColorFilterGLSL + `
+void main() {
+    vec4 c = texture(scene, TexCoord);
+    FragColor = vec4(applyColorFilter(c.rgb), c.a);
+}
+`
+
+// AccessibilityFilter copies a rendered frame to the current render target,
+// applying the color filter of the accessibility settings. Games that draw
+// through a ResolutionScaler don't need it, as the scaler applies the filter
+// when upscaling.
+type AccessibilityFilter struct {
+	shader *Shader
+}
+
+// NewAccessibilityFilter compiles the filter shader.
+func NewAccessibilityFilter() (*AccessibilityFilter, error) {
+	s := &Shader{}
+	s.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + accessibilityFilterGLSL)
+	if err := s.Link(); err != nil {
+		return nil, err
+	}
+	return &AccessibilityFilter{shader: s}, nil
+}
+
+// Apply draws src over the whole current render target.
+func (a *AccessibilityFilter) Apply(src *Texture) {
+	state := DefaultPipeline
+	state.DepthTest, state.DepthWrite = false, false
+	state.Apply()
+	a.shader.Use()
+	a.shader.UniformInts("scene", 0)
+	setColorFilter(a.shader)
+	src.Bind(0)
+	DrawFullscreen()
+	DefaultPipeline.Apply()
+}
+
+// Delete releases the shader.
+func (a *AccessibilityFilter) Delete() {
+	a.shader.Delete()
+}
@@ -0,0 +1,127 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AnimationFrame is a frame of an animated texture.
+type AnimationFrame struct {
+	// Index is the position of the frame in the vertical strip, from the top.
+	Index int `json:"index"`
+	// Time is the frame duration, in ticks.
+	Time int `json:"time"`
+}
+
+// Animation describes a flipbook texture stored as a vertical strip of square
+// frames, such as water, lava and portals.
+type Animation struct {
+	// Count is the number of frames in the strip.
+	Count int `json:"count"`
+	// FrameTime is the default frame duration, in ticks.
+	FrameTime int `json:"frametime"`
+	// Frames is the frame sequence. If empty, all frames in the strip are
+	// played in order.
+	Frames []AnimationFrame `json:"frames"`
+	// Interpolate blends each frame with the next one.
+	Interpolate bool `json:"interpolate"`
+}
+
+// AnimationTickRate is the number of animation ticks per second.
+const AnimationTickRate = 20
+
+// ParseAnimation decodes the animation metadata stored next to a texture, in
+// the JSON format:
+//
+//	{"count": 32, "frametime": 2, "frames": [{"index": 0, "time": 4}, ...]}
+//
+// Frames may also be listed as plain indexes, using the default frame time.
+func ParseAnimation(b []byte) (*Animation, error) {
+	var raw struct {
+		Animation
+		Frames []json.RawMessage `json:"frames"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("animation: %w", err)
+	}
+	a := raw.Animation
+	if a.Count <= 0 {
+		return nil, fmt.Errorf("animation: invalid frame count %d", a.Count)
+	}
+	if a.FrameTime <= 0 {
+		a.FrameTime = 1
+	}
+	for _, f := range raw.Frames {
+		var fr AnimationFrame
+		if err := json.Unmarshal(f, &fr.Index); err != nil {
+			if err := json.Unmarshal(f, &fr); err != nil {
+				return nil, fmt.Errorf("animation: invalid frame %s", f)
+			}
+		}
+		if fr.Index < 0 || fr.Index >= a.Count {
+			return nil, fmt.Errorf("animation: frame index %d out of range", fr.Index)
+		}
+		if fr.Time <= 0 {
+			fr.Time = a.FrameTime
+		}
+		a.Frames = append(a.Frames, fr)
+	}
+	return &a, nil
+}
+
+// sequence returns the frames to be played.
+func (a *Animation) sequence() []AnimationFrame {
+	if len(a.Frames) > 0 {
+		return a.Frames
+	}
+	seq := make([]AnimationFrame, a.Count)
+	for i := range seq {
+		seq[i] = AnimationFrame{Index: i, Time: a.FrameTime}
+	}
+	return seq
+}
+
+// Frame returns the strip indexes of the current and next frames at the time
+// t, in seconds, and the blend factor between them. The blend factor is always
+// zero if the animation is not interpolated.
+func (a *Animation) Frame(t float64) (current, next int, blend float32) {
+	seq := a.sequence()
+	total := 0
+	for _, f := range seq {
+		total += f.Time
+	}
+	ticks := t * AnimationTickRate
+	tick := int(ticks) % total
+	for i, f := range seq {
+		if tick < f.Time {
+			current, next = f.Index, seq[(i+1)%len(seq)].Index
+			if a.Interpolate {
+				blend = float32((float64(tick) + ticks - float64(int(ticks))) / float64(f.Time))
+			}
+			return current, next, blend
+		}
+		tick -= f.Time
+	}
+	return seq[0].Index, seq[0].Index, 0
+}
+
+// Bind sets the uniforms declared by AnimationGLSL for the time t, in seconds.
+// The texture coordinates of animated faces must span the first frame.
+func (a *Animation) Bind(shader *Shader, t float64) {
+	current, next, blend := a.Frame(t)
+	scale := 1 / float32(a.Count)
+	shader.UniformFloats("animFrame", float32(current)*scale, float32(next)*scale, blend)
+}
+
+// AnimationGLSL declares the uniform set by Animation.Bind and a function that
+// samples the animated texture. It must be included after the #version and
+// precision statements.
+const AnimationGLSL = `
+uniform vec3 animFrame; // x: current offset, y: next offset, z: blend
+
+vec4 animated(sampler2D tex, vec2 uv) {
+    vec4 a = texture(tex, uv + vec2(0.0, animFrame.x));
+    vec4 b = texture(tex, uv + vec2(0.0, animFrame.y));
+    return mix(a, b, animFrame.z);
+}
+`
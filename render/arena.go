@@ -0,0 +1,150 @@
+package render
+
+import "sort"
+
+// arenaRange is a contiguous region of the arena, in bytes.
+type arenaRange struct {
+	offset, size int
+}
+
+// ArenaMove describes a range moved by Arena.Compact, in bytes.
+type ArenaMove struct {
+	From, To, Size int
+}
+
+// Arena sub-allocates ranges of a GPU buffer, such as the chunk meshes in a
+// MeshBuffer. It only does the bookkeeping; the caller copies the data.
+//
+// Free ranges are kept in a list sorted by offset and merged with their
+// neighbors when released. Allocation uses the first range large enough, and
+// Compact moves all live ranges to the start of the buffer when the free space
+// becomes too fragmented.
+type Arena struct {
+	size  int
+	align int
+	free  []arenaRange
+	used  map[int]int
+	inUse int
+}
+
+// NewArena creates an arena managing size bytes. Allocations are rounded up to
+// multiples of align, usually the vertex size, so offsets are vertex indexes.
+func NewArena(size, align int) *Arena {
+	a := &Arena{size: size, align: align, used: map[int]int{}}
+	if size > 0 {
+		a.free = []arenaRange{{0, size}}
+	}
+	return a
+}
+
+func (a *Arena) round(size int) int {
+	return (size + a.align - 1) / a.align * a.align
+}
+
+// Alloc reserves size bytes and returns their offset. It returns false if no
+// free range is large enough.
+func (a *Arena) Alloc(size int) (offset int, ok bool) {
+	size = a.round(size)
+	for i, r := range a.free {
+		if r.size < size {
+			continue
+		}
+		if r.size == size {
+			a.free = append(a.free[:i], a.free[i+1:]...)
+		} else {
+			a.free[i] = arenaRange{r.offset + size, r.size - size}
+		}
+		a.used[r.offset] = size
+		a.inUse += size
+		return r.offset, true
+	}
+	return 0, false
+}
+
+// Free releases the range allocated at offset.
+func (a *Arena) Free(offset int) {
+	size, ok := a.used[offset]
+	if !ok {
+		return
+	}
+	delete(a.used, offset)
+	a.inUse -= size
+	a.insertFree(arenaRange{offset, size})
+}
+
+// insertFree adds the range to the free list, merging it with adjacent ones.
+func (a *Arena) insertFree(r arenaRange) {
+	i := sort.Search(len(a.free), func(i int) bool { return a.free[i].offset > r.offset })
+	a.free = append(a.free, arenaRange{})
+	copy(a.free[i+1:], a.free[i:])
+	a.free[i] = r
+	if i+1 < len(a.free) && a.free[i].offset+a.free[i].size == a.free[i+1].offset {
+		a.free[i].size += a.free[i+1].size
+		a.free = append(a.free[:i+1], a.free[i+2:]...)
+	}
+	if i > 0 && a.free[i-1].offset+a.free[i-1].size == a.free[i].offset {
+		a.free[i-1].size += a.free[i].size
+		a.free = append(a.free[:i], a.free[i+1:]...)
+	}
+}
+
+// Grow extends the arena to size bytes.
+func (a *Arena) Grow(size int) {
+	if size <= a.size {
+		return
+	}
+	a.insertFree(arenaRange{a.size, size - a.size})
+	a.size = size
+}
+
+// Size returns the arena capacity, in bytes.
+func (a *Arena) Size() int {
+	return a.size
+}
+
+// Used returns the number of allocated bytes.
+func (a *Arena) Used() int {
+	return a.inUse
+}
+
+// Fragmentation returns the fraction of the free space outside the largest
+// free range, from 0 (contiguous) to 1.
+func (a *Arena) Fragmentation() float32 {
+	free := a.size - a.inUse
+	if free == 0 {
+		return 0
+	}
+	largest := 0
+	for _, r := range a.free {
+		if r.size > largest {
+			largest = r.size
+		}
+	}
+	return 1 - float32(largest)/float32(free)
+}
+
+// Compact moves all allocations to the start of the arena, leaving a single
+// free range at the end. It returns the moves to apply to the buffer, which
+// also map the old offsets to the new ones.
+func (a *Arena) Compact() []ArenaMove {
+	offsets := make([]int, 0, len(a.used))
+	for o := range a.used {
+		offsets = append(offsets, o)
+	}
+	sort.Ints(offsets)
+	moves := make([]ArenaMove, 0, len(offsets))
+	used := make(map[int]int, len(offsets))
+	next := 0
+	for _, o := range offsets {
+		size := a.used[o]
+		moves = append(moves, ArenaMove{From: o, To: next, Size: size})
+		used[next] = size
+		next += size
+	}
+	a.used = used
+	a.free = a.free[:0]
+	if next < a.size {
+		a.free = append(a.free, arenaRange{next, a.size - next})
+	}
+	return moves
+}
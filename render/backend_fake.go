@@ -0,0 +1,925 @@
+//go:build openvoxel_fake
+
+package render
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"time"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/render/fake"
+)
+
+// deferredSupported indicates that the DeferredRenderer can be used.
+const deferredSupported = true
+
+// glslVersion is the header of the builtin shaders for this backend.
+const glslVersion = "#version 330 core\n"
+
+// record adds the call to the fake.Default recorder.
+func record(name string, args ...any) {
+	fake.Default.Record(name, args...)
+}
+
+// lastHandle is the last GPU object name handed out by the fake backend.
+var lastHandle uint32
+
+func newHandle() uint32 {
+	lastHandle++
+	return lastHandle
+}
+
+// Version returns the name of the fake backend.
+func Version() string {
+	return "Fake Backend (openvoxel_fake)"
+}
+
+var initializedAt = time.Now()
+
+// Time returns the time in seconds since the backend was initialized.
+func Time() float64 {
+	return time.Since(initializedAt).Seconds()
+}
+
+// Window is a fake window without a display. Input is fed with the Inject
+// methods, as for the windows of AttachExternalContext.
+type Window struct {
+	scene *Scene
+
+	Width  int
+	Height int
+
+	// region is the part of the window drawn by BindDefaultFramebuffer,
+	// set with SetViewport. Empty is the whole window.
+	region image.Rectangle
+
+	textInput          bool
+	closeRequested     bool
+	visible            bool
+	onVisibilityChange func(visible bool)
+}
+
+// NewWindow creates a fake window.
+func NewWindow(width, height int, title string) (*Window, error) {
+	record("NewWindow", width, height, title)
+	return &Window{Width: width, Height: height, scene: NewScene(), visible: true}, nil
+}
+
+// AttachExternalContext creates a fake window, ignoring the framebuffer.
+func AttachExternalContext(width, height int, fbo uint32) (*Window, error) {
+	record("AttachExternalContext", width, height, fbo)
+	return &Window{Width: width, Height: height, scene: NewScene(), visible: true}, nil
+}
+
+// ShouldClose returns true after RequestClose.
+func (w *Window) ShouldClose() bool {
+	return w.closeRequested
+}
+
+// RequestClose makes ShouldClose return true, ending the engine main loop.
+func (w *Window) RequestClose() {
+	w.closeRequested = true
+}
+
+// Close releases the scene and reports the leaked resources.
+func (w *Window) Close() {
+	w.scene.Delete()
+	reportLeaks()
+}
+
+// Resize changes the window size, and publishes event.WindowResized.
+func (w *Window) Resize(width, height int, fbo uint32) {
+	w.Width, w.Height = width, height
+	record("Viewport", 0, 0, width, height)
+	event.Publish(event.Default, event.WindowResized{Width: width, Height: height})
+}
+
+// InjectKey publishes the key event, using the GLFW key codes and modifiers.
+func (w *Window) InjectKey(key, scancode int, action event.KeyAction, mods int) {
+	event.Publish(event.Default, event.Key{Key: key, Scancode: scancode, Action: action, Mods: mods, Text: w.textInput})
+}
+
+// InjectMouseButton publishes the mouse button event, using the GLFW button
+// codes.
+func (w *Window) InjectMouseButton(button int, action event.KeyAction, mods int) {
+	event.Publish(event.Default, event.MouseButton{Button: button, Action: action, Mods: mods})
+}
+
+// InjectChar publishes the character as event.Text in the text input mode.
+func (w *Window) InjectChar(char rune) {
+	if w.textInput {
+		event.Publish(event.Default, event.Text{Text: string(char)})
+	}
+}
+
+// InjectCursor is ignored, as the fake window has no camera controls.
+func (w *Window) InjectCursor(x, y float64) {}
+
+// SetVisible changes the visibility, calling the visibility callback.
+func (w *Window) SetVisible(visible bool) {
+	w.visible = visible
+	if w.onVisibilityChange != nil {
+		w.onVisibilityChange(visible)
+	}
+}
+
+// Visible returns false after SetVisible(false).
+func (w *Window) Visible() bool {
+	return w.visible
+}
+
+// SetVisibilityCallback registers a function to be called when the
+// visibility changes.
+func (w *Window) SetVisibilityCallback(cb func(visible bool)) {
+	w.onVisibilityChange = cb
+}
+
+// SetCameraControls does nothing, as the fake window has no camera controls.
+func (w *Window) SetCameraControls(enabled bool) {}
+
+// StartTextInput enables the text input mode.
+func (w *Window) StartTextInput() {
+	w.textInput = true
+}
+
+// StopTextInput returns the keyboard to the game controls.
+func (w *Window) StopTextInput() {
+	w.textInput = false
+}
+
+// TextInputActive returns true while the text input mode is enabled.
+func (w *Window) TextInputActive() bool {
+	return w.textInput
+}
+
+// PollEvents does nothing, as input is injected.
+func (w *Window) PollEvents() {}
+
+// WaitEvents does nothing, as input is injected.
+func (w *Window) WaitEvents() {}
+
+// SwapBuffers records the end of a frame.
+func (w *Window) SwapBuffers() {
+	endFrame()
+	record("SwapBuffers")
+}
+
+// Scene returns the window scene.
+func (w *Window) Scene() *Scene {
+	return w.scene
+}
+
+// ShowLoading records the loading screen progress.
+func (w *Window) ShowLoading(progress float64, status string) {
+	record("ShowLoading", progress, status)
+}
+
+// HideLoading records the end of the loading screen.
+func (w *Window) HideLoading() {
+	record("HideLoading")
+}
+
+// BindDefaultFramebuffer makes the window the current render target, limited
+// to the region set with SetViewport.
+func (w *Window) BindDefaultFramebuffer() {
+	record("BindFramebuffer", uint32(0))
+	r := w.Viewport()
+	record("Viewport", r.Min.X, w.Height-r.Max.Y, r.Dx(), r.Dy())
+}
+
+type shaderSource struct {
+	src   string
+	stage ShaderStage
+}
+
+// Shader is a fake shader program. Linking always succeeds.
+type Shader struct {
+	shaderFiles []shaderSource
+	program     uint32
+}
+
+func (s *Shader) VertexShader(src string) *Shader {
+	s.shaderFiles = append(s.shaderFiles, shaderSource{preprocessGLSL(src), StageVertex})
+	return s
+}
+
+func (s *Shader) FragmentShader(src string) *Shader {
+	s.shaderFiles = append(s.shaderFiles, shaderSource{preprocessGLSL(src), StageFragment})
+	return s
+}
+
+// Link records the program creation.
+func (s *Shader) Link() error {
+	s.program = newHandle()
+	for _, f := range s.shaderFiles {
+		record("CompileShader", f.stage)
+	}
+	record("LinkProgram", s.program)
+	trackAlloc(resProgram, 1)
+	return nil
+}
+
+// Delete releases the program.
+func (s *Shader) Delete() {
+	if s.program == 0 {
+		return
+	}
+	record("DeleteProgram", s.program)
+	trackFree(resProgram, 1)
+	s.program = 0
+}
+
+// Use makes the program current. It panics if the shader is not linked.
+func (s *Shader) Use() {
+	if s.program == 0 {
+		panic("shader program not linked; call Shader.Link() first")
+	}
+	record("UseProgram", s.program)
+}
+
+func (s *Shader) UniformInts(name string, v ...int32) error {
+	if s.program == 0 {
+		return ErrShaderNotLinked
+	}
+	record("Uniform", name, v)
+	return nil
+}
+
+func (s *Shader) UniformFloats(name string, v ...float32) error {
+	if s.program == 0 {
+		return ErrShaderNotLinked
+	}
+	record("Uniform", name, v)
+	return nil
+}
+
+func (s *Shader) UniformTransformation(name string, m glm.Mat4) error {
+	if s.program == 0 {
+		return ErrShaderNotLinked
+	}
+	record("UniformMatrix", name, m)
+	return nil
+}
+
+// UniformBlock records the uniform block binding.
+func (s *Shader) UniformBlock(name string, binding uint32) error {
+	if s.program == 0 {
+		return ErrShaderNotLinked
+	}
+	record("UniformBlockBinding", name, binding)
+	return nil
+}
+
+// Scene represents a graph of elements to be drawn on screen.
+type Scene struct {
+	cam *Camera
+
+	tex        *Texture
+	clearColor color.Color
+	wireFrames bool
+
+	vao      uint32
+	vboSize  int
+	vboBytes int
+}
+
+// NewScene initializes an empty scene.
+func NewScene() *Scene {
+	return &Scene{cam: NewCamera()}
+}
+
+// Camera returns the scene camera.
+func (s *Scene) Camera() *Camera {
+	return s.cam
+}
+
+func (s *Scene) BgColor(c color.Color) {
+	s.clearColor = c
+}
+
+func (s *Scene) allocateBuffers() {
+	if s.vao == 0 {
+		s.vao = newHandle()
+		trackAlloc(resVertexArray, 1)
+		trackAlloc(resBuffer, 1)
+	}
+}
+
+// Delete releases the scene buffers.
+func (s *Scene) Delete() {
+	if s.vao == 0 {
+		return
+	}
+	record("DeleteVertexArray", s.vao)
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&s.vboBytes, 0)
+	s.vao, s.vboSize = 0, 0
+}
+
+// addVertices records the upload of vertices with size elements each.
+func (s *Scene) addVertices(vertices []float32, size int) {
+	s.allocateBuffers()
+	s.vboSize += len(vertices) / size
+	trackBufferBytes(&s.vboBytes, len(vertices)*4)
+	record("BufferData", s.vao, len(vertices)*4)
+}
+
+// AddTriangles adds the provided vertices and indices to the current scene.
+func (s *Scene) AddTriangles(vertices []float32, indices []uint32) {
+	s.addVertices(vertices, 8)
+}
+
+func (s *Scene) AddVertices(vertices []float32) {
+	s.addVertices(vertices, 5)
+}
+
+func (s *Scene) AddLitVertices(vertices []float32) {
+	s.addVertices(vertices, 8)
+}
+
+func (s *Scene) AddMappedVertices(vertices []float32) {
+	s.addVertices(vertices, VertexSizeMapped)
+}
+
+func (s *Scene) AddTintedVertices(vertices []float32) {
+	s.addVertices(vertices, VertexSizeTinted)
+}
+
+func (s *Scene) AddTexture(tex *Texture) {
+	s.tex = tex
+}
+
+func (s *Scene) Clear() {
+	prepareClear()
+	if s.clearColor == nil {
+		s.clearColor = BgColor
+	}
+	record("Clear", s.clearColor)
+}
+
+func (s *Scene) Draw(shader *Shader) {
+	s.DrawState(nil, shader, DefaultPipeline)
+}
+
+// DrawState renders the scene like Draw, using the provided pipeline state
+// and the camera matrices of the frame. A nil frame uses the scene camera.
+func (s *Scene) DrawState(f *Frame, shader *Shader, state PipelineState) {
+	s.allocateBuffers()
+	if shader != nil {
+		s.setCamera(shader, f)
+	}
+	if s.tex != nil {
+		s.tex.Bind(0)
+	}
+	state.Wireframe = state.Wireframe || s.wireFrames
+	state.Apply()
+	countDraw(s.vboSize)
+	record("DrawArrays", 0, s.vboSize)
+}
+
+// UniformBuffer holds data shared by shader programs through uniform blocks.
+type UniformBuffer struct {
+	ubo  uint32
+	data []float32
+	size int
+}
+
+// NewUniformBuffer allocates an uniform buffer with space for size floats.
+func NewUniformBuffer(size int) *UniformBuffer {
+	trackAlloc(resBuffer, 1)
+	b := &UniformBuffer{ubo: newHandle(), data: make([]float32, size)}
+	trackBufferBytes(&b.size, size*4)
+	return b
+}
+
+// Update replaces the start of the buffer with the provided data.
+func (b *UniformBuffer) Update(data []float32) {
+	copy(b.data, data)
+	record("BufferSubData", b.ubo, len(data)*4)
+}
+
+// Bind attaches the buffer to the binding point used by the uniform blocks.
+func (b *UniformBuffer) Bind(binding uint32) {
+	record("BindBufferBase", binding, b.ubo)
+}
+
+// Delete releases the buffer.
+func (b *UniformBuffer) Delete() {
+	if b.ubo == 0 {
+		return
+	}
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&b.size, 0)
+	b.ubo = 0
+}
+
+// Texture is a fake texture, keeping its pixels in memory.
+type Texture struct {
+	tex    uint32
+	pixels []uint8
+}
+
+// Bind makes the texture available to the shaders at the texture unit.
+func (t *Texture) Bind(unit int) {
+	countTextureBind()
+	record("BindTexture", unit, t.tex)
+}
+
+// Delete releases the texture.
+func (t *Texture) Delete() {
+	if t.tex == 0 {
+		return
+	}
+	record("DeleteTexture", t.tex)
+	trackFree(resTexture, 1)
+	t.tex = 0
+}
+
+// newEmptyTexture allocates a texture without data, to be used as a render
+// target.
+func newEmptyTexture(width, height int, format TextureFormat) *Texture {
+	t := &Texture{tex: newHandle()}
+	trackAlloc(resTexture, 1)
+	record("TexImage2D", t.tex, width, height, format)
+	return t
+}
+
+func NewTexture(path string) (t *Texture, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &TextureError{Path: path, Format: "unknown", Err: err}
+	}
+	return NewTextureFromBytes(b)
+}
+
+// NewTextureFromBytes decodes the image, which must be valid, into a fake
+// texture.
+func NewTextureFromBytes(b []byte) (t *Texture, err error) {
+	w, h, pixels, err := decodeImage(b)
+	if err != nil {
+		return nil, err
+	}
+	t = &Texture{tex: newHandle(), pixels: pixels}
+	trackAlloc(resTexture, 1)
+	record("TexImage2D", t.tex, w, h, FormatRGBA8)
+	return t, nil
+}
+
+// Reload replaces the pixels of the texture with the image file in b, keeping
+// the same handle. On errors the old pixels are kept.
+func (t *Texture) Reload(b []byte) error {
+	w, h, pixels, err := decodeImage(b)
+	if err != nil {
+		return err
+	}
+	t.pixels = pixels
+	record("TexImage2D", t.tex, w, h, FormatRGBA8)
+	return nil
+}
+
+// Framebuffer is an off-screen render target, with one or more color
+// textures and a depth texture.
+type Framebuffer struct {
+	Width, Height int
+
+	fbo     uint32
+	formats []TextureFormat
+	color   []*Texture
+	depth   *Texture
+}
+
+// NewFramebuffer creates a framebuffer of the given size, with one color
+// texture for each of the provided formats and a depth texture.
+func NewFramebuffer(width, height int, formats ...TextureFormat) (*Framebuffer, error) {
+	f := &Framebuffer{fbo: newHandle(), formats: formats}
+	trackAlloc(resFramebuffer, 1)
+	if err := f.Resize(width, height); err != nil {
+		f.Delete()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Resize recreates the framebuffer textures with the new size.
+func (f *Framebuffer) Resize(width, height int) error {
+	f.deleteTextures()
+	f.Width, f.Height = width, height
+	for _, format := range f.formats {
+		f.color = append(f.color, newEmptyTexture(width, height, format))
+	}
+	f.depth = newEmptyTexture(width, height, FormatDepth)
+	return nil
+}
+
+// Bind makes the framebuffer the current render target.
+func (f *Framebuffer) Bind() {
+	record("BindFramebuffer", f.fbo)
+	record("Viewport", 0, 0, f.Width, f.Height)
+}
+
+// Clear resets the framebuffer textures.
+func (f *Framebuffer) Clear() {
+	prepareClear()
+	record("Clear", color.RGBA{})
+}
+
+// Color returns the i-th color texture.
+func (f *Framebuffer) Color(i int) *Texture {
+	return f.color[i]
+}
+
+// Depth returns the depth texture.
+func (f *Framebuffer) Depth() *Texture {
+	return f.depth
+}
+
+// ReadPixels returns a transparent image of the framebuffer size.
+func (f *Framebuffer) ReadPixels(i int) *image.RGBA {
+	record("ReadPixels", f.fbo, i)
+	return image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+}
+
+// Readback is a copy of framebuffer pixels to the CPU started by
+// Framebuffer.ReadPixelsAsync. The fake backend completes it immediately.
+type Readback struct {
+	// Rect is the area read, in image coordinates.
+	Rect image.Rectangle
+
+	pbo uint32
+}
+
+// ReadPixelsAsync records the read and returns a Readback that is ready.
+func (f *Framebuffer) ReadPixelsAsync(i int, r image.Rectangle) *Readback {
+	r, x, y := f.readRect(r)
+	rb := &Readback{Rect: r, pbo: newHandle()}
+	trackAlloc(resBuffer, 1)
+	record("ReadPixelsAsync", f.fbo, i, x, y, r.Dx(), r.Dy())
+	return rb
+}
+
+// Ready always returns true.
+func (rb *Readback) Ready() bool {
+	return true
+}
+
+// Image returns a transparent image of the rectangle.
+func (rb *Readback) Image() *image.RGBA {
+	if rb.pbo == 0 {
+		return nil
+	}
+	return readbackImage(rb.Rect, nil)
+}
+
+// Delete releases the pixel buffer.
+func (rb *Readback) Delete() {
+	if rb.pbo != 0 {
+		trackFree(resBuffer, 1)
+		rb.pbo = 0
+	}
+}
+
+func (f *Framebuffer) deleteTextures() {
+	for _, t := range f.color {
+		t.Delete()
+	}
+	f.color = nil
+	if f.depth != nil {
+		f.depth.Delete()
+		f.depth = nil
+	}
+}
+
+// Delete releases the framebuffer and its textures.
+func (f *Framebuffer) Delete() {
+	f.deleteTextures()
+	if f.fbo != 0 {
+		trackFree(resFramebuffer, 1)
+		f.fbo = 0
+	}
+}
+
+// CubeMap is a cube texture that can also be used as a render target, one face
+// at a time.
+type CubeMap struct {
+	Size int
+
+	tex uint32
+	fbo uint32
+}
+
+// NewCubeMap allocates a cube map with square faces of the provided size.
+func NewCubeMap(size int) (*CubeMap, error) {
+	c := &CubeMap{Size: size, tex: newHandle(), fbo: newHandle()}
+	trackAlloc(resTexture, 1)
+	trackAlloc(resFramebuffer, 1)
+	trackAlloc(resBuffer, 1)
+	return c, nil
+}
+
+// BindFace makes the face, in the order +X, -X, +Y, -Y, +Z, -Z, the current
+// render target.
+func (c *CubeMap) BindFace(face int) {
+	record("BindFramebuffer", c.fbo, face)
+	record("Viewport", 0, 0, c.Size, c.Size)
+}
+
+// Bind makes the cube map available to the shaders at the texture unit.
+func (c *CubeMap) Bind(unit int) {
+	countTextureBind()
+	record("BindTexture", unit, c.tex)
+}
+
+// Delete releases the cube map.
+func (c *CubeMap) Delete() {
+	if c.tex == 0 {
+		return
+	}
+	trackFree(resTexture, 1)
+	trackFree(resFramebuffer, 1)
+	trackFree(resBuffer, 1)
+	c.tex, c.fbo = 0, 0
+}
+
+// VolumeTexture is a 3D texture of RGBA8 voxels.
+type VolumeTexture struct {
+	Width, Height, Depth int
+
+	tex uint32
+}
+
+// NewVolumeTexture allocates an empty volume of width x height x depth voxels.
+func NewVolumeTexture(width, height, depth int) *VolumeTexture {
+	trackAlloc(resTexture, 1)
+	return &VolumeTexture{Width: width, Height: height, Depth: depth, tex: newHandle()}
+}
+
+// Update replaces all voxels with pixels.
+func (v *VolumeTexture) Update(pixels []uint8) {
+	record("TexSubImage3D", v.tex, len(pixels))
+}
+
+// Bind makes the volume available to the shaders at the texture unit.
+func (v *VolumeTexture) Bind(unit int) {
+	countTextureBind()
+	record("BindTexture", unit, v.tex)
+}
+
+// Delete releases the volume.
+func (v *VolumeTexture) Delete() {
+	if v.tex == 0 {
+		return
+	}
+	trackFree(resTexture, 1)
+	v.tex = 0
+}
+
+// TextureArray is a stack of 2D textures of the same size.
+type TextureArray struct {
+	Width, Height, Layers int
+
+	tex uint32
+}
+
+// NewTextureArray allocates an empty texture array.
+func NewTextureArray() *TextureArray {
+	trackAlloc(resTexture, 1)
+	return &TextureArray{tex: newHandle()}
+}
+
+// Update replaces the layers with the images.
+func (t *TextureArray) Update(layers []*image.RGBA) {
+	w, h, px := layerPixels(layers)
+	if len(px) == 0 {
+		return
+	}
+	t.Width, t.Height, t.Layers = w, h, len(layers)
+	record("TexImage3D", t.tex, w, h, len(layers))
+}
+
+// Bind makes the array available to the shaders at the texture unit.
+func (t *TextureArray) Bind(unit int) {
+	countTextureBind()
+	record("BindTexture", unit, t.tex)
+}
+
+// Delete releases the array.
+func (t *TextureArray) Delete() {
+	if t.tex == 0 {
+		return
+	}
+	trackFree(resTexture, 1)
+	t.tex = 0
+}
+
+// DynamicMesh is a small vertex buffer updated frequently. Vertices have 5
+// elements: the x,y,z coordinate and the texture coordinate.
+type DynamicMesh struct {
+	vao   uint32
+	count int
+	// vertices are the last uploaded vertices, so tests can inspect them.
+	vertices []float32
+	bytes    int
+}
+
+// NewDynamicMesh allocates an empty dynamic mesh.
+func NewDynamicMesh() *DynamicMesh {
+	trackAlloc(resVertexArray, 1)
+	trackAlloc(resBuffer, 1)
+	return &DynamicMesh{vao: newHandle()}
+}
+
+// Update replaces the mesh vertices.
+func (m *DynamicMesh) Update(vertices []float32) {
+	m.count = len(vertices) / 5
+	m.vertices = append(m.vertices[:0], vertices...)
+	trackBufferBytes(&m.bytes, len(vertices)*4)
+	record("BufferData", m.vao, len(vertices)*4)
+}
+
+// Draw records the draw of the mesh triangles.
+func (m *DynamicMesh) Draw() {
+	if m.count == 0 {
+		return
+	}
+	countDraw(m.count)
+	record("DrawArrays", 0, m.count)
+}
+
+// Delete releases the mesh buffers.
+func (m *DynamicMesh) Delete() {
+	if m.vao == 0 {
+		return
+	}
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&m.bytes, 0)
+	m.vao, m.count, m.vertices = 0, 0, nil
+}
+
+// MeshBuffer is a large vertex buffer shared by many meshes. The fake keeps
+// its contents in memory.
+type MeshBuffer struct {
+	layout VertexLayout
+	vao    uint32
+	data   []byte
+	size   int
+}
+
+// NewMeshBuffer allocates a vertex buffer with size bytes using the layout.
+func NewMeshBuffer(layout VertexLayout, size int) *MeshBuffer {
+	trackAlloc(resVertexArray, 1)
+	trackAlloc(resBuffer, 1)
+	b := &MeshBuffer{layout: layout, vao: newHandle(), data: make([]byte, size)}
+	trackBufferBytes(&b.size, size)
+	return b
+}
+
+// Size returns the buffer capacity, in bytes.
+func (b *MeshBuffer) Size() int {
+	return len(b.data)
+}
+
+// Grow enlarges the buffer to size bytes, keeping its contents.
+func (b *MeshBuffer) Grow(size int) {
+	if size > len(b.data) {
+		b.data = append(b.data, make([]byte, size-len(b.data))...)
+		trackBufferBytes(&b.size, size)
+		record("BufferData", b.vao, size)
+	}
+}
+
+// Relocate moves the ranges of the buffer, as returned by Arena.Compact.
+func (b *MeshBuffer) Relocate(moves []ArenaMove) {
+	old := append([]byte(nil), b.data...)
+	for _, m := range moves {
+		copy(b.data[m.To:m.To+m.Size], old[m.From:m.From+m.Size])
+	}
+	record("CopyBufferSubData", b.vao, len(moves))
+}
+
+// Write copies the data into the buffer at the offset, in bytes.
+func (b *MeshBuffer) Write(offset int, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	copy(b.data[offset:], data)
+	record("BufferSubData", b.vao, offset, len(data))
+}
+
+// Bind prepares the buffer for DrawRange calls.
+func (b *MeshBuffer) Bind() {
+	record("BindVertexArray", b.vao)
+}
+
+// DrawRange records the draw of count vertices starting at first.
+func (b *MeshBuffer) DrawRange(first, count int) {
+	countDraw(count)
+	record("DrawArrays", first, count)
+}
+
+// setChunkOffset records the per-draw chunk offset attribute.
+func (b *MeshBuffer) setChunkOffset(x, y, z float32) {
+	record("VertexAttrib3f", ChunkOffsetLocation, x, y, z)
+}
+
+// drawIndirect is not available, so the per-draw path is recorded.
+func (b *MeshBuffer) drawIndirect(cmds []DrawCommand, offsets []float32) bool {
+	return false
+}
+
+// Delete releases the buffer.
+func (b *MeshBuffer) Delete() {
+	if b.vao == 0 {
+		return
+	}
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&b.size, 0)
+	b.vao, b.data = 0, nil
+}
+
+// ComputeMesher is never available on the fake backend.
+type ComputeMesher struct{}
+
+// ComputeMeshingSupported returns false.
+func ComputeMeshingSupported() bool {
+	return false
+}
+
+// NewComputeMesher returns ErrNotImplemented.
+func NewComputeMesher() (*ComputeMesher, error) {
+	return nil, ErrNotImplemented
+}
+
+// Mesh returns ErrNotImplemented.
+func (m *ComputeMesher) Mesh(dims [3]int, blocks, opaque []uint32, dst *MeshBuffer, offset int) (int, error) {
+	return 0, ErrNotImplemented
+}
+
+// Delete does nothing.
+func (m *ComputeMesher) Delete() {}
+
+// SetShaderCacheDir does nothing, as fake shaders are not compiled.
+func SetShaderCacheDir(dir string) {}
+
+// fakeCaps are the capabilities reported by the fake backend.
+var fakeCaps = Caps{
+	Backend:               "fake",
+	Major:                 3,
+	Minor:                 3,
+	MaxTextureSize:        16384,
+	MaxArrayTextureLayers: 2048,
+	MaxSamples:            8,
+	Instancing:            true,
+	FloatRenderTargets:    true,
+}
+
+// SetCapabilities replaces the capabilities reported by Capabilities, so tests
+// can exercise the code paths of weaker GPUs. It is only available on the
+// fake backend.
+func SetCapabilities(c Caps) {
+	fakeCaps = c
+	caps = nil
+}
+
+func queryCaps() *Caps {
+	c := fakeCaps
+	c.Extensions = append([]string(nil), c.Extensions...)
+	return &c
+}
+
+// clearDepth resets the depth buffer of the current render target.
+func clearDepth() {
+	prepareClear()
+	record("ClearDepth")
+}
+
+// setClipControl records the clip depth range change, and returns false if
+// the capabilities have no clip control.
+func setClipControl(zeroToOne bool) bool {
+	if !Capabilities().ClipControl {
+		return false
+	}
+	record("ClipControl", zeroToOne)
+	return true
+}
+
+// setClearDepth changes the value used to clear the depth buffer.
+func setClearDepth(v float32) {
+	record("ClearDepthValue", v)
+}
+
+// applyPipeline records the pipeline state changes.
+func applyPipeline(old *PipelineState, p PipelineState) {
+	record("Pipeline", p)
+}
+
+// DrawFullscreen records the draw of the fullscreen triangle.
+func DrawFullscreen() {
+	countDraw(3)
+	record("DrawArrays", 0, 3)
+}
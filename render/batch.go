@@ -0,0 +1,248 @@
+package render
+
+import (
+	"encoding/binary"
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// AttribType is the component type of a vertex attribute.
+type AttribType int
+
+const (
+	// AttribFloat attributes are read as floats.
+	AttribFloat AttribType = iota
+	// AttribUint attributes are 32 bit unsigned integers, read by the
+	// shaders as uint or uvec values.
+	AttribUint
+)
+
+// VertexAttrib describes a vertex attribute stored in a MeshBuffer. Offset is
+// in bytes from the start of the vertex.
+type VertexAttrib struct {
+	Location uint32
+	Size     int32
+	Offset   int
+	Type     AttribType
+}
+
+// VertexLayout describes how vertices are stored in a MeshBuffer. Stride is
+// the vertex size, in bytes.
+type VertexLayout struct {
+	Stride  int
+	Attribs []VertexAttrib
+}
+
+// TintedLayout is the layout of the vertices produced by the chunk mesher, the
+// same used by Scene.AddTintedVertices.
+var TintedLayout = VertexLayout{
+	Stride: VertexSizeTinted * 4,
+	Attribs: []VertexAttrib{
+		{Location: 0, Size: 3, Offset: 0},
+		{Location: 1, Size: 2, Offset: 3 * 4},
+		{Location: 2, Size: 3, Offset: 5 * 4},
+		{Location: 5, Size: 3, Offset: 8 * 4},
+	},
+}
+
+// PackedLayout is the layout of the quantized vertices produced by the chunk
+// mesher, 12 bytes each, unpacked by PackedVertexGLSL.
+var PackedLayout = VertexLayout{
+	Stride: 12,
+	Attribs: []VertexAttrib{
+		{Location: 0, Size: 3, Offset: 0, Type: AttribUint},
+	},
+}
+
+// PackedVertexGLSL declares the packed vertex attribute and a function that
+// decodes it. It must be included after the #version statement of vertex
+// shaders drawing meshes with the PackedLayout. Positions are stored offset by
+// mesh.PackedBias, one block.
+const PackedVertexGLSL = `
+layout (location = 0) in uvec3 aPacked;
+
+struct Vertex {
+    vec3 position; // relative to the chunk origin
+    vec2 uv;
+    vec3 normal;
+    vec3 light;
+    vec3 tint;
+    float ao;      // 0 (occluded) to 1
+    float layer;   // of the block TextureArray
+};
+
+const vec3 faceNormals[6] = vec3[6](
+    vec3(1, 0, 0), vec3(-1, 0, 0),
+    vec3(0, 1, 0), vec3(0, -1, 0),
+    vec3(0, 0, 1), vec3(0, 0, -1)
+);
+
+Vertex unpackVertex() {
+    Vertex v;
+    uint w0 = aPacked.x;
+    uint w1 = aPacked.y;
+    uint w2 = aPacked.z;
+    v.position = vec3(float(w0 & 511u), float((w0 >> 9) & 511u), float((w0 >> 18) & 511u)) / 16.0 - 1.0;
+    v.normal = faceNormals[(w0 >> 27) & 7u];
+    v.ao = float((w0 >> 30) & 3u) / 3.0;
+    v.uv = vec2(float(w1 & 511u), float((w1 >> 9) & 511u)) / 16.0;
+    v.light = vec3(float((w1 >> 18) & 15u), float((w1 >> 22) & 15u), float((w1 >> 26) & 15u)) / 15.0;
+    v.tint = vec3(float(w2 & 255u), float((w2 >> 8) & 255u), float((w2 >> 16) & 255u)) / 255.0;
+    v.layer = float(w2 >> 24);
+    return v;
+}
+`
+
+// Float32Bytes encodes the floats in the little endian byte order used by the
+// GPU, to be written into a MeshBuffer.
+func Float32Bytes(v []float32) []byte {
+	b := make([]byte, len(v)*4)
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(f))
+	}
+	return b
+}
+
+// DrawCommand describes one mesh in a shared buffer. The fields mirror the
+// DrawArraysIndirectCommand structure consumed by glMultiDrawArraysIndirect.
+type DrawCommand struct {
+	Count         uint32
+	InstanceCount uint32
+	First         uint32
+	BaseInstance  uint32
+}
+
+// ChunkOffsetLocation is the vertex attribute location with the chunk world
+// position, set for each mesh drawn by the ChunkBatch.
+const ChunkOffsetLocation = 7
+
+// ChunkOffsetGLSL declares the chunk offset attribute. It must be included in
+// the vertex shaders drawing a ChunkBatch, which must add it to the vertex
+// positions.
+const ChunkOffsetGLSL = `
+layout (location = 7) in vec3 aChunkOffset;
+`
+
+// ChunkBatch keeps the meshes of many chunks in a single shared MeshBuffer and
+// submits them together, avoiding a buffer bind per chunk. The buffer space is
+// managed by an Arena, so chunks streaming in and out reuse the freed ranges.
+//
+// On OpenGL 4.3 all chunks are submitted with a single
+// glMultiDrawArraysIndirect call, and the chunk offsets are read from an
+// instanced attribute. Elsewhere, including WebGL, a draw call is issued per
+// chunk with a constant attribute value.
+type ChunkBatch struct {
+	// MaxFragmentation is the fraction of fragmented free space that triggers
+	// a compaction when an allocation fails, instead of growing the buffer.
+	MaxFragmentation float32
+
+	buf      *MeshBuffer
+	arena    *Arena
+	commands map[[3]int]DrawCommand
+
+	// Per frame lists of visible chunks.
+	cmds    []DrawCommand
+	offsets []float32
+}
+
+// NewChunkBatch creates a batch with space for initial bytes of vertex data
+// using the layout. The buffer grows as needed.
+func NewChunkBatch(layout VertexLayout, initial int) *ChunkBatch {
+	return &ChunkBatch{
+		MaxFragmentation: 0.5,
+		buf:              NewMeshBuffer(layout, initial),
+		arena:            NewArena(initial, layout.Stride),
+		commands:         map[[3]int]DrawCommand{},
+	}
+}
+
+// Set uploads the mesh of the chunk at the chunk coordinates cx, cy, cz,
+// replacing any previous mesh of the same chunk.
+func (b *ChunkBatch) Set(cx, cy, cz int, vertices []byte) {
+	b.Remove(cx, cy, cz)
+	if len(vertices) == 0 {
+		return
+	}
+	stride := b.buf.layout.Stride
+	offset, ok := b.arena.Alloc(len(vertices))
+	if !ok && b.arena.Fragmentation() > b.MaxFragmentation &&
+		b.arena.Size()-b.arena.Used() >= len(vertices) {
+		b.compact()
+		offset, ok = b.arena.Alloc(len(vertices))
+	}
+	for !ok {
+		// The free space may be fragmented, so grow enough for the
+		// vertices to fit in the new space at the end.
+		size := b.arena.Size() * 2
+		if size == 0 {
+			size = len(vertices)
+		}
+		for size < b.arena.Size()+len(vertices) {
+			size *= 2
+		}
+		b.buf.Grow(size)
+		b.arena.Grow(size)
+		offset, ok = b.arena.Alloc(len(vertices))
+	}
+	b.buf.Write(offset, vertices)
+	b.commands[[3]int{cx, cy, cz}] = DrawCommand{
+		Count:         uint32(len(vertices) / stride),
+		InstanceCount: 1,
+		First:         uint32(offset / stride),
+	}
+}
+
+// compact moves all meshes to the start of the buffer.
+func (b *ChunkBatch) compact() {
+	stride := b.buf.layout.Stride
+	moves := b.arena.Compact()
+	b.buf.Relocate(moves)
+	first := make(map[uint32]uint32, len(moves))
+	for _, m := range moves {
+		first[uint32(m.From/stride)] = uint32(m.To / stride)
+	}
+	for k, cmd := range b.commands {
+		cmd.First = first[cmd.First]
+		b.commands[k] = cmd
+	}
+}
+
+// Remove drops the mesh of the chunk, releasing its buffer space.
+func (b *ChunkBatch) Remove(cx, cy, cz int) {
+	k := [3]int{cx, cy, cz}
+	if cmd, ok := b.commands[k]; ok {
+		b.arena.Free(int(cmd.First) * b.buf.layout.Stride)
+		delete(b.commands, k)
+	}
+}
+
+// Draw renders all chunk meshes with the shader, which must use the
+// ChunkOffsetGLSL attribute. Chunks for which visible returns false are
+// skipped; a nil function draws all chunks.
+func (b *ChunkBatch) Draw(shader *Shader, chunkSize glm.Vec3, visible func(cx, cy, cz int) bool) {
+	shader.Use()
+	b.buf.Bind()
+	b.cmds, b.offsets = b.cmds[:0], b.offsets[:0]
+	for k, cmd := range b.commands {
+		if visible != nil && !visible(k[0], k[1], k[2]) {
+			continue
+		}
+		cmd.BaseInstance = uint32(len(b.cmds))
+		b.cmds = append(b.cmds, cmd)
+		b.offsets = append(b.offsets, float32(k[0])*chunkSize.X(), float32(k[1])*chunkSize.Y(), float32(k[2])*chunkSize.Z())
+	}
+	countChunks(len(b.cmds), len(b.commands)-len(b.cmds))
+	if b.buf.drawIndirect(b.cmds, b.offsets) {
+		return
+	}
+	for i, cmd := range b.cmds {
+		b.buf.setChunkOffset(b.offsets[i*3], b.offsets[i*3+1], b.offsets[i*3+2])
+		b.buf.DrawRange(int(cmd.First), int(cmd.Count))
+	}
+}
+
+// Delete releases the shared buffer.
+func (b *ChunkBatch) Delete() {
+	b.buf.Delete()
+}
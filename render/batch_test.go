@@ -0,0 +1,130 @@
+//go:build openvoxel_fake
+
+package render
+
+import (
+	"bytes"
+	"testing"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/render/fake"
+)
+
+// testStride is the vertex size of the meshes in the batch tests.
+const testStride = 4
+
+// testMesh returns n vertices filled with the byte v.
+func testMesh(n int, v byte) []byte {
+	return bytes.Repeat([]byte{v}, n*testStride)
+}
+
+// checkDraws draws the batch and checks that each chunk in want is drawn once,
+// at its offset, with its own vertices.
+func checkDraws(t *testing.T, b *ChunkBatch, want map[[3]int][]byte) {
+	t.Helper()
+	shader := &Shader{}
+	shader.Link()
+	defer shader.Delete()
+	fake.Default.Reset()
+	b.Draw(shader, glm.Vec3{16, 16, 16}, nil)
+
+	var offset [3]int
+	drawn := map[[3]int]bool{}
+	for _, c := range fake.Default.Calls() {
+		switch c.Name {
+		case "VertexAttrib3f":
+			for i := range offset {
+				offset[i] = int(c.Args[i+1].(float32)) / 16
+			}
+		case "DrawArrays":
+			first, count := c.Args[0].(int), c.Args[1].(int)
+			mesh, ok := want[offset]
+			if !ok {
+				t.Errorf("drew unexpected chunk %v", offset)
+				continue
+			}
+			if drawn[offset] {
+				t.Errorf("drew chunk %v twice", offset)
+			}
+			drawn[offset] = true
+			got := b.buf.data[first*testStride : (first+count)*testStride]
+			if !bytes.Equal(got, mesh) {
+				t.Errorf("chunk %v: drew %v, want %v", offset, got, mesh)
+			}
+		}
+	}
+	for p := range want {
+		if !drawn[p] {
+			t.Errorf("chunk %v not drawn", p)
+		}
+	}
+}
+
+// testBatch returns a batch with initial bytes, and functions to set and
+// remove meshes that keep want up to date.
+func testBatch(initial int) (b *ChunkBatch, want map[[3]int][]byte, set func([3]int, []byte), remove func([3]int)) {
+	b = NewChunkBatch(VertexLayout{Stride: testStride}, initial)
+	want = map[[3]int][]byte{}
+	set = func(p [3]int, mesh []byte) {
+		b.Set(p[0], p[1], p[2], mesh)
+		want[p] = mesh
+	}
+	remove = func(p [3]int) {
+		b.Remove(p[0], p[1], p[2])
+		delete(want, p)
+	}
+	return b, want, set, remove
+}
+
+func TestChunkBatchGrow(t *testing.T) {
+	b, want, set, remove := testBatch(2 * testStride)
+	defer b.Delete()
+
+	set([3]int{0, 0, 0}, testMesh(2, 1))
+	set([3]int{1, 0, 0}, testMesh(2, 2))
+	set([3]int{0, 1, 0}, testMesh(1, 3))
+	checkDraws(t, b, want)
+
+	// A mesh larger than the whole buffer does not fit in the free space
+	// at the end if the buffer only doubles.
+	remove([3]int{0, 0, 0})
+	set([3]int{0, 0, 1}, testMesh(12, 4))
+	checkDraws(t, b, want)
+
+	// Replacing a mesh frees its old space.
+	set([3]int{1, 0, 0}, testMesh(3, 5))
+	checkDraws(t, b, want)
+
+	remove([3]int{1, 0, 0})
+	remove([3]int{0, 1, 0})
+	remove([3]int{0, 0, 1})
+	checkDraws(t, b, want)
+	if b.arena.Used() != 0 {
+		t.Errorf("arena has %d bytes used after removing all meshes", b.arena.Used())
+	}
+}
+
+func TestChunkBatchCompact(t *testing.T) {
+	b, want, set, remove := testBatch(9 * testStride)
+	defer b.Delete()
+
+	for i := 0; i < 4; i++ {
+		set([3]int{i, 0, 0}, testMesh(2, byte(i+1)))
+	}
+	set([3]int{0, 1, 0}, testMesh(1, 5))
+	remove([3]int{0, 0, 0})
+	remove([3]int{2, 0, 0})
+	remove([3]int{0, 1, 0})
+
+	// The free space is split in three ranges, none large enough, so the
+	// meshes are moved together instead of growing the buffer.
+	fake.Default.Reset()
+	set([3]int{0, 0, 1}, testMesh(4, 6))
+	if n := fake.Default.Count("CopyBufferSubData"); n != 1 {
+		t.Errorf("buffer compacted %d times, want 1", n)
+	}
+	if size := b.buf.Size(); size != 9*testStride {
+		t.Errorf("buffer grew to %d bytes, want it compacted", size)
+	}
+	checkDraws(t, b, want)
+}
@@ -0,0 +1,140 @@
+package render
+
+import (
+	"sort"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// Billboard is a textured quad in world space that always faces the camera,
+// such as player name tags and waypoint markers.
+type Billboard struct {
+	Position glm.Vec3
+	// Texture is the label image, usually rendered by the text subsystem.
+	Texture *Texture
+	// Width and Height are the quad size, in blocks.
+	Width, Height float32
+
+	// FadeStart and FadeEnd are the distances where the billboard starts to
+	// fade out and becomes invisible. FadeEnd zero disables fading.
+	FadeStart, FadeEnd float32
+	// Upright billboards only rotate around the vertical axis.
+	Upright bool
+	// SeeThrough billboards are drawn on top of the blocks in front of them,
+	// as waypoint markers usually are.
+	SeeThrough bool
+}
+
+// alpha returns the opacity of the billboard at the distance.
+func (b *Billboard) alpha(dist float32) float32 {
+	if b.FadeEnd <= 0 || dist <= b.FadeStart {
+		return 1
+	}
+	if dist >= b.FadeEnd {
+		return 0
+	}
+	return 1 - (dist-b.FadeStart)/(b.FadeEnd-b.FadeStart)
+}
+
+// BillboardRenderer draws the billboards in the scene.
+type BillboardRenderer struct {
+	billboards []*Billboard
+	quad       *DynamicMesh
+}
+
+// NewBillboardRenderer creates an empty billboard renderer.
+func NewBillboardRenderer() *BillboardRenderer {
+	r := &BillboardRenderer{quad: NewDynamicMesh()}
+	r.quad.Update([]float32{
+		-0.5, -0.5, 0, 0, 0,
+		0.5, -0.5, 0, 1, 0,
+		0.5, 0.5, 0, 1, 1,
+		-0.5, -0.5, 0, 0, 0,
+		0.5, 0.5, 0, 1, 1,
+		-0.5, 0.5, 0, 0, 1,
+	})
+	return r
+}
+
+// Add places a billboard in the scene.
+func (r *BillboardRenderer) Add(b *Billboard) {
+	r.billboards = append(r.billboards, b)
+}
+
+// Remove deletes a billboard from the scene.
+func (r *BillboardRenderer) Remove(b *Billboard) {
+	for i, o := range r.billboards {
+		if o == b {
+			r.billboards = append(r.billboards[:i], r.billboards[i+1:]...)
+			return
+		}
+	}
+}
+
+// Draw renders the billboards seen from the camera at eye, using the view and
+// projection matrices. The shader must use the model, view and projection
+// uniforms, sample the texture at unit 0 and multiply it by the alpha uniform.
+// Billboards are sorted back to front for correct blending.
+func (r *BillboardRenderer) Draw(shader *Shader, eye glm.Vec3, view, projection glm.Mat4) {
+	type item struct {
+		b    *Billboard
+		dist float32
+	}
+	visible := make([]item, 0, len(r.billboards))
+	for _, b := range r.billboards {
+		d := b.Position.Sub(eye).Len()
+		if b.Texture == nil || b.alpha(d) <= 0 {
+			continue
+		}
+		visible = append(visible, item{b, d})
+	}
+	if len(visible) == 0 {
+		return
+	}
+	sort.Slice(visible, func(i, j int) bool { return visible[i].dist > visible[j].dist })
+
+	// The inverse of the view rotation turns the quad towards the camera.
+	rot := view.Mat3().Transpose()
+	right, up := rot.Col(0), rot.Col(1)
+
+	shader.Use()
+	shader.UniformTransformation("view", view)
+	shader.UniformTransformation("projection", projection)
+	for _, it := range visible {
+		b := it.b
+		u := up
+		rt := right
+		if b.Upright {
+			u = glm.Vec3{0, 1, 0}
+			rt = u.Cross(b.Position.Sub(eye)).Normalize().Mul(-1)
+		}
+		n := rt.Cross(u)
+		model := glm.Mat4{
+			rt[0] * b.Width, rt[1] * b.Width, rt[2] * b.Width, 0,
+			u[0] * b.Height, u[1] * b.Height, u[2] * b.Height, 0,
+			n[0], n[1], n[2], 0,
+			b.Position[0], b.Position[1], b.Position[2], 1,
+		}
+		shader.UniformTransformation("model", model)
+		shader.UniformFloats("alpha", b.alpha(it.dist))
+		b.Texture.Bind(0)
+		state := OverlayPipeline
+		state.DepthTest = !b.SeeThrough
+		state.Apply()
+		r.quad.Draw()
+	}
+	DefaultPipeline.Apply()
+}
+
+// ScreenPosition returns where the billboard appears on a window of the
+// provided size, for 2D overlays such as off-screen waypoint arrows.
+func (b *Billboard) ScreenPosition(view, projection glm.Mat4, width, height int) (x, y float32, ok bool) {
+	x, y, _, ok = transform.WorldToScreen(b.Position, view, projection, width, height)
+	return x, y, ok
+}
+
+// Delete releases the billboard quad. Textures are not deleted.
+func (r *BillboardRenderer) Delete() {
+	r.quad.Delete()
+}
@@ -0,0 +1,93 @@
+package render
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// chunkWidth is the horizontal size of a chunk, in blocks.
+const chunkWidth = 16
+
+// Camera holds the viewer position and projection settings.
+type Camera struct {
+	pos   glm.Vec3
+	front glm.Vec3
+	up    glm.Vec3
+
+	// FOV is the vertical field of view, in degrees.
+	FOV float32
+	// Roll is the rotation around the view direction, in degrees. Positive
+	// values turn the camera clockwise, as seen from behind it.
+	Roll float32
+	// Near and Far are the distances of the clipping planes.
+	Near, Far float32
+	// FogStart and FogEnd are the distances where the fog starts and where
+	// it fully hides the terrain.
+	FogStart, FogEnd float32
+}
+
+func NewCamera() (c *Camera) {
+	c = &Camera{
+		pos:   glm.Vec3{-20, 4, 3},
+		front: glm.Vec3{0, 0, -1},
+		up:    glm.Vec3{0, 1, 0},
+		FOV:   45,
+		Near:  0.1,
+	}
+	c.SetRenderDistance(8)
+	return
+}
+
+// SetRenderDistance derives the far plane and the fog distances from the
+// render distance, in chunks, so the farthest loaded terrain, including the
+// chunk corners, fades into the fog before being clipped.
+func (c *Camera) SetRenderDistance(chunks int) {
+	d := float32(chunks * chunkWidth)
+	c.FogEnd = d
+	c.FogStart = d * 0.75
+	c.Far = d * float32(math.Sqrt2)
+}
+
+// Position returns the camera position.
+func (c *Camera) Position() glm.Vec3 {
+	return c.pos
+}
+
+// SetPosition moves the camera.
+func (c *Camera) SetPosition(p glm.Vec3) {
+	c.pos = p
+}
+
+// Front returns the direction the camera is looking at.
+func (c *Camera) Front() glm.Vec3 {
+	return c.front
+}
+
+// SetFront changes the direction the camera is looking at.
+func (c *Camera) SetFront(f glm.Vec3) {
+	c.front = f.Normalize()
+}
+
+// Follow places the camera behind the eye, at the distance along the
+// direction it is looking at, for the third person view. The distance should
+// be shortened with a raycast so the camera doesn't go through the blocks.
+func (c *Camera) Follow(eye glm.Vec3, distance float32) {
+	c.pos = eye.Sub(c.front.Mul(distance))
+}
+
+// View returns the view matrix.
+func (c *Camera) View() glm.Mat4 {
+	up := c.up
+	if c.Roll != 0 {
+		up = glm.HomogRotate3D(transform.DegToRad(c.Roll), c.front).Mul4x1(up.Vec4(0)).Vec3()
+	}
+	return transform.LookAt(c.pos, c.pos.Add(c.front), up)
+}
+
+// Projection returns the projection matrix for the aspect ratio, using the
+// current depth mode.
+func (c *Camera) Projection(aspect float32) glm.Mat4 {
+	return Projection(transform.DegToRad(c.FOV), aspect, c.Near, c.Far)
+}
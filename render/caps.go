@@ -0,0 +1,62 @@
+package render
+
+import "sort"
+
+// Caps describes the features of the graphics context, so higher level
+// systems can choose between code paths, such as a texture atlas or a texture
+// array, instead of failing on weaker GPUs.
+type Caps struct {
+	// Backend is "opengl", "webgl" or "fake".
+	Backend string
+	// Major and Minor are the context version, such as 3.3 for OpenGL or
+	// 2.0 for WebGL 2.
+	Major, Minor int
+
+	// MaxTextureSize is the largest width and height of a texture.
+	MaxTextureSize int
+	// MaxArrayTextureLayers is the largest number of layers in a texture
+	// array.
+	MaxArrayTextureLayers int
+	// MaxAnisotropy is the largest anisotropic filtering level, or zero if
+	// anisotropic filtering is not available.
+	MaxAnisotropy float32
+	// MaxSamples is the largest number of samples of multisampled render
+	// targets.
+	MaxSamples int
+
+	// Instancing is true if instanced draws are available.
+	Instancing bool
+	// Compute is true if compute shaders are available.
+	Compute bool
+	// MultiDrawIndirect is true if many meshes can be drawn with a single
+	// indirect draw call, as used by MeshBuffer.
+	MultiDrawIndirect bool
+	// ClipControl is true if the clip depth range can be changed, as used
+	// by the reversed depth buffer.
+	ClipControl bool
+	// FloatRenderTargets is true if float textures can be rendered to.
+	FloatRenderTargets bool
+
+	// Extensions are the names of the supported extensions, sorted.
+	Extensions []string
+}
+
+// HasExtension returns true if the extension, such as
+// "GL_EXT_texture_filter_anisotropic", is supported.
+func (c *Caps) HasExtension(name string) bool {
+	i := sort.SearchStrings(c.Extensions, name)
+	return i < len(c.Extensions) && c.Extensions[i] == name
+}
+
+var caps *Caps
+
+// Capabilities returns the features of the current graphics context. It must
+// be called after the context is created; the result is queried once and
+// reused.
+func Capabilities() *Caps {
+	if caps == nil {
+		caps = queryCaps()
+		sort.Strings(caps.Extensions)
+	}
+	return caps
+}
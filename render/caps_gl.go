@@ -0,0 +1,47 @@
+//go:build !js && !openvoxel_fake
+
+package render
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// queryCaps reads the features of the current OpenGL context.
+func queryCaps() *Caps {
+	integer := func(p uint32) int {
+		var v int32
+		gl.GetIntegerv(p, &v)
+		return int(v)
+	}
+	c := &Caps{
+		Backend:               "opengl",
+		Major:                 integer(gl.MAJOR_VERSION),
+		Minor:                 integer(gl.MINOR_VERSION),
+		MaxTextureSize:        integer(gl.MAX_TEXTURE_SIZE),
+		MaxArrayTextureLayers: integer(gl.MAX_ARRAY_TEXTURE_LAYERS),
+		MaxSamples:            integer(gl.MAX_SAMPLES),
+		// Instancing and float render targets are core since OpenGL 3.3.
+		Instancing:         true,
+		FloatRenderTargets: true,
+		Compute:            hasGL(4, 3),
+		MultiDrawIndirect:  hasGL(4, 3),
+		ClipControl:        hasGL(4, 5),
+	}
+	for i, n := 0, integer(gl.NUM_EXTENSIONS); i < n; i++ {
+		c.Extensions = append(c.Extensions, gl.GoStr(gl.GetStringi(gl.EXTENSIONS, uint32(i))))
+	}
+	if hasGL(4, 6) || c.hasExtensionUnsorted("GL_EXT_texture_filter_anisotropic") || c.hasExtensionUnsorted("GL_ARB_texture_filter_anisotropic") {
+		gl.GetFloatv(gl.MAX_TEXTURE_MAX_ANISOTROPY, &c.MaxAnisotropy)
+	}
+	return c
+}
+
+// hasExtensionUnsorted is HasExtension before the extensions are sorted.
+func (c *Caps) hasExtensionUnsorted(name string) bool {
+	for _, e := range c.Extensions {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
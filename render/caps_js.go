@@ -0,0 +1,41 @@
+//go:build !openvoxel_fake
+
+package render
+
+// queryCaps reads the features of the current WebGL 2 context.
+func queryCaps() *Caps {
+	c := &Caps{
+		Backend: "webgl",
+		Major:   2,
+		// Instancing is core in WebGL 2, which has no compute shaders nor
+		// indirect draws.
+		Instancing: true,
+	}
+	if gl.IsUndefined() || gl.IsNull() {
+		return c
+	}
+	integer := func(p string) int {
+		return gl.Call("getParameter", gl.Get(p).Int()).Int()
+	}
+	c.MaxTextureSize = integer("MAX_TEXTURE_SIZE")
+	c.MaxArrayTextureLayers = integer("MAX_ARRAY_TEXTURE_LAYERS")
+	c.MaxSamples = integer("MAX_SAMPLES")
+	exts := gl.Call("getSupportedExtensions")
+	if !exts.IsNull() {
+		for i := 0; i < exts.Length(); i++ {
+			c.Extensions = append(c.Extensions, exts.Index(i).String())
+		}
+	}
+	for _, name := range c.Extensions {
+		switch name {
+		case "EXT_texture_filter_anisotropic":
+			ext := gl.Call("getExtension", name)
+			c.MaxAnisotropy = float32(gl.Call("getParameter", ext.Get("MAX_TEXTURE_MAX_ANISOTROPY_EXT")).Float())
+		case "EXT_clip_control":
+			c.ClipControl = true
+		case "EXT_color_buffer_float":
+			c.FloatRenderTargets = true
+		}
+	}
+	return c
+}
@@ -0,0 +1,260 @@
+package render
+
+import (
+	"math"
+	"sort"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// cloudPeriod is the number of cells after which the cloud pattern repeats,
+// so the wind offset can wrap around without a visible jump.
+const cloudPeriod = 1024
+
+// CloudGLSL declares the uniforms set by Clouds.Bind, and functions to sample
+// the cloud coverage and the shadow of the clouds. Lit shaders multiply the
+// sun and sky light by cloudShadow(worldPos). It must be included after the
+// #version and precision statements.
+const CloudGLSL = `
+uniform sampler2D cloudMap;
+uniform int cloudTextured;
+uniform vec4 cloudParams;        // xy: wind offset, z: cell size, w: coverage
+uniform float cloudAltitude;
+uniform vec3 cloudSunDirection;
+uniform float cloudShadowStrength;
+
+float cloudHash(vec2 p) {
+    return fract(sin(dot(p, vec2(127.1, 311.7))) * 43758.5453);
+}
+
+float cloudNoise(vec2 p) {
+    vec2 i = floor(p);
+    vec2 f = fract(p);
+    f = f * f * (3.0 - 2.0 * f);
+    float a = cloudHash(mod(i, 256.0));
+    float b = cloudHash(mod(i + vec2(1.0, 0.0), 256.0));
+    float c = cloudHash(mod(i + vec2(0.0, 1.0), 256.0));
+    float d = cloudHash(mod(i + vec2(1.0, 1.0), 256.0));
+    return mix(mix(a, b, f.x), mix(c, d, f.x), f.y);
+}
+
+// cloudDensity returns 1 where the cloud layer covers the horizontal
+// position, and 0 where the sky is clear.
+float cloudDensity(vec2 xz) {
+    if (cloudParams.z <= 0.0) {
+        return 0.0;
+    }
+    vec2 cell = mod(floor((xz + cloudParams.xy) / cloudParams.z), 1024.0);
+    if (cloudTextured == 1) {
+        return texture(cloudMap, (cell + 0.5) / vec2(textureSize(cloudMap, 0))).a;
+    }
+    float n = cloudNoise(cell / 4.0) * 0.7 + cloudNoise(cell / 2.0) * 0.3;
+    return step(1.0 - cloudParams.w, n);
+}
+
+// cloudShadow returns the fraction of the sun light reaching the world
+// position through the clouds, blurred over a cell for soft edges.
+float cloudShadow(vec3 pos) {
+    if (cloudShadowStrength <= 0.0 || cloudSunDirection.y <= 0.0 || pos.y >= cloudAltitude) {
+        return 1.0;
+    }
+    vec2 p = pos.xz + cloudSunDirection.xz * (cloudAltitude - pos.y) / cloudSunDirection.y;
+    float h = cloudParams.z * 0.5;
+    float d = cloudDensity(p + vec2(-h, -h)) + cloudDensity(p + vec2(h, -h)) +
+        cloudDensity(p + vec2(-h, h)) + cloudDensity(p + vec2(h, h));
+    return 1.0 - cloudShadowStrength * d * 0.25;
+}
+`
+
+const cloudVertexGLSL = `
+layout (location = 0) in vec3 aPos;
+out vec3 WorldPos;
+
+uniform mat4 model;
+uniform mat4 view;
+uniform mat4 projection;
+
+void main() {
+    vec4 pos = model * vec4(aPos, 1.0);
+    WorldPos = pos.xyz;
+    gl_Position = projection * view * pos;
+}
+`
+
+const cloudFragmentGLSL = `
+out vec4 FragColor;
+in vec3 WorldPos;
+
+uniform vec3 eye;
+uniform float radius;
+uniform vec4 color;
+` + CloudGLSL + `
+void main() {
+    float d = cloudDensity(WorldPos.xz);
+    if (d <= 0.0) {
+        discard;
+    }
+    // Fade the layer out before its edge, so it blends with the sky.
+    float fade = 1.0 - smoothstep(radius * 0.6, radius, length(WorldPos.xz - eye.xz));
+    FragColor = vec4(color.rgb, color.a * d * fade);
+}
+`
+
+// Clouds draws a layer of clouds drifting with the wind, made of flat quads
+// centered on the camera, and provides the shadow they cast to the lit
+// shaders through CloudGLSL.
+type Clouds struct {
+	// Altitude is the height of the bottom of the layer.
+	Altitude float32
+	// Slices stacks copies of the layer, Thickness blocks apart in total,
+	// for thicker looking clouds. Zero and one draw a single flat layer.
+	Slices    int
+	Thickness float32
+	// Radius is the distance from the camera where the layer ends.
+	Radius float32
+	// CellSize is the size, in blocks, of each cloud cell.
+	CellSize float32
+	// Coverage is the fraction of the sky covered by the procedural clouds,
+	// from 0 to 1.
+	Coverage float32
+	// Texture, if set, replaces the procedural clouds: each texel covers a
+	// cell, with the coverage in the alpha channel. Its size must be a power
+	// of two up to 1024.
+	Texture *Texture
+	// Color is the color of the clouds at noon, with the alpha as their
+	// opacity. They get darker at night.
+	Color glm.Vec4
+	// WindSpeed is in blocks per second, and WindDirection the angle, in
+	// radians, from the +X towards the +Z axis.
+	WindSpeed, WindDirection float32
+	// ShadowStrength is how much of the light the clouds block, from 0 to 1.
+	// Zero disables the shadows.
+	ShadowStrength float32
+
+	offset [2]float64
+	shader *Shader
+	quad   *DynamicMesh
+}
+
+// NewClouds creates a cloud layer with the default settings.
+func NewClouds() (*Clouds, error) {
+	c := &Clouds{
+		Altitude:       192,
+		Slices:         1,
+		Thickness:      4,
+		Radius:         256,
+		CellSize:       12,
+		Coverage:       0.4,
+		Color:          glm.Vec4{1, 1, 1, 0.8},
+		WindSpeed:      1.5,
+		ShadowStrength: 0.3,
+	}
+	c.shader = &Shader{}
+	c.shader.VertexShader(glslVersion + cloudVertexGLSL).FragmentShader(glslVersion + cloudFragmentGLSL)
+	if err := c.shader.Link(); err != nil {
+		return nil, err
+	}
+	c.quad = NewDynamicMesh()
+	c.quad.Update([]float32{
+		-0.5, 0, -0.5, 0, 0,
+		0.5, 0, -0.5, 1, 0,
+		0.5, 0, 0.5, 1, 1,
+		-0.5, 0, -0.5, 0, 0,
+		0.5, 0, 0.5, 1, 1,
+		-0.5, 0, 0.5, 0, 1,
+	})
+	return c, nil
+}
+
+// Update moves the clouds with the wind for dt seconds.
+func (c *Clouds) Update(dt float32) {
+	d := float64(c.WindSpeed * dt)
+	a := float64(c.WindDirection)
+	period := float64(cloudPeriod * c.CellSize)
+	if period <= 0 {
+		return
+	}
+	// The layer moves with the wind, so the pattern is sampled against it.
+	c.offset[0] = math.Mod(c.offset[0]-d*math.Cos(a), period)
+	c.offset[1] = math.Mod(c.offset[1]-d*math.Sin(a), period)
+}
+
+// Bind sets the CloudGLSL uniforms of the shader, using the texture unit for
+// the cloud texture. The shader must be in use.
+func (c *Clouds) Bind(shader *Shader, unit int) {
+	if c.Texture != nil {
+		c.Texture.Bind(unit)
+		shader.UniformInts("cloudTextured", 1)
+	} else {
+		shader.UniformInts("cloudTextured", 0)
+	}
+	sun := environment.sun()
+	shader.UniformInts("cloudMap", int32(unit))
+	shader.UniformFloats("cloudParams", float32(c.offset[0]), float32(c.offset[1]), c.CellSize, c.Coverage)
+	shader.UniformFloats("cloudAltitude", c.Altitude)
+	shader.UniformFloats("cloudSunDirection", sun[0], sun[1], sun[2])
+	shader.UniformFloats("cloudShadowStrength", c.ShadowStrength)
+}
+
+// Draw renders the clouds seen from the camera at eye, using the view and
+// projection matrices. They are blended with the scene, so they must be drawn
+// after the opaque pass.
+func (c *Clouds) Draw(eye glm.Vec3, view, projection glm.Mat4) {
+	if c.Radius <= 0 || c.Color[3] <= 0 {
+		return
+	}
+	slices := c.Slices
+	if slices < 1 {
+		slices = 1
+	}
+	heights := make([]float32, slices)
+	for i := range heights {
+		if slices > 1 {
+			heights[i] = c.Altitude + c.Thickness*float32(i)/float32(slices-1)
+		} else {
+			heights[i] = c.Altitude
+		}
+	}
+	// Draw the slices from the farthest to the nearest for correct blending.
+	sort.Slice(heights, func(i, j int) bool {
+		return abs32(heights[i]-eye[1]) > abs32(heights[j]-eye[1])
+	})
+
+	e := environment
+	day := smoothstep(-0.25, 0.1, e.sun()[1])*0.85 + 0.15
+	state := TransparentPipeline
+	state.Apply()
+	c.shader.Use()
+	c.Bind(c.shader, 0)
+	c.shader.UniformTransformation("view", view)
+	c.shader.UniformTransformation("projection", projection)
+	c.shader.UniformFloats("eye", eye[0], eye[1], eye[2])
+	c.shader.UniformFloats("radius", c.Radius)
+	c.shader.UniformFloats("color", c.Color[0]*day, c.Color[1]*day, c.Color[2]*day, c.Color[3]/float32(slices))
+	size := c.Radius * 2
+	for _, y := range heights {
+		model := glm.Translate3D(eye[0], y, eye[2]).Mul4(glm.Scale3D(size, 1, size))
+		c.shader.UniformTransformation("model", model)
+		c.quad.Draw()
+	}
+	DefaultPipeline.Apply()
+}
+
+// Pass returns a pass named "clouds" that draws the clouds with the frame
+// camera, to be added after PassOpaque.
+func (c *Clouds) Pass() Pass {
+	return NewPass("clouds", func(f *Frame) { c.Draw(f.Eye, f.View, f.Projection) })
+}
+
+// Delete releases the shader and the quad. The texture is not deleted.
+func (c *Clouds) Delete() {
+	c.shader.Delete()
+	c.quad.Delete()
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
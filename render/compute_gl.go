@@ -0,0 +1,164 @@
+//go:build !js && !openvoxel_fake
+
+package render
+
+import (
+	"fmt"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// computeMeshGLSL emits the visible faces of each block as packed vertices, in
+// the same format produced by mesh.Mesher.Pack, without merging faces.
+const computeMeshGLSL = `#version 430
+layout (local_size_x = 4, local_size_y = 4, local_size_z = 4) in;
+
+// Block IDs of the chunk with a border of one block on each side.
+layout (std430, binding = 0) readonly buffer Blocks { uint blocks[]; };
+// Opaque flag of each block ID.
+layout (std430, binding = 1) readonly buffer Opaque { uint opaque[]; };
+layout (std430, binding = 2) writeonly buffer Vertices { uint vertices[]; };
+layout (std430, binding = 3) buffer Counter { uint vertexCount; };
+
+uniform ivec3 dims;
+
+const ivec3 axes[3] = ivec3[3](ivec3(1, 0, 0), ivec3(0, 1, 0), ivec3(0, 0, 1));
+const ivec2 corners[4] = ivec2[4](ivec2(0, 0), ivec2(1, 0), ivec2(1, 1), ivec2(0, 1));
+const int front[6] = int[6](0, 1, 2, 0, 2, 3);
+const int back[6] = int[6](0, 2, 1, 0, 3, 2);
+
+uint blockAt(ivec3 p) {
+    ivec3 s = dims + 2;
+    p += 1;
+    return blocks[(p.y * s.z + p.z) * s.x + p.x];
+}
+
+void main() {
+    ivec3 p = ivec3(gl_GlobalInvocationID);
+    if (any(greaterThanEqual(p, dims))) {
+        return;
+    }
+    uint id = blockAt(p);
+    if (id == 0u) {
+        return;
+    }
+    for (int face = 0; face < 6; face++) {
+        int d = face / 2;
+        int side = (face % 2 == 0) ? 1 : -1;
+        if (opaque[blockAt(p + axes[d] * side)] != 0u) {
+            continue;
+        }
+        int u = (d + 1) % 3;
+        int v = (d + 2) % 3;
+        ivec3 base = p;
+        if (side > 0) {
+            base[d] += 1;
+        }
+        uint first = atomicAdd(vertexCount, 6u);
+        for (int i = 0; i < 6; i++) {
+            ivec2 c = corners[side > 0 ? front[i] : back[i]];
+            ivec3 pos = base;
+            pos[u] += c.x;
+            pos[v] += c.y;
+            pos = (pos + 1) * 16;
+            uint w0 = uint(pos.x) | uint(pos.y) << 9 | uint(pos.z) << 18 |
+                uint(face) << 27 | 3u << 30;
+            uint w1 = uint(c.x * 16) | uint(c.y * 16) << 9 | 4095u << 18;
+            uint n = (first + uint(i)) * 3u;
+            vertices[n] = w0;
+            vertices[n + 1u] = w1;
+            vertices[n + 2u] = 0xffffffu;
+        }
+    }
+}
+`
+
+// ComputeMesher builds chunk meshes on the GPU with a compute shader. It is an
+// experimental path for very high render distances: faces are culled but not
+// merged, and there is no lighting, tint or texture layer, so its output is
+// larger than the CPU greedy mesher's. It needs OpenGL 4.3.
+type ComputeMesher struct {
+	shader *Shader
+	// SSBOs: blocks, opaque flags, output vertices and vertex counter.
+	ssbo    [4]uint32
+	outSize int
+}
+
+// ComputeMeshingSupported returns true if the context supports compute
+// shaders.
+func ComputeMeshingSupported() bool {
+	return Capabilities().Compute
+}
+
+// NewComputeMesher compiles the meshing compute shader. It returns
+// ErrNotImplemented if compute shaders are not supported.
+func NewComputeMesher() (*ComputeMesher, error) {
+	if !ComputeMeshingSupported() {
+		return nil, ErrNotImplemented
+	}
+	s := &Shader{}
+	s.shaderFiles = append(s.shaderFiles, shaderSource{computeMeshGLSL, gl.COMPUTE_SHADER})
+	if err := s.Link(); err != nil {
+		return nil, err
+	}
+	m := &ComputeMesher{shader: s}
+	gl.GenBuffers(4, &m.ssbo[0])
+	trackAlloc(resBuffer, 4)
+	return m, nil
+}
+
+// Mesh builds the mesh of a chunk with the provided dimensions, appending the
+// vertices in the PackedLayout to dst at the offset, in bytes. Blocks holds
+// the block IDs of the chunk with a border of one block from the neighbor
+// chunks, in (y, z, x) order, and opaque the flag of each block ID. It
+// returns the number of vertices written; dst must have enough space or an
+// error is returned. The packed positions, offset by one block, limit the
+// dimensions to 30 blocks.
+func (m *ComputeMesher) Mesh(dims [3]int, blocks, opaque []uint32, dst *MeshBuffer, offset int) (int, error) {
+	for _, d := range dims {
+		if d > 30 {
+			return 0, fmt.Errorf("compute mesher: chunk dimensions %v over 30 blocks", dims)
+		}
+	}
+	upload := func(i int, data []uint32) {
+		gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, m.ssbo[i])
+		gl.BufferData(gl.SHADER_STORAGE_BUFFER, len(data)*4, gl.Ptr(data), gl.STREAM_DRAW)
+		gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, uint32(i), m.ssbo[i])
+	}
+	upload(0, blocks)
+	upload(1, opaque)
+	upload(3, []uint32{0})
+
+	// Worst case: every other block is exposed on all sides
+	need := dims[0] * dims[1] * dims[2] / 2 * 6 * 6 * PackedLayout.Stride
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, m.ssbo[2])
+	if need > m.outSize {
+		gl.BufferData(gl.SHADER_STORAGE_BUFFER, need, nil, gl.DYNAMIC_COPY)
+		m.outSize = need
+	}
+	gl.BindBufferBase(gl.SHADER_STORAGE_BUFFER, 2, m.ssbo[2])
+
+	m.shader.Use()
+	m.shader.UniformInts("dims", int32(dims[0]), int32(dims[1]), int32(dims[2]))
+	gl.DispatchCompute(uint32(dims[0]+3)/4, uint32(dims[1]+3)/4, uint32(dims[2]+3)/4)
+	gl.MemoryBarrier(gl.SHADER_STORAGE_BARRIER_BIT | gl.BUFFER_UPDATE_BARRIER_BIT)
+
+	var count uint32
+	gl.BindBuffer(gl.SHADER_STORAGE_BUFFER, m.ssbo[3])
+	gl.GetBufferSubData(gl.SHADER_STORAGE_BUFFER, 0, 4, gl.Ptr(&count))
+	size := int(count) * PackedLayout.Stride
+	if offset+size > dst.Size() {
+		return 0, fmt.Errorf("compute mesher: %d bytes do not fit in the buffer", size)
+	}
+	gl.BindBuffer(gl.COPY_READ_BUFFER, m.ssbo[2])
+	gl.BindBuffer(gl.COPY_WRITE_BUFFER, dst.vbo)
+	gl.CopyBufferSubData(gl.COPY_READ_BUFFER, gl.COPY_WRITE_BUFFER, 0, offset, size)
+	return int(count), nil
+}
+
+// Delete releases the shader and buffers.
+func (m *ComputeMesher) Delete() {
+	m.shader.Delete()
+	gl.DeleteBuffers(4, &m.ssbo[0])
+	trackFree(resBuffer, 4)
+}
@@ -0,0 +1,163 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Face identifies a face of a block, in the same order as the cube map faces.
+type Face int
+
+const (
+	FaceEast  Face = iota // +X
+	FaceWest              // -X
+	FaceUp                // +Y
+	FaceDown              // -Y
+	FaceSouth             // +Z
+	FaceNorth             // -Z
+)
+
+// Normal returns the unit vector pointing out of the face.
+func (f Face) Normal() glm.Vec3 {
+	return cubeFaces[f][0]
+}
+
+// CrackStages is the number of block damage stages, laid out horizontally in
+// the crack texture.
+const CrackStages = 10
+
+// Decal is a texture projected onto a block face, drawn on top of the chunk
+// mesh without rebuilding it.
+type Decal struct {
+	X, Y, Z int
+	Face    Face
+	Texture *Texture
+	// Frame and Frames select a region of a texture holding several images
+	// side by side. Frames zero means the whole texture is used.
+	Frame, Frames int
+}
+
+// quad appends the two triangles covering the decal face.
+func (d *Decal) quad(vertices []float32) []float32 {
+	n := d.Face.Normal()
+	up := cubeFaces[d.Face][1].Mul(-1)
+	right := up.Cross(n)
+	center := glm.Vec3{float32(d.X) + 0.5, float32(d.Y) + 0.5, float32(d.Z) + 0.5}.Add(n.Mul(0.5))
+
+	u0, u1 := float32(0), float32(1)
+	if d.Frames > 0 {
+		u0 = float32(d.Frame) / float32(d.Frames)
+		u1 = float32(d.Frame+1) / float32(d.Frames)
+	}
+	corner := func(sx, sy, u, v float32) []float32 {
+		p := center.Add(right.Mul(sx * 0.5)).Add(up.Mul(sy * 0.5))
+		return []float32{p.X(), p.Y(), p.Z(), u, v}
+	}
+	a, b := corner(-1, -1, u0, 1), corner(1, -1, u1, 1)
+	c, e := corner(1, 1, u1, 0), corner(-1, 1, u0, 0)
+	for _, v := range [][]float32{a, b, c, a, c, e} {
+		vertices = append(vertices, v...)
+	}
+	return vertices
+}
+
+// DecalRenderer draws the block damage overlay and generic decals, such as
+// scorch marks. Decals are grouped by texture, and their meshes are only
+// rebuilt when they change.
+type DecalRenderer struct {
+	// Crack is the texture with the CrackStages block damage images.
+	Crack *Texture
+
+	decals map[*Decal]bool
+	meshes map[*Texture]*DynamicMesh
+	crack  *Decal
+	dirty  bool
+}
+
+// NewDecalRenderer creates an empty decal renderer.
+func NewDecalRenderer(crack *Texture) *DecalRenderer {
+	return &DecalRenderer{
+		Crack:  crack,
+		decals: map[*Decal]bool{},
+		meshes: map[*Texture]*DynamicMesh{},
+	}
+}
+
+// Add places a decal.
+func (r *DecalRenderer) Add(d *Decal) {
+	r.decals[d] = true
+	r.dirty = true
+}
+
+// Remove deletes a decal.
+func (r *DecalRenderer) Remove(d *Decal) {
+	delete(r.decals, d)
+	r.dirty = true
+}
+
+// SetDamage shows the crack overlay on the face of the block being mined, with
+// progress from 0 to 1. A negative progress removes the overlay.
+func (r *DecalRenderer) SetDamage(x, y, z int, face Face, progress float32) {
+	if r.crack != nil {
+		r.Remove(r.crack)
+		r.crack = nil
+	}
+	if progress < 0 || r.Crack == nil {
+		return
+	}
+	stage := int(progress * CrackStages)
+	if stage >= CrackStages {
+		stage = CrackStages - 1
+	}
+	r.crack = &Decal{X: x, Y: y, Z: z, Face: face, Texture: r.Crack, Frame: stage, Frames: CrackStages}
+	r.Add(r.crack)
+}
+
+func (r *DecalRenderer) rebuild() {
+	vertices := map[*Texture][]float32{}
+	for d := range r.decals {
+		vertices[d.Texture] = d.quad(vertices[d.Texture])
+	}
+	for tex, m := range r.meshes {
+		if _, ok := vertices[tex]; !ok {
+			m.Delete()
+			delete(r.meshes, tex)
+		}
+	}
+	for tex, v := range vertices {
+		m, ok := r.meshes[tex]
+		if !ok {
+			m = NewDynamicMesh()
+			r.meshes[tex] = m
+		}
+		m.Update(v)
+	}
+	r.dirty = false
+}
+
+// Draw renders the decals with the shader, which must have its view and
+// projection uniforms already set, and sample the texture at unit 0 using the
+// vertex attributes at locations 0 and 1.
+func (r *DecalRenderer) Draw(shader *Shader) {
+	if r.dirty {
+		r.rebuild()
+	}
+	if len(r.meshes) == 0 {
+		return
+	}
+	shader.Use()
+	shader.UniformTransformation("model", glm.Ident4())
+	OverlayPipeline.Apply()
+	for tex, m := range r.meshes {
+		tex.Bind(0)
+		m.Draw()
+	}
+	DefaultPipeline.Apply()
+}
+
+// Delete releases the decal meshes. Textures are not deleted.
+func (r *DecalRenderer) Delete() {
+	for tex, m := range r.meshes {
+		m.Delete()
+		delete(r.meshes, tex)
+	}
+}
@@ -0,0 +1,136 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// G-buffer color attachments written by the geometry pass shaders.
+const (
+	GBufferPosition = iota // layout (location = 0) out vec4, world position
+	GBufferNormal          // layout (location = 1) out vec4, world normal
+	GBufferAlbedo          // layout (location = 2) out vec4, base color
+)
+
+// deferredLightingGLSL computes the lighting of each pixel from the G-buffer,
+// with the surface and fog shader hooks and the shadows of the clouds.
+const deferredLightingGLSL = glslVersion + `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D gPosition;
+uniform sampler2D gNormal;
+uniform sampler2D gAlbedo;
+uniform vec3 ambient;
+` + LightsGLSL + CloudGLSL + HooksInclude + `
+void main() {
+    vec4 albedo = texture(gAlbedo, TexCoord);
+    if (albedo.a == 0.0) {
+        discard;
+    }
+    vec3 pos = texture(gPosition, TexCoord).xyz;
+    vec3 normal = normalize(texture(gNormal, TexCoord).xyz);
+    albedo = surfaceColor(albedo, pos, normal);
+    vec3 color = albedo.rgb * (ambient * cloudShadow(pos) + dynamicLight(pos, normal));
+    FragColor = vec4(applyFog(color, pos), 1.0);
+}
+`
+
+// DeferredRenderer implements a deferred shading pipeline, suitable for scenes
+// with dozens of dynamic lights.
+//
+// Geometry is first drawn into the G-buffer, between BeginGeometry and
+// EndGeometry calls, using shaders that write the world position, normal and
+// albedo to the outputs at the GBufferPosition, GBufferNormal and GBufferAlbedo
+// locations. Then, the lighting pass computes the final color of each pixel
+// only once, regardless of the scene depth complexity.
+//
+// It is only available on the desktop backend; WebGL programs should keep
+// using the forward path with the LightManager.
+type DeferredRenderer struct {
+	// Lights holds the dynamic lights applied in the lighting pass.
+	Lights *LightManager
+	// Ambient is the light color applied to all pixels.
+	Ambient glm.Vec3
+	// Clouds, if set, darken the ambient light under them.
+	Clouds *Clouds
+
+	gbuf     *Framebuffer
+	lighting *Shader
+}
+
+// NewDeferredRenderer creates the G-buffer with the provided size and compiles
+// the lighting pass shader. It returns ErrNotImplemented on WebGL.
+func NewDeferredRenderer(width, height int) (*DeferredRenderer, error) {
+	if !deferredSupported {
+		return nil, ErrNotImplemented
+	}
+	// Positions are stored as full floats: half floats step by a whole block
+	// beyond 1024 blocks from the origin.
+	gbuf, err := NewFramebuffer(width, height, FormatRGBA32F, FormatRGBA16F, FormatRGBA8)
+	if err != nil {
+		return nil, err
+	}
+	lighting := &Shader{}
+	lighting.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(deferredLightingGLSL)
+	if err := lighting.Link(); err != nil {
+		gbuf.Delete()
+		return nil, err
+	}
+	d := &DeferredRenderer{
+		Lights:   NewLightManager(),
+		Ambient:  glm.Vec3{0.2, 0.2, 0.2},
+		gbuf:     gbuf,
+		lighting: lighting,
+	}
+	lighting.Use()
+	lighting.UniformBlock("Lights", LightsBlockBinding)
+	lighting.UniformInts("gPosition", GBufferPosition)
+	lighting.UniformInts("gNormal", GBufferNormal)
+	lighting.UniformInts("gAlbedo", GBufferAlbedo)
+	return d, nil
+}
+
+// GBuffer returns the framebuffer with the geometry pass outputs.
+func (d *DeferredRenderer) GBuffer() *Framebuffer {
+	return d.gbuf
+}
+
+// Resize changes the G-buffer size, usually to match the window size.
+func (d *DeferredRenderer) Resize(width, height int) error {
+	return d.gbuf.Resize(width, height)
+}
+
+// BeginGeometry binds and clears the G-buffer for the geometry pass.
+func (d *DeferredRenderer) BeginGeometry() {
+	d.gbuf.Bind()
+	d.gbuf.Clear()
+}
+
+// EndGeometry runs the lighting pass of the frame, drawing the final image
+// into its window. The shader hooks are included when NewDeferredRenderer is
+// called.
+func (d *DeferredRenderer) EndGeometry(f *Frame) {
+	f.Window.BindDefaultFramebuffer()
+	d.lighting.Use()
+	d.lighting.UniformFloats("ambient", d.Ambient[0], d.Ambient[1], d.Ambient[2])
+	if d.Clouds != nil {
+		d.Clouds.Bind(d.lighting, GBufferAlbedo+1)
+	} else {
+		d.lighting.UniformFloats("cloudShadowStrength", 0)
+	}
+	f.ApplyEnvironment(d.lighting)
+	for i := GBufferPosition; i <= GBufferAlbedo; i++ {
+		d.gbuf.Color(i).Bind(i)
+	}
+	// Lights are not culled per tile; all lights up to MaxLights are used.
+	inf := float32(1e30)
+	d.Lights.Upload(glm.Vec3{-inf, -inf, -inf}, glm.Vec3{inf, inf, inf})
+	DrawFullscreen()
+}
+
+// Delete releases the G-buffer, the shader and the lights buffer.
+func (d *DeferredRenderer) Delete() {
+	d.gbuf.Delete()
+	d.lighting.Delete()
+	d.Lights.Delete()
+}
@@ -0,0 +1,106 @@
+package render
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// DepthMode selects how depth values are distributed, which matters for the
+// z-fighting of distant terrain at large render distances.
+type DepthMode int
+
+const (
+	// DepthStandard uses the usual [-1, 1] perspective depth, with most of
+	// the precision near the camera.
+	DepthStandard DepthMode = iota
+	// DepthReversed maps the near plane to 1 and the infinity to 0 in a
+	// [0, 1] clip range. It needs clip control support.
+	DepthReversed
+	// DepthLogarithmic writes a logarithmic depth from the vertex shaders,
+	// which must include LogDepthGLSL. It works everywhere, but disables
+	// some early depth test optimizations.
+	DepthLogarithmic
+)
+
+var depthMode = DepthStandard
+
+// SetDepthMode changes the depth mode and returns the mode in use, which is
+// DepthLogarithmic if DepthReversed was requested but clip control is not
+// available.
+func SetDepthMode(mode DepthMode) DepthMode {
+	reversed := mode == DepthReversed && setClipControl(true)
+	if mode == DepthReversed && !reversed {
+		mode = DepthLogarithmic
+	}
+	if !reversed {
+		setClipControl(false)
+	}
+	if reversed {
+		setClearDepth(0)
+	} else {
+		setClearDepth(1)
+	}
+	depthMode = mode
+	InvalidatePipeline()
+	return mode
+}
+
+// CurrentDepthMode returns the depth mode in use.
+func CurrentDepthMode() DepthMode {
+	return depthMode
+}
+
+// effective returns the comparison to use in the current depth mode, where
+// the reversed mode flips less and greater.
+func (f DepthFunc) effective() DepthFunc {
+	if depthMode != DepthReversed {
+		return f
+	}
+	switch f {
+	case DepthLess:
+		return DepthGreater
+	case DepthLessEqual:
+		return DepthGreaterEqual
+	case DepthGreater:
+		return DepthLess
+	case DepthGreaterEqual:
+		return DepthLessEqual
+	}
+	return f
+}
+
+// Projection returns the perspective projection for the current depth mode.
+// The far plane is ignored in the reversed mode, which uses an infinite far
+// plane.
+func Projection(fov, aspect, near, far float32) glm.Mat4 {
+	if depthMode == DepthReversed {
+		return transform.PerspectiveReverseZ(fov, aspect, near)
+	}
+	return transform.Perspective(fov, aspect, near, far)
+}
+
+// LogDepthGLSL declares a function that replaces the depth of the vertex
+// position with a logarithmic one, used in the DepthLogarithmic mode. Vertex
+// shaders must call it after setting gl_Position, and the logDepth uniform
+// must be set with SetLogDepth.
+const LogDepthGLSL = `
+uniform float logDepth; // 2 / log2(far + 1), or 0 when disabled
+
+void applyLogDepth() {
+    if (logDepth > 0.0) {
+        gl_Position.z = (log2(max(1e-6, 1.0 + gl_Position.w)) * logDepth - 1.0) * gl_Position.w;
+    }
+}
+`
+
+// SetLogDepth sets the LogDepthGLSL uniform of the shader for the far plane
+// distance, or disables it if the depth mode is not logarithmic.
+func SetLogDepth(shader *Shader, far float32) {
+	var v float32
+	if depthMode == DepthLogarithmic {
+		v = 2 / float32(math.Log2(float64(far)+1))
+	}
+	shader.UniformFloats("logDepth", v)
+}
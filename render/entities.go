@@ -0,0 +1,155 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+// EntityCuller finds the entities to draw in a frame. Entities are indexed by
+// the chunk containing their center in a spatial hash, so moving them and
+// finding those in a chunk take constant time, and whole chunks are skipped
+// when they are hidden or outside the frustum. This keeps thousands of
+// entities viable, as only the ones in the visible chunks are tested one by
+// one.
+//
+// Entities are identified by their entity.Store ID. Follow adds and removes
+// them as the store publishes their events, and the game updates their bounds
+// with Set when they move.
+type EntityCuller struct {
+	// ChunkSize is the size of the hash cells, in blocks, matching the
+	// chunks used by the visible function of Visible.
+	ChunkSize float32
+
+	cells    map[[3]int]*entityCell
+	entities map[uint64]entityBounds
+}
+
+type entityCell struct {
+	ids map[uint64]struct{}
+	// radius is the largest radius of the entities in the cell since it was
+	// created, so the cell box contains all of them.
+	radius float32
+}
+
+type entityBounds struct {
+	center glm.Vec3
+	radius float32
+	cell   [3]int
+}
+
+// NewEntityCuller creates a culler without entities, with cells the size of
+// the world chunks.
+func NewEntityCuller() *EntityCuller {
+	return &EntityCuller{
+		ChunkSize: chunkWidth,
+		cells:     map[[3]int]*entityCell{},
+		entities:  map[uint64]entityBounds{},
+	}
+}
+
+func (c *EntityCuller) cellOf(p glm.Vec3) [3]int {
+	var k [3]int
+	for i := range k {
+		v := p[i] / c.ChunkSize
+		k[i] = int(v)
+		if v < 0 && float32(k[i]) != v {
+			k[i]--
+		}
+	}
+	return k
+}
+
+// Set adds the entity, or moves it, with a bounding sphere at center.
+func (c *EntityCuller) Set(id uint64, center glm.Vec3, radius float32) {
+	k := c.cellOf(center)
+	if old, ok := c.entities[id]; ok && old.cell != k {
+		c.removeFromCell(id, old.cell)
+	}
+	cell := c.cells[k]
+	if cell == nil {
+		cell = &entityCell{ids: map[uint64]struct{}{}}
+		c.cells[k] = cell
+	}
+	cell.ids[id] = struct{}{}
+	if radius > cell.radius {
+		cell.radius = radius
+	}
+	c.entities[id] = entityBounds{center: center, radius: radius, cell: k}
+}
+
+// Follow adds the entities published in event.EntitySpawned on the bus, such
+// as by the entity.Store, with the bounding sphere returned by bounds, and
+// removes them on event.EntityRemoved. Entities for which bounds returns false
+// are not drawn. It returns a function that stops following the bus.
+func (c *EntityCuller) Follow(b *event.Bus, bounds func(id uint64) (center glm.Vec3, radius float32, ok bool)) (unsubscribe func()) {
+	spawned := event.Subscribe(b, func(e event.EntitySpawned) {
+		if center, radius, ok := bounds(e.ID); ok {
+			c.Set(e.ID, center, radius)
+		}
+	})
+	removed := event.Subscribe(b, func(e event.EntityRemoved) {
+		c.Remove(e.ID)
+	})
+	return func() {
+		spawned()
+		removed()
+	}
+}
+
+// Remove deletes the entity.
+func (c *EntityCuller) Remove(id uint64) {
+	if old, ok := c.entities[id]; ok {
+		c.removeFromCell(id, old.cell)
+		delete(c.entities, id)
+	}
+}
+
+func (c *EntityCuller) removeFromCell(id uint64, k [3]int) {
+	cell := c.cells[k]
+	delete(cell.ids, id)
+	if len(cell.ids) == 0 {
+		delete(c.cells, k)
+	}
+}
+
+// Len returns the number of entities.
+func (c *EntityCuller) Len() int {
+	return len(c.entities)
+}
+
+// InChunk calls fn with the entities whose center is in the chunk.
+func (c *EntityCuller) InChunk(cx, cy, cz int, fn func(id uint64)) {
+	if cell := c.cells[[3]int{cx, cy, cz}]; cell != nil {
+		for id := range cell.ids {
+			fn(id)
+		}
+	}
+}
+
+// Visible calls fn with the entities that may be seen in the frame. Chunks for
+// which visible returns false, because they are not loaded or are occluded,
+// are skipped with all their entities; a nil function only uses the frustum.
+// It returns the number of entities passed to fn.
+func (c *EntityCuller) Visible(f *Frame, visible func(cx, cy, cz int) bool, fn func(id uint64)) int {
+	frustum := FrameFrustum(f)
+	n := 0
+	for k, cell := range c.cells {
+		if visible != nil && !visible(k[0], k[1], k[2]) {
+			continue
+		}
+		min := glm.Vec3{float32(k[0]), float32(k[1]), float32(k[2])}.Mul(c.ChunkSize)
+		max := min.Add(glm.Vec3{c.ChunkSize, c.ChunkSize, c.ChunkSize})
+		r := glm.Vec3{cell.radius, cell.radius, cell.radius}
+		if !frustum.IntersectsBox(min.Sub(r), max.Add(r)) {
+			continue
+		}
+		for id := range cell.ids {
+			e := c.entities[id]
+			if frustum.IntersectsSphere(e.center, e.radius) {
+				fn(id)
+				n++
+			}
+		}
+	}
+	return n
+}
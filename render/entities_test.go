@@ -0,0 +1,38 @@
+//go:build openvoxel_fake
+
+package render
+
+import (
+	"testing"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+func TestEntityCullerFollow(t *testing.T) {
+	bus := event.NewBus()
+	c := NewEntityCuller()
+	stop := c.Follow(bus, func(id uint64) (glm.Vec3, float32, bool) {
+		return glm.Vec3{float32(id) * 20, 0, 0}, 1, id != 3
+	})
+	for id := uint64(1); id <= 3; id++ {
+		event.Publish(bus, event.EntitySpawned{ID: id})
+	}
+	if c.Len() != 2 {
+		t.Errorf("following %d entities, want the 2 with bounds", c.Len())
+	}
+	found := false
+	c.InChunk(1, 0, 0, func(id uint64) { found = id == 1 })
+	if !found {
+		t.Error("entity 1 not in the chunk of its center")
+	}
+	event.Publish(bus, event.EntityRemoved{ID: 1, Unloaded: true})
+	if c.Len() != 1 {
+		t.Errorf("following %d entities after a removal, want 1", c.Len())
+	}
+	stop()
+	event.Publish(bus, event.EntityRemoved{ID: 2})
+	if c.Len() != 1 {
+		t.Errorf("removed an entity after stopping")
+	}
+}
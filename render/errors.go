@@ -0,0 +1,176 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrShaderCompile matches the ShaderError of a shader that failed to
+	// compile.
+	ErrShaderCompile = errors.New("render: shader compilation failed")
+
+	// ErrShaderLink matches the ShaderError of a program that failed to
+	// link.
+	ErrShaderLink = errors.New("render: shader program link failed")
+
+	// ErrTextureLoad matches the TextureError of a texture that could not
+	// be loaded.
+	ErrTextureLoad = errors.New("render: texture load failed")
+
+	// ErrInvalidColor is returned by ParseColor for malformed colors.
+	ErrInvalidColor = errors.New("render: invalid color")
+)
+
+// ShaderStage is the pipeline stage of a shader.
+type ShaderStage int
+
+const (
+	// StageProgram identifies errors linking the whole program.
+	StageProgram ShaderStage = iota
+	StageVertex
+	StageFragment
+	StageCompute
+)
+
+func (s ShaderStage) String() string {
+	switch s {
+	case StageProgram:
+		return "program"
+	case StageVertex:
+		return "vertex"
+	case StageFragment:
+		return "fragment"
+	case StageCompute:
+		return "compute"
+	}
+	return fmt.Sprintf("stage(%d)", int(s))
+}
+
+// ShaderError is returned by Shader.Link when a shader fails to compile, or
+// the program fails to link. It matches ErrShaderCompile or ErrShaderLink
+// with errors.Is.
+type ShaderError struct {
+	// Stage is the shader that failed to compile, or StageProgram for link
+	// errors.
+	Stage ShaderStage
+	// Log is the info log reported by the driver.
+	Log string
+	// Line is the first source line reported in the log, or zero if it
+	// could not be found. Source is an excerpt of the lines around it.
+	Line   int
+	Source string
+}
+
+func (e *ShaderError) Error() string {
+	var b strings.Builder
+	if e.Stage == StageProgram {
+		b.WriteString("render: shader program link failed")
+	} else {
+		fmt.Fprintf(&b, "render: %v shader compilation failed", e.Stage)
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, " at line %d", e.Line)
+	}
+	if e.Log != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Log)
+	}
+	if e.Source != "" {
+		b.WriteString("\n")
+		b.WriteString(e.Source)
+	}
+	return b.String()
+}
+
+// Is reports whether the error matches ErrShaderCompile or ErrShaderLink.
+func (e *ShaderError) Is(target error) bool {
+	if e.Stage == StageProgram {
+		return target == ErrShaderLink
+	}
+	return target == ErrShaderCompile
+}
+
+// logLine matches the line numbers in the driver logs, such as "0:12(5):"
+// (Mesa), "ERROR: 0:12:" (ANGLE and WebGL) or "0(12) :" (NVIDIA).
+var logLine = regexp.MustCompile(`\b\d+[:(](\d+)\b`)
+
+// excerptLines is the number of lines shown before and after the error line.
+const excerptLines = 2
+
+// newShaderError creates the error for the log, with an excerpt of the source
+// around the first line it reports.
+func newShaderError(stage ShaderStage, log, src string) *ShaderError {
+	e := &ShaderError{Stage: stage, Log: strings.TrimSpace(strings.TrimRight(log, "\x00"))}
+	m := logLine.FindStringSubmatch(e.Log)
+	if m == nil || src == "" {
+		return e
+	}
+	e.Line, _ = strconv.Atoi(m[1])
+	lines := strings.Split(src, "\n")
+	if e.Line < 1 || e.Line > len(lines) {
+		return e
+	}
+	var b strings.Builder
+	for i := e.Line - excerptLines; i <= e.Line+excerptLines; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		mark := " "
+		if i == e.Line {
+			mark = ">"
+		}
+		fmt.Fprintf(&b, "%s%4d | %s\n", mark, i, lines[i-1])
+	}
+	e.Source = strings.TrimRight(b.String(), "\n")
+	return e
+}
+
+// TextureError is returned when a texture image can not be loaded. It matches
+// ErrTextureLoad with errors.Is, and wraps the cause.
+type TextureError struct {
+	// Path is the file the texture was loaded from, if any.
+	Path string
+	// Format is the image format detected from the data, such as "png",
+	// or "unknown".
+	Format string
+	Err    error
+}
+
+func (e *TextureError) Error() string {
+	s := "render: loading " + e.Format + " texture"
+	if e.Path != "" {
+		s += " " + e.Path
+	}
+	return s + ": " + e.Err.Error()
+}
+
+func (e *TextureError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error matches ErrTextureLoad.
+func (e *TextureError) Is(target error) bool {
+	return target == ErrTextureLoad
+}
+
+// imageFormat detects the format of the image data from its signature.
+func imageFormat(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(b, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(b, []byte("GIF8")):
+		return "gif"
+	case bytes.HasPrefix(b, []byte("BM")):
+		return "bmp"
+	case len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		return "webp"
+	}
+	return "unknown"
+}
@@ -0,0 +1,204 @@
+package render
+
+import (
+	"image"
+	"math"
+)
+
+// Log luminance range stored by the exposure measure, in EV, as in
+// exposureMeasureGLSL.
+const (
+	minLuminanceEV   = -12
+	luminanceRangeEV = 16
+)
+
+// exposureMeasureGLSL writes the average log2 luminance of the scene under
+// each pixel, from minLuminanceEV to minLuminanceEV+luminanceRangeEV,
+// normalized to [0, 1] with 16 bits of precision in the red and green
+// channels.
+const exposureMeasureGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D scene;
+uniform vec2 texel; // size of a pixel of the measure, in texture coordinates
+
+void main() {
+    float sum = 0.0;
+    for (int i = 0; i < 2; i++) {
+        for (int j = 0; j < 2; j++) {
+            vec2 offset = (vec2(float(i), float(j)) - 0.5) * texel * 0.5;
+            vec3 c = texture(scene, TexCoord + offset).rgb;
+            sum += log2(max(dot(c, vec3(0.2126, 0.7152, 0.0722)), 1e-4));
+        }
+    }
+    float v = clamp((sum / 4.0 + 12.0) / 16.0, 0.0, 1.0);
+    float hi = floor(v * 255.0) / 255.0;
+    FragColor = vec4(hi, (v - hi) * 255.0, 0.0, 1.0);
+}
+`
+
+// tonemapGLSL scales the scene by the exposure and maps it to the [0, 1]
+// range with the filmic curve fitted to ACES by Krzysztof Narkowicz.
+const tonemapGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D scene;
+uniform float exposure;
+
+void main() {
+    vec4 c = texture(scene, TexCoord);
+    vec3 x = c.rgb * exposure;
+    x = clamp((x * (2.51 * x + 0.03)) / (x * (2.43 * x + 0.59) + 0.14), 0.0, 1.0);
+    FragColor = vec4(x, c.a);
+}
+`
+
+// AutoExposure adapts the exposure of an HDR scene to its brightness, as the
+// eyes do when walking from a dark cave into daylight. The luminance of the
+// scene is measured on a small framebuffer read back asynchronously, so it
+// lags a frame or two behind without stalling the GPU, and the exposure moves
+// smoothly towards the one that brings the average luminance to Key, within
+// the limits of the Graphics settings.
+type AutoExposure struct {
+	// Key is the average luminance the scene is exposed to, 0.18 for middle
+	// gray.
+	Key float32
+	// SpeedUp and SpeedDown are the adaptation rates, per second, when the
+	// scene gets darker and brighter. Eyes adapt faster to light.
+	SpeedUp, SpeedDown float32
+
+	ev, target float32
+	fb         *Framebuffer
+	pending    *Readback
+	measure    *Shader
+	tonemap    *Shader
+}
+
+// NewAutoExposure compiles the shaders and creates the measure framebuffer.
+func NewAutoExposure() (*AutoExposure, error) {
+	measure := &Shader{}
+	measure.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + exposureMeasureGLSL)
+	if err := measure.Link(); err != nil {
+		return nil, err
+	}
+	tonemap := &Shader{}
+	tonemap.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + tonemapGLSL)
+	if err := tonemap.Link(); err != nil {
+		measure.Delete()
+		return nil, err
+	}
+	fb, err := NewFramebuffer(64, 32, FormatRGBA8)
+	if err != nil {
+		measure.Delete()
+		tonemap.Delete()
+		return nil, err
+	}
+	return &AutoExposure{
+		Key:       0.18,
+		SpeedUp:   1,
+		SpeedDown: 3,
+		fb:        fb,
+		measure:   measure,
+		tonemap:   tonemap,
+	}, nil
+}
+
+// Exposure returns the current exposure in EV.
+func (a *AutoExposure) Exposure() float32 {
+	return a.ev
+}
+
+// Update measures the luminance of the scene, an HDR texture such as one
+// with FormatRGBA16F, and adapts the exposure for the last dt seconds. It
+// binds the measure framebuffer, so the caller must bind its render target
+// again.
+func (a *AutoExposure) Update(scene *Texture, dt float64) {
+	g := graphics
+	if !g.AutoExposure {
+		a.ev, a.target = 0, 0
+		return
+	}
+	if a.pending != nil && a.pending.Ready() {
+		a.target = a.exposureOf(a.pending.Image())
+		a.pending.Delete()
+		a.pending = nil
+	}
+	if a.pending == nil {
+		state := DefaultPipeline
+		state.DepthTest, state.DepthWrite = false, false
+		state.Apply()
+		a.fb.Bind()
+		a.measure.Use()
+		a.measure.UniformInts("scene", 0)
+		a.measure.UniformFloats("texel", 1/float32(a.fb.Width), 1/float32(a.fb.Height))
+		scene.Bind(0)
+		DrawFullscreen()
+		DefaultPipeline.Apply()
+		a.pending = a.fb.ReadPixelsAsync(0, image.Rect(0, 0, a.fb.Width, a.fb.Height))
+	}
+
+	target := float32(math.Max(float64(g.MinExposure), math.Min(float64(g.MaxExposure), float64(a.target))))
+	speed := a.SpeedDown
+	if target > a.ev {
+		speed = a.SpeedUp
+	}
+	// Exponential smoothing, independent of the frame rate.
+	a.ev += (target - a.ev) * float32(1-math.Exp(-float64(speed)*dt))
+	if a.ev < g.MinExposure {
+		a.ev = g.MinExposure
+	}
+	if a.ev > g.MaxExposure {
+		a.ev = g.MaxExposure
+	}
+}
+
+// exposureOf returns the exposure, in EV, that brings the average luminance of
+// the measure to Key. The center of the screen weighs up to twice as much as
+// the borders, as the player usually looks at it.
+func (a *AutoExposure) exposureOf(img *image.RGBA) float32 {
+	b := img.Bounds()
+	var sum, weights float64
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			i := img.PixOffset(b.Min.X+x, b.Min.Y+y)
+			v := (float64(img.Pix[i]) + float64(img.Pix[i+1])/255) / 255
+			dx := (float64(x)+0.5)/float64(b.Dx())*2 - 1
+			dy := (float64(y)+0.5)/float64(b.Dy())*2 - 1
+			w := 2 - math.Min(1, math.Hypot(dx, dy))
+			sum += (minLuminanceEV + v*luminanceRangeEV) * w
+			weights += w
+		}
+	}
+	if weights == 0 {
+		return a.target
+	}
+	return float32(math.Log2(float64(a.Key)) - sum/weights)
+}
+
+// Apply draws the scene over the whole current render target, scaled by the
+// exposure and tone mapped to the [0, 1] range.
+func (a *AutoExposure) Apply(scene *Texture) {
+	state := DefaultPipeline
+	state.DepthTest, state.DepthWrite = false, false
+	state.Apply()
+	a.tonemap.Use()
+	a.tonemap.UniformInts("scene", 0)
+	a.tonemap.UniformFloats("exposure", float32(math.Exp2(float64(a.ev))))
+	scene.Bind(0)
+	DrawFullscreen()
+	DefaultPipeline.Apply()
+}
+
+// Delete releases the shaders, the measure framebuffer and the pending
+// readback.
+func (a *AutoExposure) Delete() {
+	if a.pending != nil {
+		a.pending.Delete()
+		a.pending = nil
+	}
+	a.fb.Delete()
+	a.measure.Delete()
+	a.tonemap.Delete()
+}
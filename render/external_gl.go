@@ -0,0 +1,98 @@
+//go:build !js && !openvoxel_fake
+
+package render
+
+import (
+	"time"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+// defaultFramebuffer is the render target of the window: zero for GLFW
+// windows, or the framebuffer given to AttachExternalContext.
+var defaultFramebuffer uint32
+
+// attachedAt is when AttachExternalContext was called, used by Time as GLFW is
+// not initialized in that case.
+var attachedAt time.Time
+
+// AttachExternalContext creates a Window that draws into a framebuffer of an
+// OpenGL 3.3 core context owned by another toolkit, so the engine can be
+// embedded as a viewport widget in an editor. The context must be current on
+// the calling thread, and fbo is the framebuffer of the widget, or zero for
+// the default one of the context.
+//
+// The window has no input or event loop of its own: the host calls Resize,
+// InjectKey, InjectMouseButton, InjectChar and InjectCursor from its widget
+// events, and
+// SwapBuffers and PollEvents do nothing. ShouldClose returns true after
+// RequestClose. The clipboard paste shortcut of the text input mode is not
+// available, as it relies on GLFW.
+func AttachExternalContext(width, height int, fbo uint32) (*Window, error) {
+	if err := gl.Init(); err != nil {
+		return nil, err
+	}
+	defaultFramebuffer = fbo
+	attachedAt = time.Now()
+	w := &Window{
+		Width:       width,
+		Height:      height,
+		external:    true,
+		scene:       NewScene(),
+		pressedKeys: make(map[glfw.Key]struct{}),
+		sensitivity: 0.05,
+		visible:     true,
+	}
+	w.BindDefaultFramebuffer()
+	return w, nil
+}
+
+// Resize updates the size of an external window when the host widget is
+// resized, and publishes event.WindowResized. The framebuffer may be replaced
+// too, as some toolkits recreate it on resize.
+func (w *Window) Resize(width, height int, fbo uint32) {
+	defaultFramebuffer = fbo
+	w.onWindowGeometryChanged(nil, width, height)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+}
+
+// InjectKey feeds a key event of the host toolkit, using the GLFW key codes
+// and modifiers, as if it was received by a GLFW window.
+func (w *Window) InjectKey(key, scancode int, action event.KeyAction, mods int) {
+	w.onKeyPressed(nil, glfw.Key(key), scancode, glfw.Action(action), glfw.ModifierKey(mods))
+}
+
+// InjectMouseButton feeds a mouse button event of the host toolkit, using the
+// GLFW button codes.
+func (w *Window) InjectMouseButton(button int, action event.KeyAction, mods int) {
+	w.onMouseButton(nil, glfw.MouseButton(button), glfw.Action(action), glfw.ModifierKey(mods))
+}
+
+// InjectChar feeds a character typed in the host toolkit, used in the text
+// input mode.
+func (w *Window) InjectChar(char rune) {
+	w.onChar(nil, char)
+}
+
+// InjectCursor feeds the mouse position, in pixels from the top left of the
+// widget, to the camera controls.
+func (w *Window) InjectCursor(x, y float64) {
+	w.onCursorPosChange(nil, x, y)
+}
+
+// SetVisible feeds the visibility of the host widget, such as when its tab is
+// hidden, to the visibility callback.
+func (w *Window) SetVisible(visible bool) {
+	w.onIconify(nil, !visible)
+}
+
+// RequestClose makes ShouldClose return true, ending the engine main loop.
+func (w *Window) RequestClose() {
+	if w.external {
+		w.closeRequested = true
+	} else {
+		w.window.SetShouldClose(true)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !openvoxel_fake
+
+package render
+
+// AttachExternalContext is not supported on the web, where the engine always
+// creates its own canvas. It returns ErrNotImplemented.
+func AttachExternalContext(width, height int, fbo uint32) (*Window, error) {
+	return nil, ErrNotImplemented
+}
@@ -0,0 +1,89 @@
+// package fake records the calls made by the fake render backend, so game
+// logic, scene management and mesh generation can be tested without a GPU or
+// a display.
+//
+// The fake backend replaces the OpenGL and WebGL ones when building with the
+// openvoxel_fake tag:
+//
+//	go test -tags openvoxel_fake ./...
+//
+// It implements the whole render API: resources get unique handles and are
+// tracked for leaks as usual, shaders always compile and link, and read backs
+// return blank images. Each call that would reach the GPU is recorded in
+// Default, named after the OpenGL function it replaces, such as "DrawArrays"
+// or "BindTexture".
+package fake
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Call is a recorded backend call.
+type Call struct {
+	Name string
+	Args []any
+}
+
+func (c Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, a := range c.Args {
+		args[i] = fmt.Sprint(a)
+	}
+	return c.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// Recorder keeps the calls made by the fake backend. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+	// Disabled stops recording, for benchmarks and long running tests.
+	Disabled bool
+}
+
+// Default is the recorder used by the fake backend.
+var Default = &Recorder{}
+
+// Record appends the call.
+func (r *Recorder) Record(name string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Disabled {
+		return
+	}
+	r.calls = append(r.calls, Call{Name: name, Args: args})
+}
+
+// Calls returns a copy of the recorded calls, in order.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// Find returns the recorded calls with the name.
+func (r *Recorder) Find(name string) []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Call
+	for _, c := range r.calls {
+		if c.Name == name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Count returns the number of recorded calls with the name.
+func (r *Recorder) Count(name string) int {
+	return len(r.Find(name))
+}
+
+// Reset forgets the recorded calls.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+}
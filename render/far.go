@@ -0,0 +1,135 @@
+package render
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// FarLayout is the layout of the far terrain vertices built by mesh.Far: the
+// position and the color.
+var FarLayout = VertexLayout{
+	Stride: 6 * 4,
+	Attribs: []VertexAttrib{
+		{Location: 0, Size: 3, Offset: 0},
+		{Location: 1, Size: 3, Offset: 3 * 4},
+	},
+}
+
+const farVertexGLSL = `
+layout (location = 0) in vec3 aPos;
+layout (location = 1) in vec3 aColor;
+out vec3 WorldPos;
+out vec3 Color;
+
+uniform mat4 view;
+uniform mat4 projection;
+uniform float sink;
+
+void main() {
+    WorldPos = aPos - vec3(0.0, sink, 0.0);
+    Color = aColor;
+    gl_Position = projection * view * vec4(WorldPos, 1.0);
+}
+`
+
+const farFragmentGLSL = `
+out vec4 FragColor;
+in vec3 WorldPos;
+in vec3 Color;
+
+uniform vec2 haze; // distances where the terrain starts and ends fading
+` + HooksInclude + `
+void main() {
+    // Flat shading from the triangle slope, as there are no normals.
+    vec3 normal = normalize(cross(dFdx(WorldPos), dFdy(WorldPos)));
+    if (normal.y < 0.0) {
+        normal = -normal;
+    }
+    float day = clamp(envSunDirection.y * 4.0 + 0.5, 0.1, 1.0);
+    float light = (0.45 + 0.55 * max(dot(normal, envSunDirection), 0.0)) * day;
+    vec3 dir = WorldPos - envEye;
+    float f = smoothstep(haze.x, haze.y, length(dir.xz));
+    FragColor = vec4(mix(Color * light, skyColor(dir), f), 1.0);
+}
+`
+
+// FarTerrain draws the impostor of the terrain beyond the loaded chunks, built
+// by mesh.Far. It is drawn before the chunks with its own depth range, which
+// is cleared afterwards, so the chunks always cover it and there is no depth
+// fighting where they overlap.
+type FarTerrain struct {
+	// Near and Far are the depth range of the terrain, usually half of
+	// mesh.Far.Inner and mesh.Far.Outer.
+	Near, Far float32
+	// Sink lowers the terrain, in blocks, so it hides behind the chunks at
+	// the edge of the render distance.
+	Sink float32
+	// Haze is the fraction of Far where the terrain starts fading into the
+	// sky.
+	Haze float32
+
+	shader *Shader
+	buf    *MeshBuffer
+	count  int
+}
+
+// NewFarTerrain creates an empty far terrain with the default settings.
+func NewFarTerrain() (*FarTerrain, error) {
+	t := &FarTerrain{Near: 64, Far: 4096, Sink: 2, Haze: 0.6}
+	t.shader = &Shader{}
+	t.shader.VertexShader(glslVersion + farVertexGLSL).FragmentShader(glslVersion + farFragmentGLSL)
+	if err := t.shader.Link(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Update replaces the terrain mesh with the vertices returned by mesh.Far.
+func (t *FarTerrain) Update(vertices []float32) {
+	b := Float32Bytes(vertices)
+	if t.buf == nil {
+		t.buf = NewMeshBuffer(FarLayout, len(b))
+	}
+	t.buf.Grow(len(b))
+	t.buf.Write(0, b)
+	t.count = len(b) / FarLayout.Stride
+}
+
+// Draw renders the terrain seen from the camera at eye, with the field of
+// view and aspect ratio of the projection, then clears the depth buffer.
+func (t *FarTerrain) Draw(eye glm.Vec3, view, projection glm.Mat4) {
+	if t.count == 0 {
+		return
+	}
+	// Recover the field of view and the aspect ratio of the camera, so the
+	// terrain lines up with the chunks drawn with the camera projection.
+	fov := 2 * float32(math.Atan(1/float64(projection.At(1, 1))))
+	aspect := projection.At(1, 1) / projection.At(0, 0)
+
+	DefaultPipeline.Apply()
+	t.shader.Use()
+	applyEnvironment(t.shader, environment, eye, 0, 0)
+	t.shader.UniformTransformation("view", view)
+	t.shader.UniformTransformation("projection", Projection(fov, aspect, t.Near, t.Far))
+	t.shader.UniformFloats("sink", t.Sink)
+	t.shader.UniformFloats("haze", t.Far*t.Haze, t.Far)
+	t.buf.Bind()
+	t.buf.DrawRange(0, t.count)
+	clearDepth()
+}
+
+// Pass returns a pass named "far" that draws the terrain with the frame
+// camera, to be added after PassSky and before PassOpaque.
+func (t *FarTerrain) Pass() Pass {
+	return NewPass("far", func(f *Frame) { t.Draw(f.Eye, f.View, f.Projection) })
+}
+
+// Delete releases the shader and the mesh.
+func (t *FarTerrain) Delete() {
+	t.shader.Delete()
+	if t.buf != nil {
+		t.buf.Delete()
+		t.buf = nil
+	}
+}
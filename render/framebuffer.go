@@ -0,0 +1,31 @@
+package render
+
+// TextureFormat is the pixel format of render target textures.
+type TextureFormat int
+
+const (
+	// FormatRGBA8 stores 8 bits per channel, suitable for colors.
+	FormatRGBA8 TextureFormat = iota
+	// FormatRGBA16F stores half floats per channel, suitable for normals
+	// and HDR colors.
+	FormatRGBA16F
+	// FormatDepth stores 24 bit depth values.
+	FormatDepth
+	// FormatRGBA32F stores floats per channel, suitable for world positions,
+	// which lose too much precision as half floats far from the origin.
+	FormatRGBA32F
+)
+
+// FullscreenVertexGLSL is the body of a vertex shader that generates a
+// triangle covering the screen, to be drawn with DrawFullscreen. It outputs
+// the texture coordinates as TexCoord. It must be preceded by the #version
+// statement.
+const FullscreenVertexGLSL = `
+out vec2 TexCoord;
+
+void main() {
+    vec2 pos = vec2(float((gl_VertexID << 1) & 2), float(gl_VertexID & 2));
+    TexCoord = pos;
+    gl_Position = vec4(pos * 2.0 - 1.0, 0.0, 1.0);
+}
+`
@@ -0,0 +1,67 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Frustum is the volume seen by a camera, used to skip the objects outside of
+// it before drawing them.
+//
+// Only the side planes and the camera plane are used: the near and far planes
+// depend on the depth mode, and objects past the far plane are usually culled
+// by the render distance already.
+type Frustum struct {
+	planes [5]glm.Vec4
+}
+
+// NewFrustum extracts the planes of the frustum from the product of the
+// projection and view matrices, in world space.
+func NewFrustum(viewProjection glm.Mat4) Frustum {
+	m := viewProjection
+	row := func(i int) glm.Vec4 { return m.Row(i) }
+	var f Frustum
+	f.planes[0] = row(3).Add(row(0)) // left
+	f.planes[1] = row(3).Sub(row(0)) // right
+	f.planes[2] = row(3).Add(row(1)) // bottom
+	f.planes[3] = row(3).Sub(row(1)) // top
+	f.planes[4] = row(3)             // in front of the camera
+	for i, p := range f.planes {
+		if l := p.Vec3().Len(); l > 0 {
+			f.planes[i] = p.Mul(1 / l)
+		}
+	}
+	return f
+}
+
+// FrameFrustum returns the frustum of the frame camera.
+func FrameFrustum(f *Frame) Frustum {
+	return NewFrustum(f.Projection.Mul4(f.View))
+}
+
+// IntersectsSphere returns false if the sphere is fully outside the frustum.
+func (f *Frustum) IntersectsSphere(center glm.Vec3, radius float32) bool {
+	for _, p := range f.planes {
+		if p.Vec3().Dot(center)+p[3] < -radius {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsBox returns false if the axis aligned box is fully outside the
+// frustum. Boxes near the frustum corners may be reported as intersecting.
+func (f *Frustum) IntersectsBox(min, max glm.Vec3) bool {
+	for _, p := range f.planes {
+		// The corner farthest along the plane normal.
+		c := min
+		for i := 0; i < 3; i++ {
+			if p[i] > 0 {
+				c[i] = max[i]
+			}
+		}
+		if p.Vec3().Dot(c)+p[3] < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,396 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// ResourceDesc describes a transient render target of a FrameGraph.
+type ResourceDesc struct {
+	// Width and Height are the size in pixels. When zero, the frame size
+	// multiplied by Scale is used.
+	Width, Height int
+	// Scale is the size relative to the frame, used when Width and Height
+	// are zero. Zero is handled as one.
+	Scale float64
+	// Formats are the color textures; all targets have a depth texture.
+	Formats []TextureFormat
+}
+
+func (d ResourceDesc) size(frameW, frameH int) (int, int) {
+	if d.Width > 0 && d.Height > 0 {
+		return d.Width, d.Height
+	}
+	s := d.Scale
+	if s <= 0 {
+		s = 1
+	}
+	w, h := int(float64(frameW)*s), int(float64(frameH)*s)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// GraphPass is a pass of a FrameGraph, declaring the resources it reads and
+// writes.
+type GraphPass struct {
+	Name string
+	// Reads and Writes are resource names. A pass that writes a resource
+	// already written by an earlier pass runs after it, and passes reading a
+	// resource run after all the passes writing it.
+	Reads  []string
+	Writes []string
+	// Output marks passes with effects outside of the graph, such as drawing
+	// to the window. Passes that are not outputs, and whose results are not
+	// read by any remaining pass, are culled.
+	Output bool
+	Draw   func(f *Frame, res *GraphResources)
+}
+
+// GraphResources gives the passes access to the render targets of the graph.
+type GraphResources struct {
+	targets map[string]*Framebuffer
+}
+
+// Get returns the render target of the resource. Transient targets are not
+// cleared between frames, and may be shared with other resources whose
+// lifetime does not overlap, so the first pass writing one must clear it.
+func (r *GraphResources) Get(name string) *Framebuffer {
+	return r.targets[name]
+}
+
+// FrameGraph orders the passes of a frame from the resources they declare,
+// culls the passes whose results are unused, and allocates the transient
+// render targets, reusing them between resources that are not alive at the
+// same time.
+//
+// The graph is compiled again after any change, or when the frame size
+// changes. It can be added to a Renderer with Pass.
+type FrameGraph struct {
+	resources map[string]ResourceDesc
+	imported  map[string]*Framebuffer
+	passes    []*GraphPass
+
+	compiled bool
+	order    []*GraphPass
+	res      GraphResources
+	// pool holds the framebuffers created for the transient resources.
+	pool           []*Framebuffer
+	frameW, frameH int
+}
+
+// NewFrameGraph creates an empty graph.
+func NewFrameGraph() *FrameGraph {
+	return &FrameGraph{
+		resources: make(map[string]ResourceDesc),
+		imported:  make(map[string]*Framebuffer),
+	}
+}
+
+// Declare adds a transient resource, allocated by the graph.
+func (g *FrameGraph) Declare(name string, desc ResourceDesc) error {
+	if g.has(name) {
+		return fmt.Errorf("render: resource %q already exists", name)
+	}
+	g.resources[name] = desc
+	g.compiled = false
+	return nil
+}
+
+// Import adds a resource owned by the caller, such as the G-buffer of a
+// DeferredRenderer.
+func (g *FrameGraph) Import(name string, fb *Framebuffer) error {
+	if g.has(name) {
+		return fmt.Errorf("render: resource %q already exists", name)
+	}
+	g.imported[name] = fb
+	g.compiled = false
+	return nil
+}
+
+func (g *FrameGraph) has(name string) bool {
+	_, declared := g.resources[name]
+	_, imported := g.imported[name]
+	return declared || imported
+}
+
+// AddPass adds a pass to the graph. Pass names must be unique.
+func (g *FrameGraph) AddPass(p GraphPass) error {
+	for _, o := range g.passes {
+		if o.Name == p.Name {
+			return fmt.Errorf("render: pass %q already exists", p.Name)
+		}
+	}
+	g.passes = append(g.passes, &p)
+	g.compiled = false
+	return nil
+}
+
+// RemovePass deletes the pass with the provided name.
+func (g *FrameGraph) RemovePass(name string) {
+	for i, p := range g.passes {
+		if p.Name == name {
+			g.passes = append(g.passes[:i], g.passes[i+1:]...)
+			g.compiled = false
+			return
+		}
+	}
+}
+
+// Order returns the names of the passes that will run, in order. It returns
+// an error if the graph references unknown resources or has a cycle.
+func (g *FrameGraph) Order() ([]string, error) {
+	order, err := g.sort()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(order))
+	for i, p := range order {
+		names[i] = p.Name
+	}
+	return names, nil
+}
+
+// sort culls the unused passes and returns the others in dependency order.
+// Independent passes keep the order they were added.
+func (g *FrameGraph) sort() ([]*GraphPass, error) {
+	index := make(map[*GraphPass]int, len(g.passes))
+	writers := make(map[string][]*GraphPass)
+	for i, p := range g.passes {
+		index[p] = i
+		for _, r := range append(append([]string(nil), p.Reads...), p.Writes...) {
+			if !g.has(r) {
+				return nil, fmt.Errorf("render: pass %q uses unknown resource %q", p.Name, r)
+			}
+		}
+		for _, r := range p.Writes {
+			writers[r] = append(writers[r], p)
+		}
+	}
+
+	// Edges go from each pass to the passes that depend on it.
+	deps := make(map[*GraphPass]map[*GraphPass]bool)
+	addEdge := func(from, to *GraphPass) {
+		if from == to {
+			return
+		}
+		if deps[to] == nil {
+			deps[to] = make(map[*GraphPass]bool)
+		}
+		deps[to][from] = true
+	}
+	for _, p := range g.passes {
+		for _, r := range p.Reads {
+			for _, w := range writers[r] {
+				addEdge(w, p)
+			}
+		}
+		for _, r := range p.Writes {
+			for _, w := range writers[r] {
+				if index[w] < index[p] {
+					addEdge(w, p)
+				}
+			}
+		}
+	}
+
+	// Cull: keep the outputs and, transitively, what they depend on.
+	live := make(map[*GraphPass]bool)
+	var mark func(p *GraphPass)
+	mark = func(p *GraphPass) {
+		if live[p] {
+			return
+		}
+		live[p] = true
+		for d := range deps[p] {
+			mark(d)
+		}
+	}
+	for _, p := range g.passes {
+		if p.Output {
+			mark(p)
+		}
+	}
+
+	// Kahn's algorithm, picking the ready pass added first.
+	pending := make(map[*GraphPass]int)
+	var ready []*GraphPass
+	for p := range live {
+		for d := range deps[p] {
+			if live[d] {
+				pending[p]++
+			}
+		}
+		if pending[p] == 0 {
+			ready = append(ready, p)
+		}
+	}
+	var order []*GraphPass
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return index[ready[i]] < index[ready[j]] })
+		p := ready[0]
+		ready = ready[1:]
+		order = append(order, p)
+		for q := range live {
+			if deps[q][p] {
+				if pending[q]--; pending[q] == 0 {
+					ready = append(ready, q)
+				}
+			}
+		}
+	}
+	if len(order) != len(live) {
+		return nil, fmt.Errorf("render: frame graph has a dependency cycle")
+	}
+	return order, nil
+}
+
+// compile orders the passes and assigns a framebuffer to each transient
+// resource.
+func (g *FrameGraph) compile(frameW, frameH int) error {
+	order, err := g.sort()
+	if err != nil {
+		return err
+	}
+
+	// Lifetime of each transient resource, as the first and last pass index
+	// using it.
+	type lifetime struct{ first, last int }
+	lifetimes := make(map[string]*lifetime)
+	var names []string
+	for i, p := range order {
+		for _, r := range append(append([]string(nil), p.Reads...), p.Writes...) {
+			if _, ok := g.resources[r]; !ok {
+				continue
+			}
+			if l, ok := lifetimes[r]; ok {
+				l.last = i
+			} else {
+				lifetimes[r] = &lifetime{i, i}
+				names = append(names, r)
+			}
+		}
+	}
+
+	// Assign in order of first use, reusing a framebuffer of the same size
+	// and formats once its current resource is no longer used.
+	type slot struct {
+		fb      *Framebuffer
+		formats []TextureFormat
+		freeAt  int
+	}
+	var slots []*slot
+	reuse := g.pool
+	targets := make(map[string]*Framebuffer, len(names)+len(g.imported))
+	for _, name := range names {
+		desc, l := g.resources[name], lifetimes[name]
+		w, h := desc.size(frameW, frameH)
+		var s *slot
+		for _, c := range slots {
+			if c.freeAt < l.first && c.fb.Width == w && c.fb.Height == h && sameFormats(c.formats, desc.Formats) {
+				s = c
+				break
+			}
+		}
+		if s == nil {
+			fb, err := g.framebuffer(&reuse, w, h, desc.Formats)
+			if err != nil {
+				return err
+			}
+			s = &slot{fb: fb, formats: desc.Formats}
+			slots = append(slots, s)
+		}
+		s.freeAt = l.last
+		targets[name] = s.fb
+	}
+	for _, fb := range reuse {
+		fb.Delete()
+	}
+	g.pool = g.pool[:0]
+	for _, s := range slots {
+		g.pool = append(g.pool, s.fb)
+	}
+	for name, fb := range g.imported {
+		targets[name] = fb
+	}
+
+	g.order = order
+	g.res = GraphResources{targets}
+	g.frameW, g.frameH = frameW, frameH
+	g.compiled = true
+	return nil
+}
+
+// framebuffer takes a framebuffer with the size and formats from the free
+// list, or creates a new one.
+func (g *FrameGraph) framebuffer(free *[]*Framebuffer, w, h int, formats []TextureFormat) (*Framebuffer, error) {
+	for i, fb := range *free {
+		if fb.Width == w && fb.Height == h && sameFormats(fb.formats, formats) {
+			*free = append((*free)[:i], (*free)[i+1:]...)
+			return fb, nil
+		}
+	}
+	return NewFramebuffer(w, h, formats...)
+}
+
+func sameFormats(a, b []TextureFormat) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Execute runs the graph for a frame of the provided size, compiling it first
+// if needed.
+func (g *FrameGraph) Execute(f *Frame, width, height int) error {
+	if !g.compiled || width != g.frameW || height != g.frameH {
+		if err := g.compile(width, height); err != nil {
+			return err
+		}
+	}
+	for _, p := range g.order {
+		if p.Draw != nil {
+			p.Draw(f, &g.res)
+		}
+	}
+	return nil
+}
+
+// Pass returns a Renderer pass that executes the graph with the frame size.
+// Errors compiling the graph are logged once per change.
+func (g *FrameGraph) Pass(name string) Pass {
+	var lastErr string
+	return NewPass(name, func(f *Frame) {
+		if f.Window == nil {
+			return
+		}
+		width, height := f.Size()
+		if err := g.Execute(f, width, height); err != nil {
+			if err.Error() != lastErr {
+				log.Errorf("Error executing frame graph %v: %v", name, err)
+				lastErr = err.Error()
+			}
+		}
+	})
+}
+
+// Delete releases the transient render targets. Imported resources are not
+// deleted.
+func (g *FrameGraph) Delete() {
+	for _, fb := range g.pool {
+		fb.Delete()
+	}
+	g.pool = nil
+	g.compiled = false
+}
@@ -0,0 +1,33 @@
+package render
+
+// Graphics holds the rendering settings chosen by the player in the graphics
+// menu.
+type Graphics struct {
+	// AutoExposure adapts the exposure of the AutoExposure to the brightness
+	// of the scene. When false, the exposure stays at 0 EV.
+	AutoExposure bool
+	// MinExposure and MaxExposure limit the exposure of the AutoExposure, in
+	// EV: the scene is at most 2^-MinExposure times darker and 2^MaxExposure
+	// times brighter than drawn, so caves are not lit like day and the sun is
+	// not a black spot.
+	MinExposure, MaxExposure float32
+}
+
+// DefaultGraphics enables the auto exposure between -2 and 3 EV.
+var DefaultGraphics = Graphics{AutoExposure: true, MinExposure: -2, MaxExposure: 3}
+
+var graphics = DefaultGraphics
+
+// SetGraphics changes the graphics settings. A MaxExposure lower than
+// MinExposure is replaced by MinExposure.
+func SetGraphics(g Graphics) {
+	if g.MaxExposure < g.MinExposure {
+		g.MaxExposure = g.MinExposure
+	}
+	graphics = g
+}
+
+// CurrentGraphics returns the graphics settings in use.
+func CurrentGraphics() Graphics {
+	return graphics
+}
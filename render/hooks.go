@@ -0,0 +1,198 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// HooksInclude is the line replaced by the shader hooks when a shader source is
+// added with VertexShader or FragmentShader. It must be placed after the
+// #version and precision statements.
+const HooksInclude = "#include <openvoxel/hooks.glsl>"
+
+// ShaderHook names a GLSL function that mods can replace with SetShaderHook to
+// change the look of the built-in shaders and of the game shaders that include
+// the hooks, without forking them.
+type ShaderHook string
+
+const (
+	// HookSurface changes the base color of a block surface before lighting:
+	//  vec4 surfaceColor(vec4 albedo, vec3 worldPos, vec3 normal)
+	HookSurface ShaderHook = "surfaceColor"
+	// HookFog blends a lit color with the fog:
+	//  vec3 applyFog(vec3 color, vec3 worldPos)
+	HookFog ShaderHook = "applyFog"
+	// HookSky returns the color of the sky in a world space direction:
+	//  vec3 skyColor(vec3 dir)
+	HookSky ShaderHook = "skyColor"
+)
+
+// hookOrder is the order the hooks are declared in, so later hooks can call
+// the earlier ones.
+var hookOrder = []ShaderHook{HookSky, HookFog, HookSurface}
+
+// defaultHooks are the built-in implementations of the hooks.
+var defaultHooks = map[ShaderHook]string{
+	HookSurface: `
+vec4 surfaceColor(vec4 albedo, vec3 worldPos, vec3 normal) {
+    return albedo;
+}
+`,
+	HookFog: `
+vec3 applyFog(vec3 color, vec3 worldPos) {
+    if (envFogRange.y <= envFogRange.x) {
+        return color;
+    }
+    float d = distance(worldPos, envEye);
+    float f = clamp((d - envFogRange.x) / (envFogRange.y - envFogRange.x), 0.0, 1.0);
+    return mix(color, envFogColor, f);
+}
+`,
+	HookSky: `
+vec3 skyColor(vec3 dir) {
+    dir = normalize(dir);
+    float h = clamp(dir.y, 0.0, 1.0);
+    float elevation = envSunDirection.y;
+    float day = clamp(elevation * 4.0 + 0.5, 0.1, 1.0);
+    vec3 color = mix(envSkyHorizon, envSkyZenith, sqrt(h)) * day;
+    // The horizon glows orange around the sun while it rises and sets.
+    float twilight = 1.0 - smoothstep(0.0, 0.35, abs(elevation));
+    float glow = pow(max(dot(dir, envSunDirection), 0.0), 4.0) * (1.0 - h);
+    return color + vec3(1.0, 0.45, 0.15) * twilight * glow;
+}
+`,
+}
+
+// environmentGLSL declares the uniforms set by ApplyEnvironment, available to
+// all hooks.
+const environmentGLSL = `
+uniform float envTime;         // seconds since the window was created
+uniform float envTimeOfDay;    // 0: midnight, 0.25: sunrise, 0.5: noon
+uniform vec3 envSunDirection;  // unit vector towards the sun
+uniform vec3 envEye;           // camera position
+uniform vec3 envFogColor;
+uniform vec2 envFogRange;      // fog start and end distances
+uniform vec3 envSkyZenith;
+uniform vec3 envSkyHorizon;
+`
+
+var hooks = map[ShaderHook]string{}
+
+// SetShaderHook replaces the implementation of the hook with the GLSL source,
+// which must define the hook function with its documented signature, and may
+// declare helper functions and uniforms. An empty source restores the default.
+// Only shaders added after the call use the new implementation.
+func SetShaderHook(hook ShaderHook, src string) error {
+	if _, ok := defaultHooks[hook]; !ok {
+		return fmt.Errorf("shader hook: unknown hook %q", hook)
+	}
+	if src == "" {
+		delete(hooks, hook)
+		return nil
+	}
+	if !strings.Contains(src, string(hook)+"(") {
+		return fmt.Errorf("shader hook: source does not define %v", hook)
+	}
+	hooks[hook] = src
+	return nil
+}
+
+// ResetShaderHooks restores the default implementation of all hooks.
+func ResetShaderHooks() {
+	hooks = map[ShaderHook]string{}
+}
+
+// HooksGLSL returns the environment uniforms and the current implementation
+// of the hooks, the text that replaces HooksInclude.
+func HooksGLSL() string {
+	var b strings.Builder
+	b.WriteString(environmentGLSL)
+	for _, h := range hookOrder {
+		if src, ok := hooks[h]; ok {
+			b.WriteString(src)
+		} else {
+			b.WriteString(defaultHooks[h])
+		}
+	}
+	return b.String()
+}
+
+// preprocessGLSL replaces the HooksInclude lines of src with HooksGLSL.
+func preprocessGLSL(src string) string {
+	if !strings.Contains(src, HooksInclude) {
+		return src
+	}
+	return strings.ReplaceAll(src, HooksInclude, HooksGLSL())
+}
+
+// Environment holds the world state shared by the shader hooks.
+type Environment struct {
+	// TimeOfDay is the fraction of the day, from 0 to 1: 0 is midnight,
+	// 0.25 the sunrise, 0.5 noon and 0.75 the sunset.
+	TimeOfDay float32
+	// SunDirection is the direction towards the sun. The zero vector uses
+	// SunDirection(TimeOfDay).
+	SunDirection glm.Vec3
+	// FogColor is the color of the fog, with the distances taken from the
+	// camera FogStart and FogEnd.
+	FogColor glm.Vec3
+	// SkyZenith and SkyHorizon are the colors of the default sky at noon.
+	SkyZenith, SkyHorizon glm.Vec3
+}
+
+// DefaultEnvironment is noon on a clear day.
+var DefaultEnvironment = Environment{
+	TimeOfDay:  0.5,
+	FogColor:   glm.Vec3{0.53, 0.81, 0.92},
+	SkyZenith:  glm.Vec3{0.3, 0.55, 0.95},
+	SkyHorizon: glm.Vec3{0.75, 0.87, 1},
+}
+
+var environment = DefaultEnvironment
+
+// SetEnvironment changes the values passed to the hooks by ApplyEnvironment.
+func SetEnvironment(e Environment) {
+	environment = e
+}
+
+// CurrentEnvironment returns the environment in use.
+func CurrentEnvironment() Environment {
+	return environment
+}
+
+// SunDirection returns the direction towards the sun at the time of day. The
+// sun rises at +X, is straight up at noon and sets at -X, slightly tilted to
+// +Z so it never crosses the zenith exactly.
+func SunDirection(timeOfDay float32) glm.Vec3 {
+	a := float64(timeOfDay-0.25) * 2 * math.Pi
+	return glm.Vec3{float32(math.Cos(a)), float32(math.Sin(a)), 0.2}.Normalize()
+}
+
+// ApplyEnvironment sets the uniforms declared by the hooks on the shader, for
+// the camera. The shader must be in use.
+func ApplyEnvironment(shader *Shader, cam *Camera) {
+	applyEnvironment(shader, environment, cam.Position(), cam.FogStart, cam.FogEnd)
+}
+
+// sun returns the direction towards the sun in the environment.
+func (e Environment) sun() glm.Vec3 {
+	if e.SunDirection.Len() == 0 {
+		return SunDirection(e.TimeOfDay)
+	}
+	return e.SunDirection
+}
+
+func applyEnvironment(shader *Shader, e Environment, eye glm.Vec3, fogStart, fogEnd float32) {
+	sun := e.sun()
+	shader.UniformFloats("envTime", float32(Time()))
+	shader.UniformFloats("envTimeOfDay", e.TimeOfDay)
+	shader.UniformFloats("envSunDirection", sun[0], sun[1], sun[2])
+	shader.UniformFloats("envEye", eye[0], eye[1], eye[2])
+	shader.UniformFloats("envFogColor", e.FogColor[0], e.FogColor[1], e.FogColor[2])
+	shader.UniformFloats("envFogRange", fogStart, fogEnd)
+	shader.UniformFloats("envSkyZenith", e.SkyZenith[0], e.SkyZenith[1], e.SkyZenith[2])
+	shader.UniformFloats("envSkyHorizon", e.SkyHorizon[0], e.SkyHorizon[1], e.SkyHorizon[2])
+}
@@ -0,0 +1,127 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+// Heart frames, laid out horizontally in the Hearts texture.
+const (
+	heartEmpty = iota
+	heartHalf
+	heartFull
+	heartFrames
+)
+
+// Hearts is the HUD widget showing the health of the player as a row of
+// hearts, each one worth two points of health. The hearts blink for a moment
+// after damage is taken.
+type Hearts struct {
+	// Texture holds the empty, half and full heart images side by side.
+	Texture *Texture
+	// Size is the size of each heart, and X and Y the offset of the row
+	// from the bottom left corner, in UI units. See UIProjection.
+	Size, X, Y float32
+	// PerRow is the number of hearts in each row before wrapping up.
+	PerRow int
+
+	health, max float32
+	blink       float32
+	mesh        *DynamicMesh
+	dirty       bool
+	w, h        int
+	scale       float32
+}
+
+// NewHearts creates a hearts widget with the texture.
+func NewHearts(tex *Texture) *Hearts {
+	return &Hearts{Texture: tex, Size: 9, X: 8, Y: 8, PerRow: 10}
+}
+
+// Set changes the health shown.
+func (h *Hearts) Set(health, max float32) {
+	if health < h.health {
+		h.blink = 0.6
+	}
+	h.health, h.max = health, max
+	h.dirty = true
+}
+
+// Listen updates the health shown from the event.HealthChanged published
+// for the entity. It returns a function that stops listening.
+func (h *Hearts) Listen(b *event.Bus, entity uint64) (unsubscribe func()) {
+	return event.Subscribe(b, func(e event.HealthChanged) {
+		if e.Entity == entity {
+			h.Set(e.Health, e.Max)
+		}
+	})
+}
+
+// Update advances the blinking by dt seconds.
+func (h *Hearts) Update(dt float32) {
+	if h.blink > 0 {
+		h.blink -= dt
+	}
+}
+
+func (h *Hearts) rebuild(width, height int) {
+	var vertices []float32
+	s := accessibility.UIScale
+	bottom := float32(height)/s - h.Y
+	count := int(h.max+1) / 2
+	for i := 0; i < count; i++ {
+		frame := heartEmpty
+		switch v := h.health - float32(i*2); {
+		case v >= 2:
+			frame = heartFull
+		case v >= 1:
+			frame = heartHalf
+		}
+		x := h.X + float32(i%h.PerRow)*h.Size
+		y := bottom - float32(i/h.PerRow+1)*h.Size
+		u0 := float32(frame) / heartFrames
+		u1 := float32(frame+1) / heartFrames
+		// The UI y axis points down, and the textures start at the bottom.
+		a := []float32{x, y + h.Size, 0, u0, 0}
+		b := []float32{x + h.Size, y + h.Size, 0, u1, 0}
+		c := []float32{x + h.Size, y, 0, u1, 1}
+		d := []float32{x, y, 0, u0, 1}
+		for _, v := range [][]float32{a, b, c, a, c, d} {
+			vertices = append(vertices, v...)
+		}
+	}
+	if h.mesh == nil {
+		h.mesh = NewDynamicMesh()
+	}
+	h.mesh.Update(vertices)
+	h.dirty, h.w, h.h, h.scale = false, width, height, s
+}
+
+// Draw renders the hearts on a render target of width x height pixels with
+// the shader, which must sample the texture at unit 0 using the vertex
+// attributes at locations 0 and 1.
+func (h *Hearts) Draw(shader *Shader, width, height int) {
+	if h.dirty || width != h.w || height != h.h || h.scale != accessibility.UIScale {
+		h.rebuild(width, height)
+	}
+	// Blink four times a second while recovering from damage.
+	if h.blink > 0 && int(h.blink*8)%2 == 1 {
+		return
+	}
+	shader.Use()
+	shader.UniformTransformation("projection", UIProjection(width, height))
+	shader.UniformTransformation("view", glm.Ident4())
+	shader.UniformTransformation("model", glm.Ident4())
+	HUDPipeline.Apply()
+	h.Texture.Bind(0)
+	h.mesh.Draw()
+	DefaultPipeline.Apply()
+}
+
+// Delete releases the mesh. The texture is not deleted.
+func (h *Hearts) Delete() {
+	if h.mesh != nil {
+		h.mesh.Delete()
+		h.mesh = nil
+	}
+}
@@ -0,0 +1,113 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// IconRenderer draws block models into small images for the inventory and
+// hotbar, seen from above at an isometric angle, so icons need not be drawn by
+// hand. It renders into an offscreen framebuffer and waits for the GPU to read
+// the pixels back, so it is meant for startup and build tools, not for every
+// frame.
+type IconRenderer struct {
+	// Layout is the layout of the vertices, TintedLayout by default as
+	// produced by mesh.Mesher.Item.
+	Layout VertexLayout
+
+	fb  *Framebuffer
+	buf *MeshBuffer
+}
+
+// NewIconRenderer creates an icon renderer for icons of size x size pixels.
+func NewIconRenderer(size int) (*IconRenderer, error) {
+	fb, err := NewFramebuffer(size, size, FormatRGBA8)
+	if err != nil {
+		return nil, err
+	}
+	return &IconRenderer{Layout: TintedLayout, fb: fb}, nil
+}
+
+// IconView returns the view and projection of the icons: an orthographic
+// camera looking at the center of the block from the top, east and south
+// sides, framing the whole block.
+func IconView() (view, projection glm.Mat4) {
+	center := glm.Vec3{0.5, 0.5, 0.5}
+	view = transform.LookAt(center.Add(glm.Vec3{2, 2, 2}), center, glm.Vec3{0, 1, 0})
+	// The corners of a unit cube are at most sqrt(2/3) from its center in
+	// the isometric view.
+	projection = glm.Ortho(-0.82, 0.82, -0.82, 0.82, 0.1, 10)
+	return view, projection
+}
+
+// Render draws the vertices of a block model, in block local coordinates from
+// 0 to 1, with the shader and the texture at unit 0, and returns the icon with
+// the first row at the top and a transparent background. The shader must use
+// the model, view and projection uniforms; shaders using the ChunkOffsetGLSL
+// attribute get a zero offset. The framebuffer stays bound, so the caller must
+// bind its render target again.
+func (r *IconRenderer) Render(shader *Shader, vertices []byte, tex *Texture) *image.RGBA {
+	if r.buf == nil {
+		r.buf = NewMeshBuffer(r.Layout, len(vertices))
+	}
+	r.buf.Grow(len(vertices))
+	r.buf.Write(0, vertices)
+
+	r.fb.Bind()
+	r.fb.Clear()
+	view, projection := IconView()
+	shader.Use()
+	shader.UniformTransformation("projection", projection)
+	shader.UniformTransformation("view", view)
+	shader.UniformTransformation("model", glm.Ident4())
+	if tex != nil {
+		tex.Bind(0)
+	}
+	DefaultPipeline.Apply()
+	r.buf.Bind()
+	r.buf.setChunkOffset(0, 0, 0)
+	r.buf.DrawRange(0, len(vertices)/r.Layout.Stride)
+	return r.fb.ReadPixels(0)
+}
+
+// Delete releases the framebuffer and the vertex buffer.
+func (r *IconRenderer) Delete() {
+	r.fb.Delete()
+	if r.buf != nil {
+		r.buf.Delete()
+		r.buf = nil
+	}
+}
+
+// IconSheet places the icons side by side, perRow icons per row from the top
+// left corner, so they can be uploaded as a single texture with
+// NewTextureFromImage. Icon i is at column i%perRow and row i/perRow. All
+// icons must have the size of the first one.
+func IconSheet(icons []*image.RGBA, perRow int) *image.RGBA {
+	if len(icons) == 0 || perRow < 1 {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
+	}
+	size := icons[0].Bounds().Size()
+	rows := (len(icons) + perRow - 1) / perRow
+	sheet := image.NewRGBA(image.Rect(0, 0, size.X*perRow, size.Y*rows))
+	for i, icon := range icons {
+		at := image.Pt(i%perRow*size.X, i/perRow*size.Y)
+		draw.Draw(sheet, image.Rectangle{at, at.Add(size)}, icon, icon.Bounds().Min, draw.Src)
+	}
+	return sheet
+}
+
+// NewTextureFromImage uploads the image as a texture, such as the images
+// generated at runtime by the IconRenderer.
+func NewTextureFromImage(img image.Image) (*Texture, error) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return NewTextureFromBytes(b.Bytes())
+}
@@ -0,0 +1,68 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// ImageDecoder decodes image files into RGBA pixels, with the bottom row first
+// as expected by the texture upload.
+//
+// The default decoder depends on the build: regular builds use the imaging
+// package and support all of its formats, while builds with the
+// openvoxel_small tag only decode PNG files with the standard library, to
+// keep the wasm payload small.
+type ImageDecoder interface {
+	Decode(b []byte) (w, h int, px []uint8, err error)
+}
+
+var imageDecoder ImageDecoder = defaultImageDecoder{}
+
+// SetImageDecoder replaces the decoder used to load textures. Passing nil
+// restores the default decoder.
+func SetImageDecoder(d ImageDecoder) {
+	if d == nil {
+		d = defaultImageDecoder{}
+	}
+	imageDecoder = d
+}
+
+// decodeImage decodes the image with the current decoder. Errors are returned
+// as a TextureError.
+func decodeImage(b []byte) (w, h int, px []uint8, err error) {
+	w, h, px, err = imageDecoder.Decode(b)
+	if err != nil {
+		var te *TextureError
+		if !errors.As(err, &te) {
+			err = &TextureError{Format: imageFormat(b), Err: err}
+		}
+		return 0, 0, nil, err
+	}
+	return w, h, px, nil
+}
+
+// rgbaPixels converts img to tightly packed RGBA pixels.
+func rgbaPixels(img image.Image) (w, h int, px []uint8, err error) {
+	rgba := image.NewRGBA(img.Bounds())
+	if rgba.Stride != rgba.Rect.Size().X*4 {
+		return 0, 0, nil, fmt.Errorf("unsupported stride %d", rgba.Stride)
+	}
+	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+	return rgba.Rect.Size().X, rgba.Rect.Size().Y, rgba.Pix, nil
+}
+
+// flipRows reverses the order of the image rows in place, to convert between
+// the bottom-up order of the GPU and the top-down order of images.
+func flipRows(img *image.RGBA) {
+	h := img.Rect.Dy()
+	row := make([]byte, img.Stride)
+	for y := 0; y < h/2; y++ {
+		top := img.Pix[y*img.Stride : (y+1)*img.Stride]
+		bottom := img.Pix[(h-1-y)*img.Stride : (h-y)*img.Stride]
+		copy(row, top)
+		copy(top, bottom)
+		copy(bottom, row)
+	}
+}
@@ -0,0 +1,31 @@
+//go:build openvoxel_small
+
+package render
+
+import (
+	"bytes"
+	"image/png"
+)
+
+type defaultImageDecoder struct{}
+
+func (defaultImageDecoder) Decode(b []byte) (w, h int, px []uint8, err error) {
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	w, h, px, err = rgbaPixels(img)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	// Flip in place, so the first row is the bottom one.
+	stride := w * 4
+	row := make([]uint8, stride)
+	for top, bottom := 0, h-1; top < bottom; top, bottom = top+1, bottom-1 {
+		a, b := px[top*stride:(top+1)*stride], px[bottom*stride:(bottom+1)*stride]
+		copy(row, a)
+		copy(a, b)
+		copy(b, row)
+	}
+	return w, h, px, nil
+}
@@ -0,0 +1,26 @@
+//go:build !openvoxel_small
+
+package render
+
+import (
+	"bytes"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/disintegration/imaging"
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+type defaultImageDecoder struct{}
+
+func (defaultImageDecoder) Decode(b []byte) (w, h int, px []uint8, err error) {
+	img, ftype, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	img = imaging.FlipV(img)
+	w, h = img.Bounds().Size().X, img.Bounds().Size().Y
+	log.Infof("Loaded %v image (%dx%d) from %v bytes", ftype, w, h, len(b))
+	return rgbaPixels(img)
+}
@@ -0,0 +1,171 @@
+package render
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// LightKind is the type of a dynamic light.
+type LightKind int
+
+const (
+	PointLight LightKind = iota
+	SpotLight
+)
+
+// Light is a dynamic light source, such as a torch carried by the player or a
+// light attached to an entity. Static lights should be baked into the voxel
+// light instead.
+type Light struct {
+	Kind     LightKind
+	Position glm.Vec3
+	Color    glm.Vec3
+	// Radius is the distance where the light intensity reaches zero.
+	Radius float32
+
+	// Direction and cone angles, in radians, are only used by spot lights.
+	Direction glm.Vec3
+	InnerCone float32
+	OuterCone float32
+}
+
+// affects returns true if the light can reach the box between min and max.
+func (l *Light) affects(min, max glm.Vec3) bool {
+	var d float32
+	for i := 0; i < 3; i++ {
+		if v := l.Position[i]; v < min[i] {
+			d += (min[i] - v) * (min[i] - v)
+		} else if v > max[i] {
+			d += (v - max[i]) * (v - max[i])
+		}
+	}
+	return d <= l.Radius*l.Radius
+}
+
+// MaxLights is the number of lights supported by the lights uniform block.
+const MaxLights = 32
+
+// LightsBlockBinding is the uniform buffer binding point used for the lights.
+const LightsBlockBinding = 0
+
+// LightsGLSL declares the uniform block with the dynamic lights and a function
+// to compute their contribution. It must be included by the lit shaders after
+// the #version and precision statements.
+const LightsGLSL = `
+#define MAX_LIGHTS 32
+struct Light {
+    vec4 position;  // xyz: position, w: radius
+    vec4 color;     // rgb: color, w: kind (0=point, 1=spot)
+    vec4 direction; // xyz: direction, w: cos(inner cone)
+    vec4 cone;      // x: cos(outer cone)
+};
+layout (std140) uniform Lights {
+    ivec4 lightCount;
+    Light lights[MAX_LIGHTS];
+};
+
+vec3 dynamicLight(vec3 pos, vec3 normal) {
+    vec3 result = vec3(0.0);
+    for (int i = 0; i < lightCount.x; i++) {
+        Light l = lights[i];
+        vec3 toLight = l.position.xyz - pos;
+        float dist = length(toLight);
+        vec3 dir = toLight / max(dist, 0.0001);
+        float atten = clamp(1.0 - dist / l.position.w, 0.0, 1.0);
+        atten *= atten;
+        if (l.color.w > 0.5) {
+            float theta = dot(-dir, normalize(l.direction.xyz));
+            atten *= smoothstep(l.cone.x, l.direction.w, theta);
+        }
+        result += l.color.rgb * atten * max(dot(normal, dir), 0.0);
+    }
+    return result;
+}
+`
+
+// lightStride is the number of floats used by each light in the uniform block.
+const lightStride = 16
+
+// LightManager keeps the dynamic lights of the scene, and uploads the lights
+// affecting each area to the lit shaders.
+type LightManager struct {
+	lights []*Light
+	buf    *UniformBuffer
+	data   []float32
+}
+
+// NewLightManager creates an empty light manager, allocating the uniform
+// buffer used by the lit shaders.
+func NewLightManager() *LightManager {
+	m := &LightManager{
+		data: make([]float32, 4+MaxLights*lightStride),
+	}
+	m.buf = NewUniformBuffer(len(m.data))
+	return m
+}
+
+// Add adds a light to the scene. The light can be modified after added.
+func (m *LightManager) Add(l *Light) {
+	m.lights = append(m.lights, l)
+}
+
+// Remove removes the light from the scene.
+func (m *LightManager) Remove(l *Light) {
+	for i, o := range m.lights {
+		if o == l {
+			m.lights = append(m.lights[:i], m.lights[i+1:]...)
+			return
+		}
+	}
+}
+
+// Lights returns all lights in the scene.
+func (m *LightManager) Lights() []*Light {
+	return m.lights
+}
+
+// Cull returns up to MaxLights lights that affect the box between min and max,
+// such as a chunk bounding box.
+func (m *LightManager) Cull(min, max glm.Vec3) []*Light {
+	var out []*Light
+	for _, l := range m.lights {
+		if l.affects(min, max) {
+			out = append(out, l)
+			if len(out) == MaxLights {
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Upload sends the lights affecting the box between min and max to the lights
+// uniform block. It must be called before drawing each chunk.
+func (m *LightManager) Upload(min, max glm.Vec3) {
+	lights := m.Cull(min, max)
+	d := m.data
+	// The count is an ivec4 in the block, so its bits are written as an
+	// int, not converted to a float.
+	d[0] = math.Float32frombits(uint32(len(lights)))
+	for i, l := range lights {
+		o := 4 + i*lightStride
+		copy(d[o:], []float32{
+			l.Position[0], l.Position[1], l.Position[2], l.Radius,
+			l.Color[0], l.Color[1], l.Color[2], float32(l.Kind),
+			l.Direction[0], l.Direction[1], l.Direction[2], cos(l.InnerCone),
+			cos(l.OuterCone), 0, 0, 0,
+		})
+	}
+	m.buf.Update(d[:4+len(lights)*lightStride])
+	m.buf.Bind(LightsBlockBinding)
+}
+
+// Delete releases the uniform buffer used by the manager.
+func (m *LightManager) Delete() {
+	m.buf.Delete()
+}
+
+func cos(rad float32) float32 {
+	return float32(math.Cos(float64(rad)))
+}
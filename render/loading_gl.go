@@ -0,0 +1,55 @@
+//go:build !js && !openvoxel_fake
+
+package render
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// loadingStatus is the last status shown, so it is only logged once.
+var loadingStatus string
+
+// ShowLoading draws a progress bar, with progress in the range [0, 1], over
+// the whole window. The status is only logged, as there is no text rendering
+// on the native backend. Callers must call SwapBuffers to display it.
+func (w *Window) ShowLoading(progress float64, status string) {
+	if status != loadingStatus {
+		log.Infof("Loading: %v", status)
+		loadingStatus = status
+	}
+	if progress < 0 {
+		progress = 0
+	} else if progress > 1 {
+		progress = 1
+	}
+
+	width, height := w.Width, w.Height
+	if !w.external {
+		width, height = w.window.GetFramebufferSize()
+	}
+	InvalidatePipeline()
+	gl.BindFramebuffer(gl.FRAMEBUFFER, defaultFramebuffer)
+	gl.Viewport(0, 0, int32(width), int32(height))
+	gl.ClearColor(0.05, 0.05, 0.05, 1)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	// The bar is drawn with scissored clears, so no shaders are required.
+	barW, barH := width/2, int(float32(height/40+2)*accessibility.UIScale)
+	x, y := (width-barW)/2, (height-barH)/2
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(int32(x), int32(y), int32(barW), int32(barH))
+	gl.ClearColor(0.25, 0.25, 0.25, 1)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	if fill := int(float64(barW) * progress); fill > 0 {
+		gl.Scissor(int32(x), int32(y), int32(fill), int32(barH))
+		gl.ClearColor(0.35, 0.7, 0.3, 1)
+		gl.Clear(gl.COLOR_BUFFER_BIT)
+	}
+	gl.Disable(gl.SCISSOR_TEST)
+}
+
+// HideLoading ends the loading screen. The next frame draws over it.
+func (w *Window) HideLoading() {
+	loadingStatus = ""
+}
@@ -0,0 +1,45 @@
+//go:build !openvoxel_fake
+
+package render
+
+import "fmt"
+
+// loadingElementID is the id of the loading screen element. Pages can include
+// it, so it is visible while the wasm module downloads.
+const loadingElementID = "loading"
+
+// ShowLoading updates the loading screen with progress in the range [0, 1]
+// and the status text. The loading screen is a DOM element over the canvas;
+// it is created if the page does not provide one.
+func (w *Window) ShowLoading(progress float64, status string) {
+	if progress < 0 {
+		progress = 0
+	} else if progress > 1 {
+		progress = 1
+	}
+	el := document.Call("getElementById", loadingElementID)
+	if el.IsNull() {
+		el = document.Call("createElement", "div")
+		el.Set("id", loadingElementID)
+		el.Set("innerHTML", `<p class="status"></p><progress max="1"></progress>`)
+		style := el.Get("style")
+		style.Set("cssText", "position:fixed;inset:0;display:flex;flex-direction:column;"+
+			"align-items:center;justify-content:center;background:#0d0d0d;color:#ccc;"+
+			"font-family:sans-serif")
+		document.Get("body").Call("appendChild", el)
+	}
+	el.Get("style").Set("fontSize", fmt.Sprintf("%gem", accessibility.UIScale))
+	if bar := el.Call("querySelector", "progress"); !bar.IsNull() {
+		bar.Set("value", progress)
+	}
+	if text := el.Call("querySelector", ".status"); !text.IsNull() {
+		text.Set("textContent", fmt.Sprintf("%s (%.0f%%)", status, progress*100))
+	}
+}
+
+// HideLoading removes the loading screen.
+func (w *Window) HideLoading() {
+	if el := document.Call("getElementById", loadingElementID); !el.IsNull() {
+		el.Call("remove")
+	}
+}
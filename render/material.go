@@ -0,0 +1,130 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// VertexSizeMapped is the number of floats per vertex used by
+// Scene.AddMappedVertices.
+const VertexSizeMapped = 14
+
+// VertexSizeTinted is the number of floats per vertex used by
+// Scene.AddTintedVertices.
+const VertexSizeTinted = 11
+
+// Texture units used by Material.Bind.
+const (
+	AlbedoUnit = 0
+	NormalUnit = 1
+	HeightUnit = 2
+)
+
+// Material groups the textures used to shade a surface. The normal and height
+// maps are optional, and are usually provided by texture packs with detail
+// maps.
+type Material struct {
+	Albedo *Texture
+	// Normal is a tangent space normal map.
+	Normal *Texture
+	// Height is a height map in the red channel, used for parallax mapping.
+	Height *Texture
+	// ParallaxScale is the depth, in texture coordinates, of the height map.
+	ParallaxScale float32
+}
+
+// Bind makes the material textures available to the shader and sets the
+// uniforms declared by MaterialFragmentGLSL.
+func (m *Material) Bind(shader *Shader) {
+	if m.Albedo != nil {
+		m.Albedo.Bind(AlbedoUnit)
+	}
+	shader.UniformInts("materialAlbedo", AlbedoUnit)
+	shader.UniformInts("materialNormal", NormalUnit)
+	shader.UniformInts("materialHeight", HeightUnit)
+
+	var hasNormal, hasHeight int32
+	if m.Normal != nil {
+		m.Normal.Bind(NormalUnit)
+		hasNormal = 1
+	}
+	if m.Height != nil && m.ParallaxScale > 0 {
+		m.Height.Bind(HeightUnit)
+		hasHeight = 1
+	}
+	shader.UniformInts("materialHasNormal", hasNormal)
+	shader.UniformInts("materialHasHeight", hasHeight)
+	shader.UniformFloats("materialParallax", m.ParallaxScale)
+}
+
+// MaterialVertexGLSL declares the vertex attributes written by
+// AddMappedVertices and a function that outputs the tangent space to the
+// fragment shader. It must be included after the #version statement.
+const MaterialVertexGLSL = `
+layout (location = 3) in vec3 aNormal;
+layout (location = 4) in vec3 aTangent;
+out mat3 TBN;
+out vec3 TangentViewDir;
+
+void tangentSpace(mat4 model, vec3 worldPos, vec3 viewPos) {
+    vec3 n = normalize(mat3(model) * aNormal);
+    vec3 t = normalize(mat3(model) * aTangent);
+    t = normalize(t - dot(t, n) * n);
+    TBN = mat3(t, cross(n, t), n);
+    TangentViewDir = transpose(TBN) * (viewPos - worldPos);
+}
+`
+
+// MaterialFragmentGLSL declares the material samplers and the functions to
+// apply parallax and normal mapping. It must be included after the #version
+// and precision statements.
+const MaterialFragmentGLSL = `
+uniform sampler2D materialAlbedo;
+uniform sampler2D materialNormal;
+uniform sampler2D materialHeight;
+uniform bool materialHasNormal;
+uniform bool materialHasHeight;
+uniform float materialParallax;
+in mat3 TBN;
+in vec3 TangentViewDir;
+
+vec2 parallaxUV(vec2 uv) {
+    if (!materialHasHeight) {
+        return uv;
+    }
+    vec3 v = normalize(TangentViewDir);
+    const float layers = 16.0;
+    vec2 step = v.xy / v.z * materialParallax / layers;
+    float depth = 0.0;
+    float h = 1.0 - texture(materialHeight, uv).r;
+    for (int i = 0; i < 16 && depth < h; i++) {
+        uv -= step;
+        depth += 1.0 / layers;
+        h = 1.0 - texture(materialHeight, uv).r;
+    }
+    return uv;
+}
+
+vec3 surfaceNormal(vec2 uv) {
+    if (!materialHasNormal) {
+        return normalize(TBN[2]);
+    }
+    vec3 n = texture(materialNormal, uv).rgb * 2.0 - 1.0;
+    return normalize(TBN * n);
+}
+`
+
+// Tangent returns the tangent of the triangle with the provided positions and
+// texture coordinates, pointing along the direction where u increases.
+func Tangent(p0, p1, p2 glm.Vec3, uv0, uv1, uv2 glm.Vec2) glm.Vec3 {
+	e1, e2 := p1.Sub(p0), p2.Sub(p0)
+	d1, d2 := uv1.Sub(uv0), uv2.Sub(uv0)
+	det := d1.X()*d2.Y() - d2.X()*d1.Y()
+	if det == 0 {
+		return glm.Vec3{1, 0, 0}
+	}
+	t := e1.Mul(d2.Y()).Sub(e2.Mul(d1.Y())).Mul(1 / det)
+	if t.Len() == 0 {
+		return glm.Vec3{1, 0, 0}
+	}
+	return t.Normalize()
+}
@@ -0,0 +1,156 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Node is an element of the scene graph. Its transformation is relative to the
+// parent node, so meshes, lights and particle emitters attached to a bone or to
+// another entity follow it, like a lantern hanging on a cart.
+//
+// World transformations are computed lazily: changing a node only marks it
+// and its descendants as dirty, and the matrices are updated on the next
+// World call.
+type Node struct {
+	Name string
+
+	// Draw, if set, is called by DrawTree with the node world transformation.
+	Draw func(model glm.Mat4)
+	// Light, if set, follows the node position.
+	Light *Light
+
+	position glm.Vec3
+	rotation glm.Quat
+	scale    glm.Vec3
+
+	parent   *Node
+	children []*Node
+
+	local, world glm.Mat4
+	dirty        bool
+}
+
+// NewNode creates a node with the identity transformation.
+func NewNode(name string) *Node {
+	return &Node{
+		Name:     name,
+		rotation: glm.QuatIdent(),
+		scale:    glm.Vec3{1, 1, 1},
+		local:    glm.Ident4(),
+		world:    glm.Ident4(),
+	}
+}
+
+// Parent returns the parent node, or nil for a root node.
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Children returns the attached nodes. The slice must not be modified.
+func (n *Node) Children() []*Node {
+	return n.children
+}
+
+// AddChild attaches the child to this node, detaching it from its previous
+// parent.
+func (n *Node) AddChild(child *Node) {
+	child.Detach()
+	child.parent = n
+	n.children = append(n.children, child)
+	child.invalidate()
+}
+
+// Detach removes the node from its parent, turning it into a root node.
+func (n *Node) Detach() {
+	p := n.parent
+	if p == nil {
+		return
+	}
+	for i, c := range p.children {
+		if c == n {
+			p.children = append(p.children[:i], p.children[i+1:]...)
+			break
+		}
+	}
+	n.parent = nil
+	n.invalidate()
+}
+
+// SetPosition changes the node position relative to its parent.
+func (n *Node) SetPosition(p glm.Vec3) {
+	n.position = p
+	n.invalidate()
+}
+
+// SetRotation changes the node rotation relative to its parent.
+func (n *Node) SetRotation(q glm.Quat) {
+	n.rotation = q
+	n.invalidate()
+}
+
+// SetScale changes the node scale relative to its parent.
+func (n *Node) SetScale(s glm.Vec3) {
+	n.scale = s
+	n.invalidate()
+}
+
+// Position returns the node position relative to its parent.
+func (n *Node) Position() glm.Vec3 {
+	return n.position
+}
+
+// invalidate marks the node and its descendants as dirty. Subtrees already
+// dirty are skipped, as all their descendants are dirty too.
+func (n *Node) invalidate() {
+	if n.dirty {
+		return
+	}
+	n.dirty = true
+	for _, c := range n.children {
+		c.invalidate()
+	}
+}
+
+// World returns the transformation from the node space to world space.
+func (n *Node) World() glm.Mat4 {
+	if !n.dirty {
+		return n.world
+	}
+	n.local = glm.Translate3D(n.position.X(), n.position.Y(), n.position.Z()).
+		Mul4(n.rotation.Mat4()).
+		Mul4(glm.Scale3D(n.scale.X(), n.scale.Y(), n.scale.Z()))
+	if n.parent != nil {
+		n.world = n.parent.World().Mul4(n.local)
+	} else {
+		n.world = n.local
+	}
+	n.dirty = false
+	if n.Light != nil {
+		n.Light.Position = n.world.Col(3).Vec3()
+		n.Light.Direction = n.world.Mul4x1(glm.Vec4{0, 0, -1, 0}).Vec3().Normalize()
+	}
+	return n.world
+}
+
+// Walk calls fn for the node and all its descendants, parents first. If fn
+// returns false, the children of that node are skipped.
+func (n *Node) Walk(fn func(*Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.children {
+		c.Walk(fn)
+	}
+}
+
+// DrawTree updates the world transformations of the tree and calls the Draw
+// function of each node.
+func (n *Node) DrawTree() {
+	n.Walk(func(c *Node) bool {
+		world := c.World()
+		if c.Draw != nil {
+			c.Draw(world)
+		}
+		return true
+	})
+}
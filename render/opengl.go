@@ -1,30 +1,42 @@
-//go:build !js
+//go:build !js && !openvoxel_fake
 
 package render
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
 	"math"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 	"unsafe"
 
-	_ "image/jpeg"
-	_ "image/png"
-
-	"github.com/disintegration/imaging"
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/go-gl/glfw/v3.3/glfw"
 	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
 	"github.com/ronoaldo/openvoxel/log"
-	"github.com/ronoaldo/openvoxel/transform"
 )
 
+// deferredSupported indicates that the DeferredRenderer can be used.
+const deferredSupported = true
+
+// hasGL returns true if the current context supports at least the OpenGL
+// version major.minor. Newer entry points are loaded by gl.Init as
+// extensions, and must only be called when the version allows.
+func hasGL(major, minor int32) bool {
+	var ma, mi int32
+	gl.GetIntegerv(gl.MAJOR_VERSION, &ma)
+	gl.GetIntegerv(gl.MINOR_VERSION, &mi)
+	return ma > major || ma == major && mi >= minor
+}
+
+// glslVersion is the header of the builtin shaders for this backend.
+const glslVersion = "#version 330 core\n"
+
 // f is a syntax suggar to cast any number to float32
 func f[X int | int32 | int64 | uint | uint32 | uint64 | float64](i X) float32 {
 	return float32(i)
@@ -49,22 +61,10 @@ func Version() string {
 
 // Time returns the time in miliseconds since the window was initialized.
 func Time() float64 {
-	return glfw.GetTime()
-}
-
-type Camera struct {
-	pos   glm.Vec3
-	front glm.Vec3
-	up    glm.Vec3
-}
-
-func NewCamera() (c *Camera) {
-	c = &Camera{
-		pos:   glm.Vec3{-20, 4, 3},
-		front: glm.Vec3{0, 0, -1},
-		up:    glm.Vec3{0, 1, 0},
+	if !attachedAt.IsZero() {
+		return time.Since(attachedAt).Seconds()
 	}
-	return
+	return glfw.GetTime()
 }
 
 // Window handles the basic GUI and Input event handling.
@@ -83,6 +83,10 @@ type Window struct {
 	Width  int
 	Height int
 
+	// region is the part of the window drawn by BindDefaultFramebuffer,
+	// set with SetViewport. Empty is the whole window.
+	region image.Rectangle
+
 	// Keyboard helpers
 	pressedKeys map[glfw.Key]struct{}
 	deltaTime   float64
@@ -93,6 +97,20 @@ type Window struct {
 	lastX, lastY float64
 	yaw, pitch   float64
 	sensitivity  float64
+
+	// noCameraControls disables the built-in camera movement.
+	noCameraControls bool
+	// textInput routes the keyboard to text fields instead of the game.
+	textInput bool
+
+	// external is set for windows created with AttachExternalContext, which
+	// have no GLFW window. closeRequested is their ShouldClose state.
+	external       bool
+	closeRequested bool
+
+	// Visibility helpers
+	visible            bool
+	onVisibilityChange func(visible bool)
 }
 
 // NewWindow initializes the program window and OpenGL backend.
@@ -126,13 +144,18 @@ func NewWindow(width, height int, title string) (*Window, error) {
 	// Register GLFW callbacks
 	w.window.SetFramebufferSizeCallback(w.onWindowGeometryChanged)
 	w.window.SetKeyCallback(w.onKeyPressed)
+	w.window.SetCharCallback(w.onChar)
+	w.window.SetDropCallback(w.onDrop)
 	w.window.SetCursorPosCallback(w.onCursorPosChange)
+	w.window.SetMouseButtonCallback(w.onMouseButton)
+	w.window.SetIconifyCallback(w.onIconify)
 
 	// Initialize OpenGL
 	gl.Init()
 	w.scene = NewScene()
 	w.pressedKeys = make(map[glfw.Key]struct{})
 	w.sensitivity = 0.05
+	w.visible = true
 
 	return w, nil
 }
@@ -142,24 +165,71 @@ func NewWindow(width, height int, title string) (*Window, error) {
 // closed is flipped to true, then callers must call Close() method to ensure
 // resources are properly freed.
 func (w *Window) ShouldClose() bool {
+	if w.external {
+		return w.closeRequested
+	}
 	return w.window.ShouldClose()
 }
 
 // Close frees any used resources and close the underlying GLFW window.
+//
+// Resources created by the caller, such as Shader and Texture objects, must be
+// deleted before calling Close. Any GPU objects still alive are reported as
+// leaks in the logs.
 func (w *Window) Close() {
-	glfw.Terminate()
+	w.scene.Delete()
+	reportLeaks()
+	if !w.external {
+		glfw.Terminate()
+	}
 }
 
 func (w *Window) onWindowGeometryChanged(wd *glfw.Window, width, height int) {
 	w.Width = width
 	w.Height = height
 	gl.Viewport(0, 0, int32(width), int32(height))
+	event.Publish(event.Default, event.WindowResized{Width: width, Height: height})
+}
+
+func (w *Window) onIconify(wd *glfw.Window, iconified bool) {
+	log.Infof("Window iconified: %v", iconified)
+	w.visible = !iconified
+	if w.onVisibilityChange != nil {
+		w.onVisibilityChange(w.visible)
+	}
+}
+
+// Visible returns false when the window is minimized.
+func (w *Window) Visible() bool {
+	return w.visible
+}
+
+// SetVisibilityCallback registers a function to be called when the window is
+// minimized or restored.
+func (w *Window) SetVisibilityCallback(cb func(visible bool)) {
+	w.onVisibilityChange = cb
 }
 
 func (w *Window) onKeyPressed(wd *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	log.Infof("Key event received: key: %v, scancode: %v, action: %v, mods: %v", key, scancode, action, mods)
+	event.Publish(event.Default, event.Key{
+		Key:      int(key),
+		Scancode: scancode,
+		Action:   event.KeyAction(action),
+		Mods:     int(mods),
+		Text:     w.textInput,
+	})
+	if w.textInput {
+		// Paste, as the browser does in the web backend.
+		if mods&(glfw.ModControl|glfw.ModSuper) != 0 && key == glfw.KeyV && action == glfw.Press && !w.external {
+			if text := stripControl(glfw.GetClipboardString()); text != "" {
+				event.Publish(event.Default, event.Text{Text: text})
+			}
+		}
+		return
+	}
 
-	if key == glfw.KeyEscape && action == glfw.Press {
+	if key == glfw.KeyEscape && action == glfw.Press && !w.external {
 		log.Infof("ESC key pressed. Exiting...")
 		w.window.SetShouldClose(true)
 	}
@@ -189,6 +259,9 @@ func (w *Window) onKeyPressed(wd *glfw.Window, key glfw.Key, scancode int, actio
 		delete(w.pressedKeys, key)
 	}
 
+	if w.noCameraControls {
+		return
+	}
 	cam := w.scene.cam
 	cameraSpeed := f(10 * w.deltaTime)
 
@@ -215,6 +288,14 @@ func (w *Window) onKeyPressed(wd *glfw.Window, key glfw.Key, scancode int, actio
 	}
 }
 
+func (w *Window) onMouseButton(wd *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+	event.Publish(event.Default, event.MouseButton{
+		Button: int(button),
+		Action: event.KeyAction(action),
+		Mods:   int(mods),
+	})
+}
+
 func (w *Window) onCursorPosChange(wd *glfw.Window, xpos, ypos float64) {
 	if w.firstMouse {
 		w.lastX = xpos
@@ -226,6 +307,9 @@ func (w *Window) onCursorPosChange(wd *glfw.Window, xpos, ypos float64) {
 	yoffset := w.lastY - ypos
 	w.lastX = xpos
 	w.lastY = ypos
+	if w.noCameraControls {
+		return
+	}
 
 	xoffset *= w.sensitivity
 	yoffset *= w.sensitivity
@@ -251,18 +335,97 @@ func (w *Window) onCursorPosChange(wd *glfw.Window, xpos, ypos float64) {
 	w.scene.cam.front = direction.Normalize()
 }
 
+// SetCameraControls enables or disables the built-in camera movement with the
+// keyboard and the mouse, so another controller, such as a photo mode, can move
+// the camera. The controls are enabled by default.
+func (w *Window) SetCameraControls(enabled bool) {
+	w.noCameraControls = !enabled
+}
+
+// StartTextInput enables the text input mode: typed text is published as
+// event.Text, and key events are flagged with event.Key.Text and skip the
+// built-in shortcuts and camera movement. Input method compositions are
+// handled by the operating system, as GLFW only reports the resulting text, so
+// no event.TextComposition is published by this backend.
+func (w *Window) StartTextInput() {
+	w.textInput = true
+	// Keys held when the mode starts are never seen released by the camera
+	// controls.
+	w.pressedKeys = make(map[glfw.Key]struct{})
+}
+
+// StopTextInput returns the keyboard to the game controls.
+func (w *Window) StopTextInput() {
+	w.textInput = false
+}
+
+// TextInputActive returns true while the text input mode is enabled.
+func (w *Window) TextInputActive() bool {
+	return w.textInput
+}
+
+// stripControl drops line breaks and tabs, which are only reported as key
+// events in the text input mode.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func (w *Window) onChar(wd *glfw.Window, char rune) {
+	if w.textInput {
+		event.Publish(event.Default, event.Text{Text: string(char)})
+	}
+}
+
+func (w *Window) onDrop(wd *glfw.Window, names []string) {
+	var drop event.FileDrop
+	for _, name := range names {
+		if fi, err := os.Stat(name); err != nil || fi.IsDir() || fi.Size() > MaxDropSize {
+			log.Warnf("Ignoring dropped file %v: not a regular file of up to %d bytes", name, MaxDropSize)
+			continue
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			log.Warnf("Error reading dropped file: %v", err)
+			continue
+		}
+		drop.Files = append(drop.Files, event.DroppedFile{Name: filepath.Base(name), Data: data})
+	}
+	if len(drop.Files) > 0 {
+		event.Publish(event.Default, drop)
+	}
+}
+
 // PoolEvents listen to any window/input events to be passed to the input callbacks.
 func (w *Window) PollEvents() {
-	glfw.PollEvents()
+	if !w.external {
+		glfw.PollEvents()
+	}
 
 	currentFrame := Time()
 	w.deltaTime = currentFrame - w.lastFrame
 	w.lastFrame = currentFrame
 }
 
+// WaitEvents blocks until a new window/input event is received, then passes it
+// to the input callbacks. It can be used instead of PollEvents to avoid busy
+// waiting when nothing is being drawn.
+func (w *Window) WaitEvents() {
+	if !w.external {
+		glfw.WaitEvents()
+	}
+}
+
 // SwapBuffers will flip the drawing buffer to the visible buffer on the display.
 func (w *Window) SwapBuffers() {
-	w.window.SwapBuffers()
+	endFrame()
+	if !w.external {
+		w.window.SwapBuffers()
+	}
 }
 
 // Scene returns the Scene Graph used to draw on screen.
@@ -282,17 +445,19 @@ type Shader struct {
 	program     *uint32
 }
 
-// VertexShader appends the provider shader file to the pipeline. This method
-// returns the Shader reference to allow for chaining.
+// VertexShader appends the provider shader file to the pipeline, replacing the
+// HooksInclude lines. This method returns the Shader reference to allow for
+// chaining.
 func (s *Shader) VertexShader(src string) *Shader {
-	s.shaderFiles = append(s.shaderFiles, shaderSource{src, gl.VERTEX_SHADER})
+	s.shaderFiles = append(s.shaderFiles, shaderSource{preprocessGLSL(src), gl.VERTEX_SHADER})
 	return s
 }
 
-// FragmentShader appends the provided shader file to the pipeline. This method
-// returns the Shader reference to allow for chaining.
+// FragmentShader appends the provided shader file to the pipeline, replacing
+// the HooksInclude lines. This method returns the Shader reference to allow
+// for chaining.
 func (s *Shader) FragmentShader(src string) *Shader {
-	s.shaderFiles = append(s.shaderFiles, shaderSource{src, gl.FRAGMENT_SHADER})
+	s.shaderFiles = append(s.shaderFiles, shaderSource{preprocessGLSL(src), gl.FRAGMENT_SHADER})
 	return s
 }
 
@@ -350,30 +515,68 @@ func (s *Shader) UniformTransformation(name string, model glm.Mat4) error {
 	return nil
 }
 
+// UniformBlock binds the uniform block with the given name to the uniform
+// buffer binding point. Returns an error if the shader was not linked.
+func (s *Shader) UniformBlock(name string, binding uint32) error {
+	if s.program == nil {
+		return ErrShaderNotLinked
+	}
+	index := gl.GetUniformBlockIndex(*s.program, gl.Str(name+"\x00"))
+	if index == gl.INVALID_INDEX {
+		return fmt.Errorf("shader: uniform block %q not found", name)
+	}
+	gl.UniformBlockBinding(*s.program, index, binding)
+	return nil
+}
+
 // Link creates an OpenGL shader program linking all previously compiled
 // shaders. It reports an error if no shaders where compiled, or if there were
 // an error linking them.
 func (s *Shader) Link() error {
+	key := s.cacheKey()
+	if p, ok := loadProgramBinary(key); ok {
+		s.program = new(uint32)
+		*s.program = p
+		trackAlloc(resProgram, 1)
+		return nil
+	}
+
 	shaders := []uint32{}
 	for _, file := range s.shaderFiles {
 		shaderId, err := s.compileShader(file.src, file.shaderType)
 		if err != nil {
+			for _, shader := range shaders {
+				gl.DeleteShader(shader)
+			}
 			return err
 		}
 
 		shaders = append(shaders, shaderId)
 	}
 
-	p, err := s.linkProgram(shaders...)
+	p, err := s.linkProgram(key != "", shaders...)
 	if err != nil {
 		return err
 	}
+	saveProgramBinary(key, p)
 
 	s.program = new(uint32)
 	*s.program = p
+	trackAlloc(resProgram, 1)
 	return nil
 }
 
+// Delete releases the linked shader program. The shader must be linked again
+// before it can be used.
+func (s *Shader) Delete() {
+	if s.program == nil {
+		return
+	}
+	gl.DeleteProgram(*s.program)
+	trackFree(resProgram, 1)
+	s.program = nil
+}
+
 // Use attempt to use the linked program by calling gl.UseProgram.  It will
 // panic if no shaders were compiled and linked previously.
 func (s *Shader) Use() {
@@ -383,6 +586,19 @@ func (s *Shader) Use() {
 	gl.UseProgram(*s.program)
 }
 
+// shaderStage returns the stage of the OpenGL shader type.
+func shaderStage(t uint32) ShaderStage {
+	switch t {
+	case gl.VERTEX_SHADER:
+		return StageVertex
+	case gl.FRAGMENT_SHADER:
+		return StageFragment
+	case gl.COMPUTE_SHADER:
+		return StageCompute
+	}
+	return StageProgram
+}
+
 // compileShader takes a GLSL shader source string and type and compiles it.
 // It returns the shader pointer or an error if the compilation failed.
 func (s *Shader) compileShader(shaderSource string, shaderType uint32) (uint32, error) {
@@ -402,7 +618,8 @@ func (s *Shader) compileShader(shaderSource string, shaderType uint32) (uint32,
 		gl.GetShaderiv(shader, gl.INFO_LOG_LENGTH, &logLength)
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
-		return 0, errors.New("Failed to compile shader: " + log)
+		gl.DeleteShader(shader)
+		return 0, newShaderError(shaderStage(shaderType), log, shaderSource)
 	}
 
 	log.Infof("Shader compiled (status=%v)", status)
@@ -410,13 +627,16 @@ func (s *Shader) compileShader(shaderSource string, shaderType uint32) (uint32,
 }
 
 // linkProgram takes an array of compiled shaders and link them into a usable program.
-func (s *Shader) linkProgram(shaders ...uint32) (uint32, error) {
+func (s *Shader) linkProgram(retrievable bool, shaders ...uint32) (uint32, error) {
 	shaderProgram := gl.CreateProgram()
 
 	log.Infof("Linking shaders into program ...")
 	for _, shader := range shaders {
 		gl.AttachShader(shaderProgram, shader)
 	}
+	if retrievable {
+		gl.ProgramParameteri(shaderProgram, gl.PROGRAM_BINARY_RETRIEVABLE_HINT, gl.TRUE)
+	}
 	gl.LinkProgram(shaderProgram)
 
 	var status int32
@@ -426,7 +646,11 @@ func (s *Shader) linkProgram(shaders ...uint32) (uint32, error) {
 		gl.GetProgramiv(shaderProgram, gl.INFO_LOG_LENGTH, &logLength)
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetProgramInfoLog(shaderProgram, logLength, nil, gl.Str(log))
-		return 0, errors.New("Failed to create shader program: \n" + log)
+		gl.DeleteProgram(shaderProgram)
+		for _, shader := range shaders {
+			gl.DeleteShader(shader)
+		}
+		return 0, newShaderError(StageProgram, log, "")
 	}
 	log.Infof("Shader program linked properly (status=%v)", status)
 
@@ -449,8 +673,14 @@ type Scene struct {
 	ebo     *uint32
 	eboSize int32
 
+	// vboBytes and eboBytes are the buffer sizes, in bytes.
+	vboBytes, eboBytes int
+
 	clearColor color.Color
 	wireFrames bool
+	lit        bool
+	mapped     bool
+	tinted     bool
 
 	tex *Texture
 }
@@ -472,11 +702,35 @@ func (s *Scene) allocateBuffers() {
 		gl.GenVertexArrays(1, s.vao)
 		gl.GenBuffers(1, s.vbo)
 		gl.GenBuffers(1, s.ebo)
+		trackAlloc(resVertexArray, 1)
+		trackAlloc(resBuffer, 2)
+	}
+}
+
+// Delete releases the GPU buffers allocated by the scene. Textures added to
+// the scene are not deleted, as they may be shared with other scenes.
+func (s *Scene) Delete() {
+	if s.vao == nil {
+		return
 	}
+	gl.DeleteVertexArrays(1, s.vao)
+	gl.DeleteBuffers(1, s.vbo)
+	gl.DeleteBuffers(1, s.ebo)
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 2)
+	trackBufferBytes(&s.vboBytes, 0)
+	trackBufferBytes(&s.eboBytes, 0)
+	s.vao, s.vbo, s.ebo = nil, nil, nil
+	s.vboSize, s.eboSize = 0, 0
 }
 
 var sizeOfFloat32 = int(unsafe.Sizeof(float32(0)))
 
+// Camera returns the scene camera.
+func (s *Scene) Camera() *Camera {
+	return s.cam
+}
+
 func (s *Scene) BgColor(c color.Color) {
 	s.clearColor = c
 }
@@ -490,10 +744,12 @@ func (s *Scene) AddTriangles(vertices []float32, indices []uint32) {
 
 	gl.BindBuffer(gl.ARRAY_BUFFER, *s.vbo)
 	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*sizeOfFloat32, gl.Ptr(vertices), gl.STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*sizeOfFloat32)
 	s.vboSize += int32(len(vertices))
 
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, *s.ebo)
 	gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*sizeOfFloat32, gl.Ptr(indices), gl.STATIC_DRAW)
+	trackBufferBytes(&s.eboBytes, len(indices)*sizeOfFloat32)
 	s.eboSize += int32(len(indices))
 
 	// Configure the vertex array attributes
@@ -517,6 +773,7 @@ func (s *Scene) AddVertices(vertices []float32) {
 
 	gl.BindBuffer(gl.ARRAY_BUFFER, *s.vbo)
 	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*sizeOfFloat32, gl.Ptr(vertices), gl.STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*sizeOfFloat32)
 	s.vboSize += int32(len(vertices)) / 5
 	log.Infof("Adding vertices to scene: vboSize=%v ", s.vboSize)
 
@@ -532,12 +789,112 @@ func (s *Scene) AddVertices(vertices []float32) {
 	gl.BindVertexArray(0)
 }
 
+// AddLitVertices adds the provided vertices array to the scene, including the
+// light color of each vertex. The vertices array is expected to have 8
+// elements per vertex: the x,y,z coordinate, the texture coordinate, and the
+// r,g,b light color, passed to the shaders at location 2.
+func (s *Scene) AddLitVertices(vertices []float32) {
+	s.allocateBuffers()
+
+	gl.BindVertexArray(*s.vao)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, *s.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*sizeOfFloat32, gl.Ptr(vertices), gl.STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*sizeOfFloat32)
+	s.vboSize += int32(len(vertices)) / 8
+	s.lit = true
+	log.Infof("Adding lit vertices to scene: vboSize=%v ", s.vboSize)
+
+	// [0] => positions size=3, stride=8*float, offset=0
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 8*4, nil)
+	gl.EnableVertexAttribArray(0)
+	// [1] => text coord size=2, stride=8*float, offset=3*float
+	gl.VertexAttribPointerWithOffset(1, 2, gl.FLOAT, false, 8*4, 3*4)
+	gl.EnableVertexAttribArray(1)
+	// [2] => light color size=3, stride=8*float, offset=5*float
+	gl.VertexAttribPointerWithOffset(2, 3, gl.FLOAT, false, 8*4, 5*4)
+	gl.EnableVertexAttribArray(2)
+
+	gl.BindVertexArray(0)
+}
+
+// AddMappedVertices adds the provided vertices array to the scene, including
+// the light color and the tangent space used for normal mapping. The vertices
+// array is expected to have VertexSizeMapped elements per vertex: the x,y,z
+// coordinate, the texture coordinate, the r,g,b light color, the normal and
+// the tangent, passed to the shaders at locations 0 to 4.
+func (s *Scene) AddMappedVertices(vertices []float32) {
+	s.allocateBuffers()
+
+	gl.BindVertexArray(*s.vao)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, *s.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*sizeOfFloat32, gl.Ptr(vertices), gl.STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*sizeOfFloat32)
+	s.vboSize += int32(len(vertices)) / VertexSizeMapped
+	s.lit, s.mapped = true, true
+	log.Infof("Adding mapped vertices to scene: vboSize=%v ", s.vboSize)
+
+	stride := int32(VertexSizeMapped * 4)
+	// [0] => positions size=3, offset=0
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, stride, nil)
+	gl.EnableVertexAttribArray(0)
+	// [1] => text coord size=2, offset=3*float
+	gl.VertexAttribPointerWithOffset(1, 2, gl.FLOAT, false, stride, 3*4)
+	gl.EnableVertexAttribArray(1)
+	// [2] => light color size=3, offset=5*float
+	gl.VertexAttribPointerWithOffset(2, 3, gl.FLOAT, false, stride, 5*4)
+	gl.EnableVertexAttribArray(2)
+	// [3] => normal size=3, offset=8*float
+	gl.VertexAttribPointerWithOffset(3, 3, gl.FLOAT, false, stride, 8*4)
+	gl.EnableVertexAttribArray(3)
+	// [4] => tangent size=3, offset=11*float
+	gl.VertexAttribPointerWithOffset(4, 3, gl.FLOAT, false, stride, 11*4)
+	gl.EnableVertexAttribArray(4)
+
+	gl.BindVertexArray(0)
+}
+
+// AddTintedVertices adds the provided vertices array to the scene, including
+// the light color and the biome tint of each vertex. The vertices array is
+// expected to have VertexSizeTinted elements per vertex: the x,y,z coordinate,
+// the texture coordinate, the r,g,b light color, passed at location 2, and the
+// r,g,b tint color, passed at location 5.
+func (s *Scene) AddTintedVertices(vertices []float32) {
+	s.allocateBuffers()
+
+	gl.BindVertexArray(*s.vao)
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, *s.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*sizeOfFloat32, gl.Ptr(vertices), gl.STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*sizeOfFloat32)
+	s.vboSize += int32(len(vertices)) / VertexSizeTinted
+	s.lit, s.tinted = true, true
+	log.Infof("Adding tinted vertices to scene: vboSize=%v ", s.vboSize)
+
+	stride := int32(VertexSizeTinted * 4)
+	// [0] => positions size=3, offset=0
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, stride, nil)
+	gl.EnableVertexAttribArray(0)
+	// [1] => text coord size=2, offset=3*float
+	gl.VertexAttribPointerWithOffset(1, 2, gl.FLOAT, false, stride, 3*4)
+	gl.EnableVertexAttribArray(1)
+	// [2] => light color size=3, offset=5*float
+	gl.VertexAttribPointerWithOffset(2, 3, gl.FLOAT, false, stride, 5*4)
+	gl.EnableVertexAttribArray(2)
+	// [5] => tint color size=3, offset=8*float
+	gl.VertexAttribPointerWithOffset(5, 3, gl.FLOAT, false, stride, 8*4)
+	gl.EnableVertexAttribArray(5)
+
+	gl.BindVertexArray(0)
+}
+
 func (s *Scene) AddTexture(tex *Texture) {
 	s.tex = tex
 }
 
 func (s *Scene) Clear() {
-	gl.Enable(gl.DEPTH_TEST)
+	prepareClear()
 	if s.clearColor == nil {
 		s.clearColor = BgColor
 	}
@@ -552,49 +909,664 @@ func (s *Scene) Clear() {
 // If the provided Shader program is not nil, it will be registered to be used
 // before rendering anything on screen.
 func (s *Scene) Draw(shader *Shader) {
+	s.DrawState(nil, shader, DefaultPipeline)
+}
+
+// DrawState renders the scene like Draw, using the provided pipeline state
+// and the camera matrices of the frame. A nil frame uses the scene camera.
+func (s *Scene) DrawState(f *Frame, shader *Shader, state PipelineState) {
 	s.allocateBuffers()
 
 	// TODO: use a default minimal shader program if no other shaders where specified
 	// since OpenGL requires a fragment and a vertex shader at a minimum.
 	if shader != nil {
 		// Camera position changing
-		view := transform.LookAt(
-			s.cam.pos, s.cam.pos.Add(s.cam.front), s.cam.up,
-		)
-		shader.UniformTransformation("view", view)
+		s.setCamera(shader, f)
 	}
 
 	if s.tex != nil {
 		gl.ActiveTexture(gl.TEXTURE0)
 		gl.BindTexture(gl.TEXTURE_2D, s.tex.tex)
+		countTextureBind()
 	}
 
-	if s.wireFrames {
-		gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
-	} else {
-		gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+	state.Wireframe = state.Wireframe || s.wireFrames
+	state.Apply()
+
+	if !s.lit {
+		// Vertices without light color are fully lit
+		gl.VertexAttrib3f(2, 1, 1, 1)
+	}
+	if !s.mapped {
+		// Vertices without tangent space face up, with a flat normal map
+		gl.VertexAttrib3f(3, 0, 1, 0)
+		gl.VertexAttrib3f(4, 1, 0, 0)
+	}
+	if !s.tinted {
+		gl.VertexAttrib3f(5, 1, 1, 1)
 	}
 
 	gl.BindVertexArray(*s.vao)
 	if s.eboSize > 0 {
 		gl.DrawElements(gl.TRIANGLES, s.eboSize, gl.UNSIGNED_INT, nil)
+		countDraw(int(s.eboSize))
 	} else {
 		gl.DrawArrays(gl.TRIANGLES, 0, s.vboSize)
+		countDraw(int(s.vboSize))
 	}
 	gl.BindVertexArray(0)
 }
 
+// UniformBuffer holds data shared by shader programs through uniform blocks.
+type UniformBuffer struct {
+	ubo  uint32
+	size int
+}
+
+// NewUniformBuffer allocates an uniform buffer with space for size floats.
+func NewUniformBuffer(size int) *UniformBuffer {
+	b := &UniformBuffer{}
+	gl.GenBuffers(1, &b.ubo)
+	trackAlloc(resBuffer, 1)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, b.ubo)
+	gl.BufferData(gl.UNIFORM_BUFFER, size*sizeOfFloat32, nil, gl.DYNAMIC_DRAW)
+	trackBufferBytes(&b.size, size*sizeOfFloat32)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+	return b
+}
+
+// Update replaces the start of the buffer with the provided data.
+func (b *UniformBuffer) Update(data []float32) {
+	gl.BindBuffer(gl.UNIFORM_BUFFER, b.ubo)
+	gl.BufferSubData(gl.UNIFORM_BUFFER, 0, len(data)*sizeOfFloat32, gl.Ptr(data))
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+}
+
+// Bind attaches the buffer to the binding point used by the uniform blocks.
+func (b *UniformBuffer) Bind(binding uint32) {
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, binding, b.ubo)
+}
+
+// Delete releases the buffer from the GPU memory.
+func (b *UniformBuffer) Delete() {
+	if b.ubo == 0 {
+		return
+	}
+	gl.DeleteBuffers(1, &b.ubo)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&b.size, 0)
+	b.ubo = 0
+}
+
 type Texture struct {
 	tex    uint32
 	pixels []uint8
 }
 
+// Bind makes the texture available to the shaders at the texture unit.
+func (t *Texture) Bind(unit int) {
+	gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+	gl.BindTexture(gl.TEXTURE_2D, t.tex)
+	countTextureBind()
+}
+
+// newEmptyTexture allocates a texture without data, to be used as a render
+// target.
+func newEmptyTexture(width, height int, format TextureFormat) *Texture {
+	t := &Texture{}
+	gl.GenTextures(1, &t.tex)
+	trackAlloc(resTexture, 1)
+	gl.BindTexture(gl.TEXTURE_2D, t.tex)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+
+	var internal int32
+	var pixFormat, pixType uint32
+	switch format {
+	case FormatRGBA16F:
+		internal, pixFormat, pixType = gl.RGBA16F, gl.RGBA, gl.FLOAT
+	case FormatRGBA32F:
+		internal, pixFormat, pixType = gl.RGBA32F, gl.RGBA, gl.FLOAT
+	case FormatDepth:
+		internal, pixFormat, pixType = gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT, gl.UNSIGNED_INT
+	default:
+		internal, pixFormat, pixType = gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE
+	}
+	gl.TexImage2D(gl.TEXTURE_2D, 0, internal, int32(width), int32(height), 0, pixFormat, pixType, nil)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	return t
+}
+
+// Framebuffer is an off-screen render target, with one or more color
+// textures and a depth texture.
+type Framebuffer struct {
+	Width, Height int
+
+	fbo     uint32
+	formats []TextureFormat
+	color   []*Texture
+	depth   *Texture
+}
+
+// NewFramebuffer creates a framebuffer of the given size, with one color
+// texture for each of the provided formats and a depth texture.
+func NewFramebuffer(width, height int, formats ...TextureFormat) (*Framebuffer, error) {
+	f := &Framebuffer{formats: formats}
+	gl.GenFramebuffers(1, &f.fbo)
+	trackAlloc(resFramebuffer, 1)
+	if err := f.Resize(width, height); err != nil {
+		f.Delete()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Resize recreates the framebuffer textures with the new size.
+func (f *Framebuffer) Resize(width, height int) error {
+	f.deleteTextures()
+	f.Width, f.Height = width, height
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, f.fbo)
+	defer gl.BindFramebuffer(gl.FRAMEBUFFER, defaultFramebuffer)
+
+	drawBuffers := make([]uint32, len(f.formats))
+	for i, format := range f.formats {
+		t := newEmptyTexture(width, height, format)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+uint32(i), gl.TEXTURE_2D, t.tex, 0)
+		f.color = append(f.color, t)
+		drawBuffers[i] = gl.COLOR_ATTACHMENT0 + uint32(i)
+	}
+	if len(drawBuffers) > 0 {
+		gl.DrawBuffers(int32(len(drawBuffers)), &drawBuffers[0])
+	} else {
+		gl.DrawBuffer(gl.NONE)
+	}
+	f.depth = newEmptyTexture(width, height, FormatDepth)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, f.depth.tex, 0)
+
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		return fmt.Errorf("framebuffer: incomplete (status=0x%x)", status)
+	}
+	return nil
+}
+
+// Bind makes the framebuffer the current render target, and sets the viewport
+// to its size.
+func (f *Framebuffer) Bind() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, f.fbo)
+	gl.Viewport(0, 0, int32(f.Width), int32(f.Height))
+}
+
+// Clear resets the color textures to transparent black and the depth texture
+// to the far plane. The framebuffer must be bound.
+func (f *Framebuffer) Clear() {
+	prepareClear()
+	gl.ClearColor(0, 0, 0, 0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+}
+
+// Color returns the i-th color texture.
+func (f *Framebuffer) Color(i int) *Texture {
+	return f.color[i]
+}
+
+// ReadPixels copies the i-th color texture, which must use FormatRGBA8, into an
+// image with the first row at the top. It waits for the GPU to finish
+// rendering, so it should not be called every frame.
+func (f *Framebuffer) ReadPixels(i int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, f.fbo)
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0 + uint32(i))
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(0, 0, int32(f.Width), int32(f.Height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, defaultFramebuffer)
+	flipRows(img)
+	return img
+}
+
+// Depth returns the depth texture.
+func (f *Framebuffer) Depth() *Texture {
+	return f.depth
+}
+
+func (f *Framebuffer) deleteTextures() {
+	for _, t := range f.color {
+		t.Delete()
+	}
+	f.color = nil
+	if f.depth != nil {
+		f.depth.Delete()
+		f.depth = nil
+	}
+}
+
+// Delete releases the framebuffer and its textures.
+func (f *Framebuffer) Delete() {
+	f.deleteTextures()
+	if f.fbo != 0 {
+		gl.DeleteFramebuffers(1, &f.fbo)
+		trackFree(resFramebuffer, 1)
+		f.fbo = 0
+	}
+}
+
+// CubeMap is a cube texture that can also be used as a render target, one face
+// at a time.
+type CubeMap struct {
+	Size int
+
+	tex   uint32
+	fbo   uint32
+	depth uint32
+}
+
+// NewCubeMap allocates a cube map with square faces of the provided size.
+func NewCubeMap(size int) (*CubeMap, error) {
+	c := &CubeMap{Size: size}
+	gl.GenTextures(1, &c.tex)
+	trackAlloc(resTexture, 1)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, c.tex)
+	for face := uint32(0); face < 6; face++ {
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+face, 0, gl.RGBA8, int32(size), int32(size), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	}
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, 0)
+
+	gl.GenFramebuffers(1, &c.fbo)
+	trackAlloc(resFramebuffer, 1)
+	gl.GenRenderbuffers(1, &c.depth)
+	trackAlloc(resBuffer, 1)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, c.depth)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, int32(size), int32(size))
+	gl.BindFramebuffer(gl.FRAMEBUFFER, c.fbo)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, c.depth)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_CUBE_MAP_POSITIVE_X, c.tex, 0)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, defaultFramebuffer)
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		c.Delete()
+		return nil, fmt.Errorf("cubemap: incomplete framebuffer (status=0x%x)", status)
+	}
+	return c, nil
+}
+
+// BindFace makes the face, in the order +X, -X, +Y, -Y, +Z, -Z, the current
+// render target.
+func (c *CubeMap) BindFace(face int) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, c.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(face), c.tex, 0)
+	gl.Viewport(0, 0, int32(c.Size), int32(c.Size))
+}
+
+// Bind makes the cube map available to the shaders at the texture unit.
+func (c *CubeMap) Bind(unit int) {
+	gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, c.tex)
+	countTextureBind()
+}
+
+// Delete releases the cube map texture and render target.
+func (c *CubeMap) Delete() {
+	if c.tex != 0 {
+		gl.DeleteTextures(1, &c.tex)
+		trackFree(resTexture, 1)
+		c.tex = 0
+	}
+	if c.fbo != 0 {
+		gl.DeleteFramebuffers(1, &c.fbo)
+		trackFree(resFramebuffer, 1)
+		c.fbo = 0
+	}
+	if c.depth != 0 {
+		gl.DeleteRenderbuffers(1, &c.depth)
+		trackFree(resBuffer, 1)
+		c.depth = 0
+	}
+}
+
+// DynamicMesh is a small vertex buffer updated frequently, such as overlays
+// rebuilt every time they change. Vertices have 5 elements: the x,y,z
+// coordinate and the texture coordinate.
+type DynamicMesh struct {
+	vao   uint32
+	vbo   uint32
+	count int32
+	bytes int
+}
+
+// NewDynamicMesh allocates an empty dynamic mesh.
+func NewDynamicMesh() *DynamicMesh {
+	m := &DynamicMesh{}
+	gl.GenVertexArrays(1, &m.vao)
+	gl.GenBuffers(1, &m.vbo)
+	trackAlloc(resVertexArray, 1)
+	trackAlloc(resBuffer, 1)
+	gl.BindVertexArray(m.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo)
+	gl.VertexAttribPointer(0, 3, gl.FLOAT, false, 5*4, nil)
+	gl.EnableVertexAttribArray(0)
+	gl.VertexAttribPointerWithOffset(1, 2, gl.FLOAT, false, 5*4, 3*4)
+	gl.EnableVertexAttribArray(1)
+	gl.BindVertexArray(0)
+	return m
+}
+
+// Update replaces the mesh vertices.
+func (m *DynamicMesh) Update(vertices []float32) {
+	m.count = int32(len(vertices) / 5)
+	if m.count == 0 {
+		return
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, m.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, len(vertices)*sizeOfFloat32, gl.Ptr(vertices), gl.DYNAMIC_DRAW)
+	trackBufferBytes(&m.bytes, len(vertices)*sizeOfFloat32)
+}
+
+// Draw renders the mesh triangles with the current shader program.
+func (m *DynamicMesh) Draw() {
+	if m.count == 0 {
+		return
+	}
+	gl.BindVertexArray(m.vao)
+	gl.DrawArrays(gl.TRIANGLES, 0, m.count)
+	countDraw(int(m.count))
+	gl.BindVertexArray(0)
+}
+
+// Delete releases the mesh buffers.
+func (m *DynamicMesh) Delete() {
+	if m.vao == 0 {
+		return
+	}
+	gl.DeleteVertexArrays(1, &m.vao)
+	gl.DeleteBuffers(1, &m.vbo)
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&m.bytes, 0)
+	m.vao, m.vbo, m.count = 0, 0, 0
+}
+
+// MeshBuffer is a large vertex buffer shared by many meshes, drawn one range
+// at a time.
+type MeshBuffer struct {
+	layout   VertexLayout
+	vao, vbo uint32
+	size     int
+
+	// Buffers used by drawIndirect.
+	indirect, offsets uint32
+}
+
+// NewMeshBuffer allocates a vertex buffer with size bytes using the layout.
+func NewMeshBuffer(layout VertexLayout, size int) *MeshBuffer {
+	b := &MeshBuffer{layout: layout}
+	gl.GenVertexArrays(1, &b.vao)
+	trackAlloc(resVertexArray, 1)
+	b.reallocate(size, nil)
+	return b
+}
+
+// reallocate replaces the buffer with a new one of the provided size, copying
+// the ranges of the old buffer described by moves.
+func (b *MeshBuffer) reallocate(size int, moves []ArenaMove) {
+	var vbo uint32
+	gl.GenBuffers(1, &vbo)
+	trackAlloc(resBuffer, 1)
+	gl.BindBuffer(gl.ARRAY_BUFFER, vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, size, nil, gl.DYNAMIC_DRAW)
+	if b.vbo != 0 {
+		gl.BindBuffer(gl.COPY_READ_BUFFER, b.vbo)
+		for _, m := range moves {
+			gl.CopyBufferSubData(gl.COPY_READ_BUFFER, gl.ARRAY_BUFFER, m.From, m.To, m.Size)
+		}
+		gl.DeleteBuffers(1, &b.vbo)
+		trackFree(resBuffer, 1)
+	}
+	b.vbo = vbo
+	trackBufferBytes(&b.size, size)
+
+	gl.BindVertexArray(b.vao)
+	for _, a := range b.layout.Attribs {
+		if a.Type == AttribUint {
+			gl.VertexAttribIPointerWithOffset(a.Location, a.Size, gl.UNSIGNED_INT, int32(b.layout.Stride), uintptr(a.Offset))
+		} else {
+			gl.VertexAttribPointerWithOffset(a.Location, a.Size, gl.FLOAT, false, int32(b.layout.Stride), uintptr(a.Offset))
+		}
+		gl.EnableVertexAttribArray(a.Location)
+	}
+	gl.BindVertexArray(0)
+}
+
+// Size returns the buffer capacity, in bytes.
+func (b *MeshBuffer) Size() int {
+	return b.size
+}
+
+// Grow enlarges the buffer to size bytes, keeping its contents.
+func (b *MeshBuffer) Grow(size int) {
+	if size > b.size {
+		b.reallocate(size, []ArenaMove{{From: 0, To: 0, Size: b.size}})
+	}
+}
+
+// Relocate moves the ranges of the buffer, as returned by Arena.Compact.
+func (b *MeshBuffer) Relocate(moves []ArenaMove) {
+	b.reallocate(b.size, moves)
+}
+
+// Write copies the data into the buffer at the offset, in bytes.
+func (b *MeshBuffer) Write(offset int, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.vbo)
+	gl.BufferSubData(gl.ARRAY_BUFFER, offset, len(data), gl.Ptr(data))
+}
+
+// Bind prepares the buffer for DrawRange calls.
+func (b *MeshBuffer) Bind() {
+	gl.BindVertexArray(b.vao)
+}
+
+// DrawRange draws count vertices starting at first. The buffer must be bound.
+func (b *MeshBuffer) DrawRange(first, count int) {
+	gl.DrawArrays(gl.TRIANGLES, int32(first), int32(count))
+	countDraw(count)
+}
+
+// setChunkOffset sets the per-draw chunk offset attribute used by DrawRange.
+func (b *MeshBuffer) setChunkOffset(x, y, z float32) {
+	gl.VertexAttrib3f(ChunkOffsetLocation, x, y, z)
+}
+
+// drawIndirect draws all commands with a single glMultiDrawArraysIndirect
+// call, passing the chunk offsets as an instanced attribute selected by the
+// command BaseInstance. It returns false if OpenGL 4.3 is not available.
+func (b *MeshBuffer) drawIndirect(cmds []DrawCommand, offsets []float32) bool {
+	if !multiDrawSupported() {
+		return false
+	}
+	if len(cmds) == 0 {
+		return true
+	}
+	if b.indirect == 0 {
+		gl.GenBuffers(1, &b.indirect)
+		gl.GenBuffers(1, &b.offsets)
+		trackAlloc(resBuffer, 2)
+	}
+	gl.BindVertexArray(b.vao)
+	gl.BindBuffer(gl.ARRAY_BUFFER, b.offsets)
+	gl.BufferData(gl.ARRAY_BUFFER, len(offsets)*sizeOfFloat32, gl.Ptr(offsets), gl.STREAM_DRAW)
+	gl.VertexAttribPointerWithOffset(ChunkOffsetLocation, 3, gl.FLOAT, false, 3*4, 0)
+	gl.VertexAttribDivisor(ChunkOffsetLocation, 1)
+	gl.EnableVertexAttribArray(ChunkOffsetLocation)
+
+	gl.BindBuffer(gl.DRAW_INDIRECT_BUFFER, b.indirect)
+	gl.BufferData(gl.DRAW_INDIRECT_BUFFER, len(cmds)*16, gl.Ptr(cmds), gl.STREAM_DRAW)
+	gl.MultiDrawArraysIndirect(gl.TRIANGLES, nil, int32(len(cmds)), 0)
+	countMultiDraw(cmds)
+	gl.BindBuffer(gl.DRAW_INDIRECT_BUFFER, 0)
+
+	// Restore the constant attribute used by the per-draw path
+	gl.DisableVertexAttribArray(ChunkOffsetLocation)
+	return true
+}
+
+// multiDrawSupported returns true if glMultiDrawArraysIndirect is available.
+func multiDrawSupported() bool {
+	return Capabilities().MultiDrawIndirect
+}
+
+// Delete releases the buffer.
+func (b *MeshBuffer) Delete() {
+	if b.vao == 0 {
+		return
+	}
+	gl.DeleteVertexArrays(1, &b.vao)
+	gl.DeleteBuffers(1, &b.vbo)
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 1)
+	if b.indirect != 0 {
+		gl.DeleteBuffers(1, &b.indirect)
+		gl.DeleteBuffers(1, &b.offsets)
+		trackFree(resBuffer, 2)
+	}
+	trackBufferBytes(&b.size, 0)
+	b.vao, b.vbo, b.indirect, b.offsets = 0, 0, 0, 0
+}
+
+// clearDepth resets the depth buffer of the current render target.
+func clearDepth() {
+	prepareClear()
+	gl.Clear(gl.DEPTH_BUFFER_BIT)
+}
+
+// setClipControl switches the clip depth range to [0, 1] for the reversed
+// depth, or back to [-1, 1]. It returns false if clip control is not
+// available, which needs OpenGL 4.5.
+func setClipControl(zeroToOne bool) bool {
+	if !hasGL(4, 5) {
+		return false
+	}
+	if zeroToOne {
+		gl.ClipControl(gl.LOWER_LEFT, gl.ZERO_TO_ONE)
+	} else {
+		gl.ClipControl(gl.LOWER_LEFT, gl.NEGATIVE_ONE_TO_ONE)
+	}
+	return true
+}
+
+// setClearDepth changes the value used to clear the depth buffer.
+func setClearDepth(v float32) {
+	gl.ClearDepth(float64(v))
+}
+
+// enableCap enables or disables an OpenGL capability.
+func enableCap(c uint32, enabled bool) {
+	if enabled {
+		gl.Enable(c)
+	} else {
+		gl.Disable(c)
+	}
+}
+
+// applyPipeline issues the calls to change the state from old to p. A nil old
+// state sets everything.
+func applyPipeline(old *PipelineState, p PipelineState) {
+	if old == nil || old.Blend != p.Blend {
+		enableCap(gl.BLEND, p.Blend != BlendNone)
+		switch p.Blend {
+		case BlendAlpha:
+			gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+		case BlendAdditive:
+			gl.BlendFunc(gl.SRC_ALPHA, gl.ONE)
+		}
+	}
+	if old == nil || old.DepthTest != p.DepthTest {
+		enableCap(gl.DEPTH_TEST, p.DepthTest)
+	}
+	if old == nil || old.DepthWrite != p.DepthWrite {
+		gl.DepthMask(p.DepthWrite)
+	}
+	if old == nil || old.DepthFunc != p.DepthFunc {
+		gl.DepthFunc([...]uint32{gl.LESS, gl.LEQUAL, gl.EQUAL, gl.GREATER, gl.GEQUAL}[p.DepthFunc.effective()])
+	}
+	if old == nil || old.NoColorWrite != p.NoColorWrite {
+		gl.ColorMask(!p.NoColorWrite, !p.NoColorWrite, !p.NoColorWrite, !p.NoColorWrite)
+	}
+	if old == nil || old.Cull != p.Cull {
+		enableCap(gl.CULL_FACE, p.Cull != CullNone)
+		switch p.Cull {
+		case CullBack:
+			gl.CullFace(gl.BACK)
+		case CullFront:
+			gl.CullFace(gl.FRONT)
+		}
+	}
+	if old == nil || old.Wireframe != p.Wireframe {
+		if p.Wireframe {
+			gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
+		} else {
+			gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+		}
+	}
+	if old == nil || old.PolygonOffset != p.PolygonOffset {
+		enableCap(gl.POLYGON_OFFSET_FILL, p.PolygonOffset != 0)
+		gl.PolygonOffset(p.PolygonOffset, p.PolygonOffset)
+	}
+}
+
+// BindDefaultFramebuffer makes the window the current render target, limited
+// to the region set with SetViewport.
+func (w *Window) BindDefaultFramebuffer() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, defaultFramebuffer)
+	r := w.Viewport()
+	y := int32(w.Height - r.Max.Y)
+	gl.Viewport(int32(r.Min.X), y, int32(r.Dx()), int32(r.Dy()))
+	// Scissor the clears too, so each viewport clears only its region.
+	if r == image.Rect(0, 0, w.Width, w.Height) {
+		gl.Disable(gl.SCISSOR_TEST)
+		return
+	}
+	gl.Enable(gl.SCISSOR_TEST)
+	gl.Scissor(int32(r.Min.X), y, int32(r.Dx()), int32(r.Dy()))
+}
+
+// fullscreenVAO is an empty vertex array used to draw a fullscreen triangle,
+// with the vertices generated in the shader from gl_VertexID.
+var fullscreenVAO uint32
+
+// DrawFullscreen draws a single triangle covering the whole viewport. It is
+// used by post-processing passes together with FullscreenVertexGLSL.
+func DrawFullscreen() {
+	if fullscreenVAO == 0 {
+		gl.GenVertexArrays(1, &fullscreenVAO)
+	}
+	gl.BindVertexArray(fullscreenVAO)
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+	countDraw(3)
+	gl.BindVertexArray(0)
+}
+
+// NewTexture loads the texture from the image file at path. Errors are
+// returned as a TextureError.
 func NewTexture(path string) (t *Texture, err error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return nil, &TextureError{Path: path, Format: "unknown", Err: err}
+	}
+	t, err = NewTextureFromBytes(b)
+	var te *TextureError
+	if errors.As(err, &te) {
+		te.Path = path
 	}
-	return NewTextureFromBytes(b)
+	return t, err
 }
 
 func NewTextureFromBytes(b []byte) (t *Texture, err error) {
@@ -608,6 +1580,7 @@ func NewTextureFromBytes(b []byte) (t *Texture, err error) {
 		pixels: pixels,
 	}
 	gl.GenTextures(1, &t.tex)
+	trackAlloc(resTexture, 1)
 	gl.ActiveTexture(gl.TEXTURE0)
 	gl.BindTexture(gl.TEXTURE_2D, t.tex)
 
@@ -615,7 +1588,27 @@ func NewTextureFromBytes(b []byte) (t *Texture, err error) {
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	t.upload(w, h)
+	return t, nil
+}
 
+// Reload replaces the image of the texture with the image file in b, keeping
+// the same texture object, so the scenes and materials using it show the new
+// image. On errors, returned as a TextureError, the old image is kept.
+func (t *Texture) Reload(b []byte) error {
+	w, h, pixels, err := decodeImage(b)
+	if err != nil {
+		return err
+	}
+	t.pixels = pixels
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, t.tex)
+	t.upload(w, h)
+	return nil
+}
+
+// upload sends the pixels to the bound texture.
+func (t *Texture) upload(w, h int) {
 	gl.TexImage2D(gl.TEXTURE_2D,
 		0,
 		gl.RGBA,
@@ -624,26 +1617,16 @@ func NewTextureFromBytes(b []byte) (t *Texture, err error) {
 		0,
 		gl.RGBA,
 		gl.UNSIGNED_BYTE,
-		gl.Ptr(pixels))
+		gl.Ptr(t.pixels))
 	gl.GenerateMipmap(gl.TEXTURE_2D)
-	return t, nil
 }
 
-func decodeImage(b []byte) (w, h int, px []uint8, err error) {
-	img, ftype, err := image.Decode(bytes.NewReader(b))
-	if err != nil {
-		return 0, 0, nil, err
-	}
-	img = imaging.FlipV(img)
-	w, h = img.Bounds().Size().X, img.Bounds().Size().Y
-	log.Infof("Loaded %v image (%dx%d) from %v bytes", ftype, w, h, len(b))
-
-	// Create pixel data from PNG
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return 0, 0, nil, fmt.Errorf("unsupported stride")
+// Delete releases the texture from the GPU memory.
+func (t *Texture) Delete() {
+	if t.tex == 0 {
+		return
 	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
-
-	return rgba.Rect.Size().X, rgba.Rect.Size().Y, rgba.Pix, nil
+	gl.DeleteTextures(1, &t.tex)
+	trackFree(resTexture, 1)
+	t.tex = 0
 }
@@ -0,0 +1,109 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// particleGravity is the downwards acceleration of particles, in blocks per
+// second squared.
+const particleGravity = 20
+
+// Particle is a small camera facing quad that falls with gravity, such as the
+// debris of explosions and broken blocks.
+type Particle struct {
+	Position, Velocity glm.Vec3
+	// Size is the quad width and height, in blocks.
+	Size float32
+	// Life is the remaining time, in seconds, before the particle disappears.
+	Life float32
+	// UV is the texture region drawn, as the minimum and maximum texture
+	// coordinates, usually a random part of a block tile in the atlas.
+	UV [4]float32
+}
+
+// ParticleRenderer simulates and draws particles sharing a texture. The quads
+// are built on the CPU into a single mesh each frame.
+type ParticleRenderer struct {
+	Texture *Texture
+	// Max limits the live particles, dropping the oldest ones.
+	Max int
+
+	particles []Particle
+	vertices  []float32
+	mesh      *DynamicMesh
+}
+
+// NewParticleRenderer creates a renderer without particles.
+func NewParticleRenderer(tex *Texture) *ParticleRenderer {
+	return &ParticleRenderer{Texture: tex, Max: 1024, mesh: NewDynamicMesh()}
+}
+
+// Emit adds the particles.
+func (r *ParticleRenderer) Emit(p ...Particle) {
+	r.particles = append(r.particles, p...)
+	if over := len(r.particles) - r.Max; r.Max > 0 && over > 0 {
+		r.particles = append(r.particles[:0], r.particles[over:]...)
+	}
+}
+
+// Len returns the number of live particles.
+func (r *ParticleRenderer) Len() int {
+	return len(r.particles)
+}
+
+// Update advances the particles by dt seconds, removing the expired ones.
+// Particles do not collide with the blocks.
+func (r *ParticleRenderer) Update(dt float32) {
+	live := r.particles[:0]
+	for _, p := range r.particles {
+		p.Life -= dt
+		if p.Life <= 0 {
+			continue
+		}
+		p.Velocity[1] -= particleGravity * dt
+		p.Position = p.Position.Add(p.Velocity.Mul(dt))
+		live = append(live, p)
+	}
+	r.particles = live
+}
+
+// Draw renders the particles with the view and projection matrices. The shader
+// must use the model, view and projection uniforms and sample the texture at
+// unit 0.
+func (r *ParticleRenderer) Draw(shader *Shader, view, projection glm.Mat4) {
+	if len(r.particles) == 0 || r.Texture == nil {
+		return
+	}
+	// The inverse of the view rotation turns the quads towards the camera.
+	rot := view.Mat3().Transpose()
+	right, up := rot.Col(0), rot.Col(1)
+	r.vertices = r.vertices[:0]
+	for _, p := range r.particles {
+		rt, u := right.Mul(p.Size/2), up.Mul(p.Size/2)
+		corner := func(sx, sy, tu, tv float32) {
+			c := p.Position.Add(rt.Mul(sx)).Add(u.Mul(sy))
+			r.vertices = append(r.vertices, c[0], c[1], c[2], tu, tv)
+		}
+		u0, v0, u1, v1 := p.UV[0], p.UV[1], p.UV[2], p.UV[3]
+		corner(-1, -1, u0, v0)
+		corner(1, -1, u1, v0)
+		corner(1, 1, u1, v1)
+		corner(-1, -1, u0, v0)
+		corner(1, 1, u1, v1)
+		corner(-1, 1, u0, v1)
+	}
+	r.mesh.Update(r.vertices)
+
+	shader.Use()
+	shader.UniformTransformation("model", glm.Ident4())
+	shader.UniformTransformation("view", view)
+	shader.UniformTransformation("projection", projection)
+	r.Texture.Bind(0)
+	DefaultPipeline.Apply()
+	r.mesh.Draw()
+}
+
+// Delete releases the particle mesh. The texture is not deleted.
+func (r *ParticleRenderer) Delete() {
+	r.mesh.Delete()
+}
@@ -0,0 +1,262 @@
+package render
+
+import (
+	"fmt"
+	"image"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+// Names of the built-in render passes, in the default order.
+const (
+	PassShadow      = "shadow"
+	PassSky         = "sky"
+	PassOpaque      = "opaque"
+	PassTransparent = "transparent"
+	PassViewModel   = "view-model"
+	PassUI          = "ui"
+	PassDebug       = "debug"
+)
+
+// Frame holds the state shared by all passes while rendering a frame.
+type Frame struct {
+	Window *Window
+	// Camera is the camera of the frame. Nil uses the window scene camera.
+	Camera *Camera
+	// Eye is the camera position, and View and Projection are the camera
+	// matrices used by the world passes.
+	Eye        glm.Vec3
+	View       glm.Mat4
+	Projection glm.Mat4
+	// Alpha is the interpolation factor between the last two simulation
+	// steps.
+	Alpha float64
+
+	// Opaque is the pipeline state the opaque pass must draw with. It is
+	// changed by the Renderer when the depth pre-pass is enabled.
+	Opaque PipelineState
+
+	// Viewport is the region of the render target being drawn, in pixels
+	// from the top left corner, and Player the local player it belongs to,
+	// as set by the SplitScreen. An empty Viewport is the whole window.
+	Viewport image.Rectangle
+	Player   int
+
+	// Source is the secondary view being rendered, or nil for the main
+	// camera, and Depth is how many views deep it is nested.
+	Source *View
+	Depth  int
+}
+
+// camera returns the camera of the frame.
+func (f *Frame) camera() *Camera {
+	if f.Camera != nil {
+		return f.Camera
+	}
+	return f.Window.Scene().Camera()
+}
+
+// Size returns the size of the Viewport, or of the window when it is empty,
+// to be used by the passes instead of the window size.
+func (f *Frame) Size() (width, height int) {
+	if !f.Viewport.Empty() {
+		return f.Viewport.Dx(), f.Viewport.Dy()
+	}
+	return f.Window.Width, f.Window.Height
+}
+
+// UIProjection returns the projection of the 2D layer of the frame, with the
+// origin at the top left corner of its viewport.
+func (f *Frame) UIProjection() glm.Mat4 {
+	return UIProjection(f.Size())
+}
+
+// Pass is a step in the frame rendering, such as drawing the opaque blocks or
+// the user interface.
+type Pass interface {
+	Name() string
+	Draw(f *Frame)
+}
+
+type funcPass struct {
+	name string
+	draw func(f *Frame)
+}
+
+func (p funcPass) Name() string { return p.name }
+
+func (p funcPass) Draw(f *Frame) {
+	if p.draw != nil {
+		p.draw(f)
+	}
+}
+
+// NewPass creates a pass that calls the draw function.
+func NewPass(name string, draw func(f *Frame)) Pass {
+	return funcPass{name, draw}
+}
+
+// ApplyEnvironment sets the environment uniforms of the shader like
+// ApplyEnvironment, seen from the frame Eye with the fog of its camera.
+func (f *Frame) ApplyEnvironment(shader *Shader) {
+	cam := f.camera()
+	applyEnvironment(shader, environment, f.Eye, cam.FogStart, cam.FogEnd)
+}
+
+// ScenePass creates a pass that draws the scene with the shader, using the
+// Frame.Opaque pipeline state.
+func ScenePass(name string, s *Scene, shader *Shader) Pass {
+	return NewPass(name, func(f *Frame) { s.DrawState(f, shader, f.Opaque) })
+}
+
+// setCamera uploads the View and Projection of the frame to the shader, or
+// only the view of the scene camera when f is nil.
+func (s *Scene) setCamera(shader *Shader, f *Frame) {
+	if f == nil {
+		shader.UniformTransformation("view", s.cam.View())
+		return
+	}
+	shader.UniformTransformation("view", f.View)
+	shader.UniformTransformation("projection", f.Projection)
+}
+
+// Profiler measures the time spent on each pass. It is implemented by
+// server.TickProfiler.
+type Profiler interface {
+	Measure(name string, fn func())
+}
+
+// Renderer draws a frame by running an ordered list of passes. It starts with
+// empty built-in passes, which can be replaced, and mods and plugins can
+// inject their own passes between them.
+type Renderer struct {
+	// DepthPrePass draws the opaque pass twice: first only to fill the depth
+	// buffer, then to shade only the visible fragments. It reduces overdraw
+	// on fill-rate limited GPUs, at the cost of submitting the geometry twice.
+	DepthPrePass bool
+	// Profiler, if set, measures each pass. The depth pre-pass is reported as
+	// "opaque-depth".
+	Profiler Profiler
+
+	passes   []Pass
+	disabled map[string]bool
+}
+
+// NewRenderer creates a renderer with the built-in passes, all empty.
+func NewRenderer() *Renderer {
+	r := &Renderer{disabled: map[string]bool{}}
+	for _, name := range []string{PassShadow, PassSky, PassOpaque, PassTransparent, PassViewModel, PassUI, PassDebug} {
+		r.passes = append(r.passes, NewPass(name, nil))
+	}
+	return r
+}
+
+func (r *Renderer) index(name string) int {
+	for i, p := range r.passes {
+		if p.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddPass inserts the pass right after the pass named after. An empty after
+// inserts the pass before all others. Pass names must be unique.
+func (r *Renderer) AddPass(p Pass, after string) error {
+	if r.index(p.Name()) >= 0 {
+		return fmt.Errorf("render: pass %q already exists", p.Name())
+	}
+	i := 0
+	if after != "" {
+		i = r.index(after)
+		if i < 0 {
+			return fmt.Errorf("render: pass %q not found", after)
+		}
+		i++
+	}
+	r.passes = append(r.passes, nil)
+	copy(r.passes[i+1:], r.passes[i:])
+	r.passes[i] = p
+	return nil
+}
+
+// Replace substitutes the pass with the same name, keeping its position.
+func (r *Renderer) Replace(p Pass) error {
+	i := r.index(p.Name())
+	if i < 0 {
+		return fmt.Errorf("render: pass %q not found", p.Name())
+	}
+	r.passes[i] = p
+	return nil
+}
+
+// Remove deletes the pass with the provided name.
+func (r *Renderer) Remove(name string) {
+	if i := r.index(name); i >= 0 {
+		r.passes = append(r.passes[:i], r.passes[i+1:]...)
+	}
+}
+
+// SetEnabled turns a pass on or off without changing the order.
+func (r *Renderer) SetEnabled(name string, enabled bool) {
+	if enabled {
+		delete(r.disabled, name)
+	} else {
+		r.disabled[name] = true
+	}
+}
+
+// Passes returns the pass names in the order they are drawn.
+func (r *Renderer) Passes() []string {
+	names := make([]string, len(r.passes))
+	for i, p := range r.passes {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Draw runs all enabled passes in order.
+func (r *Renderer) Draw(f *Frame) {
+	r.draw(f, nil)
+}
+
+// DrawPasses runs only the named passes, in the renderer order, skipping the
+// disabled ones. It is used to draw secondary views, which usually don't need
+// the UI or the view model.
+func (r *Renderer) DrawPasses(f *Frame, names ...string) {
+	r.draw(f, func(name string) bool {
+		for _, n := range names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (r *Renderer) draw(f *Frame, include func(name string) bool) {
+	f.Opaque = DefaultPipeline
+	for _, p := range r.passes {
+		if r.disabled[p.Name()] || (include != nil && !include(p.Name())) {
+			continue
+		}
+		if p.Name() == PassOpaque && r.DepthPrePass {
+			f.Opaque = DepthOnlyPipeline
+			r.measure(PassOpaque+"-depth", func() { p.Draw(f) })
+			f.Opaque = DepthEqualPipeline
+			r.measure(PassOpaque, func() { p.Draw(f) })
+			f.Opaque = DefaultPipeline
+			DefaultPipeline.Apply()
+			continue
+		}
+		r.measure(p.Name(), func() { p.Draw(f) })
+	}
+}
+
+func (r *Renderer) measure(name string, fn func()) {
+	if r.Profiler == nil {
+		fn()
+		return
+	}
+	r.Profiler.Measure(name, fn)
+}
@@ -0,0 +1,38 @@
+//go:build openvoxel_fake
+
+package render
+
+import (
+	"testing"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/render/fake"
+)
+
+func TestScenePassUsesFrameCamera(t *testing.T) {
+	shader := &Shader{}
+	shader.Link()
+	defer shader.Delete()
+	s := NewScene()
+	f := &Frame{
+		View:       glm.Translate3D(1, 2, 3),
+		Projection: glm.Perspective(glm.DegToRad(70), 0.5, 0.1, 100),
+		Opaque:     DefaultPipeline,
+	}
+	fake.Default.Reset()
+	ScenePass(PassOpaque, s, shader).Draw(f)
+
+	want := map[string]glm.Mat4{"view": f.View, "projection": f.Projection}
+	for _, c := range fake.Default.Find("UniformMatrix") {
+		name := c.Args[0].(string)
+		if m, ok := want[name]; ok {
+			if got := c.Args[1].(glm.Mat4); got != m {
+				t.Errorf("%v = %v, want the frame's %v", name, got, m)
+			}
+			delete(want, name)
+		}
+	}
+	for name := range want {
+		t.Errorf("%v not uploaded", name)
+	}
+}
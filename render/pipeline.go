@@ -0,0 +1,116 @@
+package render
+
+// BlendMode selects how fragments are combined with the render target.
+type BlendMode int
+
+const (
+	BlendNone BlendMode = iota
+	// BlendAlpha mixes the fragment using its alpha channel.
+	BlendAlpha
+	// BlendAdditive adds the fragment color, scaled by its alpha.
+	BlendAdditive
+)
+
+// CullMode selects which triangles are discarded by their winding.
+type CullMode int
+
+const (
+	CullNone CullMode = iota
+	CullBack
+	CullFront
+)
+
+// DepthFunc is the comparison used by the depth test.
+type DepthFunc int
+
+const (
+	DepthLess DepthFunc = iota
+	DepthLessEqual
+	DepthEqual
+	DepthGreater
+	DepthGreaterEqual
+)
+
+// PipelineState groups the fixed function settings used to draw a material or
+// a pass. States are applied with Apply, which only issues the calls for the
+// settings that differ from the current ones, so passes don't leak their
+// settings to each other and redundant calls are skipped.
+type PipelineState struct {
+	Blend      BlendMode
+	DepthTest  bool
+	DepthWrite bool
+	DepthFunc  DepthFunc
+	// NoColorWrite disables writes to the color buffers, for depth-only
+	// passes.
+	NoColorWrite bool
+	Cull         CullMode
+	// Wireframe draws only the triangle edges. It is ignored on WebGL.
+	Wireframe bool
+	// PolygonOffset moves the fragments towards the camera, in units of the
+	// depth resolution, to draw on top of coplanar geometry. Zero disables it.
+	PolygonOffset float32
+}
+
+var (
+	// DefaultPipeline is the state used for opaque geometry.
+	DefaultPipeline = PipelineState{DepthTest: true, DepthWrite: true}
+
+	// TransparentPipeline is the state used for translucent geometry, drawn
+	// back to front after the opaque geometry.
+	TransparentPipeline = PipelineState{Blend: BlendAlpha, DepthTest: true}
+
+	// DepthOnlyPipeline is the state used by the depth pre-pass, which fills
+	// the depth buffer without shading.
+	DepthOnlyPipeline = PipelineState{DepthTest: true, DepthWrite: true, NoColorWrite: true}
+
+	// DepthEqualPipeline is the state used to shade opaque geometry after
+	// the depth pre-pass, so only the visible fragment of each pixel runs the
+	// fragment shader.
+	DepthEqualPipeline = PipelineState{DepthTest: true, DepthFunc: DepthLessEqual}
+
+	// OverlayPipeline is the state used to draw decals and labels on top of
+	// coplanar geometry.
+	OverlayPipeline = PipelineState{Blend: BlendAlpha, DepthTest: true, PolygonOffset: -1}
+
+	// HUDPipeline is the state used to draw the 2D layer on top of the
+	// frame.
+	HUDPipeline = PipelineState{Blend: BlendAlpha}
+)
+
+// currentPipeline is the state last applied, or nil if unknown.
+var currentPipeline *PipelineState
+
+// PipelineStats counts the state changes issued and skipped by Apply, useful
+// to find passes that change the state too often.
+var PipelineStats struct {
+	Applied, Skipped int
+}
+
+// Apply makes p the current pipeline state.
+func (p PipelineState) Apply() {
+	if currentPipeline != nil && *currentPipeline == p {
+		PipelineStats.Skipped++
+		return
+	}
+	PipelineStats.Applied++
+	applyPipeline(currentPipeline, p)
+	currentPipeline = &p
+}
+
+// InvalidatePipeline forgets the current state, forcing the next Apply to set
+// all settings. It must be called after changing the state with direct driver
+// calls.
+func InvalidatePipeline() {
+	currentPipeline = nil
+}
+
+// prepareClear enables depth writes, which are needed to clear the depth
+// buffer, keeping the other settings.
+func prepareClear() {
+	p := DefaultPipeline
+	if currentPipeline != nil {
+		p = *currentPipeline
+	}
+	p.DepthWrite = true
+	p.Apply()
+}
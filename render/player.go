@@ -0,0 +1,311 @@
+package render
+
+import (
+	"errors"
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// ErrInvalidSkin is returned when loading a skin that is not 64x64 or 64x32
+// pixels.
+var ErrInvalidSkin = errors.New("render: invalid skin size, must be 64x64 or 64x32")
+
+// Skin is the texture of a player model, in the common 64x64 layout: each
+// part is a box unwrapped around its texture origin, with a second layer for
+// the hat, jacket, sleeves and pants. Legacy 64x32 skins are also supported,
+// and use the mirrored right arm and leg for the left ones, without second
+// layers besides the hat.
+type Skin struct {
+	Texture *Texture
+	// Slim selects the model with 3 pixels wide arms.
+	Slim bool
+	// Legacy is true for 64x32 skins.
+	Legacy bool
+}
+
+// LoadSkin decodes the skin image and uploads its texture.
+func LoadSkin(b []byte, slim bool) (*Skin, error) {
+	w, h, _, err := decodeImage(b)
+	if err != nil {
+		return nil, err
+	}
+	if w != 64 || (h != 64 && h != 32) {
+		return nil, ErrInvalidSkin
+	}
+	tex, err := NewTextureFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Skin{Texture: tex, Slim: slim, Legacy: h == 32}, nil
+}
+
+// Player model parts.
+const (
+	partHead = iota
+	partBody
+	partRightArm
+	partLeftArm
+	partRightLeg
+	partLeftLeg
+	partCount
+)
+
+// playerScale converts the model pixels to blocks, so players are 1.8 blocks
+// tall.
+const playerScale = 0.9375 / 16
+
+// playerPivots are the joints the parts rotate around, in model pixels.
+var playerPivots = [partCount]glm.Vec3{
+	partHead:     {0, 24, 0},
+	partBody:     {0, 24, 0},
+	partRightArm: {-5, 22, 0},
+	partLeftArm:  {5, 22, 0},
+	partRightLeg: {-2, 12, 0},
+	partLeftLeg:  {2, 12, 0},
+}
+
+// skinBox is a box of the player model and where it is unwrapped in the skin.
+type skinBox struct {
+	min, size glm.Vec3
+	u, v      float32
+	// inflate grows the second layer boxes around the first one.
+	inflate float32
+	mirror  bool
+}
+
+// playerBoxes returns the boxes of each part for the skin layout.
+func playerBoxes(slim, legacy bool) [partCount][]skinBox {
+	arm := float32(4)
+	if slim {
+		arm = 3
+	}
+	limb := func(x, y, w float32, u, v, u2, v2 float32, layers bool) []skinBox {
+		b := []skinBox{{min: glm.Vec3{x, y, -2}, size: glm.Vec3{w, 12, 4}, u: u, v: v}}
+		if layers {
+			b = append(b, skinBox{min: b[0].min, size: b[0].size, u: u2, v: v2, inflate: 0.25})
+		}
+		return b
+	}
+	boxes := [partCount][]skinBox{
+		partHead: {
+			{min: glm.Vec3{-4, 24, -4}, size: glm.Vec3{8, 8, 8}, u: 0, v: 0},
+			{min: glm.Vec3{-4, 24, -4}, size: glm.Vec3{8, 8, 8}, u: 32, v: 0, inflate: 0.5},
+		},
+		partBody:     limb(-4, 12, 8, 16, 16, 16, 32, !legacy),
+		partRightArm: limb(-4-arm, 12, arm, 40, 16, 40, 32, !legacy),
+		partLeftArm:  limb(4, 12, arm, 32, 48, 48, 48, true),
+		partRightLeg: limb(-4, 0, 4, 0, 16, 0, 32, !legacy),
+		partLeftLeg:  limb(0, 0, 4, 16, 48, 0, 48, true),
+	}
+	if legacy {
+		boxes[partLeftArm] = []skinBox{{min: glm.Vec3{4, 12, -2}, size: glm.Vec3{arm, 12, 4}, u: 40, v: 16, mirror: true}}
+		boxes[partLeftLeg] = []skinBox{{min: glm.Vec3{0, 0, -2}, size: glm.Vec3{4, 12, 4}, u: 0, v: 16, mirror: true}}
+	}
+	return boxes
+}
+
+// vertices appends the triangles of the box, with texture coordinates for a
+// skin texture of height pixels. The model faces +Z, so the right side of the
+// player is -X.
+func (b skinBox) vertices(dst []float32, height float32) []float32 {
+	lo := b.min.Sub(glm.Vec3{b.inflate, b.inflate, b.inflate})
+	hi := b.min.Add(b.size).Add(glm.Vec3{b.inflate, b.inflate, b.inflate})
+	w, h, d := b.size[0], b.size[1], b.size[2]
+	// rect is a region of the skin, in pixels from its top left corner.
+	type rect struct{ u, v, w, h float32 }
+	var (
+		top    = rect{b.u + d, b.v, w, d}
+		bottom = rect{b.u + d + w, b.v, w, d}
+		right  = rect{b.u, b.v + d, d, h}
+		front  = rect{b.u + d, b.v + d, w, h}
+		left   = rect{b.u + d + w, b.v + d, d, h}
+		back   = rect{b.u + 2*d + w, b.v + d, w, h}
+	)
+	// quad appends the face with the corners seen from outside, from the top
+	// left in counter-clockwise order, textured with r.
+	quad := func(r rect, tl, bl, br, tr glm.Vec3) {
+		u0, u1 := r.u/64, (r.u+r.w)/64
+		v0, v1 := 1-r.v/height, 1-(r.v+r.h)/height
+		corner := func(p glm.Vec3, u, v float32) {
+			dst = append(dst, p[0], p[1], p[2], u, v)
+		}
+		corner(tl, u0, v0)
+		corner(bl, u0, v1)
+		corner(br, u1, v1)
+		corner(tl, u0, v0)
+		corner(br, u1, v1)
+		corner(tr, u1, v0)
+	}
+	x0, y0, z0 := lo[0], lo[1], lo[2]
+	x1, y1, z1 := hi[0], hi[1], hi[2]
+	if b.mirror {
+		// Mirrored boxes are reflected on the x axis, which also flips
+		// their texture.
+		x0, x1 = x1, x0
+	}
+	v := func(x, y, z float32) glm.Vec3 { return glm.Vec3{x, y, z} }
+	quad(front, v(x0, y1, z1), v(x0, y0, z1), v(x1, y0, z1), v(x1, y1, z1))
+	quad(back, v(x1, y1, z0), v(x1, y0, z0), v(x0, y0, z0), v(x0, y1, z0))
+	quad(right, v(x0, y1, z0), v(x0, y0, z0), v(x0, y0, z1), v(x0, y1, z1))
+	quad(left, v(x1, y1, z1), v(x1, y0, z1), v(x1, y0, z0), v(x1, y1, z0))
+	quad(top, v(x0, y1, z0), v(x0, y1, z1), v(x1, y1, z1), v(x1, y1, z0))
+	quad(bottom, v(x0, y0, z1), v(x0, y0, z0), v(x1, y0, z0), v(x1, y0, z1))
+	if b.mirror {
+		// Mirroring the x axis flips the winding of the faces.
+		for i := len(dst) - 36*5; i < len(dst); i += 3 * 5 {
+			for j := 0; j < 5; j++ {
+				dst[i+5+j], dst[i+10+j] = dst[i+10+j], dst[i+5+j]
+			}
+		}
+	}
+	return dst
+}
+
+// skinLayout identifies the model geometry for a skin.
+type skinLayout struct {
+	slim, legacy bool
+}
+
+// playerModel holds the meshes of the parts for a skin layout.
+type playerModel [partCount]*DynamicMesh
+
+func newPlayerModel(l skinLayout) *playerModel {
+	height := float32(64)
+	if l.legacy {
+		height = 32
+	}
+	var m playerModel
+	for i, boxes := range playerBoxes(l.slim, l.legacy) {
+		var vertices []float32
+		for _, b := range boxes {
+			vertices = b.vertices(vertices, height)
+		}
+		m[i] = NewDynamicMesh()
+		m[i].Update(vertices)
+	}
+	return &m
+}
+
+// Avatar is a player drawn with the humanoid model, either a remote player in
+// multiplayer or the local player in the third person view.
+type Avatar struct {
+	Skin *Skin
+	// Position is the point between the feet.
+	Position glm.Vec3
+	// Yaw is the rotation of the body around the Y axis, in radians, with
+	// zero facing +Z. HeadYaw turns the head relative to the body and
+	// Pitch tilts it, positive looking down.
+	Yaw, HeadYaw, Pitch float32
+
+	walkPhase float32
+	walk      float32
+	idle      float32
+}
+
+// Update advances the animations by dt seconds. Speed is the horizontal speed,
+// in blocks per second, which blends between the idle and walking animations.
+func (a *Avatar) Update(dt, speed float32) {
+	target := float32(math.Min(float64(speed)/4.3, 1))
+	a.walk += (target - a.walk) * float32(math.Min(float64(dt)*10, 1))
+	a.walkPhase += dt * speed * 2.2
+	a.idle += dt
+}
+
+// partTransform returns the rotation of the part around its pivot.
+func (a *Avatar) partTransform(part int) glm.Mat4 {
+	swing := float32(math.Sin(float64(a.walkPhase))) * a.walk
+	breath := float32(math.Sin(float64(a.idle)*1.5)) * (1 - a.walk)
+	var r glm.Mat4
+	switch part {
+	case partHead:
+		r = transform.Chain(transform.Rotate(a.HeadYaw, 0, 1, 0), transform.Rotate(a.Pitch, 1, 0, 0))
+	case partRightArm:
+		r = transform.Chain(transform.Rotate(-swing*0.7, 1, 0, 0), transform.Rotate(-0.05-breath*0.03, 0, 0, 1))
+	case partLeftArm:
+		r = transform.Chain(transform.Rotate(swing*0.7, 1, 0, 0), transform.Rotate(0.05+breath*0.03, 0, 0, 1))
+	case partRightLeg:
+		r = transform.Rotate(swing*0.8, 1, 0, 0)
+	case partLeftLeg:
+		r = transform.Rotate(-swing*0.8, 1, 0, 0)
+	default:
+		return glm.Ident4()
+	}
+	p := playerPivots[part]
+	return transform.Chain(transform.Translate(p[0], p[1], p[2]), r, transform.Translate(-p[0], -p[1], -p[2]))
+}
+
+// model returns the world transformation of the part.
+func (a *Avatar) model(part int) glm.Mat4 {
+	return transform.Chain(
+		transform.Translate(a.Position[0], a.Position[1], a.Position[2]),
+		transform.Rotate(a.Yaw, 0, 1, 0),
+		glm.Scale3D(playerScale, playerScale, playerScale),
+		a.partTransform(part),
+	)
+}
+
+// PlayerRenderer draws the avatars. The part meshes are shared by all avatars
+// with the same skin layout.
+type PlayerRenderer struct {
+	avatars map[*Avatar]bool
+	models  map[skinLayout]*playerModel
+}
+
+// NewPlayerRenderer creates a renderer without avatars.
+func NewPlayerRenderer() *PlayerRenderer {
+	return &PlayerRenderer{
+		avatars: map[*Avatar]bool{},
+		models:  map[skinLayout]*playerModel{},
+	}
+}
+
+// Add starts drawing the avatar.
+func (r *PlayerRenderer) Add(a *Avatar) {
+	r.avatars[a] = true
+}
+
+// Remove stops drawing the avatar.
+func (r *PlayerRenderer) Remove(a *Avatar) {
+	delete(r.avatars, a)
+}
+
+// Draw renders the avatars with the view and projection matrices. The shader
+// must use the model, view and projection uniforms, sample the skin at unit 0
+// and discard its transparent texels, used by the second layer.
+func (r *PlayerRenderer) Draw(shader *Shader, view, projection glm.Mat4) {
+	if len(r.avatars) == 0 {
+		return
+	}
+	shader.Use()
+	shader.UniformTransformation("view", view)
+	shader.UniformTransformation("projection", projection)
+	DefaultPipeline.Apply()
+	for a := range r.avatars {
+		if a.Skin == nil || a.Skin.Texture == nil {
+			continue
+		}
+		l := skinLayout{a.Skin.Slim, a.Skin.Legacy}
+		m, ok := r.models[l]
+		if !ok {
+			m = newPlayerModel(l)
+			r.models[l] = m
+		}
+		a.Skin.Texture.Bind(0)
+		for part, mesh := range m {
+			shader.UniformTransformation("model", a.model(part))
+			mesh.Draw()
+		}
+	}
+}
+
+// Delete releases the model meshes. Skins are not deleted.
+func (r *PlayerRenderer) Delete() {
+	for l, m := range r.models {
+		for _, mesh := range m {
+			mesh.Delete()
+		}
+		delete(r.models, l)
+	}
+}
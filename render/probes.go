@@ -0,0 +1,165 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// cubeFaces holds the forward and up vectors used to render each cube map
+// face, in the order +X, -X, +Y, -Y, +Z, -Z.
+var cubeFaces = [6][2]glm.Vec3{
+	{{1, 0, 0}, {0, -1, 0}},
+	{{-1, 0, 0}, {0, -1, 0}},
+	{{0, 1, 0}, {0, 0, 1}},
+	{{0, -1, 0}, {0, 0, -1}},
+	{{0, 0, 1}, {0, -1, 0}},
+	{{0, 0, -1}, {0, -1, 0}},
+}
+
+// ProbeGLSL declares the uniforms set by ProbeManager.Bind and a function to
+// sample the blended reflection. It must be included by reflective material
+// shaders after the #version and precision statements.
+const ProbeGLSL = `
+uniform samplerCube envProbeA;
+uniform samplerCube envProbeB;
+uniform float envProbeBlend;
+
+vec3 environment(vec3 dir) {
+    return mix(texture(envProbeA, dir).rgb, texture(envProbeB, dir).rgb, envProbeBlend);
+}
+`
+
+// Probe is an environment probe: a cube map with the view of the surrounding
+// area from its position, sampled by reflective materials such as ice,
+// polished blocks and water.
+type Probe struct {
+	Position glm.Vec3
+	// Radius is the distance where the probe influence reaches zero.
+	Radius float32
+
+	cube       *CubeMap
+	lastUpdate float64
+	dirty      bool
+}
+
+// Invalidate forces the probe to be rendered on the next update, for instance
+// after the blocks around it changed.
+func (p *Probe) Invalidate() {
+	p.dirty = true
+}
+
+// ProbeManager keeps the environment probes in the scene, updates them
+// periodically, and selects the probes used by each object.
+type ProbeManager struct {
+	// Resolution is the size of each cube map face.
+	Resolution int
+	// UpdateInterval is the time, in seconds, between updates of each probe.
+	// Zero means the probes are only rendered once, or when invalidated.
+	UpdateInterval float64
+
+	probes []*Probe
+	next   int
+}
+
+// NewProbeManager creates an empty probe manager.
+func NewProbeManager() *ProbeManager {
+	return &ProbeManager{
+		Resolution:     128,
+		UpdateInterval: 5,
+	}
+}
+
+// Add places a new probe at the position.
+func (m *ProbeManager) Add(pos glm.Vec3, radius float32) (*Probe, error) {
+	cube, err := NewCubeMap(m.Resolution)
+	if err != nil {
+		return nil, err
+	}
+	p := &Probe{Position: pos, Radius: radius, cube: cube, dirty: true}
+	m.probes = append(m.probes, p)
+	return p, nil
+}
+
+// Remove deletes the probe from the scene.
+func (m *ProbeManager) Remove(p *Probe) {
+	for i, o := range m.probes {
+		if o == p {
+			m.probes = append(m.probes[:i], m.probes[i+1:]...)
+			p.cube.Delete()
+			return
+		}
+	}
+}
+
+// Update renders at most one stale probe, to spread the cost over several
+// frames. The draw function is called once per cube map face, and must draw
+// the scene using the provided view and projection matrices into the current
+// render target. The previous render target is not restored.
+func (m *ProbeManager) Update(now float64, draw func(view, projection glm.Mat4)) {
+	for range m.probes {
+		p := m.probes[m.next%len(m.probes)]
+		m.next++
+		stale := m.UpdateInterval > 0 && now-p.lastUpdate >= m.UpdateInterval
+		if !p.dirty && !stale {
+			continue
+		}
+		projection := transform.Perspective(transform.DegToRad(90), 1, 0.1, p.Radius*4)
+		for face, dir := range cubeFaces {
+			p.cube.BindFace(face)
+			view := transform.LookAt(p.Position, p.Position.Add(dir[0]), dir[1])
+			draw(view, projection)
+		}
+		p.lastUpdate = now
+		p.dirty = false
+		return
+	}
+}
+
+// Nearest returns the two probes with the most influence at pos, and the
+// weight of the second one, to be used for blending. It returns nil probes if
+// there are none in range.
+func (m *ProbeManager) Nearest(pos glm.Vec3) (a, b *Probe, blend float32) {
+	var wa, wb float32
+	for _, p := range m.probes {
+		d := p.Position.Sub(pos).Len()
+		if d >= p.Radius {
+			continue
+		}
+		w := 1 - d/p.Radius
+		switch {
+		case w > wa:
+			b, wb = a, wa
+			a, wa = p, w
+		case w > wb:
+			b, wb = p, w
+		}
+	}
+	if b == nil {
+		return a, a, 0
+	}
+	return a, b, wb / (wa + wb)
+}
+
+// Bind sets the ProbeGLSL uniforms of the shader with the probes nearest to
+// pos, using two consecutive texture units starting at unit. It returns false
+// if there is no probe in range.
+func (m *ProbeManager) Bind(shader *Shader, pos glm.Vec3, unit int) bool {
+	a, b, blend := m.Nearest(pos)
+	if a == nil {
+		return false
+	}
+	a.cube.Bind(unit)
+	b.cube.Bind(unit + 1)
+	shader.UniformInts("envProbeA", int32(unit))
+	shader.UniformInts("envProbeB", int32(unit+1))
+	shader.UniformFloats("envProbeBlend", blend)
+	return true
+}
+
+// Delete releases all probes.
+func (m *ProbeManager) Delete() {
+	for _, p := range m.probes {
+		p.cube.Delete()
+	}
+	m.probes = nil
+}
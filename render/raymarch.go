@@ -0,0 +1,180 @@
+package render
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+)
+
+const raymarchGLSL = `
+precision highp sampler3D;
+
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler3D volume;
+uniform sampler2D blockTexture;
+uniform int textured;
+uniform ivec3 volumeSize;
+uniform vec3 volumeOrigin;
+uniform vec3 eye;
+uniform mat4 viewProjection;
+uniform mat4 invViewProjection;
+uniform vec3 lightDir;
+uniform int maxSteps;
+uniform int zeroToOne;
+uniform float logDepth;
+
+void main() {
+    vec4 far = invViewProjection * vec4(TexCoord * 2.0 - 1.0, 1.0, 1.0);
+    vec3 ro = eye - volumeOrigin;
+    vec3 rd = normalize(far.xyz / far.w - eye);
+    // Avoid divisions by zero for axis aligned rays.
+    rd = mix(rd, vec3(1e-6), lessThan(abs(rd), vec3(1e-6)));
+    vec3 inv = 1.0 / rd;
+
+    // Clip the ray to the volume bounds.
+    vec3 size = vec3(volumeSize);
+    vec3 t0 = -ro * inv;
+    vec3 t1 = (size - ro) * inv;
+    vec3 tmin = min(t0, t1);
+    vec3 tmax = max(t0, t1);
+    float tNear = max(max(tmin.x, tmin.y), max(tmin.z, 0.0));
+    float tFar = min(min(tmax.x, tmax.y), tmax.z);
+    if (tNear >= tFar) {
+        discard;
+    }
+
+    // Walk the voxels crossed by the ray with a 3D DDA.
+    ivec3 cell = clamp(ivec3(floor(ro + rd * (tNear + 1e-4))), ivec3(0), volumeSize - 1);
+    ivec3 stp = ivec3(sign(rd));
+    vec3 delta = abs(inv);
+    vec3 next = (vec3(cell) + max(sign(rd), 0.0) - ro) * inv;
+    int axis = tmin.x > tmin.y ? (tmin.x > tmin.z ? 0 : 2) : (tmin.y > tmin.z ? 1 : 2);
+    float t = tNear;
+    vec4 voxel = vec4(0.0);
+    for (int i = 0; i < maxSteps; i++) {
+        voxel = texelFetch(volume, cell, 0);
+        if (voxel.a > 0.0) {
+            break;
+        }
+        if (next.x < next.y && next.x < next.z) {
+            axis = 0;
+        } else if (next.y < next.z) {
+            axis = 1;
+        } else {
+            axis = 2;
+        }
+        t = next[axis];
+        next[axis] += delta[axis];
+        cell[axis] += stp[axis];
+        if (t > tFar || any(lessThan(cell, ivec3(0))) || any(greaterThanEqual(cell, volumeSize))) {
+            discard;
+        }
+    }
+    if (voxel.a == 0.0) {
+        discard;
+    }
+
+    vec3 normal = vec3(0.0);
+    normal[axis] = -float(stp[axis]);
+    vec3 p = ro + rd * t;
+    vec2 uv = axis == 0 ? p.zy : (axis == 1 ? p.xz : p.xy);
+    vec3 color = voxel.rgb;
+    if (textured != 0) {
+        color *= textureLod(blockTexture, fract(uv), 0.0).rgb;
+    }
+    if (dot(lightDir, lightDir) > 0.0) {
+        color *= 0.4 + 0.6 * max(dot(normal, -normalize(lightDir)), 0.0);
+    }
+    FragColor = vec4(color, 1.0);
+
+    // Write the depth of the hit, so meshes drawn before or after are
+    // composited correctly.
+    vec4 clip = viewProjection * vec4(p + volumeOrigin, 1.0);
+    float z = clip.z / clip.w;
+    if (logDepth > 0.0) {
+        z = log2(max(1e-6, 1.0 + clip.w)) * logDepth - 1.0;
+    }
+    gl_FragDepth = zeroToOne != 0 ? z : z * 0.5 + 0.5;
+}
+`
+
+// Raymarcher is an experimental renderer that draws a VolumeTexture by
+// tracing a ray per pixel through the voxels in a fragment shader, instead of
+// rasterizing a mesh. Each ray walks the volume one voxel at a time, so the
+// cost depends on the screen resolution and the empty space crossed rather
+// than on the number of faces.
+//
+// Voxels with a zero alpha are empty; the others are drawn with their color.
+// The depth of the hits is written, so the volume can be mixed with meshes.
+type Raymarcher struct {
+	// MaxSteps limits the voxels visited by each ray. Zero visits as many as
+	// needed to cross the volume.
+	MaxSteps int
+	// LightDir is the direction of the light used to shade the faces. The
+	// zero vector draws the voxels fully lit.
+	LightDir glm.Vec3
+	// Texture, if set, is repeated on each voxel face and multiplied by the
+	// voxel color.
+	Texture *Texture
+
+	shader *Shader
+}
+
+// NewRaymarcher compiles the raymarching shader.
+func NewRaymarcher() (*Raymarcher, error) {
+	r := &Raymarcher{LightDir: glm.Vec3{-0.3, -1, -0.5}}
+	r.shader = &Shader{}
+	r.shader.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + raymarchGLSL)
+	if err := r.shader.Link(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Draw traces the volume placed with its first voxel at origin, in world
+// units, as seen by a camera at eye with the view and projection matrices.
+// far is the far plane distance, used in the logarithmic depth mode.
+func (r *Raymarcher) Draw(v *VolumeTexture, origin, eye glm.Vec3, view, projection glm.Mat4, far float32) {
+	vp := projection.Mul4(view)
+	steps := r.MaxSteps
+	if steps <= 0 {
+		steps = v.Width + v.Height + v.Depth
+	}
+	DefaultPipeline.Apply()
+	r.shader.Use()
+	v.Bind(0)
+	r.shader.UniformInts("volume", 0)
+	r.shader.UniformInts("textured", 0)
+	if r.Texture != nil {
+		r.Texture.Bind(1)
+		r.shader.UniformInts("blockTexture", 1)
+		r.shader.UniformInts("textured", 1)
+	}
+	r.shader.UniformInts("volumeSize", int32(v.Width), int32(v.Height), int32(v.Depth))
+	r.shader.UniformFloats("volumeOrigin", origin[0], origin[1], origin[2])
+	r.shader.UniformFloats("eye", eye[0], eye[1], eye[2])
+	r.shader.UniformTransformation("viewProjection", vp)
+	r.shader.UniformTransformation("invViewProjection", vp.Inv())
+	r.shader.UniformFloats("lightDir", r.LightDir[0], r.LightDir[1], r.LightDir[2])
+	r.shader.UniformInts("maxSteps", int32(steps))
+	var zeroToOne int32
+	if depthMode == DepthReversed {
+		zeroToOne = 1
+	}
+	r.shader.UniformInts("zeroToOne", zeroToOne)
+	SetLogDepth(r.shader, far)
+	DrawFullscreen()
+}
+
+// Delete releases the shader.
+func (r *Raymarcher) Delete() {
+	if r.shader != nil {
+		r.shader.Delete()
+	}
+}
+
+// Index returns the offset of the voxel x, y, z in the pixels passed to
+// Update.
+func (v *VolumeTexture) Index(x, y, z int) int {
+	return ((z*v.Height+y)*v.Width + x) * 4
+}
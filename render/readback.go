@@ -0,0 +1,20 @@
+package render
+
+import "image"
+
+// readRect clips r, in image coordinates with the first row at the top, to the
+// framebuffer, and returns its position in GPU coordinates, with the first row
+// at the bottom.
+func (f *Framebuffer) readRect(r image.Rectangle) (clipped image.Rectangle, x, y int) {
+	clipped = r.Intersect(image.Rect(0, 0, f.Width, f.Height))
+	return clipped, clipped.Min.X, f.Height - clipped.Max.Y
+}
+
+// readbackImage converts the pixels read from the GPU, with the first row at
+// the bottom, to an image of the rectangle.
+func readbackImage(r image.Rectangle, px []uint8) *image.RGBA {
+	img := image.NewRGBA(r)
+	copy(img.Pix, px)
+	flipRows(img)
+	return img
+}
@@ -0,0 +1,86 @@
+//go:build !js && !openvoxel_fake
+
+package render
+
+import (
+	"image"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Readback is a copy of framebuffer pixels to the CPU started by
+// Framebuffer.ReadPixelsAsync. The pixels are copied by the GPU into a pixel
+// buffer object after the commands before it, while the CPU keeps going, and
+// are available once Ready returns true, usually one or two frames later.
+type Readback struct {
+	// Rect is the area read, in image coordinates.
+	Rect image.Rectangle
+
+	pbo   uint32
+	sync  uintptr
+	ready bool
+}
+
+// ReadPixelsAsync starts copying the rectangle r, in image coordinates with
+// the first row at the top, of the i-th color texture, which must use
+// FormatRGBA8, without waiting for the GPU. Picking reads a single pixel,
+// screenshots the whole framebuffer.
+func (f *Framebuffer) ReadPixelsAsync(i int, r image.Rectangle) *Readback {
+	r, x, y := f.readRect(r)
+	rb := &Readback{Rect: r}
+	gl.GenBuffers(1, &rb.pbo)
+	trackAlloc(resBuffer, 1)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, rb.pbo)
+	gl.BufferData(gl.PIXEL_PACK_BUFFER, r.Dx()*r.Dy()*4, nil, gl.STREAM_READ)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, f.fbo)
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0 + uint32(i))
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	gl.ReadPixels(int32(x), int32(y), int32(r.Dx()), int32(r.Dy()), gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, defaultFramebuffer)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	rb.sync = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+	return rb
+}
+
+// Ready returns true once the GPU finished the copy, without waiting.
+func (rb *Readback) Ready() bool {
+	if !rb.ready && rb.sync != 0 {
+		switch gl.ClientWaitSync(rb.sync, gl.SYNC_FLUSH_COMMANDS_BIT, 0) {
+		case gl.ALREADY_SIGNALED, gl.CONDITION_SATISFIED:
+			rb.ready = true
+		}
+	}
+	return rb.ready
+}
+
+// Image returns the pixels read, with the first row at the top. It waits for
+// the GPU if the copy is not Ready, stalling as Framebuffer.ReadPixels does.
+func (rb *Readback) Image() *image.RGBA {
+	if rb.pbo == 0 {
+		return nil
+	}
+	if !rb.Ready() {
+		gl.ClientWaitSync(rb.sync, gl.SYNC_FLUSH_COMMANDS_BIT, gl.TIMEOUT_IGNORED)
+		rb.ready = true
+	}
+	px := make([]uint8, rb.Rect.Dx()*rb.Rect.Dy()*4)
+	if len(px) > 0 {
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, rb.pbo)
+		gl.GetBufferSubData(gl.PIXEL_PACK_BUFFER, 0, len(px), gl.Ptr(px))
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	}
+	return readbackImage(rb.Rect, px)
+}
+
+// Delete releases the pixel buffer and the fence.
+func (rb *Readback) Delete() {
+	if rb.sync != 0 {
+		gl.DeleteSync(rb.sync)
+		rb.sync = 0
+	}
+	if rb.pbo != 0 {
+		gl.DeleteBuffers(1, &rb.pbo)
+		trackFree(resBuffer, 1)
+		rb.pbo = 0
+	}
+}
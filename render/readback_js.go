@@ -0,0 +1,87 @@
+//go:build !openvoxel_fake
+
+package render
+
+import (
+	"image"
+	"syscall/js"
+)
+
+// Readback is a copy of framebuffer pixels to the CPU started by
+// Framebuffer.ReadPixelsAsync. The pixels are copied by the GPU into a pixel
+// pack buffer after the commands before it, while the CPU keeps going, and are
+// available once Ready returns true, usually one or two frames later.
+type Readback struct {
+	// Rect is the area read, in image coordinates.
+	Rect image.Rectangle
+
+	buf   js.Value
+	sync  js.Value
+	ready bool
+}
+
+// ReadPixelsAsync starts copying the rectangle r, in image coordinates with
+// the first row at the top, of the i-th color texture, which must use
+// FormatRGBA8, without waiting for the GPU. Picking reads a single pixel,
+// screenshots the whole framebuffer.
+func (f *Framebuffer) ReadPixelsAsync(i int, r image.Rectangle) *Readback {
+	r, x, y := f.readRect(r)
+	rb := &Readback{Rect: r}
+	PIXEL_PACK_BUFFER := gl.Get("PIXEL_PACK_BUFFER").Int()
+	rb.buf = gl.Call("createBuffer")
+	trackAlloc(resBuffer, 1)
+	gl.Call("bindBuffer", PIXEL_PACK_BUFFER, rb.buf)
+	gl.Call("bufferData", PIXEL_PACK_BUFFER, r.Dx()*r.Dy()*4, gl.Get("STREAM_READ").Int())
+	gl.Call("bindFramebuffer", gl.Get("READ_FRAMEBUFFER").Int(), f.fbo)
+	gl.Call("readBuffer", gl.Get("COLOR_ATTACHMENT0").Int()+i)
+	gl.Call("pixelStorei", gl.Get("PACK_ALIGNMENT").Int(), 1)
+	gl.Call("readPixels", x, y, r.Dx(), r.Dy(), gl.Get("RGBA").Int(), gl.Get("UNSIGNED_BYTE").Int(), 0)
+	gl.Call("bindFramebuffer", gl.Get("READ_FRAMEBUFFER").Int(), nil)
+	gl.Call("bindBuffer", PIXEL_PACK_BUFFER, nil)
+	rb.sync = gl.Call("fenceSync", gl.Get("SYNC_GPU_COMMANDS_COMPLETE").Int(), 0)
+	gl.Call("flush")
+	return rb
+}
+
+// Ready returns true once the GPU finished the copy, without waiting.
+func (rb *Readback) Ready() bool {
+	if !rb.ready && !rb.sync.IsUndefined() && !rb.sync.IsNull() {
+		status := gl.Call("getSyncParameter", rb.sync, gl.Get("SYNC_STATUS").Int()).Int()
+		rb.ready = status == gl.Get("SIGNALED").Int()
+	}
+	return rb.ready
+}
+
+// Image returns the pixels read, with the first row at the top. WebGL can't
+// wait for the GPU, so reading before Ready stalls inside the browser, as
+// Framebuffer.ReadPixels does.
+func (rb *Readback) Image() *image.RGBA {
+	if rb.buf.IsUndefined() || rb.buf.IsNull() {
+		return nil
+	}
+	n := rb.Rect.Dx() * rb.Rect.Dy() * 4
+	px := make([]uint8, n)
+	if n > 0 {
+		arr := js.Global().Get("Uint8Array").New(n)
+		PIXEL_PACK_BUFFER := gl.Get("PIXEL_PACK_BUFFER").Int()
+		gl.Call("bindBuffer", PIXEL_PACK_BUFFER, rb.buf)
+		gl.Call("getBufferSubData", PIXEL_PACK_BUFFER, 0, arr)
+		gl.Call("bindBuffer", PIXEL_PACK_BUFFER, nil)
+		js.CopyBytesToGo(px, arr)
+	}
+	rb.ready = true
+	return readbackImage(rb.Rect, px)
+}
+
+// Delete releases the pixel pack buffer and the fence.
+func (rb *Readback) Delete() {
+	if !rb.sync.IsUndefined() && !rb.sync.IsNull() {
+		gl.Call("deleteSync", rb.sync)
+		rb.sync = js.Undefined()
+	}
+	if !rb.buf.IsUndefined() && !rb.buf.IsNull() {
+		gl.Call("deleteBuffer", rb.buf)
+		trackFree(resBuffer, 1)
+		rb.buf = js.Undefined()
+	}
+}
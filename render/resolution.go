@@ -0,0 +1,123 @@
+package render
+
+import (
+	"math"
+)
+
+const upscaleGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D scene;
+` + ColorFilterGLSL + `
+void main() {
+    vec4 c = texture(scene, TexCoord);
+    FragColor = vec4(applyColorFilter(c.rgb), c.a);
+}
+`
+
+// ResolutionScaler implements dynamic resolution: the 3D scene is drawn into
+// a framebuffer smaller than the window, whose size adjusts to hold the target
+// frame time, and is then upscaled to the window. The user interface should be
+// drawn after End, at full resolution.
+type ResolutionScaler struct {
+	// TargetFrameTime is the desired frame time, in seconds.
+	TargetFrameTime float64
+	// MinScale and MaxScale limit the resolution scale, relative to the
+	// window size.
+	MinScale, MaxScale float32
+	// Step is the scale change applied when the frame time is off target.
+	Step float32
+
+	scale    float32
+	smoothed float64
+	fb       *Framebuffer
+	upscale  *Shader
+}
+
+// NewResolutionScaler creates a scaler targeting the frame rate, in frames
+// per second.
+func NewResolutionScaler(fps float64) (*ResolutionScaler, error) {
+	up := &Shader{}
+	up.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + upscaleGLSL)
+	if err := up.Link(); err != nil {
+		return nil, err
+	}
+	return &ResolutionScaler{
+		TargetFrameTime: 1 / fps,
+		MinScale:        0.5,
+		MaxScale:        1,
+		Step:            0.05,
+		scale:           1,
+		upscale:         up,
+	}, nil
+}
+
+// Scale returns the current resolution scale.
+func (r *ResolutionScaler) Scale() float32 {
+	return r.scale
+}
+
+// Update adjusts the scale from the duration of the last frame, in seconds.
+// The frame time is smoothed, and the scale only changes when it is more than
+// 10% off target, to avoid oscillating.
+func (r *ResolutionScaler) Update(frameTime float64) {
+	if r.smoothed == 0 {
+		r.smoothed = frameTime
+	}
+	r.smoothed += (frameTime - r.smoothed) * 0.1
+	switch {
+	case r.smoothed > r.TargetFrameTime*1.1:
+		r.scale -= r.Step
+	case r.smoothed < r.TargetFrameTime*0.9:
+		r.scale += r.Step
+	}
+	r.scale = float32(math.Max(float64(r.MinScale), math.Min(float64(r.MaxScale), float64(r.scale))))
+}
+
+// Begin binds and clears the scaled framebuffer, resizing it if the window or
+// the scale changed.
+func (r *ResolutionScaler) Begin(w *Window) error {
+	width := int(float32(w.Width) * r.scale)
+	height := int(float32(w.Height) * r.scale)
+	if width < 1 || height < 1 {
+		width, height = 1, 1
+	}
+	if r.fb == nil {
+		fb, err := NewFramebuffer(width, height, FormatRGBA8)
+		if err != nil {
+			return err
+		}
+		r.fb = fb
+	} else if r.fb.Width != width || r.fb.Height != height {
+		if err := r.fb.Resize(width, height); err != nil {
+			return err
+		}
+	}
+	r.fb.Bind()
+	r.fb.Clear()
+	return nil
+}
+
+// End upscales the scene to the window, applying the accessibility color
+// filter.
+func (r *ResolutionScaler) End(w *Window) {
+	w.BindDefaultFramebuffer()
+	state := DefaultPipeline
+	state.DepthTest, state.DepthWrite = false, false
+	state.Apply()
+	r.upscale.Use()
+	r.upscale.UniformInts("scene", 0)
+	setColorFilter(r.upscale)
+	r.fb.Color(0).Bind(0)
+	DrawFullscreen()
+	DefaultPipeline.Apply()
+}
+
+// Delete releases the framebuffer and shader.
+func (r *ResolutionScaler) Delete() {
+	if r.fb != nil {
+		r.fb.Delete()
+	}
+	r.upscale.Delete()
+}
@@ -0,0 +1,55 @@
+package render
+
+import (
+	"sort"
+
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// Kinds of GPU objects tracked by the resource accounting.
+const (
+	resBuffer      = "buffer"
+	resVertexArray = "vertex array"
+	resProgram     = "program"
+	resTexture     = "texture"
+	resFramebuffer = "framebuffer"
+)
+
+// liveObjects keeps the count of GPU objects allocated and not yet deleted,
+// by kind.
+var liveObjects = map[string]int{}
+
+func trackAlloc(kind string, n int) {
+	liveObjects[kind] += n
+}
+
+func trackFree(kind string, n int) {
+	liveObjects[kind] -= n
+	if liveObjects[kind] <= 0 {
+		delete(liveObjects, kind)
+	}
+}
+
+// LiveObjects returns the number of GPU objects that were allocated and not
+// yet released, by kind. It is intended to be used for debugging resource
+// leaks.
+func LiveObjects() map[string]int {
+	m := make(map[string]int, len(liveObjects))
+	for k, v := range liveObjects {
+		m[k] = v
+	}
+	return m
+}
+
+// reportLeaks logs a warning for any GPU objects still alive. It is called
+// when the window is closed, after all resources should have been released.
+func reportLeaks() {
+	kinds := make([]string, 0, len(liveObjects))
+	for k := range liveObjects {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	for _, k := range kinds {
+		log.Warnf("GPU resource leak: %d %s object(s) still alive at exit", liveObjects[k], k)
+	}
+}
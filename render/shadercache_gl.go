@@ -0,0 +1,98 @@
+//go:build !js && !openvoxel_fake
+
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+var shaderCacheDir string
+
+// SetShaderCacheDir enables caching of linked shader programs in dir, so the
+// shaders are not compiled again on the next runs. An empty dir disables the
+// cache. Cached programs are keyed by the shader sources and the driver, and
+// are ignored if the driver rejects them, such as after a driver update.
+func SetShaderCacheDir(dir string) {
+	shaderCacheDir = dir
+}
+
+// programBinarySupported returns true if the driver can save and load
+// program binaries.
+func programBinarySupported() bool {
+	var formats int32
+	gl.GetIntegerv(gl.NUM_PROGRAM_BINARY_FORMATS, &formats)
+	return formats > 0
+}
+
+// cacheKey returns the cache file name for the shader program, or an empty
+// string if the cache is disabled or not supported.
+func (s *Shader) cacheKey() string {
+	if shaderCacheDir == "" || !programBinarySupported() {
+		return ""
+	}
+	h := sha256.New()
+	for _, name := range []uint32{gl.VENDOR, gl.RENDERER, gl.VERSION} {
+		h.Write([]byte(gl.GoStr(gl.GetString(name))))
+		h.Write([]byte{0})
+	}
+	for _, f := range s.shaderFiles {
+		binary.Write(h, binary.LittleEndian, f.shaderType)
+		h.Write([]byte(f.src))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// loadProgramBinary creates a program from the cached binary.
+func loadProgramBinary(key string) (uint32, bool) {
+	if key == "" {
+		return 0, false
+	}
+	b, err := os.ReadFile(filepath.Join(shaderCacheDir, key+".bin"))
+	if err != nil || len(b) < 4 {
+		return 0, false
+	}
+	format := binary.LittleEndian.Uint32(b)
+	program := gl.CreateProgram()
+	gl.ProgramBinary(program, format, gl.Ptr(b[4:]), int32(len(b)-4))
+	var status int32
+	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
+	if status == gl.FALSE {
+		log.Infof("Ignoring stale shader cache entry %v", key)
+		gl.DeleteProgram(program)
+		return 0, false
+	}
+	log.Infof("Shader program loaded from cache %v", key)
+	return program, true
+}
+
+// saveProgramBinary writes the linked program binary to the cache. Errors are
+// only logged, as the cache is an optimization.
+func saveProgramBinary(key string, program uint32) {
+	if key == "" {
+		return
+	}
+	var length int32
+	gl.GetProgramiv(program, gl.PROGRAM_BINARY_LENGTH, &length)
+	if length == 0 {
+		return
+	}
+	b := make([]byte, 4+length)
+	var format uint32
+	gl.GetProgramBinary(program, length, &length, &format, gl.Ptr(b[4:]))
+	binary.LittleEndian.PutUint32(b, format)
+	if err := os.MkdirAll(shaderCacheDir, 0o755); err != nil {
+		log.Warnf("Unable to create shader cache: %v", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(shaderCacheDir, key+".bin"), b[:4+length], 0o644); err != nil {
+		log.Warnf("Unable to write shader cache: %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package render
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// skyBakeGLSL renders the skyColor hook into a cube map face.
+const skyBakeGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+` + HooksInclude + `
+
+uniform mat4 inverseViewProjection;
+
+void main() {
+    vec4 p = inverseViewProjection * vec4(TexCoord * 2.0 - 1.0, 1.0, 1.0);
+    FragColor = vec4(skyColor(p.xyz / p.w), 1.0);
+}
+`
+
+// skyGLSL draws the baked sky behind the scene, with the stars on top.
+const skyGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform samplerCube sky;
+uniform mat4 inverseViewProjection;
+uniform mat4 starRotation;
+uniform float starDensity;
+uniform float starVisibility;
+
+float hash(vec3 p) {
+    p = fract(p * 0.3183099 + 0.1) * 17.0;
+    return fract(p.x * p.y * p.z * (p.x + p.y + p.z));
+}
+
+void main() {
+    vec4 p = inverseViewProjection * vec4(TexCoord * 2.0 - 1.0, 1.0, 1.0);
+    vec3 dir = normalize(p.xyz / p.w);
+    vec3 color = texture(sky, dir).rgb;
+    if (starVisibility > 0.0) {
+        // A few cells of a grid fixed to the rotating sky hold a star each,
+        // placed randomly inside the cell.
+        vec3 s = mat3(starRotation) * dir * starDensity;
+        vec3 cell = floor(s);
+        float h = hash(cell);
+        if (h > 0.97) {
+            vec3 jitter = vec3(hash(cell + 1.0), hash(cell + 2.0), hash(cell + 3.0)) - 0.5;
+            float d = length(s - cell - 0.5 - jitter * 0.6);
+            float star = (1.0 - smoothstep(0.0, 0.15, d)) * (h - 0.97) / 0.03;
+            color += vec3(star * starVisibility * smoothstep(-0.1, 0.1, dir.y));
+        }
+    }
+    FragColor = vec4(color, 1.0);
+}
+`
+
+const skySpriteVertexGLSL = `
+layout (location = 0) in vec3 aPos;
+layout (location = 1) in vec2 aTexCoord;
+out vec2 TexCoord;
+
+uniform mat4 model;
+uniform mat4 view;
+uniform mat4 projection;
+
+void main() {
+    TexCoord = aTexCoord;
+    gl_Position = projection * view * model * vec4(aPos, 1.0);
+}
+`
+
+const skySpriteFragmentGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D sprite;
+uniform int textured;
+uniform vec4 color;
+
+void main() {
+    vec4 c = color;
+    if (textured == 1) {
+        c *= texture(sprite, TexCoord);
+    } else {
+        c.a *= 1.0 - smoothstep(0.9, 1.0, length(TexCoord - 0.5) * 2.0);
+    }
+    FragColor = c;
+}
+`
+
+// skyPipeline draws the sky behind everything else, without touching the
+// depth buffer.
+var skyPipeline = PipelineState{Blend: BlendAlpha}
+
+// Sky draws a procedural sky driven by the time of day of the environment:
+// the gradient of the skyColor hook, the sun and the moon, and a star field
+// that turns with them and shows up at night.
+//
+// The gradient is baked into a cube map by Update only when the sun moves, so
+// drawing it costs a single texture lookup per pixel. Changes to the sky hook
+// are used by skies created after SetShaderHook.
+type Sky struct {
+	// Resolution is the size of each cube map face. Changes take effect on
+	// the next bake.
+	Resolution int
+	// SunSize and MoonSize are the sprite widths, seen at a distance of one.
+	SunSize, MoonSize float32
+	// SunColor and MoonColor tint the sprites.
+	SunColor, MoonColor glm.Vec4
+	// SunTexture and MoonTexture are the sprite images, such as a moon with
+	// its craters. A nil texture draws a plain disc.
+	SunTexture, MoonTexture *Texture
+	// StarDensity scales the number of stars, and StarBrightness their
+	// brightness on a dark night. Zero brightness hides the stars.
+	StarDensity, StarBrightness float32
+
+	cube   *CubeMap
+	baked  Environment
+	dirty  bool
+	bake   *Shader
+	shader *Shader
+	sprite *Shader
+	quad   *DynamicMesh
+}
+
+// NewSky creates a sky. The cube map is baked on the first Update.
+func NewSky() (*Sky, error) {
+	s := &Sky{
+		Resolution:     64,
+		SunSize:        0.15,
+		MoonSize:       0.1,
+		SunColor:       glm.Vec4{1, 0.95, 0.8, 1},
+		MoonColor:      glm.Vec4{0.8, 0.85, 1, 1},
+		StarDensity:    60,
+		StarBrightness: 1,
+		dirty:          true,
+	}
+	s.bake = &Shader{}
+	s.bake.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + skyBakeGLSL)
+	if err := s.bake.Link(); err != nil {
+		s.Delete()
+		return nil, err
+	}
+	s.shader = &Shader{}
+	s.shader.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + skyGLSL)
+	if err := s.shader.Link(); err != nil {
+		s.Delete()
+		return nil, err
+	}
+	s.sprite = &Shader{}
+	s.sprite.VertexShader(glslVersion + skySpriteVertexGLSL).FragmentShader(glslVersion + skySpriteFragmentGLSL)
+	if err := s.sprite.Link(); err != nil {
+		s.Delete()
+		return nil, err
+	}
+	s.quad = NewDynamicMesh()
+	s.quad.Update([]float32{
+		-0.5, -0.5, 0, 0, 0,
+		0.5, -0.5, 0, 1, 0,
+		0.5, 0.5, 0, 1, 1,
+		-0.5, -0.5, 0, 0, 0,
+		0.5, 0.5, 0, 1, 1,
+		-0.5, 0.5, 0, 0, 1,
+	})
+	return s, nil
+}
+
+// Invalidate forces the sky to be baked on the next Update.
+func (s *Sky) Invalidate() {
+	s.dirty = true
+}
+
+// Update bakes the sky gradient again if the sun moved or the sky colors of
+// the environment changed since the last bake. It must be called before
+// rendering the frame, as the previous render target is not restored.
+func (s *Sky) Update() error {
+	e := environment
+	e.SunDirection = e.sun()
+	changed := e.SkyZenith != s.baked.SkyZenith || e.SkyHorizon != s.baked.SkyHorizon ||
+		e.SunDirection.Dot(s.baked.SunDirection) < 0.9999
+	if !s.dirty && !changed && s.cube != nil && s.cube.Size == s.Resolution {
+		return nil
+	}
+	if s.cube == nil || s.cube.Size != s.Resolution {
+		if s.cube != nil {
+			s.cube.Delete()
+		}
+		cube, err := NewCubeMap(s.Resolution)
+		if err != nil {
+			s.cube = nil
+			return err
+		}
+		s.cube = cube
+	}
+
+	skyPipeline.Apply()
+	s.bake.Use()
+	applyEnvironment(s.bake, e, glm.Vec3{}, 0, 0)
+	projection := transform.Perspective(transform.DegToRad(90), 1, 0.1, 10)
+	for face, dir := range cubeFaces {
+		s.cube.BindFace(face)
+		view := transform.LookAt(glm.Vec3{}, dir[0], dir[1])
+		s.bake.UniformTransformation("inverseViewProjection", projection.Mul4(view).Inv())
+		DrawFullscreen()
+	}
+	s.baked = e
+	s.dirty = false
+	return nil
+}
+
+// Draw renders the sky seen with the view and projection matrices, ignoring
+// the camera position, into the current render target. It does not write the
+// depth buffer, so it must be drawn before the terrain.
+func (s *Sky) Draw(view, projection glm.Mat4) {
+	if s.cube == nil {
+		return
+	}
+	// Only the camera rotation matters, the sky is infinitely far away.
+	view[12], view[13], view[14] = 0, 0, 0
+	e := environment
+	sun := e.sun()
+
+	skyPipeline.Apply()
+	s.shader.Use()
+	s.cube.Bind(0)
+	s.shader.UniformInts("sky", 0)
+	s.shader.UniformTransformation("inverseViewProjection", projection.Mul4(view).Inv())
+	// The stars turn around the same axis as the sun, the Z axis.
+	angle := float32(float64(e.TimeOfDay-0.25) * 2 * math.Pi)
+	s.shader.UniformTransformation("starRotation", glm.HomogRotate3DZ(-angle))
+	s.shader.UniformFloats("starDensity", s.StarDensity)
+	s.shader.UniformFloats("starVisibility", s.StarBrightness*(1-smoothstep(-0.1, 0.1, sun[1])))
+	DrawFullscreen()
+
+	s.sprite.Use()
+	s.sprite.UniformTransformation("view", view)
+	s.sprite.UniformTransformation("projection", projection)
+	s.sprite.UniformInts("sprite", 0)
+	s.drawSprite(sun, s.SunSize, s.SunColor, s.SunTexture)
+	s.drawSprite(sun.Mul(-1), s.MoonSize, s.MoonColor, s.MoonTexture)
+	DefaultPipeline.Apply()
+}
+
+// drawSprite draws a sprite in the direction dir, fading it out below the
+// horizon.
+func (s *Sky) drawSprite(dir glm.Vec3, size float32, color glm.Vec4, tex *Texture) {
+	color[3] *= smoothstep(-0.15, 0, dir[1])
+	if size <= 0 || color[3] <= 0 {
+		return
+	}
+	// The sun path never crosses the Z axis, so it is a stable reference to
+	// orient the sprites.
+	right := dir.Cross(glm.Vec3{0, 0, 1}).Normalize()
+	up := right.Cross(dir)
+	model := glm.Mat4{
+		right[0] * size, right[1] * size, right[2] * size, 0,
+		up[0] * size, up[1] * size, up[2] * size, 0,
+		dir[0], dir[1], dir[2], 0,
+		dir[0], dir[1], dir[2], 1,
+	}
+	s.sprite.UniformTransformation("model", model)
+	s.sprite.UniformFloats("color", color[0], color[1], color[2], color[3])
+	if tex != nil {
+		tex.Bind(0)
+		s.sprite.UniformInts("textured", 1)
+	} else {
+		s.sprite.UniformInts("textured", 0)
+	}
+	s.quad.Draw()
+}
+
+// Pass returns a pass that draws the sky with the frame camera, to replace
+// the built-in PassSky.
+func (s *Sky) Pass() Pass {
+	return NewPass(PassSky, func(f *Frame) { s.Draw(f.View, f.Projection) })
+}
+
+// Delete releases the cube map, shaders and sprite mesh. Textures are not
+// deleted.
+func (s *Sky) Delete() {
+	if s.cube != nil {
+		s.cube.Delete()
+		s.cube = nil
+	}
+	for _, sh := range []*Shader{s.bake, s.shader, s.sprite} {
+		if sh != nil {
+			sh.Delete()
+		}
+	}
+	if s.quad != nil {
+		s.quad.Delete()
+	}
+}
+
+// smoothstep is the GLSL function: 0 below edge0, 1 above edge1, and a smooth
+// curve between them.
+func smoothstep(edge0, edge1, x float32) float32 {
+	t := (x - edge0) / (edge1 - edge0)
+	if t < 0 {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+	return t * t * (3 - 2*t)
+}
@@ -0,0 +1,127 @@
+package render
+
+import (
+	"image"
+)
+
+// MaxViewports is the largest number of local players of a SplitScreen.
+const MaxViewports = 4
+
+// SetViewport limits the drawing on the window to the region r, in pixels
+// from the top left corner, and binds the window, so each local player of a
+// SplitScreen draws only its part of the screen. An empty region restores the
+// whole window.
+func (w *Window) SetViewport(r image.Rectangle) {
+	w.region = r
+	w.BindDefaultFramebuffer()
+}
+
+// Viewport returns the region of the window drawn by BindDefaultFramebuffer,
+// clipped to the window size.
+func (w *Window) Viewport() image.Rectangle {
+	full := image.Rect(0, 0, w.Width, w.Height)
+	if w.region.Empty() {
+		return full
+	}
+	if r := w.region.Intersect(full); !r.Empty() {
+		return r
+	}
+	return full
+}
+
+// SplitLayout returns the regions of the window, width x height pixels, of n
+// local players, from 1 to MaxViewports: one player uses the whole window,
+// two split it horizontally, three have the first player at the top and the
+// others sharing the bottom half, and four use a quarter each.
+func SplitLayout(n, width, height int) []image.Rectangle {
+	hw, hh := width/2, height/2
+	switch {
+	case n <= 1:
+		return []image.Rectangle{image.Rect(0, 0, width, height)}
+	case n == 2:
+		return []image.Rectangle{image.Rect(0, 0, width, hh), image.Rect(0, hh, width, height)}
+	case n == 3:
+		return []image.Rectangle{image.Rect(0, 0, width, hh), image.Rect(0, hh, hw, height), image.Rect(hw, hh, width, height)}
+	default:
+		return []image.Rectangle{
+			image.Rect(0, 0, hw, hh), image.Rect(hw, 0, width, hh),
+			image.Rect(0, hh, hw, height), image.Rect(hw, hh, width, height),
+		}
+	}
+}
+
+// Viewport is the camera of a local player in a SplitScreen.
+type Viewport struct {
+	// Player is the index of the local player, from 0, passed to the passes
+	// in Frame.Player so the UI pass draws the hotbar and health of that
+	// player.
+	Player int
+	Camera *Camera
+	// Hidden skips the viewport, such as while the player is dead and the
+	// game shows a respawn screen instead.
+	Hidden bool
+}
+
+// SplitScreen draws the world once per local player, each one with its own
+// camera in its own region of the window, as laid out by SplitLayout. All the
+// state of a viewport, including the camera matrices and the region size, is
+// passed to the passes in the Frame, so passes must not read it from the
+// window or the scene camera.
+type SplitScreen struct {
+	Renderer  *Renderer
+	Viewports []*Viewport
+}
+
+// NewSplitScreen creates a split screen for the players, from 1 to
+// MaxViewports, each one with a new camera.
+func NewSplitScreen(r *Renderer, players int) *SplitScreen {
+	if players < 1 {
+		players = 1
+	}
+	if players > MaxViewports {
+		players = MaxViewports
+	}
+	s := &SplitScreen{Renderer: r}
+	for i := 0; i < players; i++ {
+		s.Viewports = append(s.Viewports, &Viewport{Player: i, Camera: NewCamera()})
+	}
+	return s
+}
+
+// Frames returns the frame of each visible viewport for the window, in the
+// order of the viewports.
+func (s *SplitScreen) Frames(w *Window, alpha float64) []*Frame {
+	var visible []*Viewport
+	for _, v := range s.Viewports {
+		if !v.Hidden {
+			visible = append(visible, v)
+		}
+	}
+	rects := SplitLayout(len(visible), w.Width, w.Height)
+	frames := make([]*Frame, len(visible))
+	for i, v := range visible {
+		r := rects[i]
+		frames[i] = &Frame{
+			Window:     w,
+			Camera:     v.Camera,
+			Eye:        v.Camera.Position(),
+			View:       v.Camera.View(),
+			Projection: v.Camera.Projection(float32(r.Dx()) / float32(r.Dy())),
+			Alpha:      alpha,
+			Viewport:   r,
+			Player:     v.Player,
+		}
+	}
+	return frames
+}
+
+// Draw clears and renders the region of each visible viewport with the
+// Renderer, then restores the whole window as the render target.
+func (s *SplitScreen) Draw(w *Window, alpha float64) {
+	for _, f := range s.Frames(w, alpha) {
+		w.SetViewport(f.Viewport)
+		w.Scene().Clear()
+		s.Renderer.Draw(f)
+	}
+	w.SetViewport(image.Rectangle{})
+}
@@ -0,0 +1,202 @@
+package render
+
+import (
+	"fmt"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/rng"
+)
+
+// ssaoMaxSamples is the size of the sample kernel array in the SSAO shader.
+const ssaoMaxSamples = 64
+
+const ssaoGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D gPosition;
+uniform sampler2D gNormal;
+uniform mat4 view;
+uniform mat4 projection;
+uniform vec3 samples[64];
+uniform int sampleCount;
+uniform float radius;
+uniform float bias;
+uniform float intensity;
+
+// Interleaved gradient noise, used to rotate the kernel per pixel.
+float noise(vec2 p) {
+    return fract(52.9829189 * fract(dot(p, vec2(0.06711056, 0.00583715))));
+}
+
+void main() {
+    vec4 worldPos = texture(gPosition, TexCoord);
+    if (worldPos.w == 0.0) {
+        FragColor = vec4(1.0);
+        return;
+    }
+    vec3 pos = (view * vec4(worldPos.xyz, 1.0)).xyz;
+    vec3 normal = normalize(mat3(view) * texture(gNormal, TexCoord).xyz);
+
+    float angle = noise(gl_FragCoord.xy) * 6.2831853;
+    vec3 randomVec = vec3(cos(angle), sin(angle), 0.0);
+    vec3 tangent = normalize(randomVec - normal * dot(randomVec, normal));
+    vec3 bitangent = cross(normal, tangent);
+    mat3 TBN = mat3(tangent, bitangent, normal);
+
+    float occlusion = 0.0;
+    for (int i = 0; i < sampleCount; i++) {
+        vec3 samplePos = pos + TBN * samples[i] * radius;
+        vec4 offset = projection * vec4(samplePos, 1.0);
+        offset.xy = (offset.xy / offset.w) * 0.5 + 0.5;
+        vec4 sampleWorld = texture(gPosition, offset.xy);
+        float sampleDepth = (view * vec4(sampleWorld.xyz, 1.0)).z;
+        float rangeCheck = smoothstep(0.0, 1.0, radius / abs(pos.z - sampleDepth));
+        occlusion += (sampleDepth >= samplePos.z + bias ? 1.0 : 0.0) * rangeCheck;
+    }
+    float ao = 1.0 - intensity * occlusion / float(sampleCount);
+    FragColor = vec4(vec3(clamp(ao, 0.0, 1.0)), 1.0);
+}
+`
+
+const ssaoBlurGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D ao;
+
+void main() {
+    vec2 texel = 1.0 / vec2(textureSize(ao, 0));
+    float result = 0.0;
+    for (int x = -2; x < 2; x++) {
+        for (int y = -2; y < 2; y++) {
+            result += texture(ao, TexCoord + vec2(float(x), float(y)) * texel).r;
+        }
+    }
+    FragColor = vec4(vec3(result / 16.0), 1.0);
+}
+`
+
+// SSAO implements screen-space ambient occlusion as a post-processing pass.
+//
+// It reads the world space positions and normals from a G-buffer, like the one
+// produced by the DeferredRenderer, and outputs a blurred ambient occlusion
+// factor in the red channel of the Result texture, to be multiplied with the
+// ambient light. It complements the per-vertex voxel AO for entities and other
+// non-voxel meshes.
+type SSAO struct {
+	// Radius is the sampling radius in world units.
+	Radius float32
+	// Intensity scales the occlusion, where 0 disables the effect.
+	Intensity float32
+	// Bias avoids self-occlusion artifacts on flat surfaces.
+	Bias float32
+	// Samples is the number of kernel samples per pixel, up to 64.
+	Samples int
+
+	ao, blurred  *Framebuffer
+	shader, blur *Shader
+	kernel       []float32
+}
+
+// NewSSAO creates the SSAO pass with render targets of the provided size.
+func NewSSAO(width, height int) (*SSAO, error) {
+	s := &SSAO{
+		Radius:    0.5,
+		Intensity: 1,
+		Bias:      0.025,
+		Samples:   32,
+	}
+	var err error
+	if s.ao, err = NewFramebuffer(width, height, FormatRGBA8); err != nil {
+		return nil, err
+	}
+	if s.blurred, err = NewFramebuffer(width, height, FormatRGBA8); err != nil {
+		s.Delete()
+		return nil, err
+	}
+	s.shader = &Shader{}
+	s.shader.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + ssaoGLSL)
+	if err := s.shader.Link(); err != nil {
+		s.Delete()
+		return nil, err
+	}
+	s.blur = &Shader{}
+	s.blur.VertexShader(glslVersion + FullscreenVertexGLSL).FragmentShader(glslVersion + ssaoBlurGLSL)
+	if err := s.blur.Link(); err != nil {
+		s.Delete()
+		return nil, err
+	}
+
+	// Build the hemisphere sample kernel, with more samples close to the
+	// origin. A fixed seed keeps the effect stable between runs.
+	r := rng.New(0x55a0)
+	for i := 0; i < ssaoMaxSamples; i++ {
+		v := glm.Vec3{r.Float32()*2 - 1, r.Float32()*2 - 1, r.Float32()}.Normalize()
+		scale := float32(i) / ssaoMaxSamples
+		scale = 0.1 + 0.9*scale*scale
+		v = v.Mul(r.Float32() * scale)
+		s.kernel = append(s.kernel, v[0], v[1], v[2])
+	}
+	return s, nil
+}
+
+// Resize changes the size of the render targets.
+func (s *SSAO) Resize(width, height int) error {
+	if err := s.ao.Resize(width, height); err != nil {
+		return err
+	}
+	return s.blurred.Resize(width, height)
+}
+
+// Apply computes the ambient occlusion from the position and normal textures,
+// using the camera view and projection matrices. The previous render target
+// is not restored.
+func (s *SSAO) Apply(position, normal *Texture, view, projection glm.Mat4) error {
+	if s.Samples <= 0 || s.Samples > ssaoMaxSamples {
+		return fmt.Errorf("ssao: invalid sample count %d, expected 1 to %d", s.Samples, ssaoMaxSamples)
+	}
+	s.ao.Bind()
+	s.shader.Use()
+	position.Bind(0)
+	normal.Bind(1)
+	s.shader.UniformInts("gPosition", 0)
+	s.shader.UniformInts("gNormal", 1)
+	s.shader.UniformTransformation("view", view)
+	s.shader.UniformTransformation("projection", projection)
+	for i := 0; i < s.Samples; i++ {
+		k := s.kernel[i*3 : i*3+3]
+		s.shader.UniformFloats(fmt.Sprintf("samples[%d]", i), k[0], k[1], k[2])
+	}
+	s.shader.UniformInts("sampleCount", int32(s.Samples))
+	s.shader.UniformFloats("radius", s.Radius)
+	s.shader.UniformFloats("bias", s.Bias)
+	s.shader.UniformFloats("intensity", s.Intensity)
+	DrawFullscreen()
+
+	s.blurred.Bind()
+	s.blur.Use()
+	s.ao.Color(0).Bind(0)
+	s.blur.UniformInts("ao", 0)
+	DrawFullscreen()
+	return nil
+}
+
+// Result returns the texture with the blurred ambient occlusion factor.
+func (s *SSAO) Result() *Texture {
+	return s.blurred.Color(0)
+}
+
+// Delete releases the render targets and shaders.
+func (s *SSAO) Delete() {
+	for _, f := range []*Framebuffer{s.ao, s.blurred} {
+		if f != nil {
+			f.Delete()
+		}
+	}
+	for _, sh := range []*Shader{s.shader, s.blur} {
+		if sh != nil {
+			sh.Delete()
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package render
+
+// FrameStats counts the work submitted to the GPU during a frame, to be shown
+// in debug displays, reported by benchmarks, and checked by tests catching
+// performance regressions with the fake backend.
+type FrameStats struct {
+	// DrawCalls is the number of draw calls, counting a multi-draw call as
+	// one, and Triangles the number of triangles they drew.
+	DrawCalls, Triangles int
+	// VisibleChunks and CulledChunks are the chunks drawn and skipped by the
+	// ChunkBatch draws.
+	VisibleChunks, CulledChunks int
+	// TextureBinds is the number of textures bound for drawing.
+	TextureBinds int
+	// BufferMemory is the size, in bytes, of the vertex and uniform buffers
+	// allocated at the end of the frame.
+	BufferMemory int
+}
+
+var (
+	// frameStats counts the frame being drawn, and lastStats is the last
+	// completed frame.
+	frameStats, lastStats FrameStats
+	// bufferMemory is the size of the buffers allocated, in bytes.
+	bufferMemory int
+)
+
+// Stats returns the counters of the last frame, completed by
+// Window.SwapBuffers.
+func Stats() FrameStats {
+	return lastStats
+}
+
+// Stats returns the counters of the last frame. All scenes share them, as they
+// draw with the same GPU.
+func (s *Scene) Stats() FrameStats {
+	return lastStats
+}
+
+func countDraw(vertices int) {
+	frameStats.DrawCalls++
+	frameStats.Triangles += vertices / 3
+}
+
+// countMultiDraw counts the commands drawn by a single multi-draw call.
+func countMultiDraw(cmds []DrawCommand) {
+	frameStats.DrawCalls++
+	for _, c := range cmds {
+		frameStats.Triangles += int(c.Count*c.InstanceCount) / 3
+	}
+}
+
+func countTextureBind() {
+	frameStats.TextureBinds++
+}
+
+func countChunks(visible, culled int) {
+	frameStats.VisibleChunks += visible
+	frameStats.CulledChunks += culled
+}
+
+// trackBufferBytes records that the buffer with *size bytes now has n bytes.
+func trackBufferBytes(size *int, n int) {
+	bufferMemory += n - *size
+	*size = n
+}
+
+// endFrame completes the counters of the frame.
+func endFrame() {
+	frameStats.BufferMemory = bufferMemory
+	lastStats = frameStats
+	frameStats = FrameStats{}
+}
@@ -0,0 +1,20 @@
+package render
+
+import "image"
+
+// layerPixels concatenates the images, which must all have the size of the
+// first one, with the bottom row first as expected by the texture upload.
+func layerPixels(layers []*image.RGBA) (w, h int, px []uint8) {
+	if len(layers) == 0 {
+		return 0, 0, nil
+	}
+	w, h = layers[0].Rect.Dx(), layers[0].Rect.Dy()
+	px = make([]uint8, 0, w*h*4*len(layers))
+	for _, img := range layers {
+		for y := h - 1; y >= 0; y-- {
+			i := img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y)
+			px = append(px, img.Pix[i:i+w*4]...)
+		}
+	}
+	return w, h, px
+}
@@ -0,0 +1,65 @@
+//go:build !js && !openvoxel_fake
+
+package render
+
+import (
+	"image"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// TextureArray is a stack of 2D textures of the same size, the layers, sampled
+// by the shaders with a sampler2DArray. Each layer repeats on its own, so
+// block textures on merged faces tile without bleeding into each other.
+type TextureArray struct {
+	Width, Height, Layers int
+
+	tex uint32
+}
+
+// NewTextureArray allocates an empty texture array.
+func NewTextureArray() *TextureArray {
+	t := &TextureArray{}
+	gl.GenTextures(1, &t.tex)
+	trackAlloc(resTexture, 1)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, t.tex)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_S, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_WRAP_T, gl.REPEAT)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MIN_FILTER, gl.NEAREST_MIPMAP_LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, 0)
+	return t
+}
+
+// Update replaces the layers with the images, which must all have the same
+// size, reallocating the array if the size or number of layers changed.
+func (t *TextureArray) Update(layers []*image.RGBA) {
+	w, h, px := layerPixels(layers)
+	if len(px) == 0 {
+		return
+	}
+	t.Width, t.Height, t.Layers = w, h, len(layers)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, t.tex)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.RGBA8, int32(w), int32(h), int32(len(layers)), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(px))
+	gl.GenerateMipmap(gl.TEXTURE_2D_ARRAY)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, 0)
+}
+
+// Bind makes the array available to the shaders at the texture unit.
+func (t *TextureArray) Bind(unit int) {
+	gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, t.tex)
+	countTextureBind()
+}
+
+// Delete releases the array from the GPU memory.
+func (t *TextureArray) Delete() {
+	if t.tex == 0 {
+		return
+	}
+	gl.DeleteTextures(1, &t.tex)
+	trackFree(resTexture, 1)
+	t.tex = 0
+}
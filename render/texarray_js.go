@@ -0,0 +1,68 @@
+//go:build !openvoxel_fake
+
+package render
+
+import (
+	"image"
+	"syscall/js"
+)
+
+// TextureArray is a stack of 2D textures of the same size, the layers, sampled
+// by the shaders with a sampler2DArray. Each layer repeats on its own, so
+// block textures on merged faces tile without bleeding into each other.
+type TextureArray struct {
+	Width, Height, Layers int
+
+	tex js.Value
+}
+
+// NewTextureArray allocates an empty texture array.
+func NewTextureArray() *TextureArray {
+	TEXTURE_2D_ARRAY := gl.Get("TEXTURE_2D_ARRAY").Int()
+	t := &TextureArray{}
+	t.tex = gl.Call("createTexture")
+	trackAlloc(resTexture, 1)
+	gl.Call("bindTexture", TEXTURE_2D_ARRAY, t.tex)
+	gl.Call("texParameteri", TEXTURE_2D_ARRAY, gl.Get("TEXTURE_WRAP_S").Int(), gl.Get("REPEAT").Int())
+	gl.Call("texParameteri", TEXTURE_2D_ARRAY, gl.Get("TEXTURE_WRAP_T").Int(), gl.Get("REPEAT").Int())
+	gl.Call("texParameteri", TEXTURE_2D_ARRAY, gl.Get("TEXTURE_MIN_FILTER").Int(), gl.Get("NEAREST_MIPMAP_LINEAR").Int())
+	gl.Call("texParameteri", TEXTURE_2D_ARRAY, gl.Get("TEXTURE_MAG_FILTER").Int(), gl.Get("NEAREST").Int())
+	gl.Call("bindTexture", TEXTURE_2D_ARRAY, nil)
+	return t
+}
+
+// Update replaces the layers with the images, which must all have the same
+// size, reallocating the array if the size or number of layers changed.
+func (t *TextureArray) Update(layers []*image.RGBA) {
+	w, h, px := layerPixels(layers)
+	if len(px) == 0 {
+		return
+	}
+	t.Width, t.Height, t.Layers = w, h, len(layers)
+	arr := js.Global().Get("Uint8Array").New(len(px))
+	js.CopyBytesToJS(arr, px)
+	TEXTURE_2D_ARRAY := gl.Get("TEXTURE_2D_ARRAY").Int()
+	gl.Call("bindTexture", TEXTURE_2D_ARRAY, t.tex)
+	gl.Call("pixelStorei", gl.Get("UNPACK_ALIGNMENT").Int(), 1)
+	gl.Call("texImage3D", TEXTURE_2D_ARRAY, 0, gl.Get("RGBA8").Int(), w, h, len(layers), 0,
+		gl.Get("RGBA").Int(), gl.Get("UNSIGNED_BYTE").Int(), arr)
+	gl.Call("generateMipmap", TEXTURE_2D_ARRAY)
+	gl.Call("bindTexture", TEXTURE_2D_ARRAY, nil)
+}
+
+// Bind makes the array available to the shaders at the texture unit.
+func (t *TextureArray) Bind(unit int) {
+	gl.Call("activeTexture", gl.Get("TEXTURE0").Int()+unit)
+	gl.Call("bindTexture", gl.Get("TEXTURE_2D_ARRAY").Int(), t.tex)
+	countTextureBind()
+}
+
+// Delete releases the array from the GPU memory.
+func (t *TextureArray) Delete() {
+	if t.tex.IsNull() || t.tex.IsUndefined() {
+		return
+	}
+	gl.Call("deleteTexture", t.tex)
+	trackFree(resTexture, 1)
+	t.tex = js.Undefined()
+}
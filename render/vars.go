@@ -19,12 +19,26 @@ var (
 var (
 	// BgColor is the default rendering background color used by OpenGL.
 	BgColor = Color("#87CEEB")
+
+	// MaxDropSize is the size limit, in bytes, of the files dropped on the
+	// window. Larger files are ignored.
+	MaxDropSize int64 = 64 << 20
 )
 
 // Color parses the provided web color string and returns a color.RGBA value.
-// It panics if the provided string is not in the format #RRGGBB or #RGB.
-func Color(s string) (c color.RGBA) {
-	var err error
+// It panics if the provided string is not in the format #RRGGBB or #RGB, and is
+// meant for constant colors; use ParseColor for user input.
+func Color(s string) color.RGBA {
+	c, err := ParseColor(s)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ParseColor parses the provided web color string, in the format #RRGGBB or
+// #RGB. Malformed colors return an error matching ErrInvalidColor.
+func ParseColor(s string) (c color.RGBA, err error) {
 	c.A = 0xff
 	switch len(s) {
 	case 7:
@@ -36,10 +50,10 @@ func Color(s string) (c color.RGBA) {
 		c.G *= 17
 		c.B *= 17
 	default:
-		err = fmt.Errorf("invalid length, must be 7 or 4")
+		return color.RGBA{}, fmt.Errorf("%w %q: invalid length, must be 7 or 4", ErrInvalidColor, s)
 	}
 	if err != nil {
-		panic(err)
+		return color.RGBA{}, fmt.Errorf("%w %q: %v", ErrInvalidColor, s, err)
 	}
-	return
+	return c, nil
 }
@@ -0,0 +1,371 @@
+package render
+
+import (
+	"image"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// PassViews is the name of the pass created by ViewManager.Pass, which draws
+// the view quads.
+const PassViews = "views"
+
+const viewVertexGLSL = `
+layout (location = 0) in vec3 aPos;
+layout (location = 1) in vec2 aTexCoord;
+
+out vec2 TexCoord;
+
+uniform mat4 model;
+uniform mat4 view;
+uniform mat4 projection;
+` + LogDepthGLSL + `
+void main() {
+    gl_Position = projection * view * model * vec4(aPos, 1.0);
+    applyLogDepth();
+    TexCoord = aTexCoord;
+}
+`
+
+const viewFragmentGLSL = `
+out vec4 FragColor;
+in vec2 TexCoord;
+
+uniform sampler2D viewTexture;
+uniform int empty;
+uniform int screenSpace;
+uniform vec2 viewportSize;
+uniform vec3 background;
+
+void main() {
+    if (empty != 0) {
+        FragColor = vec4(background, 1.0);
+        return;
+    }
+    vec2 uv = screenSpace != 0 ? gl_FragCoord.xy / viewportSize : TexCoord;
+    vec4 c = texture(viewTexture, uv);
+    FragColor = vec4(mix(background, c.rgb, c.a), 1.0);
+}
+`
+
+// ViewKind selects how the camera of a View is placed.
+type ViewKind int
+
+const (
+	// ViewCamera shows the image of a fixed camera, like a security camera
+	// screen. The image is stretched over the quad.
+	ViewCamera ViewKind = iota
+	// ViewMirror shows the main camera reflected on the quad plane.
+	ViewMirror
+	// ViewPortal shows the main camera moved from the quad to the Exit quad,
+	// so looking through the quad shows what is in front of the exit.
+	ViewPortal
+)
+
+// View is a secondary camera rendered to a texture and displayed on a quad in
+// the world, such as a security camera screen, a mirror or a portal.
+type View struct {
+	Kind ViewKind
+	// Quad places the unit quad where the view is displayed. The quad spans
+	// from -0.5 to 0.5 on the X and Y axes, and is visible from +Z.
+	Quad glm.Mat4
+	// Exit places the destination quad of a ViewPortal. Its front, +Z, is
+	// the side seen through the portal.
+	Exit glm.Mat4
+
+	// Eye and Front place the camera of a ViewCamera, and FOV is its
+	// vertical field of view in degrees.
+	Eye, Front glm.Vec3
+	FOV        float32
+	// Width and Height are the texture size of a ViewCamera. Mirrors and
+	// portals use the window size scaled by ViewManager.Scale.
+	Width, Height int
+
+	// MaxDistance is the distance from the camera beyond which the view is
+	// not updated. Zero always updates it.
+	MaxDistance float32
+
+	// targets holds one render target per recursion level.
+	targets []*Framebuffer
+}
+
+// plane returns the center and the normal of the quad m.
+func plane(m glm.Mat4) (center, normal glm.Vec3) {
+	return m.Col(3).Vec3(), m.Mat3().Mul3x1(glm.Vec3{0, 0, 1}).Normalize()
+}
+
+// camera returns the view and projection of the view as seen from the parent
+// frame, and its eye position.
+func (v *View) camera(parent *Frame, aspect float32) (eye glm.Vec3, view, projection glm.Mat4) {
+	switch v.Kind {
+	case ViewMirror:
+		p, n := plane(v.Quad)
+		// Reflection on the plane, in world space.
+		d := n.Dot(p)
+		r := glm.Ident4()
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				r.Set(i, j, r.At(i, j)-2*n[i]*n[j])
+			}
+			r.Set(i, 3, 2*d*n[i])
+		}
+		eye = r.Mul4x1(parent.Eye.Vec4(1)).Vec3()
+		view = parent.View.Mul4(r)
+		projection = obliqueClip(parent.Projection, view, p, n)
+	case ViewPortal:
+		// Move the camera from the entry to the exit, turning around so the
+		// front of the entry leads to the front of the exit.
+		toExit := v.Exit.Mul4(glm.HomogRotate3DY(glm.DegToRad(180))).Mul4(v.Quad.Inv())
+		p, n := plane(v.Exit)
+		eye = toExit.Mul4x1(parent.Eye.Vec4(1)).Vec3()
+		view = parent.View.Mul4(toExit.Inv())
+		projection = obliqueClip(parent.Projection, view, p, n)
+	default:
+		fov := v.FOV
+		if fov <= 0 {
+			fov = 70
+		}
+		eye = v.Eye
+		view = transform.LookAt(v.Eye, v.Eye.Add(v.Front), glm.Vec3{0, 1, 0})
+		projection = Projection(transform.DegToRad(fov), aspect, 0.1, 256)
+	}
+	return eye, view, projection
+}
+
+// obliqueClip moves the near plane of the projection to the plane at p with
+// normal n, so nothing behind a mirror or a portal exit is drawn. It is only
+// supported in the DepthStandard mode; other modes return the projection
+// unchanged.
+func obliqueClip(projection, view glm.Mat4, p, n glm.Vec3) glm.Mat4 {
+	if depthMode != DepthStandard {
+		return projection
+	}
+	// Plane in view space.
+	c := view.Inv().Transpose().Mul4x1(glm.Vec4{n[0], n[1], n[2], -n.Dot(p)})
+	sign := func(f float32) float32 {
+		if f < 0 {
+			return -1
+		}
+		return 1
+	}
+	m := projection
+	q := glm.Vec4{
+		(sign(c[0]) + m[8]) / m[0],
+		(sign(c[1]) + m[9]) / m[5],
+		-1,
+		(1 + m[10]) / m[14],
+	}
+	c = c.Mul(2 / c.Dot(q))
+	m[2], m[6], m[10], m[14] = c[0], c[1], c[2]+1, c[3]
+	return m
+}
+
+// facing returns true if the quad front faces the eye, within the maximum
+// distance.
+func (v *View) facing(eye glm.Vec3) bool {
+	p, n := plane(v.Quad)
+	to := eye.Sub(p)
+	if v.MaxDistance > 0 && to.Len() > v.MaxDistance {
+		return false
+	}
+	return n.Dot(to) > 0
+}
+
+// ViewManager renders the secondary views and draws their quads. Views can
+// see each other, up to MaxDepth levels of nesting: a view seen inside
+// another one is rendered first with the camera of the outer one. Each level
+// multiplies the cost by the number of views visible, so it should be kept
+// small.
+type ViewManager struct {
+	// MaxDepth is the number of nested levels rendered. Views deeper than
+	// that show the Background color.
+	MaxDepth int
+	// Passes are the Renderer passes drawn in the views.
+	Passes []string
+	// Scale is the size of the mirror and portal textures relative to the
+	// window.
+	Scale float32
+	// Background is the color of the areas where nothing was drawn.
+	Background glm.Vec3
+
+	views  []*View
+	quad   *DynamicMesh
+	shader *Shader
+}
+
+// NewViewManager creates a manager without views, drawing the sky, opaque and
+// transparent passes in the views.
+func NewViewManager() (*ViewManager, error) {
+	m := &ViewManager{
+		MaxDepth:   2,
+		Passes:     []string{PassSky, PassOpaque, PassTransparent},
+		Scale:      0.5,
+		Background: glm.Vec3{0.5, 0.7, 1},
+	}
+	m.shader = &Shader{}
+	m.shader.VertexShader(glslVersion + viewVertexGLSL).FragmentShader(glslVersion + viewFragmentGLSL)
+	if err := m.shader.Link(); err != nil {
+		return nil, err
+	}
+	m.quad = NewDynamicMesh()
+	m.quad.Update([]float32{
+		-0.5, -0.5, 0, 0, 0,
+		0.5, -0.5, 0, 1, 0,
+		0.5, 0.5, 0, 1, 1,
+		-0.5, -0.5, 0, 0, 0,
+		0.5, 0.5, 0, 1, 1,
+		-0.5, 0.5, 0, 0, 1,
+	})
+	return m, nil
+}
+
+// Add places a view in the world.
+func (m *ViewManager) Add(v *View) {
+	m.views = append(m.views, v)
+}
+
+// Remove deletes the view and releases its render targets.
+func (m *ViewManager) Remove(v *View) {
+	for i, o := range m.views {
+		if o == v {
+			m.views = append(m.views[:i], m.views[i+1:]...)
+			v.deleteTargets()
+			return
+		}
+	}
+}
+
+func (v *View) deleteTargets() {
+	for _, t := range v.targets {
+		if t != nil {
+			t.Delete()
+		}
+	}
+	v.targets = nil
+}
+
+// target returns the render target of the view seen from the parent frame for
+// the recursion level, creating or resizing it as needed.
+func (m *ViewManager) target(v *View, parent *Frame, level int) (*Framebuffer, error) {
+	width, height := v.Width, v.Height
+	if v.Kind != ViewCamera {
+		width, height = parent.Size()
+		width, height = int(float32(width)*m.Scale), int(float32(height)*m.Scale)
+	}
+	if width <= 0 || height <= 0 {
+		width, height = 256, 256
+	}
+	for len(v.targets) <= level {
+		v.targets = append(v.targets, nil)
+	}
+	t := v.targets[level]
+	if t == nil {
+		var err error
+		if t, err = NewFramebuffer(width, height, FormatRGBA8); err != nil {
+			return nil, err
+		}
+		v.targets[level] = t
+	} else if t.Width != width || t.Height != height {
+		if err := t.Resize(width, height); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Render updates the textures of the views facing the camera of the main
+// frame, drawing the Passes of the renderer. It must be called before drawing
+// the main frame, and leaves the default framebuffer bound, limited to the
+// window viewport.
+func (m *ViewManager) Render(r *Renderer, f *Frame) error {
+	defer f.Window.BindDefaultFramebuffer()
+	for _, v := range m.views {
+		if v.facing(f.Eye) {
+			if err := m.render(r, f, v, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// render draws the view v as seen from the parent frame at the recursion
+// level, after the views visible from it.
+func (m *ViewManager) render(r *Renderer, parent *Frame, v *View, level int) error {
+	t, err := m.target(v, parent, level)
+	if err != nil {
+		return err
+	}
+	child := *parent
+	child.Eye, child.View, child.Projection = v.camera(parent, float32(t.Width)/float32(t.Height))
+	child.Source, child.Depth = v, level+1
+	child.Viewport = image.Rect(0, 0, t.Width, t.Height)
+	if level+1 < m.MaxDepth {
+		for _, o := range m.views {
+			if o != v && o.facing(child.Eye) {
+				if err := m.render(r, &child, o, level+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	t.Bind()
+	t.Clear()
+	r.DrawPasses(&child, m.Passes...)
+	return nil
+}
+
+// Draw renders the view quads for the frame, sampling the textures of the
+// frame recursion level. The quad of the view being rendered is skipped.
+func (m *ViewManager) Draw(f *Frame) {
+	width, height := f.Size()
+	m.shader.Use()
+	m.shader.UniformTransformation("view", f.View)
+	m.shader.UniformTransformation("projection", f.Projection)
+	SetLogDepth(m.shader, f.camera().Far)
+	m.shader.UniformFloats("viewportSize", float32(width), float32(height))
+	m.shader.UniformFloats("background", m.Background[0], m.Background[1], m.Background[2])
+	m.shader.UniformInts("viewTexture", 0)
+	DefaultPipeline.Apply()
+	for _, v := range m.views {
+		if v == f.Source {
+			continue
+		}
+		empty := f.Depth >= m.MaxDepth || f.Depth >= len(v.targets) || v.targets[f.Depth] == nil
+		if !empty {
+			v.targets[f.Depth].Color(0).Bind(0)
+		}
+		m.shader.UniformInts("empty", boolInt(empty))
+		m.shader.UniformInts("screenSpace", boolInt(v.Kind != ViewCamera))
+		m.shader.UniformTransformation("model", v.Quad)
+		m.quad.Draw()
+	}
+}
+
+// Pass returns a pass that draws the view quads, to be added to the Renderer
+// after the opaque pass.
+func (m *ViewManager) Pass() Pass {
+	return NewPass(PassViews, m.Draw)
+}
+
+// Delete releases the views render targets and the quad resources.
+func (m *ViewManager) Delete() {
+	for _, v := range m.views {
+		v.deleteTargets()
+	}
+	m.views = nil
+	if m.quad != nil {
+		m.quad.Delete()
+	}
+	if m.shader != nil {
+		m.shader.Delete()
+	}
+}
+
+func boolInt(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package render
+
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/transform"
+)
+
+// ViewModel draws the first-person held item or hand. It is rendered after the
+// world, with its own projection and a cleared depth buffer, so it never clips
+// into nearby blocks.
+type ViewModel struct {
+	// Mesh and Texture are the held item geometry, in view space units.
+	Mesh    *DynamicMesh
+	Texture *Texture
+
+	// FOV is the vertical field of view, in degrees, independent of the world
+	// camera.
+	FOV float32
+	// Offset places the item relative to the camera.
+	Offset glm.Vec3
+
+	// BobAmount and BobSpeed control the walking bob.
+	BobAmount float32
+	BobSpeed  float32
+	// SwingDuration is the length, in seconds, of the swing animation.
+	SwingDuration float32
+
+	bobPhase float32
+	bobScale float32
+	swing    float32
+}
+
+// NewViewModel creates a view model with the default placement and
+// animation settings.
+func NewViewModel(mesh *DynamicMesh, tex *Texture) *ViewModel {
+	return &ViewModel{
+		Mesh:          mesh,
+		Texture:       tex,
+		FOV:           70,
+		Offset:        glm.Vec3{0.56, -0.52, -0.72},
+		BobAmount:     0.04,
+		BobSpeed:      2.2,
+		SwingDuration: 0.3,
+		swing:         -1,
+	}
+}
+
+// Listen starts the swing animation whenever the local player performs an
+// action published on the bus. It returns a function that stops listening.
+func (v *ViewModel) Listen(b *event.Bus) (unsubscribe func()) {
+	return event.Subscribe(b, func(event.PlayerAction) { v.Swing() })
+}
+
+// Swing starts the swing animation, restarting it if already playing.
+func (v *ViewModel) Swing() {
+	v.swing = 0
+}
+
+// Update advances the animations by dt seconds. Speed is the player
+// horizontal speed, in blocks per second, and the bob only happens while on
+// the ground, unless disabled by the Accessibility.ReduceMotion setting.
+func (v *ViewModel) Update(dt float32, speed float32, onGround bool) {
+	target := float32(0)
+	if onGround && !accessibility.ReduceMotion {
+		target = float32(math.Min(float64(speed)/4.3, 1))
+	}
+	v.bobScale += (target - v.bobScale) * float32(math.Min(float64(dt)*8, 1))
+	v.bobPhase += dt * speed * v.BobSpeed
+	if v.swing >= 0 {
+		v.swing += dt / v.SwingDuration
+		if v.swing >= 1 {
+			v.swing = -1
+		}
+	}
+}
+
+// Model returns the view space transformation of the held item.
+func (v *ViewModel) Model() glm.Mat4 {
+	bob := v.BobAmount * v.bobScale
+	x := float32(math.Sin(float64(v.bobPhase))) * bob
+	y := -float32(math.Abs(math.Cos(float64(v.bobPhase)))) * bob
+
+	var angle, reach float32
+	if v.swing >= 0 {
+		s := float32(math.Sin(float64(v.swing) * math.Pi))
+		angle = -s * transform.DegToRad(60)
+		reach = s * 0.2
+	}
+	return transform.Chain(
+		transform.Translate(v.Offset.X()+x, v.Offset.Y()+y, v.Offset.Z()-reach),
+		transform.Rotate(angle, 1, 0, 0),
+	)
+}
+
+// Projection returns the view model projection for the aspect ratio.
+func (v *ViewModel) Projection(aspect float32) glm.Mat4 {
+	return transform.Perspective(transform.DegToRad(v.FOV), aspect, 0.01, 10)
+}
+
+// Draw renders the held item with the shader on top of the current render
+// target. The shader must use the model, view and projection uniforms and
+// sample the texture at unit 0.
+func (v *ViewModel) Draw(shader *Shader, aspect float32) {
+	if v.Mesh == nil {
+		return
+	}
+	clearDepth()
+	shader.Use()
+	shader.UniformTransformation("projection", v.Projection(aspect))
+	shader.UniformTransformation("view", glm.Ident4())
+	shader.UniformTransformation("model", v.Model())
+	if v.Texture != nil {
+		v.Texture.Bind(0)
+	}
+	v.Mesh.Draw()
+}
@@ -0,0 +1,65 @@
+//go:build !js && !openvoxel_fake
+
+package render
+
+import (
+	"unsafe"
+
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// VolumeTexture is a 3D texture of RGBA8 voxels, sampled without filtering.
+// It is used to upload voxel data that shaders traverse directly, such as the
+// Raymarcher.
+type VolumeTexture struct {
+	Width, Height, Depth int
+
+	tex uint32
+}
+
+// NewVolumeTexture allocates an empty volume of width x height x depth voxels.
+func NewVolumeTexture(width, height, depth int) *VolumeTexture {
+	v := &VolumeTexture{Width: width, Height: height, Depth: depth}
+	gl.GenTextures(1, &v.tex)
+	trackAlloc(resTexture, 1)
+	gl.BindTexture(gl.TEXTURE_3D, v.tex)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_3D, gl.TEXTURE_WRAP_R, gl.CLAMP_TO_EDGE)
+	gl.TexImage3D(gl.TEXTURE_3D, 0, gl.RGBA8, int32(width), int32(height), int32(depth), 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	gl.BindTexture(gl.TEXTURE_3D, 0)
+	return v
+}
+
+// Update replaces all voxels with pixels, 4 bytes per voxel ordered by x, then
+// y, then z, as returned by Index.
+func (v *VolumeTexture) Update(pixels []uint8) {
+	if len(pixels) < v.Width*v.Height*v.Depth*4 {
+		return
+	}
+	gl.BindTexture(gl.TEXTURE_3D, v.tex)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	gl.TexSubImage3D(gl.TEXTURE_3D, 0, 0, 0, 0, int32(v.Width), int32(v.Height), int32(v.Depth),
+		gl.RGBA, gl.UNSIGNED_BYTE, unsafe.Pointer(&pixels[0]))
+	gl.BindTexture(gl.TEXTURE_3D, 0)
+}
+
+// Bind makes the volume available to the shaders at the texture unit.
+func (v *VolumeTexture) Bind(unit int) {
+	gl.ActiveTexture(gl.TEXTURE0 + uint32(unit))
+	gl.BindTexture(gl.TEXTURE_3D, v.tex)
+	countTextureBind()
+}
+
+// Delete releases the volume from the GPU memory.
+func (v *VolumeTexture) Delete() {
+	if v.tex == 0 {
+		return
+	}
+	gl.DeleteTextures(1, &v.tex)
+	trackFree(resTexture, 1)
+	v.tex = 0
+}
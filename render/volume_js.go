@@ -0,0 +1,65 @@
+//go:build !openvoxel_fake
+
+package render
+
+import "syscall/js"
+
+// VolumeTexture is a 3D texture of RGBA8 voxels, sampled without filtering.
+// It is used to upload voxel data that shaders traverse directly, such as the
+// Raymarcher.
+type VolumeTexture struct {
+	Width, Height, Depth int
+
+	tex js.Value
+}
+
+// NewVolumeTexture allocates an empty volume of width x height x depth voxels.
+func NewVolumeTexture(width, height, depth int) *VolumeTexture {
+	TEXTURE_3D := gl.Get("TEXTURE_3D").Int()
+	v := &VolumeTexture{Width: width, Height: height, Depth: depth}
+	v.tex = gl.Call("createTexture")
+	trackAlloc(resTexture, 1)
+	gl.Call("bindTexture", TEXTURE_3D, v.tex)
+	gl.Call("texParameteri", TEXTURE_3D, gl.Get("TEXTURE_MIN_FILTER").Int(), gl.Get("NEAREST").Int())
+	gl.Call("texParameteri", TEXTURE_3D, gl.Get("TEXTURE_MAG_FILTER").Int(), gl.Get("NEAREST").Int())
+	gl.Call("texParameteri", TEXTURE_3D, gl.Get("TEXTURE_WRAP_S").Int(), gl.Get("CLAMP_TO_EDGE").Int())
+	gl.Call("texParameteri", TEXTURE_3D, gl.Get("TEXTURE_WRAP_T").Int(), gl.Get("CLAMP_TO_EDGE").Int())
+	gl.Call("texParameteri", TEXTURE_3D, gl.Get("TEXTURE_WRAP_R").Int(), gl.Get("CLAMP_TO_EDGE").Int())
+	gl.Call("texStorage3D", TEXTURE_3D, 1, gl.Get("RGBA8").Int(), width, height, depth)
+	gl.Call("bindTexture", TEXTURE_3D, nil)
+	return v
+}
+
+// Update replaces all voxels with pixels, 4 bytes per voxel ordered by x, then
+// y, then z, as returned by Index.
+func (v *VolumeTexture) Update(pixels []uint8) {
+	n := v.Width * v.Height * v.Depth * 4
+	if len(pixels) < n {
+		return
+	}
+	arr := js.Global().Get("Uint8Array").New(n)
+	js.CopyBytesToJS(arr, pixels[:n])
+	TEXTURE_3D := gl.Get("TEXTURE_3D").Int()
+	gl.Call("bindTexture", TEXTURE_3D, v.tex)
+	gl.Call("pixelStorei", gl.Get("UNPACK_ALIGNMENT").Int(), 1)
+	gl.Call("texSubImage3D", TEXTURE_3D, 0, 0, 0, 0, v.Width, v.Height, v.Depth,
+		gl.Get("RGBA").Int(), gl.Get("UNSIGNED_BYTE").Int(), arr)
+	gl.Call("bindTexture", TEXTURE_3D, nil)
+}
+
+// Bind makes the volume available to the shaders at the texture unit.
+func (v *VolumeTexture) Bind(unit int) {
+	gl.Call("activeTexture", gl.Get("TEXTURE0").Int()+unit)
+	gl.Call("bindTexture", gl.Get("TEXTURE_3D").Int(), v.tex)
+	countTextureBind()
+}
+
+// Delete releases the volume from the GPU memory.
+func (v *VolumeTexture) Delete() {
+	if v.tex.IsNull() || v.tex.IsUndefined() {
+		return
+	}
+	gl.Call("deleteTexture", v.tex)
+	trackFree(resTexture, 1)
+	v.tex = js.Undefined()
+}
@@ -1,20 +1,27 @@
+//go:build !openvoxel_fake
+
 package render
 
 import (
-	"bytes"
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
+	"strings"
 	"syscall/js"
 	"time"
 
 	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/event"
 	"github.com/ronoaldo/openvoxel/log"
-
-	"github.com/disintegration/imaging"
 )
 
+// deferredSupported indicates that the DeferredRenderer can be used. The
+// WebGL backend keeps the simple forward path.
+const deferredSupported = false
+
+// glslVersion is the header of the builtin shaders for this backend.
+const glslVersion = "#version 300 es\nprecision highp float;\n"
+
 // Version returns the WebGL version as reported by the driver.
 func Version() string {
 	if gl.IsUndefined() || gl.IsNull() {
@@ -43,9 +50,29 @@ func Time() float64 {
 type Window struct {
 	canvas js.Value
 	scene  *Scene
+	closed bool
+
+	// Visibility helpers
+	visible            bool
+	onVisibilityChange func(visible bool)
+	visibilityHandler  js.Func
+	keyHandler         js.Func
+	mouseHandler       js.Func
+	dragHandler        js.Func
+	dropHandler        js.Func
+
+	// textArea receives the text input while the text input mode is on, so
+	// the browser input methods can be used.
+	textArea     js.Value
+	textHandlers map[string]js.Func
+	textInput    bool
 
 	Width  int
 	Height int
+
+	// region is the part of the window drawn by BindDefaultFramebuffer,
+	// set with SetViewport. Empty is the whole window.
+	region image.Rectangle
 }
 
 var document js.Value
@@ -67,32 +94,346 @@ func NewWindow(width, height int, title string) (w *Window, err error) {
 	gl = w.canvas.Call("getContext", "webgl2")
 	w.scene = NewScene()
 
+	w.visible = !document.Get("hidden").Bool()
+	w.visibilityHandler = js.FuncOf(w.onVisibilityChangeEvent)
+	document.Call("addEventListener", "visibilitychange", w.visibilityHandler)
+	w.keyHandler = js.FuncOf(w.onKeyEvent)
+	document.Call("addEventListener", "keydown", w.keyHandler)
+	document.Call("addEventListener", "keyup", w.keyHandler)
+	w.mouseHandler = js.FuncOf(w.onMouseEvent)
+	w.canvas.Call("addEventListener", "mousedown", w.mouseHandler)
+	w.canvas.Call("addEventListener", "mouseup", w.mouseHandler)
+	w.canvas.Call("addEventListener", "contextmenu", w.mouseHandler)
+	w.dragHandler = js.FuncOf(func(this js.Value, args []js.Value) any {
+		// Required for the browser to allow dropping on the canvas.
+		args[0].Call("preventDefault")
+		return nil
+	})
+	w.dropHandler = js.FuncOf(w.onDrop)
+	w.canvas.Call("addEventListener", "dragover", w.dragHandler)
+	w.canvas.Call("addEventListener", "drop", w.dropHandler)
+
 	requestAnimationFrame()
 
 	return w, nil
 }
 
 func (w *Window) ShouldClose() bool {
-	return false
+	return w.closed
+}
+
+// Close frees any used resources, including the pending requestAnimationFrame
+// callback, and removes the canvas from the document.
+//
+// Resources created by the caller, such as Shader and Texture objects, must be
+// deleted before calling Close. Any GPU objects still alive are reported as
+// leaks in the logs.
+func (w *Window) Close() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	cancelAnimationFrame()
+	document.Call("removeEventListener", "visibilitychange", w.visibilityHandler)
+	w.visibilityHandler.Release()
+	document.Call("removeEventListener", "keydown", w.keyHandler)
+	document.Call("removeEventListener", "keyup", w.keyHandler)
+	w.keyHandler.Release()
+	w.canvas.Call("removeEventListener", "mousedown", w.mouseHandler)
+	w.canvas.Call("removeEventListener", "mouseup", w.mouseHandler)
+	w.canvas.Call("removeEventListener", "contextmenu", w.mouseHandler)
+	w.mouseHandler.Release()
+	w.canvas.Call("removeEventListener", "dragover", w.dragHandler)
+	w.canvas.Call("removeEventListener", "drop", w.dropHandler)
+	w.dragHandler.Release()
+	w.dropHandler.Release()
+	w.StopTextInput()
+	w.scene.Delete()
+	reportLeaks()
+	w.canvas.Call("remove")
+}
+
+// PollEvents resizes the drawing buffer of the canvas to the size of the
+// canvas in the page, such as when a style makes it fill the browser window,
+// and publishes event.WindowResized. Input events are delivered by the browser
+// as they happen.
+func (w *Window) PollEvents() {
+	width, height := w.canvas.Get("clientWidth").Int(), w.canvas.Get("clientHeight").Int()
+	if width <= 0 || height <= 0 || width == w.Width && height == w.Height {
+		return
+	}
+	w.Width, w.Height = width, height
+	w.canvas.Set("width", width)
+	w.canvas.Set("height", height)
+	gl.Call("viewport", 0, 0, width, height)
+	event.Publish(event.Default, event.WindowResized{Width: width, Height: height})
+}
+
+// WaitEvents blocks until the browser schedules the next animation frame.
+// Browsers stop scheduling frames for hidden tabs, so this will block until
+// the page is visible again.
+func (w *Window) WaitEvents() {
+	<-animationFrameLock
+	requestAnimationFrame()
+}
+
+func (w *Window) onVisibilityChangeEvent(this js.Value, args []js.Value) any {
+	w.visible = !document.Get("hidden").Bool()
+	log.Infof("Document visibility changed: visible=%v", w.visible)
+	if w.onVisibilityChange != nil {
+		w.onVisibilityChange(w.visible)
+	}
+	return nil
+}
+
+// SetCameraControls is a no-op, as the web backend has no built-in camera
+// movement.
+func (w *Window) SetCameraControls(enabled bool) {}
+
+// webKeys maps the browser key codes that differ from the desktop ones.
+var webKeys = map[int]int{
+	8: 259, 9: 258, 13: 257, 16: 340, 17: 341, 18: 342, 27: 256,
+	35: 269, 36: 268, 37: 263, 38: 265, 39: 262, 40: 264, 46: 261, 187: 61, 189: 45,
+}
+
+// onKeyEvent publishes the keyboard events as event.Key, with the same key
+// codes used by the desktop backend.
+func (w *Window) onKeyEvent(this js.Value, args []js.Value) any {
+	e := args[0]
+	if e.Get("isComposing").Bool() || e.Get("keyCode").Int() == 229 {
+		// Keys used by the input method to compose text.
+		return nil
+	}
+	key := e.Get("keyCode").Int()
+	if k, ok := webKeys[key]; ok {
+		key = k
+	} else if key >= 112 && key <= 123 {
+		// F1 to F12
+		key += 290 - 112
+	}
+	action := event.KeyPress
+	switch {
+	case e.Get("type").String() == "keyup":
+		action = event.KeyRelease
+	case e.Get("repeat").Bool():
+		action = event.KeyRepeat
+	}
+	var mods int
+	for i, m := range []string{"shiftKey", "ctrlKey", "altKey", "metaKey"} {
+		if e.Get(m).Bool() {
+			mods |= 1 << i
+		}
+	}
+	event.Publish(event.Default, event.Key{Key: key, Action: action, Mods: mods, Text: w.textInput})
+	return nil
+}
+
+// onMouseEvent publishes the mouse buttons pressed on the canvas as
+// event.MouseButton, and disables the context menu so the right button can be
+// used by the game.
+func (w *Window) onMouseEvent(this js.Value, args []js.Value) any {
+	e := args[0]
+	action := event.KeyPress
+	switch e.Get("type").String() {
+	case "contextmenu":
+		e.Call("preventDefault")
+		return nil
+	case "mouseup":
+		action = event.KeyRelease
+	}
+	// The browser numbers the middle button 1 and the right one 2.
+	button := e.Get("button").Int()
+	switch button {
+	case 1:
+		button = event.MouseMiddle
+	case 2:
+		button = event.MouseRight
+	}
+	var mods int
+	for i, m := range []string{"shiftKey", "ctrlKey", "altKey", "metaKey"} {
+		if e.Get(m).Bool() {
+			mods |= 1 << i
+		}
+	}
+	event.Publish(event.Default, event.MouseButton{Button: button, Action: action, Mods: mods})
+	return nil
+}
+
+// onDrop reads the files dropped on the canvas and publishes them as
+// event.FileDrop once all of them are read.
+func (w *Window) onDrop(this js.Value, args []js.Value) any {
+	e := args[0]
+	e.Call("preventDefault")
+	files := e.Get("dataTransfer").Get("files")
+	var drop event.FileDrop
+	pending := 0
+	done := func() {
+		pending--
+		if pending == 0 && len(drop.Files) > 0 {
+			event.Publish(event.Default, drop)
+		}
+	}
+	for i := 0; i < files.Length(); i++ {
+		file := files.Index(i)
+		name := file.Get("name").String()
+		if int64(file.Get("size").Float()) > MaxDropSize {
+			log.Warnf("Ignoring dropped file %v: larger than %d bytes", name, MaxDropSize)
+			continue
+		}
+		pending++
+		var ok, fail js.Func
+		ok = js.FuncOf(func(this js.Value, args []js.Value) any {
+			ok.Release()
+			fail.Release()
+			data := make([]byte, args[0].Get("byteLength").Int())
+			js.CopyBytesToGo(data, js.Global().Get("Uint8Array").New(args[0]))
+			drop.Files = append(drop.Files, event.DroppedFile{Name: name, Data: data})
+			done()
+			return nil
+		})
+		fail = js.FuncOf(func(this js.Value, args []js.Value) any {
+			ok.Release()
+			fail.Release()
+			log.Warnf("Error reading dropped file %v: %v", name, args[0].Call("toString").String())
+			done()
+			return nil
+		})
+		file.Call("arrayBuffer").Call("then", ok, fail)
+	}
+	return nil
+}
+
+// StartTextInput enables the text input mode: typed text is published as
+// event.Text, input method compositions as event.TextComposition, and key
+// events are flagged with event.Key.Text. A hidden text area takes the focus,
+// so the browser input methods work as in any text field.
+func (w *Window) StartTextInput() {
+	if w.textInput {
+		return
+	}
+	w.textInput = true
+	ta := document.Call("createElement", "textarea")
+	style := ta.Get("style")
+	style.Set("position", "absolute")
+	style.Set("opacity", "0")
+	style.Set("left", "0")
+	style.Set("bottom", "0")
+	style.Set("width", "1px")
+	style.Set("height", "1px")
+	ta.Call("setAttribute", "autocomplete", "off")
+	ta.Call("setAttribute", "autocapitalize", "off")
+	ta.Call("setAttribute", "spellcheck", "false")
+	document.Get("body").Call("appendChild", ta)
+
+	w.textHandlers = map[string]js.Func{
+		"input": js.FuncOf(func(this js.Value, args []js.Value) any {
+			if args[0].Get("isComposing").Bool() {
+				return nil
+			}
+			w.flushText()
+			return nil
+		}),
+		"compositionupdate": js.FuncOf(func(this js.Value, args []js.Value) any {
+			text := args[0].Get("data").String()
+			event.Publish(event.Default, event.TextComposition{Text: text, Cursor: len([]rune(text))})
+			return nil
+		}),
+		"compositionend": js.FuncOf(func(this js.Value, args []js.Value) any {
+			event.Publish(event.Default, event.TextComposition{})
+			w.flushText()
+			return nil
+		}),
+	}
+	for name, fn := range w.textHandlers {
+		ta.Call("addEventListener", name, fn)
+	}
+	w.textArea = ta
+	ta.Call("focus")
+}
+
+// stripControl drops line breaks and tabs, which are only reported as key
+// events in the text input mode.
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// flushText publishes the text typed or pasted in the text area and clears
+// it.
+func (w *Window) flushText() {
+	text := stripControl(w.textArea.Get("value").String())
+	w.textArea.Set("value", "")
+	if text != "" {
+		event.Publish(event.Default, event.Text{Text: text})
+	}
+}
+
+// StopTextInput returns the keyboard to the game controls.
+func (w *Window) StopTextInput() {
+	if !w.textInput {
+		return
+	}
+	w.textInput = false
+	for name, fn := range w.textHandlers {
+		w.textArea.Call("removeEventListener", name, fn)
+		fn.Release()
+	}
+	w.textHandlers = nil
+	w.textArea.Call("remove")
+	w.textArea = js.Undefined()
+	w.canvas.Call("focus")
 }
 
-func (w *Window) Close() {}
+// TextInputActive returns true while the text input mode is enabled.
+func (w *Window) TextInputActive() bool {
+	return w.textInput
+}
 
-func (w *Window) PollEvents() {}
+// Visible returns false when the browser tab is hidden.
+func (w *Window) Visible() bool {
+	return w.visible
+}
+
+// SetVisibilityCallback registers a function to be called when the browser
+// tab is hidden or shown again.
+func (w *Window) SetVisibilityCallback(cb func(visible bool)) {
+	w.onVisibilityChange = cb
+}
 
 var animationFrameLock = make(chan struct{}, 1)
 
+// pendingFrame holds the callback scheduled with requestAnimationFrame, so it
+// can be canceled and released when the window is closed.
+var (
+	pendingFrame   js.Func
+	pendingFrameID js.Value
+)
+
 func requestAnimationFrame() {
-	var cb js.Func
-	cb = js.FuncOf(func(this js.Value, args []js.Value) any {
+	pendingFrame = js.FuncOf(func(this js.Value, args []js.Value) any {
 		animationFrameLock <- struct{}{}
-		cb.Release()
+		pendingFrame.Release()
+		pendingFrame = js.Func{}
 		return nil
 	})
-	js.Global().Call("requestAnimationFrame", cb)
+	pendingFrameID = js.Global().Call("requestAnimationFrame", pendingFrame)
+}
+
+func cancelAnimationFrame() {
+	if pendingFrame.IsUndefined() {
+		return
+	}
+	js.Global().Call("cancelAnimationFrame", pendingFrameID)
+	pendingFrame.Release()
+	pendingFrame = js.Func{}
 }
 
 func (w *Window) SwapBuffers() {
+	endFrame()
 	<-animationFrameLock
 	requestAnimationFrame()
 }
@@ -112,12 +453,12 @@ type Shader struct {
 }
 
 func (s *Shader) VertexShader(src string) *Shader {
-	s.shaderFiles = append(s.shaderFiles, shaderSource{src, gl.Get("VERTEX_SHADER").Int()})
+	s.shaderFiles = append(s.shaderFiles, shaderSource{preprocessGLSL(src), gl.Get("VERTEX_SHADER").Int()})
 	return s
 }
 
 func (s *Shader) FragmentShader(src string) *Shader {
-	s.shaderFiles = append(s.shaderFiles, shaderSource{src, gl.Get("FRAGMENT_SHADER").Int()})
+	s.shaderFiles = append(s.shaderFiles, shaderSource{preprocessGLSL(src), gl.Get("FRAGMENT_SHADER").Int()})
 	return s
 }
 
@@ -184,11 +525,28 @@ func (s *Shader) UniformTransformation(name string, t glm.Mat4) error {
 	return nil
 }
 
+// UniformBlock binds the uniform block with the given name to the uniform
+// buffer binding point. Returns an error if the shader was not linked.
+func (s *Shader) UniformBlock(name string, binding uint32) error {
+	if s.program.IsNull() || s.program.IsUndefined() {
+		return ErrShaderNotLinked
+	}
+	index := gl.Call("getUniformBlockIndex", s.program, name)
+	if index.Int() == gl.Get("INVALID_INDEX").Int() {
+		return fmt.Errorf("shader: uniform block %q not found", name)
+	}
+	gl.Call("uniformBlockBinding", s.program, index, binding)
+	return nil
+}
+
 func (s *Shader) Link() error {
 	shaders := []js.Value{}
 	for _, file := range s.shaderFiles {
 		shader, err := s.compileShader(file.src, file.shaderType)
 		if err != nil {
+			for _, shader := range shaders {
+				gl.Call("deleteShader", shader)
+			}
 			return err
 		}
 
@@ -201,9 +559,32 @@ func (s *Shader) Link() error {
 	}
 
 	s.program = p
+	trackAlloc(resProgram, 1)
 	return nil
 }
 
+// Delete releases the linked shader program. The shader must be linked again
+// before it can be used.
+func (s *Shader) Delete() {
+	if s.program.IsNull() || s.program.IsUndefined() {
+		return
+	}
+	gl.Call("deleteProgram", s.program)
+	trackFree(resProgram, 1)
+	s.program = js.Undefined()
+}
+
+// shaderStage returns the stage of the WebGL shader type.
+func shaderStage(t int) ShaderStage {
+	switch t {
+	case gl.Get("VERTEX_SHADER").Int():
+		return StageVertex
+	case gl.Get("FRAGMENT_SHADER").Int():
+		return StageFragment
+	}
+	return StageProgram
+}
+
 func (s *Shader) compileShader(shaderSource string, shaderType int) (js.Value, error) {
 	log.Infof("Compiling shader (type=%v): %#s", shaderType, shaderSource)
 
@@ -214,7 +595,8 @@ func (s *Shader) compileShader(shaderSource string, shaderType int) (js.Value, e
 	if !status.Bool() {
 		reason := gl.Call("getShaderInfoLog", shader).String()
 		log.Warnf("Error compiling shader: %v", reason)
-		return js.Undefined(), fmt.Errorf("webgl: " + reason)
+		gl.Call("deleteShader", shader)
+		return js.Undefined(), newShaderError(shaderStage(shaderType), reason, shaderSource)
 	}
 	log.Infof("Shader compiled (status=%v)", status)
 	return shader, nil
@@ -230,6 +612,14 @@ func (s *Shader) linkProgram(shaders ...js.Value) (js.Value, error) {
 	gl.Call("linkProgram", shaderProgram)
 
 	status := gl.Call("getProgramParameter", shaderProgram, gl.Get("LINK_STATUS").Int())
+	for _, shader := range shaders {
+		gl.Call("deleteShader", shader)
+	}
+	if !status.Bool() {
+		reason := gl.Call("getProgramInfoLog", shaderProgram).String()
+		gl.Call("deleteProgram", shaderProgram)
+		return js.Undefined(), newShaderError(StageProgram, reason, "")
+	}
 	log.Infof("Program linked (status=%v)", status)
 	return shaderProgram, nil
 }
@@ -244,19 +634,31 @@ func (s *Shader) Use() {
 // Scene represents a graph of elements to be drawn on screen by the WebGL
 // driver.
 type Scene struct {
+	cam *Camera
+
 	tex        *Texture
 	clearColor color.Color
 	wireFrames bool
+	lit        bool
+	mapped     bool
+	tinted     bool
 
 	vao js.Value
 
 	vbo     js.Value
 	vboSize int
+	// vboBytes is the buffer size, in bytes.
+	vboBytes int
 }
 
 // NewScene initializes an empty scene with the proper memory allocations.
 func NewScene() *Scene {
-	return &Scene{}
+	return &Scene{cam: NewCamera()}
+}
+
+// Camera returns the scene camera.
+func (s *Scene) Camera() *Camera {
+	return s.cam
 }
 
 func (s *Scene) allocateBuffers() {
@@ -264,9 +666,26 @@ func (s *Scene) allocateBuffers() {
 		log.Infof("Allocating buffers ...")
 		s.vao = gl.Call("createVertexArray")
 		s.vbo = gl.Call("createBuffer")
+		trackAlloc(resVertexArray, 1)
+		trackAlloc(resBuffer, 1)
 	}
 }
 
+// Delete releases the GPU buffers allocated by the scene. Textures added to
+// the scene are not deleted, as they may be shared with other scenes.
+func (s *Scene) Delete() {
+	if s.vao.IsNull() || s.vao.IsUndefined() {
+		return
+	}
+	gl.Call("deleteVertexArray", s.vao)
+	gl.Call("deleteBuffer", s.vbo)
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&s.vboBytes, 0)
+	s.vao, s.vbo = js.Undefined(), js.Undefined()
+	s.vboSize = 0
+}
+
 // AddTriangles adds the provided vertices and indices to the current scene.
 func (s *Scene) AddTriangles(vertices []float32, indices []float32) {
 }
@@ -290,6 +709,7 @@ func (s *Scene) AddVertices(vertices []float32) {
 	s.vboSize += len(vertices) / 5
 	log.Infof("s.vboSize %d/%d [%d bytes/item]", len(vertices), v.Length(), v.Get("BYTES_PER_ELEMENT").Int())
 	gl.Call("bufferData", ARRAY_BUFFER, v, STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*4)
 
 	gl.Call("vertexAttribPointer", 0, 3, GLFLOAT, false, 5*4, 0)
 	gl.Call("enableVertexAttribArray", 0)
@@ -300,6 +720,96 @@ func (s *Scene) AddVertices(vertices []float32) {
 	gl.Call("bindVertexArray", nil)
 }
 
+// AddLitVertices adds the provided vertices array to the scene, including the
+// light color of each vertex. The vertices array is expected to have 8
+// elements per vertex: the x,y,z coordinate, the texture coordinate, and the
+// r,g,b light color, passed to the shaders at location 2.
+func (s *Scene) AddLitVertices(vertices []float32) {
+	s.allocateBuffers()
+
+	ARRAY_BUFFER := gl.Get("ARRAY_BUFFER").Int()
+	STATIC_DRAW := gl.Get("STATIC_DRAW").Int()
+	GLFLOAT := gl.Get("FLOAT")
+
+	gl.Call("bindVertexArray", s.vao)
+	gl.Call("bindBuffer", ARRAY_BUFFER, s.vbo)
+
+	s.vboSize += len(vertices) / 8
+	s.lit = true
+	gl.Call("bufferData", ARRAY_BUFFER, toFloat32Array(vertices), STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*4)
+
+	gl.Call("vertexAttribPointer", 0, 3, GLFLOAT, false, 8*4, 0)
+	gl.Call("enableVertexAttribArray", 0)
+
+	gl.Call("vertexAttribPointer", 1, 2, GLFLOAT, false, 8*4, 3*4)
+	gl.Call("enableVertexAttribArray", 1)
+
+	gl.Call("vertexAttribPointer", 2, 3, GLFLOAT, false, 8*4, 5*4)
+	gl.Call("enableVertexAttribArray", 2)
+
+	gl.Call("bindVertexArray", nil)
+}
+
+// AddMappedVertices adds the provided vertices array to the scene, including
+// the light color and the tangent space used for normal mapping. The vertices
+// array is expected to have VertexSizeMapped elements per vertex: the x,y,z
+// coordinate, the texture coordinate, the r,g,b light color, the normal and
+// the tangent, passed to the shaders at locations 0 to 4.
+func (s *Scene) AddMappedVertices(vertices []float32) {
+	s.allocateBuffers()
+
+	ARRAY_BUFFER := gl.Get("ARRAY_BUFFER").Int()
+	STATIC_DRAW := gl.Get("STATIC_DRAW").Int()
+	GLFLOAT := gl.Get("FLOAT")
+
+	gl.Call("bindVertexArray", s.vao)
+	gl.Call("bindBuffer", ARRAY_BUFFER, s.vbo)
+
+	s.vboSize += len(vertices) / VertexSizeMapped
+	s.lit, s.mapped = true, true
+	gl.Call("bufferData", ARRAY_BUFFER, toFloat32Array(vertices), STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*4)
+
+	stride := VertexSizeMapped * 4
+	for i, size := range []int{3, 2, 3, 3, 3} {
+		offset := []int{0, 3, 5, 8, 11}[i] * 4
+		gl.Call("vertexAttribPointer", i, size, GLFLOAT, false, stride, offset)
+		gl.Call("enableVertexAttribArray", i)
+	}
+
+	gl.Call("bindVertexArray", nil)
+}
+
+// AddTintedVertices adds the provided vertices array to the scene, including
+// the light color and the biome tint of each vertex. The vertices array is
+// expected to have VertexSizeTinted elements per vertex: the x,y,z coordinate,
+// the texture coordinate, the r,g,b light color, passed at location 2, and the
+// r,g,b tint color, passed at location 5.
+func (s *Scene) AddTintedVertices(vertices []float32) {
+	s.allocateBuffers()
+
+	ARRAY_BUFFER := gl.Get("ARRAY_BUFFER").Int()
+	STATIC_DRAW := gl.Get("STATIC_DRAW").Int()
+	GLFLOAT := gl.Get("FLOAT")
+
+	gl.Call("bindVertexArray", s.vao)
+	gl.Call("bindBuffer", ARRAY_BUFFER, s.vbo)
+
+	s.vboSize += len(vertices) / VertexSizeTinted
+	s.lit, s.tinted = true, true
+	gl.Call("bufferData", ARRAY_BUFFER, toFloat32Array(vertices), STATIC_DRAW)
+	trackBufferBytes(&s.vboBytes, len(vertices)*4)
+
+	stride := VertexSizeTinted * 4
+	for _, a := range [][3]int{{0, 3, 0}, {1, 2, 3}, {2, 3, 5}, {5, 3, 8}} {
+		gl.Call("vertexAttribPointer", a[0], a[1], GLFLOAT, false, stride, a[2]*4)
+		gl.Call("enableVertexAttribArray", a[0])
+	}
+
+	gl.Call("bindVertexArray", nil)
+}
+
 func toFloat32Array(in []float32) (out js.Value) {
 	out = js.Global().Get("Float32Array").New(len(in))
 	for k, v := range in {
@@ -313,7 +823,7 @@ func (s *Scene) AddTexture(tex *Texture) {
 }
 
 func (s *Scene) Clear() {
-	gl.Call("enable", gl.Get("DEPTH_TEST").Int())
+	prepareClear()
 	if s.clearColor == nil {
 		s.clearColor = BgColor
 	}
@@ -323,27 +833,657 @@ func (s *Scene) Clear() {
 }
 
 func (s *Scene) Draw(shader *Shader) {
+	s.DrawState(nil, shader, DefaultPipeline)
+}
+
+// DrawState renders the scene like Draw, using the provided pipeline state
+// and the camera matrices of the frame. A nil frame uses the scene camera.
+func (s *Scene) DrawState(f *Frame, shader *Shader, state PipelineState) {
 	s.allocateBuffers()
 
 	if shader != nil {
 		shader.Use()
+		s.setCamera(shader, f)
 	}
+	state.Apply()
 
 	if s.tex != nil {
 		gl.Call("activeTexture", gl.Get("TEXTURE0").Int())
 		gl.Call("bindTexture", gl.Get("TEXTURE_2D").Int(), s.tex.tex)
+		countTextureBind()
+	}
+
+	if !s.lit {
+		// Vertices without light color are fully lit
+		gl.Call("vertexAttrib3f", 2, 1, 1, 1)
+	}
+	if !s.mapped {
+		// Vertices without tangent space face up, with a flat normal map
+		gl.Call("vertexAttrib3f", 3, 0, 1, 0)
+		gl.Call("vertexAttrib3f", 4, 1, 0, 0)
+	}
+	if !s.tinted {
+		gl.Call("vertexAttrib3f", 5, 1, 1, 1)
 	}
 
 	gl.Call("bindVertexArray", s.vao)
 	gl.Call("drawArrays", gl.Get("TRIANGLES").Int(), 0, s.vboSize)
+	countDraw(s.vboSize)
 	gl.Call("bindVertexArray", nil)
 }
 
+// UniformBuffer holds data shared by shader programs through uniform blocks.
+type UniformBuffer struct {
+	ubo  js.Value
+	size int
+}
+
+// NewUniformBuffer allocates an uniform buffer with space for size floats.
+func NewUniformBuffer(size int) *UniformBuffer {
+	b := &UniformBuffer{}
+	UNIFORM_BUFFER := gl.Get("UNIFORM_BUFFER").Int()
+	b.ubo = gl.Call("createBuffer")
+	trackAlloc(resBuffer, 1)
+	gl.Call("bindBuffer", UNIFORM_BUFFER, b.ubo)
+	gl.Call("bufferData", UNIFORM_BUFFER, size*4, gl.Get("DYNAMIC_DRAW").Int())
+	trackBufferBytes(&b.size, size*4)
+	gl.Call("bindBuffer", UNIFORM_BUFFER, nil)
+	return b
+}
+
+// Update replaces the start of the buffer with the provided data.
+func (b *UniformBuffer) Update(data []float32) {
+	UNIFORM_BUFFER := gl.Get("UNIFORM_BUFFER").Int()
+	gl.Call("bindBuffer", UNIFORM_BUFFER, b.ubo)
+	gl.Call("bufferSubData", UNIFORM_BUFFER, 0, toFloat32Array(data))
+	gl.Call("bindBuffer", UNIFORM_BUFFER, nil)
+}
+
+// Bind attaches the buffer to the binding point used by the uniform blocks.
+func (b *UniformBuffer) Bind(binding uint32) {
+	gl.Call("bindBufferBase", gl.Get("UNIFORM_BUFFER").Int(), binding, b.ubo)
+}
+
+// Delete releases the buffer from the GPU memory.
+func (b *UniformBuffer) Delete() {
+	if b.ubo.IsNull() || b.ubo.IsUndefined() {
+		return
+	}
+	gl.Call("deleteBuffer", b.ubo)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&b.size, 0)
+	b.ubo = js.Undefined()
+}
+
 type Texture struct {
 	tex    js.Value
 	pixels []uint8
 }
 
+// Bind makes the texture available to the shaders at the texture unit.
+func (t *Texture) Bind(unit int) {
+	gl.Call("activeTexture", gl.Get("TEXTURE0").Int()+unit)
+	gl.Call("bindTexture", gl.Get("TEXTURE_2D").Int(), t.tex)
+	countTextureBind()
+}
+
+// newEmptyTexture allocates a texture without data, to be used as a render
+// target.
+func newEmptyTexture(width, height int, format TextureFormat) *Texture {
+	TEXTURE_2D := gl.Get("TEXTURE_2D").Int()
+	t := &Texture{}
+	t.tex = gl.Call("createTexture")
+	trackAlloc(resTexture, 1)
+	gl.Call("bindTexture", TEXTURE_2D, t.tex)
+	gl.Call("texParameteri", TEXTURE_2D, gl.Get("TEXTURE_MIN_FILTER").Int(), gl.Get("NEAREST").Int())
+	gl.Call("texParameteri", TEXTURE_2D, gl.Get("TEXTURE_MAG_FILTER").Int(), gl.Get("NEAREST").Int())
+	gl.Call("texParameteri", TEXTURE_2D, gl.Get("TEXTURE_WRAP_S").Int(), gl.Get("CLAMP_TO_EDGE").Int())
+	gl.Call("texParameteri", TEXTURE_2D, gl.Get("TEXTURE_WRAP_T").Int(), gl.Get("CLAMP_TO_EDGE").Int())
+
+	var internal, pixFormat, pixType string
+	switch format {
+	case FormatRGBA16F:
+		internal, pixFormat, pixType = "RGBA16F", "RGBA", "HALF_FLOAT"
+	case FormatRGBA32F:
+		internal, pixFormat, pixType = "RGBA32F", "RGBA", "FLOAT"
+	case FormatDepth:
+		internal, pixFormat, pixType = "DEPTH_COMPONENT24", "DEPTH_COMPONENT", "UNSIGNED_INT"
+	default:
+		internal, pixFormat, pixType = "RGBA8", "RGBA", "UNSIGNED_BYTE"
+	}
+	gl.Call("texImage2D", TEXTURE_2D, 0, gl.Get(internal).Int(), width, height, 0,
+		gl.Get(pixFormat).Int(), gl.Get(pixType).Int(), nil)
+	gl.Call("bindTexture", TEXTURE_2D, nil)
+	return t
+}
+
+// Framebuffer is an off-screen render target, with one or more color
+// textures and a depth texture.
+type Framebuffer struct {
+	Width, Height int
+
+	fbo     js.Value
+	formats []TextureFormat
+	color   []*Texture
+	depth   *Texture
+}
+
+// NewFramebuffer creates a framebuffer of the given size, with one color
+// texture for each of the provided formats and a depth texture. Float formats
+// require the EXT_color_buffer_float extension.
+func NewFramebuffer(width, height int, formats ...TextureFormat) (*Framebuffer, error) {
+	for _, format := range formats {
+		if (format == FormatRGBA16F || format == FormatRGBA32F) && gl.Call("getExtension", "EXT_color_buffer_float").IsNull() {
+			return nil, fmt.Errorf("framebuffer: float textures not supported: %w", ErrNotImplemented)
+		}
+	}
+	f := &Framebuffer{formats: formats}
+	f.fbo = gl.Call("createFramebuffer")
+	trackAlloc(resFramebuffer, 1)
+	if err := f.Resize(width, height); err != nil {
+		f.Delete()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Resize recreates the framebuffer textures with the new size.
+func (f *Framebuffer) Resize(width, height int) error {
+	FRAMEBUFFER := gl.Get("FRAMEBUFFER").Int()
+	TEXTURE_2D := gl.Get("TEXTURE_2D").Int()
+	COLOR_ATTACHMENT0 := gl.Get("COLOR_ATTACHMENT0").Int()
+
+	f.deleteTextures()
+	f.Width, f.Height = width, height
+
+	gl.Call("bindFramebuffer", FRAMEBUFFER, f.fbo)
+	defer gl.Call("bindFramebuffer", FRAMEBUFFER, nil)
+
+	drawBuffers := []any{}
+	for i, format := range f.formats {
+		t := newEmptyTexture(width, height, format)
+		gl.Call("framebufferTexture2D", FRAMEBUFFER, COLOR_ATTACHMENT0+i, TEXTURE_2D, t.tex, 0)
+		f.color = append(f.color, t)
+		drawBuffers = append(drawBuffers, COLOR_ATTACHMENT0+i)
+	}
+	if len(drawBuffers) == 0 {
+		drawBuffers = append(drawBuffers, gl.Get("NONE").Int())
+	}
+	gl.Call("drawBuffers", js.ValueOf(drawBuffers))
+	f.depth = newEmptyTexture(width, height, FormatDepth)
+	gl.Call("framebufferTexture2D", FRAMEBUFFER, gl.Get("DEPTH_ATTACHMENT").Int(), TEXTURE_2D, f.depth.tex, 0)
+
+	status := gl.Call("checkFramebufferStatus", FRAMEBUFFER).Int()
+	if status != gl.Get("FRAMEBUFFER_COMPLETE").Int() {
+		return fmt.Errorf("framebuffer: incomplete (status=0x%x)", status)
+	}
+	return nil
+}
+
+// Bind makes the framebuffer the current render target, and sets the viewport
+// to its size.
+func (f *Framebuffer) Bind() {
+	gl.Call("bindFramebuffer", gl.Get("FRAMEBUFFER").Int(), f.fbo)
+	gl.Call("viewport", 0, 0, f.Width, f.Height)
+}
+
+// Clear resets the color textures to transparent black and the depth texture
+// to the far plane. The framebuffer must be bound.
+func (f *Framebuffer) Clear() {
+	prepareClear()
+	gl.Call("clearColor", 0, 0, 0, 0)
+	gl.Call("clear", gl.Get("COLOR_BUFFER_BIT").Int()|gl.Get("DEPTH_BUFFER_BIT").Int())
+}
+
+// Color returns the i-th color texture.
+func (f *Framebuffer) Color(i int) *Texture {
+	return f.color[i]
+}
+
+// ReadPixels copies the i-th color texture, which must use FormatRGBA8, into an
+// image with the first row at the top. It waits for the GPU to finish
+// rendering, so it should not be called every frame.
+func (f *Framebuffer) ReadPixels(i int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	arr := js.Global().Get("Uint8Array").New(len(img.Pix))
+	gl.Call("bindFramebuffer", gl.Get("READ_FRAMEBUFFER").Int(), f.fbo)
+	gl.Call("readBuffer", gl.Get("COLOR_ATTACHMENT0").Int()+i)
+	gl.Call("pixelStorei", gl.Get("PACK_ALIGNMENT").Int(), 1)
+	gl.Call("readPixels", 0, 0, f.Width, f.Height, gl.Get("RGBA").Int(), gl.Get("UNSIGNED_BYTE").Int(), arr)
+	gl.Call("bindFramebuffer", gl.Get("READ_FRAMEBUFFER").Int(), nil)
+	js.CopyBytesToGo(img.Pix, arr)
+	flipRows(img)
+	return img
+}
+
+// Depth returns the depth texture.
+func (f *Framebuffer) Depth() *Texture {
+	return f.depth
+}
+
+func (f *Framebuffer) deleteTextures() {
+	for _, t := range f.color {
+		t.Delete()
+	}
+	f.color = nil
+	if f.depth != nil {
+		f.depth.Delete()
+		f.depth = nil
+	}
+}
+
+// Delete releases the framebuffer and its textures.
+func (f *Framebuffer) Delete() {
+	f.deleteTextures()
+	if !f.fbo.IsNull() && !f.fbo.IsUndefined() {
+		gl.Call("deleteFramebuffer", f.fbo)
+		trackFree(resFramebuffer, 1)
+		f.fbo = js.Undefined()
+	}
+}
+
+// CubeMap is a cube texture that can also be used as a render target, one face
+// at a time.
+type CubeMap struct {
+	Size int
+
+	tex   js.Value
+	fbo   js.Value
+	depth js.Value
+}
+
+// NewCubeMap allocates a cube map with square faces of the provided size.
+func NewCubeMap(size int) (*CubeMap, error) {
+	TEXTURE_CUBE_MAP := gl.Get("TEXTURE_CUBE_MAP").Int()
+	POSITIVE_X := gl.Get("TEXTURE_CUBE_MAP_POSITIVE_X").Int()
+	FRAMEBUFFER := gl.Get("FRAMEBUFFER").Int()
+	RENDERBUFFER := gl.Get("RENDERBUFFER").Int()
+
+	c := &CubeMap{Size: size}
+	c.tex = gl.Call("createTexture")
+	trackAlloc(resTexture, 1)
+	gl.Call("bindTexture", TEXTURE_CUBE_MAP, c.tex)
+	for face := 0; face < 6; face++ {
+		gl.Call("texImage2D", POSITIVE_X+face, 0, gl.Get("RGBA8").Int(), size, size, 0,
+			gl.Get("RGBA").Int(), gl.Get("UNSIGNED_BYTE").Int(), nil)
+	}
+	for _, p := range []string{"TEXTURE_MIN_FILTER", "TEXTURE_MAG_FILTER"} {
+		gl.Call("texParameteri", TEXTURE_CUBE_MAP, gl.Get(p).Int(), gl.Get("LINEAR").Int())
+	}
+	for _, p := range []string{"TEXTURE_WRAP_S", "TEXTURE_WRAP_T", "TEXTURE_WRAP_R"} {
+		gl.Call("texParameteri", TEXTURE_CUBE_MAP, gl.Get(p).Int(), gl.Get("CLAMP_TO_EDGE").Int())
+	}
+	gl.Call("bindTexture", TEXTURE_CUBE_MAP, nil)
+
+	c.fbo = gl.Call("createFramebuffer")
+	trackAlloc(resFramebuffer, 1)
+	c.depth = gl.Call("createRenderbuffer")
+	trackAlloc(resBuffer, 1)
+	gl.Call("bindRenderbuffer", RENDERBUFFER, c.depth)
+	gl.Call("renderbufferStorage", RENDERBUFFER, gl.Get("DEPTH_COMPONENT24").Int(), size, size)
+	gl.Call("bindFramebuffer", FRAMEBUFFER, c.fbo)
+	gl.Call("framebufferRenderbuffer", FRAMEBUFFER, gl.Get("DEPTH_ATTACHMENT").Int(), RENDERBUFFER, c.depth)
+	gl.Call("framebufferTexture2D", FRAMEBUFFER, gl.Get("COLOR_ATTACHMENT0").Int(), POSITIVE_X, c.tex, 0)
+	status := gl.Call("checkFramebufferStatus", FRAMEBUFFER).Int()
+	gl.Call("bindFramebuffer", FRAMEBUFFER, nil)
+	if status != gl.Get("FRAMEBUFFER_COMPLETE").Int() {
+		c.Delete()
+		return nil, fmt.Errorf("cubemap: incomplete framebuffer (status=0x%x)", status)
+	}
+	return c, nil
+}
+
+// BindFace makes the face, in the order +X, -X, +Y, -Y, +Z, -Z, the current
+// render target.
+func (c *CubeMap) BindFace(face int) {
+	FRAMEBUFFER := gl.Get("FRAMEBUFFER").Int()
+	gl.Call("bindFramebuffer", FRAMEBUFFER, c.fbo)
+	gl.Call("framebufferTexture2D", FRAMEBUFFER, gl.Get("COLOR_ATTACHMENT0").Int(),
+		gl.Get("TEXTURE_CUBE_MAP_POSITIVE_X").Int()+face, c.tex, 0)
+	gl.Call("viewport", 0, 0, c.Size, c.Size)
+}
+
+// Bind makes the cube map available to the shaders at the texture unit.
+func (c *CubeMap) Bind(unit int) {
+	gl.Call("activeTexture", gl.Get("TEXTURE0").Int()+unit)
+	gl.Call("bindTexture", gl.Get("TEXTURE_CUBE_MAP").Int(), c.tex)
+	countTextureBind()
+}
+
+// Delete releases the cube map texture and render target.
+func (c *CubeMap) Delete() {
+	if !c.tex.IsUndefined() {
+		gl.Call("deleteTexture", c.tex)
+		trackFree(resTexture, 1)
+		c.tex = js.Undefined()
+	}
+	if !c.fbo.IsUndefined() {
+		gl.Call("deleteFramebuffer", c.fbo)
+		trackFree(resFramebuffer, 1)
+		c.fbo = js.Undefined()
+	}
+	if !c.depth.IsUndefined() {
+		gl.Call("deleteRenderbuffer", c.depth)
+		trackFree(resBuffer, 1)
+		c.depth = js.Undefined()
+	}
+}
+
+// DynamicMesh is a small vertex buffer updated frequently, such as overlays
+// rebuilt every time they change. Vertices have 5 elements: the x,y,z
+// coordinate and the texture coordinate.
+type DynamicMesh struct {
+	vao   js.Value
+	vbo   js.Value
+	count int
+	bytes int
+}
+
+// NewDynamicMesh allocates an empty dynamic mesh.
+func NewDynamicMesh() *DynamicMesh {
+	ARRAY_BUFFER := gl.Get("ARRAY_BUFFER").Int()
+	GLFLOAT := gl.Get("FLOAT")
+
+	m := &DynamicMesh{}
+	m.vao = gl.Call("createVertexArray")
+	m.vbo = gl.Call("createBuffer")
+	trackAlloc(resVertexArray, 1)
+	trackAlloc(resBuffer, 1)
+	gl.Call("bindVertexArray", m.vao)
+	gl.Call("bindBuffer", ARRAY_BUFFER, m.vbo)
+	gl.Call("vertexAttribPointer", 0, 3, GLFLOAT, false, 5*4, 0)
+	gl.Call("enableVertexAttribArray", 0)
+	gl.Call("vertexAttribPointer", 1, 2, GLFLOAT, false, 5*4, 3*4)
+	gl.Call("enableVertexAttribArray", 1)
+	gl.Call("bindVertexArray", nil)
+	return m
+}
+
+// Update replaces the mesh vertices.
+func (m *DynamicMesh) Update(vertices []float32) {
+	m.count = len(vertices) / 5
+	if m.count == 0 {
+		return
+	}
+	ARRAY_BUFFER := gl.Get("ARRAY_BUFFER").Int()
+	gl.Call("bindBuffer", ARRAY_BUFFER, m.vbo)
+	gl.Call("bufferData", ARRAY_BUFFER, toFloat32Array(vertices), gl.Get("DYNAMIC_DRAW").Int())
+	trackBufferBytes(&m.bytes, len(vertices)*4)
+}
+
+// Draw renders the mesh triangles with the current shader program.
+func (m *DynamicMesh) Draw() {
+	if m.count == 0 {
+		return
+	}
+	gl.Call("bindVertexArray", m.vao)
+	gl.Call("drawArrays", gl.Get("TRIANGLES").Int(), 0, m.count)
+	countDraw(m.count)
+	gl.Call("bindVertexArray", nil)
+}
+
+// Delete releases the mesh buffers.
+func (m *DynamicMesh) Delete() {
+	if m.vao.IsUndefined() {
+		return
+	}
+	gl.Call("deleteVertexArray", m.vao)
+	gl.Call("deleteBuffer", m.vbo)
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&m.bytes, 0)
+	m.vao, m.vbo, m.count = js.Undefined(), js.Undefined(), 0
+}
+
+// MeshBuffer is a large vertex buffer shared by many meshes, drawn one range
+// at a time.
+type MeshBuffer struct {
+	layout   VertexLayout
+	vao, vbo js.Value
+	size     int
+}
+
+// NewMeshBuffer allocates a vertex buffer with size bytes using the layout.
+func NewMeshBuffer(layout VertexLayout, size int) *MeshBuffer {
+	b := &MeshBuffer{layout: layout, vbo: js.Undefined()}
+	b.vao = gl.Call("createVertexArray")
+	trackAlloc(resVertexArray, 1)
+	b.reallocate(size, nil)
+	return b
+}
+
+// reallocate replaces the buffer with a new one of the provided size, copying
+// the ranges of the old buffer described by moves.
+func (b *MeshBuffer) reallocate(size int, moves []ArenaMove) {
+	ARRAY_BUFFER := gl.Get("ARRAY_BUFFER").Int()
+	COPY_READ_BUFFER := gl.Get("COPY_READ_BUFFER").Int()
+	GLFLOAT := gl.Get("FLOAT")
+
+	vbo := gl.Call("createBuffer")
+	trackAlloc(resBuffer, 1)
+	gl.Call("bindBuffer", ARRAY_BUFFER, vbo)
+	gl.Call("bufferData", ARRAY_BUFFER, size, gl.Get("DYNAMIC_DRAW").Int())
+	if !b.vbo.IsUndefined() {
+		gl.Call("bindBuffer", COPY_READ_BUFFER, b.vbo)
+		for _, m := range moves {
+			gl.Call("copyBufferSubData", COPY_READ_BUFFER, ARRAY_BUFFER, m.From, m.To, m.Size)
+		}
+		gl.Call("deleteBuffer", b.vbo)
+		trackFree(resBuffer, 1)
+	}
+	b.vbo = vbo
+	trackBufferBytes(&b.size, size)
+
+	gl.Call("bindVertexArray", b.vao)
+	for _, a := range b.layout.Attribs {
+		if a.Type == AttribUint {
+			gl.Call("vertexAttribIPointer", a.Location, a.Size, gl.Get("UNSIGNED_INT").Int(), b.layout.Stride, a.Offset)
+		} else {
+			gl.Call("vertexAttribPointer", a.Location, a.Size, GLFLOAT, false, b.layout.Stride, a.Offset)
+		}
+		gl.Call("enableVertexAttribArray", a.Location)
+	}
+	gl.Call("bindVertexArray", nil)
+}
+
+// Size returns the buffer capacity, in bytes.
+func (b *MeshBuffer) Size() int {
+	return b.size
+}
+
+// Grow enlarges the buffer to size bytes, keeping its contents.
+func (b *MeshBuffer) Grow(size int) {
+	if size > b.size {
+		b.reallocate(size, []ArenaMove{{From: 0, To: 0, Size: b.size}})
+	}
+}
+
+// Relocate moves the ranges of the buffer, as returned by Arena.Compact.
+func (b *MeshBuffer) Relocate(moves []ArenaMove) {
+	b.reallocate(b.size, moves)
+}
+
+// Write copies the data into the buffer at the offset, in bytes.
+func (b *MeshBuffer) Write(offset int, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	ARRAY_BUFFER := gl.Get("ARRAY_BUFFER").Int()
+	arr := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(arr, data)
+	gl.Call("bindBuffer", ARRAY_BUFFER, b.vbo)
+	gl.Call("bufferSubData", ARRAY_BUFFER, offset, arr)
+}
+
+// Bind prepares the buffer for DrawRange calls.
+func (b *MeshBuffer) Bind() {
+	gl.Call("bindVertexArray", b.vao)
+}
+
+// DrawRange draws count vertices starting at first. The buffer must be bound.
+func (b *MeshBuffer) DrawRange(first, count int) {
+	gl.Call("drawArrays", gl.Get("TRIANGLES").Int(), first, count)
+	countDraw(count)
+}
+
+// setChunkOffset sets the per-draw chunk offset attribute used by DrawRange.
+func (b *MeshBuffer) setChunkOffset(x, y, z float32) {
+	gl.Call("vertexAttrib3f", ChunkOffsetLocation, x, y, z)
+}
+
+// drawIndirect is not available on WebGL, which has no indirect draws.
+func (b *MeshBuffer) drawIndirect(cmds []DrawCommand, offsets []float32) bool {
+	return false
+}
+
+// Delete releases the buffer.
+func (b *MeshBuffer) Delete() {
+	if b.vao.IsUndefined() {
+		return
+	}
+	gl.Call("deleteVertexArray", b.vao)
+	gl.Call("deleteBuffer", b.vbo)
+	trackFree(resVertexArray, 1)
+	trackFree(resBuffer, 1)
+	trackBufferBytes(&b.size, 0)
+	b.vao, b.vbo = js.Undefined(), js.Undefined()
+}
+
+// ComputeMesher builds chunk meshes on the GPU. WebGL has no compute
+// shaders, so it is never available.
+type ComputeMesher struct{}
+
+// ComputeMeshingSupported returns false, as WebGL has no compute shaders.
+func ComputeMeshingSupported() bool {
+	return false
+}
+
+// NewComputeMesher returns ErrNotImplemented.
+func NewComputeMesher() (*ComputeMesher, error) {
+	return nil, ErrNotImplemented
+}
+
+// Mesh returns ErrNotImplemented.
+func (m *ComputeMesher) Mesh(dims [3]int, blocks, opaque []uint32, dst *MeshBuffer, offset int) (int, error) {
+	return 0, ErrNotImplemented
+}
+
+// Delete does nothing.
+func (m *ComputeMesher) Delete() {}
+
+// SetShaderCacheDir does nothing: WebGL does not expose program binaries, and
+// browsers already cache compiled shaders.
+func SetShaderCacheDir(dir string) {}
+
+// clearDepth resets the depth buffer of the current render target.
+func clearDepth() {
+	prepareClear()
+	gl.Call("clear", gl.Get("DEPTH_BUFFER_BIT").Int())
+}
+
+// setClipControl switches the clip depth range to [0, 1] for the reversed
+// depth, or back to [-1, 1]. It returns false if the EXT_clip_control
+// extension is not available.
+func setClipControl(zeroToOne bool) bool {
+	ext := gl.Call("getExtension", "EXT_clip_control")
+	if ext.IsNull() {
+		return false
+	}
+	depth := ext.Get("NEGATIVE_ONE_TO_ONE_EXT")
+	if zeroToOne {
+		depth = ext.Get("ZERO_TO_ONE_EXT")
+	}
+	ext.Call("clipControlEXT", ext.Get("LOWER_LEFT_EXT"), depth)
+	return true
+}
+
+// setClearDepth changes the value used to clear the depth buffer.
+func setClearDepth(v float32) {
+	gl.Call("clearDepth", v)
+}
+
+// enableCap enables or disables a WebGL capability.
+func enableCap(name string, enabled bool) {
+	if enabled {
+		gl.Call("enable", gl.Get(name).Int())
+	} else {
+		gl.Call("disable", gl.Get(name).Int())
+	}
+}
+
+// applyPipeline issues the calls to change the state from old to p. A nil old
+// state sets everything. WebGL has no polygon mode, so Wireframe is ignored.
+func applyPipeline(old *PipelineState, p PipelineState) {
+	if old == nil || old.Blend != p.Blend {
+		enableCap("BLEND", p.Blend != BlendNone)
+		switch p.Blend {
+		case BlendAlpha:
+			gl.Call("blendFunc", gl.Get("SRC_ALPHA").Int(), gl.Get("ONE_MINUS_SRC_ALPHA").Int())
+		case BlendAdditive:
+			gl.Call("blendFunc", gl.Get("SRC_ALPHA").Int(), gl.Get("ONE").Int())
+		}
+	}
+	if old == nil || old.DepthTest != p.DepthTest {
+		enableCap("DEPTH_TEST", p.DepthTest)
+	}
+	if old == nil || old.DepthWrite != p.DepthWrite {
+		gl.Call("depthMask", p.DepthWrite)
+	}
+	if old == nil || old.DepthFunc != p.DepthFunc {
+		name := [...]string{"LESS", "LEQUAL", "EQUAL", "GREATER", "GEQUAL"}[p.DepthFunc.effective()]
+		gl.Call("depthFunc", gl.Get(name).Int())
+	}
+	if old == nil || old.NoColorWrite != p.NoColorWrite {
+		c := !p.NoColorWrite
+		gl.Call("colorMask", c, c, c, c)
+	}
+	if old == nil || old.Cull != p.Cull {
+		enableCap("CULL_FACE", p.Cull != CullNone)
+		switch p.Cull {
+		case CullBack:
+			gl.Call("cullFace", gl.Get("BACK").Int())
+		case CullFront:
+			gl.Call("cullFace", gl.Get("FRONT").Int())
+		}
+	}
+	if old == nil || old.PolygonOffset != p.PolygonOffset {
+		enableCap("POLYGON_OFFSET_FILL", p.PolygonOffset != 0)
+		gl.Call("polygonOffset", p.PolygonOffset, p.PolygonOffset)
+	}
+}
+
+// BindDefaultFramebuffer makes the canvas the current render target, limited
+// to the region set with SetViewport.
+func (w *Window) BindDefaultFramebuffer() {
+	gl.Call("bindFramebuffer", gl.Get("FRAMEBUFFER").Int(), nil)
+	r := w.Viewport()
+	y := w.Height - r.Max.Y
+	gl.Call("viewport", r.Min.X, y, r.Dx(), r.Dy())
+	// Scissor the clears too, so each viewport clears only its region.
+	if r == image.Rect(0, 0, w.Width, w.Height) {
+		gl.Call("disable", gl.Get("SCISSOR_TEST").Int())
+		return
+	}
+	gl.Call("enable", gl.Get("SCISSOR_TEST").Int())
+	gl.Call("scissor", r.Min.X, y, r.Dx(), r.Dy())
+}
+
+// fullscreenVAO is an empty vertex array used to draw a fullscreen triangle,
+// with the vertices generated in the shader from gl_VertexID.
+var fullscreenVAO js.Value
+
+// DrawFullscreen draws a single triangle covering the whole viewport. It is
+// used by post-processing passes together with FullscreenVertexGLSL.
+func DrawFullscreen() {
+	if fullscreenVAO.IsUndefined() {
+		fullscreenVAO = gl.Call("createVertexArray")
+	}
+	gl.Call("bindVertexArray", fullscreenVAO)
+	gl.Call("drawArrays", gl.Get("TRIANGLES").Int(), 0, 3)
+	countDraw(3)
+	gl.Call("bindVertexArray", nil)
+}
+
 func NewTexture(path string) (t *Texture, err error) {
 	return nil, ErrNotImplemented
 }
@@ -359,6 +1499,7 @@ func NewTextureFromBytes(b []byte) (t *Texture, err error) {
 		pixels: pixels,
 	}
 	t.tex = gl.Call("createTexture")
+	trackAlloc(resTexture, 1)
 	gl.Call("activeTexture", gl.Get("TEXTURE0").Int())
 	gl.Call("bindTexture", gl.Get("TEXTURE_2D").Int(), t.tex)
 
@@ -371,8 +1512,29 @@ func NewTextureFromBytes(b []byte) (t *Texture, err error) {
 	gl.Call("texParameteri", gl.Get("TEXTURE_2D").Int(),
 		gl.Get("TEXTURE_MAG_FILTER").Int(), gl.Get("NEAREST").Int())
 
-	jsPix := js.Global().Call("eval", fmt.Sprintf("new Uint8Array(%d)", len(pixels)))
-	log.Infof("js.CopyBytesToJS copied %d/%d bytes", js.CopyBytesToJS(jsPix, pixels), len(pixels))
+	t.upload(w, h)
+	return t, nil
+}
+
+// Reload replaces the image of the texture with the image file in b, keeping
+// the same texture object, so the scenes and materials using it show the new
+// image. On errors, returned as a TextureError, the old image is kept.
+func (t *Texture) Reload(b []byte) error {
+	w, h, pixels, err := decodeImage(b)
+	if err != nil {
+		return err
+	}
+	t.pixels = pixels
+	gl.Call("activeTexture", gl.Get("TEXTURE0").Int())
+	gl.Call("bindTexture", gl.Get("TEXTURE_2D").Int(), t.tex)
+	t.upload(w, h)
+	return nil
+}
+
+// upload sends the pixels to the bound texture.
+func (t *Texture) upload(w, h int) {
+	jsPix := js.Global().Call("eval", fmt.Sprintf("new Uint8Array(%d)", len(t.pixels)))
+	log.Infof("js.CopyBytesToJS copied %d/%d bytes", js.CopyBytesToJS(jsPix, t.pixels), len(t.pixels))
 	gl.Call("texImage2D",
 		gl.Get("TEXTURE_2D").Int(),
 		0,
@@ -384,24 +1546,14 @@ func NewTextureFromBytes(b []byte) (t *Texture, err error) {
 		gl.Get("UNSIGNED_BYTE").Int(),
 		jsPix)
 	gl.Call("generateMipmap", gl.Get("TEXTURE_2D").Int())
-	return t, nil
 }
 
-func decodeImage(b []byte) (w, h int, px []uint8, err error) {
-	img, ftype, err := image.Decode(bytes.NewReader(b))
-	if err != nil {
-		return 0, 0, nil, err
+// Delete releases the texture from the GPU memory.
+func (t *Texture) Delete() {
+	if t.tex.IsNull() || t.tex.IsUndefined() {
+		return
 	}
-	img = imaging.FlipV(img)
-	w, h = img.Bounds().Size().X, img.Bounds().Size().Y
-	log.Infof("Loaded %v image (%dx%d) from %v bytes", ftype, w, h, len(b))
-
-	// Create pixel data from PNG
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return 0, 0, nil, fmt.Errorf("unsupported stride")
-	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
-
-	return rgba.Rect.Size().X, rgba.Rect.Size().Y, rgba.Pix, nil
+	gl.Call("deleteTexture", t.tex)
+	trackFree(resTexture, 1)
+	t.tex = js.Undefined()
 }
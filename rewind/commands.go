@@ -0,0 +1,82 @@
+package rewind
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ronoaldo/openvoxel/console"
+)
+
+// RegisterCommands adds the pause, resume, step and rewind commands to the
+// registry, controlling the recorder.
+func RegisterCommands(reg *console.Registry, r *Recorder) {
+	reg.Register(console.Command{
+		Name: "pause",
+		Help: "pauses the simulation",
+		Handler: func(args []string) (string, error) {
+			r.Pause()
+			return fmt.Sprintf("paused at tick %d", r.Now()), nil
+		},
+	})
+	reg.Register(console.Command{
+		Name: "resume",
+		Help: "resumes the simulation",
+		Handler: func(args []string) (string, error) {
+			r.Resume()
+			return fmt.Sprintf("resumed at tick %d", r.Now()), nil
+		},
+	})
+	reg.Register(console.Command{
+		Name:  "step",
+		Usage: "[ticks]",
+		Help:  "pauses the simulation and runs the ticks, one by default, up to the rewind span",
+		Handler: func(args []string) (string, error) {
+			n, err := ticks(args, 1, "step [ticks]")
+			if err != nil {
+				return "", err
+			}
+			if err := r.Step(int(n)); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("paused at tick %d", r.Now()), nil
+		},
+	})
+	reg.Register(console.Command{
+		Name:  "rewind",
+		Usage: "[ticks]",
+		Help:  "pauses the simulation and goes back the ticks, or shows the recorded range",
+		Handler: func(args []string) (string, error) {
+			if len(args) == 0 {
+				oldest, ok := r.Oldest()
+				if !ok {
+					return "nothing recorded", nil
+				}
+				return fmt.Sprintf("recorded from tick %d to %d", oldest, r.Now()), nil
+			}
+			n, err := ticks(args, 0, "rewind [ticks]")
+			if err != nil {
+				return "", err
+			}
+			now, err := r.Rewind(n)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("paused at tick %d", now), nil
+		},
+	})
+}
+
+// ticks parses the optional tick count argument.
+func ticks(args []string, def uint64, usage string) (uint64, error) {
+	switch len(args) {
+	case 0:
+		return def, nil
+	case 1:
+		n, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ticks: %v", args[0])
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("usage: %v", usage)
+}
@@ -0,0 +1,225 @@
+// package rewind records the simulation state while the game runs, so it can
+// be paused, stepped one tick at a time and rewound from the console, to
+// diagnose physics and fluid bugs.
+//
+// The state is recorded every few ticks into a ring buffer. Rewinding restores
+// the latest snapshot before the target tick and simulates the ticks up to it
+// again, which gives the exact state as long as the simulation is
+// deterministic.
+package rewind
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+)
+
+// ErrNotRecorded is returned when rewinding to a tick older than the oldest
+// snapshot.
+var ErrNotRecorded = errors.New("rewind: tick not recorded")
+
+// State is a part of the simulation recorded by the Recorder, such as the
+// world.Map, the tick.Scheduler or the fluid.Simulator.
+type State interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// Funcs adapts a pair of functions to a State, such as for the
+// physics.Controller snapshots.
+type Funcs struct {
+	Save func() ([]byte, error)
+	Load func(b []byte) error
+}
+
+func (f Funcs) MarshalBinary() ([]byte, error) { return f.Save() }
+func (f Funcs) UnmarshalBinary(b []byte) error { return f.Load(b) }
+
+type named struct {
+	name  string
+	state State
+}
+
+// snapshot is the state of all the recorded parts at a tick.
+type snapshot struct {
+	tick uint64
+	data [][]byte
+}
+
+// Recorder runs the simulation ticks, recording the state every Interval
+// ticks and keeping the last Capacity snapshots, so the memory used is
+// bounded. It is not safe for concurrent use, so the console commands must run
+// on the game update goroutine.
+type Recorder struct {
+	// Simulate runs a single simulation tick.
+	Simulate func()
+	// Interval is the number of ticks between snapshots, and Capacity the
+	// number of snapshots kept. The simulation can be rewound up to
+	// Interval*Capacity ticks.
+	Interval, Capacity int
+
+	states    []named
+	snapshots []snapshot
+	now       uint64
+	paused    bool
+}
+
+// New creates a recorder running the simulate function, recording a snapshot
+// every second, at 20 ticks per second, for the last minute.
+func New(simulate func()) *Recorder {
+	return &Recorder{Simulate: simulate, Interval: 20, Capacity: 60}
+}
+
+// Add records the state with the name, used in the errors. The states are
+// restored in the order they were added.
+func (r *Recorder) Add(name string, s State) {
+	r.states = append(r.states, named{name, s})
+}
+
+// Now returns the number of ticks simulated.
+func (r *Recorder) Now() uint64 {
+	return r.now
+}
+
+// Paused returns true while the simulation is paused.
+func (r *Recorder) Paused() bool {
+	return r.paused
+}
+
+// Pause stops the simulation, so Tick does nothing until Resume.
+func (r *Recorder) Pause() {
+	r.paused = true
+}
+
+// Resume continues the simulation from the current tick. Snapshots after it,
+// left by a rewind, were already discarded.
+func (r *Recorder) Resume() {
+	r.paused = false
+}
+
+// Oldest returns the oldest tick the simulation can be rewound to, and false
+// if nothing was recorded yet.
+func (r *Recorder) Oldest() (uint64, bool) {
+	if len(r.snapshots) == 0 {
+		return 0, false
+	}
+	return r.snapshots[0].tick, true
+}
+
+// Tick runs a simulation tick unless paused. It must be called from the
+// fixed timestep game update, in place of the simulation tick.
+func (r *Recorder) Tick() error {
+	if r.paused {
+		return nil
+	}
+	return r.step()
+}
+
+// Span returns the number of ticks covered by the snapshots when the ring is
+// full, Interval*Capacity.
+func (r *Recorder) Span() int {
+	return r.interval() * r.capacity()
+}
+
+// Step pauses the simulation and runs n ticks, for inspecting the state one
+// tick at a time. The ticks run synchronously, so n is capped at Span.
+func (r *Recorder) Step(n int) error {
+	r.paused = true
+	if span := r.Span(); n > span {
+		n = span
+	}
+	for i := 0; i < n; i++ {
+		if err := r.step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rewind pauses the simulation and brings it back n ticks, or to the oldest
+// snapshot when n is larger, and returns the current tick. Snapshots after the
+// target tick are discarded, so resuming records a new history.
+func (r *Recorder) Rewind(n uint64) (uint64, error) {
+	r.paused = true
+	target := uint64(0)
+	if n < r.now {
+		target = r.now - n
+	}
+	i := len(r.snapshots) - 1
+	for i >= 0 && r.snapshots[i].tick > target {
+		i--
+	}
+	if i < 0 {
+		oldest, ok := r.Oldest()
+		if !ok {
+			return r.now, ErrNotRecorded
+		}
+		i, target = 0, oldest
+	}
+	s := r.snapshots[i]
+	for j, st := range r.states {
+		if err := st.state.UnmarshalBinary(s.data[j]); err != nil {
+			return r.now, fmt.Errorf("rewind: restoring %v: %w", st.name, err)
+		}
+	}
+	r.snapshots = r.snapshots[:i+1]
+	r.now = s.tick
+	for r.now < target {
+		if err := r.step(); err != nil {
+			return r.now, err
+		}
+	}
+	return r.now, nil
+}
+
+// step records the state if due, then runs a tick.
+func (r *Recorder) step() error {
+	if err := r.record(); err != nil {
+		return err
+	}
+	r.Simulate()
+	r.now++
+	return nil
+}
+
+// record takes a snapshot every Interval ticks, dropping the oldest one when
+// the ring is full.
+func (r *Recorder) record() error {
+	if r.now%uint64(r.interval()) != 0 {
+		return nil
+	}
+	if n := len(r.snapshots); n > 0 && r.snapshots[n-1].tick == r.now {
+		return nil
+	}
+	s := snapshot{tick: r.now, data: make([][]byte, len(r.states))}
+	for i, st := range r.states {
+		b, err := st.state.MarshalBinary()
+		if err != nil {
+			return fmt.Errorf("rewind: recording %v: %w", st.name, err)
+		}
+		s.data[i] = b
+	}
+	capacity := r.capacity()
+	if len(r.snapshots) >= capacity {
+		copy(r.snapshots, r.snapshots[len(r.snapshots)-capacity+1:])
+		r.snapshots = r.snapshots[:capacity-1]
+	}
+	r.snapshots = append(r.snapshots, s)
+	return nil
+}
+
+// interval returns the Interval, at least 1.
+func (r *Recorder) interval() int {
+	if r.Interval < 1 {
+		return 1
+	}
+	return r.Interval
+}
+
+// capacity returns the Capacity, at least 1.
+func (r *Recorder) capacity() int {
+	if r.Capacity < 1 {
+		return 1
+	}
+	return r.Capacity
+}
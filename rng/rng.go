@@ -0,0 +1,173 @@
+// package rng provides deterministic random number streams.
+//
+// Each subsystem (world generation, decorators, particles, AI) should use its
+// own stream derived from the world seed and a label, so that changes in how
+// one subsystem consumes random numbers don't affect the others, and the same
+// seed reproduces the same world on every platform.
+package rng
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math/bits"
+)
+
+// Service creates labeled random number streams from a world seed.
+type Service struct {
+	seed uint64
+}
+
+// NewService creates a service that derives streams from the provided seed.
+func NewService(seed uint64) *Service {
+	return &Service{seed: seed}
+}
+
+// Seed returns the seed used by the service.
+func (s *Service) Seed() uint64 {
+	return s.seed
+}
+
+// Stream returns a new random number stream for the provided label. Calling
+// Stream twice with the same label returns two independent generators that
+// produce the same sequence.
+func (s *Service) Stream(label string) *Rand {
+	return New(Derive(s.seed, label))
+}
+
+// Derive combines the seed and label into a new seed.
+func Derive(seed uint64, label string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(label))
+	sm := seed ^ h.Sum64()
+	return splitmix64(&sm)
+}
+
+// splitmix64 advances the state and returns the next value. It is used to
+// expand a single seed into the xoshiro state.
+func splitmix64(state *uint64) uint64 {
+	*state += 0x9e3779b97f4a7c15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Rand is a xoshiro256** pseudo-random number generator. It is not safe for
+// concurrent use.
+//
+// Rand implements math/rand.Source64, so it can be used with math/rand.New
+// when the helpers from the standard library are required.
+type Rand struct {
+	s [4]uint64
+}
+
+// New creates a generator initialized with the provided seed.
+func New(seed uint64) *Rand {
+	r := &Rand{}
+	r.Seed(int64(seed))
+	return r
+}
+
+// Seed resets the generator state using the provided seed.
+func (r *Rand) Seed(seed int64) {
+	sm := uint64(seed)
+	for i := range r.s {
+		r.s[i] = splitmix64(&sm)
+	}
+}
+
+// MarshalBinary encodes the generator state as four little endian uint64
+// values, so the stream can be resumed where it stopped.
+func (r *Rand) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 32)
+	for _, v := range r.s {
+		b = binary.LittleEndian.AppendUint64(b, v)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary restores the state encoded by MarshalBinary.
+func (r *Rand) UnmarshalBinary(b []byte) error {
+	if len(b) != 32 {
+		return errors.New("rng: invalid state length")
+	}
+	for i := range r.s {
+		r.s[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	return nil
+}
+
+// Uint64 returns a pseudo-random 64-bit value.
+func (r *Rand) Uint64() uint64 {
+	s := &r.s
+	result := bits.RotateLeft64(s[1]*5, 7) * 9
+	t := s[1] << 17
+	s[2] ^= s[0]
+	s[3] ^= s[1]
+	s[1] ^= s[2]
+	s[0] ^= s[3]
+	s[2] ^= t
+	s[3] = bits.RotateLeft64(s[3], 45)
+	return result
+}
+
+// Uint32 returns a pseudo-random 32-bit value.
+func (r *Rand) Uint32() uint32 {
+	return uint32(r.Uint64() >> 32)
+}
+
+// Int63 returns a non-negative pseudo-random 63-bit integer.
+func (r *Rand) Int63() int64 {
+	return int64(r.Uint64() >> 1)
+}
+
+// Intn returns a pseudo-random number in [0, n). It panics if n <= 0.
+func (r *Rand) Intn(n int) int {
+	if n <= 0 {
+		panic("rng: invalid argument to Intn")
+	}
+	return int(r.uint64n(uint64(n)))
+}
+
+// uint64n returns an unbiased value in [0, n) using Lemire's method.
+func (r *Rand) uint64n(n uint64) uint64 {
+	hi, lo := bits.Mul64(r.Uint64(), n)
+	if lo < n {
+		threshold := -n % n
+		for lo < threshold {
+			hi, lo = bits.Mul64(r.Uint64(), n)
+		}
+	}
+	return hi
+}
+
+// Range returns a pseudo-random number in [min, max]. It panics if max < min.
+func (r *Rand) Range(min, max int) int {
+	if max < min {
+		panic("rng: invalid argument to Range")
+	}
+	return min + int(r.uint64n(uint64(max-min)+1))
+}
+
+// Float64 returns a pseudo-random number in [0.0, 1.0).
+func (r *Rand) Float64() float64 {
+	return float64(r.Uint64()>>11) * (1.0 / (1 << 53))
+}
+
+// Float32 returns a pseudo-random number in [0.0, 1.0).
+func (r *Rand) Float32() float32 {
+	return float32(r.Uint64()>>40) * (1.0 / (1 << 24))
+}
+
+// Bool returns a pseudo-random boolean value.
+func (r *Rand) Bool() bool {
+	return r.Uint64()>>63 == 1
+}
+
+// Shuffle randomizes the order of n elements using the provided swap function.
+func (r *Rand) Shuffle(n int, swap func(i, j int)) {
+	for i := n - 1; i > 0; i-- {
+		swap(i, int(r.uint64n(uint64(i+1))))
+	}
+}
@@ -0,0 +1,95 @@
+// package server implements the dedicated server administration tools.
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ronoaldo/openvoxel/console"
+	"github.com/ronoaldo/openvoxel/gamemode"
+)
+
+// Admin is implemented by the game server to support the administration
+// commands.
+type Admin interface {
+	Kick(player, reason string) error
+	SaveAll() error
+	SetTime(ticks int) error
+	Stats() string
+	// SetGameMode changes the game mode of the player, and sends it to
+	// their client, which applies it with gamemode.Set.
+	SetGameMode(player string, m gamemode.Mode) error
+}
+
+// RegisterAdminCommands adds the kick, save-all, set-time, stats and gamemode
+// commands to the registry.
+func RegisterAdminCommands(r *console.Registry, a Admin) {
+	r.Register(console.Command{
+		Name:  "kick",
+		Usage: "<player> [reason]",
+		Help:  "disconnects the player from the server",
+		Handler: func(args []string) (string, error) {
+			if len(args) < 1 {
+				return "", fmt.Errorf("usage: kick <player> [reason]")
+			}
+			if err := a.Kick(args[0], strings.Join(args[1:], " ")); err != nil {
+				return "", err
+			}
+			return "kicked " + args[0], nil
+		},
+	})
+	r.Register(console.Command{
+		Name: "save-all",
+		Help: "saves all loaded chunks to disk",
+		Handler: func(args []string) (string, error) {
+			if err := a.SaveAll(); err != nil {
+				return "", err
+			}
+			return "saved", nil
+		},
+	})
+	r.Register(console.Command{
+		Name:  "set-time",
+		Usage: "<ticks>",
+		Help:  "changes the time of day",
+		Handler: func(args []string) (string, error) {
+			if len(args) != 1 {
+				return "", fmt.Errorf("usage: set-time <ticks>")
+			}
+			t, err := strconv.Atoi(args[0])
+			if err != nil {
+				return "", fmt.Errorf("invalid time: %v", args[0])
+			}
+			if err := a.SetTime(t); err != nil {
+				return "", err
+			}
+			return "time set to " + args[0], nil
+		},
+	})
+	r.Register(console.Command{
+		Name: "stats",
+		Help: "shows the server statistics",
+		Handler: func(args []string) (string, error) {
+			return a.Stats(), nil
+		},
+	})
+	r.Register(console.Command{
+		Name:  "gamemode",
+		Usage: "<player> <survival|creative|spectator>",
+		Help:  "changes the game mode of the player",
+		Handler: func(args []string) (string, error) {
+			if len(args) != 2 {
+				return "", fmt.Errorf("usage: gamemode <player> <survival|creative|spectator>")
+			}
+			m, err := gamemode.Parse(args[1])
+			if err != nil {
+				return "", err
+			}
+			if err := a.SetGameMode(args[0], m); err != nil {
+				return "", err
+			}
+			return args[0] + " is now in " + m.String() + " mode", nil
+		},
+	})
+}
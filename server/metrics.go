@@ -0,0 +1,80 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+)
+
+// quantiles reported by the metrics endpoint.
+var quantiles = []float64{0.5, 0.9, 0.99}
+
+// Metrics exposes the server state in the Prometheus text format.
+type Metrics struct {
+	Profiler *TickProfiler
+
+	// Players and ChunksLoaded are called on each scrape to report the
+	// current values.
+	Players      func() int
+	ChunksLoaded func() int
+
+	bytesIn  atomic.Uint64
+	bytesOut atomic.Uint64
+}
+
+// AddBytesIn adds n to the count of bytes received from the network.
+func (m *Metrics) AddBytesIn(n int) {
+	m.bytesIn.Add(uint64(n))
+}
+
+// AddBytesOut adds n to the count of bytes sent to the network.
+func (m *Metrics) AddBytesOut(n int) {
+	m.bytesOut.Add(uint64(n))
+}
+
+// ServeHTTP writes the metrics, so Metrics can be registered as the handler of
+// the /metrics path.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	gauge := func(name, help string, v any) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", name, help, name, name, v)
+	}
+	counter := func(name, help string, v any) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %v\n", name, help, name, name, v)
+	}
+
+	if m.Players != nil {
+		gauge("openvoxel_players", "Number of connected players.", m.Players())
+	}
+	if m.ChunksLoaded != nil {
+		gauge("openvoxel_chunks_loaded", "Number of chunks loaded in memory.", m.ChunksLoaded())
+	}
+	counter("openvoxel_network_bytes_in_total", "Bytes received from clients.", m.bytesIn.Load())
+	counter("openvoxel_network_bytes_out_total", "Bytes sent to clients.", m.bytesOut.Load())
+
+	if p := m.Profiler; p != nil {
+		counter("openvoxel_ticks_total", "Number of server ticks.", p.Ticks())
+
+		name := "openvoxel_tick_duration_seconds"
+		fmt.Fprintf(w, "# HELP %s Duration of the recent server ticks.\n# TYPE %s summary\n", name, name)
+		for _, q := range quantiles {
+			fmt.Fprintf(w, "%s{quantile=\"%v\"} %v\n", name, q, p.Percentile(q).Seconds())
+		}
+
+		name = "openvoxel_system_duration_seconds"
+		fmt.Fprintf(w, "# HELP %s Duration of each system in the recent server ticks.\n# TYPE %s summary\n", name, name)
+		for _, q := range quantiles {
+			systems := p.SystemPercentiles(q)
+			names := make([]string, 0, len(systems))
+			for s := range systems {
+				names = append(names, s)
+			}
+			sort.Strings(names)
+			for _, s := range names {
+				fmt.Fprintf(w, "%s{system=%q,quantile=\"%v\"} %v\n", name, s, q, systems[s].Seconds())
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// profilerWindow is the number of ticks kept to compute percentiles.
+const profilerWindow = 600
+
+// TickProfiler measures how long each server system takes per tick.
+//
+// Call BeginTick at the start of the tick, wrap each system with Measure, and
+// call EndTick when done. The last ticks are kept to compute percentiles.
+type TickProfiler struct {
+	// Trace, if set, records each tick and system as a span, in the same
+	// trace as the frames and input events. It must be set before the first
+	// tick.
+	Trace *Trace
+
+	mu        sync.Mutex
+	tickStart time.Time
+	ticks     uint64
+	total     *samples
+	systems   map[string]*samples
+}
+
+// NewTickProfiler creates an empty profiler.
+func NewTickProfiler() *TickProfiler {
+	return &TickProfiler{
+		total:   newSamples(),
+		systems: map[string]*samples{},
+	}
+}
+
+// BeginTick marks the start of a server tick.
+func (p *TickProfiler) BeginTick() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tickStart = time.Now()
+}
+
+// EndTick records the duration of the tick started with BeginTick.
+func (p *TickProfiler) EndTick() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d := time.Since(p.tickStart)
+	p.total.add(d)
+	p.ticks++
+	if p.Trace != nil {
+		p.Trace.Span("tick", "tick", p.tickStart, d)
+	}
+}
+
+// Measure runs fn and records its duration for the system.
+func (p *TickProfiler) Measure(system string, fn func()) {
+	start := time.Now()
+	fn()
+	d := time.Since(start)
+	if p.Trace != nil {
+		p.Trace.Span(system, "system", start, d)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.systems[system]
+	if !ok {
+		s = newSamples()
+		p.systems[system] = s
+	}
+	s.add(d)
+}
+
+// Ticks returns the number of ticks recorded.
+func (p *TickProfiler) Ticks() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ticks
+}
+
+// Percentile returns the q-th percentile, in the range [0, 1], of the tick
+// duration over the recent ticks.
+func (p *TickProfiler) Percentile(q float64) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.total.percentile(q)
+}
+
+// SystemPercentiles returns the q-th percentile of the duration of each
+// system over the recent ticks.
+func (p *TickProfiler) SystemPercentiles(q float64) map[string]time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	m := make(map[string]time.Duration, len(p.systems))
+	for name, s := range p.systems {
+		m[name] = s.percentile(q)
+	}
+	return m
+}
+
+// samples is a ring buffer of durations.
+type samples struct {
+	values []time.Duration
+	next   int
+}
+
+func newSamples() *samples {
+	return &samples{values: make([]time.Duration, 0, profilerWindow)}
+}
+
+func (s *samples) add(d time.Duration) {
+	if len(s.values) < cap(s.values) {
+		s.values = append(s.values, d)
+		return
+	}
+	s.values[s.next] = d
+	s.next = (s.next + 1) % len(s.values)
+}
+
+func (s *samples) percentile(q float64) time.Duration {
+	if len(s.values) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), s.values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(q * float64(len(sorted)-1))
+	return sorted[i]
+}
@@ -0,0 +1,31 @@
+package server
+
+import "sync"
+
+// Queue runs the functions submitted from other goroutines on the goroutine
+// that calls Drain, usually the game update, so they can change the game state
+// without locks. Its Run method can be used as RCON.Run. It is safe for
+// concurrent use.
+type Queue struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// Run queues fn to be called by the next Drain. It does not wait for it.
+func (q *Queue) Run(fn func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.fns = append(q.fns, fn)
+}
+
+// Drain calls the queued functions, in the order they were queued. Functions
+// queued while draining run on the next call.
+func (q *Queue) Drain() {
+	q.mu.Lock()
+	fns := q.fns
+	q.fns = nil
+	q.mu.Unlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ronoaldo/openvoxel/console"
+	"github.com/ronoaldo/openvoxel/log"
+)
+
+// RCON implements a line based remote administration protocol over TCP.
+//
+// The first line sent by the client must be the password. The server replies
+// with "OK" or "ERR", and after three failed attempts the connection is
+// closed. Once authenticated, each line is a command, and the server replies
+// with the command output followed by a line with a single dot.
+//
+// The password and commands are sent in plain text, so RCON must only listen
+// on localhost or a trusted network, such as through an SSH tunnel or a VPN,
+// and never be exposed to the internet. Addresses that fail too many
+// passwords are locked out for a while, which slows down guessing but does
+// not make the protocol safe on untrusted networks.
+//
+// Connections are served on their own goroutines, but the commands change the
+// game state, so they are handed to Run, which must call them on the game
+// update goroutine, such as with a Queue drained on each fixed update. The
+// reply is sent once the command finished.
+type RCON struct {
+	Password string
+	Commands *console.Registry
+	// Run calls fn on the game update goroutine, now or later. It is
+	// required.
+	Run func(fn func())
+
+	// IdleTimeout closes connections without activity. Zero means no limit.
+	IdleTimeout time.Duration
+
+	// Lockout is how long an address is refused after maxAuthAttempts wrong
+	// passwords, DefaultLockout if zero. It doubles each time the address
+	// is locked out again, up to maxLockout.
+	Lockout time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*authFailures
+}
+
+// maxAuthAttempts is the number of wrong passwords allowed per connection,
+// and per address before it is locked out.
+const maxAuthAttempts = 3
+
+// DefaultLockout is the default RCON.Lockout.
+const DefaultLockout = time.Minute
+
+// maxLockout caps the lockout of addresses that keep failing, and is how long
+// their failures are remembered.
+const maxLockout = time.Hour
+
+// authFailures counts the wrong passwords sent from an address.
+type authFailures struct {
+	count int
+	last  time.Time
+	until time.Time
+}
+
+// host returns the address without the port, so all connections from a host
+// share the lockout.
+func host(addr net.Addr) string {
+	h, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return h
+}
+
+// lockedOut returns true if the host is refused until a later time.
+func (s *RCON) lockedOut(h string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.failures[h]
+	return f != nil && now.Before(f.until)
+}
+
+// failed records a wrong password from the host, locking it out after every
+// maxAuthAttempts failures, and forgets the hosts that stopped failing.
+func (s *RCON) failed(h string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures == nil {
+		s.failures = map[string]*authFailures{}
+	}
+	for k, f := range s.failures {
+		if now.Sub(f.last) > maxLockout && now.After(f.until) {
+			delete(s.failures, k)
+		}
+	}
+	f := s.failures[h]
+	if f == nil {
+		f = &authFailures{}
+		s.failures[h] = f
+	}
+	f.count++
+	f.last = now
+	if f.count%maxAuthAttempts != 0 {
+		return
+	}
+	d := s.Lockout
+	if d <= 0 {
+		d = DefaultLockout
+	}
+	for i := 1; i < f.count/maxAuthAttempts && d < maxLockout; i++ {
+		d *= 2
+	}
+	if d > maxLockout {
+		d = maxLockout
+	}
+	f.until = now.Add(d)
+	log.Warnf("rcon: locking out %v for %v after %d failed attempts", h, d, f.count)
+}
+
+// succeeded clears the failures of the host.
+func (s *RCON) succeeded(h string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, h)
+}
+
+// ListenAndServe listens on the TCP address and serves RCON clients.
+func (s *RCON) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the listener, handling each one in a new
+// goroutine.
+func (s *RCON) Serve(l net.Listener) error {
+	if s.Password == "" {
+		return fmt.Errorf("rcon: refusing to serve without a password")
+	}
+	if s.Run == nil {
+		return fmt.Errorf("rcon: refusing to serve without Run")
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *RCON) handle(conn net.Conn) {
+	defer conn.Close()
+	addr := conn.RemoteAddr()
+	h := host(addr)
+	if s.lockedOut(h, time.Now()) {
+		log.Warnf("rcon: refusing connection from %v, locked out", addr)
+		return
+	}
+	log.Infof("rcon: connection from %v", addr)
+
+	r := bufio.NewScanner(conn)
+	w := bufio.NewWriter(conn)
+	next := func() (string, bool) {
+		if s.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+		if !r.Scan() {
+			return "", false
+		}
+		return strings.TrimRight(r.Text(), "\r"), true
+	}
+
+	authenticated := false
+	for attempt := 0; attempt < maxAuthAttempts; attempt++ {
+		pass, ok := next()
+		if !ok {
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(pass), []byte(s.Password)) == 1 {
+			authenticated = true
+			break
+		}
+		log.Warnf("rcon: authentication failed from %v", addr)
+		fmt.Fprintln(w, "ERR")
+		w.Flush()
+		s.failed(h, time.Now())
+		if s.lockedOut(h, time.Now()) {
+			return
+		}
+	}
+	if !authenticated {
+		return
+	}
+	s.succeeded(h)
+	fmt.Fprintln(w, "OK")
+	w.Flush()
+
+	for {
+		line, ok := next()
+		if !ok {
+			return
+		}
+		log.Infof("rcon: %v executed %q", addr, line)
+		out, err := s.execute(line)
+		if err != nil {
+			out = "error: " + err.Error()
+		}
+		for _, l := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+			// Escape lines starting with a dot, so they are not confused
+			// with the end of the response.
+			if strings.HasPrefix(l, ".") {
+				l = "." + l
+			}
+			fmt.Fprintln(w, l)
+		}
+		fmt.Fprintln(w, ".")
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// execute runs the command line with Run, and waits for its output.
+func (s *RCON) execute(line string) (out string, err error) {
+	done := make(chan struct{})
+	s.Run(func() {
+		defer close(done)
+		out, err = s.Commands.Execute(line)
+	})
+	<-done
+	return out, err
+}
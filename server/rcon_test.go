@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ronoaldo/openvoxel/console"
+)
+
+func TestRCONRunsCommandsOnDrain(t *testing.T) {
+	var q Queue
+	drained := false
+	commands := console.NewRegistry()
+	commands.Register(console.Command{
+		Name: "ping",
+		Handler: func(args []string) (string, error) {
+			if !drained {
+				t.Error("command ran outside of Queue.Drain")
+			}
+			return "pong", nil
+		},
+	})
+	s := &RCON{Password: "secret", Commands: commands, Run: q.Run}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go s.Serve(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewScanner(conn)
+	fmt.Fprintln(conn, "secret")
+	if !r.Scan() || r.Text() != "OK" {
+		t.Fatalf("login reply %q, want OK", r.Text())
+	}
+	fmt.Fprintln(conn, "ping")
+
+	// Stand in for the game update loop.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				drained = true
+				q.Drain()
+			}
+		}
+	}()
+	var lines []string
+	for r.Scan() && r.Text() != "." {
+		lines = append(lines, r.Text())
+	}
+	if len(lines) != 1 || lines[0] != "pong" {
+		t.Errorf("reply %q, want pong", lines)
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+// DefaultTraceCapacity is the number of events kept by a new Trace.
+const DefaultTraceCapacity = 200000
+
+// The tracks of the trace, shown as threads by the trace viewers.
+const (
+	traceTicks = iota + 1
+	traceFrames
+	traceInput
+)
+
+var traceTracks = [...]string{
+	traceTicks:  "ticks",
+	traceFrames: "frames",
+	traceInput:  "input",
+}
+
+// traceEvent is an event in the Chrome trace event format.
+type traceEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Ph    string                 `json:"ph"`
+	Ts    float64                `json:"ts"`
+	Dur   float64                `json:"dur,omitempty"`
+	Pid   int                    `json:"pid"`
+	Tid   int                    `json:"tid"`
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// Trace records the ticks, frames and input events in the Chrome trace event
+// format, to be opened in chrome://tracing or Perfetto, such as to find the
+// latency between an input event and the frame that shows it. It keeps the
+// last Capacity events. It is safe for concurrent use.
+//
+// The ticks are recorded by a TickProfiler with the Trace set, the frames by
+// the engine with Config.Tracer, and the input events with RecordInput.
+type Trace struct {
+	// Capacity is the number of events kept, DefaultTraceCapacity by
+	// default. Older events are dropped.
+	Capacity int
+
+	mu     sync.Mutex
+	start  time.Time
+	events []traceEvent
+	next   int
+	// frames counts the frames, and lastFrame is when the last one was
+	// presented. pending holds when the input events since then arrived.
+	frames    uint64
+	lastFrame time.Time
+	pending   []time.Time
+}
+
+// NewTrace creates an empty trace, with the time starting now.
+func NewTrace() *Trace {
+	now := time.Now()
+	return &Trace{Capacity: DefaultTraceCapacity, start: now, lastFrame: now}
+}
+
+// add records the event, dropping the oldest one when full. It must be called
+// with the mutex held.
+func (t *Trace) add(e traceEvent) {
+	e.Pid = 1
+	limit := t.Capacity
+	if limit <= 0 {
+		limit = DefaultTraceCapacity
+	}
+	if len(t.events) < limit {
+		t.events = append(t.events, e)
+		return
+	}
+	t.events[t.next] = e
+	t.next = (t.next + 1) % len(t.events)
+}
+
+// micros returns the time since the start of the trace in microseconds.
+func (t *Trace) micros(at time.Time) float64 {
+	return float64(at.Sub(t.start).Nanoseconds()) / 1e3
+}
+
+// Span records an event named name in the category that started at start and
+// took d.
+func (t *Trace) Span(name, category string, start time.Time, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.span(name, category, traceTicks, start, d, nil)
+}
+
+func (t *Trace) span(name, category string, track int, start time.Time, d time.Duration, args map[string]interface{}) {
+	t.add(traceEvent{Name: name, Cat: category, Ph: "X", Ts: t.micros(start), Dur: float64(d.Nanoseconds()) / 1e3, Tid: track, Args: args})
+}
+
+// Frame marks that a frame was presented. It records the frame from the last
+// one, with the number of input events that arrived in between and the time
+// since the oldest one, which is the worst input latency the frame shows.
+func (t *Trace) Frame() {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	args := map[string]interface{}{"frame": t.frames, "inputs": len(t.pending)}
+	if len(t.pending) > 0 {
+		args["input_latency_ms"] = float64(now.Sub(t.pending[0]).Microseconds()) / 1e3
+	}
+	t.span("frame", "render", traceFrames, t.lastFrame, now.Sub(t.lastFrame), args)
+	t.frames++
+	t.lastFrame = now
+	t.pending = t.pending[:0]
+}
+
+// Input records an instant input event with the arguments, and counts it for
+// the latency of the next frame.
+func (t *Trace) Input(name string, args map[string]interface{}) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(traceEvent{Name: name, Cat: "input", Ph: "i", Ts: t.micros(now), Tid: traceInput, Scope: "t", Args: args})
+	t.pending = append(t.pending, now)
+}
+
+// RecordInput records the key, mouse button and text events published on the
+// bus, usually event.Default, as they arrive from the window. The typed text
+// is never recorded, only its length, and neither are the keys pressed in the
+// text input mode, only their action, so traces can be shared without leaking
+// chat messages or passwords. It returns a function that stops recording.
+func (t *Trace) RecordInput(bus *event.Bus) (stop func()) {
+	actions := [...]string{event.KeyRelease: "release", event.KeyPress: "press", event.KeyRepeat: "repeat"}
+	action := func(a event.KeyAction) string {
+		if a >= 0 && int(a) < len(actions) {
+			return actions[a]
+		}
+		return "unknown"
+	}
+	stops := []func(){
+		event.Subscribe(bus, func(e event.Key) {
+			if e.Text {
+				t.Input("key", map[string]interface{}{"action": action(e.Action), "text": true})
+				return
+			}
+			t.Input("key", map[string]interface{}{"key": e.Key, "scancode": e.Scancode, "action": action(e.Action), "mods": e.Mods})
+		}),
+		event.Subscribe(bus, func(e event.MouseButton) {
+			t.Input("mouse", map[string]interface{}{"button": e.Button, "action": action(e.Action), "mods": e.Mods})
+		}),
+		event.Subscribe(bus, func(e event.Text) {
+			t.Input("text", map[string]interface{}{"length": len(e.Text)})
+		}),
+	}
+	return func() {
+		for _, s := range stops {
+			s()
+		}
+	}
+}
+
+// WriteTo writes the events, oldest first, as a Chrome trace JSON object.
+func (t *Trace) WriteTo(w io.Writer) (int64, error) {
+	t.mu.Lock()
+	events := make([]traceEvent, 0, len(t.events)+len(traceTracks))
+	for tid, name := range traceTracks[1:] {
+		events = append(events, traceEvent{Name: "thread_name", Ph: "M", Pid: 1, Tid: tid + 1, Args: map[string]interface{}{"name": name}})
+	}
+	events = append(events, t.events[t.next:]...)
+	events = append(events, t.events[:t.next]...)
+	t.mu.Unlock()
+
+	b, err := json.Marshal(struct {
+		TraceEvents     []traceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{events, "ms"})
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// WriteFile writes the trace to the file with WriteTo.
+func (t *Trace) WriteFile(name string) error {
+	fd, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := t.WriteTo(fd); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
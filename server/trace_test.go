@@ -0,0 +1,54 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+func TestRecordInputHidesText(t *testing.T) {
+	bus := event.NewBus()
+	tr := NewTrace()
+	stop := tr.RecordInput(bus)
+	event.Publish(bus, event.Key{Key: 65, Scancode: 38, Action: event.KeyPress, Text: true})
+	event.Publish(bus, event.Text{Text: "hunter2"})
+	event.Publish(bus, event.Key{Key: 87, Scancode: 25, Action: event.KeyPress})
+	stop()
+	event.Publish(bus, event.Key{Key: 66, Scancode: 56, Action: event.KeyPress})
+
+	var buf bytes.Buffer
+	if _, err := tr.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatal(err)
+	}
+	var inputs []traceEvent
+	for _, e := range trace.TraceEvents {
+		if e.Cat == "input" {
+			inputs = append(inputs, e)
+		}
+	}
+	if len(inputs) != 3 {
+		t.Fatalf("recorded %d input events, want 3: %+v", len(inputs), inputs)
+	}
+	for _, arg := range []string{"key", "scancode", "mods"} {
+		if _, ok := inputs[0].Args[arg]; ok {
+			t.Errorf("text mode key recorded %v: %v", arg, inputs[0].Args)
+		}
+	}
+	if got := inputs[0].Args["action"]; got != "press" {
+		t.Errorf("text mode key action = %v, want press", got)
+	}
+	if len(inputs[1].Args) != 1 || inputs[1].Args["length"] != float64(len("hunter2")) {
+		t.Errorf("text recorded %v, want only its length", inputs[1].Args)
+	}
+	if got := inputs[2].Args["key"]; got != float64(87) {
+		t.Errorf("game key = %v, want 87", got)
+	}
+}
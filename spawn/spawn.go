@@ -0,0 +1,380 @@
+// package spawn populates the loaded chunks with mobs, following spawn rules
+// such as the light level, the block underneath, the biome and the distance
+// from the players, and removes them again when the players walk away, so the
+// world feels alive without game specific code.
+//
+// The number of mobs is limited by category, such as monsters and animals,
+// and in total, so the world never fills up and the simulation cost stays
+// bounded.
+package spawn
+
+import (
+	"math"
+	"sort"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/biome"
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/entity"
+	"github.com/ronoaldo/openvoxel/physics"
+	"github.com/ronoaldo/openvoxel/rng"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// Category groups the mobs sharing a cap.
+type Category string
+
+const (
+	Monster  Category = "monster"
+	Creature Category = "creature"
+	Ambient  Category = "ambient"
+	Water    Category = "water"
+)
+
+// DefaultCaps are the caps of the built-in categories.
+var DefaultCaps = map[Category]int{Monster: 70, Creature: 10, Ambient: 15, Water: 5}
+
+// Rule describes where an entity type spawns.
+type Rule struct {
+	// Type is the entity type, such as "openvoxel:zombie".
+	Type     string
+	Category Category
+	// Weight is the chance of the rule being picked among the ones that
+	// match a position, relative to the other rules.
+	Weight int
+
+	// MinLight and MaxLight limit the block light at the spawn position,
+	// the brightest channel from 0 to light.MaxLevel. Both zero allows any
+	// light.
+	MinLight, MaxLight uint8
+	// Medium is the medium the entity spawns in: air for land mobs, liquid
+	// for fish.
+	Medium physics.Medium
+	// Ground lists the names of the blocks the entity spawns on, such as
+	// "openvoxel:grass". Empty allows any block with collision for land
+	// mobs, and any block for the others.
+	Ground []string
+	// Biomes lists the names of the biomes the entity spawns in. Empty
+	// allows all biomes.
+	Biomes []string
+	// MinDistance and MaxDistance limit the horizontal distance, in blocks,
+	// from the nearest player. Zero MaxDistance uses the Spawner
+	// MaxDistance.
+	MinDistance, MaxDistance float32
+	// Height is the number of free blocks needed above the ground, 1 if
+	// zero.
+	Height int
+	// GroupMin and GroupMax are the size of the groups spawned together,
+	// such as herds of animals. Both default to 1.
+	GroupMin, GroupMax int
+	// Condition, if set, is an additional check, such as only spawning
+	// at night.
+	Condition func(x, y, z int) bool
+}
+
+// Spawner spawns and despawns the entities of the Rules on each Tick. Only
+// the entities of the types with rules are counted in the caps and despawned.
+// It is not safe for concurrent use.
+type Spawner struct {
+	Store  *entity.Store
+	Map    *world.Map
+	Blocks *block.Registry
+	// Biome returns the biome of a column, such as
+	// worldgen.Generator.Biome. It is required by the rules with Biomes.
+	Biome biome.Source
+	Rules []Rule
+
+	// Caps limit the number of entities of each category, and Cap the total.
+	// Categories without a cap are not limited, and zero Cap does not limit
+	// the total.
+	Caps map[Category]int
+	Cap  int
+	// Attempts is the number of random positions tried in each chunk near
+	// the players on each Tick.
+	Attempts int
+	// MaxDistance is the horizontal distance, in blocks, from the players
+	// where entities spawn, and DespawnDistance the distance from all the
+	// players where they are removed right away.
+	MaxDistance, DespawnDistance float32
+	// RandomDespawnDistance is the distance from all the players where
+	// entities are removed with RandomDespawnChance on each Tick, so mobs
+	// left behind slowly disappear.
+	RandomDespawnDistance, RandomDespawnChance float32
+	// Keep, if set, prevents despawning the entities it returns true for,
+	// such as named or tamed mobs.
+	Keep func(e *entity.Entity) bool
+
+	rand *rng.Rand
+}
+
+// New creates a spawner with the DefaultCaps and no rules, with the random
+// positions derived from the world seed.
+func New(store *entity.Store, m *world.Map, blocks *block.Registry, seed uint64) *Spawner {
+	caps := make(map[Category]int, len(DefaultCaps))
+	for c, n := range DefaultCaps {
+		caps[c] = n
+	}
+	return &Spawner{
+		Store:                 store,
+		Map:                   m,
+		Blocks:                blocks,
+		Caps:                  caps,
+		Attempts:              1,
+		MaxDistance:           96,
+		DespawnDistance:       128,
+		RandomDespawnDistance: 32,
+		RandomDespawnChance:   1.0 / 800,
+		rand:                  rng.New(rng.Derive(seed, "spawn")),
+	}
+}
+
+// Counts returns the number of entities of each category with rules.
+func (s *Spawner) Counts() map[Category]int {
+	categories := s.categories()
+	counts := map[Category]int{}
+	s.Store.Each(func(e *entity.Entity) {
+		if c, ok := categories[e.Type]; ok {
+			counts[c]++
+		}
+	})
+	return counts
+}
+
+// categories returns the category of each entity type with rules.
+func (s *Spawner) categories() map[string]Category {
+	out := make(map[string]Category, len(s.Rules))
+	for _, r := range s.Rules {
+		out[r.Type] = r.Category
+	}
+	return out
+}
+
+// Tick despawns the entities far from the players, then tries to spawn new
+// ones around them, and returns the entities spawned. It must be called from
+// the fixed timestep game update, with the positions of all the players.
+func (s *Spawner) Tick(players []glm.Vec3) []*entity.Entity {
+	if len(players) == 0 {
+		return nil
+	}
+	s.despawn(players)
+	return s.spawn(players)
+}
+
+// distance returns the horizontal distance from p to the nearest player.
+func distance(p glm.Vec3, players []glm.Vec3) float32 {
+	d := float32(math.Inf(1))
+	for _, pl := range players {
+		dx, dz := p[0]-pl[0], p[2]-pl[2]
+		if v := float32(math.Sqrt(float64(dx*dx + dz*dz))); v < d {
+			d = v
+		}
+	}
+	return d
+}
+
+func (s *Spawner) despawn(players []glm.Vec3) {
+	categories := s.categories()
+	var remove []*entity.Entity
+	s.Store.Each(func(e *entity.Entity) {
+		if _, ok := categories[e.Type]; ok && (s.Keep == nil || !s.Keep(e)) {
+			remove = append(remove, e)
+		}
+	})
+	// The store is not ordered, so sort the entities for the random
+	// despawns to be deterministic.
+	sort.Slice(remove, func(i, j int) bool { return remove[i].ID < remove[j].ID })
+	for _, e := range remove {
+		d := distance(e.Position, players)
+		switch {
+		case s.DespawnDistance > 0 && d > s.DespawnDistance:
+			s.Store.Remove(e)
+		case s.RandomDespawnDistance > 0 && d > s.RandomDespawnDistance && s.rand.Float32() < s.RandomDespawnChance:
+			s.Store.Remove(e)
+		}
+	}
+}
+
+func (s *Spawner) spawn(players []glm.Vec3) []*entity.Entity {
+	counts := s.Counts()
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	full := func(c Category) bool {
+		if s.Cap > 0 && total >= s.Cap {
+			return true
+		}
+		limit, ok := s.Caps[c]
+		return ok && counts[c] >= limit
+	}
+
+	var chunks []*world.Chunk
+	s.Map.Each(func(c *world.Chunk) {
+		x, _, z := c.Origin()
+		center := glm.Vec3{float32(x + world.SizeX/2), 0, float32(z + world.SizeZ/2)}
+		if distance(center, players) <= s.MaxDistance+world.SizeX {
+			chunks = append(chunks, c)
+		}
+	})
+	sort.Slice(chunks, func(i, j int) bool {
+		a, b := chunks[i], chunks[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		return a.X < b.X
+	})
+
+	var spawned []*entity.Entity
+	for _, c := range chunks {
+		ox, oy, oz := c.Origin()
+		for i := 0; i < s.Attempts; i++ {
+			n := s.rand.Intn(world.Volume)
+			x, z, y := ox+n%world.SizeX, oz+n/world.SizeX%world.SizeZ, oy+n/(world.SizeX*world.SizeZ)
+			r := s.pick(x, y, z, players, full)
+			if r == nil {
+				continue
+			}
+			size := r.GroupMin
+			if size < 1 {
+				size = 1
+			}
+			if r.GroupMax > size {
+				size = s.rand.Range(size, r.GroupMax)
+			}
+			for j := 0; j < size && !full(r.Category); j++ {
+				// The first entity spawns at the picked position, and
+				// the rest of the group around it.
+				px, py, pz := x, y, z
+				if j > 0 {
+					px, pz = x+s.rand.Range(-2, 2), z+s.rand.Range(-2, 2)
+					if !s.allowed(r, px, py, pz, players) {
+						continue
+					}
+				}
+				e, err := s.Store.Spawn(r.Type, glm.Vec3{float32(px) + 0.5, float32(py), float32(pz) + 0.5})
+				if err != nil {
+					continue
+				}
+				counts[r.Category]++
+				total++
+				spawned = append(spawned, e)
+			}
+		}
+	}
+	return spawned
+}
+
+// pick returns one of the rules allowed at the position, randomly chosen by
+// weight, or nil if none is.
+func (s *Spawner) pick(x, y, z int, players []glm.Vec3, full func(Category) bool) *Rule {
+	var candidates []*Rule
+	sum := 0
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		if r.Weight <= 0 || full(r.Category) || !s.allowed(r, x, y, z, players) {
+			continue
+		}
+		candidates = append(candidates, r)
+		sum += r.Weight
+	}
+	if sum == 0 {
+		return nil
+	}
+	n := s.rand.Intn(sum)
+	for _, r := range candidates {
+		if n < r.Weight {
+			return r
+		}
+		n -= r.Weight
+	}
+	return nil
+}
+
+// allowed returns true if the rule allows the entity to spawn with its feet
+// at the block x, y, z.
+func (s *Spawner) allowed(r *Rule, x, y, z int, players []glm.Vec3) bool {
+	p := glm.Vec3{float32(x) + 0.5, float32(y), float32(z) + 0.5}
+	d := distance(p, players)
+	max := r.MaxDistance
+	if max == 0 {
+		max = s.MaxDistance
+	}
+	if d < r.MinDistance || d > max {
+		return false
+	}
+
+	height := r.Height
+	if height < 1 {
+		height = 1
+	}
+	for i := 0; i < height; i++ {
+		def := s.Blocks.Get(s.Map.Block(x, y+i, z).ID)
+		if def.Collision != nil || def.Medium != r.Medium {
+			return false
+		}
+	}
+	below := s.Map.Block(x, y-1, z)
+	if len(r.Ground) > 0 {
+		if !s.named(below.ID, r.Ground) {
+			return false
+		}
+	} else if r.Medium == physics.MediumAir && s.Blocks.Get(below.ID).Collision == nil {
+		return false
+	}
+
+	if r.MinLight != 0 || r.MaxLight != 0 {
+		l := s.light(x, y, z)
+		if l < r.MinLight || l > r.MaxLight {
+			return false
+		}
+	}
+	if len(r.Biomes) > 0 {
+		if s.Biome == nil {
+			return false
+		}
+		b := s.Biome(x, z)
+		if b == nil || !contains(r.Biomes, b.Name) {
+			return false
+		}
+	}
+	return r.Condition == nil || r.Condition(x, y, z)
+}
+
+// light returns the brightest channel of the block light at x, y, z.
+func (s *Spawner) light(x, y, z int) uint8 {
+	c := s.Map.Chunk(world.ChunkAt(x, y, z))
+	if c == nil {
+		return 0
+	}
+	l := c.Light(world.Local(x, y, z))
+	v := l.R()
+	if l.G() > v {
+		v = l.G()
+	}
+	if l.B() > v {
+		v = l.B()
+	}
+	return v
+}
+
+// named returns true if the block has one of the names.
+func (s *Spawner) named(id block.ID, names []string) bool {
+	for _, name := range names {
+		if other, ok := s.Blocks.Lookup(name); ok && other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
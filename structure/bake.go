@@ -0,0 +1,206 @@
+package structure
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/job"
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/rng"
+)
+
+// BakeOptions configures Bake.
+type BakeOptions struct {
+	// Samples is the number of rays traced from each block.
+	Samples int
+	// Bounces is the number of times a ray is reflected by the blocks
+	// before being dropped.
+	Bounces int
+	// Sky is the color of the light coming from outside the structure, from
+	// 0 to 1 per channel: white for daylight, black for underground
+	// structures.
+	Sky [3]float32
+	// Albedo is the fraction of the light reflected by the blocks.
+	Albedo float32
+	// Intensity scales the average light of the rays, so a block on open
+	// ground under the Sky, which sees it in half of the directions, is
+	// fully lit with the default of 2.
+	Intensity float32
+	// Seed makes the baking reproducible.
+	Seed uint64
+	// Jobs runs the layers of the structure in parallel, job.Default if
+	// nil.
+	Jobs *job.Scheduler
+}
+
+// DefaultBakeOptions trace 256 rays per block, with 2 bounces, under a white
+// sky.
+var DefaultBakeOptions = BakeOptions{
+	Samples:   256,
+	Bounces:   2,
+	Sky:       [3]float32{1, 1, 1},
+	Albedo:    0.6,
+	Intensity: 2,
+	Seed:      1,
+}
+
+// Bake computes the Lightmap of the structure by path tracing: rays are cast
+// in all directions from each block, gathering the light of the emissive
+// blocks in the registry and of the sky, and bouncing on the opaque blocks.
+// Emissive blocks keep their emission, and opaque blocks are dark, as the
+// faces are lit by the blocks in front of them. It takes seconds to minutes
+// for large structures, so it is meant for offline tools, such as
+// cmd/ovbake, and does not need a window or a GPU.
+func Bake(s *Structure, reg *block.Registry, o BakeOptions) {
+	if o.Samples < 1 {
+		o.Samples = 1
+	}
+	jobs := o.Jobs
+	if jobs == nil {
+		jobs = job.Default
+	}
+	lightmap := make([]light.Color, len(s.Blocks))
+	t := tracer{s: s, reg: reg, o: o}
+	layers := make([]*job.Job, s.SizeY)
+	for y := range layers {
+		y := y
+		layers[y] = jobs.Submit(func() {
+			// Each layer has its own stream, so the result does not
+			// depend on the order the jobs run.
+			r := rng.New(rng.Derive(o.Seed, fmt.Sprintf("bake/%d", y)))
+			for z := 0; z < s.SizeZ; z++ {
+				for x := 0; x < s.SizeX; x++ {
+					lightmap[s.index(x, y, z)] = t.gather(r, x, y, z)
+				}
+			}
+		})
+	}
+	for _, j := range layers {
+		j.Wait()
+	}
+	s.Lightmap = lightmap
+}
+
+// tracer casts the rays of Bake.
+type tracer struct {
+	s   *Structure
+	reg *block.Registry
+	o   BakeOptions
+}
+
+// gather returns the light of the block at x, y, z.
+func (t *tracer) gather(r *rng.Rand, x, y, z int) light.Color {
+	def := t.reg.Get(t.s.Get(x, y, z).ID)
+	if def.Emission != 0 {
+		return def.Emission
+	}
+	if def.Opaque {
+		return 0
+	}
+	var sum [3]float64
+	origin := [3]float64{float64(x) + 0.5, float64(y) + 0.5, float64(z) + 0.5}
+	for i := 0; i < t.o.Samples; i++ {
+		c := t.trace(r, origin, sphere(r), t.o.Bounces)
+		for j := range sum {
+			sum[j] += c[j]
+		}
+	}
+	var level [3]uint8
+	for j := range sum {
+		v := sum[j] / float64(t.o.Samples) * float64(t.o.Intensity) * light.MaxLevel
+		level[j] = uint8(math.Min(light.MaxLevel, math.Round(v)))
+	}
+	return light.RGB(level[0], level[1], level[2])
+}
+
+// trace returns the light reaching origin from the direction dir.
+func (t *tracer) trace(r *rng.Rand, origin, dir [3]float64, bounces int) [3]float64 {
+	cell, normal, ok := t.march(origin, dir)
+	if !ok {
+		return [3]float64{float64(t.o.Sky[0]), float64(t.o.Sky[1]), float64(t.o.Sky[2])}
+	}
+	def := t.reg.Get(t.s.Get(cell[0], cell[1], cell[2]).ID)
+	if e := def.Emission; e != 0 {
+		return [3]float64{float64(e.R()) / light.MaxLevel, float64(e.G()) / light.MaxLevel, float64(e.B()) / light.MaxLevel}
+	}
+	if bounces <= 0 {
+		return [3]float64{}
+	}
+	// Reflect from the center of the face hit, in a cosine weighted
+	// direction around its normal.
+	hit := [3]float64{float64(cell[0]) + 0.5, float64(cell[1]) + 0.5, float64(cell[2]) + 0.5}
+	for i := range hit {
+		hit[i] += float64(normal[i]) * 0.5001
+	}
+	d := sphere(r)
+	for i := range d {
+		d[i] += float64(normal[i])
+	}
+	d = normalize(d)
+	c := t.trace(r, hit, d, bounces-1)
+	for i := range c {
+		c[i] *= float64(t.o.Albedo)
+	}
+	return c
+}
+
+// march walks the blocks along the ray until it leaves the structure, and
+// returns the first opaque or emissive block hit and the normal of the face
+// it entered through.
+func (t *tracer) march(origin, dir [3]float64) (cell, normal [3]int, ok bool) {
+	var step [3]int
+	var next, delta [3]float64
+	for i := 0; i < 3; i++ {
+		cell[i] = int(math.Floor(origin[i]))
+		switch {
+		case dir[i] > 0:
+			step[i] = 1
+			next[i] = (float64(cell[i]+1) - origin[i]) / dir[i]
+			delta[i] = 1 / dir[i]
+		case dir[i] < 0:
+			step[i] = -1
+			next[i] = (origin[i] - float64(cell[i])) / -dir[i]
+			delta[i] = -1 / dir[i]
+		default:
+			next[i] = math.Inf(1)
+			delta[i] = math.Inf(1)
+		}
+	}
+	for {
+		axis := 0
+		if next[1] < next[axis] {
+			axis = 1
+		}
+		if next[2] < next[axis] {
+			axis = 2
+		}
+		cell[axis] += step[axis]
+		next[axis] += delta[axis]
+		if !t.s.Inside(cell[0], cell[1], cell[2]) {
+			return cell, normal, false
+		}
+		def := t.reg.Get(t.s.Get(cell[0], cell[1], cell[2]).ID)
+		if def.Opaque || def.Emission != 0 {
+			normal = [3]int{}
+			normal[axis] = -step[axis]
+			return cell, normal, true
+		}
+	}
+}
+
+// sphere returns a random direction, uniformly distributed.
+func sphere(r *rng.Rand) [3]float64 {
+	z := r.Float64()*2 - 1
+	a := r.Float64() * 2 * math.Pi
+	s := math.Sqrt(1 - z*z)
+	return [3]float64{s * math.Cos(a), s * math.Sin(a), z}
+}
+
+func normalize(v [3]float64) [3]float64 {
+	l := math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+	if l == 0 {
+		return [3]float64{0, 1, 0}
+	}
+	return [3]float64{v[0] / l, v[1] / l, v[2] / l}
+}
@@ -0,0 +1,79 @@
+package structure
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// ErrInvalidLightmap is returned when decoding a malformed lightmap, or one
+// baked for a structure of another size.
+var ErrInvalidLightmap = errors.New("structure: invalid lightmap")
+
+// LightmapExt is appended to the name of the structure file to store its
+// lightmap alongside it, such as "house.vox.light".
+const LightmapExt = ".light"
+
+// EncodeLightmap serializes the Lightmap, to be saved alongside the structure
+// file.
+//
+// The payload is the structure size as three uint32 values, followed by the
+// light of each block, in the order of the Blocks, as an uint16. All values
+// are little endian.
+func (s *Structure) EncodeLightmap() ([]byte, error) {
+	if s.Lightmap == nil {
+		return nil, fmt.Errorf("%w: structure not baked", ErrInvalidLightmap)
+	}
+	data := binary.LittleEndian.AppendUint32(nil, uint32(s.SizeX))
+	data = binary.LittleEndian.AppendUint32(data, uint32(s.SizeY))
+	data = binary.LittleEndian.AppendUint32(data, uint32(s.SizeZ))
+	for _, c := range s.Lightmap {
+		data = binary.LittleEndian.AppendUint16(data, uint16(c))
+	}
+	compressed, err := world.Compress(world.DefaultCompression, data)
+	if err != nil {
+		return nil, err
+	}
+	b := world.AppendHeader(nil, world.Header{Kind: world.KindLightmap, Version: world.CurrentVersion[world.KindLightmap]})
+	return append(b, compressed...), nil
+}
+
+// DecodeLightmap sets the Lightmap serialized by EncodeLightmap.
+func (s *Structure) DecodeLightmap(b []byte) error {
+	h, payload, err := world.ReadHeader(b)
+	if err != nil {
+		return err
+	}
+	if h.Kind != world.KindLightmap {
+		return fmt.Errorf("%w: expected lightmap, got %v", world.ErrInvalidHeader, h.Kind)
+	}
+	data, err := world.Decompress(payload, 12+len(s.Blocks)*2)
+	if err != nil {
+		return err
+	}
+	if data, err = world.Migrate(h.Kind, h.Version, data); err != nil {
+		return err
+	}
+	if len(data) < 12 {
+		return ErrInvalidLightmap
+	}
+	x := int(binary.LittleEndian.Uint32(data[0:]))
+	y := int(binary.LittleEndian.Uint32(data[4:]))
+	z := int(binary.LittleEndian.Uint32(data[8:]))
+	if x != s.SizeX || y != s.SizeY || z != s.SizeZ {
+		return fmt.Errorf("%w: baked for %dx%dx%d, structure is %dx%dx%d", ErrInvalidLightmap, x, y, z, s.SizeX, s.SizeY, s.SizeZ)
+	}
+	data = data[12:]
+	if len(data) != len(s.Blocks)*2 {
+		return fmt.Errorf("%w: unexpected size %d", ErrInvalidLightmap, len(data))
+	}
+	lightmap := make([]light.Color, len(s.Blocks))
+	for i := range lightmap {
+		lightmap[i] = light.Color(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	s.Lightmap = lightmap
+	return nil
+}
@@ -0,0 +1,149 @@
+// package structure implements the static structures pasted into the world,
+// such as buildings and ruins imported from MagicaVoxel .vox files, and the
+// offline baking of their lighting.
+//
+// The runtime voxel light only spreads from emissive blocks, so structures
+// look flat. Bake traces the light bouncing inside the structure, including
+// the sky light coming through its openings, into a lightmap stored alongside
+// the structure, which is blended with the runtime light after pasting.
+package structure
+
+import (
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/light"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// Structure is a box of SizeX x SizeY x SizeZ blocks.
+type Structure struct {
+	SizeX, SizeY, SizeZ int
+	// Blocks are ordered by y, then z, then x. Air blocks are not pasted.
+	Blocks []block.State
+	// Lightmap is the baked light of each block, in the order of the
+	// Blocks, or nil if the structure was not baked.
+	Lightmap []light.Color
+}
+
+// New creates a structure filled with air.
+func New(x, y, z int) *Structure {
+	return &Structure{SizeX: x, SizeY: y, SizeZ: z, Blocks: make([]block.State, x*y*z)}
+}
+
+// Inside returns true if the local coordinates are inside the structure.
+func (s *Structure) Inside(x, y, z int) bool {
+	return x >= 0 && y >= 0 && z >= 0 && x < s.SizeX && y < s.SizeY && z < s.SizeZ
+}
+
+func (s *Structure) index(x, y, z int) int {
+	return (y*s.SizeZ+z)*s.SizeX + x
+}
+
+// Get returns the block at the local coordinates, or air outside the
+// structure.
+func (s *Structure) Get(x, y, z int) block.State {
+	if !s.Inside(x, y, z) {
+		return block.State{ID: block.Air}
+	}
+	return s.Blocks[s.index(x, y, z)]
+}
+
+// Set changes the block at the local coordinates. It invalidates the
+// Lightmap, which must be baked again.
+func (s *Structure) Set(x, y, z int, b block.State) {
+	if s.Inside(x, y, z) {
+		s.Blocks[s.index(x, y, z)] = b
+		s.Lightmap = nil
+	}
+}
+
+// Light returns the baked light at the local coordinates, or zero if the
+// structure was not baked.
+func (s *Structure) Light(x, y, z int) light.Color {
+	if s.Lightmap == nil || !s.Inside(x, y, z) {
+		return 0
+	}
+	return s.Lightmap[s.index(x, y, z)]
+}
+
+// Paste copies the blocks of the structure into the map, with the local
+// origin at the world coordinates x, y, z, and returns the chunks changed,
+// to be lit and meshed again. Air blocks keep the world blocks.
+func (s *Structure) Paste(m *world.Map, x, y, z int) []world.ChunkPos {
+	changed := map[world.ChunkPos]bool{}
+	var out []world.ChunkPos
+	for ly := 0; ly < s.SizeY; ly++ {
+		for lz := 0; lz < s.SizeZ; lz++ {
+			for lx := 0; lx < s.SizeX; lx++ {
+				b := s.Blocks[s.index(lx, ly, lz)]
+				if b.ID == block.Air {
+					continue
+				}
+				m.SetBlock(x+lx, y+ly, z+lz, b)
+				if p := world.ChunkAt(x+lx, y+ly, z+lz); !changed[p] {
+					changed[p] = true
+					out = append(out, p)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// placed is a structure pasted at an origin.
+type placed struct {
+	s       *Structure
+	x, y, z int
+}
+
+// Lights blends the baked light of the pasted structures with the runtime
+// voxel light, to be used as the mesh.Mesher Light function. It is not safe
+// for concurrent use.
+type Lights struct {
+	// Runtime is the runtime light, such as world.Lighting.Light.
+	Runtime func(x, y, z int) light.Color
+
+	chunks map[world.ChunkPos][]*placed
+}
+
+// NewLights creates the blending of the runtime light without structures.
+func NewLights(runtime func(x, y, z int) light.Color) *Lights {
+	return &Lights{Runtime: runtime, chunks: map[world.ChunkPos][]*placed{}}
+}
+
+// Add blends the lightmap of the structure pasted at the world coordinates x,
+// y, z. Structures without a lightmap are ignored.
+func (l *Lights) Add(s *Structure, x, y, z int) {
+	if s.Lightmap == nil {
+		return
+	}
+	p := &placed{s, x, y, z}
+	from := world.ChunkAt(x, y, z)
+	to := world.ChunkAt(x+s.SizeX-1, y+s.SizeY-1, z+s.SizeZ-1)
+	for cy := from.Y; cy <= to.Y; cy++ {
+		for cz := from.Z; cz <= to.Z; cz++ {
+			for cx := from.X; cx <= to.X; cx++ {
+				c := world.ChunkPos{X: cx, Y: cy, Z: cz}
+				l.chunks[c] = append(l.chunks[c], p)
+			}
+		}
+	}
+}
+
+// Remove stops blending the lightmaps in the chunk at p, such as when it is
+// unloaded or a structure in it is destroyed.
+func (l *Lights) Remove(p world.ChunkPos) {
+	delete(l.chunks, p)
+}
+
+// Light returns the per channel maximum of the runtime light and the baked
+// light of the structures at the world coordinates x, y, z.
+func (l *Lights) Light(x, y, z int) light.Color {
+	var c light.Color
+	if l.Runtime != nil {
+		c = l.Runtime(x, y, z)
+	}
+	for _, p := range l.chunks[world.ChunkAt(x, y, z)] {
+		c = c.Max(p.s.Light(x-p.x, y-p.y, z-p.z))
+	}
+	return c
+}
@@ -0,0 +1,114 @@
+package structure
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+// ErrInvalidVox is returned when reading a malformed .vox file.
+var ErrInvalidVox = errors.New("structure: invalid .vox file")
+
+// Palette maps a MagicaVoxel color, by its index from 1 to 255 and its value,
+// to a block state, such as picking the registered block with the closest
+// color. Files without a palette pass a zero color.
+type Palette func(index uint8, c color.RGBA) block.State
+
+// ReadVox reads the first model of a MagicaVoxel .vox file. MagicaVoxel uses
+// the z axis up, so the model is turned to have y up, keeping the model
+// handedness. The scene graph, materials and the other models are ignored.
+func ReadVox(r io.Reader, palette Palette) (*Structure, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 || string(data[:4]) != "VOX " {
+		return nil, fmt.Errorf("%w: missing magic", ErrInvalidVox)
+	}
+	data = data[8:]
+	id, _, children, _, err := voxChunk(data)
+	if err != nil {
+		return nil, err
+	}
+	if id != "MAIN" {
+		return nil, fmt.Errorf("%w: expected MAIN, got %q", ErrInvalidVox, id)
+	}
+
+	var (
+		size   []byte
+		voxels []byte
+		colors [256]color.RGBA
+	)
+	for len(children) > 0 {
+		id, content, _, rest, err := voxChunk(children)
+		if err != nil {
+			return nil, err
+		}
+		children = rest
+		switch id {
+		case "SIZE":
+			if size == nil {
+				size = content
+			}
+		case "XYZI":
+			if voxels == nil {
+				voxels = content
+			}
+		case "RGBA":
+			for i := 0; i < 255 && i*4+3 < len(content); i++ {
+				c := content[i*4:]
+				colors[i+1] = color.RGBA{c[0], c[1], c[2], c[3]}
+			}
+		}
+	}
+	if len(size) < 12 || len(voxels) < 4 {
+		return nil, fmt.Errorf("%w: missing model", ErrInvalidVox)
+	}
+	sx := int(int32(binary.LittleEndian.Uint32(size[0:])))
+	sy := int(int32(binary.LittleEndian.Uint32(size[4:])))
+	sz := int(int32(binary.LittleEndian.Uint32(size[8:])))
+	if sx <= 0 || sy <= 0 || sz <= 0 || sx > 256 || sy > 256 || sz > 256 {
+		return nil, fmt.Errorf("%w: invalid size %dx%dx%d", ErrInvalidVox, sx, sy, sz)
+	}
+	n := int(binary.LittleEndian.Uint32(voxels))
+	voxels = voxels[4:]
+	if len(voxels) < n*4 {
+		return nil, fmt.Errorf("%w: truncated voxels", ErrInvalidVox)
+	}
+
+	s := New(sx, sz, sy)
+	var blocks [256]*block.State
+	for i := 0; i < n; i++ {
+		v := voxels[i*4:]
+		x, y, z, c := int(v[0]), int(v[1]), int(v[2]), v[3]
+		if c == 0 {
+			continue
+		}
+		if blocks[c] == nil {
+			b := palette(c, colors[c])
+			blocks[c] = &b
+		}
+		s.Set(x, z, sy-1-y, *blocks[c])
+	}
+	return s, nil
+}
+
+// voxChunk decodes the chunk at the start of data, and returns the data after
+// it.
+func voxChunk(data []byte) (id string, content, children, rest []byte, err error) {
+	if len(data) < 12 {
+		return "", nil, nil, nil, fmt.Errorf("%w: truncated chunk", ErrInvalidVox)
+	}
+	id = string(data[:4])
+	nc := int(binary.LittleEndian.Uint32(data[4:]))
+	nd := int(binary.LittleEndian.Uint32(data[8:]))
+	data = data[12:]
+	if nc < 0 || nd < 0 || nc+nd > len(data) {
+		return "", nil, nil, nil, fmt.Errorf("%w: chunk %q out of bounds", ErrInvalidVox, id)
+	}
+	return id, data[:nc], data[nc : nc+nd], data[nc+nd:], nil
+}
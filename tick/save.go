@@ -0,0 +1,172 @@
+package tick
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// ErrInvalidTicks is returned when decoding malformed tick data.
+var ErrInvalidTicks = errors.New("tick: invalid tick data")
+
+// EncodeChunk removes the scheduled ticks of the chunk at p and serializes
+// them, to be saved with the chunk when it is unloaded.
+//
+// The payload is the chunk coordinates as three int32 values and the number of
+// ticks as an uint32, followed by each tick as the block index in the chunk,
+// as an uint16, and its remaining delay, as an uint32. Ticks are ordered as
+// they would run. All values are little endian.
+func (s *Scheduler) EncodeChunk(p world.ChunkPos) ([]byte, error) {
+	var ticks, keep queue
+	for _, t := range s.pending {
+		if world.ChunkAt(t.pos[0], t.pos[1], t.pos[2]) != p || s.due[t.pos] != t.due {
+			keep = append(keep, t)
+			continue
+		}
+		ticks = append(ticks, t)
+		delete(s.due, t.pos)
+	}
+	if len(ticks) < len(s.pending) {
+		s.pending = keep
+		heap.Init(&s.pending)
+	}
+	sort.Sort(ticks)
+
+	data := binary.LittleEndian.AppendUint32(nil, uint32(int32(p.X)))
+	data = binary.LittleEndian.AppendUint32(data, uint32(int32(p.Y)))
+	data = binary.LittleEndian.AppendUint32(data, uint32(int32(p.Z)))
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(ticks)))
+	for _, t := range ticks {
+		x, y, z := world.Local(t.pos[0], t.pos[1], t.pos[2])
+		data = binary.LittleEndian.AppendUint16(data, uint16((y*world.SizeZ+z)*world.SizeX+x))
+		delay := uint64(1)
+		if t.due > s.now {
+			delay = t.due - s.now
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(delay))
+	}
+	compressed, err := world.Compress(world.DefaultCompression, data)
+	if err != nil {
+		return nil, err
+	}
+	b := world.AppendHeader(nil, world.Header{Kind: world.KindTicks, Version: world.CurrentVersion[world.KindTicks]})
+	return append(b, compressed...), nil
+}
+
+// maxChunkBytes limits the decompressed size of the ticks of a chunk, at most
+// one for each block.
+const maxChunkBytes = 16 + world.Volume*6
+
+// DecodeChunk schedules the ticks serialized by EncodeChunk, when the chunk is
+// loaded again.
+func (s *Scheduler) DecodeChunk(b []byte) error {
+	h, payload, err := world.ReadHeader(b)
+	if err != nil {
+		return err
+	}
+	if h.Kind != world.KindTicks {
+		return fmt.Errorf("%w: expected ticks, got %v", world.ErrInvalidHeader, h.Kind)
+	}
+	data, err := world.Decompress(payload, maxChunkBytes)
+	if err != nil {
+		return err
+	}
+	if data, err = world.Migrate(h.Kind, h.Version, data); err != nil {
+		return err
+	}
+	if len(data) < 16 {
+		return ErrInvalidTicks
+	}
+	cx := int(int32(binary.LittleEndian.Uint32(data[0:])))
+	cy := int(int32(binary.LittleEndian.Uint32(data[4:])))
+	cz := int(int32(binary.LittleEndian.Uint32(data[8:])))
+	n := int(binary.LittleEndian.Uint32(data[12:]))
+	data = data[16:]
+	if len(data) != n*6 {
+		return fmt.Errorf("%w: unexpected size %d", ErrInvalidTicks, len(data))
+	}
+	for i := 0; i < n; i++ {
+		idx := int(binary.LittleEndian.Uint16(data[i*6:]))
+		delay := binary.LittleEndian.Uint32(data[i*6+2:])
+		if idx >= world.Volume {
+			return fmt.Errorf("%w: block index %d out of range", ErrInvalidTicks, idx)
+		}
+		x, z, y := idx%world.SizeX, idx/world.SizeX%world.SizeZ, idx/(world.SizeX*world.SizeZ)
+		p := [3]int{cx*world.SizeX + x, cy*world.SizeY + y, cz*world.SizeZ + z}
+		s.schedule(p, s.now+uint64(delay))
+	}
+	return nil
+}
+
+// MarshalBinary encodes the whole state of the scheduler, including the
+// random ticks generator and the scheduled ticks of all chunks, such as to
+// rewind the simulation. Handlers are not encoded.
+//
+// The format is the tick count and the scheduling sequence as uint64 values,
+// the time elapsed since the last tick as a float64, the 32 bytes of the
+// random generator state and the number of ticks as an uint32, followed by
+// each tick as its world coordinates as three int32 values, its due tick and
+// its sequence as uint64 values. All values are little endian.
+func (s *Scheduler) MarshalBinary() ([]byte, error) {
+	data := binary.LittleEndian.AppendUint64(nil, s.now)
+	data = binary.LittleEndian.AppendUint64(data, s.seq)
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(s.elapsed))
+	r, err := s.rand.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, r...)
+	var ticks queue
+	for _, t := range s.pending {
+		if s.due[t.pos] == t.due {
+			ticks = append(ticks, t)
+		}
+	}
+	sort.Sort(ticks)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(ticks)))
+	for _, t := range ticks {
+		for _, v := range t.pos {
+			data = binary.LittleEndian.AppendUint32(data, uint32(int32(v)))
+		}
+		data = binary.LittleEndian.AppendUint64(data, t.due)
+		data = binary.LittleEndian.AppendUint64(data, t.seq)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary replaces the state of the scheduler with the one encoded by
+// MarshalBinary, keeping the handlers.
+func (s *Scheduler) UnmarshalBinary(data []byte) error {
+	const header, size = 60, 28
+	if len(data) < header {
+		return ErrInvalidTicks
+	}
+	n := int(binary.LittleEndian.Uint32(data[56:]))
+	if len(data) != header+n*size {
+		return fmt.Errorf("%w: unexpected size %d", ErrInvalidTicks, len(data))
+	}
+	if err := s.rand.UnmarshalBinary(data[24:56]); err != nil {
+		return err
+	}
+	s.now = binary.LittleEndian.Uint64(data[0:])
+	s.seq = binary.LittleEndian.Uint64(data[8:])
+	s.elapsed = math.Float64frombits(binary.LittleEndian.Uint64(data[16:]))
+	s.pending = s.pending[:0]
+	s.due = make(map[[3]int]uint64, n)
+	for i := 0; i < n; i++ {
+		b := data[header+i*size:]
+		t := scheduled{due: binary.LittleEndian.Uint64(b[12:]), seq: binary.LittleEndian.Uint64(b[20:])}
+		for j := range t.pos {
+			t.pos[j] = int(int32(binary.LittleEndian.Uint32(b[j*4:])))
+		}
+		s.pending = append(s.pending, t)
+		s.due[t.pos] = t.due
+	}
+	heap.Init(&s.pending)
+	return nil
+}
@@ -0,0 +1,231 @@
+// package tick schedules the block updates of the world: random ticks, which
+// update a few random blocks of each loaded chunk on every tick, such as
+// growing crops and spreading grass, and scheduled ticks, which update a block
+// after a delay, such as flowing fluids and redstone-like mechanics.
+//
+// Ticks only run on loaded chunks, and always in the same order for the same
+// seed and inputs, so worlds evolve identically on every machine.
+package tick
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/rng"
+	"github.com/ronoaldo/openvoxel/world"
+)
+
+// Handler updates the block s at the world coordinates x, y, z.
+type Handler func(x, y, z int, s block.State)
+
+// scheduled is a pending scheduled tick.
+type scheduled struct {
+	due, seq uint64
+	pos      [3]int
+}
+
+// queue is a priority queue of scheduled ticks, ordered by due tick and then
+// by the order they were scheduled.
+type queue []scheduled
+
+func (q queue) Len() int { return len(q) }
+func (q queue) Less(i, j int) bool {
+	if q[i].due != q[j].due {
+		return q[i].due < q[j].due
+	}
+	return q[i].seq < q[j].seq
+}
+func (q queue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x any)   { *q = append(*q, x.(scheduled)) }
+func (q *queue) Pop() any {
+	old := *q
+	v := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return v
+}
+
+type periodic struct {
+	interval uint64
+	fn       func()
+}
+
+// Scheduler runs the block ticks of a world.
+type Scheduler struct {
+	Map *world.Map
+	// Rate is the number of ticks per second run by Update.
+	Rate float64
+	// RandomTicks is the number of blocks picked in each loaded chunk on
+	// every tick.
+	RandomTicks int
+
+	now, seq uint64
+	elapsed  float64
+	rand     *rng.Rand
+
+	random   map[block.ID]Handler
+	handlers map[block.ID]Handler
+	periodic []periodic
+
+	pending queue
+	// due holds the earliest due tick of each position in pending, so a
+	// block is not scheduled twice.
+	due map[[3]int]uint64
+}
+
+// New creates a scheduler for the world with the seed, running 20 ticks per
+// second with 3 random ticks per chunk.
+func New(m *world.Map, seed uint64) *Scheduler {
+	return &Scheduler{
+		Map:         m,
+		Rate:        20,
+		RandomTicks: 3,
+		rand:        rng.New(rng.Derive(seed, "tick")),
+		random:      map[block.ID]Handler{},
+		handlers:    map[block.ID]Handler{},
+		due:         map[[3]int]uint64{},
+	}
+}
+
+// OnRandomTick sets the handler called when a block with the id is picked by
+// a random tick.
+func (s *Scheduler) OnRandomTick(id block.ID, fn Handler) {
+	s.random[id] = fn
+}
+
+// OnScheduledTick sets the handler called for the scheduled ticks of the
+// blocks with the id. Scheduled ticks of positions that hold another block
+// when they run are ignored.
+func (s *Scheduler) OnScheduledTick(id block.ID, fn Handler) {
+	s.handlers[id] = fn
+}
+
+// Every calls fn once every interval ticks, after the block ticks, for
+// systems that update on their own, such as the fluid.Simulator.
+func (s *Scheduler) Every(interval int, fn func()) {
+	if interval < 1 {
+		interval = 1
+	}
+	s.periodic = append(s.periodic, periodic{uint64(interval), fn})
+}
+
+// Now returns the number of ticks run.
+func (s *Scheduler) Now() uint64 {
+	return s.now
+}
+
+// Pending returns the number of scheduled ticks waiting to run.
+func (s *Scheduler) Pending() int {
+	return len(s.due)
+}
+
+// Schedule updates the block at the world coordinates x, y, z after delay
+// ticks, at least one. If the block is already scheduled, the earliest tick
+// is kept.
+func (s *Scheduler) Schedule(x, y, z int, delay int) {
+	if delay < 1 {
+		delay = 1
+	}
+	s.schedule([3]int{x, y, z}, s.now+uint64(delay))
+}
+
+func (s *Scheduler) schedule(p [3]int, due uint64) {
+	if d, ok := s.due[p]; ok && d <= due {
+		return
+	}
+	s.due[p] = due
+	s.seq++
+	heap.Push(&s.pending, scheduled{due: due, seq: s.seq, pos: p})
+}
+
+// Update runs the ticks due after dt seconds, and returns how many ran. It is
+// called from the fixed timestep game update.
+func (s *Scheduler) Update(dt float64) int {
+	s.elapsed += dt
+	step := 1 / s.Rate
+	n := 0
+	for s.elapsed >= step {
+		s.elapsed -= step
+		s.Tick()
+		n++
+	}
+	return n
+}
+
+// Tick runs a single tick: the scheduled ticks due, the random ticks and the
+// periodic systems, in that order.
+func (s *Scheduler) Tick() {
+	s.now++
+	s.runScheduled()
+	s.runRandom()
+	for _, p := range s.periodic {
+		if s.now%p.interval == 0 {
+			p.fn()
+		}
+	}
+}
+
+func (s *Scheduler) runScheduled() {
+	var unloaded []scheduled
+	for len(s.pending) > 0 && s.pending[0].due <= s.now {
+		t := heap.Pop(&s.pending).(scheduled)
+		if s.due[t.pos] != t.due {
+			// Superseded by an earlier tick of the same block.
+			continue
+		}
+		p := t.pos
+		if s.Map.Chunk(world.ChunkAt(p[0], p[1], p[2])) == nil {
+			unloaded = append(unloaded, t)
+			continue
+		}
+		delete(s.due, p)
+		b := s.Map.Block(p[0], p[1], p[2])
+		if fn := s.handlers[b.ID]; fn != nil {
+			fn(p[0], p[1], p[2], b)
+		}
+	}
+	// Ticks of unloaded chunks wait for them to load again.
+	for _, t := range unloaded {
+		t.due = s.now + 1
+		s.due[t.pos] = t.due
+		heap.Push(&s.pending, t)
+	}
+}
+
+func (s *Scheduler) runRandom() {
+	if s.RandomTicks <= 0 || len(s.random) == 0 {
+		return
+	}
+	var chunks []*world.Chunk
+	s.Map.Each(func(c *world.Chunk) {
+		for _, b := range c.Palette() {
+			if s.random[b.ID] != nil {
+				chunks = append(chunks, c)
+				return
+			}
+		}
+	})
+	// The map is not ordered, so sort the chunks for the ticks to be
+	// deterministic.
+	sort.Slice(chunks, func(i, j int) bool {
+		a, b := chunks[i], chunks[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		return a.X < b.X
+	})
+	for _, c := range chunks {
+		ox, oy, oz := c.Origin()
+		for i := 0; i < s.RandomTicks; i++ {
+			n := s.rand.Intn(world.Volume)
+			x, z, y := n%world.SizeX, n/world.SizeX%world.SizeZ, n/(world.SizeX*world.SizeZ)
+			b := c.Get(x, y, z)
+			if fn := s.random[b.ID]; fn != nil {
+				fn(ox+x, oy+y, oz+z, b)
+			}
+		}
+	}
+}
@@ -2,7 +2,11 @@
 // matrices.
 package transform
 
-import glm "github.com/go-gl/mathgl/mgl32"
+import (
+	"math"
+
+	glm "github.com/go-gl/mathgl/mgl32"
+)
 
 // RadToDeg converts the value in radians to degrees.
 func RadToDeg(rad float32) float32 {
@@ -48,3 +52,32 @@ func Chain(operations ...glm.Mat4) glm.Mat4 {
 func LookAt(eye, center, up glm.Vec3) glm.Mat4 {
 	return glm.LookAtV(glm.Vec3(eye), glm.Vec3(center), glm.Vec3(up))
 }
+
+// WorldToScreen projects the world position to window coordinates, in pixels
+// from the top left corner, using the view and projection matrices. The depth
+// is the normalized device depth, from -1 to 1. It returns false if the
+// position is behind the camera.
+func WorldToScreen(pos glm.Vec3, view, projection glm.Mat4, width, height int) (x, y, depth float32, ok bool) {
+	clip := projection.Mul4(view).Mul4x1(pos.Vec4(1))
+	if clip.W() <= 0 {
+		return 0, 0, 0, false
+	}
+	ndc := clip.Vec3().Mul(1 / clip.W())
+	x = (ndc.X() + 1) / 2 * float32(width)
+	y = (1 - ndc.Y()) / 2 * float32(height)
+	return x, y, ndc.Z(), true
+}
+
+// PerspectiveReverseZ creates a perspective projection with an infinite far
+// plane that maps the near plane to depth 1 and the infinity to depth 0. It
+// must be used with a [0, 1] clip depth range and a greater depth test, and
+// keeps the depth precision evenly spread over very large view distances.
+func PerspectiveReverseZ(fov, aspect, near float32) glm.Mat4 {
+	f := 1 / float32(math.Tan(float64(fov)/2))
+	var m glm.Mat4
+	m[0] = f / aspect
+	m[5] = f
+	m[11] = -1
+	m[14] = near
+	return m
+}
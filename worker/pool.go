@@ -0,0 +1,73 @@
+//go:build !js
+
+package worker
+
+import (
+	"sync"
+
+	"github.com/ronoaldo/openvoxel/job"
+)
+
+// Pool runs tasks as background jobs of a job.Scheduler.
+type Pool struct {
+	jobs *job.Scheduler
+	// own is true if the scheduler was started by the pool, and is closed
+	// with it.
+	own     bool
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// NewPool starts a pool with n workers. If n is zero or negative, the tasks
+// run on job.Default, shared with the other engine workloads.
+func NewPool(n int) (*Pool, error) {
+	if n <= 0 {
+		return &Pool{jobs: job.Default}, nil
+	}
+	return &Pool{jobs: job.NewScheduler(n), own: true}, nil
+}
+
+// Submit queues the named task. The request must not be modified until the
+// call is done.
+func (p *Pool) Submit(name string, req []byte) *Call {
+	c := newCall(name, req)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		c.finish(nil, ErrClosed)
+		return c
+	}
+	p.pending.Add(1)
+	p.jobs.Submit(func() {
+		defer p.pending.Done()
+		c.finish(run(c.Name, c.Request))
+	})
+	return c
+}
+
+// Close waits for the pending tasks and stops the workers started by the
+// pool.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	p.mu.Unlock()
+	p.pending.Wait()
+	if p.own {
+		p.jobs.Close()
+	}
+}
+
+// IsWorker reports whether the program was started as a worker. It is always
+// false on native builds.
+func IsWorker() bool {
+	return false
+}
+
+// Serve processes the tasks sent by the main thread. It is only meaningful on
+// the web, and returns immediately on native builds.
+func Serve() {}
@@ -0,0 +1,205 @@
+package worker
+
+import (
+	"errors"
+	"sync"
+	"syscall/js"
+)
+
+// ScriptURL is the script used to bootstrap the Web Workers. It loads
+// wasm_exec.js and the wasm module named by the global openvoxelWasm variable
+// set by the page.
+var ScriptURL = "worker.js"
+
+// Pool runs tasks on a fixed number of Web Workers.
+type Pool struct {
+	mu      sync.Mutex
+	workers []*webWorker
+	pending map[int]*Call
+	nextID  int
+	closed  bool
+}
+
+type webWorker struct {
+	v         js.Value
+	busy      int
+	onMessage js.Func
+	onError   js.Func
+	calls     map[int]bool
+}
+
+// NewPool starts a pool with n workers. If n is zero or negative, one worker
+// per logical processor not used by the main thread is started.
+func NewPool(n int) (*Pool, error) {
+	wasm := js.Global().Get("openvoxelWasm")
+	if wasm.Type() != js.TypeString {
+		return nil, errors.New("worker: openvoxelWasm is not set by the page")
+	}
+	if js.Global().Get("Worker").IsUndefined() {
+		return nil, errors.New("worker: Web Workers are not supported")
+	}
+	if n <= 0 {
+		n = js.Global().Get("navigator").Get("hardwareConcurrency").Int() - 1
+		if n < 1 {
+			n = 1
+		}
+	}
+	p := &Pool{pending: make(map[int]*Call)}
+	opts := js.Global().Get("Object").New()
+	opts.Set("name", wasm)
+	for i := 0; i < n; i++ {
+		w := &webWorker{
+			v:     js.Global().Get("Worker").New(ScriptURL, opts),
+			calls: make(map[int]bool),
+		}
+		w.onMessage = js.FuncOf(func(this js.Value, args []js.Value) any {
+			p.receive(w, args[0].Get("data"))
+			return nil
+		})
+		w.onError = js.FuncOf(func(this js.Value, args []js.Value) any {
+			p.fail(w, errors.New("worker: "+args[0].Get("message").String()))
+			return nil
+		})
+		w.v.Set("onmessage", w.onMessage)
+		w.v.Set("onerror", w.onError)
+		p.workers = append(p.workers, w)
+	}
+	return p, nil
+}
+
+// Submit queues the named task on the least busy worker. The request is
+// copied, so it can be reused right away.
+func (p *Pool) Submit(name string, req []byte) *Call {
+	c := newCall(name, req)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		c.finish(nil, ErrClosed)
+		return c
+	}
+	w := p.workers[0]
+	for _, o := range p.workers[1:] {
+		if o.busy < w.busy {
+			w = o
+		}
+	}
+	id := p.nextID
+	p.nextID++
+	p.pending[id] = c
+	w.calls[id] = true
+	w.busy++
+
+	data := bytesToJS(req)
+	msg := js.Global().Get("Object").New()
+	msg.Set("id", id)
+	msg.Set("name", name)
+	msg.Set("data", data)
+	w.v.Call("postMessage", msg, []any{data.Get("buffer")})
+	return c
+}
+
+func (p *Pool) receive(w *webWorker, msg js.Value) {
+	id := msg.Get("id").Int()
+	p.mu.Lock()
+	c, ok := p.pending[id]
+	delete(p.pending, id)
+	delete(w.calls, id)
+	w.busy--
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	if e := msg.Get("error"); e.Truthy() {
+		c.finish(nil, errors.New(e.String()))
+		return
+	}
+	c.finish(bytesFromJS(msg.Get("data")), nil)
+}
+
+// fail finishes all the calls pending on w with err.
+func (p *Pool) fail(w *webWorker, err error) {
+	p.mu.Lock()
+	var calls []*Call
+	for id := range w.calls {
+		calls = append(calls, p.pending[id])
+		delete(p.pending, id)
+	}
+	w.calls = make(map[int]bool)
+	w.busy = 0
+	p.mu.Unlock()
+	for _, c := range calls {
+		c.finish(nil, err)
+	}
+}
+
+// Close terminates the workers. Pending calls finish with ErrClosed.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	workers := p.workers
+	p.mu.Unlock()
+	for _, w := range workers {
+		w.v.Call("terminate")
+		p.fail(w, ErrClosed)
+		w.onMessage.Release()
+		w.onError.Release()
+	}
+}
+
+// IsWorker reports whether the program was started as a worker by a Pool.
+func IsWorker() bool {
+	g := js.Global()
+	return g.Get("document").IsUndefined() && !g.Get("importScripts").IsUndefined()
+}
+
+// Serve processes the tasks sent by the main thread. It never returns.
+func Serve() {
+	self := js.Global()
+	handle := func(msg js.Value) {
+		id := msg.Get("id").Int()
+		name := msg.Get("name").String()
+		req := bytesFromJS(msg.Get("data"))
+		go func() {
+			resp, err := run(name, req)
+			out := self.Get("Object").New()
+			out.Set("id", id)
+			if err != nil {
+				out.Set("error", err.Error())
+				self.Call("postMessage", out)
+				return
+			}
+			data := bytesToJS(resp)
+			out.Set("data", data)
+			self.Call("postMessage", out, []any{data.Get("buffer")})
+		}()
+	}
+	self.Set("onmessage", js.FuncOf(func(this js.Value, args []js.Value) any {
+		handle(args[0].Get("data"))
+		return nil
+	}))
+	// Messages received while the module was loading are queued by the
+	// bootstrap script.
+	if queued := self.Get("openvoxelPending"); queued.Truthy() {
+		for i := 0; i < queued.Length(); i++ {
+			handle(queued.Index(i))
+		}
+	}
+	self.Delete("openvoxelPending")
+	select {}
+}
+
+func bytesToJS(b []byte) js.Value {
+	arr := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(arr, b)
+	return arr
+}
+
+func bytesFromJS(v js.Value) []byte {
+	b := make([]byte, v.Length())
+	js.CopyBytesToGo(b, v)
+	return b
+}
@@ -0,0 +1,88 @@
+// Package worker runs CPU heavy tasks, such as meshing and world generation,
+// away from the thread that renders the frames.
+//
+// Tasks are registered by name and exchange plain bytes, so they can cross
+// process boundaries. On native builds they run on goroutines. On the web,
+// where the Go runtime is single threaded, each worker is a dedicated Web
+// Worker running its own copy of the wasm module, and requests and responses
+// are transferred with postMessage.
+//
+// Programs that use a Pool on the web must call Serve at the start of main, so
+// the same wasm binary can act as a worker:
+//
+//	func main() {
+//		worker.Register("mesh", mesher.Handler())
+//		if worker.IsWorker() {
+//			worker.Serve()
+//			return
+//		}
+//		...
+//	}
+package worker
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Script is the worker bootstrap script, worker.js, that must be served next
+// to wasm_exec.js for pools to start on the web.
+//
+//go:embed worker.js
+var Script []byte
+
+// Handler processes a task request and returns its response.
+type Handler func(req []byte) ([]byte, error)
+
+// ErrClosed is returned by calls submitted to a closed Pool.
+var ErrClosed = errors.New("worker: pool is closed")
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]Handler)
+)
+
+// Register makes h available to process the tasks with the provided name. It
+// panics if the name is already in use. Handlers must be registered both on
+// the main program and on the workers, so Register is usually called from
+// init or at the start of main.
+func Register(name string, h Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	if _, dup := handlers[name]; dup {
+		panic("worker: Register called twice for " + name)
+	}
+	handlers[name] = h
+}
+
+// run executes the named task in the current thread.
+func run(name string, req []byte) ([]byte, error) {
+	handlersMu.RLock()
+	h, ok := handlers[name]
+	handlersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("worker: no handler registered for %q", name)
+	}
+	return h(req)
+}
+
+// Call is a task submitted to a Pool.
+type Call struct {
+	Name     string
+	Request  []byte
+	Response []byte
+	Err      error
+	// Done receives the call when it finishes.
+	Done chan *Call
+}
+
+func newCall(name string, req []byte) *Call {
+	return &Call{Name: name, Request: req, Done: make(chan *Call, 1)}
+}
+
+func (c *Call) finish(resp []byte, err error) {
+	c.Response, c.Err = resp, err
+	c.Done <- c
+}
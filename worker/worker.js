@@ -0,0 +1,16 @@
+// worker.js bootstraps a Web Worker for the worker package. The worker runs
+// the wasm module named by the worker name, which is expected to call
+// worker.Serve on start.
+importScripts("wasm_exec.js");
+
+// Queue the requests received while the module loads; worker.Serve drains
+// them on start.
+self.openvoxelPending = [];
+self.onmessage = (e) => self.openvoxelPending.push(e.data);
+
+const go = new Go();
+const source = fetch(self.name);
+const instance = WebAssembly.instantiateStreaming ?
+	WebAssembly.instantiateStreaming(source, go.importObject) :
+	source.then(r => r.arrayBuffer()).then(b => WebAssembly.instantiate(b, go.importObject));
+instance.then(result => go.run(result.instance));
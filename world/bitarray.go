@@ -0,0 +1,63 @@
+package world
+
+// bitArray stores fixed width unsigned integers packed into 64 bit words.
+// Entries never span two words, which keeps reads and writes simple.
+type bitArray struct {
+	bits    uint
+	perWord int
+	mask    uint64
+	words   []uint64
+}
+
+// newBitArray creates an array of n entries with the provided width. A width
+// of zero stores no data, and all entries read as zero.
+func newBitArray(n int, bits uint) bitArray {
+	a := bitArray{bits: bits}
+	if bits == 0 {
+		return a
+	}
+	a.perWord = 64 / int(bits)
+	a.mask = 1<<bits - 1
+	a.words = make([]uint64, (n+a.perWord-1)/a.perWord)
+	return a
+}
+
+func (a *bitArray) get(i int) uint16 {
+	if a.bits == 0 {
+		return 0
+	}
+	w, o := i/a.perWord, uint(i%a.perWord)*a.bits
+	return uint16(a.words[w] >> o & a.mask)
+}
+
+func (a *bitArray) set(i int, v uint16) {
+	w, o := i/a.perWord, uint(i%a.perWord)*a.bits
+	a.words[w] = a.words[w]&^(a.mask<<o) | uint64(v)<<o
+}
+
+// unpack decodes the first len(dst) entries into dst, one word at a time.
+func (a *bitArray) unpack(dst []uint16) {
+	if a.bits == 0 {
+		for i := range dst {
+			dst[i] = 0
+		}
+		return
+	}
+	i := 0
+	for _, w := range a.words {
+		for j := 0; j < a.perWord && i < len(dst); j++ {
+			dst[i] = uint16(w & a.mask)
+			w >>= a.bits
+			i++
+		}
+	}
+}
+
+// bitsFor returns the number of bits required to store values up to n-1.
+func bitsFor(n int) uint {
+	bits := uint(0)
+	for (1 << bits) < n {
+		bits++
+	}
+	return bits
+}
@@ -0,0 +1,177 @@
+// package world implements the storage of the voxel world.
+package world
+
+import (
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/light"
+)
+
+// Chunk dimensions, in blocks. Chunks are cubes, stacked vertically without
+// limit, so worlds have no minimum or maximum altitude.
+const (
+	Size   = 16
+	SizeX  = Size
+	SizeY  = Size
+	SizeZ  = Size
+	Volume = SizeX * SizeY * SizeZ
+)
+
+// Chunk is a cube of SizeX x SizeY x SizeZ blocks, at the chunk coordinates
+// X, Y, Z.
+//
+// Blocks are stored as indices into a per-chunk palette of block states, so
+// that blocks with metadata take no more memory than plain block IDs. The
+// indices are bit-packed using just enough bits for the palette size: once
+// compacted, a chunk with a single block type uses no memory for the blocks at
+// all, and typical terrain needs only 2 to 4 bits per block. States no longer
+// used are dropped from the palette by Compact, which also runs before the
+// indices grow wider, so the palette never holds more than the states in use
+// and the one being added.
+type Chunk struct {
+	X, Y, Z int
+
+	palette []block.State
+	blocks  bitArray
+	// lights is the light of each block, allocated when the first block is
+	// lit. It is not saved, as it is computed again by Lighting.
+	lights []light.Color
+}
+
+// NewChunk creates a chunk at the chunk coordinates x, y, z filled with air.
+func NewChunk(x, y, z int) *Chunk {
+	return &Chunk{
+		X:       x,
+		Y:       y,
+		Z:       z,
+		palette: []block.State{{ID: block.Air}},
+	}
+}
+
+func index(x, y, z int) int {
+	return (y*SizeZ+z)*SizeX + x
+}
+
+// Pos returns the chunk coordinates.
+func (c *Chunk) Pos() ChunkPos {
+	return ChunkPos{c.X, c.Y, c.Z}
+}
+
+// Origin returns the world coordinates of the chunk block at local 0, 0, 0.
+func (c *Chunk) Origin() (x, y, z int) {
+	return c.X * SizeX, c.Y * SizeY, c.Z * SizeZ
+}
+
+// Inside returns true if the local coordinates are within the chunk bounds.
+func Inside(x, y, z int) bool {
+	return x >= 0 && x < SizeX && y >= 0 && y < SizeY && z >= 0 && z < SizeZ
+}
+
+// Get returns the block state at the local coordinates x, y, z.
+func (c *Chunk) Get(x, y, z int) block.State {
+	return c.palette[c.blocks.get(index(x, y, z))]
+}
+
+// Set changes the block state at the local coordinates x, y, z.
+func (c *Chunk) Set(x, y, z int, s block.State) {
+	i := c.paletteIndex(s)
+	if c.blocks.bits == 0 && i == 0 {
+		// Nothing to store for uniform chunks
+		return
+	}
+	c.blocks.set(index(x, y, z), i)
+}
+
+// paletteIndex returns the palette index of s, adding it if needed. Growing
+// the palette may require repacking the blocks with more bits per entry.
+func (c *Chunk) paletteIndex(s block.State) uint16 {
+	for i, p := range c.palette {
+		if p == s {
+			return uint16(i)
+		}
+	}
+	if bitsFor(len(c.palette)+1) > c.blocks.bits {
+		// Drop the unused states first, which may leave room for s.
+		c.Compact()
+	}
+	c.palette = append(c.palette, s)
+	if bits := bitsFor(len(c.palette)); bits > c.blocks.bits {
+		c.repack(bits, nil)
+	}
+	return uint16(len(c.palette) - 1)
+}
+
+// repack stores the blocks with the new number of bits per entry, optionally
+// remapping the palette indices.
+func (c *Chunk) repack(bits uint, remap []uint16) {
+	indices := c.Indices(nil)
+	c.blocks = newBitArray(Volume, bits)
+	if bits == 0 {
+		return
+	}
+	for i, v := range indices {
+		if remap != nil {
+			v = remap[v]
+		}
+		if v != 0 {
+			c.blocks.set(i, v)
+		}
+	}
+}
+
+// BitsPerBlock returns the number of bits used to store each block.
+func (c *Chunk) BitsPerBlock() uint {
+	return c.blocks.bits
+}
+
+// Indices decodes the palette index of every block into dst, which is
+// allocated if it is smaller than Volume. Blocks are ordered by y, then z,
+// then x. Decoding all blocks at once is much faster than calling Get for each
+// block, and is the preferred way for the mesher to read the chunk.
+func (c *Chunk) Indices(dst []uint16) []uint16 {
+	if len(dst) < Volume {
+		dst = make([]uint16, Volume)
+	}
+	dst = dst[:Volume]
+	c.blocks.unpack(dst)
+	return dst
+}
+
+// Each calls fn for every block in the chunk, ordered by y, then z, then x.
+func (c *Chunk) Each(fn func(x, y, z int, s block.State)) {
+	indices := c.Indices(nil)
+	i := 0
+	for y := 0; y < SizeY; y++ {
+		for z := 0; z < SizeZ; z++ {
+			for x := 0; x < SizeX; x++ {
+				fn(x, y, z, c.palette[indices[i]])
+				i++
+			}
+		}
+	}
+}
+
+// Palette returns the block states in use by the chunk. The returned slice
+// must not be modified.
+func (c *Chunk) Palette() []block.State {
+	return c.palette
+}
+
+// Compact removes unused states from the palette, and repacks the blocks with
+// fewer bits if possible. A chunk filled with a single state, even if it is
+// not air, is left with no bits per block.
+func (c *Chunk) Compact() {
+	used := make([]bool, len(c.palette))
+	for _, i := range c.Indices(nil) {
+		used[i] = true
+	}
+	remap := make([]uint16, len(c.palette))
+	palette := c.palette[:0]
+	for i, s := range c.palette {
+		if used[i] {
+			remap[i] = uint16(len(palette))
+			palette = append(palette, s)
+		}
+	}
+	c.palette = palette
+	c.repack(bitsFor(len(palette)), remap)
+}
@@ -0,0 +1,62 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+func fill(c *Chunk, s block.State) {
+	for y := 0; y < SizeY; y++ {
+		for z := 0; z < SizeZ; z++ {
+			for x := 0; x < SizeX; x++ {
+				c.Set(x, y, z, s)
+			}
+		}
+	}
+}
+
+func TestCompactUniformChunk(t *testing.T) {
+	c := NewChunk(0, 0, 0)
+	stone := block.State{ID: 1}
+	fill(c, stone)
+	c.Compact()
+	if bits := c.BitsPerBlock(); bits != 0 {
+		t.Errorf("uniform stone chunk uses %d bits per block, want 0", bits)
+	}
+	if p := c.Palette(); len(p) != 1 || p[0] != stone {
+		t.Errorf("palette = %v, want only stone", p)
+	}
+	if s := c.Get(3, 4, 5); s != stone {
+		t.Errorf("Get = %v, want stone", s)
+	}
+	c.Set(3, 4, 5, block.State{})
+	if s := c.Get(3, 4, 5); s != (block.State{}) {
+		t.Errorf("Get after Set = %v, want air", s)
+	}
+	if s := c.Get(3, 4, 6); s != stone {
+		t.Errorf("Get of a neighbor = %v, want stone", s)
+	}
+}
+
+func TestPaletteBounded(t *testing.T) {
+	c := NewChunk(0, 0, 0)
+	// Overwrite the same blocks with many states: only the last ones stay
+	// in use, so the palette must not keep growing.
+	for i := 1; i <= 1000; i++ {
+		for x := 0; x < 4; x++ {
+			c.Set(x, 0, 0, block.State{ID: block.ID(i), Meta: uint16(x)})
+		}
+	}
+	if n := len(c.Palette()); n > 16 {
+		t.Errorf("palette has %d states for 5 in use", n)
+	}
+	for x := 0; x < 4; x++ {
+		if s, want := c.Get(x, 0, 0), (block.State{ID: 1000, Meta: uint16(x)}); s != want {
+			t.Errorf("Get(%d, 0, 0) = %v, want %v", x, s, want)
+		}
+	}
+	if s := c.Get(5, 5, 5); s != (block.State{}) {
+		t.Errorf("Get of an untouched block = %v, want air", s)
+	}
+}
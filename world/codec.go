@@ -0,0 +1,143 @@
+package world
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+// ErrInvalidChunk is returned when decoding malformed chunk data.
+var ErrInvalidChunk = errors.New("world: invalid chunk data")
+
+// MarshalBinary encodes the chunk, including its palette of block states.
+//
+// The format is the chunk coordinates as three int32 values, the palette length
+// as an uint16, followed by each palette entry as an (id, meta) pair of uint16
+// values, and finally the bit-packed block indices as uint64 words. The number
+// of bits per block is derived from the palette length. All values are little
+// endian.
+func (c *Chunk) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, 14+len(c.palette)*4+len(c.blocks.words)*8)
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(c.X)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(c.Y)))
+	b = binary.LittleEndian.AppendUint32(b, uint32(int32(c.Z)))
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(c.palette)))
+	for _, s := range c.palette {
+		b = binary.LittleEndian.AppendUint16(b, uint16(s.ID))
+		b = binary.LittleEndian.AppendUint16(b, s.Meta)
+	}
+	for _, w := range c.blocks.words {
+		b = binary.LittleEndian.AppendUint64(b, w)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the chunk data encoded by MarshalBinary.
+func (c *Chunk) UnmarshalBinary(b []byte) error {
+	if len(b) < 14 {
+		return ErrInvalidChunk
+	}
+	x := int32(binary.LittleEndian.Uint32(b[0:]))
+	y := int32(binary.LittleEndian.Uint32(b[4:]))
+	z := int32(binary.LittleEndian.Uint32(b[8:]))
+	n := int(binary.LittleEndian.Uint16(b[12:]))
+	b = b[14:]
+	if n == 0 {
+		return fmt.Errorf("%w: empty palette", ErrInvalidChunk)
+	}
+	blocks := newBitArray(Volume, bitsFor(n))
+	if len(b) != n*4+len(blocks.words)*8 {
+		return fmt.Errorf("%w: unexpected size %d", ErrInvalidChunk, len(b))
+	}
+
+	palette := make([]block.State, n)
+	for i := range palette {
+		palette[i].ID = block.ID(binary.LittleEndian.Uint16(b[i*4:]))
+		palette[i].Meta = binary.LittleEndian.Uint16(b[i*4+2:])
+	}
+	b = b[n*4:]
+	for i := range blocks.words {
+		blocks.words[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+	for i, v := range (&Chunk{blocks: blocks}).Indices(nil) {
+		if int(v) >= n {
+			return fmt.Errorf("%w: palette index %d out of range at %d", ErrInvalidChunk, v, i)
+		}
+	}
+
+	c.X, c.Y, c.Z = int(x), int(y), int(z)
+	c.palette = palette
+	c.blocks = blocks
+	return nil
+}
+
+// MarshalBinary encodes all the loaded chunks, such as to keep a snapshot of
+// the world in memory. The format is the number of chunks as an uint32,
+// followed by each chunk, ordered by position, as its length in bytes as an
+// uint32 and the data encoded by Chunk.MarshalBinary. All values are little
+// endian.
+func (m *Map) MarshalBinary() ([]byte, error) {
+	chunks := make([]*Chunk, 0, len(m.chunks))
+	for _, c := range m.chunks {
+		chunks = append(chunks, c)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		a, b := chunks[i], chunks[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		return a.X < b.X
+	})
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(chunks)))
+	for _, c := range chunks {
+		data, err := c.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+		b = append(b, data...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces the loaded chunks with the ones encoded by
+// MarshalBinary. Chunks loaded at the same positions are updated in place, so
+// references to them stay valid, and the other ones are unloaded. The light
+// is not restored, as it is computed again by Lighting.
+func (m *Map) UnmarshalBinary(b []byte) error {
+	if len(b) < 4 {
+		return ErrInvalidChunk
+	}
+	n := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	chunks := make(map[ChunkPos]*Chunk, n)
+	for i := 0; i < n; i++ {
+		if len(b) < 4 {
+			return ErrInvalidChunk
+		}
+		size := int(binary.LittleEndian.Uint32(b))
+		if len(b) < 4+size {
+			return fmt.Errorf("%w: unexpected size %d", ErrInvalidChunk, size)
+		}
+		c := &Chunk{}
+		if err := c.UnmarshalBinary(b[4 : 4+size]); err != nil {
+			return err
+		}
+		chunks[c.Pos()] = c
+		b = b[4+size:]
+	}
+	for p, c := range chunks {
+		if old := m.chunks[p]; old != nil {
+			*old = *c
+			chunks[p] = old
+		}
+	}
+	m.chunks = chunks
+	return nil
+}
@@ -0,0 +1,171 @@
+package world
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Compression identifies the codec used to compress chunk data. The value is
+// written as the first byte of the compressed data, so data compressed with
+// any registered codec can always be decompressed, even after the default
+// codec changes.
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota
+	CompressionRLE
+	CompressionZlib
+)
+
+// DefaultCompression is the codec used by Compress when none is specified.
+var DefaultCompression = CompressionZlib
+
+// ErrUnknownCompression is returned when decompressing data tagged with a
+// codec that is not registered.
+var ErrUnknownCompression = errors.New("world: unknown compression")
+
+// ErrTooLarge is returned when decompressing data larger than the limit, such
+// as a crafted save that would exhaust the memory.
+var ErrTooLarge = errors.New("world: decompressed data too large")
+
+// MaxStateBytes is an upper bound of the encoded size of each block of a
+// chunk, its palette entry and packed index, used to limit the decompressed
+// size of chunk data.
+const MaxStateBytes = 8
+
+// Codec compresses and decompresses chunk data.
+type Codec interface {
+	// Compress appends the compressed src to dst.
+	Compress(dst, src []byte) ([]byte, error)
+	// Decompress appends the decompressed src to dst. It returns
+	// ErrTooLarge if more than limit bytes would be appended.
+	Decompress(dst, src []byte, limit int) ([]byte, error)
+}
+
+var codecs = map[Compression]Codec{
+	CompressionNone: noneCodec{},
+	CompressionRLE:  rleCodec{},
+	CompressionZlib: zlibCodec{},
+}
+
+// RegisterCodec adds or replaces the codec used for the compression c. It
+// allows programs to plug other algorithms, such as zstd, without adding the
+// dependency to the engine.
+func RegisterCodec(c Compression, codec Codec) {
+	codecs[c] = codec
+}
+
+// Compress compresses data with the codec c, prefixing the output with the
+// codec tag.
+func Compress(c Compression, data []byte) ([]byte, error) {
+	codec, ok := codecs[c]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCompression, c)
+	}
+	return codec.Compress([]byte{byte(c)}, data)
+}
+
+// Decompress decompresses data produced by Compress, using the codec from the
+// data tag. It fails with ErrTooLarge if the output is larger than limit
+// bytes.
+func Decompress(data []byte, limit int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalidChunk
+	}
+	c := Compression(data[0])
+	codec, ok := codecs[c]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownCompression, c)
+	}
+	return codec.Decompress(nil, data[1:], limit)
+}
+
+type noneCodec struct{}
+
+func (noneCodec) Compress(dst, src []byte) ([]byte, error) {
+	return append(dst, src...), nil
+}
+
+func (noneCodec) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	if len(src) > limit {
+		return nil, ErrTooLarge
+	}
+	return append(dst, src...), nil
+}
+
+// rleCodec encodes runs of repeated bytes as an (uvarint length, byte) pair.
+// It is very fast, and works well for chunks with large homogeneous areas,
+// such as air or stone layers.
+type rleCodec struct{}
+
+func (rleCodec) Compress(dst, src []byte) ([]byte, error) {
+	for i := 0; i < len(src); {
+		j := i + 1
+		for j < len(src) && src[j] == src[i] {
+			j++
+		}
+		dst = binary.AppendUvarint(dst, uint64(j-i))
+		dst = append(dst, src[i])
+		i = j
+	}
+	return dst, nil
+}
+
+func (rleCodec) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	total := 0
+	for len(src) > 0 {
+		n, size := binary.Uvarint(src)
+		if size <= 0 || size >= len(src) {
+			return nil, fmt.Errorf("%w: malformed run", ErrInvalidChunk)
+		}
+		if n > uint64(limit-total) {
+			return nil, ErrTooLarge
+		}
+		total += int(n)
+		v := src[size]
+		src = src[size+1:]
+		for ; n > 0; n-- {
+			dst = append(dst, v)
+		}
+	}
+	return dst, nil
+}
+
+// zlibCodec uses the standard library zlib implementation, and gives better
+// compression ratios at the cost of speed.
+type zlibCodec struct{}
+
+func (zlibCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w := zlib.NewWriter(buf)
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (zlibCodec) Decompress(dst, src []byte, limit int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	buf := bytes.NewBuffer(dst)
+	// Read one byte past the limit to tell data of exactly limit bytes from
+	// larger data.
+	n, err := io.Copy(buf, io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > int64(limit) {
+		return nil, ErrTooLarge
+	}
+	return buf.Bytes(), nil
+}
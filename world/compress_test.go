@@ -0,0 +1,104 @@
+package world
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+// terrainChunk returns the encoded data of a chunk like the generated
+// terrain: stone at the bottom with scattered ores, then dirt, grass and air.
+func terrainChunk(tb testing.TB) []byte {
+	tb.Helper()
+	r := rand.New(rand.NewSource(1))
+	c := NewChunk(0, 0, 0)
+	for y := 0; y < SizeY; y++ {
+		for z := 0; z < SizeZ; z++ {
+			for x := 0; x < SizeX; x++ {
+				surface := 8 + r.Intn(3)
+				switch {
+				case y < surface-3 && r.Intn(20) == 0:
+					c.Set(x, y, z, block.State{ID: block.ID(4 + r.Intn(3))})
+				case y < surface-3:
+					c.Set(x, y, z, block.State{ID: 1})
+				case y < surface:
+					c.Set(x, y, z, block.State{ID: 2})
+				case y == surface:
+					c.Set(x, y, z, block.State{ID: 3})
+				}
+			}
+		}
+	}
+	b, err := c.MarshalBinary()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return b
+}
+
+var testCodecs = []struct {
+	name string
+	c    Compression
+}{
+	{"none", CompressionNone},
+	{"rle", CompressionRLE},
+	{"zlib", CompressionZlib},
+}
+
+func TestDecompressLimit(t *testing.T) {
+	data := terrainChunk(t)
+	for _, tc := range testCodecs {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Compress(tc.c, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Decompress(b, len(data))
+			if err != nil {
+				t.Fatalf("Decompress with an exact limit: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("Decompress returned %d bytes, want the %d compressed", len(got), len(data))
+			}
+			if _, err := Decompress(b, len(data)-1); !errors.Is(err, ErrTooLarge) {
+				t.Errorf("Decompress over the limit: got %v, want ErrTooLarge", err)
+			}
+		})
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	data := terrainChunk(b)
+	for _, tc := range testCodecs {
+		b.Run(tc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := Compress(tc.c, data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	data := terrainChunk(b)
+	for _, tc := range testCodecs {
+		compressed, err := Compress(tc.c, data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(tc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportMetric(float64(len(compressed)), "bytes/chunk")
+			for i := 0; i < b.N; i++ {
+				if _, err := Decompress(compressed, Volume*MaxStateBytes); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package world
+
+import (
+	"sort"
+
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/event"
+	"github.com/ronoaldo/openvoxel/light"
+)
+
+// DefaultLightBudget is the number of blocks lit per Lighting.Process.
+const DefaultLightBudget = 16384
+
+// Light returns the light at the local coordinates x, y, z.
+func (c *Chunk) Light(x, y, z int) light.Color {
+	if c.lights == nil {
+		return 0
+	}
+	return c.lights[index(x, y, z)]
+}
+
+// SetLight changes the light at the local coordinates x, y, z.
+func (c *Chunk) SetLight(x, y, z int, l light.Color) {
+	if c.lights == nil {
+		if l == 0 {
+			return
+		}
+		c.lights = make([]light.Color, Volume)
+	}
+	c.lights[index(x, y, z)] = l
+}
+
+// Lighting keeps the light of the blocks of a Map, using the opacity and the
+// emission of the blocks in the registry. It implements light.Volume.
+//
+// Changed blocks and whole chunks, such as after an explosion or pasting a
+// structure, are queued with Update and Queue, and lit a few blocks at a time
+// by Process, which the game calls on each tick, so large edits don't stall a
+// tick. Relight lights a chunk right away instead. Lighting is not safe for
+// concurrent use.
+type Lighting struct {
+	Map    *Map
+	Blocks *block.Registry
+	// Budget is the number of blocks lit by each call to Process.
+	Budget int
+
+	prop *light.Propagator
+	// dirty are the chunks whose light changed since they were last
+	// returned by Process.
+	dirty map[ChunkPos]struct{}
+}
+
+// NewLighting creates the lighting of the map.
+func NewLighting(m *Map, reg *block.Registry) *Lighting {
+	l := &Lighting{Map: m, Blocks: reg, Budget: DefaultLightBudget, dirty: map[ChunkPos]struct{}{}}
+	l.prop = light.NewPropagator(l)
+	return l
+}
+
+// Opaque implements light.Volume.
+func (l *Lighting) Opaque(x, y, z int) bool {
+	return l.Blocks.Get(l.Map.Block(x, y, z).ID).Opaque
+}
+
+// Emission implements light.Volume.
+func (l *Lighting) Emission(x, y, z int) light.Color {
+	return l.Blocks.Get(l.Map.Block(x, y, z).ID).Emission
+}
+
+// Light implements light.Volume. Blocks in unloaded chunks are dark.
+func (l *Lighting) Light(x, y, z int) light.Color {
+	c := l.Map.Chunk(ChunkAt(x, y, z))
+	if c == nil {
+		return 0
+	}
+	return c.Light(Local(x, y, z))
+}
+
+// SetLight implements light.Volume. Light spreading into unloaded chunks is
+// dropped, and spread again by Relight when they are loaded.
+func (l *Lighting) SetLight(x, y, z int, c light.Color) {
+	p := ChunkAt(x, y, z)
+	ch := l.Map.Chunk(p)
+	if ch == nil {
+		return
+	}
+	lx, ly, lz := Local(x, y, z)
+	if ch.Light(lx, ly, lz) == c {
+		return
+	}
+	ch.SetLight(lx, ly, lz, c)
+	l.dirty[p] = struct{}{}
+}
+
+// Update queues the block at the position, and the blocks around it, to be lit
+// again after it changed.
+func (l *Lighting) Update(x, y, z int) {
+	l.prop.Remove(x, y, z)
+}
+
+// Relight computes the light of the chunk at p again, and of the light it
+// spreads to its neighbors, before returning. It also finishes the blocks
+// queued before.
+func (l *Lighting) Relight(p ChunkPos) {
+	l.Queue(p)
+	l.prop.Propagate()
+}
+
+// Queue schedules the light of the chunk at p to be computed again by
+// Process. Until then, the chunk may be darker than it should be.
+func (l *Lighting) Queue(p ChunkPos) {
+	c := l.Map.Chunk(p)
+	if c == nil {
+		return
+	}
+	ox, oy, oz := c.Origin()
+	// Remove the light of the chunk, which also removes the light it
+	// spread to the neighbors and spreads their own light back in, and
+	// emit the light of the sources again.
+	c.Each(func(x, y, z int, s block.State) {
+		if c.Light(x, y, z) != 0 || l.Blocks.Get(s.ID).Emission != 0 {
+			l.prop.Remove(ox+x, oy+y, oz+z)
+		}
+	})
+	// Spread the light of the neighbors into dark chunks, such as chunks
+	// just loaded.
+	for i := 0; i < Size; i++ {
+		for j := 0; j < Size; j++ {
+			for _, b := range [6][3]int{
+				{ox - 1, oy + i, oz + j}, {ox + SizeX, oy + i, oz + j},
+				{ox + i, oy - 1, oz + j}, {ox + i, oy + SizeY, oz + j},
+				{ox + i, oy + j, oz - 1}, {ox + i, oy + j, oz + SizeZ},
+			} {
+				if l.Light(b[0], b[1], b[2]) != 0 {
+					l.prop.AddSource(b[0], b[1], b[2])
+				}
+			}
+		}
+	}
+	l.dirty[p] = struct{}{}
+}
+
+// Pending returns the number of blocks waiting to be lit.
+func (l *Lighting) Pending() int {
+	return l.prop.Pending()
+}
+
+// Process lights up to Budget queued blocks. When the queue is done, it
+// returns the chunks whose light changed, ordered by position, to be meshed
+// again.
+func (l *Lighting) Process() []ChunkPos {
+	budget := l.Budget
+	if budget <= 0 {
+		budget = DefaultLightBudget
+	}
+	if l.prop.Step(budget) || len(l.dirty) == 0 {
+		return nil
+	}
+	out := make([]ChunkPos, 0, len(l.dirty))
+	for p := range l.dirty {
+		out = append(out, p)
+	}
+	l.dirty = map[ChunkPos]struct{}{}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if a.X != b.X {
+			return a.X < b.X
+		}
+		return a.Z < b.Z
+	})
+	return out
+}
+
+// Listen updates the light of the blocks changed in the world, and queues the
+// chunks loaded and the chunks changed by explosions. It returns a function
+// that stops listening.
+func (l *Lighting) Listen(bus *event.Bus) (unsubscribe func()) {
+	stopChanged := event.Subscribe(bus, func(e event.BlockChanged) {
+		l.Update(e.X, e.Y, e.Z)
+	})
+	stopLoaded := event.Subscribe(bus, func(e event.ChunkLoaded) {
+		l.Queue(ChunkPos{e.X, e.Y, e.Z})
+	})
+	stopExplosion := event.Subscribe(bus, func(e event.Explosion) {
+		for _, c := range e.Chunks {
+			l.Queue(ChunkPos{c[0], c[1], c[2]})
+		}
+	})
+	return func() {
+		stopChanged()
+		stopLoaded()
+		stopExplosion()
+	}
+}
@@ -0,0 +1,185 @@
+package world
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/light"
+)
+
+// The light tests use a 3x3x3 chunks area, from -1 to 1 in chunk coordinates.
+const (
+	lightMin = -Size
+	lightMax = 2 * Size
+)
+
+// testLighting returns the lighting of a map with the area loaded and filled
+// with random blocks, and the IDs of the blocks.
+func testLighting(t *testing.T, r *rand.Rand) (l *Lighting, ids []block.ID) {
+	t.Helper()
+	reg := block.NewRegistry()
+	for _, def := range []block.Definition{
+		{Name: "test:stone", Opaque: true},
+		{Name: "test:torch", Emission: light.Torch},
+		{Name: "test:lava", Emission: light.Lava},
+		{Name: "test:glowstone", Opaque: true, Emission: light.Glowstone},
+	} {
+		id, err := reg.Register(def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	m := NewMap()
+	for cy := -1; cy <= 1; cy++ {
+		for cz := -1; cz <= 1; cz++ {
+			for cx := -1; cx <= 1; cx++ {
+				m.SetChunk(NewChunk(cx, cy, cz))
+			}
+		}
+	}
+	for y := lightMin; y < lightMax; y++ {
+		for z := lightMin; z < lightMax; z++ {
+			for x := lightMin; x < lightMax; x++ {
+				m.SetBlock(x, y, z, block.State{ID: randomBlock(r, ids)})
+			}
+		}
+	}
+	return NewLighting(m, reg), ids
+}
+
+// randomBlock returns mostly air, some stone and a few light sources.
+func randomBlock(r *rand.Rand, ids []block.ID) block.ID {
+	switch n := r.Intn(1000); {
+	case n < 200:
+		return ids[0]
+	case n < 203:
+		return ids[1+r.Intn(len(ids)-1)]
+	}
+	return block.Air
+}
+
+// process runs the queued light updates to the end, a few blocks at a time.
+func process(l *Lighting) {
+	for l.Pending() > 0 {
+		l.Process()
+	}
+}
+
+// bruteForceLight computes the light of every block of the area from scratch:
+// each channel is the brightest emission of the sources, less the length of
+// the shortest path through transparent blocks.
+func bruteForceLight(l *Lighting) map[[3]int]light.Color {
+	const n = lightMax - lightMin
+	var levels [3][n][n][n]int8
+	at := func(x, y, z int) (bool, int, int, int) {
+		x, y, z = x-lightMin, y-lightMin, z-lightMin
+		return x >= 0 && y >= 0 && z >= 0 && x < n && y < n && z < n, x, y, z
+	}
+	for y := lightMin; y < lightMax; y++ {
+		for z := lightMin; z < lightMax; z++ {
+			for x := lightMin; x < lightMax; x++ {
+				e := l.Emission(x, y, z)
+				_, i, j, k := at(x, y, z)
+				levels[0][i][j][k] = int8(e.R())
+				levels[1][i][j][k] = int8(e.G())
+				levels[2][i][j][k] = int8(e.B())
+			}
+		}
+	}
+	// Relax until nothing changes: each transparent block is at least as
+	// bright as its neighbors, less one.
+	for changed := true; changed; {
+		changed = false
+		for y := lightMin; y < lightMax; y++ {
+			for z := lightMin; z < lightMax; z++ {
+				for x := lightMin; x < lightMax; x++ {
+					if l.Opaque(x, y, z) {
+						continue
+					}
+					_, i, j, k := at(x, y, z)
+					for _, o := range [6][3]int{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}} {
+						ok, ni, nj, nk := at(x+o[0], y+o[1], z+o[2])
+						if !ok {
+							continue
+						}
+						for c := range levels {
+							if v := levels[c][ni][nj][nk] - 1; v > levels[c][i][j][k] {
+								levels[c][i][j][k] = v
+								changed = true
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	out := map[[3]int]light.Color{}
+	for y := lightMin; y < lightMax; y++ {
+		for z := lightMin; z < lightMax; z++ {
+			for x := lightMin; x < lightMax; x++ {
+				_, i, j, k := at(x, y, z)
+				out[[3]int{x, y, z}] = light.RGB(uint8(levels[0][i][j][k]), uint8(levels[1][i][j][k]), uint8(levels[2][i][j][k]))
+			}
+		}
+	}
+	return out
+}
+
+// checkLight compares the light of the area with the brute force one.
+func checkLight(t *testing.T, l *Lighting) {
+	t.Helper()
+	wrong := 0
+	for p, want := range bruteForceLight(l) {
+		if got := l.Light(p[0], p[1], p[2]); got != want {
+			if wrong++; wrong <= 10 {
+				t.Errorf("light at %v = %03x, want %03x", p, got, want)
+			}
+		}
+	}
+	if wrong > 10 {
+		t.Errorf("... and %d more blocks", wrong-10)
+	}
+}
+
+func TestLightingIncremental(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	l, ids := testLighting(t, r)
+	l.Budget = 1000
+	for cy := -1; cy <= 1; cy++ {
+		for cz := -1; cz <= 1; cz++ {
+			for cx := -1; cx <= 1; cx++ {
+				l.Queue(ChunkPos{cx, cy, cz})
+			}
+		}
+	}
+	process(l)
+	checkLight(t, l)
+
+	for round := 0; round < 5; round++ {
+		for i := 0; i < 100; i++ {
+			x, y, z := lightMin+r.Intn(lightMax-lightMin), lightMin+r.Intn(lightMax-lightMin), lightMin+r.Intn(lightMax-lightMin)
+			id := block.Air
+			switch r.Intn(3) {
+			case 0:
+				id = ids[0]
+			case 1:
+				id = ids[1+r.Intn(len(ids)-1)]
+			}
+			l.Map.SetBlock(x, y, z, block.State{ID: id})
+			l.Update(x, y, z)
+		}
+		// Bulk edits, such as pasted structures, queue whole chunks.
+		if round%2 == 1 {
+			p := ChunkPos{r.Intn(3) - 1, r.Intn(3) - 1, r.Intn(3) - 1}
+			c := l.Map.Chunk(p)
+			c.Each(func(x, y, z int, _ block.State) {
+				c.Set(x, y, z, block.State{ID: randomBlock(r, ids)})
+			})
+			l.Queue(p)
+		}
+		process(l)
+		checkLight(t, l)
+	}
+}
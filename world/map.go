@@ -0,0 +1,91 @@
+package world
+
+import (
+	glm "github.com/go-gl/mathgl/mgl32"
+	"github.com/ronoaldo/openvoxel/block"
+	"github.com/ronoaldo/openvoxel/event"
+)
+
+// Map holds the loaded chunks of a world, keyed by their position. It is not
+// safe for concurrent use.
+type Map struct {
+	// Spawn is where players appear when joining the world and respawn
+	// after dying.
+	Spawn glm.Vec3
+	// Bus, if set, receives event.ChunkLoaded when a chunk is added, so the
+	// light and the meshes can be built for it.
+	Bus *event.Bus
+
+	chunks map[ChunkPos]*Chunk
+}
+
+// NewMap creates an empty map.
+func NewMap() *Map {
+	return &Map{chunks: make(map[ChunkPos]*Chunk)}
+}
+
+// Chunk returns the chunk at p, or nil if it is not loaded.
+func (m *Map) Chunk(p ChunkPos) *Chunk {
+	return m.chunks[p]
+}
+
+// SetChunk adds the chunk to the map, replacing the chunk at the same
+// position, and publishes event.ChunkLoaded.
+func (m *Map) SetChunk(c *Chunk) {
+	m.chunks[c.Pos()] = c
+	m.loaded(c.Pos())
+}
+
+// loaded publishes event.ChunkLoaded for the chunk at p.
+func (m *Map) loaded(p ChunkPos) {
+	if m.Bus != nil {
+		event.Publish(m.Bus, event.ChunkLoaded{X: p.X, Y: p.Y, Z: p.Z})
+	}
+}
+
+// RemoveChunk unloads the chunk at p.
+func (m *Map) RemoveChunk(p ChunkPos) {
+	delete(m.chunks, p)
+}
+
+// Len returns the number of loaded chunks.
+func (m *Map) Len() int {
+	return len(m.chunks)
+}
+
+// Each calls fn for each loaded chunk, in no particular order.
+func (m *Map) Each(fn func(c *Chunk)) {
+	for _, c := range m.chunks {
+		fn(c)
+	}
+}
+
+// Block returns the block state at the world coordinates x, y, z, or air if
+// its chunk is not loaded.
+func (m *Map) Block(x, y, z int) block.State {
+	c := m.chunks[ChunkAt(x, y, z)]
+	if c == nil {
+		return block.State{ID: block.Air}
+	}
+	return c.Get(Local(x, y, z))
+}
+
+// SetBlock changes the block state at the world coordinates x, y, z, creating
+// its chunk, and publishing event.ChunkLoaded, if it is not loaded.
+func (m *Map) SetBlock(x, y, z int, s block.State) {
+	p := ChunkAt(x, y, z)
+	c := m.chunks[p]
+	if c == nil {
+		if s.ID == block.Air {
+			return
+		}
+		c = NewChunk(p.X, p.Y, p.Z)
+		m.chunks[p] = c
+		lx, ly, lz := Local(x, y, z)
+		c.Set(lx, ly, lz, s)
+		m.loaded(p)
+		return
+	}
+	lx, ly, lz := Local(x, y, z)
+	c.Set(lx, ly, lz, s)
+}
@@ -0,0 +1,79 @@
+package world
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+func init() {
+	RegisterMigration(KindChunk, 1, migrateColumnChunk)
+}
+
+// legacyColumnHeight is the height of the chunk columns saved by version 1.
+const legacyColumnHeight = 256
+
+// migrateColumnChunk upgrades a version 1 chunk, a 16x256x16 column encoded as
+// the x, z coordinates, palette and packed blocks, to the version 2 list of
+// cubic chunks, one per 16 blocks of height starting at y=0. Empty sections
+// are dropped.
+func migrateColumnChunk(data []byte) ([]byte, error) {
+	if len(data) < 10 {
+		return nil, ErrInvalidChunk
+	}
+	x := int32(binary.LittleEndian.Uint32(data[0:]))
+	z := int32(binary.LittleEndian.Uint32(data[4:]))
+	n := int(binary.LittleEndian.Uint16(data[8:]))
+	data = data[10:]
+	if n == 0 {
+		return nil, fmt.Errorf("%w: empty palette", ErrInvalidChunk)
+	}
+	volume := SizeX * legacyColumnHeight * SizeZ
+	blocks := newBitArray(volume, bitsFor(n))
+	if len(data) != n*4+len(blocks.words)*8 {
+		return nil, fmt.Errorf("%w: unexpected size %d", ErrInvalidChunk, len(data))
+	}
+	palette := make([]block.State, n)
+	for i := range palette {
+		palette[i].ID = block.ID(binary.LittleEndian.Uint16(data[i*4:]))
+		palette[i].Meta = binary.LittleEndian.Uint16(data[i*4+2:])
+	}
+	data = data[n*4:]
+	for i := range blocks.words {
+		blocks.words[i] = binary.LittleEndian.Uint64(data[i*8:])
+	}
+
+	// Blocks are ordered by y, then z, then x, so each section is a
+	// contiguous range of Volume blocks.
+	out := []byte{0, 0}
+	count := 0
+	for sy := 0; sy < legacyColumnHeight/SizeY; sy++ {
+		c := &Chunk{X: int(x), Y: sy, Z: int(z), palette: append([]block.State(nil), palette...)}
+		c.blocks = newBitArray(Volume, bitsFor(n))
+		empty := true
+		for i := 0; i < Volume; i++ {
+			v := blocks.get(sy*Volume + i)
+			if int(v) >= n {
+				return nil, fmt.Errorf("%w: palette index %d out of range", ErrInvalidChunk, v)
+			}
+			if v != 0 && c.blocks.bits > 0 {
+				c.blocks.set(i, v)
+				empty = false
+			}
+		}
+		if empty && palette[0].ID == block.Air {
+			continue
+		}
+		c.Compact()
+		b, err := c.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(b)))
+		out = append(out, b...)
+		count++
+	}
+	binary.LittleEndian.PutUint16(out, uint16(count))
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package world
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+// The fixtures are chunks saved by each version of the format, loaded through
+// the migration registry. Do not regenerate them: they must keep the bytes
+// written by the older versions.
+var chunkFixtures = []struct {
+	file string
+	// chunks are the positions of the chunks expected after loading.
+	chunks []ChunkPos
+	// blocks are the expected states at world coordinates.
+	blocks map[[3]int]block.State
+}{
+	{
+		// A 16x256x16 column at x=3, z=-2: stone up to y=59, dirt up to
+		// y=62, grass at y=63 and a single block at y=100.
+		file: "testdata/chunk_v1.bin",
+		chunks: []ChunkPos{
+			{3, 0, -2}, {3, 1, -2}, {3, 2, -2}, {3, 3, -2}, {3, 6, -2},
+		},
+		blocks: map[[3]int]block.State{
+			{48, 0, -32}:   {ID: 1},
+			{63, 59, -17}:  {ID: 1},
+			{50, 60, -30}:  {ID: 2},
+			{50, 62, -30}:  {ID: 2},
+			{55, 63, -25}:  {ID: 3},
+			{55, 64, -25}:  {},
+			{53, 100, -25}: {ID: 4, Meta: 2},
+			{53, 101, -25}: {},
+		},
+	},
+	{
+		// Two cubic chunks at x=1, z=2: solid stone at y=-1, with grass
+		// on the bottom layer at y=0.
+		file:   "testdata/chunk_v2.bin",
+		chunks: []ChunkPos{{1, -1, 2}, {1, 0, 2}},
+		blocks: map[[3]int]block.State{
+			{16, -16, 32}: {ID: 1},
+			{31, -1, 47}:  {ID: 1},
+			{18, -12, 38}: {ID: 7, Meta: 5},
+			{20, 0, 40}:   {ID: 3},
+			{24, 1, 40}:   {ID: 9, Meta: 1},
+			{20, 1, 40}:   {},
+		},
+	},
+}
+
+func TestDecodeChunksFixtures(t *testing.T) {
+	for _, f := range chunkFixtures {
+		t.Run(f.file, func(t *testing.T) {
+			b, err := os.ReadFile(f.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs, err := DecodeChunks(b)
+			if err != nil {
+				t.Fatalf("DecodeChunks: %v", err)
+			}
+			m := NewMap()
+			var got []ChunkPos
+			for _, c := range cs {
+				got = append(got, ChunkPos{c.X, c.Y, c.Z})
+				m.SetChunk(c)
+			}
+			if len(got) != len(f.chunks) {
+				t.Fatalf("DecodeChunks loaded chunks %v, want %v", got, f.chunks)
+			}
+			for i := range got {
+				if got[i] != f.chunks[i] {
+					t.Fatalf("DecodeChunks loaded chunks %v, want %v", got, f.chunks)
+				}
+			}
+			for p, want := range f.blocks {
+				if s := m.Block(p[0], p[1], p[2]); s != want {
+					t.Errorf("block at %v = %+v, want %+v", p, s, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package world
+
+import (
+	"fmt"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+// Octree stores a cube of blocks as a sparse voxel octree. Each node covers a
+// cube and is either a leaf with a single block state, or split in 8 octants.
+// Uniform regions collapse into a single leaf, so empty space costs nothing
+// but the node that covers it.
+//
+// It is an alternative to the dense palette storage of Chunk for very tall
+// worlds, where most of the volume is air or solid stone.
+type Octree struct {
+	size int
+	root octNode
+}
+
+type octNode struct {
+	state    block.State
+	children *[8]octNode
+}
+
+// NewOctree creates an octree with size blocks per side, filled with air. The
+// size must be a power of two.
+func NewOctree(size int) *Octree {
+	if size <= 0 || size&(size-1) != 0 {
+		panic(fmt.Sprintf("world: octree size %d is not a power of two", size))
+	}
+	return &Octree{size: size, root: octNode{state: block.State{ID: block.Air}}}
+}
+
+// Size returns the number of blocks per side.
+func (o *Octree) Size() int {
+	return o.size
+}
+
+// Inside returns true if the coordinates are within the octree bounds.
+func (o *Octree) Inside(x, y, z int) bool {
+	return x >= 0 && x < o.size && y >= 0 && y < o.size && z >= 0 && z < o.size
+}
+
+// octant returns the child index of x, y, z in a node whose children have
+// half blocks per side.
+func octant(x, y, z, half int) int {
+	i := 0
+	if x&half != 0 {
+		i |= 1
+	}
+	if y&half != 0 {
+		i |= 2
+	}
+	if z&half != 0 {
+		i |= 4
+	}
+	return i
+}
+
+// Get returns the block state at x, y, z.
+func (o *Octree) Get(x, y, z int) block.State {
+	n := &o.root
+	for half := o.size / 2; n.children != nil; half /= 2 {
+		n = &n.children[octant(x, y, z, half)]
+	}
+	return n.state
+}
+
+// Set changes the block state at x, y, z, splitting and collapsing nodes as
+// needed.
+func (o *Octree) Set(x, y, z int, s block.State) {
+	o.root.set(x, y, z, o.size/2, s)
+}
+
+func (n *octNode) set(x, y, z, half int, s block.State) {
+	if n.children == nil {
+		if n.state == s {
+			return
+		}
+		if half == 0 {
+			n.state = s
+			return
+		}
+		n.children = new([8]octNode)
+		for i := range n.children {
+			n.children[i].state = n.state
+		}
+	}
+	n.children[octant(x, y, z, half)].set(x, y, z, half/2, s)
+
+	// Collapse the children if they are now the same leaf.
+	first := n.children[0]
+	if first.children != nil {
+		return
+	}
+	for _, c := range n.children[1:] {
+		if c.children != nil || c.state != first.state {
+			return
+		}
+	}
+	n.state, n.children = first.state, nil
+}
+
+// Nodes returns the number of nodes in the octree, a measure of its memory
+// use.
+func (o *Octree) Nodes() int {
+	return o.root.count()
+}
+
+func (n *octNode) count() int {
+	c := 1
+	if n.children != nil {
+		for i := range n.children {
+			c += n.children[i].count()
+		}
+	}
+	return c
+}
+
+// LoadChunk copies the blocks of c into the octree, with the chunk origin at
+// x, y, z. Blocks outside of the octree are ignored.
+func (o *Octree) LoadChunk(c *Chunk, x, y, z int) {
+	c.Each(func(bx, by, bz int, s block.State) {
+		if o.Inside(x+bx, y+by, z+bz) {
+			o.Set(x+bx, y+by, z+bz, s)
+		}
+	})
+}
+
+// StoreChunk copies the blocks of the octree starting at x, y, z into c, in
+// the dense palette format. Blocks outside of the octree are stored as air.
+func (o *Octree) StoreChunk(c *Chunk, x, y, z int) {
+	air := block.State{ID: block.Air}
+	for by := 0; by < SizeY; by++ {
+		for bz := 0; bz < SizeZ; bz++ {
+			for bx := 0; bx < SizeX; bx++ {
+				s := air
+				if o.Inside(x+bx, y+by, z+bz) {
+					s = o.Get(x+bx, y+by, z+bz)
+				}
+				c.Set(bx, by, bz, s)
+			}
+		}
+	}
+	c.Compact()
+}
+
+// OctreeColumn is a column of SizeX x Height x SizeZ blocks stored in an
+// octree, for worlds much taller than a Chunk. Block coordinates are local in
+// x and z, and absolute in y, starting at MinY.
+type OctreeColumn struct {
+	X, Z   int
+	MinY   int
+	Height int
+
+	tree *Octree
+}
+
+// NewOctreeColumn creates a column at the chunk coordinates x, z, covering
+// height blocks from minY, filled with air. For example, a column from -2048
+// with a height of 4096 supports worlds from y=-2048 to y=2047.
+func NewOctreeColumn(x, z, minY, height int) *OctreeColumn {
+	size := SizeX
+	for size < height || size < SizeZ {
+		size *= 2
+	}
+	return &OctreeColumn{X: x, Z: z, MinY: minY, Height: height, tree: NewOctree(size)}
+}
+
+// Inside returns true if the local x, z and absolute y are within the column.
+func (c *OctreeColumn) Inside(x, y, z int) bool {
+	return x >= 0 && x < SizeX && z >= 0 && z < SizeZ && y >= c.MinY && y < c.MinY+c.Height
+}
+
+// Get returns the block state at x, y, z, or air outside of the column.
+func (c *OctreeColumn) Get(x, y, z int) block.State {
+	if !c.Inside(x, y, z) {
+		return block.State{ID: block.Air}
+	}
+	return c.tree.Get(x, y-c.MinY, z)
+}
+
+// Set changes the block state at x, y, z. Blocks outside of the column are
+// ignored.
+func (c *OctreeColumn) Set(x, y, z int, s block.State) {
+	if c.Inside(x, y, z) {
+		c.tree.Set(x, y-c.MinY, z, s)
+	}
+}
+
+// Nodes returns the number of octree nodes in use.
+func (c *OctreeColumn) Nodes() int {
+	return c.tree.Nodes()
+}
+
+// Chunk returns the blocks of the chunk at the chunk coordinate cy as a dense
+// Chunk, for meshing and serialization.
+func (c *OctreeColumn) Chunk(cy int) *Chunk {
+	ch := NewChunk(c.X, cy, c.Z)
+	c.tree.StoreChunk(ch, 0, cy*SizeY-c.MinY, 0)
+	return ch
+}
+
+// SetChunk replaces the blocks covered by ch, at its chunk Y coordinate, with
+// the blocks of ch.
+func (c *OctreeColumn) SetChunk(ch *Chunk) {
+	c.tree.LoadChunk(ch, 0, ch.Y*SizeY-c.MinY, 0)
+}
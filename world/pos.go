@@ -0,0 +1,68 @@
+package world
+
+// ChunkPos identifies a chunk by its chunk coordinates.
+type ChunkPos struct {
+	X, Y, Z int
+}
+
+// floorDiv divides rounding towards negative infinity, so negative world
+// coordinates map to the right chunk.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// ChunkAt returns the position of the chunk containing the block at the world
+// coordinates x, y, z.
+func ChunkAt(x, y, z int) ChunkPos {
+	return ChunkPos{floorDiv(x, SizeX), floorDiv(y, SizeY), floorDiv(z, SizeZ)}
+}
+
+// Local converts world coordinates to coordinates local to their chunk.
+func Local(x, y, z int) (lx, ly, lz int) {
+	return x - floorDiv(x, SizeX)*SizeX, y - floorDiv(y, SizeY)*SizeY, z - floorDiv(z, SizeZ)*SizeZ
+}
+
+// Neighbor returns the position of the adjacent chunk offset by dx, dy, dz.
+func (p ChunkPos) Neighbor(dx, dy, dz int) ChunkPos {
+	return ChunkPos{p.X + dx, p.Y + dy, p.Z + dz}
+}
+
+// InRange returns the chunks within radius chunks horizontally and vertical
+// chunks up and down from center, ordered from nearest to farthest, as used
+// to decide which chunks to generate, load and stream.
+func InRange(center ChunkPos, radius, vertical int) []ChunkPos {
+	var out []ChunkPos
+	for dist := 0; dist <= radius || dist <= vertical; dist++ {
+		// Visit the shell of chunks at this Chebyshev distance.
+		h, v := minInt(dist, radius), minInt(dist, vertical)
+		for dy := -v; dy <= v; dy++ {
+			for dz := -h; dz <= h; dz++ {
+				for dx := -h; dx <= h; dx++ {
+					if abs(dx) != dist && abs(dy) != dist && abs(dz) != dist {
+						continue
+					}
+					out = append(out, center.Neighbor(dx, dy, dz))
+				}
+			}
+		}
+	}
+	return out
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,231 @@
+package world
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// DataKind identifies the type of persisted data.
+type DataKind uint8
+
+const (
+	KindWorld DataKind = iota + 1
+	KindRegion
+	KindChunk
+	KindTicks
+	KindEntities
+	KindLightmap
+)
+
+func (k DataKind) String() string {
+	switch k {
+	case KindWorld:
+		return "world"
+	case KindRegion:
+		return "region"
+	case KindChunk:
+		return "chunk"
+	case KindTicks:
+		return "ticks"
+	case KindEntities:
+		return "entities"
+	case KindLightmap:
+		return "lightmap"
+	}
+	return fmt.Sprintf("kind(%d)", uint8(k))
+}
+
+// CurrentVersion is the version of the data written by this version of the
+// engine, for each kind of data.
+var CurrentVersion = map[DataKind]uint16{
+	KindWorld:    1,
+	KindRegion:   1,
+	KindChunk:    2,
+	KindTicks:    1,
+	KindEntities: 1,
+	KindLightmap: 1,
+}
+
+// saveMagic identifies openvoxel save data.
+const saveMagic = "OVXL"
+
+// headerSize is the size of the encoded Header.
+const headerSize = len(saveMagic) + 3
+
+var (
+	// ErrInvalidHeader is returned when the data is not an openvoxel save.
+	ErrInvalidHeader = errors.New("world: invalid save header")
+
+	// ErrUnsupportedVersion is returned when loading data saved by a newer
+	// engine, or when there is no migration path for an older version.
+	ErrUnsupportedVersion = errors.New("world: unsupported save version")
+)
+
+// Header is written before all persisted data.
+type Header struct {
+	Kind    DataKind
+	Version uint16
+}
+
+// AppendHeader appends the encoded header to b.
+func AppendHeader(b []byte, h Header) []byte {
+	b = append(b, saveMagic...)
+	b = append(b, byte(h.Kind))
+	return binary.LittleEndian.AppendUint16(b, h.Version)
+}
+
+// ReadHeader decodes the header at the start of data, and returns the
+// remaining bytes.
+func ReadHeader(data []byte) (Header, []byte, error) {
+	if len(data) < headerSize || string(data[:len(saveMagic)]) != saveMagic {
+		return Header{}, nil, ErrInvalidHeader
+	}
+	data = data[len(saveMagic):]
+	h := Header{
+		Kind:    DataKind(data[0]),
+		Version: binary.LittleEndian.Uint16(data[1:]),
+	}
+	return h, data[3:], nil
+}
+
+// Migration upgrades the decompressed data of a kind from one version to the
+// next.
+type Migration func(data []byte) ([]byte, error)
+
+type migrationKey struct {
+	kind DataKind
+	from uint16
+}
+
+var migrations = map[migrationKey]Migration{}
+
+// RegisterMigration registers the function that upgrades data of the kind from
+// version from to version from+1. When the save format changes, increment
+// CurrentVersion and register a migration from the previous version.
+func RegisterMigration(kind DataKind, from uint16, m Migration) {
+	migrations[migrationKey{kind, from}] = m
+}
+
+// Migrate upgrades data of the kind from the provided version to the current
+// version, applying all registered migrations in order.
+func Migrate(kind DataKind, version uint16, data []byte) ([]byte, error) {
+	current, ok := CurrentVersion[kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown kind %v", ErrInvalidHeader, kind)
+	}
+	if version > current {
+		return nil, fmt.Errorf("%w: %v version %d is newer than %d", ErrUnsupportedVersion, kind, version, current)
+	}
+	for v := version; v < current; v++ {
+		m, ok := migrations[migrationKey{kind, v}]
+		if !ok {
+			return nil, fmt.Errorf("%w: no migration for %v version %d", ErrUnsupportedVersion, kind, v)
+		}
+		var err error
+		if data, err = m(data); err != nil {
+			return nil, fmt.Errorf("world: migrating %v from version %d: %w", kind, v, err)
+		}
+	}
+	return data, nil
+}
+
+// EncodeChunk serializes the chunk with the current version header, compressed
+// with the DefaultCompression codec.
+func EncodeChunk(c *Chunk) ([]byte, error) {
+	return EncodeChunks([]*Chunk{c})
+}
+
+// EncodeChunks serializes the chunks together, usually a vertical stack of
+// chunks, with the current version header, compressed with the
+// DefaultCompression codec.
+//
+// The payload is the number of chunks as an uint16, followed by each chunk
+// encoded by MarshalBinary, prefixed by its length as an uint32. At most
+// MaxEncodedChunks chunks are accepted, so DecodeChunks can always load them.
+func EncodeChunks(cs []*Chunk) ([]byte, error) {
+	if len(cs) > MaxEncodedChunks {
+		return nil, fmt.Errorf("world: too many chunks to encode: %d, the limit is %d", len(cs), MaxEncodedChunks)
+	}
+	data := binary.LittleEndian.AppendUint16(nil, uint16(len(cs)))
+	for _, c := range cs {
+		b, err := c.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(b)))
+		data = append(data, b...)
+	}
+	if len(data) > maxChunksBytes {
+		return nil, fmt.Errorf("world: encoded chunks too large: %d bytes", len(data))
+	}
+	compressed, err := Compress(DefaultCompression, data)
+	if err != nil {
+		return nil, err
+	}
+	b := AppendHeader(nil, Header{KindChunk, CurrentVersion[KindChunk]})
+	return append(b, compressed...), nil
+}
+
+// DecodeChunk loads a chunk serialized by EncodeChunk, upgrading it from older
+// versions if needed. Data holding several chunks, including chunk columns
+// saved before chunks were cubic, must be loaded with DecodeChunks.
+func DecodeChunk(b []byte) (*Chunk, error) {
+	cs, err := DecodeChunks(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(cs) != 1 {
+		return nil, fmt.Errorf("%w: expected one chunk, got %d", ErrInvalidChunk, len(cs))
+	}
+	return cs[0], nil
+}
+
+// MaxEncodedChunks is the number of chunks EncodeChunks accepts, a stack as
+// tall as the legacy columns.
+const MaxEncodedChunks = legacyColumnHeight / SizeY
+
+// maxChunksBytes limits the decompressed size of the data read by
+// DecodeChunks, enough for MaxEncodedChunks chunks of any content.
+const maxChunksBytes = MaxEncodedChunks * Volume * MaxStateBytes
+
+// DecodeChunks loads the chunks serialized by EncodeChunks, upgrading them from
+// older versions if needed.
+func DecodeChunks(b []byte) ([]*Chunk, error) {
+	h, payload, err := ReadHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if h.Kind != KindChunk {
+		return nil, fmt.Errorf("%w: expected chunk, got %v", ErrInvalidHeader, h.Kind)
+	}
+	data, err := Decompress(payload, maxChunksBytes)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = Migrate(h.Kind, h.Version, data); err != nil {
+		return nil, err
+	}
+	if len(data) < 2 {
+		return nil, ErrInvalidChunk
+	}
+	n := int(binary.LittleEndian.Uint16(data))
+	data = data[2:]
+	cs := make([]*Chunk, n)
+	for i := range cs {
+		if len(data) < 4 {
+			return nil, ErrInvalidChunk
+		}
+		size := int(binary.LittleEndian.Uint32(data))
+		data = data[4:]
+		if len(data) < size {
+			return nil, ErrInvalidChunk
+		}
+		cs[i] = &Chunk{}
+		if err := cs[i].UnmarshalBinary(data[:size]); err != nil {
+			return nil, err
+		}
+		data = data[size:]
+	}
+	return cs, nil
+}
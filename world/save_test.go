@@ -0,0 +1,53 @@
+package world
+
+import (
+	"testing"
+
+	"github.com/ronoaldo/openvoxel/block"
+)
+
+// denseChunk returns a chunk where every block has a different state, the
+// largest chunk to encode.
+func denseChunk(y int) *Chunk {
+	c := NewChunk(0, y, 0)
+	i := 0
+	for y := 0; y < SizeY; y++ {
+		for z := 0; z < SizeZ; z++ {
+			for x := 0; x < SizeX; x++ {
+				c.Set(x, y, z, block.State{ID: block.ID(i % 512), Meta: uint16(i / 512)})
+				i++
+			}
+		}
+	}
+	return c
+}
+
+func TestEncodeChunksLimit(t *testing.T) {
+	cs := make([]*Chunk, MaxEncodedChunks)
+	for i := range cs {
+		cs[i] = denseChunk(i)
+	}
+	b, err := EncodeChunks(cs)
+	if err != nil {
+		t.Fatalf("EncodeChunks of %d dense chunks: %v", len(cs), err)
+	}
+	got, err := DecodeChunks(b)
+	if err != nil {
+		t.Fatalf("DecodeChunks: %v", err)
+	}
+	if len(got) != len(cs) {
+		t.Fatalf("DecodeChunks returned %d chunks, want %d", len(got), len(cs))
+	}
+	for i, c := range got {
+		if c.Y != i {
+			t.Errorf("chunk %d at y=%d", i, c.Y)
+		}
+		if s, want := c.Get(15, 15, 15), (block.State{ID: (Volume - 1) % 512, Meta: (Volume - 1) / 512}); s != want {
+			t.Errorf("chunk %d: last block = %v, want %v", i, s, want)
+		}
+	}
+
+	if _, err := EncodeChunks(append(cs, denseChunk(len(cs)))); err == nil {
+		t.Errorf("EncodeChunks accepted %d chunks", len(cs)+1)
+	}
+}
@@ -0,0 +1,46 @@
+package worldgen
+
+import "math"
+
+// noise is a seeded 2D value noise, summed over several octaves.
+type noise struct {
+	seed    uint64
+	scale   float64
+	octaves int
+}
+
+// hash returns a pseudo-random value in [-1, 1] for the lattice point.
+func (n noise) hash(x, z int64) float64 {
+	h := n.seed ^ uint64(x)*0x9e3779b97f4a7c15 ^ uint64(z)*0xc2b2ae3d27d4eb4f
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return float64(h>>11)/(1<<52) - 1
+}
+
+// value returns the smoothly interpolated lattice noise at x, z.
+func (n noise) value(x, z float64) float64 {
+	x0, z0 := math.Floor(x), math.Floor(z)
+	fx, fz := x-x0, z-z0
+	fx, fz = fx*fx*(3-2*fx), fz*fz*(3-2*fz)
+	ix, iz := int64(x0), int64(z0)
+	a := n.hash(ix, iz) + (n.hash(ix+1, iz)-n.hash(ix, iz))*fx
+	b := n.hash(ix, iz+1) + (n.hash(ix+1, iz+1)-n.hash(ix, iz+1))*fx
+	return a + (b-a)*fz
+}
+
+// At returns the noise at the world column x, z, in [-1, 1].
+func (n noise) At(x, z float64) float64 {
+	var sum, amp, total float64 = 0, 1, 0
+	x, z = x/n.scale, z/n.scale
+	for o := 0; o < n.octaves; o++ {
+		sum += n.value(x, z) * amp
+		total += amp
+		amp *= 0.5
+		// The offset keeps the octaves from aligning at the origin.
+		x, z = x*2+17.3, z*2-9.1
+	}
+	return sum / total
+}
@@ -0,0 +1,119 @@
+// package worldgen generates the shape of the terrain and the biomes of a
+// world from its seed. The generator is deterministic: the same seed always
+// produces the same world, on every platform.
+package worldgen
+
+import (
+	"image/color"
+
+	"github.com/ronoaldo/openvoxel/biome"
+	"github.com/ronoaldo/openvoxel/rng"
+)
+
+// The biomes placed by the generator.
+var (
+	Ocean     = &biome.Biome{Name: "ocean", Temperature: 0.5, Humidity: 0.5, Water: color.RGBA{0x3f, 0x76, 0xe4, 0xff}, Ground: color.RGBA{0x3f, 0x76, 0xe4, 0xff}}
+	Beach     = &biome.Biome{Name: "beach", Temperature: 0.8, Humidity: 0.4, Water: color.RGBA{0x3f, 0x76, 0xe4, 0xff}, Ground: color.RGBA{0xdb, 0xcf, 0x9c, 0xff}}
+	Plains    = &biome.Biome{Name: "plains", Temperature: 0.8, Humidity: 0.4, Water: color.RGBA{0x3f, 0x76, 0xe4, 0xff}, Ground: color.RGBA{0x74, 0xa8, 0x4a, 0xff}}
+	Forest    = &biome.Biome{Name: "forest", Temperature: 0.7, Humidity: 0.8, Water: color.RGBA{0x3f, 0x76, 0xe4, 0xff}, Ground: color.RGBA{0x4a, 0x7a, 0x32, 0xff}}
+	Desert    = &biome.Biome{Name: "desert", Temperature: 1, Humidity: 0, Water: color.RGBA{0x32, 0xa5, 0x98, 0xff}, Ground: color.RGBA{0xdb, 0xcf, 0x9c, 0xff}}
+	Taiga     = &biome.Biome{Name: "taiga", Temperature: 0.25, Humidity: 0.8, Water: color.RGBA{0x28, 0x70, 0xbf, 0xff}, Ground: color.RGBA{0x4f, 0x73, 0x5a, 0xff}}
+	Tundra    = &biome.Biome{Name: "tundra", Temperature: 0, Humidity: 0.5, Water: color.RGBA{0x39, 0x38, 0xc9, 0xff}, Ground: color.RGBA{0xee, 0xf2, 0xf5, 0xff}}
+	Mountains = &biome.Biome{Name: "mountains", Temperature: 0.2, Humidity: 0.3, Water: color.RGBA{0x3f, 0x76, 0xe4, 0xff}, Ground: color.RGBA{0x8a, 0x8a, 0x8a, 0xff}}
+)
+
+// Generator computes the terrain of a world.
+type Generator struct {
+	Seed uint64
+	// SeaLevel is the height of the oceans surface.
+	SeaLevel int
+
+	continents, hills, mountains noise
+	temperature, humidity        noise
+}
+
+// New creates the generator of the world with the seed.
+func New(seed uint64) *Generator {
+	n := func(label string, scale float64, octaves int) noise {
+		return noise{seed: rng.Derive(seed, "worldgen/"+label), scale: scale, octaves: octaves}
+	}
+	return &Generator{
+		Seed:        seed,
+		SeaLevel:    64,
+		continents:  n("continents", 1024, 5),
+		hills:       n("hills", 96, 4),
+		mountains:   n("mountains", 384, 4),
+		temperature: n("temperature", 768, 3),
+		humidity:    n("humidity", 640, 3),
+	}
+}
+
+// Height returns the height of the terrain surface at the world column x, z.
+func (g *Generator) Height(x, z int) int {
+	fx, fz := float64(x), float64(z)
+	c := g.continents.At(fx, fz)
+	h := float64(g.SeaLevel) + c*48 + g.hills.At(fx, fz)*8*(c+1)
+	if m := g.mountains.At(fx, fz); m > 0.2 && c > 0 {
+		h += (m - 0.2) * 160 * c
+	}
+	return int(h)
+}
+
+// Climate returns the temperature and the humidity at the world column x, z,
+// from 0 to 1. High terrain is colder.
+func (g *Generator) Climate(x, z int) (temperature, humidity float32) {
+	fx, fz := float64(x), float64(z)
+	t := g.temperature.At(fx, fz)*0.8 + 0.5
+	if above := g.Height(x, z) - g.SeaLevel - 32; above > 0 {
+		t -= float64(above) / 64
+	}
+	return clamp01(t), clamp01(g.humidity.At(fx, fz)*0.8 + 0.5)
+}
+
+// Biome returns the biome at the world column x, z. It can be used as the
+// biome.Source of the world.
+func (g *Generator) Biome(x, z int) *biome.Biome {
+	h := g.Height(x, z)
+	switch {
+	case h < g.SeaLevel:
+		return Ocean
+	case h < g.SeaLevel+2:
+		return Beach
+	case h > g.SeaLevel+56:
+		return Mountains
+	}
+	t, m := g.Climate(x, z)
+	switch {
+	case t < 0.2:
+		return Tundra
+	case t < 0.4:
+		return Taiga
+	case t > 0.75 && m < 0.35:
+		return Desert
+	case m > 0.55:
+		return Forest
+	}
+	return Plains
+}
+
+// Surface returns the height of the top of the world column x, z, including
+// the oceans, and the Ground color of its biome. It can be used as the
+// mesh.Surface of the far terrain.
+func (g *Generator) Surface(x, z int) (height float32, ground [3]float32) {
+	h := g.Height(x, z)
+	if h < g.SeaLevel {
+		h = g.SeaLevel
+	}
+	c := g.Biome(x, z).Ground
+	return float32(h), [3]float32{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255}
+}
+
+func clamp01(v float64) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return float32(v)
+}